@@ -9,11 +9,19 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/apilog"
+	"github.com/shridarpatil/whatomate/internal/cache"
 	"github.com/shridarpatil/whatomate/internal/config"
 	"github.com/shridarpatil/whatomate/internal/database"
 	"github.com/shridarpatil/whatomate/internal/frontend"
+	"github.com/shridarpatil/whatomate/internal/egress"
 	"github.com/shridarpatil/whatomate/internal/handlers"
 	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/offboarding"
+	"github.com/shridarpatil/whatomate/internal/pricing"
+	"github.com/shridarpatil/whatomate/internal/push"
 	"github.com/shridarpatil/whatomate/internal/queue"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/internal/worker"
@@ -21,12 +29,17 @@ import (
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 	"github.com/zerodha/logf"
+	"gorm.io/gorm"
 )
 
 var (
 	configPath  = flag.String("config", "config.toml", "Path to config file")
 	migrate     = flag.Bool("migrate", false, "Run database migrations")
 	numWorkers  = flag.Int("workers", 1, "Number of workers to run (0 to disable embedded workers)")
+
+	upgradeAPIVersion = flag.String("upgrade-api-version", "", `Move accounts on older Graph API versions to this version ("latest" for the newest known) and exit`)
+	upgradeOrg        = flag.String("upgrade-org", "", "Only upgrade the accounts of this organization ID")
+	upgradeDryRun     = flag.Bool("upgrade-dry-run", false, "List the accounts -upgrade-api-version would move without changing them")
 )
 
 func main() {
@@ -79,6 +92,19 @@ func main() {
 	}
 	lo.Info("Connected to Redis")
 
+	// Keep the tenant cache shared with workers in step with model writes
+	tenantCache := cache.New(rdb, db, lo)
+	models.RegisterCacheInvalidator(tenantCache.OnModelChange)
+
+	// Bulk upgrade accounts' Graph API version if requested, after the cache is
+	// hooked up so running servers see the new versions
+	if *upgradeAPIVersion != "" {
+		if err := runAPIVersionUpgrade(db, lo); err != nil {
+			lo.Fatal("Graph API version upgrade failed", "error", err)
+		}
+		return
+	}
+
 	// Initialize job queue
 	jobQueue := queue.NewRedisQueue(rdb, lo)
 	lo.Info("Job queue initialized")
@@ -86,8 +112,29 @@ func main() {
 	// Initialize Fastglue
 	g := fastglue.NewGlue()
 
-	// Initialize WhatsApp client
+	// Initialize WhatsApp client, routing Graph API calls through the deployment's
+	// egress proxy or the one an organization configures, refusing messages from
+	// organizations being offboarded, and recording calls of accounts being debugged
 	waClient := whatsapp.New(lo)
+	if cfg.WhatsApp.EgressProxy != "" {
+		if err := waClient.SetProxy(cfg.WhatsApp.EgressProxy); err != nil {
+			lo.Fatal("Invalid WhatsApp egress proxy", "error", err)
+		}
+	}
+	egressResolver := egress.NewResolver(db)
+	waClient.ProxyResolver = egressResolver.Proxy
+	offboardingGuard := offboarding.NewGuard(db)
+	waClient.SendGuard = offboardingGuard.Check
+	apiLogRecorder := apilog.NewRecorder(db, lo)
+	waClient.RequestLogger = apiLogRecorder
+	pushService, err := push.New(cfg.Push)
+	if err != nil {
+		lo.Error("Failed to set up push notifications", "error", err)
+	}
+	rateCard, err := pricing.Load(cfg.Pricing.RatesFile)
+	if err != nil {
+		lo.Fatal("Invalid pricing rate card", "error", err)
+	}
 
 	// Initialize WebSocket hub
 	wsHub := websocket.NewHub(lo)
@@ -96,13 +143,18 @@ func main() {
 
 	// Initialize app with dependencies
 	app := &handlers.App{
-		Config:   cfg,
-		DB:       db,
-		Redis:    rdb,
-		Log:      lo,
-		WhatsApp: waClient,
-		WSHub:    wsHub,
-		Queue:    jobQueue,
+		Config:      cfg,
+		DB:          db,
+		Redis:       rdb,
+		Log:         lo,
+		WhatsApp:    waClient,
+		Egress:      egressResolver,
+		Offboarding: offboardingGuard,
+		APILog:      apiLogRecorder,
+		Push:        pushService,
+		Pricing:     rateCard,
+		WSHub:       wsHub,
+		Queue:       jobQueue,
 	}
 
 	// Start campaign stats subscriber for real-time WebSocket updates from worker
@@ -110,10 +162,21 @@ func main() {
 		lo.Error("Failed to start campaign stats subscriber", "error", err)
 	}
 
+	// Deliver campaign lifecycle events from workers to outbound webhooks
+	if err := app.StartCampaignEventsSubscriber(); err != nil {
+		lo.Error("Failed to start campaign events subscriber", "error", err)
+	}
+
+	// Push background job progress from workers and other instances over WebSocket
+	if err := app.StartJobUpdatesSubscriber(); err != nil {
+		lo.Error("Failed to start job updates subscriber", "error", err)
+	}
+
 	// Setup middleware
 	g.Before(middleware.RequestLogger(lo))
 	g.Before(middleware.CORS())
 	g.Before(middleware.Recovery(lo))
+	g.After(middleware.LocalizeErrors())
 
 	// Setup routes
 	setupRoutes(g, app, lo, cfg.Server.BasePath)
@@ -141,6 +204,81 @@ func main() {
 	go slaProcessor.Start(slaCtx)
 	lo.Info("SLA processor started")
 
+	// Start session timeout processor (nudges, expires and hands off inactive chatbot sessions every minute)
+	sessionTimeoutProcessor := handlers.NewSessionTimeoutProcessor(app, time.Minute)
+	sessionTimeoutCtx, sessionTimeoutCancel := context.WithCancel(context.Background())
+	go sessionTimeoutProcessor.Start(sessionTimeoutCtx)
+
+	// Start anomaly processor (compares account rates with their baseline every 15 minutes)
+	anomalyProcessor := handlers.NewAnomalyProcessor(app, 15*time.Minute)
+	anomalyCtx, anomalyCancel := context.WithCancel(context.Background())
+	go anomalyProcessor.Start(anomalyCtx)
+
+	// Start win-back processor (enrolls dormant contacts and sends due steps every 5 minutes)
+	winBackProcessor := handlers.NewWinBackProcessor(app, 5*time.Minute)
+	winBackCtx, winBackCancel := context.WithCancel(context.Background())
+	go winBackProcessor.Start(winBackCtx)
+
+	// Start sequence processor (ends replied enrollments and sends due steps every minute)
+	sequenceProcessor := handlers.NewSequenceProcessor(app, time.Minute)
+	sequenceCtx, sequenceCancel := context.WithCancel(context.Background())
+	go sequenceProcessor.Start(sequenceCtx)
+
+	// Start inbox view count processor (pushes changed view counts to subscribers every 15 seconds)
+	inboxViewCountProcessor := handlers.NewInboxViewCountProcessor(app, 15*time.Second)
+	inboxViewCountCtx, inboxViewCountCancel := context.WithCancel(context.Background())
+	go inboxViewCountProcessor.Start(inboxViewCountCtx)
+
+	// Start notification digest processor (sends due digests every minute)
+	notificationDigestProcessor := handlers.NewNotificationDigestProcessor(app, time.Minute)
+	notificationDigestCtx, notificationDigestCancel := context.WithCancel(context.Background())
+	go notificationDigestProcessor.Start(notificationDigestCtx)
+
+	// Start date trigger processor (checks every 15 minutes for triggers due today)
+	dateTriggerProcessor := handlers.NewDateTriggerProcessor(app, 15*time.Minute)
+	dateTriggerCtx, dateTriggerCancel := context.WithCancel(context.Background())
+	go dateTriggerProcessor.Start(dateTriggerCtx)
+
+	// Start automation trigger processor (runs segment triggers for contacts entering or exiting every 5 minutes)
+	automationTriggerProcessor := handlers.NewAutomationTriggerProcessor(app, 5*time.Minute)
+	automationTriggerCtx, automationTriggerCancel := context.WithCancel(context.Background())
+	go automationTriggerProcessor.Start(automationTriggerCtx)
+
+	// Start campaign scheduler (queues scheduled campaigns whose send time has come, every 30 seconds)
+	campaignSchedulerProcessor := handlers.NewCampaignSchedulerProcessor(app, 30*time.Second)
+	campaignSchedulerCtx, campaignSchedulerCancel := context.WithCancel(context.Background())
+	go campaignSchedulerProcessor.Start(campaignSchedulerCtx)
+
+	// Start template approval processor (polls Meta every 5 minutes for templates campaigns are waiting on)
+	templateApprovalProcessor := handlers.NewTemplateApprovalProcessor(app, 5*time.Minute)
+	templateApprovalCtx, templateApprovalCancel := context.WithCancel(context.Background())
+	go templateApprovalProcessor.Start(templateApprovalCtx)
+
+	// Start webhook subscription processor (checks each account's WABA subscription hourly)
+	webhookSubscriptionProcessor := handlers.NewWebhookSubscriptionProcessor(app, time.Hour)
+	webhookSubscriptionCtx, webhookSubscriptionCancel := context.WithCancel(context.Background())
+	go webhookSubscriptionProcessor.Start(webhookSubscriptionCtx)
+
+	// Start account status processor (checks display name, business verification and green tick status)
+	accountStatusProcessor := handlers.NewAccountStatusProcessor(app, 6*time.Hour)
+	accountStatusCtx, accountStatusCancel := context.WithCancel(context.Background())
+	go accountStatusProcessor.Start(accountStatusCtx)
+
+	// Start status reconciliation processor (checks messages stuck in sent against Meta's analytics hourly)
+	statusReconciliationProcessor := handlers.NewStatusReconciliationProcessor(app, time.Hour)
+	statusReconciliationCtx, statusReconciliationCancel := context.WithCancel(context.Background())
+	go statusReconciliationProcessor.Start(statusReconciliationCtx)
+
+	// Start API call log processor (prunes Graph API debug logs hourly)
+	apiCallLogProcessor := handlers.NewAPICallLogProcessor(app, time.Hour)
+	apiCallLogCtx, apiCallLogCancel := context.WithCancel(context.Background())
+	go apiCallLogProcessor.Start(apiCallLogCtx)
+
+	// Start change stream processor (publishes captured change events every 2 seconds)
+	changeStreamProcessor := handlers.NewChangeStreamProcessor(app, 2*time.Second)
+	changeStreamCtx, changeStreamCancel := context.WithCancel(context.Background())
+	go changeStreamProcessor.Start(changeStreamCtx)
+
 	// Start embedded workers
 	var workers []*worker.Worker
 	var workerCancel context.CancelFunc
@@ -179,6 +317,8 @@ func main() {
 	lo.Info("Stopping campaign stats subscriber...")
 	app.StopCampaignStatsSubscriber()
 	lo.Info("Campaign stats subscriber stopped")
+	app.StopCampaignEventsSubscriber()
+	app.StopJobUpdatesSubscriber()
 
 	// Stop SLA processor
 	lo.Info("Stopping SLA processor...")
@@ -186,6 +326,51 @@ func main() {
 	slaProcessor.Stop()
 	lo.Info("SLA processor stopped")
 
+	sessionTimeoutCancel()
+	sessionTimeoutProcessor.Stop()
+
+	anomalyCancel()
+	anomalyProcessor.Stop()
+
+	winBackCancel()
+	winBackProcessor.Stop()
+
+	sequenceCancel()
+	sequenceProcessor.Stop()
+
+	inboxViewCountCancel()
+	inboxViewCountProcessor.Stop()
+
+	notificationDigestCancel()
+	notificationDigestProcessor.Stop()
+
+	dateTriggerCancel()
+	dateTriggerProcessor.Stop()
+
+	automationTriggerCancel()
+	automationTriggerProcessor.Stop()
+
+	campaignSchedulerCancel()
+	campaignSchedulerProcessor.Stop()
+
+	templateApprovalCancel()
+	templateApprovalProcessor.Stop()
+
+	webhookSubscriptionCancel()
+	webhookSubscriptionProcessor.Stop()
+
+	accountStatusCancel()
+	accountStatusProcessor.Stop()
+
+	statusReconciliationCancel()
+	statusReconciliationProcessor.Stop()
+
+	apiCallLogCancel()
+	apiCallLogProcessor.Stop()
+
+	changeStreamCancel()
+	changeStreamProcessor.Stop()
+
 	// Stop workers first
 	if workerCancel != nil {
 		lo.Info("Stopping workers...", "count", len(workers))
@@ -233,7 +418,7 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 		// Skip auth for public routes
 		if path == "/health" || path == "/ready" ||
 			path == "/api/auth/login" || path == "/api/auth/register" || path == "/api/auth/refresh" ||
-			path == "/api/webhook" || path == "/ws" {
+			path == "/api/webhook" || path == "/api/i18n" || path == "/api/domains/check" || path == "/ws" {
 			return r
 		}
 		// Skip auth for SSO routes (they handle their own auth via state tokens)
@@ -244,9 +429,13 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 		if len(path) >= 28 && path[:28] == "/api/custom-actions/redirect" {
 			return r
 		}
+		// Skip auth for store order webhooks (verified by the store's signature)
+		if len(path) >= 20 && path[:20] == "/api/orders/webhook/" {
+			return r
+		}
 		// Apply auth for all other /api routes (supports both JWT and API key)
 		if len(path) > 4 && path[:4] == "/api" {
-			return middleware.AuthWithDB(app.Config.JWT.Secret, app.DB)(r)
+			return middleware.AuthWithDB(app.Config.JWT.Secret, app.DB, app.Config.Server.TrustProxyHeaders)(r)
 		}
 		return r
 	})
@@ -267,10 +456,20 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 			return r // Auth middleware will handle unauthenticated requests
 		}
 
-		// Admin-only routes: user management, API keys, and SSO settings
+		// Admin-only routes: user management, API keys, BI service accounts, SSO settings, config promotion, email branding, change streams, offboarding, custom reports, the audit log, and dead campaign jobs
 		if (len(path) >= 10 && path[:10] == "/api/users") ||
 			(len(path) >= 13 && path[:13] == "/api/api-keys") ||
-			(len(path) >= 17 && path[:17] == "/api/settings/sso") {
+			(len(path) >= 16 && path[:16] == "/api/bi-accounts") ||
+			(len(path) >= 17 && path[:17] == "/api/settings/sso") ||
+			(len(path) >= 20 && path[:20] == "/api/settings/domain") ||
+			(len(path) >= 20 && path[:20] == "/api/settings/config") ||
+			(len(path) >= 20 && path[:20] == "/api/org/offboarding") ||
+			(len(path) >= 26 && path[:26] == "/api/settings/email-domain") ||
+			(len(path) >= 27 && path[:27] == "/api/settings/change-stream") ||
+			(len(path) >= 36 && path[:36] == "/api/settings/notification-templates") ||
+			(len(path) >= 12 && path[:12] == "/api/reports") ||
+			(len(path) >= 10 && path[:10] == "/api/audit") ||
+			(len(path) >= 18 && path[:18] == "/api/campaign-jobs") {
 			if role != "admin" {
 				r.RequestCtx.SetStatusCode(403)
 				r.RequestCtx.SetBodyString(`{"status":"error","message":"Admin access required"}`)
@@ -300,8 +499,21 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 					"/api/templates",
 					"/api/flows",
 					"/api/campaigns",
+					"/api/holiday-calendars",
+					"/api/blackout-dates",
+					"/api/test-numbers",
+					"/api/label-rules",
+					"/api/win-back",
+					"/api/sequences",
+					"/api/date-triggers",
+					"/api/recurring-campaigns",
+					"/api/automation-triggers",
 					"/api/chatbot",
 					"/api/analytics",
+					"/api/orders",
+					"/api/external-refs",
+					"/api/conversation-shares",
+					"/api/partner",
 				}
 				for _, prefix := range managerRoutes {
 					if len(path) >= len(prefix) && path[:len(prefix)] == prefix {
@@ -323,11 +535,21 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 		return r
 	})
 
+	// Localized strings (public so the login page can use them)
+	g.GET("/api/i18n", app.GetLocaleStrings)
+
 	// Current User (all authenticated users)
 	g.GET("/api/me", app.GetCurrentUser)
 	g.PUT("/api/me/settings", app.UpdateCurrentUserSettings)
 	g.PUT("/api/me/password", app.ChangePassword)
 	g.PUT("/api/me/availability", app.UpdateAvailability)
+	g.PUT("/api/me/locale", app.UpdateCurrentUserLocale)
+	g.GET("/api/me/following", app.ListFollowedConversations)
+	g.GET("/api/me/push-devices", app.ListPushDevices)
+	g.POST("/api/me/push-devices", app.RegisterPushDevice)
+	g.DELETE("/api/me/push-devices/{id}", app.DeletePushDevice)
+	g.GET("/api/me/notification-preferences", app.GetNotificationPreferences)
+	g.PUT("/api/me/notification-preferences", app.UpdateNotificationPreferences)
 
 	// User Management (admin only - enforced by middleware)
 	g.GET("/api/users", app.ListUsers)
@@ -339,7 +561,33 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	// API Keys (admin only - enforced by middleware)
 	g.GET("/api/api-keys", app.ListAPIKeys)
 	g.POST("/api/api-keys", app.CreateAPIKey)
+	g.PUT("/api/api-keys/{id}", app.UpdateAPIKey)
 	g.DELETE("/api/api-keys/{id}", app.DeleteAPIKey)
+	g.GET("/api/api-keys/{id}/usage", app.GetAPIKeyUsage)
+
+	// BI service accounts (admin only - enforced by middleware)
+	g.GET("/api/bi-accounts", app.ListBIServiceAccounts)
+	g.POST("/api/bi-accounts", app.CreateBIServiceAccount)
+	g.GET("/api/bi-accounts/schema", app.GetReportingSchema)
+	g.POST("/api/bi-accounts/{id}/rotate", app.RotateBIServiceAccountPassword)
+	g.DELETE("/api/bi-accounts/{id}", app.DeleteBIServiceAccount)
+
+	// Partners (agencies managing several organizations; membership checked in handlers)
+	g.POST("/api/partners", app.CreatePartner)
+	g.GET("/api/partner", app.GetPartner)
+	g.PUT("/api/partner", app.UpdatePartner)
+	g.GET("/api/partner/organizations", app.ListPartnerOrganizations)
+	g.POST("/api/partner/organizations", app.CreatePartnerOrganization)
+	g.DELETE("/api/partner/organizations/{id}", app.RemovePartnerOrganization)
+	g.PUT("/api/partner/organizations/{id}/branding", app.UpdatePartnerOrganizationBranding)
+	g.POST("/api/partner/members", app.AddPartnerMember)
+	g.DELETE("/api/partner/members/{id}", app.RemovePartnerMember)
+	g.GET("/api/partner/dashboard", app.GetPartnerDashboard)
+	g.GET("/api/partner/billing", app.GetPartnerBilling)
+	g.GET("/api/partner/api-keys", app.ListPartnerAPIKeys)
+	g.POST("/api/partner/api-keys", app.CreatePartnerAPIKey)
+	g.DELETE("/api/partner/api-keys/{id}", app.DeletePartnerAPIKey)
+	g.GET("/api/branding", app.GetBranding)
 
 	// Accounts
 	g.GET("/api/accounts", app.ListAccounts)
@@ -348,6 +596,21 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.PUT("/api/accounts/{id}", app.UpdateAccount)
 	g.DELETE("/api/accounts/{id}", app.DeleteAccount)
 	g.POST("/api/accounts/{id}/test", app.TestAccountConnection)
+	g.POST("/api/accounts/{id}/webhook-subscription/check", app.CheckWebhookSubscription)
+	g.GET("/api/accounts/{id}/health", app.GetAccountHealth)
+	g.POST("/api/accounts/{id}/reconcile-statuses", app.ReconcileMessageStatuses)
+	g.PUT("/api/accounts/{id}/debug-logging", app.SetAccountDebugLogging)
+	g.GET("/api/accounts/{id}/api-calls", app.ListAPICallLogs)
+	g.DELETE("/api/accounts/{id}/api-calls", app.ClearAPICallLogs)
+	g.GET("/api/accounts/api-versions", app.ListAPIVersions)
+	g.POST("/api/accounts/api-versions/upgrade", app.UpgradeAccountsAPIVersion)
+	g.GET("/api/accounts/{id}/migration", app.GetPhoneMigration)
+	g.POST("/api/accounts/{id}/migration", app.StartPhoneMigration)
+	g.DELETE("/api/accounts/{id}/migration", app.CancelPhoneMigration)
+	g.POST("/api/accounts/{id}/migration/request-code", app.RequestPhoneMigrationCode)
+	g.POST("/api/accounts/{id}/migration/verify", app.VerifyPhoneMigrationCode)
+	g.POST("/api/accounts/{id}/migration/complete", app.CompletePhoneMigration)
+	g.GET("/api/accounts/{id}/migration/export", app.ExportPhoneMigrationLinkage)
 
 	// Contacts
 	g.GET("/api/contacts", app.ListContacts)
@@ -356,15 +619,56 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.PUT("/api/contacts/{id}", app.UpdateContact)
 	g.DELETE("/api/contacts/{id}", app.DeleteContact)
 	g.PUT("/api/contacts/{id}/assign", app.AssignContact)
+	g.POST("/api/contacts/bulk-actions", app.BulkConversationAction)
+	g.GET("/api/contacts/{id}/labels", app.GetContactLabels)
+	g.POST("/api/contacts/{id}/labels", app.AddContactLabel)
+	g.DELETE("/api/contacts/{id}/labels/{label_id}", app.RemoveContactLabel)
+
+	// Saved inbox views (private, or shared with a team)
+	g.GET("/api/inbox-views", app.ListInboxViews)
+	g.POST("/api/inbox-views", app.CreateInboxView)
+	g.GET("/api/inbox-views/counts", app.GetInboxViewCounts)
+	g.PUT("/api/inbox-views/{id}", app.UpdateInboxView)
+	g.DELETE("/api/inbox-views/{id}", app.DeleteInboxView)
+	g.POST("/api/inbox-views/{id}/subscribe", app.SubscribeInboxView)
+	g.DELETE("/api/inbox-views/{id}/subscribe", app.UnsubscribeInboxView)
+
+	// Conversation search (query syntax with facets)
+	g.GET("/api/search", app.SearchConversations)
+
+	// Phone number validation, e.g. for integrators cleaning recipient lists
+	g.POST("/api/phone-numbers/validate", app.ValidatePhoneNumbers)
+
+	// Conversation Labels (agents can list; defining labels and rules is manager+)
+	g.GET("/api/labels", app.ListLabels)
+	g.POST("/api/labels", app.CreateLabel)
+	g.PUT("/api/labels/{id}", app.UpdateLabel)
+	g.DELETE("/api/labels/{id}", app.DeleteLabel)
+	g.GET("/api/label-rules", app.ListLabelRules)
+	g.POST("/api/label-rules", app.CreateLabelRule)
+	g.PUT("/api/label-rules/{id}", app.UpdateLabelRule)
+	g.DELETE("/api/label-rules/{id}", app.DeleteLabelRule)
 
 	// Messages
+	g.GET("/api/contacts/{id}/participants", app.ListConversationParticipants)
+	g.GET("/api/contacts/{id}/orders", app.ListContactOrders)
+	g.GET("/api/contacts/{id}/followers", app.ListConversationFollowers)
+	g.POST("/api/contacts/{id}/follow", app.FollowConversation)
+	g.DELETE("/api/contacts/{id}/follow", app.UnfollowConversation)
 	g.GET("/api/contacts/{id}/messages", app.GetMessages)
 	g.POST("/api/contacts/{id}/messages", app.SendMessage)
+	g.GET("/api/link-preview", app.GetLinkPreview)
 	g.POST("/api/contacts/{id}/messages/{message_id}/reaction", app.SendReaction)
+	g.POST("/api/contacts/{id}/stickers", app.SendSticker)
+	g.POST("/api/contacts/{id}/contact-cards", app.SendContactCards)
+	g.POST("/api/contacts/{id}/messages/{message_id}/shared-contacts/{index}", app.SaveSharedContact)
+	g.POST("/api/contacts/{id}/share", app.ShareConversation)
+	g.GET("/api/conversation-share-targets", app.ListConversationShareTargets)
 	g.POST("/api/messages", app.SendMessage) // Legacy route
 	g.POST("/api/messages/template", app.SendTemplateMessage)
 	g.POST("/api/messages/media", app.SendMediaMessage)
 	g.PUT("/api/messages/{id}/read", app.MarkMessageRead)
+	g.GET("/api/messages/{id}/timeline", app.GetMessageTimeline)
 
 	// Media (serves media files for messages, auth-protected)
 	g.GET("/api/media/{message_id}", app.ServeMedia)
@@ -375,7 +679,10 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.GET("/api/templates/{id}", app.GetTemplate)
 	g.PUT("/api/templates/{id}", app.UpdateTemplate)
 	g.DELETE("/api/templates/{id}", app.DeleteTemplate)
+	g.GET("/api/templates/{id}/usage", app.GetTemplateUsage)
+	g.PUT("/api/templates/{id}/param-sources", app.UpdateTemplateParamSources)
 	g.POST("/api/templates/sync", app.SyncTemplates)
+	g.POST("/api/templates/migrate", app.MigrateTemplates)
 	g.POST("/api/templates/{id}/publish", app.SubmitTemplate)
 
 	// WhatsApp Flows
@@ -392,16 +699,45 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	// Bulk Campaigns
 	g.GET("/api/campaigns", app.ListCampaigns)
 	g.POST("/api/campaigns", app.CreateCampaign)
+	g.POST("/api/campaigns/capacity-plan", app.PlanCampaignCapacity)
+	g.POST("/api/campaigns/from-selection", app.CreateCampaignFromSelection)
 	g.GET("/api/campaigns/{id}", app.GetCampaign)
 	g.PUT("/api/campaigns/{id}", app.UpdateCampaign)
 	g.DELETE("/api/campaigns/{id}", app.DeleteCampaign)
 	g.POST("/api/campaigns/{id}/start", app.StartCampaign)
+	g.GET("/api/campaigns/{id}/dry-run", app.GetCampaignDryRun)
 	g.POST("/api/campaigns/{id}/pause", app.PauseCampaign)
 	g.POST("/api/campaigns/{id}/cancel", app.CancelCampaign)
 	g.POST("/api/campaigns/{id}/retry-failed", app.RetryFailed)
 	g.GET("/api/campaigns/{id}/progress", app.GetCampaign)
+	g.GET("/api/campaigns/{id}/variants", app.GetCampaignVariantStats)
+	g.GET("/api/campaigns/{id}/preview", app.PreviewCampaign)
+	g.POST("/api/campaigns/{id}/estimate", app.EstimateCampaign)
+	g.POST("/api/campaigns/{id}/test-send", app.TestSendCampaign)
 	g.POST("/api/campaigns/{id}/recipients/import", app.ImportRecipients)
+	g.POST("/api/campaigns/{id}/recipients/skip", app.SkipRecipients)
 	g.GET("/api/campaigns/{id}/recipients", app.GetCampaignRecipients)
+	g.GET("/api/campaigns/{id}/recipients/{recipient_id}/timeline", app.GetCampaignRecipientTimeline)
+
+	// Dead campaign jobs (failed too many times to be retried automatically)
+	g.GET("/api/campaign-jobs/dead", app.ListDeadCampaignJobs)
+	g.POST("/api/campaign-jobs/dead/{id}/requeue", app.RequeueDeadCampaignJob)
+	g.DELETE("/api/campaign-jobs/dead/{id}", app.DeleteDeadCampaignJob)
+
+	// Holiday calendars and campaign blackout dates
+	g.GET("/api/holiday-calendars", app.ListHolidayCalendars)
+	g.POST("/api/holiday-calendars", app.CreateHolidayCalendar)
+	g.PUT("/api/holiday-calendars/{id}", app.UpdateHolidayCalendar)
+	g.DELETE("/api/holiday-calendars/{id}", app.DeleteHolidayCalendar)
+	g.POST("/api/holiday-calendars/{id}/sync", app.SyncHolidayCalendar)
+	g.GET("/api/blackout-dates", app.ListBlackoutDates)
+	g.POST("/api/blackout-dates", app.CreateBlackoutDate)
+	g.DELETE("/api/blackout-dates/{id}", app.DeleteBlackoutDate)
+
+	// Test numbers
+	g.GET("/api/test-numbers", app.ListTestNumbers)
+	g.POST("/api/test-numbers", app.CreateTestNumber)
+	g.DELETE("/api/test-numbers/{id}", app.DeleteTestNumber)
 
 	// Chatbot Settings
 	g.GET("/api/chatbot/settings", app.GetChatbotSettings)
@@ -420,6 +756,11 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.GET("/api/chatbot/flows/{id}", app.GetChatbotFlow)
 	g.PUT("/api/chatbot/flows/{id}", app.UpdateChatbotFlow)
 	g.DELETE("/api/chatbot/flows/{id}", app.DeleteChatbotFlow)
+	g.POST("/api/chatbot/flows/{id}/simulate", app.SimulateChatbotFlow)
+	g.POST("/api/chatbot/flows/{id}/publish", app.PublishChatbotFlow)
+	g.GET("/api/chatbot/flows/{id}/versions", app.ListChatbotFlowVersions)
+	g.POST("/api/chatbot/flows/{id}/rollback", app.RollbackChatbotFlow)
+	g.GET("/api/chatbot/flows/{id}/analytics", app.GetChatbotFlowAnalytics)
 
 	// AI Contexts
 	g.GET("/api/chatbot/ai-contexts", app.ListAIContexts)
@@ -428,6 +769,12 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.PUT("/api/chatbot/ai-contexts/{id}", app.UpdateAIContext)
 	g.DELETE("/api/chatbot/ai-contexts/{id}", app.DeleteAIContext)
 
+	// Flow credentials for HTTP request steps
+	g.GET("/api/chatbot/credentials", app.ListFlowCredentials)
+	g.POST("/api/chatbot/credentials", app.CreateFlowCredential)
+	g.PUT("/api/chatbot/credentials/{id}", app.UpdateFlowCredential)
+	g.DELETE("/api/chatbot/credentials/{id}", app.DeleteFlowCredential)
+
 	// Agent Transfers
 	g.GET("/api/chatbot/transfers", app.ListAgentTransfers)
 	g.POST("/api/chatbot/transfers", app.CreateAgentTransfer)
@@ -453,6 +800,22 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.DELETE("/api/canned-responses/{id}", app.DeleteCannedResponse)
 	g.POST("/api/canned-responses/{id}/use", app.IncrementCannedResponseUsage)
 
+	// Broadcast Lists (agents manage their own)
+	g.GET("/api/broadcast-lists", app.ListBroadcastLists)
+	g.POST("/api/broadcast-lists", app.CreateBroadcastList)
+	g.GET("/api/broadcast-lists/{id}", app.GetBroadcastList)
+	g.PUT("/api/broadcast-lists/{id}", app.UpdateBroadcastList)
+	g.DELETE("/api/broadcast-lists/{id}", app.DeleteBroadcastList)
+	g.POST("/api/broadcast-lists/{id}/members", app.AddBroadcastListMembers)
+	g.DELETE("/api/broadcast-lists/{id}/members/{contact_id}", app.RemoveBroadcastListMember)
+	g.POST("/api/broadcast-lists/{id}/send", app.SendBroadcastList)
+
+	// Sticker Library
+	g.GET("/api/stickers", app.ListStickers)
+	g.POST("/api/stickers", app.CreateSticker)
+	g.GET("/api/stickers/{id}/file", app.ServeSticker)
+	g.DELETE("/api/stickers/{id}", app.DeleteSticker)
+
 	// Sessions (admin/debug)
 	g.GET("/api/chatbot/sessions", app.ListChatbotSessions)
 	g.GET("/api/chatbot/sessions/{id}", app.GetChatbotSession)
@@ -464,16 +827,143 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.GET("/api/analytics/agents", app.GetAgentAnalytics)
 	g.GET("/api/analytics/agents/{id}", app.GetAgentDetails)
 	g.GET("/api/analytics/agents/comparison", app.GetAgentComparison)
+	g.GET("/api/analytics/labels", app.GetLabelAnalytics)
+	g.GET("/api/analytics/anomalies", app.ListMetricAnomalies)
+	g.PUT("/api/analytics/anomalies/{id}/acknowledge", app.AcknowledgeMetricAnomaly)
+	g.GET("/api/analytics/status-reconciliations", app.ListStatusReconciliations)
+
+	// Custom Reports (admin only - enforced by middleware)
+	g.GET("/api/reports", app.ListReports)
+	g.POST("/api/reports", app.CreateReport)
+	g.GET("/api/reports/fields", app.GetReportFields)
+	g.GET("/api/reports/{id}", app.GetReport)
+	g.PUT("/api/reports/{id}", app.UpdateReport)
+	g.DELETE("/api/reports/{id}", app.DeleteReport)
+	g.POST("/api/reports/{id}/run", app.RunReport)
+	g.GET("/api/reports/{id}/runs", app.ListReportRuns)
+	g.GET("/api/reports/{id}/runs/{run_id}", app.GetReportRun)
+	g.GET("/api/reports/{id}/runs/{run_id}/csv", app.DownloadReportRun)
+
+	// Background job progress
+	g.GET("/api/jobs", app.ListJobs)
+	g.GET("/api/jobs/{id}", app.GetJob)
+
+	// Orders and revenue attribution
+	g.GET("/api/orders", app.ListOrders)
+	g.POST("/api/orders", app.CreateOrder)
+	g.GET("/api/orders/connectors", app.ListOrderConnectors)
+	g.POST("/api/orders/connectors", app.CreateOrderConnector)
+	g.DELETE("/api/orders/connectors/{id}", app.DeleteOrderConnector)
+	g.POST("/api/orders/webhook/{id}", app.ReceiveOrderWebhook)
+	g.GET("/api/orders/{id}", app.GetOrder)
+	g.PUT("/api/orders/{id}", app.UpdateOrder)
+	g.DELETE("/api/orders/{id}", app.DeleteOrder)
+
+	// External References (CRM IDs for contacts, conversations and campaigns)
+	g.GET("/api/external-refs", app.ListExternalReferences)
+	g.PUT("/api/external-refs", app.SetExternalReference)
+	g.GET("/api/external-refs/lookup", app.LookupExternalReference)
+	g.DELETE("/api/external-refs/{id}", app.DeleteExternalReference)
+
+	// Conversation Shares (handovers between partner organizations)
+	g.GET("/api/conversation-shares", app.ListConversationShares)
+	g.GET("/api/conversation-shares/{id}", app.GetConversationShare)
+	g.POST("/api/conversation-shares/{id}/accept", app.AcceptConversationShare)
+	g.POST("/api/conversation-shares/{id}/decline", app.DeclineConversationShare)
+	g.POST("/api/conversation-shares/{id}/revoke", app.RevokeConversationShare)
+
+	// Message Audit Log (admin only - enforced by middleware)
+	g.GET("/api/audit/messages", app.ListMessageAuditRecords)
+	g.GET("/api/audit/messages/verify", app.VerifyMessageAuditChain)
+	g.GET("/api/audit/messages/{message_id}", app.GetMessageAuditRecord)
+
+	// Win-back Automations
+	g.GET("/api/win-back", app.ListWinBackAutomations)
+	g.POST("/api/win-back", app.CreateWinBackAutomation)
+	g.GET("/api/win-back/{id}", app.GetWinBackAutomation)
+	g.PUT("/api/win-back/{id}", app.UpdateWinBackAutomation)
+	g.DELETE("/api/win-back/{id}", app.DeleteWinBackAutomation)
+	g.GET("/api/win-back/{id}/enrollments", app.ListWinBackEnrollments)
+
+	// Drip Sequences
+	g.GET("/api/sequences", app.ListSequences)
+	g.POST("/api/sequences", app.CreateSequence)
+	g.GET("/api/sequences/{id}", app.GetSequence)
+	g.PUT("/api/sequences/{id}", app.UpdateSequence)
+	g.DELETE("/api/sequences/{id}", app.DeleteSequence)
+	g.GET("/api/sequences/{id}/enrollments", app.ListSequenceEnrollments)
+	g.POST("/api/sequences/{id}/enrollments", app.EnrollSequenceContacts)
+	g.DELETE("/api/sequences/{id}/enrollments/{enrollment_id}", app.UnenrollSequenceContact)
+
+	// Date Triggers
+	g.GET("/api/date-triggers", app.ListDateTriggers)
+	g.POST("/api/date-triggers", app.CreateDateTrigger)
+	g.GET("/api/date-triggers/{id}", app.GetDateTrigger)
+	g.PUT("/api/date-triggers/{id}", app.UpdateDateTrigger)
+	g.DELETE("/api/date-triggers/{id}", app.DeleteDateTrigger)
+	g.GET("/api/date-triggers/{id}/preview", app.PreviewDateTrigger)
+
+	// Recurring Campaigns
+	g.GET("/api/recurring-campaigns", app.ListRecurringCampaigns)
+	g.POST("/api/recurring-campaigns", app.CreateRecurringCampaign)
+	g.GET("/api/recurring-campaigns/{id}", app.GetRecurringCampaign)
+	g.PUT("/api/recurring-campaigns/{id}", app.UpdateRecurringCampaign)
+	g.DELETE("/api/recurring-campaigns/{id}", app.DeleteRecurringCampaign)
+
+	// Automation Triggers
+	g.GET("/api/automation-triggers", app.ListAutomationTriggers)
+	g.POST("/api/automation-triggers", app.CreateAutomationTrigger)
+	g.POST("/api/automation-triggers/link-clicks", app.ReportLinkClick)
+	g.GET("/api/automation-triggers/{id}", app.GetAutomationTrigger)
+	g.PUT("/api/automation-triggers/{id}", app.UpdateAutomationTrigger)
+	g.DELETE("/api/automation-triggers/{id}", app.DeleteAutomationTrigger)
+	g.GET("/api/automation-triggers/{id}/runs", app.ListAutomationTriggerRuns)
 
 	// Organization Settings
 	g.GET("/api/org/settings", app.GetOrganizationSettings)
 	g.PUT("/api/org/settings", app.UpdateOrganizationSettings)
 
+	// Organization offboarding (admin only - enforced by middleware)
+	g.GET("/api/org/offboarding", app.GetOffboarding)
+	g.POST("/api/org/offboarding", app.RequestOffboarding)
+	g.POST("/api/org/offboarding/cancel", app.CancelOffboarding)
+	g.GET("/api/org/offboarding/archive", app.DownloadOffboardingArchive)
+
 	// SSO Settings (admin only - enforced by middleware)
 	g.GET("/api/settings/sso", app.GetSSOSettings)
 	g.PUT("/api/settings/sso/{provider}", app.UpdateSSOProvider)
 	g.DELETE("/api/settings/sso/{provider}", app.DeleteSSOProvider)
 
+	// Custom Domain (admin only - enforced by middleware)
+	g.GET("/api/settings/domain", app.GetCustomDomain)
+	g.PUT("/api/settings/domain", app.SetCustomDomain)
+	g.POST("/api/settings/domain/verify", app.VerifyCustomDomain)
+	g.DELETE("/api/settings/domain", app.DeleteCustomDomain)
+
+	// Branded emails and notifications (admin only - enforced by middleware)
+	g.GET("/api/settings/email-domain", app.GetEmailSenderDomain)
+	g.PUT("/api/settings/email-domain", app.SetEmailSenderDomain)
+	g.POST("/api/settings/email-domain/verify", app.VerifyEmailSenderDomain)
+	g.DELETE("/api/settings/email-domain", app.DeleteEmailSenderDomain)
+	g.GET("/api/settings/notification-templates", app.ListNotificationTemplates)
+	g.PUT("/api/settings/notification-templates/{key}", app.UpdateNotificationTemplate)
+	g.DELETE("/api/settings/notification-templates/{key}", app.ResetNotificationTemplate)
+	g.POST("/api/settings/notification-templates/{key}/preview", app.PreviewNotificationTemplate)
+
+	// Change data capture stream (admin only - enforced by middleware)
+	g.GET("/api/settings/change-stream", app.GetChangeStream)
+	g.PUT("/api/settings/change-stream", app.SetChangeStream)
+	g.DELETE("/api/settings/change-stream", app.DeleteChangeStream)
+	g.POST("/api/settings/change-stream/test", app.TestChangeStream)
+	g.GET("/api/settings/change-stream/schemas", app.GetChangeStreamSchemas)
+
+	// Config promotion (admin only - enforced by middleware)
+	g.GET("/api/settings/config/export", app.ExportConfig)
+	g.POST("/api/settings/config/import", app.ImportConfig)
+
+	// Custom domain lookup for reverse proxies issuing on-demand TLS (public)
+	g.GET("/api/domains/check", app.CheckCustomDomain)
+
 	// Webhooks
 	g.GET("/api/webhooks", app.ListWebhooks)
 	g.POST("/api/webhooks", app.CreateWebhook)
@@ -481,6 +971,7 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 	g.PUT("/api/webhooks/:id", app.UpdateWebhook)
 	g.DELETE("/api/webhooks/:id", app.DeleteWebhook)
 	g.POST("/api/webhooks/:id/test", app.TestWebhook)
+	g.POST("/api/webhooks/:id/rotate-secret", app.RotateWebhookSecret)
 
 	// Custom Actions
 	g.GET("/api/custom-actions", app.ListCustomActions)
@@ -522,3 +1013,36 @@ func setupRoutes(g *fastglue.Fastglue, app *handlers.App, lo logf.Logger, basePa
 		lo.Info("Frontend not embedded, API-only mode")
 	}
 }
+
+// runAPIVersionUpgrade performs -upgrade-api-version, logging each account it moves
+func runAPIVersionUpgrade(db *gorm.DB, lo logf.Logger) error {
+	version := *upgradeAPIVersion
+	if version == "latest" {
+		version = whatsapp.LatestAPIVersion()
+	}
+
+	orgID := uuid.Nil
+	if *upgradeOrg != "" {
+		id, err := uuid.Parse(*upgradeOrg)
+		if err != nil {
+			return fmt.Errorf("invalid organization ID %q", *upgradeOrg)
+		}
+		orgID = id
+	}
+
+	upgrades, err := handlers.UpgradeAPIVersions(db, orgID, version, *upgradeDryRun)
+	for _, u := range upgrades {
+		lo.Info("Account Graph API version upgrade",
+			"organization_id", u.OrganizationID,
+			"account", u.Name,
+			"from", u.From,
+			"to", u.To,
+			"dry_run", *upgradeDryRun,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	lo.Info("Graph API version upgrade finished", "accounts", len(upgrades), "dry_run", *upgradeDryRun)
+	return nil
+}
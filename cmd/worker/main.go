@@ -6,9 +6,12 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/shridarpatil/whatomate/internal/cache"
 	"github.com/shridarpatil/whatomate/internal/config"
 	"github.com/shridarpatil/whatomate/internal/database"
+	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/internal/worker"
 	"github.com/zerodha/logf"
 )
@@ -60,6 +63,9 @@ func main() {
 	}
 	lo.Info("Connected to Redis")
 
+	// Keep the tenant cache in step with any model writes made by this process
+	models.RegisterCacheInvalidator(cache.New(rdb, db, lo).OnModelChange)
+
 	// Create worker
 	w, err := worker.New(cfg, db, rdb, lo)
 	if err != nil {
@@ -85,6 +91,16 @@ func main() {
 	case sig := <-quit:
 		lo.Info("Received shutdown signal", "signal", sig)
 		cancel()
+
+		// Stop taking recipients but let the sends in progress finish, so their outcome
+		// is recorded; a second signal stops straight away
+		select {
+		case <-errCh:
+		case <-quit:
+			lo.Warn("Received second shutdown signal, not waiting for sends in progress")
+		case <-time.After(time.Duration(cfg.Worker.ShutdownTimeout) * time.Second):
+			lo.Warn("Timed out waiting for sends in progress", "timeout_seconds", cfg.Worker.ShutdownTimeout)
+		}
 	case err := <-errCh:
 		if err != nil && err != context.Canceled {
 			lo.Fatal("Worker error", "error", err)
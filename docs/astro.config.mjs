@@ -37,6 +37,7 @@ export default defineConfig({
             { label: 'Overview', slug: 'api-reference/overview' },
             { label: 'Authentication', slug: 'api-reference/authentication' },
             { label: 'API Keys', slug: 'api-reference/api-keys' },
+            { label: 'BI Access', slug: 'api-reference/bi-access' },
             { label: 'Users', slug: 'api-reference/users' },
             { label: 'Accounts', slug: 'api-reference/accounts' },
             { label: 'Contacts', slug: 'api-reference/contacts' },
@@ -47,6 +48,8 @@ export default defineConfig({
             { label: 'Chatbot', slug: 'api-reference/chatbot' },
             { label: 'Canned Responses', slug: 'api-reference/canned-responses' },
             { label: 'Webhooks', slug: 'api-reference/webhooks' },
+            { label: 'Change Stream', slug: 'api-reference/change-stream' },
+            { label: 'Jobs', slug: 'api-reference/jobs' },
             { label: 'Analytics', slug: 'api-reference/analytics' },
           ],
         },
@@ -98,7 +98,24 @@ export const usersService = {
   changePassword: (data: { current_password: string; new_password: string }) =>
     api.put('/me/password', data),
   updateAvailability: (isAvailable: boolean) =>
-    api.put('/me/availability', { is_available: isAvailable })
+    api.put('/me/availability', { is_available: isAvailable }),
+  pushDevices: {
+    list: () => api.get('/me/push-devices'),
+    register: (data: { platform: 'fcm' | 'apns'; token: string; device_name?: string }) =>
+      api.post('/me/push-devices', data),
+    delete: (id: string) => api.delete(`/me/push-devices/${id}`)
+  },
+  notificationPreferences: () => api.get('/me/notification-preferences'),
+  updateNotificationPreferences: (data: {
+    mode: 'instant' | 'digest' | 'muted'
+    digest_interval_mins?: number
+    muted_until?: string | null
+    muted_team_ids?: string[]
+    working_hours_start: string
+    working_hours_end: string
+    working_days: string[]
+    timezone: string
+  }) => api.put('/me/notification-preferences', data)
 }
 
 export const apiKeysService = {
@@ -108,16 +125,71 @@ export const apiKeysService = {
   delete: (id: string) => api.delete(`/api-keys/${id}`)
 }
 
+export const changeStreamService = {
+  get: () => api.get('/settings/change-stream'),
+  update: (data: {
+    sink: 'redis' | 'kafka'
+    target?: string
+    events?: string[]
+    is_enabled?: boolean
+    max_len?: number
+    redis_url?: string
+    kafka_rest_url?: string
+    kafka_username?: string
+    kafka_password?: string
+  }) => api.put('/settings/change-stream', data),
+  delete: () => api.delete('/settings/change-stream'),
+  test: () => api.post('/settings/change-stream/test'),
+  getSchemas: () => api.get('/settings/change-stream/schemas')
+}
+
+export const biAccountsService = {
+  list: () => api.get('/bi-accounts'),
+  create: (data: { name: string }) => api.post('/bi-accounts', data),
+  rotate: (id: string) => api.post(`/bi-accounts/${id}/rotate`),
+  delete: (id: string) => api.delete(`/bi-accounts/${id}`),
+  getSchema: () => api.get('/bi-accounts/schema')
+}
+
+export const partnerService = {
+  create: (data: { name: string; billing_email?: string }) => api.post('/partners', data),
+  get: () => api.get('/partner'),
+  update: (data: any) => api.put('/partner', data),
+  listOrganizations: () => api.get('/partner/organizations'),
+  createOrganization: (data: { name: string; admin_email: string; admin_password: string; admin_full_name: string }) =>
+    api.post('/partner/organizations', data),
+  removeOrganization: (id: string) => api.delete(`/partner/organizations/${id}`),
+  updateBranding: (id: string, data: any) => api.put(`/partner/organizations/${id}/branding`, data),
+  addMember: (data: { email: string; role?: string }) => api.post('/partner/members', data),
+  removeMember: (id: string) => api.delete(`/partner/members/${id}`),
+  dashboard: (params?: { from?: string; to?: string }) => api.get('/partner/dashboard', { params }),
+  billing: (params?: { month?: string }) => api.get('/partner/billing', { params }),
+  listAPIKeys: () => api.get('/partner/api-keys'),
+  createAPIKey: (data: { name: string; expires_at?: string; organization_ids?: string[]; scopes?: string[] }) =>
+    api.post('/partner/api-keys', data),
+  deleteAPIKey: (id: string) => api.delete(`/partner/api-keys/${id}`),
+  branding: () => api.get('/branding')
+}
+
 export const accountsService = {
   list: () => api.get('/accounts'),
   get: (id: string) => api.get(`/accounts/${id}`),
   create: (data: any) => api.post('/accounts', data),
   update: (id: string, data: any) => api.put(`/accounts/${id}`, data),
-  delete: (id: string) => api.delete(`/accounts/${id}`)
+  delete: (id: string) => api.delete(`/accounts/${id}`),
+  reconcileStatuses: (id: string) => api.post(`/accounts/${id}/reconcile-statuses`),
+  setDebugLogging: (id: string, data: { enabled: boolean; hours?: number }) =>
+    api.put(`/accounts/${id}/debug-logging`, data),
+  apiCalls: (id: string, params?: { failed?: boolean; limit?: number }) =>
+    api.get(`/accounts/${id}/api-calls`, { params }),
+  clearApiCalls: (id: string) => api.delete(`/accounts/${id}/api-calls`),
+  listApiVersions: () => api.get('/accounts/api-versions'),
+  upgradeApiVersions: (data: { version?: string; dry_run?: boolean }) =>
+    api.post('/accounts/api-versions/upgrade', data)
 }
 
 export const contactsService = {
-  list: (params?: { search?: string; page?: number; limit?: number }) =>
+  list: (params?: { search?: string; page?: number; limit?: number; view?: string }) =>
     api.get('/contacts', { params }),
   get: (id: string) => api.get(`/contacts/${id}`),
   create: (data: any) => api.post('/contacts', data),
@@ -134,15 +206,44 @@ export const contactsService = {
   }
 }
 
+export const inboxViewsService = {
+  list: () => api.get('/inbox-views'),
+  create: (data: any) => api.post('/inbox-views', data),
+  update: (id: string, data: any) => api.put(`/inbox-views/${id}`, data),
+  delete: (id: string) => api.delete(`/inbox-views/${id}`),
+  counts: () => api.get('/inbox-views/counts'),
+  subscribe: (id: string) => api.post(`/inbox-views/${id}/subscribe`),
+  unsubscribe: (id: string) => api.delete(`/inbox-views/${id}/subscribe`)
+}
+
+export const searchService = {
+  search: (params: { q: string; page?: number; limit?: number }) =>
+    api.get('/search', { params })
+}
+
 export const messagesService = {
   list: (contactId: string, params?: { page?: number; limit?: number; before_id?: string }) =>
     api.get(`/contacts/${contactId}/messages`, { params }),
-  send: (contactId: string, data: { type: string; content: any; reply_to_message_id?: string }) =>
+  send: (contactId: string, data: { type: string; content: any; reply_to_message_id?: string; preview_url?: boolean }) =>
     api.post(`/contacts/${contactId}/messages`, data),
   sendTemplate: (contactId: string, data: { template_name: string; components?: any[] }) =>
     api.post(`/contacts/${contactId}/messages/template`, data),
   sendReaction: (contactId: string, messageId: string, emoji: string) =>
-    api.post(`/contacts/${contactId}/messages/${messageId}/reaction`, { emoji })
+    api.post(`/contacts/${contactId}/messages/${messageId}/reaction`, { emoji }),
+  timeline: (messageId: string) => api.get(`/messages/${messageId}/timeline`),
+  linkPreview: (url: string) => api.get('/link-preview', { params: { url } }),
+  share: (id: string, data: { target_organization_id: string; note?: string; message_limit?: number; customer_consent: boolean }) =>
+    api.post(`/contacts/${id}/share`, data),
+  shareTargets: () => api.get('/conversation-share-targets')
+}
+
+export const conversationSharesService = {
+  list: (params?: { direction?: 'incoming' | 'outgoing'; status?: string }) =>
+    api.get('/conversation-shares', { params }),
+  get: (id: string) => api.get(`/conversation-shares/${id}`),
+  accept: (id: string) => api.post(`/conversation-shares/${id}/accept`),
+  decline: (id: string) => api.post(`/conversation-shares/${id}/decline`),
+  revoke: (id: string) => api.post(`/conversation-shares/${id}/revoke`)
 }
 
 export const templatesService = {
@@ -151,6 +252,8 @@ export const templatesService = {
   get: (id: string) => api.get(`/templates/${id}`),
   create: (data: any) => api.post('/templates', data),
   update: (id: string, data: any) => api.put(`/templates/${id}`, data),
+  updateParamSources: (id: string, paramSources: Record<string, string>) =>
+    api.put(`/templates/${id}/param-sources`, { param_sources: paramSources }),
   delete: (id: string) => api.delete(`/templates/${id}`),
   sync: () => api.post('/templates/sync')
 }
@@ -173,15 +276,80 @@ export const campaignsService = {
   create: (data: any) => api.post('/campaigns', data),
   update: (id: string, data: any) => api.put(`/campaigns/${id}`, data),
   delete: (id: string) => api.delete(`/campaigns/${id}`),
-  start: (id: string) => api.post(`/campaigns/${id}/start`),
+  planCapacity: (data: {
+    campaigns: { campaign_id?: string; whatsapp_account?: string; recipients?: number }[]
+    deadline_days?: number
+  }) => api.post('/campaigns/capacity-plan', data),
+  createFromSelection: (data: {
+    contact_ids: string[]
+    template_id: string
+    name?: string
+    whatsapp_account?: string
+    template_params?: Record<string, string>
+  }) => api.post('/campaigns/from-selection', data),
+  start: (id: string, confirm = false) =>
+    api.post(`/campaigns/${id}/start`, null, { params: confirm ? { confirm: true } : undefined }),
+  dryRun: (id: string) => api.post(`/campaigns/${id}/start`, null, { params: { dry_run: true } }),
+  getDryRun: (id: string, params?: { outcome?: string; page?: number; limit?: number }) =>
+    api.get(`/campaigns/${id}/dry-run`, { params }),
   pause: (id: string) => api.post(`/campaigns/${id}/pause`),
   cancel: (id: string) => api.post(`/campaigns/${id}/cancel`),
-  retryFailed: (id: string) => api.post(`/campaigns/${id}/retry-failed`),
+  retryFailed: (id: string, data?: { error_classes?: string[] }) => api.post(`/campaigns/${id}/retry-failed`, data),
   stats: (id: string) => api.get(`/campaigns/${id}/stats`),
+  variantStats: (id: string) => api.get(`/campaigns/${id}/variants`),
+  preview: (id: string) => api.get(`/campaigns/${id}/preview`),
+  estimate: (id: string) => api.post(`/campaigns/${id}/estimate`),
+  testSend: (id: string, data?: { phone_numbers?: string[]; template_params?: Record<string, any> }) =>
+    api.post(`/campaigns/${id}/test-send`, data),
   // Recipients
   getRecipients: (id: string) => api.get(`/campaigns/${id}/recipients`),
-  addRecipients: (id: string, recipients: Array<{ phone_number: string; recipient_name?: string; template_params?: Record<string, any> }>) =>
-    api.post(`/campaigns/${id}/recipients/import`, { recipients })
+  recipientTimeline: (id: string, recipientId: string) => api.get(`/campaigns/${id}/recipients/${recipientId}/timeline`),
+  addRecipients: (id: string, recipients: Array<{ phone_number: string; recipient_name?: string; template_params?: Record<string, any> }>, async = false) =>
+    api.post(`/campaigns/${id}/recipients/import`, { recipients }, { params: async ? { async: true } : undefined }),
+  skipRecipients: (id: string, data: { phone_numbers: string[]; reason?: string }) =>
+    api.post(`/campaigns/${id}/recipients/skip`, data)
+}
+
+export const jobsService = {
+  list: (params?: { type?: string; status?: string }) => api.get('/jobs', { params }),
+  get: (id: string) => api.get(`/jobs/${id}`)
+}
+
+export const testNumbersService = {
+  list: () => api.get('/test-numbers'),
+  create: (data: { phone_number: string; label?: string }) => api.post('/test-numbers', data),
+  delete: (id: string) => api.delete(`/test-numbers/${id}`)
+}
+
+export const campaignJobsService = {
+  listDead: () => api.get('/campaign-jobs/dead'),
+  requeueDead: (id: string) => api.post(`/campaign-jobs/dead/${id}/requeue`),
+  deleteDead: (id: string) => api.delete(`/campaign-jobs/dead/${id}`)
+}
+
+export const sequencesService = {
+  list: () => api.get('/sequences'),
+  get: (id: string) => api.get(`/sequences/${id}`),
+  create: (data: any) => api.post('/sequences', data),
+  update: (id: string, data: any) => api.put(`/sequences/${id}`, data),
+  delete: (id: string) => api.delete(`/sequences/${id}`),
+  enrollments: (id: string, params?: { status?: string; page?: number; limit?: number }) =>
+    api.get(`/sequences/${id}/enrollments`, { params }),
+  enroll: (id: string, data: { contact_ids?: string[]; broadcast_list_id?: string }) =>
+    api.post(`/sequences/${id}/enrollments`, data),
+  unenroll: (id: string, enrollmentId: string) => api.delete(`/sequences/${id}/enrollments/${enrollmentId}`)
+}
+
+export const automationTriggersService = {
+  list: () => api.get('/automation-triggers'),
+  get: (id: string) => api.get(`/automation-triggers/${id}`),
+  create: (data: any) => api.post('/automation-triggers', data),
+  update: (id: string, data: any) => api.put(`/automation-triggers/${id}`, data),
+  delete: (id: string) => api.delete(`/automation-triggers/${id}`),
+  runs: (id: string, params?: { status?: string; limit?: number }) =>
+    api.get(`/automation-triggers/${id}/runs`, { params }),
+  reportLinkClick: (data: { contact_id?: string; phone_number?: string; url: string; campaign_id?: string }) =>
+    api.post('/automation-triggers/link-clicks', data)
 }
 
 export const chatbotService = {
@@ -202,6 +370,12 @@ export const chatbotService = {
   createFlow: (data: any) => api.post('/chatbot/flows', data),
   updateFlow: (id: string, data: any) => api.put(`/chatbot/flows/${id}`, data),
   deleteFlow: (id: string) => api.delete(`/chatbot/flows/${id}`),
+  simulateFlow: (id: string, data: any) => api.post(`/chatbot/flows/${id}/simulate`, data),
+  publishFlow: (id: string, data?: { notes?: string }) => api.post(`/chatbot/flows/${id}/publish`, data),
+  listFlowVersions: (id: string) => api.get(`/chatbot/flows/${id}/versions`),
+  rollbackFlow: (id: string, data?: { version?: number }) => api.post(`/chatbot/flows/${id}/rollback`, data),
+  flowAnalytics: (id: string, params?: { from?: string; to?: string; version?: number }) =>
+    api.get(`/chatbot/flows/${id}/analytics`, { params }),
 
   // AI Contexts
   listAIContexts: () => api.get('/chatbot/ai-contexts'),
@@ -210,6 +384,12 @@ export const chatbotService = {
   updateAIContext: (id: string, data: any) => api.put(`/chatbot/ai-contexts/${id}`, data),
   deleteAIContext: (id: string) => api.delete(`/chatbot/ai-contexts/${id}`),
 
+  // Flow credentials
+  listCredentials: () => api.get('/chatbot/credentials'),
+  createCredential: (data: any) => api.post('/chatbot/credentials', data),
+  updateCredential: (id: string, data: any) => api.put(`/chatbot/credentials/${id}`, data),
+  deleteCredential: (id: string) => api.delete(`/chatbot/credentials/${id}`),
+
   // Sessions
   listSessions: (params?: { status?: string; contact_id?: string }) =>
     api.get('/chatbot/sessions', { params }),
@@ -269,7 +449,9 @@ export const analyticsService = {
   campaigns: (params?: { from?: string; to?: string }) =>
     api.get('/analytics/campaigns', { params }),
   chatbot: (params?: { from?: string; to?: string }) =>
-    api.get('/analytics/chatbot', { params })
+    api.get('/analytics/chatbot', { params }),
+  statusReconciliations: (params?: { account?: string; missed?: boolean; limit?: number }) =>
+    api.get('/analytics/status-reconciliations', { params })
 }
 
 export const agentAnalyticsService = {
@@ -287,8 +469,32 @@ export const organizationService = {
     mask_phone_numbers?: boolean
     timezone?: string
     date_format?: string
+    week_start?: string
+    fiscal_year_start?: number
     name?: string
-  }) => api.put('/org/settings', data)
+  }) => api.put('/org/settings', data),
+  exportConfig: () => api.get('/settings/config/export'),
+  importConfig: (bundle: unknown, dryRun = true) =>
+    api.post('/settings/config/import', bundle, { params: { dry_run: dryRun } }),
+  getOffboarding: () => api.get('/org/offboarding'),
+  requestOffboarding: (data: { confirm: string; reason?: string; grace_days?: number }) =>
+    api.post('/org/offboarding', data),
+  cancelOffboarding: () => api.post('/org/offboarding/cancel'),
+  downloadOffboardingArchive: () => api.get('/org/offboarding/archive', { responseType: 'blob' })
+}
+
+export const notificationTemplatesService = {
+  list: () => api.get('/settings/notification-templates'),
+  update: (key: string, data: { subject: string; body: string }) =>
+    api.put(`/settings/notification-templates/${key}`, data),
+  reset: (key: string) => api.delete(`/settings/notification-templates/${key}`),
+  preview: (key: string, data: { subject?: string; body?: string }) =>
+    api.post(`/settings/notification-templates/${key}/preview`, data),
+  getEmailDomain: () => api.get('/settings/email-domain'),
+  setEmailDomain: (data: { domain: string; from_name?: string; from_local_part?: string }) =>
+    api.put('/settings/email-domain', data),
+  verifyEmailDomain: () => api.post('/settings/email-domain/verify'),
+  deleteEmailDomain: () => api.delete('/settings/email-domain')
 }
 
 export interface Webhook {
@@ -299,6 +505,8 @@ export interface Webhook {
   headers: Record<string, string>
   is_active: boolean
   has_secret: boolean
+  campaign_id?: string
+  expires_at?: string
   created_at: string
   updated_at: string
 }
@@ -307,6 +515,7 @@ export interface WebhookEvent {
   value: string
   label: string
   description: string
+  scope?: 'campaign'
 }
 
 export interface Team {
@@ -358,7 +567,8 @@ export const teamsService = {
 }
 
 export const webhooksService = {
-  list: () => api.get<{ webhooks: Webhook[]; available_events: WebhookEvent[] }>('/webhooks'),
+  list: (params?: { campaign_id?: string }) =>
+    api.get<{ webhooks: Webhook[]; available_events: WebhookEvent[] }>('/webhooks', { params }),
   get: (id: string) => api.get<Webhook>(`/webhooks/${id}`),
   create: (data: {
     name: string
@@ -366,6 +576,7 @@ export const webhooksService = {
     events: string[]
     headers?: Record<string, string>
     secret?: string
+    campaign_id?: string
   }) => api.post<Webhook>('/webhooks', data),
   update: (id: string, data: {
     name?: string
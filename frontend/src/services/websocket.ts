@@ -57,6 +57,15 @@ const WS_TYPE_TRANSFER_ESCALATION = 'transfer_escalation'
 // Campaign types
 const WS_TYPE_CAMPAIGN_STATS_UPDATE = 'campaign_stats_update'
 
+// Conversation types (sent only to followers)
+const WS_TYPE_CONVERSATION_UPDATE = 'conversation_update'
+
+// Notification types (sent only to users who get notifications as a digest)
+const WS_TYPE_NOTIFICATION_DIGEST = 'notification_digest'
+
+// Background job types (sent to whoever started the job)
+const WS_TYPE_JOB_PROGRESS = 'job_progress'
+
 interface WSMessage {
   type: string
   payload: any
@@ -71,6 +80,7 @@ class WebSocketService {
   private isConnected = false
   private hasConnectedBefore = false
   private campaignStatsCallbacks: ((payload: any) => void)[] = []
+  private jobProgressCallbacks: ((payload: any) => void)[] = []
 
   connect(token: string) {
     if (this.ws?.readyState === WebSocket.OPEN) {
@@ -167,6 +177,16 @@ class WebSocketService {
         case WS_TYPE_CAMPAIGN_STATS_UPDATE:
           this.handleCampaignStatsUpdate(message.payload)
           break
+        case WS_TYPE_JOB_PROGRESS:
+          this.jobProgressCallbacks.forEach(callback => callback(message.payload))
+          break
+        case WS_TYPE_CONVERSATION_UPDATE:
+          // A followed conversation was replied to or reassigned
+          store.fetchContacts()
+          break
+        case WS_TYPE_NOTIFICATION_DIGEST:
+          this.handleNotificationDigest(message.payload)
+          break
         default:
           console.log('Unknown message type:', message.type)
       }
@@ -222,8 +242,11 @@ class WebSocketService {
       const currentUserId = authStore.user?.id
       const settings = authStore.userSettings
 
-      // Check if user is assigned to this contact
-      const isAssignedToUser = payload.assigned_user_id === currentUserId
+      // Check if user should be alerted (the server lists them; older payloads only carry the assignee)
+      const notifyIds: string[] | undefined = payload.notify_user_ids
+      const isAssignedToUser = notifyIds
+        ? notifyIds.includes(currentUserId || '')
+        : payload.assigned_user_id === currentUserId
 
       // Check if new message alerts are enabled (default to true if not set)
       const alertsEnabled = settings.new_message_alerts !== false
@@ -253,6 +276,16 @@ class WebSocketService {
     store.fetchContacts()
   }
 
+  private handleNotificationDigest(payload: any) {
+    // The server already applied the user's notification preferences; the View action
+    // opens the conversation with the latest message
+    const latest = payload.conversations?.[0]
+    if (!latest) return
+
+    playNotificationSound()
+    showNotification(payload.title, payload.body, latest.contact_id)
+  }
+
   private handleStatusUpdate(store: ReturnType<typeof useContactsStore>, payload: any) {
     store.updateMessageStatus(payload.message_id, payload.status)
   }
@@ -397,6 +430,17 @@ class WebSocketService {
     }
   }
 
+  onJobProgress(callback: (payload: any) => void) {
+    this.jobProgressCallbacks.push(callback)
+    // Return unsubscribe function
+    return () => {
+      const index = this.jobProgressCallbacks.indexOf(callback)
+      if (index > -1) {
+        this.jobProgressCallbacks.splice(index, 1)
+      }
+    }
+  }
+
   private handleReconnect(token: string) {
     if (this.reconnectAttempts >= this.maxReconnectAttempts) {
       console.log('Max reconnect attempts reached')
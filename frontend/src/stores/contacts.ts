@@ -202,9 +202,9 @@ export const useContactsStore = defineStore('contacts', () => {
     }
   }
 
-  async function sendMessage(contactId: string, type: string, content: any, replyToMessageId?: string) {
+  async function sendMessage(contactId: string, type: string, content: any, replyToMessageId?: string, previewUrl = false) {
     try {
-      const response = await messagesService.send(contactId, { type, content, reply_to_message_id: replyToMessageId })
+      const response = await messagesService.send(contactId, { type, content, reply_to_message_id: replyToMessageId, preview_url: previewUrl })
       // API returns { status: "success", data: { ... } }
       const newMessage = response.data.data || response.data
       // Use addMessage which has duplicate checking (WebSocket may also broadcast this)
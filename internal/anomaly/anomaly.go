@@ -0,0 +1,187 @@
+// Package anomaly baselines daily per-account messaging rates from the message
+// rollups and flags days that deviate sharply from that baseline.
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// BaselineDays is how many days before the evaluated day form the baseline
+	BaselineDays = 28
+
+	// MinVolume is the number of messages a day needs for its rate to be meaningful
+	MinVolume = 50
+
+	// Fewer baseline days than this and the account is not evaluated
+	minBaselineDays = 7
+
+	// A day is anomalous when it is this many standard deviations from the baseline mean...
+	zThreshold = 3.0
+
+	// ...with the deviation floored so that very stable accounts don't alert on noise
+	minStdDev = 0.02
+)
+
+// Metric names
+const (
+	MetricFailureRate  = "failure_rate"
+	MetricDeliveryRate = "delivery_rate"
+	MetricReadRate     = "read_rate"
+)
+
+// dayStats are one account's rollup totals for one day
+type dayStats struct {
+	OrganizationID  uuid.UUID
+	WhatsAppAccount string
+	Date            time.Time
+	Sent            int64
+	Delivered       int64
+	Read            int64
+	Failed          int64
+}
+
+// metric describes how a rate is computed and which direction is bad
+type metric struct {
+	label     string
+	direction string  // spike, drop
+	minChange float64 // Smallest absolute change worth alerting on
+	rate      func(s dayStats) (float64, int64)
+}
+
+var metrics = map[string]metric{
+	MetricFailureRate: {
+		label:     "Failure rate",
+		direction: "spike",
+		minChange: 0.10,
+		rate: func(s dayStats) (float64, int64) {
+			return ratio(s.Failed, s.Sent+s.Failed), s.Sent + s.Failed
+		},
+	},
+	MetricDeliveryRate: {
+		label:     "Delivery rate",
+		direction: "drop",
+		minChange: 0.15,
+		rate: func(s dayStats) (float64, int64) {
+			return ratio(s.Delivered, s.Sent), s.Sent
+		},
+	},
+	MetricReadRate: {
+		label:     "Read rate",
+		direction: "drop",
+		minChange: 0.15,
+		rate: func(s dayStats) (float64, int64) {
+			return ratio(s.Read, s.Delivered), s.Delivered
+		},
+	},
+}
+
+// Detect evaluates the given metrics for every account on day against the preceding
+// BaselineDays. The returned anomalies are not saved.
+func Detect(db *gorm.DB, day time.Time, metricNames ...string) ([]models.MetricAnomaly, error) {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	var rows []dayStats
+	err := db.Model(&models.MessageDailyRollup{}).
+		Select(`organization_id, whats_app_account, date,
+			SUM(sent_count)::bigint AS sent, SUM(delivered_count)::bigint AS delivered,
+			SUM(read_count)::bigint AS read, SUM(failed_count)::bigint AS failed`).
+		Where("date >= ? AND date <= ?", day.AddDate(0, 0, -BaselineDays).Format("2006-01-02"), day.Format("2006-01-02")).
+		Group("organization_id, whats_app_account, date").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rollups: %w", err)
+	}
+
+	type accountKey struct {
+		orgID   uuid.UUID
+		account string
+	}
+	history := make(map[accountKey][]dayStats)
+	current := make(map[accountKey]dayStats)
+	for _, row := range rows {
+		key := accountKey{row.OrganizationID, row.WhatsAppAccount}
+		if row.Date.Equal(day) {
+			current[key] = row
+		} else {
+			history[key] = append(history[key], row)
+		}
+	}
+
+	var anomalies []models.MetricAnomaly
+	for key, today := range current {
+		for _, name := range metricNames {
+			m, ok := metrics[name]
+			if !ok {
+				continue
+			}
+
+			value, volume := m.rate(today)
+			if volume < MinVolume {
+				continue
+			}
+
+			var baseline []float64
+			for _, past := range history[key] {
+				if v, n := m.rate(past); n >= MinVolume {
+					baseline = append(baseline, v)
+				}
+			}
+			if len(baseline) < minBaselineDays {
+				continue
+			}
+
+			mean, stddev := meanStdDev(baseline)
+			deviation := value - mean
+			if m.direction == "drop" {
+				deviation = -deviation
+			}
+			if deviation < m.minChange || deviation < zThreshold*math.Max(stddev, minStdDev) {
+				continue
+			}
+
+			anomalies = append(anomalies, models.MetricAnomaly{
+				OrganizationID:  key.orgID,
+				WhatsAppAccount: key.account,
+				Date:            day,
+				Metric:          name,
+				Direction:       m.direction,
+				Value:           value,
+				Baseline:        mean,
+				StdDev:          stddev,
+				Volume:          volume,
+				Message: fmt.Sprintf("%s on %s is %.1f%% against a %d-day baseline of %.1f%% (%d messages)",
+					m.label, key.account, value*100, len(baseline), mean*100, volume),
+			})
+		}
+	}
+
+	return anomalies, nil
+}
+
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
@@ -0,0 +1,130 @@
+// Package apilog records the Graph API calls of accounts with debug logging turned
+// on, so a failed send can be looked into from the API instead of the server logs.
+package apilog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/zerodha/logf"
+	"gorm.io/gorm"
+)
+
+// MaxEntries is how many calls are kept per account; older ones are pruned
+const MaxEntries = 2000
+
+// cacheTTL bounds how long turning debug logging on or off takes to apply on other
+// instances
+const cacheTTL = 30 * time.Second
+
+type entry struct {
+	organizationID uuid.UUID
+	account        string
+	until          *time.Time // Debug logging is on until then
+	expiresAt      time.Time
+}
+
+// Recorder saves Graph API calls for accounts with debug logging on. Use it as
+// whatsapp.Client.RequestLogger.
+type Recorder struct {
+	db      *gorm.DB
+	log     logf.Logger
+	entries sync.Map // phone or business ID -> entry
+}
+
+// NewRecorder creates a recorder backed by db
+func NewRecorder(db *gorm.DB, log logf.Logger) *Recorder {
+	return &Recorder{db: db, log: log}
+}
+
+// Enabled reports whether debug logging is on for account
+func (r *Recorder) Enabled(account *whatsapp.Account) bool {
+	e, ok := r.lookup(account)
+	return ok && e.until != nil && time.Now().Before(*e.until)
+}
+
+// LogRequest saves a Graph API call made for account
+func (r *Recorder) LogRequest(account *whatsapp.Account, call *whatsapp.APICall) {
+	e, ok := r.lookup(account)
+	if !ok {
+		return
+	}
+	record := models.APICallLog{
+		OrganizationID:  e.organizationID,
+		WhatsAppAccount: e.account,
+		Method:          call.Method,
+		URL:             call.URL,
+		RequestBody:     call.RequestBody,
+		StatusCode:      call.StatusCode,
+		ResponseBody:    call.ResponseBody,
+		Error:           call.Error,
+		DurationMs:      call.Duration.Milliseconds(),
+	}
+	if err := r.db.Create(&record).Error; err != nil {
+		r.log.Error("Failed to save API call log", "error", err, "account", e.account)
+	}
+}
+
+// Invalidate drops cached settings so turning debug logging on or off applies
+// immediately on this instance
+func (r *Recorder) Invalidate() {
+	r.entries.Clear()
+}
+
+// lookup returns the account's organization, name and debug logging setting
+func (r *Recorder) lookup(account *whatsapp.Account) (entry, bool) {
+	column, id := "phone_id", account.PhoneID
+	if id == "" {
+		column, id = "business_id", account.BusinessID
+	}
+	if id == "" {
+		return entry{}, false
+	}
+
+	key := column + ":" + id
+	if e, ok := r.entries.Load(key); ok && time.Now().Before(e.(entry).expiresAt) {
+		return e.(entry), e.(entry).account != ""
+	}
+
+	var accounts []models.WhatsAppAccount
+	if err := r.db.Select("organization_id", "name", "debug_log_until").
+		Where(column+" = ?", id).Limit(1).Find(&accounts).Error; err != nil {
+		// Debug logging is best effort; don't fail or slow the call over it
+		r.log.Error("Failed to check API debug logging", "error", err)
+		return entry{}, false
+	}
+
+	e := entry{expiresAt: time.Now().Add(cacheTTL)}
+	if len(accounts) > 0 {
+		e.organizationID = accounts[0].OrganizationID
+		e.account = accounts[0].Name
+		e.until = accounts[0].DebugLogUntil
+	}
+	r.entries.Store(key, e)
+	return e, e.account != ""
+}
+
+// Prune deletes calls older than retention and all but the newest MaxEntries of
+// each account, returning how many were deleted
+func Prune(db *gorm.DB, retention time.Duration) (int64, error) {
+	expired := db.Unscoped().Where("created_at < ?", time.Now().Add(-retention)).Delete(&models.APICallLog{})
+	if expired.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired API call logs: %w", expired.Error)
+	}
+
+	excess := db.Exec(`
+		DELETE FROM api_call_logs WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY organization_id, whats_app_account ORDER BY created_at DESC) AS n
+				FROM api_call_logs
+			) ranked WHERE n > ?
+		)`, MaxEntries)
+	if excess.Error != nil {
+		return expired.RowsAffected, fmt.Errorf("failed to delete excess API call logs: %w", excess.Error)
+	}
+	return expired.RowsAffected + excess.RowsAffected, nil
+}
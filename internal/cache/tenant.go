@@ -0,0 +1,172 @@
+// Package cache provides a tenant-scoped Redis cache for lookups that are
+// repeated on hot paths such as campaign sends.
+//
+// Entries for an organization live under "tenant:<org_id>:" so one tenant's
+// data can be dropped without touching any other. Accounts and templates are
+// stored as Redis hashes (one per organization) which lets a single DEL
+// invalidate every entry of that kind for the tenant.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/zerodha/logf"
+	"gorm.io/gorm"
+)
+
+const (
+	// TTL is how long entries live; writes invalidate them earlier through model hooks
+	TTL = 6 * time.Hour
+
+	tenantKeyPrefix = "tenant:"
+	accountsKey     = "wa_accounts"
+	templatesKey    = "templates"
+	settingsKey     = "settings"
+)
+
+// TenantCache caches per-organization lookups in Redis with a database fallback
+type TenantCache struct {
+	Redis *redis.Client
+	DB    *gorm.DB
+	Log   logf.Logger
+}
+
+// New creates a tenant cache
+func New(rdb *redis.Client, db *gorm.DB, log logf.Logger) *TenantCache {
+	return &TenantCache{Redis: rdb, DB: db, Log: log}
+}
+
+// whatsAppAccountEntry is used for caching since AccessToken has json:"-" tag
+type whatsAppAccountEntry struct {
+	models.WhatsAppAccount
+	AccessToken string `json:"access_token"`
+}
+
+func tenantKey(orgID uuid.UUID, kind string) string {
+	return fmt.Sprintf("%s%s:%s", tenantKeyPrefix, orgID.String(), kind)
+}
+
+// WhatsAppAccount returns the organization's WhatsApp account with the given name
+func (c *TenantCache) WhatsAppAccount(ctx context.Context, orgID uuid.UUID, name string) (*models.WhatsAppAccount, error) {
+	key := tenantKey(orgID, accountsKey)
+
+	if cached, err := c.Redis.HGet(ctx, key, name).Result(); err == nil && cached != "" {
+		var entry whatsAppAccountEntry
+		if err := json.Unmarshal([]byte(cached), &entry); err == nil {
+			entry.WhatsAppAccount.AccessToken = entry.AccessToken
+			return &entry.WhatsAppAccount, nil
+		}
+	}
+
+	var account models.WhatsAppAccount
+	if err := c.DB.Where("name = ? AND organization_id = ?", name, orgID).First(&account).Error; err != nil {
+		return nil, err
+	}
+
+	c.setHashField(ctx, key, name, whatsAppAccountEntry{WhatsAppAccount: account, AccessToken: account.AccessToken})
+	return &account, nil
+}
+
+// Template returns a template by ID, scoped to the organization
+func (c *TenantCache) Template(ctx context.Context, orgID, templateID uuid.UUID) (*models.Template, error) {
+	key := tenantKey(orgID, templatesKey)
+
+	if cached, err := c.Redis.HGet(ctx, key, templateID.String()).Result(); err == nil && cached != "" {
+		var template models.Template
+		if err := json.Unmarshal([]byte(cached), &template); err == nil {
+			return &template, nil
+		}
+	}
+
+	var template models.Template
+	if err := c.DB.Where("id = ? AND organization_id = ?", templateID, orgID).First(&template).Error; err != nil {
+		return nil, err
+	}
+
+	c.setHashField(ctx, key, templateID.String(), template)
+	return &template, nil
+}
+
+// OrgSettings returns the organization's settings document
+func (c *TenantCache) OrgSettings(ctx context.Context, orgID uuid.UUID) (models.JSONB, error) {
+	key := tenantKey(orgID, settingsKey)
+
+	if cached, err := c.Redis.Get(ctx, key).Result(); err == nil && cached != "" {
+		var settings models.JSONB
+		if err := json.Unmarshal([]byte(cached), &settings); err == nil {
+			return settings, nil
+		}
+	}
+
+	var org models.Organization
+	if err := c.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil, err
+	}
+	if org.Settings == nil {
+		org.Settings = models.JSONB{}
+	}
+
+	if data, err := json.Marshal(org.Settings); err == nil {
+		c.Redis.Set(ctx, key, data, TTL)
+	}
+	return org.Settings, nil
+}
+
+func (c *TenantCache) setHashField(ctx context.Context, key, field string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	pipe := c.Redis.TxPipeline()
+	pipe.HSet(ctx, key, field, data)
+	pipe.Expire(ctx, key, TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.Log.Error("Failed to write tenant cache", "error", err, "key", key)
+	}
+}
+
+// Invalidate drops cached entries of one entity kind for an organization.
+// A nil orgID drops that kind for every organization.
+func (c *TenantCache) Invalidate(ctx context.Context, entity string, orgID uuid.UUID) {
+	var kind string
+	switch entity {
+	case models.CacheEntityWhatsAppAccount:
+		kind = accountsKey
+	case models.CacheEntityTemplate:
+		kind = templatesKey
+	case models.CacheEntityOrganization:
+		kind = settingsKey
+	default:
+		return
+	}
+
+	if orgID != uuid.Nil {
+		c.Redis.Del(ctx, tenantKey(orgID, kind))
+		return
+	}
+	c.deleteKeysByPattern(ctx, tenantKeyPrefix+"*:"+kind)
+}
+
+// InvalidateTenant drops every cached entry for an organization
+func (c *TenantCache) InvalidateTenant(ctx context.Context, orgID uuid.UUID) {
+	c.deleteKeysByPattern(ctx, tenantKeyPrefix+orgID.String()+":*")
+}
+
+// OnModelChange is a models.CacheInvalidator that keeps the cache in step with database writes
+func (c *TenantCache) OnModelChange(entity string, orgID uuid.UUID) {
+	c.Invalidate(context.Background(), entity, orgID)
+}
+
+// deleteKeysByPattern deletes all keys matching a pattern
+func (c *TenantCache) deleteKeysByPattern(ctx context.Context, pattern string) {
+	iter := c.Redis.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		c.Redis.Del(ctx, iter.Val())
+	}
+}
@@ -0,0 +1,248 @@
+// Package changestream publishes normalized change events, such as a contact being
+// updated or a message being created, to an organization's Redis stream or Kafka
+// topic. Database triggers capture the changes into the change_events outbox in the
+// same transaction as the change, so events are never lost or published for a change
+// that was rolled back, and the relay publishes them in the order they were captured.
+package changestream
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// Event types
+const (
+	EventContactCreated        = "contact.created"
+	EventContactUpdated        = "contact.updated"
+	EventMessageCreated        = "message.created"
+	EventCampaignStatusChanged = "campaign.status_changed"
+
+	// EventTest is only published by the test endpoint, to check a sink's settings
+	EventTest = "change_stream.test"
+)
+
+// Schema versions per event type. A version is only bumped for a breaking change to
+// the event's data, such as a field being removed, renamed or changing type; fields
+// are added without a new version, so consumers must ignore fields they don't know.
+const (
+	contactSchemaVersion  = 1
+	messageSchemaVersion  = 1
+	campaignSchemaVersion = 1
+)
+
+// Envelope is the published form of an event
+type Envelope struct {
+	ID             uuid.UUID    `json:"id"` // Unique per event, for deduplicating redeliveries
+	Type           string       `json:"type"`
+	SchemaVersion  int          `json:"schema_version"`
+	OrganizationID uuid.UUID    `json:"organization_id"`
+	EntityID       uuid.UUID    `json:"entity_id"` // ID of the contact, message or campaign
+	Sequence       int64        `json:"sequence"`  // Capture order, increasing across organizations
+	OccurredAt     time.Time    `json:"occurred_at"`
+	Data           models.JSONB `json:"data"`
+}
+
+// envelope builds the published form of a captured event
+func envelope(e *models.ChangeEvent) Envelope {
+	return Envelope{
+		ID:             e.EventID,
+		Type:           e.Type,
+		SchemaVersion:  e.SchemaVersion,
+		OrganizationID: e.OrganizationID,
+		EntityID:       e.EntityID,
+		Sequence:       e.ID,
+		OccurredAt:     e.OccurredAt.UTC(),
+		Data:           e.Data,
+	}
+}
+
+// Field is one field of an event's data
+type Field struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// Schema documents the data of one event type at its current version
+type Schema struct {
+	Type          string  `json:"type"`
+	SchemaVersion int     `json:"schema_version"`
+	Description   string  `json:"description"`
+	Fields        []Field `json:"fields"`
+}
+
+var contactFields = []Field{
+	{"id", "uuid", "Contact ID"},
+	{"phone_number", "string", "Phone number"},
+	{"profile_name", "string", "WhatsApp profile name"},
+	{"whatsapp_account", "string", "Name of the WhatsApp account the contact messages"},
+	{"assigned_user_id", "uuid|null", "Assigned agent"},
+	{"tags", "string[]", "Tags"},
+	{"metadata", "object", "Custom fields"},
+	{"deleted", "boolean", "Whether the contact was deleted"},
+	{"created_at", "timestamp", "When the contact was first seen"},
+	{"updated_at", "timestamp", "When the contact last changed"},
+	{"changed", "string[]", "Fields that changed: phone_number, profile_name, whatsapp_account, assigned_user_id, tags, metadata or deleted; empty for contact.created"},
+}
+
+// Schemas documents every event type
+var Schemas = []Schema{
+	{
+		Type:          EventContactCreated,
+		SchemaVersion: contactSchemaVersion,
+		Description:   "A contact was created, by an incoming message, an import or the API",
+		Fields:        contactFields,
+	},
+	{
+		Type:          EventContactUpdated,
+		SchemaVersion: contactSchemaVersion,
+		Description:   "A contact's details, assignment, tags or custom fields changed, or it was deleted. Activity such as new messages or read state doesn't publish an event.",
+		Fields:        contactFields,
+	},
+	{
+		Type:          EventMessageCreated,
+		SchemaVersion: messageSchemaVersion,
+		Description:   "A message was received or sent. Content has the organization's redaction rules applied; media is not included.",
+		Fields: []Field{
+			{"id", "uuid", "Message ID"},
+			{"contact_id", "uuid", "Contact"},
+			{"whatsapp_account", "string", "Name of the WhatsApp account"},
+			{"whatsapp_message_id", "string", "Meta message ID; empty until an outgoing message is accepted"},
+			{"direction", "string", "incoming or outgoing"},
+			{"message_type", "string", "text, image, document, template, interactive, ..."},
+			{"status", "string", "Status when created; later delivery receipts don't publish events"},
+			{"content", "string", "Text, caption or template body"},
+			{"template_name", "string", "Template of a template message"},
+			{"media_mime_type", "string", "MIME type of a media message"},
+			{"is_reply", "boolean", "Whether the message quotes another message"},
+			{"reply_to_message_id", "uuid|null", "Quoted message"},
+			{"sent_by_user_id", "uuid|null", "Agent who sent it; null for incoming and automated messages"},
+			{"created_at", "timestamp", "When the message was sent or received"},
+		},
+	},
+	{
+		Type:          EventCampaignStatusChanged,
+		SchemaVersion: campaignSchemaVersion,
+		Description:   "A bulk message campaign moved to another status",
+		Fields: []Field{
+			{"id", "uuid", "Campaign ID"},
+			{"name", "string", "Campaign name"},
+			{"whatsapp_account", "string", "Name of the WhatsApp account"},
+			{"template_id", "uuid", "Template"},
+			{"previous_status", "string", "Status before the change"},
+			{"status", "string", "draft, pending_template, scheduled, queued, processing, paused, completed, cancelled or failed"},
+			{"total_recipients", "integer", "Recipients"},
+			{"sent_count", "integer", "Messages sent so far"},
+			{"delivered_count", "integer", "Messages delivered so far"},
+			{"read_count", "integer", "Messages read so far"},
+			{"failed_count", "integer", "Messages failed so far"},
+			{"scheduled_at", "timestamp|null", "When the campaign is scheduled to start"},
+			{"started_at", "timestamp|null", "When sending started"},
+			{"completed_at", "timestamp|null", "When sending finished"},
+		},
+	},
+}
+
+// IsEventType reports whether t is an event type that can be subscribed to
+func IsEventType(t string) bool {
+	for _, s := range Schemas {
+		if s.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Triggers returns the statements that install the capture triggers. A trigger only
+// writes an event when the organization has an enabled change stream subscribed to
+// it, so organizations without one pay for a single indexed lookup per change.
+func Triggers() []string {
+	return []string{
+		`CREATE OR REPLACE FUNCTION change_stream_wants(org uuid, event text) RETURNS boolean LANGUAGE sql STABLE AS $$
+			SELECT EXISTS (SELECT 1 FROM change_streams WHERE organization_id = org AND is_enabled AND deleted_at IS NULL
+				AND (events IS NULL OR events = '[]'::jsonb OR events @> jsonb_build_array(event)))
+		$$`,
+
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION capture_contact_changes() RETURNS trigger LANGUAGE plpgsql AS $$
+		DECLARE
+			event text := CASE WHEN TG_OP = 'INSERT' THEN '%s' ELSE '%s' END;
+			changed text[] := '{}';
+		BEGIN
+			IF TG_OP = 'UPDATE' THEN
+				IF NEW.phone_number IS DISTINCT FROM OLD.phone_number THEN changed := changed || 'phone_number'::text; END IF;
+				IF NEW.profile_name IS DISTINCT FROM OLD.profile_name THEN changed := changed || 'profile_name'::text; END IF;
+				IF NEW.whats_app_account IS DISTINCT FROM OLD.whats_app_account THEN changed := changed || 'whatsapp_account'::text; END IF;
+				IF NEW.assigned_user_id IS DISTINCT FROM OLD.assigned_user_id THEN changed := changed || 'assigned_user_id'::text; END IF;
+				IF NEW.tags IS DISTINCT FROM OLD.tags THEN changed := changed || 'tags'::text; END IF;
+				IF NEW.metadata IS DISTINCT FROM OLD.metadata THEN changed := changed || 'metadata'::text; END IF;
+				IF NEW.deleted_at IS DISTINCT FROM OLD.deleted_at THEN changed := changed || 'deleted'::text; END IF;
+				IF cardinality(changed) = 0 THEN RETURN NULL; END IF;
+			END IF;
+			IF NOT change_stream_wants(NEW.organization_id, event) THEN RETURN NULL; END IF;
+			INSERT INTO change_events (organization_id, type, schema_version, entity_id, data)
+			VALUES (NEW.organization_id, event, %d, NEW.id, jsonb_build_object(
+				'id', NEW.id,
+				'phone_number', NEW.phone_number,
+				'profile_name', NEW.profile_name,
+				'whatsapp_account', NEW.whats_app_account,
+				'assigned_user_id', NEW.assigned_user_id,
+				'tags', COALESCE(NEW.tags, '[]'::jsonb),
+				'metadata', COALESCE(NEW.metadata, '{}'::jsonb),
+				'deleted', NEW.deleted_at IS NOT NULL,
+				'created_at', NEW.created_at,
+				'updated_at', NEW.updated_at,
+				'changed', to_jsonb(changed)));
+			RETURN NULL;
+		END $$`, EventContactCreated, EventContactUpdated, contactSchemaVersion),
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'capture_contact_changes') THEN CREATE TRIGGER capture_contact_changes AFTER INSERT OR UPDATE ON contacts FOR EACH ROW EXECUTE FUNCTION capture_contact_changes(); END IF; END $$`,
+
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION capture_message_changes() RETURNS trigger LANGUAGE plpgsql AS $$
+		BEGIN
+			IF NOT change_stream_wants(NEW.organization_id, '%s') THEN RETURN NULL; END IF;
+			INSERT INTO change_events (organization_id, type, schema_version, entity_id, data)
+			VALUES (NEW.organization_id, '%s', %d, NEW.id, jsonb_build_object(
+				'id', NEW.id,
+				'contact_id', NEW.contact_id,
+				'whatsapp_account', NEW.whats_app_account,
+				'whatsapp_message_id', NEW.whats_app_message_id,
+				'direction', NEW.direction,
+				'message_type', NEW.message_type,
+				'status', NEW.status,
+				'content', NEW.content,
+				'template_name', NEW.template_name,
+				'media_mime_type', NEW.media_mime_type,
+				'is_reply', NEW.is_reply,
+				'reply_to_message_id', NEW.reply_to_message_id,
+				'sent_by_user_id', NEW.sent_by_user_id,
+				'created_at', NEW.created_at));
+			RETURN NULL;
+		END $$`, EventMessageCreated, EventMessageCreated, messageSchemaVersion),
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'capture_message_changes') THEN CREATE TRIGGER capture_message_changes AFTER INSERT ON messages FOR EACH ROW EXECUTE FUNCTION capture_message_changes(); END IF; END $$`,
+
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION capture_campaign_changes() RETURNS trigger LANGUAGE plpgsql AS $$
+		BEGIN
+			IF NOT change_stream_wants(NEW.organization_id, '%s') THEN RETURN NULL; END IF;
+			INSERT INTO change_events (organization_id, type, schema_version, entity_id, data)
+			VALUES (NEW.organization_id, '%s', %d, NEW.id, jsonb_build_object(
+				'id', NEW.id,
+				'name', NEW.name,
+				'whatsapp_account', NEW.whats_app_account,
+				'template_id', NEW.template_id,
+				'previous_status', OLD.status,
+				'status', NEW.status,
+				'total_recipients', NEW.total_recipients,
+				'sent_count', NEW.sent_count,
+				'delivered_count', NEW.delivered_count,
+				'read_count', NEW.read_count,
+				'failed_count', NEW.failed_count,
+				'scheduled_at', NEW.scheduled_at,
+				'started_at', NEW.started_at,
+				'completed_at', NEW.completed_at));
+			RETURN NULL;
+		END $$`, EventCampaignStatusChanged, EventCampaignStatusChanged, campaignSchemaVersion),
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'capture_campaign_changes') THEN CREATE TRIGGER capture_campaign_changes AFTER UPDATE OF status ON bulk_message_campaigns FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status) EXECUTE FUNCTION capture_campaign_changes(); END IF; END $$`,
+	}
+}
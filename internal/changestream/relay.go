@@ -0,0 +1,214 @@
+package changestream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// batchSize is the number of events published in one request
+	batchSize = 100
+
+	// maxBatchesPerRun keeps one busy organization from delaying the others
+	maxBatchesPerRun = 20
+
+	// retryBackoff is how long a stream whose sink failed waits before it is retried
+	retryBackoff = 30 * time.Second
+
+	// retention is how long events wait for a failing sink before they are dropped
+	retention = 7 * 24 * time.Hour
+
+	lockKey = "change_stream_lock:"
+	lockTTL = time.Minute
+)
+
+// Relay publishes captured events to each organization's change stream. Several
+// instances can run at once: an organization's events are published by one instance
+// at a time, oldest first, and only deleted once the sink accepted them, so delivery
+// is at least once and in order.
+type Relay struct {
+	db    *gorm.DB
+	redis *redis.Client
+
+	// Transform adjusts an event before it is published, e.g. to redact message text
+	Transform func(e *Envelope)
+
+	sinks map[uuid.UUID]cachedSink // Stream ID -> open sink; only used from Run
+}
+
+type cachedSink struct {
+	sink      Sink
+	updatedAt time.Time // Stream's UpdatedAt when the sink was opened
+}
+
+// NewRelay creates a relay that reads events from db; rdb is the platform's Redis
+func NewRelay(db *gorm.DB, rdb *redis.Client) *Relay {
+	return &Relay{db: db, redis: rdb, sinks: make(map[uuid.UUID]cachedSink)}
+}
+
+// Run publishes the pending events of every organization once
+func (r *Relay) Run(ctx context.Context) error {
+	var orgIDs []uuid.UUID
+	if err := r.db.Model(&models.ChangeEvent{}).Distinct("organization_id").Pluck("organization_id", &orgIDs).Error; err != nil {
+		return fmt.Errorf("failed to list pending change events: %w", err)
+	}
+
+	for _, orgID := range orgIDs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		locked, err := r.redis.SetNX(ctx, lockKey+orgID.String(), 1, lockTTL).Result()
+		if err != nil {
+			return err
+		}
+		if !locked {
+			continue
+		}
+		err = r.publishOrganization(ctx, orgID)
+		r.redis.Del(ctx, lockKey+orgID.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the sinks the relay opened
+func (r *Relay) Close() {
+	for id, cached := range r.sinks {
+		cached.sink.Close()
+		delete(r.sinks, id)
+	}
+}
+
+// Test publishes a test event to the stream's sink, returning why it failed
+func Test(ctx context.Context, stream *models.ChangeStream, rdb *redis.Client) error {
+	sink, err := NewSink(stream, rdb)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	return sink.Publish(ctx, []Envelope{{
+		ID:             uuid.New(),
+		Type:           EventTest,
+		SchemaVersion:  1,
+		OrganizationID: stream.OrganizationID,
+		EntityID:       stream.ID,
+		OccurredAt:     time.Now().UTC(),
+		Data:           models.JSONB{"message": "Change stream test event"},
+	}})
+}
+
+// publishOrganization publishes an organization's pending events in order, stopping at
+// the first batch the sink rejects. Sink failures are recorded on the stream rather
+// than returned, so one broken sink doesn't hold up other organizations.
+func (r *Relay) publishOrganization(ctx context.Context, orgID uuid.UUID) error {
+	var stream models.ChangeStream
+	err := r.db.Where("organization_id = ?", orgID).First(&stream).Error
+	if err != nil || !stream.IsEnabled {
+		// Left over from a stream that was disabled or deleted
+		return r.db.Where("organization_id = ?", orgID).Delete(&models.ChangeEvent{}).Error
+	}
+	if stream.LastErrorAt != nil && time.Since(*stream.LastErrorAt) < retryBackoff {
+		return nil
+	}
+
+	expired := r.db.Where("organization_id = ? AND occurred_at < ?", orgID, time.Now().Add(-retention)).Delete(&models.ChangeEvent{})
+	if expired.Error != nil {
+		return expired.Error
+	}
+	if expired.RowsAffected > 0 {
+		r.recordFailure(&stream, fmt.Sprintf("%d events older than %d days were dropped because the sink kept failing",
+			expired.RowsAffected, int(retention.Hours()/24)))
+	}
+
+	sink, err := r.sink(&stream)
+	if err != nil {
+		r.recordFailure(&stream, err.Error())
+		return nil
+	}
+
+	for i := 0; i < maxBatchesPerRun; i++ {
+		// Keep the lock while batches are slow, so no other instance publishes out of order
+		r.redis.Expire(ctx, lockKey+orgID.String(), lockTTL)
+
+		var pending []models.ChangeEvent
+		if err := r.db.Where("organization_id = ?", orgID).Order("id").Limit(batchSize).Find(&pending).Error; err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		events := make([]Envelope, len(pending))
+		ids := make([]int64, len(pending))
+		for j := range pending {
+			events[j] = envelope(&pending[j])
+			if r.Transform != nil {
+				r.Transform(&events[j])
+			}
+			ids[j] = pending[j].ID
+		}
+
+		if err := sink.Publish(ctx, events); err != nil {
+			r.recordFailure(&stream, err.Error())
+			r.dropSink(stream.ID)
+			return nil
+		}
+
+		if err := r.db.Where("id IN ?", ids).Delete(&models.ChangeEvent{}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		r.db.Model(&stream).UpdateColumns(map[string]interface{}{
+			"published_count":   gorm.Expr("published_count + ?", len(events)),
+			"last_published_at": now,
+			"last_error":        "",
+			"last_error_at":     nil,
+		})
+		if len(pending) < batchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// sink returns the open sink for a stream, reopening it when the stream's settings
+// changed since it was opened
+func (r *Relay) sink(stream *models.ChangeStream) (Sink, error) {
+	if cached, ok := r.sinks[stream.ID]; ok {
+		if cached.updatedAt.Equal(stream.UpdatedAt) {
+			return cached.sink, nil
+		}
+		r.dropSink(stream.ID)
+	}
+
+	sink, err := NewSink(stream, r.redis)
+	if err != nil {
+		return nil, err
+	}
+	r.sinks[stream.ID] = cachedSink{sink: sink, updatedAt: stream.UpdatedAt}
+	return sink, nil
+}
+
+func (r *Relay) dropSink(streamID uuid.UUID) {
+	if cached, ok := r.sinks[streamID]; ok {
+		cached.sink.Close()
+		delete(r.sinks, streamID)
+	}
+}
+
+// recordFailure stores a sink failure on the stream, which also starts its backoff
+func (r *Relay) recordFailure(stream *models.ChangeStream, msg string) {
+	r.db.Model(stream).UpdateColumns(map[string]interface{}{
+		"last_error":    msg,
+		"last_error_at": time.Now(),
+	})
+}
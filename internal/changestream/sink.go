@@ -0,0 +1,177 @@
+package changestream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+const (
+	// defaultMaxLen caps a Redis stream's length when the stream doesn't set one
+	defaultMaxLen = 100000
+
+	// kafkaTimeout bounds one request to the Kafka REST Proxy
+	kafkaTimeout = 15 * time.Second
+)
+
+// PlatformStreamKey is the stream an organization's events go to on the platform's
+// own Redis. Streams there always use this key, so an organization can't write to
+// keys the platform or another organization uses.
+func PlatformStreamKey(orgID uuid.UUID) string {
+	return "whatomate:changes:" + orgID.String()
+}
+
+// Sink publishes events to one destination, in order
+type Sink interface {
+	Publish(ctx context.Context, events []Envelope) error
+	Close() error
+}
+
+// NewSink returns the sink a change stream publishes to. rdb is the platform's Redis,
+// used by Redis streams without a URL of their own.
+func NewSink(stream *models.ChangeStream, rdb *redis.Client) (Sink, error) {
+	switch stream.Sink {
+	case models.ChangeStreamRedis:
+		if stream.RedisURL == "" {
+			return &redisSink{client: rdb, key: PlatformStreamKey(stream.OrganizationID), maxLen: stream.MaxLen}, nil
+		}
+		opts, err := redis.ParseURL(stream.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+		return &redisSink{client: redis.NewClient(opts), key: stream.Target, maxLen: stream.MaxLen, owned: true}, nil
+	case models.ChangeStreamKafka:
+		return &kafkaSink{
+			url:      strings.TrimRight(stream.KafkaRESTURL, "/") + "/topics/" + url.PathEscape(stream.Target),
+			username: stream.KafkaUsername,
+			password: stream.KafkaPassword,
+			client:   &http.Client{Timeout: kafkaTimeout},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown sink %q", stream.Sink)
+}
+
+// redisSink appends events to a Redis stream with XADD, trimming it to about maxLen
+// entries. Each entry has the event's type, schema version and entity ID as fields
+// for consumers that filter, and the whole envelope as JSON in "event".
+type redisSink struct {
+	client *redis.Client
+	key    string
+	maxLen int64
+	owned  bool // Whether Close closes the client, which isn't the platform's
+}
+
+func (s *redisSink) Publish(ctx context.Context, events []Envelope) error {
+	maxLen := s.maxLen
+	if maxLen <= 0 {
+		maxLen = defaultMaxLen
+	}
+
+	pipe := s.client.Pipeline()
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.key,
+			MaxLen: maxLen,
+			Approx: true,
+			Values: map[string]interface{}{
+				"type":           e.Type,
+				"schema_version": strconv.Itoa(e.SchemaVersion),
+				"entity_id":      e.EntityID.String(),
+				"event":          data,
+			},
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *redisSink) Close() error {
+	if s.owned {
+		return s.client.Close()
+	}
+	return nil
+}
+
+// kafkaSink produces events to a Kafka topic through a Kafka REST Proxy (v2 API),
+// keyed by entity ID so the events of one contact, message or campaign stay in order
+// on one partition
+type kafkaSink struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+type kafkaRecord struct {
+	Key   string   `json:"key"`
+	Value Envelope `json:"value"`
+}
+
+type kafkaResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, events []Envelope) error {
+	records := make([]kafkaRecord, len(events))
+	for i, e := range events {
+		records[i] = kafkaRecord{Key: e.EntityID.String(), Value: e}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("kafka REST proxy returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result kafkaResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("invalid kafka REST proxy response: %w", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			return fmt.Errorf("kafka rejected a record (error %d): %s", *offset.ErrorCode, offset.Error)
+		}
+	}
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return nil
+}
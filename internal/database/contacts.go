@@ -0,0 +1,105 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// contactReferences are the columns that point at a contact and are moved to the
+// contact a duplicate is merged into. Message audit records are append-only and keep
+// pointing at the merged-away contact, which is soft-deleted rather than removed.
+// Campaign recipients are matched to contacts by phone number, not ID.
+var contactReferences = []struct{ table, column string }{
+	{"messages", "contact_id"},
+	{"chatbot_sessions", "contact_id"},
+	{"agent_transfers", "contact_id"},
+	{"conversation_labels", "contact_id"},
+	{"conversation_participants", "contact_id"},
+	{"conversation_shares", "source_contact_id"},
+	{"conversation_shares", "target_contact_id"},
+	{"broadcast_list_members", "contact_id"},
+	{"sequence_enrollments", "contact_id"},
+	{"win_back_enrollments", "contact_id"},
+	{"automation_trigger_runs", "contact_id"},
+	{"automation_segment_members", "contact_id"},
+	{"link_clicks", "contact_id"},
+	{"orders", "contact_id"},
+}
+
+// contactMergeConflicts remove or retire the duplicate's rows where a contact may
+// appear only once and the kept contact already has one, before references move
+var contactMergeConflicts = []string{
+	`DELETE FROM conversation_participants r USING contact_merges m
+		WHERE r.contact_id = m.duplicate_id AND EXISTS (SELECT 1 FROM conversation_participants k WHERE k.contact_id = m.keep_id AND k.user_id = r.user_id)`,
+	`DELETE FROM broadcast_list_members r USING contact_merges m
+		WHERE r.contact_id = m.duplicate_id AND EXISTS (SELECT 1 FROM broadcast_list_members k WHERE k.contact_id = m.keep_id AND k.list_id = r.list_id)`,
+	`DELETE FROM automation_segment_members r USING contact_merges m
+		WHERE r.contact_id = m.duplicate_id AND EXISTS (SELECT 1 FROM automation_segment_members k WHERE k.contact_id = m.keep_id AND k.trigger_id = r.trigger_id)`,
+	`UPDATE conversation_labels r SET deleted_at = NOW() FROM contact_merges m
+		WHERE r.contact_id = m.duplicate_id AND r.deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM conversation_labels k WHERE k.contact_id = m.keep_id AND k.label_id = r.label_id AND k.deleted_at IS NULL)`,
+	`UPDATE sequence_enrollments r SET deleted_at = NOW() FROM contact_merges m
+		WHERE r.contact_id = m.duplicate_id AND r.status = 'active' AND r.deleted_at IS NULL
+		AND EXISTS (SELECT 1 FROM sequence_enrollments k WHERE k.contact_id = m.keep_id AND k.sequence_id = r.sequence_id AND k.status = 'active' AND k.deleted_at IS NULL)`,
+}
+
+// mergeDuplicateContacts merges contacts of an organization whose phone numbers differ
+// only by a leading +, which earlier versions allowed, so the unique index on the
+// normalized number can be created. Each number keeps its live contact, else its
+// oldest; the others' conversations and memberships move to it and they are
+// soft-deleted. Their numbers are prefixed with ~ so they no longer hold the index.
+// Nothing is done once the index exists. It returns how many contacts were merged away.
+func mergeDuplicateContacts(db *gorm.DB) (int64, error) {
+	var merged int64
+	var indexed int64
+	if err := db.Raw(`SELECT COUNT(*) FROM pg_indexes WHERE indexname = 'idx_contacts_org_normalized_phone'`).Scan(&indexed).Error; err != nil || indexed > 0 {
+		return 0, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`CREATE TEMP TABLE contact_merges ON COMMIT DROP AS
+			SELECT id AS duplicate_id, keep_id FROM (
+				SELECT id, first_value(id) OVER (
+					PARTITION BY organization_id, ltrim(phone_number, '+')
+					ORDER BY deleted_at IS NOT NULL, created_at, id
+				) AS keep_id
+				FROM contacts
+			) c WHERE id <> keep_id`).Error; err != nil {
+			return fmt.Errorf("failed to find duplicate contacts: %w", err)
+		}
+		if err := tx.Raw(`SELECT COUNT(*) FROM contact_merges`).Scan(&merged).Error; err != nil || merged == 0 {
+			return err
+		}
+
+		for _, stmt := range contactMergeConflicts {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("failed to merge duplicate contacts: %w", err)
+			}
+		}
+		for _, ref := range contactReferences {
+			if err := tx.Exec(fmt.Sprintf(`UPDATE %[1]s r SET %[2]s = m.keep_id FROM contact_merges m WHERE r.%[2]s = m.duplicate_id`,
+				ref.table, ref.column)).Error; err != nil {
+				return fmt.Errorf("failed to move %s of duplicate contacts: %w", ref.table, err)
+			}
+		}
+
+		// Keep a name the kept contact is missing, and the latest message of the two
+		if err := tx.Exec(`UPDATE contacts k SET profile_name = d.profile_name
+			FROM contact_merges m JOIN contacts d ON d.id = m.duplicate_id
+			WHERE k.id = m.keep_id AND k.profile_name = '' AND d.profile_name <> ''`).Error; err != nil {
+			return fmt.Errorf("failed to merge duplicate contacts: %w", err)
+		}
+		if err := tx.Exec(`UPDATE contacts k SET last_message_at = d.last_message_at, last_message_preview = d.last_message_preview, is_read = d.is_read
+			FROM contact_merges m JOIN contacts d ON d.id = m.duplicate_id
+			WHERE k.id = m.keep_id AND d.last_message_at > COALESCE(k.last_message_at, '-infinity')`).Error; err != nil {
+			return fmt.Errorf("failed to merge duplicate contacts: %w", err)
+		}
+		if err := tx.Exec(`UPDATE contacts c SET deleted_at = COALESCE(c.deleted_at, NOW()), phone_number = left('~' || c.phone_number, 20)
+			FROM contact_merges m WHERE c.id = m.duplicate_id`).Error; err != nil {
+			return fmt.Errorf("failed to remove duplicate contacts: %w", err)
+		}
+		return nil
+	})
+	return merged, err
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/changestream"
 	"github.com/shridarpatil/whatomate/internal/config"
 	"github.com/shridarpatil/whatomate/internal/models"
 	"golang.org/x/crypto/bcrypt"
@@ -62,36 +63,140 @@ func GetMigrationModels() []MigrationModel {
 		{"Team", &models.Team{}},
 		{"TeamMember", &models.TeamMember{}},
 		{"APIKey", &models.APIKey{}},
+		{"APIKeyUsage", &models.APIKeyUsage{}},
 		{"SSOProvider", &models.SSOProvider{}},
 		{"Webhook", &models.Webhook{}},
 		{"CustomAction", &models.CustomAction{}},
 		{"WhatsAppAccount", &models.WhatsAppAccount{}},
 		{"Contact", &models.Contact{}},
 		{"Message", &models.Message{}},
+		{"MessageEvent", &models.MessageEvent{}},
 		{"Template", &models.Template{}},
 		{"WhatsAppFlow", &models.WhatsAppFlow{}},
 
 		// Bulk & Notifications
 		{"BulkMessageCampaign", &models.BulkMessageCampaign{}},
 		{"BulkMessageRecipient", &models.BulkMessageRecipient{}},
+		{"CampaignVariant", &models.CampaignVariant{}},
+		{"CampaignDryRun", &models.CampaignDryRun{}},
+		{"CampaignDryRunRow", &models.CampaignDryRunRow{}},
 		{"NotificationRule", &models.NotificationRule{}},
+		{"HolidayCalendar", &models.HolidayCalendar{}},
+		{"CampaignBlackoutDate", &models.CampaignBlackoutDate{}},
+
+		// Reports
+		{"MessageDailyRollup", &models.MessageDailyRollup{}},
+		{"MessageTagDailyRollup", &models.MessageTagDailyRollup{}},
+		{"Report", &models.Report{}},
+		{"ReportRun", &models.ReportRun{}},
+		{"MetricAnomaly", &models.MetricAnomaly{}},
+		{"StatusReconciliation", &models.StatusReconciliation{}},
+		{"APICallLog", &models.APICallLog{}},
+
+		// Conversation labels
+		{"Label", &models.Label{}},
+		{"ConversationLabel", &models.ConversationLabel{}},
+		{"LabelRule", &models.LabelRule{}},
+
+		// Win-back automation
+		{"WinBackAutomation", &models.WinBackAutomation{}},
+		{"WinBackEnrollment", &models.WinBackEnrollment{}},
+
+		// Drip sequences
+		{"Sequence", &models.Sequence{}},
+		{"SequenceEnrollment", &models.SequenceEnrollment{}},
+
+		// Saved inbox views
+		{"InboxView", &models.InboxView{}},
+		{"InboxViewSubscription", &models.InboxViewSubscription{}},
+
+		// Date triggers
+		{"DateTrigger", &models.DateTrigger{}},
+
+		// Recurring campaigns
+		{"RecurringCampaign", &models.RecurringCampaign{}},
+
+		// Activity automation triggers
+		{"AutomationTrigger", &models.AutomationTrigger{}},
+		{"AutomationTriggerRun", &models.AutomationTriggerRun{}},
+		{"LinkClick", &models.LinkClick{}},
+		{"AutomationSegmentMember", &models.AutomationSegmentMember{}},
+
+		// Custom domains
+		{"CustomDomain", &models.CustomDomain{}},
+
+		// Test numbers
+		{"TestNumber", &models.TestNumber{}},
+		{"Job", &models.Job{}},
+
+		// Phone number migrations
+		{"PhoneNumberMigration", &models.PhoneNumberMigration{}},
+
+		// Conversation participants
+		{"ConversationParticipant", &models.ConversationParticipant{}},
+
+		// Orders
+		{"Order", &models.Order{}},
+		{"OrderConnector", &models.OrderConnector{}},
+
+		// External references
+		{"ExternalReference", &models.ExternalReference{}},
+
+		// Conversation shares between organizations
+		{"ConversationShare", &models.ConversationShare{}},
+
+		// Partners managing several organizations
+		{"Partner", &models.Partner{}},
+		{"PartnerMember", &models.PartnerMember{}},
+		{"PartnerAPIKey", &models.PartnerAPIKey{}},
+
+		// Branded emails and notifications
+		{"NotificationTemplate", &models.NotificationTemplate{}},
+		{"EmailSenderDomain", &models.EmailSenderDomain{}},
+
+		// Organization offboarding
+		{"OrganizationOffboarding", &models.OrganizationOffboarding{}},
+
+		// BI service accounts
+		{"BIServiceAccount", &models.BIServiceAccount{}},
+
+		// Change data capture
+		{"ChangeStream", &models.ChangeStream{}},
+		{"ChangeEvent", &models.ChangeEvent{}},
+
+		// Broadcast lists
+		{"BroadcastList", &models.BroadcastList{}},
+		{"BroadcastListMember", &models.BroadcastListMember{}},
+
+		// Compliance
+		{"MessageAuditRecord", &models.MessageAuditRecord{}},
 
 		// Chatbot models
 		{"ChatbotSettings", &models.ChatbotSettings{}},
 		{"KeywordRule", &models.KeywordRule{}},
 		{"ChatbotFlow", &models.ChatbotFlow{}},
 		{"ChatbotFlowStep", &models.ChatbotFlowStep{}},
+		{"ChatbotFlowVersion", &models.ChatbotFlowVersion{}},
+		{"ChatbotFlowDailyRollup", &models.ChatbotFlowDailyRollup{}},
 		{"ChatbotSession", &models.ChatbotSession{}},
 		{"ChatbotSessionMessage", &models.ChatbotSessionMessage{}},
+		{"FlowCredential", &models.FlowCredential{}},
 		{"AIContext", &models.AIContext{}},
 		{"AgentTransfer", &models.AgentTransfer{}},
 
 		// User tracking
 		{"UserAvailabilityLog", &models.UserAvailabilityLog{}},
 
+		// Notifications
+		{"PushDevice", &models.PushDevice{}},
+		{"NotificationPreference", &models.NotificationPreference{}},
+
 		// Canned responses
 		{"CannedResponse", &models.CannedResponse{}},
 
+		// Stickers
+		{"Sticker", &models.Sticker{}},
+
 		// Catalogs
 		{"Catalog", &models.Catalog{}},
 		{"CatalogProduct", &models.CatalogProduct{}},
@@ -116,9 +221,11 @@ func RunMigrationWithProgress(db *gorm.DB) error {
 
 	migrationModels := GetMigrationModels()
 	indexes := getIndexes()
+	indexes = append(indexes, changestream.Triggers()...)
+	reporting := reportingSchemaStatements()
 
-	// Total steps: models + indexes + default admin check
-	totalSteps := len(migrationModels) + len(indexes) + 1
+	// Total steps: reporting schema drop + models + duplicate contact merge + indexes and triggers + reporting schema + default admin check
+	totalSteps := 1 + len(migrationModels) + 1 + len(indexes) + len(reporting) + 1
 	currentStep := 0
 	barWidth := 40
 
@@ -134,6 +241,14 @@ func RunMigrationWithProgress(db *gorm.DB) error {
 
 	fmt.Println()
 
+	// Drop the reporting views so they don't block column changes; they are recreated below
+	printProgress(currentStep, totalSteps)
+	if err := silentDB.Exec(dropReportingSchema).Error; err != nil {
+		fmt.Printf("\n  \033[31m✗ Reporting schema drop failed\033[0m\n\n")
+		return fmt.Errorf("failed to drop reporting schema: %w", err)
+	}
+	currentStep++
+
 	// Migrate models
 	for _, m := range migrationModels {
 		printProgress(currentStep, totalSteps)
@@ -144,6 +259,18 @@ func RunMigrationWithProgress(db *gorm.DB) error {
 		currentStep++
 	}
 
+	// Merge contacts the unique normalized phone index would refuse
+	printProgress(currentStep, totalSteps)
+	merged, err := mergeDuplicateContacts(silentDB)
+	if err != nil {
+		fmt.Printf("\n  \033[31m✗ Duplicate contact merge failed\033[0m\n\n")
+		return err
+	}
+	if merged > 0 {
+		fmt.Printf("\n  Merged %d duplicate contacts\n", merged)
+	}
+	currentStep++
+
 	// Create indexes
 	for _, idx := range indexes {
 		printProgress(currentStep, totalSteps)
@@ -154,6 +281,16 @@ func RunMigrationWithProgress(db *gorm.DB) error {
 		currentStep++
 	}
 
+	// Create the reporting schema for BI service accounts
+	for _, stmt := range reporting {
+		printProgress(currentStep, totalSteps)
+		if err := silentDB.Exec(stmt).Error; err != nil {
+			fmt.Printf("\n  \033[31m✗ Reporting schema creation failed\033[0m\n\n")
+			return fmt.Errorf("failed to create reporting schema: %w", err)
+		}
+		currentStep++
+	}
+
 	// Create default admin
 	printProgress(currentStep, totalSteps)
 	if err := CreateDefaultAdmin(silentDB); err != nil {
@@ -183,6 +320,7 @@ func getIndexes() []string {
 		`CREATE INDEX IF NOT EXISTS idx_messages_contact_created ON messages(contact_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id)`,
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_org_phone ON contacts(organization_id, phone_number)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_org_normalized_phone ON contacts(organization_id, (ltrim(phone_number, '+')))`,
 		`CREATE INDEX IF NOT EXISTS idx_contacts_assigned_read ON contacts(assigned_user_id, is_read)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_phone_status ON chatbot_sessions(organization_id, phone_number, status)`,
 		`CREATE INDEX IF NOT EXISTS idx_keyword_rules_priority ON keyword_rules(organization_id, is_enabled, priority DESC)`,
@@ -205,6 +343,47 @@ func getIndexes() []string {
 		`CREATE INDEX IF NOT EXISTS idx_availability_logs_user_time ON user_availability_logs(user_id, started_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_availability_logs_org_time ON user_availability_logs(organization_id, started_at DESC)`,
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sso_providers_org_provider ON sso_providers(organization_id, provider)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_api_key_usages_unique ON api_key_usages(api_key_id, date, method, endpoint)`,
+		`CREATE INDEX IF NOT EXISTS idx_campaign_blackout_dates_org_date ON campaign_blackout_dates(organization_id, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_created ON messages(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_message_daily_rollups_org_date ON message_daily_rollups(organization_id, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_message_tag_daily_rollups_org_date ON message_tag_daily_rollups(organization_id, date, tag)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_anomalies_unique ON metric_anomalies(organization_id, whats_app_account, date, metric)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_status_reconciliations_unique ON status_reconciliations(organization_id, whats_app_account, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_stuck_sent ON messages(whats_app_account, created_at) WHERE direction = 'outgoing' AND status = 'sent'`,
+		`CREATE INDEX IF NOT EXISTS idx_api_call_logs_account ON api_call_logs(organization_id, whats_app_account, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_contacts_org_engagement ON contacts(organization_id, engagement_score DESC)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_org_name ON labels(organization_id, LOWER(name)) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_labels_contact_label ON conversation_labels(contact_id, label_id) WHERE deleted_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_win_back_enrollments_due ON win_back_enrollments(automation_id, next_send_at) WHERE status = 'active'`,
+		`CREATE INDEX IF NOT EXISTS idx_sequence_enrollments_due ON sequence_enrollments(sequence_id, next_send_at) WHERE status = 'active'`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sequence_enrollments_active ON sequence_enrollments(sequence_id, contact_id) WHERE status = 'active' AND deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_inbox_view_subscriptions_view_user ON inbox_view_subscriptions(view_id, user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_automation_trigger_runs_contact ON automation_trigger_runs(trigger_id, contact_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_automation_trigger_runs_trigger ON automation_trigger_runs(trigger_id, created_at DESC)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_domain ON custom_domains(domain) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_org ON custom_domains(organization_id) WHERE deleted_at IS NULL`,
+
+		// Test numbers: a number is registered once per organization
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_test_numbers_org_phone ON test_numbers(organization_id, phone_number) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_phone_migrations_active ON phone_number_migrations(whats_app_account_id) WHERE status NOT IN ('completed', 'cancelled') AND deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_participants_contact_user ON conversation_participants(contact_id, user_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_source_external ON orders(organization_id, source, external_id) WHERE external_id <> '' AND deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_external_refs_entity_key ON external_references(organization_id, entity_type, entity_id, key) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_external_refs_lookup ON external_references(organization_id, entity_type, key, value) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_flow_credentials_org_name ON flow_credentials(organization_id, name) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_chatbot_flow_versions_flow_version ON chatbot_flow_versions(flow_id, version) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_chatbot_flow_daily_rollups_unique ON chatbot_flow_daily_rollups(flow_id, flow_version, date, step_name, event, value)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_partner_members_user ON partner_members(user_id) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_templates_org_key ON notification_templates(organization_id, key) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_email_sender_domains_org ON email_sender_domains(organization_id) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_offboardings_active ON organization_offboardings(organization_id) WHERE status IN ('exporting', 'scheduled', 'purging') AND deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_bi_service_accounts_role ON bi_service_accounts(role_name)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_change_streams_org ON change_streams(organization_id) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_broadcast_list_members_list_contact ON broadcast_list_members(list_id, contact_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_message_audit_records_org_seq ON message_audit_records(organization_id, sequence)`,
+		`CREATE OR REPLACE FUNCTION message_audit_records_write_once() RETURNS trigger AS $$ BEGIN RAISE EXCEPTION 'message_audit_records is append-only'; END; $$ LANGUAGE plpgsql`,
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'message_audit_records_write_once') THEN CREATE TRIGGER message_audit_records_write_once BEFORE UPDATE OR DELETE ON message_audit_records FOR EACH ROW EXECUTE FUNCTION message_audit_records_write_once(); END IF; END $$`,
 	}
 }
 
@@ -217,6 +396,7 @@ func CreateIndexes(db *gorm.DB) error {
 
 		// Contacts indexes
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_org_phone ON contacts(organization_id, phone_number)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_contacts_org_normalized_phone ON contacts(organization_id, (ltrim(phone_number, '+')))`,
 		`CREATE INDEX IF NOT EXISTS idx_contacts_assigned_read ON contacts(assigned_user_id, is_read)`,
 
 		// Sessions indexes
@@ -268,6 +448,98 @@ func CreateIndexes(db *gorm.DB) error {
 
 		// SSO providers indexes
 		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sso_providers_org_provider ON sso_providers(organization_id, provider)`,
+
+		// API key usage indexes
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_api_key_usages_unique ON api_key_usages(api_key_id, date, method, endpoint)`,
+
+		// Campaign blackout dates indexes
+		`CREATE INDEX IF NOT EXISTS idx_campaign_blackout_dates_org_date ON campaign_blackout_dates(organization_id, date)`,
+
+		// Report rollup indexes
+		`CREATE INDEX IF NOT EXISTS idx_messages_created ON messages(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_message_daily_rollups_org_date ON message_daily_rollups(organization_id, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_message_tag_daily_rollups_org_date ON message_tag_daily_rollups(organization_id, date, tag)`,
+
+		// Metric anomaly indexes
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_metric_anomalies_unique ON metric_anomalies(organization_id, whats_app_account, date, metric)`,
+
+		// Status reconciliation: one report per account and day, and the messages it looks for
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_status_reconciliations_unique ON status_reconciliations(organization_id, whats_app_account, date)`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_stuck_sent ON messages(whats_app_account, created_at) WHERE direction = 'outgoing' AND status = 'sent'`,
+
+		// Graph API debug logs, listed and pruned per account
+		`CREATE INDEX IF NOT EXISTS idx_api_call_logs_account ON api_call_logs(organization_id, whats_app_account, created_at DESC)`,
+
+		// Engagement score indexes
+		`CREATE INDEX IF NOT EXISTS idx_contacts_org_engagement ON contacts(organization_id, engagement_score DESC)`,
+
+		// Conversation label indexes
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_labels_org_name ON labels(organization_id, LOWER(name)) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_labels_contact_label ON conversation_labels(contact_id, label_id) WHERE deleted_at IS NULL`,
+
+		// Win-back enrollment indexes
+		`CREATE INDEX IF NOT EXISTS idx_win_back_enrollments_due ON win_back_enrollments(automation_id, next_send_at) WHERE status = 'active'`,
+
+		// Sequence enrollment indexes: due steps, and one active enrollment per contact
+		`CREATE INDEX IF NOT EXISTS idx_sequence_enrollments_due ON sequence_enrollments(sequence_id, next_send_at) WHERE status = 'active'`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_sequence_enrollments_active ON sequence_enrollments(sequence_id, contact_id) WHERE status = 'active' AND deleted_at IS NULL`,
+
+		// A user subscribes to an inbox view once
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_inbox_view_subscriptions_view_user ON inbox_view_subscriptions(view_id, user_id)`,
+
+		// Automation trigger runs, checked per contact for guardrails and listed per trigger
+		`CREATE INDEX IF NOT EXISTS idx_automation_trigger_runs_contact ON automation_trigger_runs(trigger_id, contact_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_automation_trigger_runs_trigger ON automation_trigger_runs(trigger_id, created_at DESC)`,
+
+		// Custom domains: a hostname belongs to one organization, which has at most one
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_domain ON custom_domains(domain) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_org ON custom_domains(organization_id) WHERE deleted_at IS NULL`,
+
+		// Test numbers: a number is registered once per organization
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_test_numbers_org_phone ON test_numbers(organization_id, phone_number) WHERE deleted_at IS NULL`,
+
+		// Phone number migrations: one in progress per account
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_phone_migrations_active ON phone_number_migrations(whats_app_account_id) WHERE status NOT IN ('completed', 'cancelled') AND deleted_at IS NULL`,
+
+		// Conversation participants: one row per contact and user
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_conversation_participants_contact_user ON conversation_participants(contact_id, user_id)`,
+
+		// Orders: a store's order is recorded once
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_source_external ON orders(organization_id, source, external_id) WHERE external_id <> '' AND deleted_at IS NULL`,
+
+		// External references: one value per record and key, one record per value
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_external_refs_entity_key ON external_references(organization_id, entity_type, entity_id, key) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_external_refs_lookup ON external_references(organization_id, entity_type, key, value) WHERE deleted_at IS NULL`,
+
+		// Flow credentials: names are unique within an organization
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_flow_credentials_org_name ON flow_credentials(organization_id, name) WHERE deleted_at IS NULL`,
+
+		// Chatbot flow versions: numbered in order within a flow
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_chatbot_flow_versions_flow_version ON chatbot_flow_versions(flow_id, version) WHERE deleted_at IS NULL`,
+
+		// Chatbot flow analytics: one counter per flow version, day, step, event and value
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_chatbot_flow_daily_rollups_unique ON chatbot_flow_daily_rollups(flow_id, flow_version, date, step_name, event, value)`,
+
+		// Partner members: a user belongs to one partner
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_partner_members_user ON partner_members(user_id) WHERE deleted_at IS NULL`,
+
+		// Notifications: one template per organization and key, one sender domain per organization
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_templates_org_key ON notification_templates(organization_id, key) WHERE deleted_at IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_email_sender_domains_org ON email_sender_domains(organization_id) WHERE deleted_at IS NULL`,
+		// Offboarding: one offboarding in progress per organization
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_organization_offboardings_active ON organization_offboardings(organization_id) WHERE status IN ('exporting', 'scheduled', 'purging') AND deleted_at IS NULL`,
+		// BI service accounts: a Postgres role belongs to one account
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_bi_service_accounts_role ON bi_service_accounts(role_name)`,
+		// Change streams: one per organization
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_change_streams_org ON change_streams(organization_id) WHERE deleted_at IS NULL`,
+
+		// Broadcast lists: a contact is a member once
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_broadcast_list_members_list_contact ON broadcast_list_members(list_id, contact_id)`,
+
+		// Message audit log: chain order is unique and the table is write-once
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_message_audit_records_org_seq ON message_audit_records(organization_id, sequence)`,
+		`CREATE OR REPLACE FUNCTION message_audit_records_write_once() RETURNS trigger AS $$ BEGIN RAISE EXCEPTION 'message_audit_records is append-only'; END; $$ LANGUAGE plpgsql`,
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'message_audit_records_write_once') THEN CREATE TRIGGER message_audit_records_write_once BEFORE UPDATE OR DELETE ON message_audit_records FOR EACH ROW EXECUTE FUNCTION message_audit_records_write_once(); END IF; END $$`,
 	}
 
 	for _, idx := range indexes {
@@ -0,0 +1,405 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ReportingSchema is the Postgres schema BI service accounts read from
+const ReportingSchema = "reporting"
+
+// ErrReportingNotPermitted is returned when the database user can't manage roles
+var ErrReportingNotPermitted = errors.New("the database user needs the CREATEROLE privilege to manage BI service accounts")
+
+// ReportingColumn is one column of a reporting view
+type ReportingColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	source      string // Expression over the view's source tables
+}
+
+// ReportingView is one view of the reporting schema. The views are a stable interface
+// for BI tools: columns are only ever added, at the end, so saved questions and
+// dashboards keep working across upgrades. Credentials, tokens and message content
+// are left out.
+type ReportingView struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Columns     []ReportingColumn `json:"columns"`
+	from        string            // Source tables, the main one aliased t
+	org         string            // Column holding the organization ID
+}
+
+// ReportingViews is the documented reporting schema
+var ReportingViews = []ReportingView{
+	{
+		Name:        "whatsapp_accounts",
+		Description: "WhatsApp Business phone numbers connected to the organization",
+		from:        "whatsapp_accounts t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Account ID", "t.id"},
+			{"name", "text", "Account name, referenced by whatsapp_account in other views", "t.name"},
+			{"phone_id", "text", "Meta phone number ID", "t.phone_id"},
+			{"business_id", "text", "Meta WhatsApp Business Account ID", "t.business_id"},
+			{"verified_name", "text", "Display name verified by Meta", "t.verified_name"},
+			{"status", "text", "active, or suspended while the organization is offboarding", "t.status"},
+			{"quality_rating", "text", "Meta quality rating: GREEN, YELLOW or RED", "t.quality_rating"},
+			{"messaging_limit_tier", "text", "Meta messaging limit tier", "t.messaging_limit_tier"},
+			{"created_at", "timestamptz", "When the account was connected", "t.created_at"},
+		},
+	},
+	{
+		Name:        "users",
+		Description: "Agents, managers and admins",
+		from:        "users t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "User ID", "t.id"},
+			{"full_name", "text", "Full name", "t.full_name"},
+			{"email", "text", "Email address", "t.email"},
+			{"role", "text", "admin, manager or agent", "t.role"},
+			{"is_active", "boolean", "Whether the user can sign in", "t.is_active"},
+			{"created_at", "timestamptz", "When the user was added", "t.created_at"},
+		},
+	},
+	{
+		Name:        "teams",
+		Description: "Agent teams",
+		from:        "teams t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Team ID", "t.id"},
+			{"name", "text", "Team name", "t.name"},
+			{"assignment_strategy", "text", "round_robin, load_balanced or manual", "t.assignment_strategy"},
+			{"is_active", "boolean", "Whether the team receives transfers", "t.is_active"},
+			{"created_at", "timestamptz", "When the team was created", "t.created_at"},
+		},
+	},
+	{
+		Name:        "team_members",
+		Description: "Users in each team",
+		from:        "team_members t JOIN teams p ON p.id = t.team_id AND p.deleted_at IS NULL",
+		org:         "p.organization_id",
+		Columns: []ReportingColumn{
+			{"team_id", "uuid", "Team, see teams.id", "t.team_id"},
+			{"user_id", "uuid", "User, see users.id", "t.user_id"},
+			{"role", "text", "manager or agent", "t.role"},
+		},
+	},
+	{
+		Name:        "contacts",
+		Description: "WhatsApp contacts",
+		from:        "contacts t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Contact ID", "t.id"},
+			{"phone_number", "text", "Phone number", "t.phone_number"},
+			{"profile_name", "text", "WhatsApp profile name", "t.profile_name"},
+			{"whatsapp_account", "text", "Account the contact messages, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"assigned_user_id", "uuid", "Assigned agent, see users.id", "t.assigned_user_id"},
+			{"tags", "jsonb", "Tags as a JSON array of strings", "t.tags"},
+			{"engagement_score", "integer", "Engagement score from 0 to 100", "t.engagement_score"},
+			{"last_message_at", "timestamptz", "Time of the latest message", "t.last_message_at"},
+			{"created_at", "timestamptz", "When the contact was first seen", "t.created_at"},
+		},
+	},
+	{
+		Name:        "messages",
+		Description: "Messages sent and received, without their content or media",
+		from:        "messages t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Message ID", "t.id"},
+			{"contact_id", "uuid", "Contact, see contacts.id", "t.contact_id"},
+			{"whatsapp_account", "text", "Account, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"conversation_id", "text", "Meta conversation ID, set on outgoing messages once billed", "t.conversation_id"},
+			{"direction", "text", "incoming or outgoing", "t.direction"},
+			{"message_type", "text", "text, image, template, interactive, ...", "t.message_type"},
+			{"status", "text", "pending, sent, delivered, read or failed", "t.status"},
+			{"template_name", "text", "Template of a template message", "t.template_name"},
+			{"is_reply", "boolean", "Whether the message quotes another message", "t.is_reply"},
+			{"sent_by_user_id", "uuid", "Agent who sent it, see users.id; empty for automated messages", "t.sent_by_user_id"},
+			{"error_message", "text", "Why the message failed", "t.error_message"},
+			{"created_at", "timestamptz", "When the message was sent or received", "t.created_at"},
+			{"updated_at", "timestamptz", "Time of the latest status change", "t.updated_at"},
+		},
+	},
+	{
+		Name:        "message_daily_rollups",
+		Description: "Message counts per day, account, template and agent",
+		from:        "message_daily_rollups t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"date", "date", "Day, in the organization's timezone", "t.date"},
+			{"whatsapp_account", "text", "Account, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"template_name", "text", "Template, empty for non-template messages", "t.template_name"},
+			{"agent_id", "uuid", "Agent who sent the message or the message replied to, see users.id", "t.agent_id"},
+			{"sent_count", "bigint", "Messages sent", "t.sent_count"},
+			{"delivered_count", "bigint", "Messages delivered", "t.delivered_count"},
+			{"read_count", "bigint", "Messages read", "t.read_count"},
+			{"failed_count", "bigint", "Messages failed", "t.failed_count"},
+			{"reply_count", "bigint", "Incoming messages", "t.reply_count"},
+			{"conversion_count", "bigint", "Incoming button and interactive responses", "t.conversion_count"},
+		},
+	},
+	{
+		Name:        "templates",
+		Description: "Message templates",
+		from:        "templates t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Template ID", "t.id"},
+			{"whatsapp_account", "text", "Account, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"name", "text", "Template name", "t.name"},
+			{"language", "text", "Language code", "t.language"},
+			{"category", "text", "MARKETING, UTILITY or AUTHENTICATION", "t.category"},
+			{"status", "text", "Meta review status: PENDING, APPROVED or REJECTED", "t.status"},
+			{"created_at", "timestamptz", "When the template was created", "t.created_at"},
+		},
+	},
+	{
+		Name:        "campaigns",
+		Description: "Bulk message campaigns",
+		from:        "bulk_message_campaigns t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Campaign ID", "t.id"},
+			{"name", "text", "Campaign name", "t.name"},
+			{"whatsapp_account", "text", "Account, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"template_id", "uuid", "Template, see templates.id", "t.template_id"},
+			{"status", "text", "draft, pending_template, scheduled, queued, processing, paused, completed, cancelled or failed", "t.status"},
+			{"total_recipients", "integer", "Recipients", "t.total_recipients"},
+			{"sent_count", "integer", "Messages sent", "t.sent_count"},
+			{"delivered_count", "integer", "Messages delivered", "t.delivered_count"},
+			{"read_count", "integer", "Messages read", "t.read_count"},
+			{"failed_count", "integer", "Messages failed", "t.failed_count"},
+			{"scheduled_at", "timestamptz", "When the campaign is scheduled to start", "t.scheduled_at"},
+			{"started_at", "timestamptz", "When sending started", "t.started_at"},
+			{"completed_at", "timestamptz", "When sending finished", "t.completed_at"},
+			{"created_by", "uuid", "User who created it, see users.id", "t.created_by"},
+			{"created_at", "timestamptz", "When the campaign was created", "t.created_at"},
+		},
+	},
+	{
+		Name:        "campaign_recipients",
+		Description: "Recipients of each campaign and their delivery status",
+		from:        "bulk_message_recipients t JOIN bulk_message_campaigns p ON p.id = t.campaign_id AND p.deleted_at IS NULL",
+		org:         "p.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Recipient ID", "t.id"},
+			{"campaign_id", "uuid", "Campaign, see campaigns.id", "t.campaign_id"},
+			{"phone_number", "text", "Recipient phone number", "t.phone_number"},
+			{"status", "text", "pending, sent, delivered, read or failed", "t.status"},
+			{"message_id", "uuid", "Message sent, see messages.id", "t.message_id"},
+			{"error_message", "text", "Why sending failed", "t.error_message"},
+			{"sent_at", "timestamptz", "When the message was sent", "t.sent_at"},
+			{"delivered_at", "timestamptz", "When the message was delivered", "t.delivered_at"},
+			{"read_at", "timestamptz", "When the message was read", "t.read_at"},
+		},
+	},
+	{
+		Name:        "agent_transfers",
+		Description: "Conversations handed from the chatbot to agents, with SLA timings",
+		from:        "agent_transfers t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Transfer ID", "t.id"},
+			{"contact_id", "uuid", "Contact, see contacts.id", "t.contact_id"},
+			{"whatsapp_account", "text", "Account, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"status", "text", "active or resumed", "t.status"},
+			{"source", "text", "manual, flow, keyword or chatbot_disabled", "t.source"},
+			{"agent_id", "uuid", "Agent, see users.id; empty while queued", "t.agent_id"},
+			{"team_id", "uuid", "Team, see teams.id", "t.team_id"},
+			{"transferred_at", "timestamptz", "When the conversation was transferred", "t.transferred_at"},
+			{"picked_up_at", "timestamptz", "When an agent picked it up", "t.picked_up_at"},
+			{"first_response_at", "timestamptz", "Time of the agent's first reply", "t.first_response_at"},
+			{"resumed_at", "timestamptz", "When the conversation went back to the chatbot", "t.resumed_at"},
+			{"escalation_level", "integer", "Times the SLA escalated the transfer", "t.escalation_level"},
+			{"sla_breached", "boolean", "Whether the SLA was breached", "t.sla_breached"},
+			{"sla_breached_at", "timestamptz", "When the SLA was breached", "t.sla_breached_at"},
+		},
+	},
+	{
+		Name:        "chatbot_sessions",
+		Description: "Chatbot conversations",
+		from:        "chatbot_sessions t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Session ID", "t.id"},
+			{"contact_id", "uuid", "Contact, see contacts.id", "t.contact_id"},
+			{"whatsapp_account", "text", "Account, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"status", "text", "active, completed, cancelled or timeout", "t.status"},
+			{"current_flow_id", "uuid", "Flow the session is in", "t.current_flow_id"},
+			{"started_at", "timestamptz", "When the session started", "t.started_at"},
+			{"last_activity_at", "timestamptz", "Time of the latest message", "t.last_activity_at"},
+			{"completed_at", "timestamptz", "When the session ended", "t.completed_at"},
+		},
+	},
+	{
+		Name:        "labels",
+		Description: "Conversation labels",
+		from:        "labels t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Label ID", "t.id"},
+			{"name", "text", "Label name", "t.name"},
+			{"color", "text", "Label color", "t.color"},
+		},
+	},
+	{
+		Name:        "conversation_labels",
+		Description: "Labels applied to conversations",
+		from:        "conversation_labels t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"contact_id", "uuid", "Conversation's contact, see contacts.id", "t.contact_id"},
+			{"label_id", "uuid", "Label, see labels.id", "t.label_id"},
+			{"source", "text", "manual or rule", "t.source"},
+			{"created_at", "timestamptz", "When the label was applied", "t.created_at"},
+		},
+	},
+	{
+		Name:        "orders",
+		Description: "Orders attributed to conversations and campaigns",
+		from:        "orders t",
+		org:         "t.organization_id",
+		Columns: []ReportingColumn{
+			{"id", "uuid", "Order ID", "t.id"},
+			{"contact_id", "uuid", "Contact, see contacts.id", "t.contact_id"},
+			{"whatsapp_account", "text", "Account, see whatsapp_accounts.name", "t.whats_app_account"},
+			{"source", "text", "whatsapp, shopify, woocommerce or manual", "t.source"},
+			{"order_number", "text", "Order number", "t.order_number"},
+			{"status", "text", "pending, paid, fulfilled, cancelled or refunded", "t.status"},
+			{"currency", "text", "ISO 4217 currency code", "t.currency"},
+			{"total_amount", "bigint", "Total in cents", "t.total_amount"},
+			{"campaign_id", "uuid", "Attributed campaign, see campaigns.id", "t.campaign_id"},
+			{"agent_id", "uuid", "Attributed agent, see users.id", "t.agent_id"},
+			{"ordered_at", "timestamptz", "When the order was placed", "t.ordered_at"},
+		},
+	},
+}
+
+// dropReportingSchema removes the reporting views before tables are migrated, since
+// Postgres won't change the type of a column a view selects
+const dropReportingSchema = `DROP SCHEMA IF EXISTS ` + ReportingSchema + ` CASCADE`
+
+// reportingSchemaStatements creates the reporting schema and grants it to the existing
+// BI service accounts again. Each view shows the rows of the organization whose
+// account is connected, through reporting.organization_id(), which runs as the schema
+// owner to look up the connected role; security_barrier keeps rows of other
+// organizations from leaking through functions in a BI user's query.
+func reportingSchemaStatements() []string {
+	stmts := []string{
+		`CREATE SCHEMA IF NOT EXISTS ` + ReportingSchema,
+		`CREATE OR REPLACE FUNCTION ` + ReportingSchema + `.organization_id() RETURNS uuid
+			LANGUAGE sql STABLE SECURITY DEFINER SET search_path FROM CURRENT AS
+			$$ SELECT organization_id FROM bi_service_accounts WHERE role_name = session_user AND is_active AND deleted_at IS NULL $$`,
+		`COMMENT ON SCHEMA ` + ReportingSchema + ` IS 'Read-only analytical views for BI service accounts'`,
+	}
+
+	for _, v := range ReportingViews {
+		cols := make([]string, len(v.Columns))
+		for i, c := range v.Columns {
+			cols[i] = c.source + " AS " + c.Name
+		}
+		name := ReportingSchema + "." + v.Name
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE OR REPLACE VIEW %s WITH (security_barrier) AS SELECT %s FROM %s WHERE %s = %s.organization_id() AND t.deleted_at IS NULL",
+			name, strings.Join(cols, ", "), v.from, v.org, ReportingSchema))
+		stmts = append(stmts, fmt.Sprintf("COMMENT ON VIEW %s IS %s", name, quoteLiteral(v.Description)))
+		for _, c := range v.Columns {
+			stmts = append(stmts, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", name, c.Name, quoteLiteral(c.Description)))
+		}
+	}
+
+	return append(stmts, `DO $$ DECLARE r record; BEGIN
+		FOR r IN SELECT a.role_name FROM bi_service_accounts a JOIN pg_roles p ON p.rolname = a.role_name WHERE a.deleted_at IS NULL LOOP
+			EXECUTE format('GRANT USAGE ON SCHEMA `+ReportingSchema+` TO %I', r.role_name);
+			EXECUTE format('GRANT SELECT ON ALL TABLES IN SCHEMA `+ReportingSchema+` TO %I', r.role_name);
+		END LOOP; END $$`)
+}
+
+// CanManageReportingRoles reports whether the database user can create BI service
+// account roles
+func CanManageReportingRoles(db *gorm.DB) bool {
+	var ok bool
+	db.Raw(`SELECT rolcreaterole OR rolsuper FROM pg_roles WHERE rolname = current_user`).Scan(&ok)
+	return ok
+}
+
+// CreateReportingRole creates a login role that can only read the reporting schema.
+// Besides the grants, its sessions default to read-only transactions with a statement
+// timeout, and connections are limited so a busy dashboard can't exhaust the server.
+func CreateReportingRole(db *gorm.DB, role, password string) error {
+	return reportingDDL(db, func(tx *gorm.DB) error {
+		id := quoteIdent(role)
+		for _, stmt := range []string{
+			"CREATE ROLE " + id + " LOGIN NOINHERIT CONNECTION LIMIT 5 PASSWORD " + quoteLiteral(password),
+			"ALTER ROLE " + id + " SET default_transaction_read_only = on",
+			"ALTER ROLE " + id + " SET statement_timeout = '60s'",
+			"ALTER ROLE " + id + " SET search_path = " + ReportingSchema,
+			"GRANT USAGE ON SCHEMA " + ReportingSchema + " TO " + id,
+			"GRANT SELECT ON ALL TABLES IN SCHEMA " + ReportingSchema + " TO " + id,
+		} {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetReportingRolePassword replaces a reporting role's password
+func SetReportingRolePassword(db *gorm.DB, role, password string) error {
+	return reportingDDL(db, func(tx *gorm.DB) error {
+		return tx.Exec("ALTER ROLE " + quoteIdent(role) + " PASSWORD " + quoteLiteral(password)).Error
+	})
+}
+
+// DropReportingRole revokes a reporting role's grants and drops it, ending its open
+// connections first. A role that no longer exists is not an error.
+func DropReportingRole(db *gorm.DB, role string) error {
+	var exists bool
+	if err := db.Raw(`SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ?)`, role).Scan(&exists).Error; err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	db.Exec(`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE usename = ?`, role)
+	return reportingDDL(db, func(tx *gorm.DB) error {
+		id := quoteIdent(role)
+		if err := tx.Exec("DROP OWNED BY " + id).Error; err != nil {
+			return err
+		}
+		return tx.Exec("DROP ROLE " + id).Error
+	})
+}
+
+// reportingDDL runs role statements in a transaction with logging off, so passwords
+// don't end up in the SQL log, and reports a missing CREATEROLE privilege as
+// ErrReportingNotPermitted
+func reportingDDL(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	err := db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)}).Transaction(fn)
+	if err != nil && strings.Contains(err.Error(), "SQLSTATE 42501") {
+		return ErrReportingNotPermitted
+	}
+	return err
+}
+
+// quoteIdent quotes a Postgres identifier
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a Postgres string literal; role DDL can't take bind parameters
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,76 @@
+// Package egress resolves the HTTPS proxy an organization requires its Graph API
+// traffic to go through, for customers whose compliance needs fixed egress IPs.
+package egress
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"gorm.io/gorm"
+)
+
+// SettingKey is the organization setting holding the proxy URL
+const SettingKey = "egress_proxy"
+
+// cacheTTL bounds how long a changed proxy setting takes to apply
+const cacheTTL = time.Minute
+
+type entry struct {
+	proxy     string
+	expiresAt time.Time
+}
+
+// Resolver maps WhatsApp accounts to their organization's egress proxy
+type Resolver struct {
+	db      *gorm.DB
+	entries sync.Map // phone or business ID -> entry
+}
+
+// NewResolver creates a resolver backed by db
+func NewResolver(db *gorm.DB) *Resolver {
+	return &Resolver{db: db}
+}
+
+// Proxy returns the egress proxy configured by the organization that owns
+// account, or "" if it has none. Use it as whatsapp.Client.ProxyResolver.
+func (r *Resolver) Proxy(account *whatsapp.Account) (string, error) {
+	column, id := "phone_id", account.PhoneID
+	if id == "" {
+		column, id = "business_id", account.BusinessID
+	}
+	if id == "" {
+		return "", nil
+	}
+
+	key := column + ":" + id
+	if e, ok := r.entries.Load(key); ok && time.Now().Before(e.(entry).expiresAt) {
+		return e.(entry).proxy, nil
+	}
+
+	var proxies []string
+	err := r.db.Raw(`
+		SELECT COALESCE(o.settings->>?, '') FROM whatsapp_accounts wa
+		JOIN organizations o ON o.id = wa.organization_id
+		WHERE wa.`+column+` = ? AND wa.deleted_at IS NULL
+		LIMIT 1`, SettingKey, id).
+		Scan(&proxies).Error
+	if err != nil {
+		// Fail the request rather than risk sending it from an unapproved IP
+		return "", fmt.Errorf("failed to resolve egress proxy: %w", err)
+	}
+
+	proxy := ""
+	if len(proxies) > 0 {
+		proxy = proxies[0]
+	}
+	r.entries.Store(key, entry{proxy: proxy, expiresAt: time.Now().Add(cacheTTL)})
+	return proxy, nil
+}
+
+// Invalidate drops cached proxies so a changed setting applies immediately on
+// this instance
+func (r *Resolver) Invalidate() {
+	r.entries.Clear()
+}
@@ -0,0 +1,147 @@
+// Package engagement scores how engaged each contact is from their recent message
+// history. Scores run from 0 to 100 and are recomputed periodically by the worker.
+package engagement
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// Window is how far back message history counts towards the score
+	Window = 90 * 24 * time.Hour
+
+	// RefreshInterval is how often scores are recomputed
+	RefreshInterval = 6 * time.Hour
+
+	// batchSize is how many contacts are scored per query
+	batchSize = 1000
+
+	// Replies in the window at which the frequency component maxes out
+	fullFrequency = 20
+
+	// Component weights; they add up to 100
+	recencyWeight   = 35
+	frequencyWeight = 35
+	readWeight      = 30
+)
+
+// OptOutKeywords are replies treated as an opt-out request
+var OptOutKeywords = []string{"STOP", "STOP ALL", "UNSUBSCRIBE", "OPT OUT", "OPTOUT", "CANCEL"}
+
+// NotOptedOut is a condition on the contacts table that excludes contacts who replied
+// with an opt-out keyword; its argument is OptOutKeywords
+const NotOptedOut = `NOT EXISTS (SELECT 1 FROM messages m WHERE m.contact_id = contacts.id
+	AND m.direction = 'incoming' AND m.deleted_at IS NULL AND UPPER(TRIM(m.content)) IN ?)`
+
+// Stats is the message history a contact's score is computed from
+type Stats struct {
+	ContactID uuid.UUID
+	LastReply *time.Time // Most recent incoming message in the window
+	Replies   int64      // Incoming messages in the window
+	Delivered int64      // Outgoing messages delivered or read
+	Read      int64      // Outgoing messages read
+	OptedOut  bool       // The contact asked to stop receiving messages
+}
+
+// Score combines reply recency, reply frequency and read rate. A contact that has
+// opted out scores 0 regardless of the rest.
+func Score(s Stats, now time.Time) int {
+	if s.OptedOut {
+		return 0
+	}
+
+	var score float64
+	if s.LastReply != nil {
+		age := now.Sub(*s.LastReply)
+		score += recencyWeight * math.Max(0, 1-float64(age)/float64(Window))
+	}
+
+	score += frequencyWeight * math.Min(1, float64(s.Replies)/fullFrequency)
+
+	// Contacts we haven't messaged get half marks rather than being penalised
+	readRate := 0.5
+	if s.Delivered > 0 {
+		readRate = float64(s.Read) / float64(s.Delivered)
+	}
+	score += readWeight * readRate
+
+	return int(math.Round(score))
+}
+
+// Refresh recomputes the score of every contact and returns how many were scored
+func Refresh(db *gorm.DB, now time.Time) (int, error) {
+	since := now.Add(-Window)
+	scored := 0
+	lastID := uuid.Nil
+
+	for {
+		var batch []Stats
+		err := db.Raw(`
+			SELECT c.id AS contact_id,
+				MAX(m.created_at) FILTER (WHERE m.direction = 'incoming') AS last_reply,
+				COUNT(m.id) FILTER (WHERE m.direction = 'incoming') AS replies,
+				COUNT(m.id) FILTER (WHERE m.direction = 'outgoing' AND m.status IN ('delivered', 'read')) AS delivered,
+				COUNT(m.id) FILTER (WHERE m.direction = 'outgoing' AND m.status = 'read') AS read,
+				COALESCE(BOOL_OR(m.direction = 'incoming' AND UPPER(TRIM(m.content)) IN ?), false) AS opted_out
+			FROM contacts c
+			LEFT JOIN messages m ON m.contact_id = c.id AND m.created_at >= ? AND m.deleted_at IS NULL
+			WHERE c.deleted_at IS NULL AND c.id > ?
+			GROUP BY c.id
+			ORDER BY c.id
+			LIMIT ?`, OptOutKeywords, since, lastID, batchSize).
+			Scan(&batch).Error
+		if err != nil {
+			return scored, fmt.Errorf("failed to load engagement stats: %w", err)
+		}
+		if len(batch) == 0 {
+			return scored, nil
+		}
+
+		values := make([]string, len(batch))
+		args := []interface{}{now}
+		for i, s := range batch {
+			values[i] = "(?::uuid, ?::int)"
+			args = append(args, s.ContactID, Score(s, now))
+		}
+
+		err = db.Exec(`
+			UPDATE contacts SET engagement_score = v.score, engagement_scored_at = ?
+			FROM (VALUES `+strings.Join(values, ", ")+`) AS v(id, score)
+			WHERE contacts.id = v.id`, args...).Error
+		if err != nil {
+			return scored, fmt.Errorf("failed to save engagement scores: %w", err)
+		}
+
+		scored += len(batch)
+		lastID = batch[len(batch)-1].ContactID
+		if len(batch) < batchSize {
+			return scored, nil
+		}
+	}
+}
+
+// TopThreshold returns the lowest score in the top percent of the organization's
+// contacts, e.g. percent 20 for the top 20% most engaged
+func TopThreshold(db *gorm.DB, orgID uuid.UUID, percent int) (int, error) {
+	if percent <= 0 || percent > 100 {
+		return 0, fmt.Errorf("percent must be between 1 and 100")
+	}
+
+	var threshold float64
+	err := db.Raw(`
+		SELECT COALESCE(PERCENTILE_DISC(?) WITHIN GROUP (ORDER BY engagement_score), 0)
+		FROM contacts
+		WHERE organization_id = ? AND deleted_at IS NULL`,
+		1-float64(percent)/100, orgID).
+		Scan(&threshold).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute engagement threshold: %w", err)
+	}
+	return int(threshold), nil
+}
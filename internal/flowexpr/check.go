@@ -0,0 +1,196 @@
+package flowexpr
+
+import (
+	"fmt"
+	"time"
+)
+
+func check(n node, vars map[string]Type) (Type, error) {
+	switch n := n.(type) {
+	case literalNode:
+		return literalType(n.value), nil
+
+	case varNode:
+		t, ok := vars[n.name]
+		if !ok {
+			return "", fmt.Errorf("unknown variable %s", n.name)
+		}
+		return t, nil
+
+	case memberNode:
+		t, err := check(n.object, vars)
+		if err != nil {
+			return "", err
+		}
+		if t != Any {
+			return "", fmt.Errorf("a %s has no field %s", t, n.field)
+		}
+		return Any, nil
+
+	case indexNode:
+		t, err := check(n.object, vars)
+		if err != nil {
+			return "", err
+		}
+		if _, err := check(n.index, vars); err != nil {
+			return "", err
+		}
+		if t != Any {
+			return "", fmt.Errorf("a %s can't be indexed", t)
+		}
+		return Any, nil
+
+	case unaryNode:
+		t, err := check(n.operand, vars)
+		if err != nil {
+			return "", err
+		}
+		if n.op == "!" {
+			return Boolean, nil
+		}
+		if t != Any && t != Number {
+			return "", fmt.Errorf("can't negate a %s", t)
+		}
+		return Number, nil
+
+	case binaryNode:
+		left, err := check(n.left, vars)
+		if err != nil {
+			return "", err
+		}
+		right, err := check(n.right, vars)
+		if err != nil {
+			return "", err
+		}
+		return checkBinary(n.op, left, right)
+
+	case callNode:
+		return checkCall(n, vars)
+	}
+	return "", fmt.Errorf("unknown expression")
+}
+
+func literalType(value interface{}) Type {
+	switch value.(type) {
+	case string:
+		return String
+	case float64:
+		return Number
+	case bool:
+		return Boolean
+	case time.Time:
+		return Date
+	}
+	return Any
+}
+
+// checkBinary returns the type of a binary operation, rejecting operands it can never
+// work on. Operands of type Any are let through.
+func checkBinary(op string, left, right Type) (Type, error) {
+	mismatch := fmt.Errorf("can't use %s on %s and %s values", op, left, right)
+
+	switch op {
+	case "&&", "||", "==", "!=":
+		return Boolean, nil
+
+	case "<", "<=", ">", ">=":
+		if left == Boolean || right == Boolean ||
+			left == Date && right == Number || left == Number && right == Date {
+			return "", mismatch
+		}
+		return Boolean, nil
+
+	case "+":
+		switch {
+		case left == Boolean || right == Boolean || left == Date && right == Date:
+			return "", mismatch
+		case left == Date && right == Number || left == Number && right == Date:
+			return Date, nil
+		case left == Number && right == Number:
+			return Number, nil
+		case left == String && right == String ||
+			left == Date && right == String || left == String && right == Date:
+			return String, nil
+		}
+		return Any, nil
+
+	case "-":
+		switch {
+		case left == Boolean || right == Boolean || left == String || right == String ||
+			left == Number && right == Date:
+			return "", mismatch
+		case left == Date && right == Date:
+			return Number, nil
+		case left == Date && right == Number:
+			return Date, nil
+		case left == Any || right == Any:
+			return Any, nil
+		}
+		return Number, nil
+	}
+
+	// * / %
+	if left != Any && left != Number || right != Any && right != Number {
+		return "", mismatch
+	}
+	return Number, nil
+}
+
+func checkCall(n callNode, vars map[string]Type) (Type, error) {
+	fn := functions[n.name]
+	if len(n.args) < fn.min || !fn.variadic && len(n.args) > len(fn.params) {
+		want := fmt.Sprintf("%d", fn.min)
+		switch {
+		case fn.variadic:
+			want = fmt.Sprintf("at least %d", fn.min)
+		case fn.min != len(fn.params):
+			want = fmt.Sprintf("%d to %d", fn.min, len(fn.params))
+		}
+		return "", fmt.Errorf("wrong number of arguments to %s: want %s, got %d", n.name, want, len(n.args))
+	}
+
+	var argTypes []Type
+	for i, arg := range n.args {
+		t, err := check(arg, vars)
+		if err != nil {
+			return "", err
+		}
+		param := fn.params[min(i, len(fn.params)-1)]
+		if !accepts(param, t) {
+			return "", fmt.Errorf("argument %d of %s must be a %s, not a %s", i+1, n.name, param, t)
+		}
+		argTypes = append(argTypes, t)
+	}
+
+	if fn.result != "" {
+		return fn.result, nil
+	}
+	// if() and default() have the type of their possible results, when they agree.
+	// An if() without an else may be null.
+	result := argTypes[len(argTypes)-1]
+	start := 0
+	if n.name == "if" {
+		if len(argTypes) < 3 {
+			return Any, nil
+		}
+		start = 1
+	}
+	for _, t := range argTypes[start:] {
+		if t != result {
+			return Any, nil
+		}
+	}
+	return result, nil
+}
+
+// accepts reports whether a parameter takes an argument of type arg. Anything can be
+// read as text or as a condition, and dates are also accepted as text.
+func accepts(param, arg Type) bool {
+	switch {
+	case arg == Any || param == Any || param == String || param == Boolean || param == arg:
+		return true
+	case param == Date && arg == String:
+		return true
+	}
+	return false
+}
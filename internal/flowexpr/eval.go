@@ -0,0 +1,353 @@
+package flowexpr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+func eval(n node, env *Env) (interface{}, error) {
+	switch n := n.(type) {
+	case literalNode:
+		return n.value, nil
+
+	case varNode:
+		return variable(n.name, env)
+
+	case memberNode:
+		object, err := eval(n.object, env)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := object.(map[string]interface{}); ok {
+			return m[n.field], nil
+		}
+		return nil, nil
+
+	case indexNode:
+		object, err := eval(n.object, env)
+		if err != nil {
+			return nil, err
+		}
+		index, err := eval(n.index, env)
+		if err != nil {
+			return nil, err
+		}
+		switch v := normalize(object).(type) {
+		case []interface{}:
+			i, err := toNumber(index)
+			if err != nil {
+				return nil, fmt.Errorf("list index: %w", err)
+			}
+			if i < 0 || int(i) >= len(v) {
+				return nil, nil
+			}
+			return v[int(i)], nil
+		case map[string]interface{}:
+			return v[Format(index)], nil
+		}
+		return nil, nil
+
+	case unaryNode:
+		operand, err := eval(n.operand, env)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			return !Truthy(operand), nil
+		}
+		x, err := toNumber(operand)
+		if err != nil {
+			return nil, err
+		}
+		return -x, nil
+
+	case binaryNode:
+		return binary(n, env)
+
+	case callNode:
+		return call(n, env)
+	}
+	return nil, fmt.Errorf("unknown expression")
+}
+
+// variable reads a variable, converting it to its declared type
+func variable(name string, env *Env) (interface{}, error) {
+	value, ok := env.Data[name]
+	if !ok || value == nil {
+		return nil, nil
+	}
+
+	var err error
+	switch env.Types[name] {
+	case String:
+		value = Format(value)
+	case Number:
+		value, err = toNumber(value)
+	case Boolean:
+		value, err = toBoolean(value)
+	case Date:
+		value, err = toDate(value, env.Location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("variable %s: %w", name, err)
+	}
+	return value, nil
+}
+
+func binary(n binaryNode, env *Env) (interface{}, error) {
+	left, err := eval(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit
+	switch n.op {
+	case "&&":
+		if !Truthy(left) {
+			return false, nil
+		}
+		right, err := eval(n.right, env)
+		return err == nil && Truthy(right), err
+	case "||":
+		if Truthy(left) {
+			return true, nil
+		}
+		right, err := eval(n.right, env)
+		return err == nil && Truthy(right), err
+	}
+
+	right, err := eval(n.right, env)
+	if err != nil {
+		return nil, err
+	}
+	left, right = normalize(left), normalize(right)
+
+	switch n.op {
+	case "==":
+		return equal(left, right, env), nil
+	case "!=":
+		return !equal(left, right, env), nil
+	case "<", "<=", ">", ">=":
+		c, err := compare(left, right, env)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "+":
+		return add(left, right)
+	case "-":
+		return subtract(left, right)
+	}
+
+	x, err := toNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toNumber(right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "*":
+		return x * y, nil
+	case "/":
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return x / y, nil
+	case "%":
+		if y == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(x, y), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", n.op)
+}
+
+// equal compares two values, converting the right to the left's type when they
+// differ, so that a reply of "18" equals 18. Null equals the empty string.
+func equal(left, right interface{}, env *Env) bool {
+	if left == nil || right == nil {
+		return Format(left) == Format(right)
+	}
+	switch l := left.(type) {
+	case time.Time:
+		r, err := toDate(right, env.Location)
+		return err == nil && l.Equal(r)
+	case float64:
+		r, err := toNumber(right)
+		return err == nil && l == r
+	case bool:
+		r, err := toBoolean(right)
+		return err == nil && l == r
+	}
+	switch right.(type) {
+	case time.Time, float64, bool:
+		return equal(right, left, env)
+	}
+	return Format(left) == Format(right)
+}
+
+// compare orders two values as dates if either is one, then as numbers, then as text
+func compare(left, right interface{}, env *Env) (int, error) {
+	_, leftDate := left.(time.Time)
+	_, rightDate := right.(time.Time)
+	if leftDate || rightDate {
+		l, err := toDate(left, env.Location)
+		if err != nil {
+			return 0, err
+		}
+		r, err := toDate(right, env.Location)
+		if err != nil {
+			return 0, err
+		}
+		return l.Compare(r), nil
+	}
+
+	_, leftNumber := left.(float64)
+	_, rightNumber := right.(float64)
+	if leftNumber || rightNumber {
+		l, err := toNumber(left)
+		if err != nil {
+			return 0, err
+		}
+		r, err := toNumber(right)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case l < r:
+			return -1, nil
+		case l > r:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	l, lok := left.(string)
+	r, rok := right.(string)
+	if !lok || !rok {
+		return 0, fmt.Errorf("cannot compare %s and %s", typeOf(left), typeOf(right))
+	}
+	return strings.Compare(l, r), nil
+}
+
+// add sums numbers, moves a date by a number of days, and otherwise joins text. Text
+// that reads as a number is added to a number rather than joined to it.
+func add(left, right interface{}) (interface{}, error) {
+	if d, ok := left.(time.Time); ok {
+		if days, ok := right.(float64); ok {
+			return addDays(d, days), nil
+		}
+	}
+	if d, ok := right.(time.Time); ok {
+		if days, ok := left.(float64); ok {
+			return addDays(d, days), nil
+		}
+	}
+
+	_, leftNumber := left.(float64)
+	_, rightNumber := right.(float64)
+	if leftNumber || rightNumber {
+		x, errX := toNumber(left)
+		y, errY := toNumber(right)
+		if errX == nil && errY == nil {
+			return x + y, nil
+		}
+	}
+	return Format(left) + Format(right), nil
+}
+
+// subtract takes numbers from each other, days from a date, or gives the days
+// between two dates
+func subtract(left, right interface{}) (interface{}, error) {
+	if d, ok := left.(time.Time); ok {
+		switch r := right.(type) {
+		case time.Time:
+			return d.Sub(r).Hours() / 24, nil
+		case float64:
+			return addDays(d, -r), nil
+		}
+		return nil, fmt.Errorf("cannot subtract %s from a date", typeOf(right))
+	}
+
+	x, err := toNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toNumber(right)
+	if err != nil {
+		return nil, err
+	}
+	return x - y, nil
+}
+
+// addDays moves a date by whole calendar days, keeping its time of day
+func addDays(d time.Time, days float64) time.Time {
+	if days == math.Trunc(days) {
+		return d.AddDate(0, 0, int(days))
+	}
+	return d.Add(time.Duration(days * 24 * float64(time.Hour)))
+}
+
+func call(n callNode, env *Env) (interface{}, error) {
+	// if evaluates only the branch taken
+	if n.name == "if" {
+		cond, err := eval(n.args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(cond) {
+			return eval(n.args[1], env)
+		}
+		if len(n.args) > 2 {
+			return eval(n.args[2], env)
+		}
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := eval(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = normalize(v)
+	}
+	v, err := functions[n.name].call(env, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return v, nil
+}
+
+// typeOf names the type of a runtime value, for errors
+func typeOf(value interface{}) string {
+	switch normalize(value).(type) {
+	case nil:
+		return "null"
+	case string:
+		return "text"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case time.Time:
+		return "a date"
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
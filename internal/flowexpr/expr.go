@@ -0,0 +1,251 @@
+// Package flowexpr implements the expression language of chatbot flows. Expressions
+// compare, compute and format the flow's variables, and are used by condition steps,
+// variable assignments and {{= expr}} interpolation in messages. They are checked
+// against the flow's variables when it is enabled and evaluated against the
+// session's data at runtime.
+package flowexpr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of a flow variable or expression
+type Type string
+
+const (
+	Any     Type = "any"
+	String  Type = "string"
+	Number  Type = "number"
+	Boolean Type = "boolean"
+	Date    Type = "date"
+)
+
+// ParseType returns the Type named s
+func ParseType(s string) (Type, bool) {
+	switch t := Type(strings.ToLower(strings.TrimSpace(s))); t {
+	case Any, String, Number, Boolean, Date:
+		return t, true
+	}
+	return "", false
+}
+
+// dateLayout is how dates without a time of day are written and stored
+const dateLayout = "2006-01-02"
+
+// Expr is a parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Env is what an expression is evaluated against
+type Env struct {
+	Data     map[string]interface{} // Session data, by variable name
+	Types    map[string]Type        // Declared variable types; values are coerced to these when read
+	Now      time.Time              // Defaults to the current time
+	Location *time.Location         // For now() and today(); defaults to UTC
+}
+
+// Parse parses an expression
+func Parse(src string) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	root, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the expression's source
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Check type-checks the expression against the variables in scope and returns its
+// type. Variables of type Any, and fields of them, are checked only at runtime.
+func (e *Expr) Check(vars map[string]Type) (Type, error) {
+	return check(e.root, vars)
+}
+
+// Eval evaluates the expression. Variables missing from the data are null.
+func (e *Expr) Eval(env Env) (interface{}, error) {
+	if env.Now.IsZero() {
+		env.Now = time.Now()
+	}
+	if env.Location == nil {
+		env.Location = time.UTC
+	}
+	return eval(e.root, &env)
+}
+
+// EvalBool evaluates the expression and returns whether the result is truthy
+func (e *Expr) EvalBool(env Env) (bool, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	return Truthy(v), nil
+}
+
+// Coerce converts a value, usually a contact's reply, to t for storing in session
+// data. Dates are returned in their string form, which is how they are stored.
+func Coerce(value interface{}, t Type) (interface{}, error) {
+	switch t {
+	case String:
+		return Format(value), nil
+	case Number:
+		return toNumber(value)
+	case Boolean:
+		return toBoolean(value)
+	case Date:
+		d, err := toDate(value, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		return formatDate(d), nil
+	}
+	if d, ok := value.(time.Time); ok {
+		return formatDate(d), nil
+	}
+	return value, nil
+}
+
+// Format renders a value as text: whole numbers without decimals, dates as
+// YYYY-MM-DD or RFC 3339 when they have a time of day, and null as empty
+func Format(value interface{}) string {
+	switch v := normalize(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return formatDate(v)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// Truthy reports whether a value counts as true: false, 0, "", "false", "0", null
+// and empty lists and objects don't
+func Truthy(value interface{}) bool {
+	switch v := normalize(value).(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != "" && v != "false" && v != "0"
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+func formatDate(d time.Time) string {
+	if d.Hour() == 0 && d.Minute() == 0 && d.Second() == 0 && d.Nanosecond() == 0 {
+		return d.Format(dateLayout)
+	}
+	return d.Format(time.RFC3339)
+}
+
+// normalize converts the numeric types session data may hold to float64
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i := range v {
+			list[i] = v[i]
+		}
+		return list
+	}
+	return value
+}
+
+func toNumber(value interface{}) (float64, error) {
+	switch v := normalize(value).(type) {
+	case float64:
+		return v, nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return n, nil
+	case nil:
+		return 0, fmt.Errorf("null is not a number")
+	}
+	return 0, fmt.Errorf("%s is not a number", Format(value))
+}
+
+func toBoolean(value interface{}) (bool, error) {
+	switch v := normalize(value).(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "y", "1":
+			return true, nil
+		case "false", "no", "n", "0":
+			return false, nil
+		}
+		return false, fmt.Errorf("%q is not yes or no", v)
+	}
+	return false, fmt.Errorf("%s is not a boolean", Format(value))
+}
+
+// dateLayouts are the forms a date may be written in, most specific first
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02 15:04", dateLayout, "2/1/2006"}
+
+// toDate reads a date; one without a time zone is taken to be in loc
+func toDate(value interface{}, loc *time.Location) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range dateLayouts {
+			if d, err := time.ParseInLocation(layout, s, loc); err == nil {
+				return d, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%q is not a date", v)
+	case nil:
+		return time.Time{}, fmt.Errorf("null is not a date")
+	}
+	return time.Time{}, fmt.Errorf("%s is not a date", Format(value))
+}
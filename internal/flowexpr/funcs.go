@@ -0,0 +1,308 @@
+package flowexpr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// function is a built-in function
+type function struct {
+	params   []Type // The last repeats when variadic
+	min      int    // Required arguments
+	variadic bool
+	result   Type // Empty when the result has the type of the arguments after the first
+	call     func(env *Env, args []interface{}) (interface{}, error)
+}
+
+// functions are the built-in functions, by name
+var functions = map[string]function{
+	// Text
+	"len":         {params: []Type{Any}, min: 1, result: Number, call: fnLen},
+	"upper":       {params: []Type{String}, min: 1, result: String, call: stringFn(strings.ToUpper)},
+	"lower":       {params: []Type{String}, min: 1, result: String, call: stringFn(strings.ToLower)},
+	"trim":        {params: []Type{String}, min: 1, result: String, call: stringFn(strings.TrimSpace)},
+	"contains":    {params: []Type{Any, Any}, min: 2, result: Boolean, call: fnContains},
+	"starts_with": {params: []Type{String, String}, min: 2, result: Boolean, call: fnStartsWith},
+	"ends_with":   {params: []Type{String, String}, min: 2, result: Boolean, call: fnEndsWith},
+	"replace":     {params: []Type{String, String, String}, min: 3, result: String, call: fnReplace},
+	"substr":      {params: []Type{String, Number, Number}, min: 2, result: String, call: fnSubstr},
+	"string":      {params: []Type{Any}, min: 1, result: String, call: fnString},
+	"number":      {params: []Type{Any}, min: 1, result: Number, call: fnNumber},
+
+	// Numbers
+	"round": {params: []Type{Number, Number}, min: 1, result: Number, call: fnRound},
+	"floor": {params: []Type{Number}, min: 1, result: Number, call: numberFn(math.Floor)},
+	"ceil":  {params: []Type{Number}, min: 1, result: Number, call: numberFn(math.Ceil)},
+	"abs":   {params: []Type{Number}, min: 1, result: Number, call: numberFn(math.Abs)},
+	"min":   {params: []Type{Number}, min: 1, variadic: true, result: Number, call: extremeFn(-1)},
+	"max":   {params: []Type{Number}, min: 1, variadic: true, result: Number, call: extremeFn(1)},
+
+	// Dates
+	"now":          {result: Date, call: fnNow},
+	"today":        {result: Date, call: fnToday},
+	"date":         {params: []Type{Any}, min: 1, result: Date, call: fnDate},
+	"add_days":     {params: []Type{Date, Number}, min: 2, result: Date, call: fnAddDays},
+	"add_months":   {params: []Type{Date, Number}, min: 2, result: Date, call: fnAddMonths},
+	"days_between": {params: []Type{Date, Date}, min: 2, result: Number, call: fnDaysBetween},
+	"format_date":  {params: []Type{Date, String}, min: 2, result: String, call: fnFormatDate},
+	"weekday":      {params: []Type{Date}, min: 1, result: String, call: fnWeekday},
+
+	// Other
+	"if":       {params: []Type{Any, Any, Any}, min: 2}, // Evaluated by call
+	"default":  {params: []Type{Any, Any}, min: 2, call: fnDefault},
+	"is_empty": {params: []Type{Any}, min: 1, result: Boolean, call: fnIsEmpty},
+}
+
+func stringFn(fn func(string) string) func(*Env, []interface{}) (interface{}, error) {
+	return func(_ *Env, args []interface{}) (interface{}, error) {
+		return fn(Format(args[0])), nil
+	}
+}
+
+func numberFn(fn func(float64) float64) func(*Env, []interface{}) (interface{}, error) {
+	return func(_ *Env, args []interface{}) (interface{}, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(x), nil
+	}
+}
+
+// extremeFn returns the smallest of its arguments for sign -1, the largest for 1
+func extremeFn(sign float64) func(*Env, []interface{}) (interface{}, error) {
+	return func(_ *Env, args []interface{}) (interface{}, error) {
+		var result float64
+		for i, arg := range args {
+			x, err := toNumber(arg)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 || (x-result)*sign > 0 {
+				result = x
+			}
+		}
+		return result, nil
+	}
+}
+
+func fnLen(_ *Env, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case nil:
+		return 0.0, nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+	return float64(utf8.RuneCountInString(Format(args[0]))), nil
+}
+
+// fnContains reports whether a list has an item, or text has a substring, ignoring case
+func fnContains(env *Env, args []interface{}) (interface{}, error) {
+	if list, ok := args[0].([]interface{}); ok {
+		for _, item := range list {
+			if equal(normalize(item), args[1], env) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return strings.Contains(strings.ToLower(Format(args[0])), strings.ToLower(Format(args[1]))), nil
+}
+
+func fnStartsWith(_ *Env, args []interface{}) (interface{}, error) {
+	return strings.HasPrefix(strings.ToLower(Format(args[0])), strings.ToLower(Format(args[1]))), nil
+}
+
+func fnEndsWith(_ *Env, args []interface{}) (interface{}, error) {
+	return strings.HasSuffix(strings.ToLower(Format(args[0])), strings.ToLower(Format(args[1]))), nil
+}
+
+func fnReplace(_ *Env, args []interface{}) (interface{}, error) {
+	return strings.ReplaceAll(Format(args[0]), Format(args[1]), Format(args[2])), nil
+}
+
+// fnSubstr returns length characters of text from start, counted from zero, or the
+// rest of it without a length
+func fnSubstr(_ *Env, args []interface{}) (interface{}, error) {
+	runes := []rune(Format(args[0]))
+	start, err := toNumber(args[1])
+	if err != nil {
+		return nil, err
+	}
+	from := clamp(int(start), 0, len(runes))
+	to := len(runes)
+	if len(args) > 2 {
+		length, err := toNumber(args[2])
+		if err != nil {
+			return nil, err
+		}
+		to = clamp(from+int(length), from, len(runes))
+	}
+	return string(runes[from:to]), nil
+}
+
+func clamp(n, lo, hi int) int {
+	return max(lo, min(n, hi))
+}
+
+func fnString(_ *Env, args []interface{}) (interface{}, error) {
+	return Format(args[0]), nil
+}
+
+func fnNumber(_ *Env, args []interface{}) (interface{}, error) {
+	return toNumber(args[0])
+}
+
+// fnRound rounds to a number of decimal places, none by default
+func fnRound(_ *Env, args []interface{}) (interface{}, error) {
+	x, err := toNumber(args[0])
+	if err != nil {
+		return nil, err
+	}
+	places := 0.0
+	if len(args) > 1 {
+		if places, err = toNumber(args[1]); err != nil {
+			return nil, err
+		}
+	}
+	scale := math.Pow(10, math.Trunc(places))
+	return math.Round(x*scale) / scale, nil
+}
+
+func fnNow(env *Env, _ []interface{}) (interface{}, error) {
+	return env.Now.In(env.Location), nil
+}
+
+func fnToday(env *Env, _ []interface{}) (interface{}, error) {
+	return startOfDay(env.Now.In(env.Location)), nil
+}
+
+func fnDate(env *Env, args []interface{}) (interface{}, error) {
+	return toDate(args[0], env.Location)
+}
+
+func fnAddDays(env *Env, args []interface{}) (interface{}, error) {
+	d, err := toDate(args[0], env.Location)
+	if err != nil {
+		return nil, err
+	}
+	days, err := toNumber(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return addDays(d, days), nil
+}
+
+func fnAddMonths(env *Env, args []interface{}) (interface{}, error) {
+	d, err := toDate(args[0], env.Location)
+	if err != nil {
+		return nil, err
+	}
+	months, err := toNumber(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return d.AddDate(0, int(months), 0), nil
+}
+
+// fnDaysBetween counts the calendar days from the first date to the second
+func fnDaysBetween(env *Env, args []interface{}) (interface{}, error) {
+	from, err := toDate(args[0], env.Location)
+	if err != nil {
+		return nil, err
+	}
+	to, err := toDate(args[1], env.Location)
+	if err != nil {
+		return nil, err
+	}
+	from, to = startOfDay(from.In(env.Location)), startOfDay(to.In(env.Location))
+	return math.Round(to.Sub(from).Hours() / 24), nil
+}
+
+// dateTokens are the placeholders format_date replaces, longest first
+var dateTokens = []struct {
+	token  string
+	format func(time.Time) string
+}{
+	{"YYYY", func(d time.Time) string { return fmt.Sprintf("%04d", d.Year()) }},
+	{"YY", func(d time.Time) string { return fmt.Sprintf("%02d", d.Year()%100) }},
+	{"MMMM", func(d time.Time) string { return d.Month().String() }},
+	{"MMM", func(d time.Time) string { return d.Month().String()[:3] }},
+	{"MM", func(d time.Time) string { return fmt.Sprintf("%02d", int(d.Month())) }},
+	{"DD", func(d time.Time) string { return fmt.Sprintf("%02d", d.Day()) }},
+	{"HH", func(d time.Time) string { return fmt.Sprintf("%02d", d.Hour()) }},
+	{"mm", func(d time.Time) string { return fmt.Sprintf("%02d", d.Minute()) }},
+	{"ss", func(d time.Time) string { return fmt.Sprintf("%02d", d.Second()) }},
+}
+
+// fnFormatDate writes a date using the layout's YYYY, YY, MMMM, MMM, MM, DD, HH, mm
+// and ss placeholders. Anything else in the layout is kept as is.
+func fnFormatDate(env *Env, args []interface{}) (interface{}, error) {
+	d, err := toDate(args[0], env.Location)
+	if err != nil {
+		return nil, err
+	}
+	layout := Format(args[1])
+
+	var b strings.Builder
+	for i := 0; i < len(layout); {
+		matched := false
+		for _, t := range dateTokens {
+			if strings.HasPrefix(layout[i:], t.token) {
+				b.WriteString(t.format(d))
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(layout[i])
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+func fnWeekday(env *Env, args []interface{}) (interface{}, error) {
+	d, err := toDate(args[0], env.Location)
+	if err != nil {
+		return nil, err
+	}
+	return d.Weekday().String(), nil
+}
+
+// fnDefault returns the first argument unless it is empty, else the second
+func fnDefault(_ *Env, args []interface{}) (interface{}, error) {
+	if isEmpty(args[0]) {
+		return args[1], nil
+	}
+	return args[0], nil
+}
+
+func fnIsEmpty(_ *Env, args []interface{}) (interface{}, error) {
+	return isEmpty(args[0]), nil
+}
+
+// isEmpty reports whether a value is null, blank text, or an empty list or object
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func startOfDay(d time.Time) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location())
+}
@@ -0,0 +1,309 @@
+package flowexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind classifies a lexed token
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp // Operators and punctuation
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// lex splits src into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", src[start:i], start+1)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], num: n, pos: start})
+
+		case c == '\'' || c == '"':
+			start := i
+			var b strings.Builder
+			for i++; i < len(src) && src[i] != c; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				b.WriteByte(src[i])
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokString, text: b.String(), pos: start})
+
+		case c == '_' || unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i+1)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// node is a parsed expression
+type node interface{}
+
+type (
+	literalNode struct{ value interface{} }
+	varNode     struct{ name string }
+	memberNode  struct {
+		object node
+		field  string
+	}
+	indexNode struct{ object, index node }
+	unaryNode struct {
+		op      string
+		operand node
+	}
+	binaryNode struct {
+		op          string
+		left, right node
+	}
+	callNode struct {
+		name string
+		args []node
+	}
+)
+
+// Binary operator precedence, loosest first. Keyword forms are normalized to symbols.
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+// keywordOps are the word forms of the logical operators
+var keywordOps = map[string]string{"and": "&&", "or": "||", "not": "!"}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse builds the syntax tree of src
+func parse(src string) (node, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// operator returns the binary operator at the current token, if any
+func (p *parser) operator() (string, bool) {
+	t := p.peek()
+	switch t.kind {
+	case tokOp:
+		_, ok := precedence[t.text]
+		return t.text, ok
+	case tokIdent:
+		op, ok := keywordOps[strings.ToLower(t.text)]
+		return op, ok && op != "!"
+	}
+	return "", false
+}
+
+// expression parses operators binding tighter than minPrec by precedence climbing
+func (p *parser) expression(minPrec int) (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.operator()
+		if !ok || precedence[op] <= minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.expression(precedence[op])
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	t := p.peek()
+	if t.kind == tokOp && (t.text == "!" || t.text == "-") || t.kind == tokIdent && strings.EqualFold(t.text, "not") {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		op := t.text
+		if op != "-" {
+			op = "!"
+		}
+		return unaryNode{op: op, operand: operand}, nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (node, error) {
+	n, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokOp && t.text == ".":
+			p.next()
+			field := p.next()
+			if field.kind != tokIdent {
+				return nil, fmt.Errorf("expected a field name at position %d", field.pos+1)
+			}
+			n = memberNode{object: n, field: field.text}
+		case t.kind == tokOp && t.text == "[":
+			p.next()
+			index, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = indexNode{object: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return literalNode{value: t.num}, nil
+	case tokString:
+		return literalNode{value: t.text}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+		if next := p.peek(); next.kind == tokOp && next.text == "(" {
+			p.next()
+			return p.call(t)
+		}
+		return varNode{name: t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			n, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.pos+1)
+}
+
+// call parses the arguments of a function call after its opening parenthesis
+func (p *parser) call(name token) (node, error) {
+	fn := strings.ToLower(name.text)
+	if _, ok := functions[fn]; !ok {
+		return nil, fmt.Errorf("unknown function %s at position %d", name.text, name.pos+1)
+	}
+
+	var args []node
+	if t := p.peek(); t.kind == tokOp && t.text == ")" {
+		p.next()
+		return callNode{name: fn, args: args}, nil
+	}
+	for {
+		arg, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		t := p.next()
+		if t.kind == tokOp && t.text == ")" {
+			return callNode{name: fn, args: args}, nil
+		}
+		if t.kind != tokOp || t.text != "," {
+			return nil, fmt.Errorf("expected , or ) at position %d", t.pos+1)
+		}
+	}
+}
+
+func (p *parser) expect(op string) error {
+	t := p.next()
+	if t.kind != tokOp || t.text != op {
+		return fmt.Errorf("expected %s at position %d", op, t.pos+1)
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// AccountStatusProcessor periodically fetches each account's display name status,
+// business verification status and official business account (green tick) status
+// from the Graph API, alerting the organization when any of them changes
+type AccountStatusProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAccountStatusProcessor creates a new account status processor
+func NewAccountStatusProcessor(app *App, interval time.Duration) *AccountStatusProcessor {
+	return &AccountStatusProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the account status check loop
+func (p *AccountStatusProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Account status processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Account status processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Account status processor stopped")
+			return
+		case <-ticker.C:
+			p.checkAccounts(ctx)
+		}
+	}
+}
+
+// Stop stops the account status processor
+func (p *AccountStatusProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// checkAccounts checks every active account not checked within the interval
+func (p *AccountStatusProcessor) checkAccounts(ctx context.Context) {
+	due := time.Now().Add(-p.interval / 2)
+
+	var accounts []models.WhatsAppAccount
+	if err := p.app.DB.Where("status = ? AND (status_checked_at IS NULL OR status_checked_at < ?)", "active", due).
+		Find(&accounts).Error; err != nil {
+		p.app.Log.Error("Failed to load accounts for status check", "error", err)
+		return
+	}
+
+	for i := range accounts {
+		account := &accounts[i]
+
+		// Claim the check so other server instances skip this account
+		result := p.app.DB.Model(&models.WhatsAppAccount{}).
+			Where("id = ? AND (status_checked_at IS NULL OR status_checked_at < ?)", account.ID, due).
+			Update("status_checked_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		p.app.checkAccountStatus(ctx, account)
+		p.app.checkAPIVersionSunset(account)
+	}
+}
+
+// refreshAccountStatuses checks the status of every account on a WABA now, when Meta
+// notifies a name decision or account update rather than waiting for the next run
+func (a *App) refreshAccountStatuses(wabaID string) {
+	var accounts []models.WhatsAppAccount
+	if err := a.DB.Where("business_id = ? AND status = ?", wabaID, "active").Find(&accounts).Error; err != nil {
+		a.Log.Error("Failed to load accounts for status refresh", "error", err, "waba_id", wabaID)
+		return
+	}
+	for i := range accounts {
+		a.checkAccountStatus(context.Background(), &accounts[i])
+	}
+}
+
+// checkAccountStatus fetches the account's statuses from the Graph API and records
+// them on the account. Changes since the previous successful check are broadcast and
+// sent to the organization's webhooks.
+func (a *App) checkAccountStatus(ctx context.Context, account *models.WhatsAppAccount) {
+	waAccount := &whatsapp.Account{
+		PhoneID:     account.PhoneID,
+		BusinessID:  account.BusinessID,
+		APIVersion:  account.APIVersion,
+		AccessToken: account.AccessToken,
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status_checked_at": now}
+	account.StatusCheckedAt = &now
+
+	phone, err := a.WhatsApp.GetPhoneNumberStatus(ctx, waAccount)
+	if err != nil {
+		account.StatusError = "Failed to fetch phone number status: " + err.Error()
+		updates["status_error"] = account.StatusError
+		a.saveAccountStatus(account, updates)
+		return
+	}
+	verification, err := a.WhatsApp.GetBusinessVerificationStatus(ctx, waAccount)
+	if err != nil {
+		account.StatusError = "Failed to fetch business verification status: " + err.Error()
+		updates["status_error"] = account.StatusError
+		a.saveAccountStatus(account, updates)
+		return
+	}
+
+	// A previous check that never succeeded has nothing to compare against
+	checkedBefore := account.DisplayNameStatus != "" || account.BusinessVerificationStatus != "" ||
+		account.OfficialBusinessStatus != "" || account.VerifiedName != ""
+
+	var changes []AccountStatusChange
+	track := func(field string, current *string, value string) {
+		if *current != value {
+			changes = append(changes, AccountStatusChange{Field: field, From: *current, To: value})
+			*current = value
+			updates[field] = value
+		}
+	}
+	track("verified_name", &account.VerifiedName, phone.VerifiedName)
+	track("display_name_status", &account.DisplayNameStatus, phone.NameStatus)
+	track("business_verification_status", &account.BusinessVerificationStatus, verification)
+	track("official_business_status", &account.OfficialBusinessStatus, phone.OfficialBusinessAccount)
+	// Quality and tier are kept for capacity planning; they have their own alerts in Meta
+	account.QualityRating = phone.QualityRating
+	account.MessagingLimitTier = phone.MessagingLimitTier
+	updates["quality_rating"] = phone.QualityRating
+	updates["messaging_limit_tier"] = phone.MessagingLimitTier
+	account.StatusError = ""
+	updates["status_error"] = ""
+	a.saveAccountStatus(account, updates)
+
+	if !checkedBefore || len(changes) == 0 {
+		return
+	}
+
+	a.Log.Info("Account status changed",
+		"organization_id", account.OrganizationID,
+		"account", account.Name,
+		"changes", changes,
+	)
+	data := AccountStatusEventData{
+		WhatsAppAccount: account.Name,
+		PhoneID:         account.PhoneID,
+		BusinessID:      account.BusinessID,
+		Changes:         changes,
+	}
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(account.OrganizationID, websocket.WSMessage{
+			Type:    websocket.TypeAccountStatus,
+			Payload: a.withNotification(account.OrganizationID, notify.KeyAccountStatus, data),
+		})
+	}
+	a.DispatchWebhook(account.OrganizationID, EventAccountStatus, data)
+}
+
+func (a *App) saveAccountStatus(account *models.WhatsAppAccount, updates map[string]interface{}) {
+	if err := a.DB.Model(&models.WhatsAppAccount{}).Where("id = ?", account.ID).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to save account status", "error", err, "account", account.Name)
+	}
+}
+
+// AccountHealthResponse summarizes the periodic checks of an account
+type AccountHealthResponse struct {
+	AccountID                  uuid.UUID `json:"account_id"`
+	Name                       string    `json:"name"`
+	Healthy                    bool      `json:"healthy"`
+	Problems                   []string  `json:"problems"`
+	WebhookStatus              string    `json:"webhook_status"`
+	WebhookError               string    `json:"webhook_error,omitempty"`
+	WebhookCheckedAt           string    `json:"webhook_checked_at,omitempty"`
+	VerifiedName               string    `json:"verified_name"`
+	DisplayNameStatus          string    `json:"display_name_status"`
+	BusinessVerificationStatus string    `json:"business_verification_status"`
+	OfficialBusinessStatus     string    `json:"official_business_status"`
+	QualityRating              string    `json:"quality_rating"`
+	MessagingLimitTier         string    `json:"messaging_limit_tier"`
+	StatusError                string    `json:"status_error,omitempty"`
+	StatusCheckedAt            string    `json:"status_checked_at,omitempty"`
+	APIVersion                 string    `json:"api_version"`
+	APIVersionStatus           string    `json:"api_version_status"` // current, deprecating, sunset, unknown
+	APIVersionSunset           string    `json:"api_version_sunset,omitempty"`
+	MissedReceipts             int64     `json:"missed_receipts"`
+	ReconciledAt               string    `json:"reconciled_at,omitempty"`
+}
+
+// GetAccountHealth returns the results of an account's webhook subscription and
+// status checks. With refresh=true both checks are run first.
+func (a *App) GetAccountHealth(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid account ID", nil, "")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	if string(r.RequestCtx.QueryArgs().Peek("refresh")) == "true" {
+		a.checkWebhookSubscription(r.RequestCtx, &account)
+		a.checkAccountStatus(r.RequestCtx, &account)
+	}
+
+	return r.SendEnvelope(accountHealth(account))
+}
+
+func accountHealth(acc models.WhatsAppAccount) AccountHealthResponse {
+	resp := AccountHealthResponse{
+		AccountID:                  acc.ID,
+		Name:                       acc.Name,
+		Problems:                   []string{},
+		WebhookStatus:              acc.WebhookStatus,
+		WebhookError:               acc.WebhookError,
+		VerifiedName:               acc.VerifiedName,
+		DisplayNameStatus:          acc.DisplayNameStatus,
+		BusinessVerificationStatus: acc.BusinessVerificationStatus,
+		OfficialBusinessStatus:     acc.OfficialBusinessStatus,
+		QualityRating:              acc.QualityRating,
+		MessagingLimitTier:         acc.MessagingLimitTier,
+		StatusError:                acc.StatusError,
+		APIVersion:                 acc.APIVersion,
+		MissedReceipts:             acc.MissedReceipts,
+	}
+	var sunset *time.Time
+	resp.APIVersionStatus, sunset = whatsapp.APIVersionStatus(acc.APIVersion, time.Now())
+	if sunset != nil {
+		resp.APIVersionSunset = sunset.Format("2006-01-02")
+	}
+	if acc.WebhookCheckedAt != nil {
+		resp.WebhookCheckedAt = acc.WebhookCheckedAt.Format("2006-01-02T15:04:05Z")
+	}
+	if acc.StatusCheckedAt != nil {
+		resp.StatusCheckedAt = acc.StatusCheckedAt.Format("2006-01-02T15:04:05Z")
+	}
+	if acc.ReconciledAt != nil {
+		resp.ReconciledAt = acc.ReconciledAt.Format("2006-01-02T15:04:05Z")
+	}
+
+	if acc.WebhookStatus == models.WebhookSubscriptionFailed {
+		resp.Problems = append(resp.Problems, "Webhook subscription is broken")
+	}
+	switch resp.APIVersionStatus {
+	case whatsapp.APIVersionSunset:
+		resp.Problems = append(resp.Problems, "Graph API "+acc.APIVersion+" is past its sunset")
+	case whatsapp.APIVersionDeprecating:
+		resp.Problems = append(resp.Problems, "Graph API "+acc.APIVersion+" reaches its sunset on "+resp.APIVersionSunset)
+	}
+	if acc.MissedReceipts >= missedReceiptsAlertMin {
+		resp.Problems = append(resp.Problems, "Delivery receipts are being missed")
+	}
+	if acc.StatusError != "" {
+		resp.Problems = append(resp.Problems, "Account status couldn't be checked")
+	}
+	switch acc.DisplayNameStatus {
+	case "DECLINED", "EXPIRED", "NON_EXISTS":
+		resp.Problems = append(resp.Problems, "Display name is "+acc.DisplayNameStatus)
+	}
+	switch acc.BusinessVerificationStatus {
+	case "rejected", "failed", "revoked":
+		resp.Problems = append(resp.Problems, "Business verification is "+acc.BusinessVerificationStatus)
+	}
+	if acc.QualityRating == "RED" {
+		resp.Problems = append(resp.Problems, "Quality rating is low")
+	}
+	resp.Healthy = len(resp.Problems) == 0
+	return resp
+}
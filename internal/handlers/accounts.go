@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 )
@@ -21,31 +24,47 @@ type AccountRequest struct {
 	PhoneID            string `json:"phone_id" validate:"required"`
 	BusinessID         string `json:"business_id" validate:"required"`
 	AccessToken        string `json:"access_token" validate:"required"`
+	AppSecret          string `json:"app_secret"`
 	WebhookVerifyToken string `json:"webhook_verify_token"`
 	APIVersion         string `json:"api_version"`
 	IsDefaultIncoming  bool   `json:"is_default_incoming"`
 	IsDefaultOutgoing  bool   `json:"is_default_outgoing"`
 	AutoReadReceipt    bool   `json:"auto_read_receipt"`
+
+	CampaignConcurrency *int `json:"campaign_concurrency"` // 0 uses the server default
+	MessagesPerSecond   *int `json:"messages_per_second"`  // 0 uses the server default
 }
 
 // AccountResponse represents the response for an account (without sensitive data)
 type AccountResponse struct {
-	ID                 uuid.UUID `json:"id"`
-	Name               string    `json:"name"`
-	AppID              string    `json:"app_id"`
-	PhoneID            string    `json:"phone_id"`
-	BusinessID         string    `json:"business_id"`
-	WebhookVerifyToken string    `json:"webhook_verify_token"`
-	APIVersion         string    `json:"api_version"`
-	IsDefaultIncoming  bool      `json:"is_default_incoming"`
-	IsDefaultOutgoing  bool      `json:"is_default_outgoing"`
-	AutoReadReceipt    bool      `json:"auto_read_receipt"`
-	Status             string    `json:"status"`
-	HasAccessToken     bool      `json:"has_access_token"`
-	PhoneNumber        string    `json:"phone_number,omitempty"`
-	DisplayName        string    `json:"display_name,omitempty"`
-	CreatedAt          string    `json:"created_at"`
-	UpdatedAt          string    `json:"updated_at"`
+	ID                         uuid.UUID `json:"id"`
+	Name                       string    `json:"name"`
+	AppID                      string    `json:"app_id"`
+	PhoneID                    string    `json:"phone_id"`
+	BusinessID                 string    `json:"business_id"`
+	WebhookVerifyToken         string    `json:"webhook_verify_token"`
+	APIVersion                 string    `json:"api_version"`
+	IsDefaultIncoming          bool      `json:"is_default_incoming"`
+	IsDefaultOutgoing          bool      `json:"is_default_outgoing"`
+	AutoReadReceipt            bool      `json:"auto_read_receipt"`
+	CampaignConcurrency        int       `json:"campaign_concurrency"`
+	MessagesPerSecond          int       `json:"messages_per_second"`
+	Status                     string    `json:"status"`
+	HasAccessToken             bool      `json:"has_access_token"`
+	HasAppSecret               bool      `json:"has_app_secret"`
+	PhoneNumber                string    `json:"phone_number,omitempty"`
+	DisplayName                string    `json:"display_name,omitempty"`
+	WebhookStatus              string    `json:"webhook_status"`
+	WebhookError               string    `json:"webhook_error,omitempty"`
+	WebhookCheckedAt           string    `json:"webhook_checked_at,omitempty"`
+	VerifiedName               string    `json:"verified_name,omitempty"`
+	DisplayNameStatus          string    `json:"display_name_status,omitempty"`
+	BusinessVerificationStatus string    `json:"business_verification_status,omitempty"`
+	OfficialBusinessStatus     string    `json:"official_business_status,omitempty"`
+	StatusCheckedAt            string    `json:"status_checked_at,omitempty"`
+	DebugLogUntil              string    `json:"debug_log_until,omitempty"`
+	CreatedAt                  string    `json:"created_at"`
+	UpdatedAt                  string    `json:"updated_at"`
 }
 
 // ListAccounts returns all WhatsApp accounts for the organization
@@ -96,9 +115,13 @@ func (a *App) CreateAccount(r *fastglue.Request) error {
 	}
 
 	// Set default API version
-	apiVersion := req.APIVersion
-	if apiVersion == "" {
-		apiVersion = "v21.0"
+	apiVersion := a.Config.WhatsApp.APIVersion
+	if req.APIVersion != "" {
+		apiVersion = req.APIVersion
+	}
+	apiVersion, err = checkAPIVersion(apiVersion)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
 	}
 
 	account := models.WhatsAppAccount{
@@ -108,6 +131,7 @@ func (a *App) CreateAccount(r *fastglue.Request) error {
 		PhoneID:            req.PhoneID,
 		BusinessID:         req.BusinessID,
 		AccessToken:        req.AccessToken, // TODO: encrypt before storing
+		AppSecret:          req.AppSecret,
 		WebhookVerifyToken: webhookVerifyToken,
 		APIVersion:         apiVersion,
 		IsDefaultIncoming:  req.IsDefaultIncoming,
@@ -115,6 +139,18 @@ func (a *App) CreateAccount(r *fastglue.Request) error {
 		AutoReadReceipt:    req.AutoReadReceipt,
 		Status:             "active",
 	}
+	if req.CampaignConcurrency != nil {
+		if !models.ValidCampaignConcurrency(*req.CampaignConcurrency) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("campaign_concurrency must be between 0 and %d", models.MaxCampaignConcurrency), nil, "")
+		}
+		account.CampaignConcurrency = *req.CampaignConcurrency
+	}
+	if req.MessagesPerSecond != nil {
+		if *req.MessagesPerSecond < 0 || *req.MessagesPerSecond > models.MaxMessagesPerSecond {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("messages_per_second must be between 0 and %d", models.MaxMessagesPerSecond), nil, "")
+		}
+		account.MessagesPerSecond = *req.MessagesPerSecond
+	}
 
 	// If this is set as default, unset other defaults
 	if req.IsDefaultIncoming {
@@ -199,13 +235,32 @@ func (a *App) UpdateAccount(r *fastglue.Request) error {
 	if req.AccessToken != "" {
 		account.AccessToken = req.AccessToken // TODO: encrypt
 	}
+	if req.AppSecret != "" {
+		account.AppSecret = req.AppSecret
+	}
 	if req.WebhookVerifyToken != "" {
 		account.WebhookVerifyToken = req.WebhookVerifyToken
 	}
 	if req.APIVersion != "" {
-		account.APIVersion = req.APIVersion
+		apiVersion, err := checkAPIVersion(req.APIVersion)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		account.APIVersion = apiVersion
 	}
 	account.AutoReadReceipt = req.AutoReadReceipt
+	if req.CampaignConcurrency != nil {
+		if !models.ValidCampaignConcurrency(*req.CampaignConcurrency) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("campaign_concurrency must be between 0 and %d", models.MaxCampaignConcurrency), nil, "")
+		}
+		account.CampaignConcurrency = *req.CampaignConcurrency
+	}
+	if req.MessagesPerSecond != nil {
+		if *req.MessagesPerSecond < 0 || *req.MessagesPerSecond > models.MaxMessagesPerSecond {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("messages_per_second must be between 0 and %d", models.MaxMessagesPerSecond), nil, "")
+		}
+		account.MessagesPerSecond = *req.MessagesPerSecond
+	}
 
 	// Handle default flags
 	if req.IsDefaultIncoming && !account.IsDefaultIncoming {
@@ -282,13 +337,13 @@ func (a *App) TestAccountConnection(r *fastglue.Request) error {
 
 	// Test the connection by fetching phone number details from Meta API
 	url := fmt.Sprintf("https://graph.facebook.com/%s/%s?fields=display_phone_number,verified_name,quality_rating,messaging_limit_tier",
-		account.APIVersion, account.PhoneID)
+		whatsapp.ResolveAPIVersion(account.APIVersion), account.PhoneID)
 
-	req, _ := http.NewRequest("GET", url, nil)
+	ctx := whatsapp.WithAccount(context.Background(), &whatsapp.Account{PhoneID: account.PhoneID, BusinessID: account.BusinessID})
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := a.WhatsApp.HTTPClient.Do(req)
 	if err != nil {
 		return r.SendEnvelope(map[string]interface{}{
 			"success": false,
@@ -324,22 +379,41 @@ func (a *App) TestAccountConnection(r *fastglue.Request) error {
 // Helper functions
 
 func accountToResponse(acc models.WhatsAppAccount) AccountResponse {
-	return AccountResponse{
-		ID:                 acc.ID,
-		Name:               acc.Name,
-		AppID:              acc.AppID,
-		PhoneID:            acc.PhoneID,
-		BusinessID:         acc.BusinessID,
-		WebhookVerifyToken: acc.WebhookVerifyToken,
-		APIVersion:         acc.APIVersion,
-		IsDefaultIncoming:  acc.IsDefaultIncoming,
-		IsDefaultOutgoing:  acc.IsDefaultOutgoing,
-		AutoReadReceipt:    acc.AutoReadReceipt,
-		Status:             acc.Status,
-		HasAccessToken:     acc.AccessToken != "",
-		CreatedAt:          acc.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		UpdatedAt:          acc.UpdatedAt.Format("2006-01-02T15:04:05Z"),
-	}
+	resp := AccountResponse{
+		ID:                         acc.ID,
+		Name:                       acc.Name,
+		AppID:                      acc.AppID,
+		PhoneID:                    acc.PhoneID,
+		BusinessID:                 acc.BusinessID,
+		WebhookVerifyToken:         acc.WebhookVerifyToken,
+		APIVersion:                 acc.APIVersion,
+		IsDefaultIncoming:          acc.IsDefaultIncoming,
+		IsDefaultOutgoing:          acc.IsDefaultOutgoing,
+		AutoReadReceipt:            acc.AutoReadReceipt,
+		CampaignConcurrency:        acc.CampaignConcurrency,
+		MessagesPerSecond:          acc.MessagesPerSecond,
+		Status:                     acc.Status,
+		HasAccessToken:             acc.AccessToken != "",
+		HasAppSecret:               acc.AppSecret != "",
+		WebhookStatus:              acc.WebhookStatus,
+		WebhookError:               acc.WebhookError,
+		VerifiedName:               acc.VerifiedName,
+		DisplayNameStatus:          acc.DisplayNameStatus,
+		BusinessVerificationStatus: acc.BusinessVerificationStatus,
+		OfficialBusinessStatus:     acc.OfficialBusinessStatus,
+		CreatedAt:                  acc.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:                  acc.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if acc.WebhookCheckedAt != nil {
+		resp.WebhookCheckedAt = acc.WebhookCheckedAt.Format("2006-01-02T15:04:05Z")
+	}
+	if acc.StatusCheckedAt != nil {
+		resp.StatusCheckedAt = acc.StatusCheckedAt.Format("2006-01-02T15:04:05Z")
+	}
+	if acc.DebugLogUntil != nil && acc.DebugLogUntil.After(time.Now()) {
+		resp.DebugLogUntil = acc.DebugLogUntil.Format("2006-01-02T15:04:05Z")
+	}
+	return resp
 }
 
 func generateVerifyToken() string {
@@ -1133,7 +1133,7 @@ func (a *App) broadcastTransferAssigned(transfer *models.AgentTransfer) {
 }
 
 // createTransferToQueue creates an unassigned agent transfer that goes to the queue
-func (a *App) createTransferToQueue(account *models.WhatsAppAccount, contact *models.Contact, source string) {
+func (a *App) createTransferToQueue(account *models.WhatsAppAccount, contact *models.Contact, notes string, source string) {
 	// Check for existing active transfer
 	var existingCount int64
 	a.DB.Model(&models.AgentTransfer{}).
@@ -1158,6 +1158,7 @@ func (a *App) createTransferToQueue(account *models.WhatsAppAccount, contact *mo
 		Status:          "active",
 		Source:          source,
 		AgentID:         nil, // Unassigned - goes to queue
+		Notes:           notes,
 		TransferredAt:   time.Now(),
 	}
 
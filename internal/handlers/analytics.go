@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"strings"
 	"time"
 
 	"github.com/shridarpatil/whatomate/internal/models"
@@ -18,6 +19,10 @@ type DashboardStats struct {
 	ChatbotChange   float64 `json:"chatbot_change"`
 	CampaignsSent   int64   `json:"campaigns_sent"`
 	CampaignsChange float64 `json:"campaigns_change"`
+
+	// Traffic with the organization's test numbers, left out of the counts above
+	TestMessages int64 `json:"test_messages"`
+	TestContacts int64 `json:"test_contacts"`
 }
 
 // RecentMessageResponse represents a recent message in the dashboard
@@ -28,6 +33,7 @@ type RecentMessageResponse struct {
 	Direction   string `json:"direction"`
 	CreatedAt   string `json:"created_at"`
 	Status      string `json:"status"`
+	IsTest      bool   `json:"is_test"` // Exchanged with one of the organization's test numbers
 }
 
 // GetDashboardStats returns dashboard statistics for the organization
@@ -67,28 +73,42 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 	previousPeriodStart := periodStart.Add(-periodDuration - time.Nanosecond)
 	previousPeriodEnd := periodStart.Add(-time.Nanosecond)
 
-	// Get message counts for the selected period
-	var previousPeriodMessages, currentPeriodMessages int64
+	// Get message counts for the selected period, leaving out test numbers
+	var previousPeriodMessages, currentPeriodMessages, testMessages int64
 	a.DB.Model(&models.Message{}).
 		Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, previousPeriodStart, previousPeriodEnd).
+		Where("NOT " + messageToTestNumber).
 		Count(&previousPeriodMessages)
 
 	a.DB.Model(&models.Message{}).
 		Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, periodStart, periodEnd).
+		Where("NOT " + messageToTestNumber).
 		Count(&currentPeriodMessages)
 
+	a.DB.Model(&models.Message{}).
+		Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, periodStart, periodEnd).
+		Where(messageToTestNumber).
+		Count(&testMessages)
+
 	messagesChange := calculatePercentageChange(previousPeriodMessages, currentPeriodMessages)
 
-	// Get contact counts for the selected period
-	var previousPeriodContacts, currentPeriodContacts int64
+	// Get contact counts for the selected period, leaving out test numbers
+	var previousPeriodContacts, currentPeriodContacts, testContacts int64
 	a.DB.Model(&models.Contact{}).
 		Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, previousPeriodStart, previousPeriodEnd).
+		Where("NOT " + contactIsTestNumber).
 		Count(&previousPeriodContacts)
 
 	a.DB.Model(&models.Contact{}).
 		Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, periodStart, periodEnd).
+		Where("NOT " + contactIsTestNumber).
 		Count(&currentPeriodContacts)
 
+	a.DB.Model(&models.Contact{}).
+		Where("organization_id = ? AND created_at >= ? AND created_at <= ?", orgID, periodStart, periodEnd).
+		Where(contactIsTestNumber).
+		Count(&testContacts)
+
 	contactsChange := calculatePercentageChange(previousPeriodContacts, currentPeriodContacts)
 
 	// Get chatbot session counts for the selected period
@@ -124,6 +144,8 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 		ChatbotChange:   sessionsChange,
 		CampaignsSent:   currentPeriodCampaigns,
 		CampaignsChange: campaignsChange,
+		TestMessages:    testMessages,
+		TestContacts:    testContacts,
 	}
 
 	// Get recent messages
@@ -134,6 +156,7 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 		Limit(5).
 		Find(&messages)
 
+	testNumbers := a.testNumberSet(orgID)
 	recentMessages := make([]RecentMessageResponse, len(messages))
 	for i, msg := range messages {
 		contactName := "Unknown"
@@ -145,7 +168,7 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 			}
 		}
 
-		content := msg.Content
+		content := a.redactMessage(orgID, msg.Content)
 		if content == "" && msg.MessageType != "text" {
 			content = "[" + msg.MessageType + "]"
 		}
@@ -157,6 +180,7 @@ func (a *App) GetDashboardStats(r *fastglue.Request) error {
 			Direction:   msg.Direction,
 			CreatedAt:   msg.CreatedAt.Format(time.RFC3339),
 			Status:      msg.Status,
+			IsTest:      msg.Contact != nil && testNumbers[strings.TrimPrefix(msg.Contact.PhoneNumber, "+")],
 		}
 	}
 
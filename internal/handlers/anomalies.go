@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// ListMetricAnomalies returns detected messaging anomalies, newest first.
+// Query params: account, unacknowledged=true, limit (default 50)
+func (a *App) ListMetricAnomalies(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if account := string(r.RequestCtx.QueryArgs().Peek("account")); account != "" {
+		query = query.Where("whats_app_account = ?", account)
+	}
+	if string(r.RequestCtx.QueryArgs().Peek("unacknowledged")) == "true" {
+		query = query.Where("acknowledged_at IS NULL")
+	}
+
+	limit := r.RequestCtx.QueryArgs().GetUintOrZero("limit")
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var anomalies []models.MetricAnomaly
+	if err := query.Order("date DESC, created_at DESC").Limit(limit).Find(&anomalies).Error; err != nil {
+		a.Log.Error("Failed to list anomalies", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list anomalies", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"anomalies": anomalies,
+	})
+}
+
+// AcknowledgeMetricAnomaly marks an anomaly as seen
+func (a *App) AcknowledgeMetricAnomaly(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid ID", nil, "")
+	}
+
+	var anomaly models.MetricAnomaly
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&anomaly).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Anomaly not found", nil, "")
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"acknowledged_at": now}
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		updates["acknowledged_by_id"] = userID
+	}
+	if err := a.DB.Model(&anomaly).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to acknowledge anomaly", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to acknowledge anomaly", nil, "")
+	}
+
+	a.DB.First(&anomaly, anomaly.ID)
+	return r.SendEnvelope(anomaly)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/anomaly"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"gorm.io/gorm/clause"
+)
+
+// AnomalyProcessor periodically compares account messaging rates with their baseline
+// and alerts the organization when they deviate sharply
+type AnomalyProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAnomalyProcessor creates a new anomaly processor
+func NewAnomalyProcessor(app *App, interval time.Duration) *AnomalyProcessor {
+	return &AnomalyProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the anomaly detection loop
+func (p *AnomalyProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Anomaly processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Anomaly processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Anomaly processor stopped")
+			return
+		case <-ticker.C:
+			p.detectAnomalies()
+		}
+	}
+}
+
+// Stop stops the anomaly processor
+func (p *AnomalyProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// detectAnomalies checks today's failure rate, which settles within minutes, and
+// yesterday's delivery and read rates, which need receipts to arrive first
+func (p *AnomalyProcessor) detectAnomalies() {
+	today := time.Now().UTC()
+
+	checks := []struct {
+		day     time.Time
+		metrics []string
+	}{
+		{today, []string{anomaly.MetricFailureRate}},
+		{today.AddDate(0, 0, -1), []string{anomaly.MetricDeliveryRate, anomaly.MetricReadRate}},
+	}
+
+	for _, check := range checks {
+		anomalies, err := anomaly.Detect(p.app.DB, check.day, check.metrics...)
+		if err != nil {
+			p.app.Log.Error("Failed to detect anomalies", "error", err)
+			continue
+		}
+		for i := range anomalies {
+			p.recordAnomaly(&anomalies[i])
+		}
+	}
+}
+
+// recordAnomaly saves an anomaly and notifies the organization the first time it is seen.
+// The unique index on account, date and metric keeps server instances from alerting twice.
+func (p *AnomalyProcessor) recordAnomaly(a *models.MetricAnomaly) {
+	result := p.app.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	if result.Error != nil {
+		p.app.Log.Error("Failed to save anomaly", "error", result.Error, "account", a.WhatsAppAccount, "metric", a.Metric)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	p.app.Log.Warn("Messaging anomaly detected",
+		"organization_id", a.OrganizationID,
+		"account", a.WhatsAppAccount,
+		"metric", a.Metric,
+		"value", a.Value,
+		"baseline", a.Baseline,
+	)
+
+	p.app.WSHub.BroadcastToOrg(a.OrganizationID, websocket.WSMessage{
+		Type:    websocket.TypeMetricAnomaly,
+		Payload: p.app.withNotification(a.OrganizationID, notify.KeyMetricAnomaly, anomalyEventData(a)),
+	})
+	p.app.DispatchWebhook(a.OrganizationID, EventAccountAnomaly, anomalyEventData(a))
+}
+
+func anomalyEventData(a *models.MetricAnomaly) AnomalyEventData {
+	return AnomalyEventData{
+		AnomalyID:       a.ID.String(),
+		WhatsAppAccount: a.WhatsAppAccount,
+		Date:            a.Date.Format("2006-01-02"),
+		Metric:          a.Metric,
+		Direction:       a.Direction,
+		Value:           a.Value,
+		Baseline:        a.Baseline,
+		Volume:          a.Volume,
+		Message:         a.Message,
+	}
+}
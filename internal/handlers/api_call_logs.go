@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/apilog"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// defaultAPICallLogRetention is how long Graph API debug logs are kept
+	defaultAPICallLogRetention = 72 * time.Hour
+
+	// defaultDebugLogHours is how long debug logging stays on when turned on
+	// without a duration; maxDebugLogHours bounds it, as the logs hold message content
+	defaultDebugLogHours = 24
+	maxDebugLogHours     = 72
+)
+
+// DebugLoggingRequest turns an account's Graph API debug logging on or off
+type DebugLoggingRequest struct {
+	Enabled bool `json:"enabled"`
+	Hours   int  `json:"hours"` // How long to keep it on, default 24 and at most 72
+}
+
+// APICallLogProcessor periodically deletes Graph API debug logs past their
+// retention, and the oldest of accounts over apilog.MaxEntries
+type APICallLogProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAPICallLogProcessor creates a new API call log processor
+func NewAPICallLogProcessor(app *App, interval time.Duration) *APICallLogProcessor {
+	return &APICallLogProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the API call log pruning loop
+func (p *APICallLogProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("API call log processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("API call log processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("API call log processor stopped")
+			return
+		case <-ticker.C:
+			deleted, err := apilog.Prune(p.app.DB, p.app.apiCallLogRetention())
+			if err != nil {
+				p.app.Log.Error("Failed to prune API call logs", "error", err)
+			} else if deleted > 0 {
+				p.app.Log.Info("Pruned API call logs", "count", deleted)
+			}
+		}
+	}
+}
+
+// Stop stops the API call log processor
+func (p *APICallLogProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// SetAccountDebugLogging turns recording of the account's Graph API calls on for a
+// number of hours, or off
+func (a *App) SetAccountDebugLogging(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid account ID", nil, "")
+	}
+
+	var req DebugLoggingRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Hours == 0 {
+		req.Hours = defaultDebugLogHours
+	}
+	if req.Hours < 0 || req.Hours > maxDebugLogHours {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "hours must be between 1 and 72", nil, "")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var until *time.Time
+	if req.Enabled {
+		t := time.Now().Add(time.Duration(req.Hours) * time.Hour)
+		until = &t
+	}
+	if err := a.DB.Model(&account).Update("debug_log_until", until).Error; err != nil {
+		a.Log.Error("Failed to update debug logging", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update debug logging", nil, "")
+	}
+	if a.APILog != nil {
+		a.APILog.Invalidate()
+	}
+
+	a.Log.Info("Account API debug logging changed", "account", account.Name, "enabled", req.Enabled, "until", until)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"enabled":         req.Enabled,
+		"debug_log_until": until,
+	})
+}
+
+// ListAPICallLogs returns the account's recorded Graph API calls, newest first.
+// ?failed=true only returns calls that failed.
+func (a *App) ListAPICallLogs(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid account ID", nil, "")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ? AND whats_app_account = ?", orgID, account.Name)
+	if string(r.RequestCtx.QueryArgs().Peek("failed")) == "true" {
+		query = query.Where("error <> ''")
+	}
+
+	limit := r.RequestCtx.QueryArgs().GetUintOrZero("limit")
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	var calls []models.APICallLog
+	if err := query.Order("created_at DESC").Limit(limit).Find(&calls).Error; err != nil {
+		a.Log.Error("Failed to list API call logs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list API call logs", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"calls":           calls,
+		"debug_log_until": account.DebugLogUntil,
+		"retention_hours": int(a.apiCallLogRetention().Hours()),
+	})
+}
+
+// ClearAPICallLogs deletes the account's recorded Graph API calls
+func (a *App) ClearAPICallLogs(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid account ID", nil, "")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	result := a.DB.Unscoped().Where("organization_id = ? AND whats_app_account = ?", orgID, account.Name).Delete(&models.APICallLog{})
+	if result.Error != nil {
+		a.Log.Error("Failed to clear API call logs", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to clear API call logs", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"deleted": result.RowsAffected,
+	})
+}
+
+// apiCallLogRetention is how long Graph API debug logs are kept
+func (a *App) apiCallLogRetention() time.Duration {
+	if a.Config != nil && a.Config.WhatsApp.DebugLogRetentionHours > 0 {
+		return time.Duration(a.Config.WhatsApp.DebugLogRetentionHours) * time.Hour
+	}
+	return defaultAPICallLogRetention
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// APIVersionUpgrade is an account moved, or that would be moved, to a newer Graph
+// API version
+type APIVersionUpgrade struct {
+	AccountID      uuid.UUID `json:"account_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Name           string    `json:"name"`
+	From           string    `json:"from"`
+	To             string    `json:"to"`
+}
+
+// UpgradeAPIVersionRequest is the request body for upgrading the organization's accounts
+type UpgradeAPIVersionRequest struct {
+	Version string `json:"version"` // Defaults to the latest known version
+	DryRun  bool   `json:"dry_run"`
+}
+
+// ListAPIVersions returns the known Graph API versions with their sunsets, and the
+// version and sunset status of each of the organization's accounts
+func (a *App) ListAPIVersions(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var accounts []models.WhatsAppAccount
+	if err := a.DB.Where("organization_id = ?", orgID).Order("name").Find(&accounts).Error; err != nil {
+		a.Log.Error("Failed to list accounts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list accounts", nil, "")
+	}
+
+	now := time.Now()
+	items := make([]map[string]interface{}, len(accounts))
+	for i, acc := range accounts {
+		status, sunset := whatsapp.APIVersionStatus(acc.APIVersion, now)
+		items[i] = map[string]interface{}{
+			"account_id":   acc.ID,
+			"name":         acc.Name,
+			"api_version":  acc.APIVersion,
+			"status":       status,
+			"sunset":       sunset,
+			"used_version": whatsapp.ResolveAPIVersion(acc.APIVersion),
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"versions": whatsapp.APIVersions(),
+		"latest":   whatsapp.LatestAPIVersion(),
+		"accounts": items,
+	})
+}
+
+// UpgradeAccountsAPIVersion moves every organization account on an older Graph API
+// version to the requested one. With dry_run the accounts are only listed.
+func (a *App) UpgradeAccountsAPIVersion(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req UpgradeAPIVersionRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Version == "" {
+		req.Version = whatsapp.LatestAPIVersion()
+	}
+	version, err := checkAPIVersion(req.Version)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	upgrades, err := UpgradeAPIVersions(a.DB, orgID, version, req.DryRun)
+	if err != nil {
+		a.Log.Error("Failed to upgrade API versions", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to upgrade API versions", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"upgrades": upgrades,
+		"dry_run":  req.DryRun,
+	})
+}
+
+// UpgradeAPIVersions moves accounts on a Graph API version older than version to
+// it, and returns them. uuid.Nil upgrades the accounts of every organization. The
+// warning about the old version is cleared, so the new one is warned about in turn.
+func UpgradeAPIVersions(db *gorm.DB, orgID uuid.UUID, version string, dryRun bool) ([]APIVersionUpgrade, error) {
+	version, err := checkAPIVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	query := db.Model(&models.WhatsAppAccount{})
+	if orgID != uuid.Nil {
+		query = query.Where("organization_id = ?", orgID)
+	}
+	var accounts []models.WhatsAppAccount
+	if err := query.Order("organization_id, name").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	upgrades := []APIVersionUpgrade{}
+	for _, acc := range accounts {
+		// Unparseable versions are fixed too, as no request on them works
+		current, err := whatsapp.NormalizeAPIVersion(acc.APIVersion)
+		if err == nil && whatsapp.CompareAPIVersions(current, version) >= 0 {
+			continue
+		}
+		upgrades = append(upgrades, APIVersionUpgrade{
+			AccountID:      acc.ID,
+			OrganizationID: acc.OrganizationID,
+			Name:           acc.Name,
+			From:           acc.APIVersion,
+			To:             version,
+		})
+		if dryRun {
+			continue
+		}
+		// Updated through the model so the account cache is invalidated
+		acc.APIVersion = version
+		acc.APIVersionWarning = ""
+		if err := db.Model(&acc).Select("api_version", "api_version_warning").Updates(&acc).Error; err != nil {
+			return upgrades[:len(upgrades)-1], fmt.Errorf("failed to upgrade %s: %w", acc.Name, err)
+		}
+	}
+	return upgrades, nil
+}
+
+// checkAPIVersion normalizes a Graph API version for an account, refusing versions
+// Meta no longer serves
+func checkAPIVersion(v string) (string, error) {
+	normalized, err := whatsapp.NormalizeAPIVersion(v)
+	if err != nil {
+		return "", err
+	}
+	if status, _ := whatsapp.APIVersionStatus(normalized, time.Now()); status == whatsapp.APIVersionSunset {
+		return "", fmt.Errorf("Graph API %s is past its sunset; use %s or newer", normalized, whatsapp.ResolveAPIVersion(normalized))
+	}
+	return normalized, nil
+}
+
+// checkAPIVersionSunset warns the organization when the account's Graph API version
+// comes within whatsapp.DeprecationWarning of its sunset, and again once it passes.
+// The stage warned about is kept on the account so each is only sent once.
+func (a *App) checkAPIVersionSunset(account *models.WhatsAppAccount) {
+	status, sunset := whatsapp.APIVersionStatus(account.APIVersion, time.Now())
+	warning := ""
+	if status == whatsapp.APIVersionDeprecating || status == whatsapp.APIVersionSunset {
+		warning = status
+	}
+	if warning == account.APIVersionWarning {
+		return
+	}
+
+	// Claim the change so other server instances don't warn twice
+	result := a.DB.Model(&models.WhatsAppAccount{}).
+		Where("id = ? AND api_version_warning = ?", account.ID, account.APIVersionWarning).
+		Update("api_version_warning", warning)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	account.APIVersionWarning = warning
+	if warning == "" {
+		return
+	}
+
+	data := APIVersionEventData{
+		WhatsAppAccount: account.Name,
+		APIVersion:      account.APIVersion,
+		Status:          status,
+		UsedVersion:     whatsapp.ResolveAPIVersion(account.APIVersion),
+		LatestVersion:   whatsapp.LatestAPIVersion(),
+	}
+	if sunset != nil {
+		data.Sunset = sunset.Format("2006-01-02")
+	}
+	a.Log.Warn("Account Graph API version nears sunset",
+		"organization_id", account.OrganizationID,
+		"account", account.Name,
+		"api_version", account.APIVersion,
+		"status", status,
+		"sunset", data.Sunset,
+	)
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(account.OrganizationID, websocket.WSMessage{
+			Type:    websocket.TypeAPIVersion,
+			Payload: a.withNotification(account.OrganizationID, notify.KeyAPIVersion, data),
+		})
+	}
+	a.DispatchWebhook(account.OrganizationID, EventAccountAPIVersion, data)
+}
@@ -3,9 +3,11 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/middleware"
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
@@ -13,9 +15,23 @@ import (
 )
 
 // APIKeyRequest represents the request body for creating an API key
+// Scopes use "[METHOD ]/api/path" where "*" matches one path segment and a
+// trailing "/**" matches everything below, e.g. "GET /api/contacts/**"
 type APIKeyRequest struct {
-	Name      string  `json:"name"`
-	ExpiresAt *string `json:"expires_at,omitempty"`
+	Name       string   `json:"name"`
+	ExpiresAt  *string  `json:"expires_at,omitempty"`
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
+	Scopes     []string `json:"scopes,omitempty"`
+}
+
+// APIKeyUpdateRequest represents the request body for updating an API key
+// Omitted fields are left unchanged; an empty expires_at clears the expiry
+type APIKeyUpdateRequest struct {
+	Name       *string   `json:"name,omitempty"`
+	ExpiresAt  *string   `json:"expires_at,omitempty"`
+	AllowedIPs *[]string `json:"allowed_ips,omitempty"`
+	Scopes     *[]string `json:"scopes,omitempty"`
+	IsActive   *bool     `json:"is_active,omitempty"`
 }
 
 // APIKeyResponse represents an API key in list responses
@@ -24,19 +40,41 @@ type APIKeyResponse struct {
 	Name       string     `json:"name"`
 	KeyPrefix  string     `json:"key_prefix"`
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
 	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 	IsActive   bool       `json:"is_active"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	Scopes     []string   `json:"scopes"`
 	CreatedAt  string     `json:"created_at"`
 }
 
 // APIKeyCreateResponse includes the full key (only shown once)
 type APIKeyCreateResponse struct {
-	ID        uuid.UUID  `json:"id"`
-	Name      string     `json:"name"`
-	Key       string     `json:"key"` // Full key, only returned on create
-	KeyPrefix string     `json:"key_prefix"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt string     `json:"created_at"`
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key"` // Full key, only returned on create
+	KeyPrefix  string     `json:"key_prefix"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  string     `json:"created_at"`
+}
+
+// APIKeyDailyUsage represents request totals for an API key on one day
+type APIKeyDailyUsage struct {
+	Date     string `json:"date"`
+	Requests int64  `json:"requests"`
+	Denied   int64  `json:"denied"`
+}
+
+// APIKeyEndpointUsage represents request totals for an API key on one endpoint
+type APIKeyEndpointUsage struct {
+	Method     string    `json:"method"`
+	Endpoint   string    `json:"endpoint"`
+	Requests   int64     `json:"requests"`
+	Denied     int64     `json:"denied"`
+	LastIP     string    `json:"last_ip"`
+	LastUsedAt time.Time `json:"last_used_at"`
 }
 
 // generateAPIKey generates a random API key with whm_ prefix
@@ -70,15 +108,7 @@ func (a *App) ListAPIKeys(r *fastglue.Request) error {
 	// Convert to response format
 	response := make([]APIKeyResponse, len(apiKeys))
 	for i, key := range apiKeys {
-		response[i] = APIKeyResponse{
-			ID:         key.ID,
-			Name:       key.Name,
-			KeyPrefix:  key.KeyPrefix,
-			LastUsedAt: key.LastUsedAt,
-			ExpiresAt:  key.ExpiresAt,
-			IsActive:   key.IsActive,
-			CreatedAt:  key.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		}
+		response[i] = apiKeyToResponse(key)
 	}
 
 	return r.SendEnvelope(response)
@@ -119,6 +149,13 @@ func (a *App) CreateAPIKey(r *fastglue.Request) error {
 		expiresAt = &t
 	}
 
+	if err := middleware.ValidateIPAllowlist(req.AllowedIPs); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	if err := middleware.ValidateScopes(req.Scopes); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
 	// Generate the API key
 	fullKey, err := generateAPIKey()
 	if err != nil {
@@ -144,6 +181,14 @@ func (a *App) CreateAPIKey(r *fastglue.Request) error {
 		KeyHash:        string(hashedKey),
 		ExpiresAt:      expiresAt,
 		IsActive:       true,
+		AllowedIPs:     models.StringArray(req.AllowedIPs),
+		Scopes:         models.StringArray(req.Scopes),
+	}
+	if apiKey.AllowedIPs == nil {
+		apiKey.AllowedIPs = models.StringArray{}
+	}
+	if apiKey.Scopes == nil {
+		apiKey.Scopes = models.StringArray{}
 	}
 
 	if err := a.DB.Create(&apiKey).Error; err != nil {
@@ -153,15 +198,175 @@ func (a *App) CreateAPIKey(r *fastglue.Request) error {
 
 	// Return full key only on creation
 	return r.SendEnvelope(APIKeyCreateResponse{
-		ID:        apiKey.ID,
-		Name:      apiKey.Name,
-		Key:       fullKey, // This is the only time the full key is returned
-		KeyPrefix: apiKey.KeyPrefix,
-		ExpiresAt: apiKey.ExpiresAt,
-		CreatedAt: apiKey.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Key:        fullKey, // This is the only time the full key is returned
+		KeyPrefix:  apiKey.KeyPrefix,
+		ExpiresAt:  apiKey.ExpiresAt,
+		AllowedIPs: apiKey.AllowedIPs,
+		Scopes:     apiKey.Scopes,
+		CreatedAt:  apiKey.CreatedAt.Format("2006-01-02T15:04:05Z"),
 	})
 }
 
+// UpdateAPIKey updates an API key's name, expiry, IP allowlist, scopes or active state (admin only)
+func (a *App) UpdateAPIKey(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	// Check if user is admin
+	role, _ := r.RequestCtx.UserValue("role").(string)
+	if role != "admin" {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Admin access required", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid API key ID", nil, "")
+	}
+
+	var apiKey models.APIKey
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&apiKey).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "API key not found", nil, "")
+	}
+
+	var req APIKeyUpdateRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
+		}
+		apiKey.Name = *req.Name
+	}
+	if req.ExpiresAt != nil {
+		if *req.ExpiresAt == "" {
+			apiKey.ExpiresAt = nil
+		} else {
+			t, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+			if err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid expires_at format. Use RFC3339 format", nil, "")
+			}
+			apiKey.ExpiresAt = &t
+		}
+	}
+	if req.AllowedIPs != nil {
+		if err := middleware.ValidateIPAllowlist(*req.AllowedIPs); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		apiKey.AllowedIPs = models.StringArray(*req.AllowedIPs)
+	}
+	if req.Scopes != nil {
+		if err := middleware.ValidateScopes(*req.Scopes); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		apiKey.Scopes = models.StringArray(*req.Scopes)
+	}
+	if req.IsActive != nil {
+		apiKey.IsActive = *req.IsActive
+	}
+
+	if err := a.DB.Save(&apiKey).Error; err != nil {
+		a.Log.Error("Failed to update API key", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update API key", nil, "")
+	}
+
+	return r.SendEnvelope(apiKeyToResponse(apiKey))
+}
+
+// GetAPIKeyUsage returns daily and per-endpoint usage for an API key (admin only)
+// Query params: days (default 30, max 90)
+func (a *App) GetAPIKeyUsage(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	// Check if user is admin
+	role, _ := r.RequestCtx.UserValue("role").(string)
+	if role != "admin" {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Admin access required", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid API key ID", nil, "")
+	}
+
+	var apiKey models.APIKey
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&apiKey).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "API key not found", nil, "")
+	}
+
+	days, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("days")))
+	if days < 1 || days > 90 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days+1).Format("2006-01-02")
+
+	var daily []APIKeyDailyUsage
+	if err := a.DB.Model(&models.APIKeyUsage{}).
+		Select("to_char(date, 'YYYY-MM-DD') AS date, SUM(request_count) AS requests, SUM(denied_count) AS denied").
+		Where("api_key_id = ? AND date >= ?", apiKey.ID, since).
+		Group("date").Order("date ASC").
+		Scan(&daily).Error; err != nil {
+		a.Log.Error("Failed to load API key usage", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load API key usage", nil, "")
+	}
+
+	var endpoints []APIKeyEndpointUsage
+	if err := a.DB.Model(&models.APIKeyUsage{}).
+		Select("method, endpoint, SUM(request_count) AS requests, SUM(denied_count) AS denied, MAX(last_used_at) AS last_used_at, (ARRAY_AGG(last_ip ORDER BY last_used_at DESC))[1] AS last_ip").
+		Where("api_key_id = ? AND date >= ?", apiKey.ID, since).
+		Group("method, endpoint").Order("requests DESC").
+		Scan(&endpoints).Error; err != nil {
+		a.Log.Error("Failed to load API key usage", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load API key usage", nil, "")
+	}
+
+	var totalRequests, totalDenied int64
+	for _, d := range daily {
+		totalRequests += d.Requests
+		totalDenied += d.Denied
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"api_key":        apiKeyToResponse(apiKey),
+		"days":           days,
+		"total_requests": totalRequests,
+		"total_denied":   totalDenied,
+		"daily":          daily,
+		"endpoints":      endpoints,
+	})
+}
+
+func apiKeyToResponse(key models.APIKey) APIKeyResponse {
+	allowedIPs := []string(key.AllowedIPs)
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+	scopes := []string(key.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		KeyPrefix:  key.KeyPrefix,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		ExpiresAt:  key.ExpiresAt,
+		IsActive:   key.IsActive,
+		AllowedIPs: allowedIPs,
+		Scopes:     scopes,
+		CreatedAt:  key.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
+
 // DeleteAPIKey revokes an API key (admin only)
 func (a *App) DeleteAPIKey(r *fastglue.Request) error {
 	orgID, err := getOrganizationID(r)
@@ -6,7 +6,12 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/apilog"
 	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/shridarpatil/whatomate/internal/egress"
+	"github.com/shridarpatil/whatomate/internal/offboarding"
+	"github.com/shridarpatil/whatomate/internal/pricing"
+	"github.com/shridarpatil/whatomate/internal/push"
 	"github.com/shridarpatil/whatomate/internal/queue"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
@@ -17,14 +22,21 @@ import (
 
 // App holds all dependencies for handlers
 type App struct {
-	Config            *config.Config
-	DB                *gorm.DB
-	Redis             *redis.Client
-	Log               logf.Logger
-	WhatsApp          *whatsapp.Client
-	WSHub             *websocket.Hub
-	Queue             queue.Queue
-	CampaignSubCancel context.CancelFunc
+	Config               *config.Config
+	DB                   *gorm.DB
+	Redis                *redis.Client
+	Log                  logf.Logger
+	WhatsApp             *whatsapp.Client
+	Egress               *egress.Resolver
+	Offboarding          *offboarding.Guard
+	APILog               *apilog.Recorder
+	Push                 *push.Service
+	Pricing              *pricing.RateCard
+	WSHub                *websocket.Hub
+	Queue                queue.Queue
+	CampaignSubCancel    context.CancelFunc
+	CampaignEventsCancel context.CancelFunc
+	JobUpdatesCancel     context.CancelFunc
 }
 
 // getOrgIDFromContext extracts organization ID from request context (set by auth middleware)
@@ -100,6 +112,11 @@ func (a *App) StartCampaignStatsSubscriber() error {
 				"delivered_count": update.DeliveredCount,
 				"read_count":      update.ReadCount,
 				"failed_count":    update.FailedCount,
+				"cancelled_count": update.CancelledCount,
+
+				"total_recipients":        update.TotalRecipients,
+				"processed_per_second":    update.ProcessedPerSecond,
+				"estimated_completion_at": update.EstimatedCompletionAt,
 			},
 		})
 	})
@@ -111,41 +111,57 @@ func (a *App) Register(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
 	}
 
-	// Create organization
 	org := models.Organization{
 		Name: req.OrganizationName,
 		Slug: generateSlug(req.OrganizationName),
 	}
+	user := models.User{
+		Email:        req.Email,
+		PasswordHash: string(hashedPassword),
+		FullName:     req.FullName,
+		Role:         "admin", // First user is admin
+		IsActive:     true,
+	}
+	if err := a.createOrganizationWithAdmin(&org, &user); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
+	}
+
+	a.Log.Info("Registration completed", "user_id", user.ID, "org_id", org.ID)
+
+	// Generate tokens
+	accessToken, _ := a.generateAccessToken(&user)
+	refreshToken, _ := a.generateRefreshToken(&user)
 
-	// Start transaction
+	return r.SendEnvelope(AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    a.Config.JWT.AccessExpiryMins * 60,
+		User:         user,
+	})
+}
+
+// createOrganizationWithAdmin creates an organization, its first admin user and its
+// default chatbot settings in one transaction
+func (a *App) createOrganizationWithAdmin(org *models.Organization, user *models.User) error {
 	tx := a.DB.Begin()
 	if tx.Error != nil {
 		a.Log.Error("Failed to begin transaction", "error", tx.Error)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
+		return tx.Error
 	}
 
-	if err := tx.Create(&org).Error; err != nil {
+	if err := tx.Create(org).Error; err != nil {
 		tx.Rollback()
-		a.Log.Error("Failed to create organization", "error", err, "org_name", req.OrganizationName)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
+		a.Log.Error("Failed to create organization", "error", err, "org_name", org.Name)
+		return err
 	}
 
 	a.Log.Info("Created organization", "org_id", org.ID, "org_name", org.Name)
 
-	// Create user
-	user := models.User{
-		OrganizationID: org.ID,
-		Email:          req.Email,
-		PasswordHash:   string(hashedPassword),
-		FullName:       req.FullName,
-		Role:           "admin", // First user is admin
-		IsActive:       true,
-	}
-
-	if err := tx.Create(&user).Error; err != nil {
+	user.OrganizationID = org.ID
+	if err := tx.Create(user).Error; err != nil {
 		tx.Rollback()
-		a.Log.Error("Failed to create user", "error", err, "email", req.Email, "org_id", org.ID)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
+		a.Log.Error("Failed to create user", "error", err, "email", user.Email, "org_id", org.ID)
+		return err
 	}
 
 	a.Log.Info("Created user", "user_id", user.ID, "email", user.Email)
@@ -160,26 +176,14 @@ func (a *App) Register(r *fastglue.Request) error {
 	if err := tx.Create(&chatbotSettings).Error; err != nil {
 		tx.Rollback()
 		a.Log.Error("Failed to create chatbot settings", "error", err, "org_id", org.ID)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
+		return err
 	}
 
 	if err := tx.Commit().Error; err != nil {
 		a.Log.Error("Failed to commit transaction", "error", err)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create account", nil, "")
+		return err
 	}
-
-	a.Log.Info("Registration completed", "user_id", user.ID, "org_id", org.ID)
-
-	// Generate tokens
-	accessToken, _ := a.generateAccessToken(&user)
-	refreshToken, _ := a.generateRefreshToken(&user)
-
-	return r.SendEnvelope(AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    a.Config.JWT.AccessExpiryMins * 60,
-		User:         user,
-	})
+	return nil
 }
 
 // RefreshToken refreshes access token using refresh token
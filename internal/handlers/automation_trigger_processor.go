@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// automationSegmentLockKey keeps server instances from evaluating segments twice
+	automationSegmentLockKey = "automation:segment_lock"
+
+	// automationSegmentBatch caps the contacts entering or exiting a trigger's segment
+	// handled per cycle; the rest are picked up on the next one
+	automationSegmentBatch = 500
+
+	// customerServiceWindow is how long after a contact's last message free-form
+	// messages, such as a flow's, can be sent to them
+	customerServiceWindow = 24 * time.Hour
+
+	// defaultFlowSessionTimeoutMins is used for flows started by a trigger when the
+	// account has no chatbot settings
+	defaultFlowSessionTimeoutMins = 30
+)
+
+// AutomationEvent is something that happened to a contact that automation triggers
+// can act on
+type AutomationEvent struct {
+	Type       string
+	Tag        string    // tag_added
+	Attribute  string    // attribute_changed
+	Value      string    // attribute_changed: the new value
+	CampaignID uuid.UUID // campaign_delivered
+	URL        string    // link_clicked
+}
+
+// matches reports whether the trigger's conditions hold for the event
+func (e *AutomationEvent) matches(trigger *models.AutomationTrigger) bool {
+	if trigger.Event != e.Type {
+		return false
+	}
+	switch e.Type {
+	case models.AutomationEventTagAdded:
+		return trigger.Tag == "" || trigger.Tag == e.Tag
+	case models.AutomationEventAttributeChanged:
+		return (trigger.AttributeKey == "" || trigger.AttributeKey == e.Attribute) &&
+			(trigger.AttributeValue == "" || trigger.AttributeValue == e.Value)
+	case models.AutomationEventCampaignDelivered:
+		return trigger.CampaignID == nil || *trigger.CampaignID == e.CampaignID
+	case models.AutomationEventLinkClicked:
+		return trigger.URLContains == "" || strings.Contains(strings.ToLower(e.URL), strings.ToLower(trigger.URLContains))
+	}
+	return true
+}
+
+// fireAutomationTriggers runs every enabled trigger of the contact's account that
+// matches event
+func (a *App) fireAutomationTriggers(contact *models.Contact, event AutomationEvent) {
+	var triggers []models.AutomationTrigger
+	if err := a.DB.Where("organization_id = ? AND whats_app_account = ? AND event = ? AND is_enabled = true",
+		contact.OrganizationID, contact.WhatsAppAccount, event.Type).Find(&triggers).Error; err != nil {
+		a.Log.Error("Failed to load automation triggers", "error", err, "event", event.Type)
+		return
+	}
+
+	for i := range triggers {
+		if event.matches(&triggers[i]) {
+			a.runAutomationTrigger(&triggers[i], contact, event.Type)
+		}
+	}
+}
+
+// fireCampaignDeliveredTriggers runs the campaign_delivered triggers for a delivered
+// campaign message
+func (a *App) fireCampaignDeliveredTriggers(contactID uuid.UUID, campaignID string) {
+	id, err := uuid.Parse(campaignID)
+	if err != nil {
+		return
+	}
+	var contact models.Contact
+	if err := a.DB.Where("id = ?", contactID).First(&contact).Error; err != nil {
+		return
+	}
+	a.fireAutomationTriggers(&contact, AutomationEvent{Type: models.AutomationEventCampaignDelivered, CampaignID: id})
+}
+
+// runAutomationTrigger runs the trigger's action for contact unless a guardrail
+// stops it, and records the run
+func (a *App) runAutomationTrigger(trigger *models.AutomationTrigger, contact *models.Contact, event string) {
+	run := models.AutomationTriggerRun{
+		OrganizationID: trigger.OrganizationID,
+		TriggerID:      trigger.ID,
+		ContactID:      contact.ID,
+		Event:          event,
+		Status:         models.AutomationRunSucceeded,
+	}
+
+	if reason := a.automationGuardrail(trigger, contact); reason != "" {
+		run.Status = models.AutomationRunSkipped
+		run.Reason = reason
+	} else {
+		var err error
+		switch trigger.Action {
+		case models.AutomationActionSendTemplate:
+			run.Reason, err = a.automationSendTemplate(trigger, contact)
+		case models.AutomationActionStartFlow:
+			run.Reason, err = a.automationStartFlow(trigger, contact)
+		default:
+			err = fmt.Errorf("unknown action %q", trigger.Action)
+		}
+		if err != nil {
+			run.Status = models.AutomationRunFailed
+			run.Reason = err.Error()
+		} else if run.Reason != "" {
+			run.Status = models.AutomationRunSkipped
+		}
+	}
+
+	if err := a.DB.Create(&run).Error; err != nil {
+		a.Log.Error("Failed to record automation trigger run", "error", err, "trigger_id", trigger.ID)
+	}
+	a.Log.Info("Automation trigger run", "trigger_id", trigger.ID, "contact_id", contact.ID, "event", event, "status", run.Status, "reason", run.Reason)
+}
+
+// automationGuardrail returns why the trigger must not run for contact now, or ""
+func (a *App) automationGuardrail(trigger *models.AutomationTrigger, contact *models.Contact) string {
+	var optedIn int64
+	a.DB.Model(&models.Contact{}).Where("id = ?", contact.ID).Where(contactNotOptedOut, engagement.OptOutKeywords).Count(&optedIn)
+	if optedIn == 0 {
+		return "Contact opted out"
+	}
+
+	now := time.Now()
+	succeeded := func() *gorm.DB {
+		return a.DB.Model(&models.AutomationTriggerRun{}).
+			Where("trigger_id = ? AND status = ?", trigger.ID, models.AutomationRunSucceeded)
+	}
+
+	if trigger.CooldownHours > 0 {
+		var recent int64
+		succeeded().Where("contact_id = ? AND created_at >= ?", contact.ID, now.Add(-time.Duration(trigger.CooldownHours)*time.Hour)).Count(&recent)
+		if recent > 0 {
+			return fmt.Sprintf("Ran for this contact in the last %d hours", trigger.CooldownHours)
+		}
+	}
+	if trigger.MaxPerContact > 0 {
+		var total int64
+		succeeded().Where("contact_id = ?", contact.ID).Count(&total)
+		if total >= int64(trigger.MaxPerContact) {
+			return fmt.Sprintf("Reached the limit of %d runs per contact", trigger.MaxPerContact)
+		}
+	}
+	if trigger.DailyCap > 0 {
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		var today int64
+		succeeded().Where("created_at >= ?", startOfDay).Count(&today)
+		if today >= int64(trigger.DailyCap) {
+			return fmt.Sprintf("Reached the daily cap of %d runs", trigger.DailyCap)
+		}
+	}
+	return ""
+}
+
+// automationSendTemplate sends the trigger's template to contact and records the
+// message. It returns why the template wasn't sent when a send-time check stops it.
+func (a *App) automationSendTemplate(trigger *models.AutomationTrigger, contact *models.Contact) (string, error) {
+	if blackout := a.campaignBlackout(trigger.OrganizationID); blackout != nil {
+		return fmt.Sprintf("Blackout date (%s)", blackout.Name), nil
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("organization_id = ? AND name = ?", trigger.OrganizationID, trigger.WhatsAppAccount).First(&account).Error; err != nil {
+		return "", fmt.Errorf("WhatsApp account not found")
+	}
+	var template models.Template
+	if trigger.TemplateID == nil || a.DB.Where("id = ? AND organization_id = ?", *trigger.TemplateID, trigger.OrganizationID).First(&template).Error != nil {
+		return "", fmt.Errorf("template not found")
+	}
+	if !strings.EqualFold(template.Status, "APPROVED") {
+		return "", fmt.Errorf("template %s is not approved", template.Name)
+	}
+
+	if !a.allowBotMessage(trigger.OrganizationID, account.Name, contact, BotMessageSourceAutomation) {
+		return "Contact reached the automated message limit", nil
+	}
+
+	recipient := contactRecipient(contact, trigger.TemplateParams)
+	recipient.TemplateParams = a.fillContactTemplateParams(nil, &template, contact, recipient.TemplateParams)
+	waMessageID, err := a.sendTemplateMessage(&account, &template, &recipient)
+	if err != nil {
+		return "", err
+	}
+
+	message := models.Message{
+		OrganizationID:    trigger.OrganizationID,
+		WhatsAppAccount:   account.Name,
+		ContactID:         contact.ID,
+		WhatsAppMessageID: waMessageID,
+		Direction:         "outgoing",
+		MessageType:       "template",
+		Content:           renderTemplateText(template.BodyContent, recipient.TemplateParams),
+		TemplateName:      template.Name,
+		TemplateParams:    recipient.TemplateParams,
+		Status:            "sent",
+		Metadata: models.JSONB{
+			"automation_trigger_id": trigger.ID.String(),
+		},
+	}
+	if err := a.DB.Create(&message).Error; err != nil {
+		a.Log.Error("Failed to save automation trigger message", "error", err, "trigger_id", trigger.ID)
+	}
+	return "", nil
+}
+
+// automationStartFlow starts the trigger's chatbot flow for contact. Flows send
+// free-form messages, so the contact must have messaged within the customer service
+// window, and a flow the contact is already in isn't interrupted.
+func (a *App) automationStartFlow(trigger *models.AutomationTrigger, contact *models.Contact) (string, error) {
+	var lastIncoming models.Message
+	err := a.DB.Select("created_at").
+		Where("contact_id = ? AND direction = ?", contact.ID, "incoming").
+		Order("created_at DESC").
+		First(&lastIncoming).Error
+	if err != nil || time.Since(lastIncoming.CreatedAt) > customerServiceWindow {
+		return "Outside the 24-hour customer service window", nil
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("organization_id = ? AND name = ?", trigger.OrganizationID, trigger.WhatsAppAccount).First(&account).Error; err != nil {
+		return "", fmt.Errorf("WhatsApp account not found")
+	}
+	if trigger.FlowID == nil {
+		return "", fmt.Errorf("flow not found")
+	}
+	flow, err := a.getChatbotFlowByIDCached(trigger.OrganizationID, *trigger.FlowID)
+	if err != nil || flow == nil {
+		return "", fmt.Errorf("flow not found")
+	}
+
+	timeoutMins := defaultFlowSessionTimeoutMins
+	if settings, err := a.getChatbotSettingsCached(trigger.OrganizationID, account.Name); err == nil && settings != nil && settings.SessionTimeoutMins > 0 {
+		timeoutMins = settings.SessionTimeoutMins
+	}
+	session, _ := a.getOrCreateSession(trigger.OrganizationID, contact.ID, account.Name, contact.PhoneNumber, timeoutMins)
+	if session.CurrentFlowID != nil {
+		return "Contact is already in a flow", nil
+	}
+
+	a.startFlow(&account, session, contact, flow)
+	return "", nil
+}
+
+// AutomationTriggerProcessor evaluates the segments of segment triggers, running
+// them for contacts who entered or exited since the last evaluation
+type AutomationTriggerProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewAutomationTriggerProcessor creates a new automation trigger processor
+func NewAutomationTriggerProcessor(app *App, interval time.Duration) *AutomationTriggerProcessor {
+	return &AutomationTriggerProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the segment evaluation loop
+func (p *AutomationTriggerProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Automation trigger processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Automation trigger processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Automation trigger processor stopped")
+			return
+		case <-ticker.C:
+			p.processSegments(ctx)
+		}
+	}
+}
+
+// Stop stops the automation trigger processor
+func (p *AutomationTriggerProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// processSegments evaluates every enabled segment trigger
+func (p *AutomationTriggerProcessor) processSegments(ctx context.Context) {
+	acquired, err := p.app.Redis.SetNX(ctx, automationSegmentLockKey, 1, p.interval/2).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	var triggers []models.AutomationTrigger
+	if err := p.app.DB.Where("is_enabled = true AND event IN ?", []string{
+		models.AutomationEventSegmentEntered, models.AutomationEventSegmentExited,
+	}).Find(&triggers).Error; err != nil {
+		p.app.Log.Error("Failed to load segment triggers", "error", err)
+		return
+	}
+
+	for i := range triggers {
+		if err := p.evaluateSegment(&triggers[i]); err != nil {
+			p.app.Log.Error("Failed to evaluate trigger segment", "error", err, "trigger_id", triggers[i].ID)
+		}
+	}
+}
+
+// evaluateSegment records who entered and exited the trigger's segment and runs the
+// trigger for those its event is about. The first evaluation only records the
+// segment, so turning a trigger on doesn't reach everyone already in it.
+func (p *AutomationTriggerProcessor) evaluateSegment(trigger *models.AutomationTrigger) error {
+	segment, args := automationSegmentCondition(trigger)
+
+	if trigger.SegmentSeededAt == nil {
+		if err := p.app.DB.Exec(`
+			INSERT INTO automation_segment_members (trigger_id, contact_id, created_at)
+			SELECT ?, c.id, NOW() FROM contacts c WHERE `+segment+`
+			ON CONFLICT DO NOTHING`, append([]interface{}{trigger.ID}, args...)...).Error; err != nil {
+			return fmt.Errorf("failed to record segment: %w", err)
+		}
+		return p.app.DB.Model(trigger).Update("segment_seeded_at", time.Now()).Error
+	}
+
+	var entered []models.Contact
+	if err := p.app.DB.Raw(`
+		SELECT c.* FROM contacts c
+		WHERE `+segment+` AND NOT EXISTS (
+			SELECT 1 FROM automation_segment_members s WHERE s.trigger_id = ? AND s.contact_id = c.id
+		)
+		LIMIT ?`, append(args, trigger.ID, automationSegmentBatch)...).
+		Scan(&entered).Error; err != nil {
+		return fmt.Errorf("failed to find contacts entering segment: %w", err)
+	}
+
+	var exitedIDs []uuid.UUID
+	if err := p.app.DB.Raw(`
+		SELECT s.contact_id FROM automation_segment_members s
+		WHERE s.trigger_id = ? AND NOT EXISTS (
+			SELECT 1 FROM contacts c WHERE c.id = s.contact_id AND `+segment+`
+		)
+		LIMIT ?`, append(append([]interface{}{trigger.ID}, args...), automationSegmentBatch)...).
+		Scan(&exitedIDs).Error; err != nil {
+		return fmt.Errorf("failed to find contacts exiting segment: %w", err)
+	}
+
+	if len(entered) > 0 {
+		members := make([]models.AutomationSegmentMember, len(entered))
+		for i := range entered {
+			members[i] = models.AutomationSegmentMember{TriggerID: trigger.ID, ContactID: entered[i].ID}
+		}
+		if err := p.app.DB.Create(&members).Error; err != nil {
+			return fmt.Errorf("failed to record segment members: %w", err)
+		}
+		if trigger.Event == models.AutomationEventSegmentEntered {
+			for i := range entered {
+				p.app.runAutomationTrigger(trigger, &entered[i], trigger.Event)
+			}
+		}
+	}
+
+	if len(exitedIDs) > 0 {
+		if err := p.app.DB.Where("trigger_id = ? AND contact_id IN ?", trigger.ID, exitedIDs).
+			Delete(&models.AutomationSegmentMember{}).Error; err != nil {
+			return fmt.Errorf("failed to remove segment members: %w", err)
+		}
+		if trigger.Event == models.AutomationEventSegmentExited {
+			var exited []models.Contact
+			p.app.DB.Where("id IN ?", exitedIDs).Find(&exited)
+			for i := range exited {
+				p.app.runAutomationTrigger(trigger, &exited[i], trigger.Event)
+			}
+		}
+	}
+	return nil
+}
+
+// automationSegmentCondition matches the contacts (aliased c) in the trigger's
+// segment
+func automationSegmentCondition(trigger *models.AutomationTrigger) (string, []interface{}) {
+	conds := []string{"c.organization_id = ?", "c.whats_app_account = ?", "c.deleted_at IS NULL"}
+	args := []interface{}{trigger.OrganizationID, trigger.WhatsAppAccount}
+
+	if trigger.SegmentMinEngagement > 0 {
+		conds = append(conds, "c.engagement_score >= ?")
+		args = append(args, trigger.SegmentMinEngagement)
+	}
+	if trigger.SegmentLabelID != nil {
+		conds = append(conds, "EXISTS (SELECT 1 FROM conversation_labels cl WHERE cl.contact_id = c.id AND cl.label_id = ? AND cl.deleted_at IS NULL)")
+		args = append(args, *trigger.SegmentLabelID)
+	}
+	if trigger.Tag != "" {
+		tag, _ := json.Marshal([]string{trigger.Tag})
+		conds = append(conds, "c.tags @> ?::jsonb")
+		args = append(args, string(tag))
+	}
+	return strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// defaultAutomationCooldownHours is the cooldown of triggers created without one
+const defaultAutomationCooldownHours = 24
+
+// automationEvents are the events a trigger can be created for
+var automationEvents = []string{
+	models.AutomationEventTagAdded,
+	models.AutomationEventAttributeChanged,
+	models.AutomationEventSegmentEntered,
+	models.AutomationEventSegmentExited,
+	models.AutomationEventCampaignDelivered,
+	models.AutomationEventLinkClicked,
+}
+
+// AutomationTriggerRequest is the request body for creating or updating an
+// automation trigger
+type AutomationTriggerRequest struct {
+	Name                 string            `json:"name"`
+	WhatsAppAccount      string            `json:"whatsapp_account"`
+	IsEnabled            *bool             `json:"is_enabled"`
+	Event                string            `json:"event"`
+	Tag                  string            `json:"tag"`
+	AttributeKey         string            `json:"attribute_key"`
+	AttributeValue       string            `json:"attribute_value"`
+	SegmentMinEngagement int               `json:"segment_min_engagement"`
+	SegmentLabelID       *string           `json:"segment_label_id"`
+	CampaignID           *string           `json:"campaign_id"`
+	URLContains          string            `json:"url_contains"`
+	Action               string            `json:"action"`
+	FlowID               *string           `json:"flow_id"`
+	TemplateID           *string           `json:"template_id"`
+	TemplateParams       map[string]string `json:"template_params"`
+	CooldownHours        *int              `json:"cooldown_hours"`
+	MaxPerContact        int               `json:"max_per_contact"`
+	DailyCap             int               `json:"daily_cap"`
+}
+
+// LinkClickRequest reports a click on a link sent to a contact, identified by ID or
+// phone number
+type LinkClickRequest struct {
+	ContactID   string `json:"contact_id"`
+	PhoneNumber string `json:"phone_number"`
+	URL         string `json:"url"`
+	CampaignID  string `json:"campaign_id"` // Optional; the campaign that sent the link
+}
+
+// ListAutomationTriggers returns all automation triggers for the organization
+func (a *App) ListAutomationTriggers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var triggers []models.AutomationTrigger
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&triggers).Error; err != nil {
+		a.Log.Error("Failed to list automation triggers", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list automation triggers", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"triggers": triggers,
+	})
+}
+
+// CreateAutomationTrigger creates an automation trigger
+func (a *App) CreateAutomationTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req AutomationTriggerRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	trigger := models.AutomationTrigger{
+		OrganizationID: orgID,
+		IsEnabled:      true,
+		CooldownHours:  defaultAutomationCooldownHours,
+		CreatedBy:      userID,
+	}
+	if err := a.applyAutomationTriggerRequest(orgID, &trigger, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Create(&trigger).Error; err != nil {
+		a.Log.Error("Failed to create automation trigger", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create automation trigger", nil, "")
+	}
+
+	return r.SendEnvelope(trigger)
+}
+
+// GetAutomationTrigger returns an automation trigger
+func (a *App) GetAutomationTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findAutomationTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Automation trigger not found", nil, "")
+	}
+
+	return r.SendEnvelope(trigger)
+}
+
+// UpdateAutomationTrigger replaces an automation trigger's configuration. Changing a
+// segment trigger's segment records it afresh without running the trigger.
+func (a *App) UpdateAutomationTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findAutomationTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Automation trigger not found", nil, "")
+	}
+
+	var req AutomationTriggerRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	segment := automationSegmentKey(trigger)
+	if err := a.applyAutomationTriggerRequest(orgID, trigger, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	if automationSegmentKey(trigger) != segment {
+		trigger.SegmentSeededAt = nil
+		a.DB.Where("trigger_id = ?", trigger.ID).Delete(&models.AutomationSegmentMember{})
+	}
+
+	if err := a.DB.Save(trigger).Error; err != nil {
+		a.Log.Error("Failed to update automation trigger", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update automation trigger", nil, "")
+	}
+
+	return r.SendEnvelope(trigger)
+}
+
+// DeleteAutomationTrigger deletes an automation trigger and its segment membership.
+// Its runs are kept.
+func (a *App) DeleteAutomationTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findAutomationTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Automation trigger not found", nil, "")
+	}
+
+	if err := a.DB.Delete(trigger).Error; err != nil {
+		a.Log.Error("Failed to delete automation trigger", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete automation trigger", nil, "")
+	}
+	a.DB.Where("trigger_id = ?", trigger.ID).Delete(&models.AutomationSegmentMember{})
+
+	return r.SendEnvelope(map[string]string{"message": "Automation trigger deleted successfully"})
+}
+
+// ListAutomationTriggerRuns returns a trigger's runs, newest first.
+// Query params: status, limit (default 50, max 200)
+func (a *App) ListAutomationTriggerRuns(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findAutomationTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Automation trigger not found", nil, "")
+	}
+
+	query := a.DB.Where("trigger_id = ?", trigger.ID)
+	if status := string(r.RequestCtx.QueryArgs().Peek("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	limit := r.RequestCtx.QueryArgs().GetUintOrZero("limit")
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	var runs []models.AutomationTriggerRun
+	if err := query.Order("created_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		a.Log.Error("Failed to list automation trigger runs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list automation trigger runs", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"runs": runs,
+	})
+}
+
+// ReportLinkClick records that a contact clicked a link, running the link_clicked
+// triggers that match it. Clicks are reported by whatever tracks them, such as a
+// link shortener or the website the link points to, and show on the timelines of
+// the contact's campaign recipients.
+func (a *App) ReportLinkClick(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req LinkClickRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.URL == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "url is required", nil, "")
+	}
+
+	var contact models.Contact
+	query := a.DB.Where("organization_id = ?", orgID)
+	if req.ContactID != "" {
+		contactID, err := uuid.Parse(req.ContactID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+		}
+		query = query.Where("id = ?", contactID)
+	} else if phone := strings.TrimPrefix(strings.TrimSpace(req.PhoneNumber), "+"); phone != "" {
+		query = query.Where("phone_number IN ?", []string{phone, "+" + phone})
+	} else {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_id or phone_number is required", nil, "")
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	click := models.LinkClick{
+		OrganizationID: orgID,
+		ContactID:      contact.ID,
+		URL:            req.URL,
+		ClickedAt:      time.Now(),
+	}
+	if req.CampaignID != "" {
+		campaignID, err := uuid.Parse(req.CampaignID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+		}
+		click.CampaignID = &campaignID
+	}
+	if err := a.DB.Create(&click).Error; err != nil {
+		a.Log.Error("Failed to save link click", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to record link click", nil, "")
+	}
+
+	go a.fireAutomationTriggers(&contact, AutomationEvent{Type: models.AutomationEventLinkClicked, URL: req.URL})
+
+	return r.SendEnvelope(map[string]string{"message": "Link click recorded"})
+}
+
+// applyAutomationTriggerRequest validates req and copies it onto trigger
+func (a *App) applyAutomationTriggerRequest(orgID uuid.UUID, trigger *models.AutomationTrigger, req *AutomationTriggerRequest) error {
+	if req.Name == "" || req.WhatsAppAccount == "" {
+		return fmt.Errorf("name and whatsapp_account are required")
+	}
+	if !slices.Contains(automationEvents, req.Event) {
+		return fmt.Errorf("event must be one of tag_added, attribute_changed, segment_entered, segment_exited, campaign_delivered or link_clicked")
+	}
+	if req.MaxPerContact < 0 || req.DailyCap < 0 || (req.CooldownHours != nil && *req.CooldownHours < 0) {
+		return fmt.Errorf("cooldown_hours, max_per_contact and daily_cap can't be negative")
+	}
+
+	var count int64
+	a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("WhatsApp account not found")
+	}
+
+	trigger.Name = req.Name
+	trigger.WhatsAppAccount = req.WhatsAppAccount
+	if req.IsEnabled != nil {
+		trigger.IsEnabled = *req.IsEnabled
+	}
+	trigger.Event = req.Event
+	trigger.Tag = ""
+	trigger.AttributeKey = ""
+	trigger.AttributeValue = ""
+	trigger.SegmentMinEngagement = 0
+	trigger.SegmentLabelID = nil
+	trigger.CampaignID = nil
+	trigger.URLContains = ""
+
+	switch req.Event {
+	case models.AutomationEventTagAdded:
+		trigger.Tag = req.Tag
+
+	case models.AutomationEventAttributeChanged:
+		if req.AttributeKey == "" {
+			return fmt.Errorf("attribute_key is required")
+		}
+		trigger.AttributeKey = req.AttributeKey
+		trigger.AttributeValue = req.AttributeValue
+
+	case models.AutomationEventSegmentEntered, models.AutomationEventSegmentExited:
+		if req.SegmentMinEngagement < 0 || req.SegmentMinEngagement > 100 {
+			return fmt.Errorf("segment_min_engagement must be between 0 and 100")
+		}
+		trigger.SegmentMinEngagement = req.SegmentMinEngagement
+		trigger.Tag = req.Tag
+		if req.SegmentLabelID != nil && *req.SegmentLabelID != "" {
+			labelID, err := uuid.Parse(*req.SegmentLabelID)
+			if err != nil {
+				return fmt.Errorf("invalid segment_label_id")
+			}
+			a.DB.Model(&models.Label{}).Where("id = ? AND organization_id = ?", labelID, orgID).Count(&count)
+			if count == 0 {
+				return fmt.Errorf("label not found")
+			}
+			trigger.SegmentLabelID = &labelID
+		}
+		if trigger.SegmentMinEngagement == 0 && trigger.SegmentLabelID == nil && trigger.Tag == "" {
+			return fmt.Errorf("a segment needs segment_min_engagement, segment_label_id or tag")
+		}
+
+	case models.AutomationEventCampaignDelivered:
+		if req.CampaignID != nil && *req.CampaignID != "" {
+			campaignID, err := uuid.Parse(*req.CampaignID)
+			if err != nil {
+				return fmt.Errorf("invalid campaign_id")
+			}
+			a.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND organization_id = ?", campaignID, orgID).Count(&count)
+			if count == 0 {
+				return fmt.Errorf("campaign not found")
+			}
+			trigger.CampaignID = &campaignID
+		}
+
+	case models.AutomationEventLinkClicked:
+		trigger.URLContains = req.URLContains
+	}
+
+	trigger.Action = req.Action
+	trigger.FlowID = nil
+	trigger.TemplateID = nil
+	trigger.TemplateParams = models.JSONB{}
+
+	switch req.Action {
+	case models.AutomationActionStartFlow:
+		if req.FlowID == nil || *req.FlowID == "" {
+			return fmt.Errorf("flow_id is required")
+		}
+		flowID, err := uuid.Parse(*req.FlowID)
+		if err != nil {
+			return fmt.Errorf("invalid flow_id")
+		}
+		a.DB.Model(&models.ChatbotFlow{}).Where("id = ? AND organization_id = ?", flowID, orgID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("flow not found")
+		}
+		trigger.FlowID = &flowID
+
+	case models.AutomationActionSendTemplate:
+		if req.TemplateID == nil || *req.TemplateID == "" {
+			return fmt.Errorf("template_id is required")
+		}
+		templateID, err := uuid.Parse(*req.TemplateID)
+		if err != nil {
+			return fmt.Errorf("invalid template_id")
+		}
+		a.DB.Model(&models.Template{}).Where("id = ? AND organization_id = ? AND whats_app_account = ?", templateID, orgID, req.WhatsAppAccount).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("template not found for this account")
+		}
+		trigger.TemplateID = &templateID
+		for k, v := range req.TemplateParams {
+			trigger.TemplateParams[k] = v
+		}
+
+	default:
+		return fmt.Errorf("action must be start_flow or send_template")
+	}
+
+	if req.CooldownHours != nil {
+		trigger.CooldownHours = *req.CooldownHours
+	}
+	trigger.MaxPerContact = req.MaxPerContact
+	trigger.DailyCap = req.DailyCap
+	return nil
+}
+
+// automationSegmentKey identifies the contacts the trigger's segment selects
+func automationSegmentKey(trigger *models.AutomationTrigger) string {
+	condition, args := automationSegmentCondition(trigger)
+	return condition + fmt.Sprint(args...)
+}
+
+func (a *App) findAutomationTrigger(r *fastglue.Request, orgID uuid.UUID) (*models.AutomationTrigger, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var trigger models.AutomationTrigger
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&trigger).Error; err != nil {
+		return nil, err
+	}
+	return &trigger, nil
+}
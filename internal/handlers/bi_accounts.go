@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/database"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// maxBIServiceAccounts limits the Postgres roles one organization can create
+	maxBIServiceAccounts = 10
+
+	biRolePrefix       = "whatomate_bi_"
+	biPasswordLength   = 32
+	biPasswordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789"
+)
+
+// BIServiceAccountRequest is the request body for creating a BI service account
+type BIServiceAccountRequest struct {
+	Name string `json:"name"`
+}
+
+// BICredentials are the connection details for a BI tool. The password is only
+// returned when the account is created or its password rotated.
+type BICredentials struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Database string `json:"database"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Schema   string `json:"schema"`
+	SSLMode  string `json:"ssl_mode"`
+}
+
+// ListBIServiceAccounts returns the organization's BI service accounts, and whether
+// the server's database user can create them
+func (a *App) ListBIServiceAccounts(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var accounts []models.BIServiceAccount
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&accounts).Error; err != nil {
+		a.Log.Error("Failed to list BI service accounts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list BI service accounts", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"accounts":  accounts,
+		"supported": database.CanManageReportingRoles(a.DB),
+		"schema":    database.ReportingSchema,
+	})
+}
+
+// CreateBIServiceAccount creates a read-only Postgres login scoped to the organization's
+// rows in the reporting schema
+func (a *App) CreateBIServiceAccount(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req BIServiceAccountRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
+	}
+
+	if _, err := a.activeOffboarding(orgID); err == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "The organization is being offboarded", nil, "")
+	}
+
+	var count int64
+	a.DB.Model(&models.BIServiceAccount{}).Where("organization_id = ?", orgID).Count(&count)
+	if count >= maxBIServiceAccounts {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Maximum number of BI service accounts reached", nil, "")
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		a.Log.Error("Failed to generate BI role name", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create BI service account", nil, "")
+	}
+	password, err := generateBIPassword()
+	if err != nil {
+		a.Log.Error("Failed to generate BI password", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create BI service account", nil, "")
+	}
+
+	now := time.Now()
+	account := models.BIServiceAccount{
+		OrganizationID:    orgID,
+		Name:              req.Name,
+		RoleName:          biRolePrefix + hex.EncodeToString(suffix),
+		IsActive:          true,
+		CreatedByID:       userID,
+		PasswordRotatedAt: &now,
+	}
+
+	if err := database.CreateReportingRole(a.DB, account.RoleName, password); err != nil {
+		return a.sendBIRoleError(r, err, "Failed to create BI service account")
+	}
+	if err := a.DB.Create(&account).Error; err != nil {
+		a.Log.Error("Failed to create BI service account", "error", err)
+		if err := database.DropReportingRole(a.DB, account.RoleName); err != nil {
+			a.Log.Error("Failed to drop orphaned BI role", "error", err, "role", account.RoleName)
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create BI service account", nil, "")
+	}
+
+	a.Log.Info("BI service account created", "organization_id", orgID, "role", account.RoleName)
+	return r.SendEnvelope(map[string]any{
+		"account":     account,
+		"credentials": a.biCredentials(&account, password),
+	})
+}
+
+// RotateBIServiceAccountPassword replaces a BI service account's password
+func (a *App) RotateBIServiceAccountPassword(r *fastglue.Request) error {
+	account, err := a.getBIServiceAccount(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "BI service account not found", nil, "")
+	}
+
+	password, err := generateBIPassword()
+	if err != nil {
+		a.Log.Error("Failed to generate BI password", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to rotate password", nil, "")
+	}
+	if err := database.SetReportingRolePassword(a.DB, account.RoleName, password); err != nil {
+		return a.sendBIRoleError(r, err, "Failed to rotate password")
+	}
+
+	now := time.Now()
+	a.DB.Model(account).Update("password_rotated_at", now)
+	account.PasswordRotatedAt = &now
+
+	return r.SendEnvelope(map[string]any{
+		"account":     account,
+		"credentials": a.biCredentials(account, password),
+	})
+}
+
+// DeleteBIServiceAccount drops a BI service account's role, closing its connections
+func (a *App) DeleteBIServiceAccount(r *fastglue.Request) error {
+	account, err := a.getBIServiceAccount(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "BI service account not found", nil, "")
+	}
+
+	if err := database.DropReportingRole(a.DB, account.RoleName); err != nil {
+		return a.sendBIRoleError(r, err, "Failed to delete BI service account")
+	}
+	if err := a.DB.Unscoped().Delete(account).Error; err != nil {
+		a.Log.Error("Failed to delete BI service account", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete BI service account", nil, "")
+	}
+
+	a.Log.Info("BI service account deleted", "organization_id", account.OrganizationID, "role", account.RoleName)
+	return r.SendEnvelope(map[string]string{"message": "BI service account deleted successfully"})
+}
+
+// GetReportingSchema documents the views BI service accounts can query
+func (a *App) GetReportingSchema(r *fastglue.Request) error {
+	return r.SendEnvelope(map[string]any{
+		"schema": database.ReportingSchema,
+		"views":  database.ReportingViews,
+	})
+}
+
+// getBIServiceAccount loads the organization's BI service account named by the {id} path param
+func (a *App) getBIServiceAccount(r *fastglue.Request) (*models.BIServiceAccount, error) {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.BIServiceAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// sendBIRoleError reports a failed role change, explaining a missing CREATEROLE privilege
+func (a *App) sendBIRoleError(r *fastglue.Request, err error, msg string) error {
+	if errors.Is(err, database.ErrReportingNotPermitted) {
+		return r.SendErrorEnvelope(fasthttp.StatusServiceUnavailable, "BI service accounts are not available: "+err.Error(), nil, "")
+	}
+	a.Log.Error(msg, "error", err)
+	return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, msg, nil, "")
+}
+
+// biCredentials returns the connection details BI tools use for the account
+func (a *App) biCredentials(account *models.BIServiceAccount, password string) BICredentials {
+	db := a.Config.Database
+	creds := BICredentials{
+		Host:     db.Host,
+		Port:     db.Port,
+		Database: db.Name,
+		Username: account.RoleName,
+		Password: password,
+		Schema:   database.ReportingSchema,
+		SSLMode:  db.SSLMode,
+	}
+	if db.ReportingHost != "" {
+		creds.Host = db.ReportingHost
+	}
+	if db.ReportingPort != 0 {
+		creds.Port = db.ReportingPort
+	}
+	return creds
+}
+
+// generateBIPassword generates a random password from characters that need no quoting
+// in connection strings and are easy to read back
+func generateBIPassword() (string, error) {
+	size := big.NewInt(int64(len(biPasswordAlphabet)))
+	b := make([]byte, biPasswordLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b[i] = biPasswordAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+)
+
+const (
+	// botMessageCountPrefix counts the automated messages sent to a contact in the
+	// current window
+	botMessageCountPrefix = "chatbot:bot_messages:"
+	// botMessageLimitedPrefix marks a contact that went over the limit in the current
+	// window, so the organization is alerted once
+	botMessageLimitedPrefix = "chatbot:bot_messages_limited:"
+)
+
+// Automated message sources
+const (
+	BotMessageSourceChatbot    = "chatbot"
+	BotMessageSourceAutomation = "automation_trigger"
+)
+
+// errBotMessageLimit is returned for automated messages held back by the limit
+var errBotMessageLimit = errors.New("contact reached the automated message limit")
+
+// allowBotMessage counts an automated message to contact and reports whether it may be
+// sent. Chatbot replies, flows and automation triggers share the limit, so a flow or
+// trigger that loops can't flood a contact. The first message over the limit in a
+// window takes the contact out of their flow and alerts the organization.
+func (a *App) allowBotMessage(orgID uuid.UUID, accountName string, contact *models.Contact, source string) bool {
+	settings, err := a.getChatbotSettingsCached(orgID, accountName)
+	if err != nil || settings == nil || settings.BotMessageLimit <= 0 {
+		return true
+	}
+	window := time.Duration(max(settings.BotMessageWindowMins, 1)) * time.Minute
+
+	ctx := context.Background()
+	key := botMessageCountPrefix + contact.ID.String()
+	count, err := a.Redis.Incr(ctx, key).Result()
+	if err != nil {
+		// Don't stop the bot because the counter is unavailable
+		a.Log.Error("Failed to count automated message", "error", err, "contact_id", contact.ID)
+		return true
+	}
+	if count == 1 {
+		a.Redis.Expire(ctx, key, window)
+	}
+	if count <= int64(settings.BotMessageLimit) {
+		return true
+	}
+
+	a.Log.Warn("Automated message held back by the contact's limit", "contact_id", contact.ID, "source", source, "count", count)
+	if first, err := a.Redis.SetNX(ctx, botMessageLimitedPrefix+contact.ID.String(), 1, window).Result(); err == nil && first {
+		a.botMessageLimitReached(orgID, accountName, contact, source, settings)
+	}
+	return false
+}
+
+// botMessageLimited reports whether contact has gone over the automated message limit
+// in the current window
+func (a *App) botMessageLimited(contactID uuid.UUID) bool {
+	n, err := a.Redis.Exists(context.Background(), botMessageLimitedPrefix+contactID.String()).Result()
+	return err == nil && n > 0
+}
+
+// botMessageLimitReached takes the contact out of the flow they are in and alerts the
+// organization
+func (a *App) botMessageLimitReached(orgID uuid.UUID, accountName string, contact *models.Contact, source string, settings *models.ChatbotSettings) {
+	data := BotMessageLimitEventData{
+		ContactID:       contact.ID.String(),
+		ContactPhone:    contact.PhoneNumber,
+		ContactName:     contact.ProfileName,
+		WhatsAppAccount: accountName,
+		Source:          source,
+		Limit:           settings.BotMessageLimit,
+		WindowMinutes:   max(settings.BotMessageWindowMins, 1),
+	}
+
+	var session models.ChatbotSession
+	if err := a.DB.Where("organization_id = ? AND contact_id = ? AND whats_app_account = ? AND status = ? AND current_flow_id IS NOT NULL",
+		orgID, contact.ID, accountName, "active").First(&session).Error; err == nil {
+		data.FlowID = session.CurrentFlowID.String()
+		data.Step = session.CurrentStep
+		if flow, err := a.getChatbotFlowByIDCached(orgID, *session.CurrentFlowID); err == nil && flow != nil {
+			data.FlowName = flow.Name
+		}
+		a.exitFlow(&session, models.FlowExitMessageLimit)
+	}
+
+	a.Log.Warn("Contact reached the automated message limit",
+		"organization_id", orgID,
+		"contact_id", contact.ID,
+		"source", source,
+		"limit", data.Limit,
+		"window_minutes", data.WindowMinutes,
+		"flow_id", data.FlowID,
+		"step", data.Step,
+	)
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
+			Type:    websocket.TypeBotMessageLimit,
+			Payload: a.withNotification(orgID, notify.KeyBotMessageLimit, data),
+		})
+	}
+	a.DispatchWebhook(orgID, EventChatbotLimit, data)
+}
@@ -0,0 +1,558 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultBroadcastDailyLimit is how many recipients a user may send to from broadcast
+// lists per day when the organization hasn't set broadcast_daily_limit
+const defaultBroadcastDailyLimit = 1000
+
+// BroadcastListRequest is the request body for creating or updating a broadcast list
+type BroadcastListRequest struct {
+	Name            string   `json:"name"`
+	Description     string   `json:"description"`
+	WhatsAppAccount string   `json:"whatsapp_account"` // Only used on create
+	ContactIDs      []string `json:"contact_ids"`      // Only used on create
+}
+
+// BroadcastListMembersRequest is the request body for adding contacts to a broadcast list
+type BroadcastListMembersRequest struct {
+	ContactIDs []string `json:"contact_ids"`
+}
+
+// BroadcastSendRequest is the request body for sending a template to a broadcast list
+type BroadcastSendRequest struct {
+	TemplateID     string            `json:"template_id"`
+	TemplateParams map[string]string `json:"template_params"` // "1" -> "{{name}}"
+}
+
+// BroadcastListMemberResponse is a broadcast list member in API responses
+type BroadcastListMemberResponse struct {
+	ContactID   uuid.UUID `json:"contact_id"`
+	PhoneNumber string    `json:"phone_number"`
+	ProfileName string    `json:"profile_name"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// BroadcastSendResponse summarizes a send to a broadcast list
+type BroadcastSendResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	Name            string     `json:"name"`
+	TemplateName    string     `json:"template_name,omitempty"`
+	Status          string     `json:"status"`
+	TotalRecipients int        `json:"total_recipients"`
+	SentCount       int        `json:"sent_count"`
+	DeliveredCount  int        `json:"delivered_count"`
+	ReadCount       int        `json:"read_count"`
+	FailedCount     int        `json:"failed_count"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// ListBroadcastLists returns the organization's broadcast lists. Agents only see
+// their own.
+func (a *App) ListBroadcastLists(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	query := a.DB.Where("organization_id = ?", orgID).Preload("Owner").Order("name ASC")
+	if role == "agent" {
+		query = query.Where("owner_id = ?", userID)
+	}
+	if account := string(r.RequestCtx.QueryArgs().Peek("whatsapp_account")); account != "" {
+		query = query.Where("whats_app_account = ?", account)
+	}
+
+	var lists []models.BroadcastList
+	if err := query.Find(&lists).Error; err != nil {
+		a.Log.Error("Failed to list broadcast lists", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list broadcast lists", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"lists":       lists,
+		"daily_limit": a.broadcastDailyLimit(orgID),
+		"sent_today":  a.broadcastRecipientsToday(orgID, userID),
+		"max_members": models.MaxBroadcastListMembers,
+	})
+}
+
+// CreateBroadcastList creates a broadcast list owned by the current user, optionally
+// with initial members
+func (a *App) CreateBroadcastList(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req BroadcastListRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || req.WhatsAppAccount == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name and whatsapp_account are required", nil, "")
+	}
+
+	var count int64
+	a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+	if count == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+	}
+
+	list := models.BroadcastList{
+		OrganizationID:  orgID,
+		WhatsAppAccount: req.WhatsAppAccount,
+		Name:            req.Name,
+		Description:     req.Description,
+		OwnerID:         userID,
+	}
+	if err := a.DB.Create(&list).Error; err != nil {
+		a.Log.Error("Failed to create broadcast list", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create broadcast list", nil, "")
+	}
+
+	if len(req.ContactIDs) > 0 {
+		if err := a.addBroadcastListMembers(r, &list, req.ContactIDs); err != nil {
+			a.DB.Unscoped().Delete(&list)
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+	}
+
+	return r.SendEnvelope(list)
+}
+
+// GetBroadcastList returns a broadcast list with its members and recent sends
+func (a *App) GetBroadcastList(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	list, err := a.findBroadcastList(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Broadcast list not found", nil, "")
+	}
+
+	var members []models.BroadcastListMember
+	if err := a.DB.Where("list_id = ?", list.ID).Preload("Contact").Order("created_at ASC").Find(&members).Error; err != nil {
+		a.Log.Error("Failed to load broadcast list members", "error", err, "list_id", list.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load broadcast list", nil, "")
+	}
+	memberResponse := make([]BroadcastListMemberResponse, 0, len(members))
+	for _, m := range members {
+		if m.Contact == nil {
+			continue // Contact was deleted
+		}
+		memberResponse = append(memberResponse, BroadcastListMemberResponse{
+			ContactID:   m.ContactID,
+			PhoneNumber: m.Contact.PhoneNumber,
+			ProfileName: m.Contact.ProfileName,
+			AddedAt:     m.CreatedAt,
+		})
+	}
+
+	var campaigns []models.BulkMessageCampaign
+	a.DB.Where("organization_id = ? AND broadcast_list_id = ?", orgID, list.ID).
+		Preload("Template").Order("created_at DESC").Limit(10).Find(&campaigns)
+	sends := make([]BroadcastSendResponse, len(campaigns))
+	for i := range campaigns {
+		sends[i] = broadcastSendToResponse(&campaigns[i])
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"list":    list,
+		"members": memberResponse,
+		"sends":   sends,
+	})
+}
+
+// UpdateBroadcastList renames a broadcast list. Its account can't be changed.
+func (a *App) UpdateBroadcastList(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	list, err := a.findBroadcastList(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Broadcast list not found", nil, "")
+	}
+
+	var req BroadcastListRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name is required", nil, "")
+	}
+
+	list.Name = req.Name
+	list.Description = req.Description
+	list.Owner = nil
+	if err := a.DB.Save(list).Error; err != nil {
+		a.Log.Error("Failed to update broadcast list", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update broadcast list", nil, "")
+	}
+
+	return r.SendEnvelope(list)
+}
+
+// DeleteBroadcastList deletes a broadcast list and its members. Sends already made
+// are kept as campaigns.
+func (a *App) DeleteBroadcastList(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	list, err := a.findBroadcastList(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Broadcast list not found", nil, "")
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("list_id = ?", list.ID).Delete(&models.BroadcastListMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(list).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to delete broadcast list", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete broadcast list", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Broadcast list deleted successfully"})
+}
+
+// AddBroadcastListMembers adds contacts to a broadcast list. Contacts already on the
+// list are skipped.
+func (a *App) AddBroadcastListMembers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	list, err := a.findBroadcastList(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Broadcast list not found", nil, "")
+	}
+
+	var req BroadcastListMembersRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.ContactIDs) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_ids is required", nil, "")
+	}
+
+	if err := a.addBroadcastListMembers(r, list, req.ContactIDs); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(list)
+}
+
+// RemoveBroadcastListMember removes a contact from a broadcast list
+func (a *App) RemoveBroadcastListMember(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	list, err := a.findBroadcastList(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Broadcast list not found", nil, "")
+	}
+
+	contactIDStr, _ := r.RequestCtx.UserValue("contact_id").(string)
+	contactID, err := uuid.Parse(contactIDStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	result := a.DB.Unscoped().Where("list_id = ? AND contact_id = ?", list.ID, contactID).Delete(&models.BroadcastListMember{})
+	if result.Error != nil {
+		a.Log.Error("Failed to remove broadcast list member", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to remove member", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact is not on this list", nil, "")
+	}
+	a.updateBroadcastListMemberCount(list)
+
+	return r.SendEnvelope(list)
+}
+
+// SendBroadcastList sends a template to the list's members. The send is created as a
+// campaign and run by the worker like any other; members who opted out are skipped,
+// and it counts against the sender's daily broadcast limit.
+func (a *App) SendBroadcastList(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	list, err := a.findBroadcastList(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Broadcast list not found", nil, "")
+	}
+
+	var req BroadcastSendRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	templateID, err := uuid.Parse(req.TemplateID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template ID", nil, "")
+	}
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ? AND whats_app_account = ?", templateID, orgID, list.WhatsAppAccount).First(&template).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template not found for this account", nil, "")
+	}
+	if !strings.EqualFold(template.Status, "APPROVED") {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template is not approved", nil, "")
+	}
+
+	// Broadcasts follow the same blackout dates as campaigns
+	if blackout := a.campaignBlackout(orgID); blackout != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaigns are blocked today: "+blackout.Name, nil, "")
+	}
+
+	var contacts []models.Contact
+	if err := a.DB.Where("organization_id = ?", orgID).
+		Where("id IN (?)", a.DB.Model(&models.BroadcastListMember{}).Select("contact_id").Where("list_id = ?", list.ID)).
+		Where(contactNotOptedOut, engagement.OptOutKeywords).
+		Order("created_at ASC").
+		Find(&contacts).Error; err != nil {
+		a.Log.Error("Failed to load broadcast list contacts", "error", err, "list_id", list.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load broadcast list", nil, "")
+	}
+	var memberCount int64
+	a.DB.Model(&models.BroadcastListMember{}).Where("list_id = ?", list.ID).Count(&memberCount)
+	optedOut := int(memberCount) - len(contacts)
+	if len(contacts) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Broadcast list has no contacts to send to", nil, "")
+	}
+
+	// Test numbers don't count toward the daily limit
+	counted := len(contacts)
+	testNumbers := a.testNumberSet(orgID)
+	for i := range contacts {
+		if testNumbers[strings.TrimPrefix(contacts[i].PhoneNumber, "+")] {
+			counted--
+		}
+	}
+	limit := a.broadcastDailyLimit(orgID)
+	used := a.broadcastRecipientsToday(orgID, userID)
+	if used+counted > limit {
+		return r.SendErrorEnvelope(fasthttp.StatusTooManyRequests,
+			fmt.Sprintf("Daily broadcast limit reached: %d of %d recipients used today", used, limit), nil, "")
+	}
+
+	params := models.JSONB{}
+	for k, v := range req.TemplateParams {
+		params[k] = v
+	}
+	recipients := make([]models.BulkMessageRecipient, len(contacts))
+	for i := range contacts {
+		recipients[i] = contactRecipient(&contacts[i], params)
+	}
+
+	startedAt := time.Now()
+	campaign := models.BulkMessageCampaign{
+		OrganizationID:  orgID,
+		WhatsAppAccount: list.WhatsAppAccount,
+		Name:            fmt.Sprintf("%s (%s)", list.Name, startedAt.In(a.orgLocation(orgID)).Format("2006-01-02 15:04")),
+		TemplateID:      templateID,
+		Status:          "queued",
+		TotalRecipients: len(recipients),
+		StartedAt:       &startedAt,
+		CreatedBy:       userID,
+		BroadcastListID: &list.ID,
+	}
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		for i := range recipients {
+			recipients[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(&recipients, dateTriggerRecipientBatch).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to create broadcast campaign", "error", err, "list_id", list.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to send broadcast", nil, "")
+	}
+
+	a.Log.Info("Broadcast list sent", "list_id", list.ID, "campaign_id", campaign.ID, "recipients", len(recipients))
+	go a.dispatchCampaignEvent(orgID, campaign.ID, EventCampaignQueued, "")
+
+	if a.Queue != nil {
+		if err := a.Queue.EnqueueCampaign(r.RequestCtx, campaign.ID, queue.Priority(campaign.Priority)); err != nil {
+			a.Log.Error("Failed to enqueue broadcast campaign", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue broadcast", nil, "")
+		}
+	} else {
+		go a.processCampaign(campaign.ID)
+	}
+
+	campaign.Template = &template
+	return r.SendEnvelope(map[string]interface{}{
+		"send":              broadcastSendToResponse(&campaign),
+		"skipped_opted_out": optedOut,
+		"remaining_today":   limit - used - len(recipients),
+	})
+}
+
+// addBroadcastListMembers validates contactIDs and adds them to list. Contacts must
+// belong to the list's account; agents can only add contacts assigned to them.
+func (a *App) addBroadcastListMembers(r *fastglue.Request, list *models.BroadcastList, contactIDs []string) error {
+	ids := make([]uuid.UUID, 0, len(contactIDs))
+	seen := make(map[uuid.UUID]bool, len(contactIDs))
+	for _, s := range contactIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return fmt.Errorf("invalid contact ID: %s", s)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	query := a.DB.Model(&models.Contact{}).
+		Where("organization_id = ? AND whats_app_account = ? AND id IN ?", list.OrganizationID, list.WhatsAppAccount, ids)
+	if role, _ := r.RequestCtx.UserValue("role").(string); role == "agent" {
+		userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	var found []uuid.UUID
+	if err := query.Pluck("id", &found).Error; err != nil {
+		a.Log.Error("Failed to look up broadcast list contacts", "error", err)
+		return fmt.Errorf("failed to add members")
+	}
+	if len(found) != len(ids) {
+		return fmt.Errorf("some contacts were not found on this list's account")
+	}
+
+	var existing int64
+	a.DB.Model(&models.BroadcastListMember{}).Where("list_id = ? AND contact_id NOT IN ?", list.ID, found).Count(&existing)
+	if int(existing)+len(found) > models.MaxBroadcastListMembers {
+		return fmt.Errorf("a broadcast list can have at most %d members", models.MaxBroadcastListMembers)
+	}
+
+	members := make([]models.BroadcastListMember, len(found))
+	for i, id := range found {
+		members[i] = models.BroadcastListMember{ListID: list.ID, ContactID: id}
+	}
+	if err := a.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error; err != nil {
+		a.Log.Error("Failed to add broadcast list members", "error", err)
+		return fmt.Errorf("failed to add members")
+	}
+	a.updateBroadcastListMemberCount(list)
+	return nil
+}
+
+// updateBroadcastListMemberCount refreshes the list's cached member count
+func (a *App) updateBroadcastListMemberCount(list *models.BroadcastList) {
+	var count int64
+	a.DB.Model(&models.BroadcastListMember{}).Where("list_id = ?", list.ID).Count(&count)
+	list.MemberCount = int(count)
+	a.DB.Model(&models.BroadcastList{}).Where("id = ?", list.ID).Update("member_count", count)
+}
+
+// broadcastDailyLimit returns how many recipients each user may send to from
+// broadcast lists per day
+func (a *App) broadcastDailyLimit(orgID uuid.UUID) int {
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return defaultBroadcastDailyLimit
+	}
+	if v, ok := org.Settings["broadcast_daily_limit"].(float64); ok && v >= 0 {
+		return int(v)
+	}
+	return defaultBroadcastDailyLimit
+}
+
+// broadcastRecipientsToday counts the recipients other than test numbers a user has
+// sent to from broadcast lists since midnight in the organization's timezone
+func (a *App) broadcastRecipientsToday(orgID, userID uuid.UUID) int {
+	now := time.Now().In(a.orgLocation(orgID))
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var total int64
+	a.DB.Raw(`
+		SELECT COUNT(*) FROM bulk_message_recipients r
+		JOIN bulk_message_campaigns c ON c.id = r.campaign_id
+		WHERE c.organization_id = ? AND c.created_by = ? AND c.broadcast_list_id IS NOT NULL AND c.created_at >= ?
+			AND c.deleted_at IS NULL AND r.deleted_at IS NULL AND NOT `+recipientIsTestNumber,
+		orgID, userID, midnight, orgID).Scan(&total)
+	return int(total)
+}
+
+// findBroadcastList loads the list in the route's id. Agents can only reach lists
+// they own.
+func (a *App) findBroadcastList(r *fastglue.Request, orgID uuid.UUID) (*models.BroadcastList, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	query := a.DB.Where("id = ? AND organization_id = ?", id, orgID)
+	if role, _ := r.RequestCtx.UserValue("role").(string); role == "agent" {
+		userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+		query = query.Where("owner_id = ?", userID)
+	}
+
+	var list models.BroadcastList
+	if err := query.First(&list).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func broadcastSendToResponse(c *models.BulkMessageCampaign) BroadcastSendResponse {
+	resp := BroadcastSendResponse{
+		ID:              c.ID,
+		Name:            c.Name,
+		Status:          c.Status,
+		TotalRecipients: c.TotalRecipients,
+		SentCount:       c.SentCount,
+		DeliveredCount:  c.DeliveredCount,
+		ReadCount:       c.ReadCount,
+		FailedCount:     c.FailedCount,
+		StartedAt:       c.StartedAt,
+		CompletedAt:     c.CompletedAt,
+	}
+	if c.Template != nil {
+		resp.TemplateName = c.Template.Name
+	}
+	return resp
+}
@@ -20,6 +20,12 @@ const (
 	webhooksCacheTTL        = 6 * time.Hour
 	slaSettingsCacheTTL     = 6 * time.Hour
 	aiContextsCacheTTL      = 6 * time.Hour
+	messageFooterCacheTTL   = 6 * time.Hour
+	redactionRulesCacheTTL  = 6 * time.Hour
+
+	// Link previews change rarely; failed fetches are retried sooner
+	linkPreviewCacheTTL        = 24 * time.Hour
+	linkPreviewFailureCacheTTL = 10 * time.Minute
 
 	// Cache key prefixes
 	settingsCachePrefix        = "chatbot:settings:"
@@ -29,6 +35,9 @@ const (
 	webhooksCachePrefix        = "webhooks:"
 	slaSettingsCacheKey        = "chatbot:sla_enabled_settings"
 	aiContextsCachePrefix      = "chatbot:ai_contexts:"
+	messageFooterCachePrefix   = "org:message_footer:"
+	redactionRulesCachePrefix  = "org:redaction_rules:"
+	linkPreviewCachePrefix     = "link_preview:"
 )
 
 // getChatbotSettingsCached retrieves chatbot settings from cache or database
@@ -64,7 +73,8 @@ func (a *App) getChatbotSettingsCached(orgID uuid.UUID, whatsAppAccount string)
 	return &settings, nil
 }
 
-// getChatbotFlowsCached retrieves all enabled flows with steps from cache or database
+// getChatbotFlowsCached retrieves all enabled flows with steps from cache or database,
+// each at the version new sessions start on
 func (a *App) getChatbotFlowsCached(orgID uuid.UUID) ([]models.ChatbotFlow, error) {
 	ctx := context.Background()
 	cacheKey := fmt.Sprintf("%s%s", flowsCachePrefix, orgID.String())
@@ -88,6 +98,11 @@ func (a *App) getChatbotFlowsCached(orgID uuid.UUID) ([]models.ChatbotFlow, erro
 		return nil, err
 	}
 
+	// New sessions run the published version of flows that have one
+	if err := a.applyPublishedFlows(flows); err != nil {
+		return nil, err
+	}
+
 	// Cache the result
 	if data, err := json.Marshal(flows); err == nil {
 		a.Redis.Set(ctx, cacheKey, data, flowsCacheTTL)
@@ -237,8 +252,12 @@ func (a *App) getWebhooksCached(orgID uuid.UUID) ([]models.Webhook, error) {
 	// Try cache first
 	cached, err := a.Redis.Get(ctx, cacheKey).Result()
 	if err == nil && cached != "" {
-		var webhooks []models.Webhook
-		if err := json.Unmarshal([]byte(cached), &webhooks); err == nil {
+		var entries []webhookCacheEntry
+		if err := json.Unmarshal([]byte(cached), &entries); err == nil {
+			webhooks := make([]models.Webhook, len(entries))
+			for i, e := range entries {
+				webhooks[i] = e.toWebhook()
+			}
 			return webhooks, nil
 		}
 	}
@@ -250,13 +269,35 @@ func (a *App) getWebhooksCached(orgID uuid.UUID) ([]models.Webhook, error) {
 	}
 
 	// Cache the result
-	if data, err := json.Marshal(webhooks); err == nil {
+	entries := make([]webhookCacheEntry, len(webhooks))
+	for i, wh := range webhooks {
+		entries[i] = newWebhookCacheEntry(wh)
+	}
+	if data, err := json.Marshal(entries); err == nil {
 		a.Redis.Set(ctx, cacheKey, data, webhooksCacheTTL)
 	}
 
 	return webhooks, nil
 }
 
+// webhookCacheEntry carries the signing secrets that models.Webhook hides from JSON
+type webhookCacheEntry struct {
+	models.Webhook
+	Secret         string `json:"secret"`
+	PreviousSecret string `json:"previous_secret"`
+}
+
+func newWebhookCacheEntry(wh models.Webhook) webhookCacheEntry {
+	return webhookCacheEntry{Webhook: wh, Secret: wh.Secret, PreviousSecret: wh.PreviousSecret}
+}
+
+func (e webhookCacheEntry) toWebhook() models.Webhook {
+	wh := e.Webhook
+	wh.Secret = e.Secret
+	wh.PreviousSecret = e.PreviousSecret
+	return wh
+}
+
 // InvalidateWebhooksCache invalidates the webhooks cache for an organization
 func (a *App) InvalidateWebhooksCache(orgID uuid.UUID) {
 	ctx := context.Background()
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// startDryRun queues a dry run of the campaign, which goes through sending to its
+// pending recipients without sending anything, for StartCampaign with ?dry_run=true.
+// What would stop the campaign starting is reported as a warning rather than refused,
+// and replaces the campaign's previous dry run.
+func (a *App) startDryRun(r *fastglue.Request, campaign *models.BulkMessageCampaign) error {
+	if a.Queue == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusServiceUnavailable, "Dry runs need a worker", nil, "")
+	}
+
+	var pending int64
+	a.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ? AND status = ?", campaign.ID, "pending").Count(&pending)
+	if pending == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign has no pending recipients", nil, "")
+	}
+
+	dryRun := models.CampaignDryRun{
+		OrganizationID: campaign.OrganizationID,
+		CampaignID:     campaign.ID,
+		Status:         "queued",
+		Warnings:       a.dryRunWarnings(campaign),
+	}
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		dryRun.CreatedByID = &userID
+	}
+	// Replaces the previous dry run; its rows are deleted with it
+	a.DB.Unscoped().Where("campaign_id = ?", campaign.ID).Delete(&models.CampaignDryRun{})
+	if err := a.DB.Create(&dryRun).Error; err != nil {
+		a.Log.Error("Failed to create dry run", "error", err, "campaign_id", campaign.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue dry run", nil, "")
+	}
+	if tracker, err := a.startJob(r, campaign.OrganizationID, models.JobTypeCampaignDryRun, &dryRun.ID); err == nil {
+		jobID := tracker.JobID()
+		dryRun.JobID = &jobID
+		a.DB.Model(&dryRun).Update("job_id", jobID)
+	} else {
+		a.Log.Error("Failed to record dry run job", "error", err, "dry_run_id", dryRun.ID)
+	}
+
+	if err := a.Queue.EnqueueCampaignDryRun(r.RequestCtx, campaign.ID, dryRun.ID, queue.Priority(campaign.Priority)); err != nil {
+		a.Log.Error("Failed to enqueue dry run", "error", err, "campaign_id", campaign.ID)
+		a.DB.Model(&dryRun).Updates(map[string]interface{}{
+			"status": "failed",
+			"error":  "Failed to queue dry run",
+		})
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue dry run", nil, "")
+	}
+
+	a.Log.Info("Campaign dry run queued", "campaign_id", campaign.ID, "dry_run_id", dryRun.ID, "recipients", pending)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":    "Dry run queued",
+		"status":     "queued",
+		"dry_run_id": dryRun.ID,
+		"job_id":     dryRun.JobID,
+		"warnings":   dryRun.Warnings,
+	})
+}
+
+// dryRunWarnings returns why starting the campaign now would be refused or held up
+func (a *App) dryRunWarnings(campaign *models.BulkMessageCampaign) models.StringArray {
+	warnings := models.StringArray{}
+	if !(campaign.Status == "draft" && campaignScheduledAhead(campaign)) {
+		if blackout := a.campaignBlackout(campaign.OrganizationID); blackout != nil {
+			warnings = append(warnings, "Campaigns are blocked today: "+blackout.Name)
+		}
+	}
+	for _, v := range a.campaignVariants(campaign.ID) {
+		if v.Template == nil || !strings.EqualFold(v.Template.Status, "APPROVED") {
+			warnings = append(warnings, fmt.Sprintf("Variant %s's template must be approved before the campaign starts", v.Name))
+		}
+	}
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaign.TemplateID, campaign.OrganizationID).First(&template).Error; err == nil &&
+		!strings.EqualFold(template.Status, "APPROVED") {
+		warnings = append(warnings, fmt.Sprintf("Template %s isn't approved; starting the campaign waits for Meta to approve it", template.Name))
+	}
+	return warnings
+}
+
+// GetCampaignDryRun returns the campaign's latest dry run with a page of its rows, in
+// send order. ?outcome= lists only the recipients with that outcome: send, defer, skip or fail.
+func (a *App) GetCampaignDryRun(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var dryRun models.CampaignDryRun
+	if err := a.DB.Where("campaign_id = ? AND organization_id = ?", id, orgID).Order("created_at DESC").First(&dryRun).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign has no dry run", nil, "")
+	}
+
+	args := r.RequestCtx.QueryArgs()
+	page, _ := strconv.Atoi(string(args.Peek("page")))
+	limit, _ := strconv.Atoi(string(args.Peek("limit")))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	query := a.DB.Model(&models.CampaignDryRunRow{}).Where("dry_run_id = ?", dryRun.ID)
+	if outcome := string(args.Peek("outcome")); outcome != "" {
+		query = query.Where("outcome = ?", outcome)
+	}
+	var total int64
+	query.Count(&total)
+
+	rows := []models.CampaignDryRunRow{}
+	if err := query.Order("id").Offset((page - 1) * limit).Limit(limit).Find(&rows).Error; err != nil {
+		a.Log.Error("Failed to load dry run rows", "error", err, "dry_run_id", dryRun.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load dry run", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"dry_run":    dryRun,
+		"rows":       rows,
+		"rows_total": total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
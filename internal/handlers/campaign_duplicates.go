@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// Duplicate campaign check modes
+const (
+	DuplicateCampaignOff     = "off"
+	DuplicateCampaignConfirm = "confirm" // Starting a duplicate needs confirm=true
+	DuplicateCampaignBlock   = "block"
+)
+
+const (
+	defaultDuplicateCampaignWindowHours = 24
+	defaultDuplicateCampaignOverlap     = 80
+)
+
+// sentCampaignStatuses are the statuses of campaigns that have sent or will send
+// without being started again
+var sentCampaignStatuses = []string{"scheduled", "pending_template", "queued", "processing", "held", "paused", "completed"}
+
+// DuplicateCampaign is a recent campaign that sent the same template to much of the
+// same audience
+type DuplicateCampaign struct {
+	CampaignID       uuid.UUID  `json:"campaign_id"`
+	Name             string     `json:"name"`
+	Status           string     `json:"status"`
+	StartedAt        *time.Time `json:"started_at,omitempty"`
+	SharedRecipients int64      `json:"shared_recipients"`
+	OverlapPercent   float64    `json:"overlap_percent"` // Of the new campaign's recipients
+}
+
+// checkDuplicateCampaign stops a campaign from starting when another campaign sent
+// the same template to most of its recipients within the organization's window, e.g.
+// after an accidental double launch. In confirm mode the request can pass
+// confirm=true to start it anyway. It returns true when it has sent the conflict
+// response.
+func (a *App) checkDuplicateCampaign(r *fastglue.Request, campaign *models.BulkMessageCampaign) (bool, error) {
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", campaign.OrganizationID).First(&org).Error; err != nil {
+		return false, nil
+	}
+	settings := organizationSettings(&org)
+	mode := settings.DuplicateCampaignCheck
+	if mode == DuplicateCampaignOff {
+		return false, nil
+	}
+	if mode != DuplicateCampaignBlock && string(r.RequestCtx.QueryArgs().Peek("confirm")) == "true" {
+		return false, nil
+	}
+
+	window := time.Duration(settings.DuplicateCampaignWindowHours) * time.Hour
+	duplicates, err := a.findDuplicateCampaigns(campaign, window, float64(settings.DuplicateCampaignOverlap))
+	if err != nil {
+		a.Log.Error("Failed to check for duplicate campaigns", "error", err, "campaign_id", campaign.ID)
+		return true, r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to check for duplicate campaigns", nil, "")
+	}
+	if len(duplicates) == 0 {
+		return false, nil
+	}
+
+	d := duplicates[0]
+	msg := fmt.Sprintf("Campaign %q already sent this template to %.0f%% of these recipients in the last %d hours",
+		d.Name, d.OverlapPercent, settings.DuplicateCampaignWindowHours)
+	a.Log.Warn("Duplicate campaign launch",
+		"campaign_id", campaign.ID,
+		"duplicate_of", d.CampaignID,
+		"overlap_percent", d.OverlapPercent,
+		"mode", mode,
+	)
+	return true, r.SendErrorEnvelope(fasthttp.StatusConflict, msg, map[string]interface{}{
+		"mode":       mode,
+		"duplicates": duplicates,
+	}, "")
+}
+
+// findDuplicateCampaigns returns the campaigns started within window that sent the
+// campaign's template to at least minOverlap percent of its recipients, most
+// overlapping first. Test numbers are left out of the overlap.
+func (a *App) findDuplicateCampaigns(campaign *models.BulkMessageCampaign, window time.Duration, minOverlap float64) ([]DuplicateCampaign, error) {
+	var total int64
+	if err := a.DB.Raw(`
+		SELECT COUNT(DISTINCT TRIM(LEADING '+' FROM r.phone_number)) FROM bulk_message_recipients r
+		WHERE r.campaign_id = ? AND r.deleted_at IS NULL AND NOT `+recipientIsTestNumber,
+		campaign.ID, campaign.OrganizationID,
+	).Scan(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count recipients: %w", err)
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	var rows []struct {
+		ID        uuid.UUID
+		Name      string
+		Status    string
+		StartedAt *time.Time
+		Shared    int64
+	}
+	if err := a.DB.Raw(`
+		SELECT c.id, c.name, c.status, c.started_at, COUNT(DISTINCT TRIM(LEADING '+' FROM o.phone_number)) AS shared
+		FROM bulk_message_campaigns c
+		JOIN bulk_message_recipients o ON o.campaign_id = c.id
+		WHERE c.organization_id = ? AND c.id <> ? AND c.template_id = ? AND c.deleted_at IS NULL
+			AND c.status IN ? AND COALESCE(c.started_at, c.updated_at) >= ?
+			AND TRIM(LEADING '+' FROM o.phone_number) IN (
+				SELECT TRIM(LEADING '+' FROM r.phone_number) FROM bulk_message_recipients r
+				WHERE r.campaign_id = ? AND NOT `+recipientIsTestNumber+`
+			)
+		GROUP BY c.id, c.name, c.status, c.started_at
+		ORDER BY shared DESC`,
+		campaign.OrganizationID, campaign.ID, campaign.TemplateID,
+		sentCampaignStatuses, time.Now().Add(-window), campaign.ID, campaign.OrganizationID,
+	).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to compare recipients: %w", err)
+	}
+
+	var duplicates []DuplicateCampaign
+	for _, row := range rows {
+		overlap := 100 * float64(row.Shared) / float64(total)
+		if overlap < minOverlap {
+			continue
+		}
+		duplicates = append(duplicates, DuplicateCampaign{
+			CampaignID:       row.ID,
+			Name:             row.Name,
+			Status:           row.Status,
+			StartedAt:        row.StartedAt,
+			SharedRecipients: row.Shared,
+			OverlapPercent:   float64(int(overlap*10)) / 10,
+		})
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/pricing"
+	"github.com/shridarpatil/whatomate/pkg/phonenumber"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// CampaignEstimateLine is the estimated charge for a campaign's messages of one
+// category to one country
+type CampaignEstimateLine struct {
+	Country    string  `json:"country"` // ISO 3166-1 alpha-2; empty for numbers not tied to a country
+	Market     string  `json:"market"`
+	Category   string  `json:"category"`
+	Recipients int64   `json:"recipients"`
+	Rate       float64 `json:"rate"`
+	Cost       float64 `json:"cost"`
+}
+
+// CampaignEstimate is what Meta is expected to charge for a campaign's pending messages
+type CampaignEstimate struct {
+	Currency   string                 `json:"currency"`
+	Total      float64                `json:"total"`
+	Recipients int64                  `json:"recipients"` // Pending recipients that were priced
+	OptedOut   int64                  `json:"opted_out"`  // Pending recipients who opted out and won't be sent to
+	Invalid    int64                  `json:"invalid"`    // Pending recipients with numbers that can't be delivered to
+	Lines      []CampaignEstimateLine `json:"lines"`
+}
+
+// EstimateCampaign estimates the Meta charges for a campaign's pending recipients from
+// the category of the template each is sent, their destination country and the rate
+// card, so the spend can be confirmed before the campaign is started. It assumes every
+// message is delivered and charged; Meta only bills delivered messages.
+func (a *App) EstimateCampaign(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Template").
+		First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+	if campaign.Template == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign has no template", nil, "")
+	}
+	variants := a.campaignVariants(campaign.ID)
+
+	card := a.Pricing
+	if card == nil {
+		card = pricing.Default()
+	}
+
+	rows, err := a.DB.Raw(`
+		SELECT r.phone_number, `+recipientOptedOut+` AS opted_out
+		FROM bulk_message_recipients r
+		WHERE r.campaign_id = ? AND r.status = 'pending' AND r.deleted_at IS NULL`,
+		orgID, engagement.OptOutKeywords, campaign.ID).Rows()
+	if err != nil {
+		a.Log.Error("Failed to load campaign recipients", "error", err, "campaign_id", campaign.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to estimate campaign cost", nil, "")
+	}
+	defer rows.Close()
+
+	type lineKey struct{ country, category string }
+	lines := map[lineKey]*CampaignEstimateLine{}
+	estimate := CampaignEstimate{Currency: card.Currency}
+	for rows.Next() {
+		var (
+			phone    string
+			optedOut bool
+		)
+		if err := rows.Scan(&phone, &optedOut); err != nil {
+			a.Log.Error("Failed to read campaign recipient", "error", err, "campaign_id", campaign.ID)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to estimate campaign cost", nil, "")
+		}
+		if optedOut {
+			estimate.OptedOut++
+			continue
+		}
+		number, err := phonenumber.Parse(phone, "")
+		if err != nil {
+			estimate.Invalid++
+			continue
+		}
+
+		template := campaign.Template
+		if variant := models.PickCampaignVariant(campaign.ID, phone, variants); variant != nil && variant.Template != nil {
+			template = variant.Template
+		}
+		key := lineKey{number.Country, pricing.Category(template.Category)}
+		line, ok := lines[key]
+		if !ok {
+			market, rate := card.Rate(key.country, key.category)
+			line = &CampaignEstimateLine{Country: key.country, Market: market, Category: key.category, Rate: rate}
+			lines[key] = line
+		}
+		line.Recipients++
+		estimate.Recipients++
+	}
+	if err := rows.Err(); err != nil {
+		a.Log.Error("Failed to read campaign recipients", "error", err, "campaign_id", campaign.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to estimate campaign cost", nil, "")
+	}
+
+	estimate.Lines = make([]CampaignEstimateLine, 0, len(lines))
+	for _, line := range lines {
+		line.Cost = roundCost(line.Rate * float64(line.Recipients))
+		estimate.Total += line.Rate * float64(line.Recipients)
+		estimate.Lines = append(estimate.Lines, *line)
+	}
+	estimate.Total = roundCost(estimate.Total)
+	sort.Slice(estimate.Lines, func(i, j int) bool {
+		if estimate.Lines[i].Cost != estimate.Lines[j].Cost {
+			return estimate.Lines[i].Cost > estimate.Lines[j].Cost
+		}
+		if estimate.Lines[i].Country != estimate.Lines[j].Country {
+			return estimate.Lines[i].Country < estimate.Lines[j].Country
+		}
+		return estimate.Lines[i].Category < estimate.Lines[j].Category
+	})
+
+	return r.SendEnvelope(estimate)
+}
+
+// roundCost rounds a cost to the four decimals rate cards are quoted in
+func roundCost(cost float64) float64 {
+	return math.Round(cost*10000) / 10000
+}
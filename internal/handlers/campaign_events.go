@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+)
+
+// campaignEventClaimPrefix marks worker events already handled by one server instance
+const campaignEventClaimPrefix = "campaign_event:"
+
+// workerCampaignEvents maps worker lifecycle events to webhook event types
+var workerCampaignEvents = map[string]string{
+	"queued":    EventCampaignQueued,
+	"started":   EventCampaignStarted,
+	"paused":    EventCampaignPaused,
+	"held":      EventCampaignHeld,
+	"completed": EventCampaignCompleted,
+	"failed":    EventCampaignFailed,
+}
+
+// workerRecipientEvents maps worker recipient events to webhook event types
+var workerRecipientEvents = map[string]string{
+	"recipient_sent":   EventRecipientSent,
+	"recipient_failed": EventRecipientFailed,
+}
+
+// recipientStatusEvents maps the statuses Meta reports for campaign messages to
+// webhook event types
+var recipientStatusEvents = map[string]string{
+	"delivered": EventRecipientDelivered,
+	"read":      EventRecipientRead,
+	"failed":    EventRecipientFailed,
+}
+
+// dispatchCampaignEvent sends a campaign lifecycle webhook with the campaign's current stats
+func (a *App) dispatchCampaignEvent(orgID, campaignID uuid.UUID, eventType, errMsg string) {
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaignID, orgID).Preload("Template").First(&campaign).Error; err != nil {
+		a.Log.Error("Failed to load campaign for webhook", "error", err, "campaign_id", campaignID, "event", eventType)
+		return
+	}
+
+	data := CampaignEventData{
+		CampaignID:      campaign.ID.String(),
+		Name:            campaign.Name,
+		Status:          campaign.Status,
+		WhatsAppAccount: campaign.WhatsAppAccount,
+		TemplateID:      campaign.TemplateID.String(),
+		TotalRecipients: campaign.TotalRecipients,
+		SentCount:       campaign.SentCount,
+		DeliveredCount:  campaign.DeliveredCount,
+		ReadCount:       campaign.ReadCount,
+		FailedCount:     campaign.FailedCount,
+		StartedAt:       campaign.StartedAt,
+		CompletedAt:     campaign.CompletedAt,
+		Error:           errMsg,
+	}
+	if campaign.Template != nil {
+		data.TemplateName = campaign.Template.Name
+	}
+
+	a.DispatchWebhook(orgID, eventType, data)
+
+	// The campaign's webhooks are unsubscribed once the receipts of its last messages are in
+	if eventType == EventCampaignCompleted || eventType == EventCampaignFailed {
+		a.expireCampaignWebhooks(orgID, campaignID)
+	}
+}
+
+// dispatchRecipientStatus sends the recipient event for a status Meta reported on a
+// campaign's message, when the campaign has webhooks
+func (a *App) dispatchRecipientStatus(message *models.Message, campaignID, status, errMsg string) {
+	eventType, ok := recipientStatusEvents[status]
+	if !ok || !a.hasCampaignWebhooks(message.OrganizationID, campaignID) {
+		return
+	}
+
+	recipientID, _ := message.Metadata["recipient_id"].(string)
+	var recipient models.BulkMessageRecipient
+	if err := a.DB.Where("id = ? AND campaign_id = ?", recipientID, campaignID).First(&recipient).Error; err != nil {
+		return
+	}
+	variant, _ := message.Metadata["campaign_variant"].(string)
+
+	a.DispatchWebhook(message.OrganizationID, eventType, RecipientEventData{
+		CampaignID:        campaignID,
+		RecipientID:       recipient.ID.String(),
+		PhoneNumber:       recipient.PhoneNumber,
+		RecipientName:     recipient.RecipientName,
+		Status:            status,
+		Variant:           variant,
+		MessageID:         message.ID.String(),
+		WhatsAppMessageID: message.WhatsAppMessageID,
+		Error:             errMsg,
+	})
+}
+
+// recordRecipientFailure fails the recipient a campaign message was sent to when Meta
+// reports the message failed, with the failure's code and category
+func (a *App) recordRecipientFailure(message *models.Message, campaignID string, code int, errMsg string) {
+	recipientID, _ := message.Metadata["recipient_id"].(string)
+	if recipientID == "" {
+		return
+	}
+	if err := a.DB.Model(&models.BulkMessageRecipient{}).
+		Where("id = ? AND campaign_id = ?", recipientID, campaignID).
+		Updates(map[string]interface{}{
+			"status":         "failed",
+			"error_message":  errMsg,
+			"error_code":     code,
+			"error_category": whatsapp.FailureCategory(code),
+		}).Error; err != nil {
+		a.Log.Error("Failed to record campaign recipient failure", "error", err, "recipient_id", recipientID)
+	}
+}
+
+// hasCampaignWebhooks reports whether the campaign has webhooks its events are sent to
+func (a *App) hasCampaignWebhooks(orgID uuid.UUID, campaignID string) bool {
+	webhooks, err := a.getWebhooksCached(orgID)
+	if err != nil {
+		return false
+	}
+	now := time.Now()
+	for _, webhook := range webhooks {
+		if webhook.CampaignID != nil && webhook.CampaignID.String() == campaignID && !webhook.Expired(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// signalCampaignStopped tells workers the campaign was paused or cancelled, so the run
+// sending it stops before its next recipient
+func (a *App) signalCampaignStopped(campaignID uuid.UUID, status string) {
+	queue.NewPublisher(a.Redis, a.Log).PublishCampaignControl(context.Background(), &queue.CampaignControl{
+		CampaignID: campaignID.String(),
+		Status:     status,
+	})
+}
+
+// StartCampaignEventsSubscriber listens for lifecycle events published by workers and
+// forwards them to outbound webhooks. Each event is claimed in Redis first so that only
+// one server instance delivers it.
+func (a *App) StartCampaignEventsSubscriber() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.CampaignEventsCancel = cancel
+
+	subscriber := queue.NewSubscriber(a.Redis, a.Log)
+
+	err := subscriber.SubscribeCampaignEvents(ctx, func(event *queue.CampaignEvent) {
+		eventType, ok := workerCampaignEvents[event.Event]
+		if event.Recipient != nil {
+			eventType, ok = workerRecipientEvents[event.Event]
+		}
+		if !ok {
+			return
+		}
+		campaignID, err := uuid.Parse(event.CampaignID)
+		if err != nil {
+			return
+		}
+
+		claimed, err := a.Redis.SetNX(ctx, campaignEventClaimPrefix+event.ID, 1, time.Hour).Result()
+		if err != nil || !claimed {
+			return
+		}
+
+		if recipient := event.Recipient; recipient != nil {
+			a.DispatchWebhook(event.OrganizationID, eventType, RecipientEventData{
+				CampaignID:        campaignID.String(),
+				RecipientID:       recipient.RecipientID,
+				PhoneNumber:       recipient.PhoneNumber,
+				RecipientName:     recipient.RecipientName,
+				Status:            recipient.Status,
+				Variant:           recipient.Variant,
+				MessageID:         recipient.MessageID,
+				WhatsAppMessageID: recipient.WhatsAppMessageID,
+				Error:             recipient.Error,
+			})
+			return
+		}
+		a.dispatchCampaignEvent(event.OrganizationID, campaignID, eventType, event.Error)
+	})
+
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	a.Log.Info("Campaign events subscriber started")
+	return nil
+}
+
+// StopCampaignEventsSubscriber stops the campaign events subscriber
+func (a *App) StopCampaignEventsSubscriber() {
+	if a.CampaignEventsCancel != nil {
+		a.CampaignEventsCancel()
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// deadJobScanLimit bounds how many dead jobs, across organizations, are read to
+// list an organization's
+const deadJobScanLimit = 1000
+
+// deadJobIDPattern matches a Redis stream entry ID, which dead jobs are identified by
+var deadJobIDPattern = regexp.MustCompile(`^\d+-\d+$`)
+
+// DeadCampaignJob is a dead campaign job with the campaign it was for
+type DeadCampaignJob struct {
+	queue.DeadJob
+	CampaignName   string `json:"campaign_name"`
+	CampaignStatus string `json:"campaign_status"`
+}
+
+// ListDeadCampaignJobs lists the organization's campaign jobs that failed too many
+// times to be retried automatically, newest first
+func (a *App) ListDeadCampaignJobs(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if a.Queue == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusServiceUnavailable, "Job queue is not configured", nil, "")
+	}
+
+	jobs, err := a.Queue.ListDeadJobs(r.RequestCtx, deadJobScanLimit)
+	if err != nil {
+		a.Log.Error("Failed to list dead campaign jobs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list dead jobs", nil, "")
+	}
+
+	campaignIDs := make([]uuid.UUID, 0, len(jobs))
+	for _, job := range jobs {
+		if job.CampaignID != uuid.Nil {
+			campaignIDs = append(campaignIDs, job.CampaignID)
+		}
+	}
+	var campaigns []models.BulkMessageCampaign
+	if len(campaignIDs) > 0 {
+		if err := a.DB.Select("id", "name", "status").
+			Where("organization_id = ? AND id IN ?", orgID, campaignIDs).
+			Find(&campaigns).Error; err != nil {
+			a.Log.Error("Failed to load campaigns of dead jobs", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list dead jobs", nil, "")
+		}
+	}
+	byID := make(map[uuid.UUID]*models.BulkMessageCampaign, len(campaigns))
+	for i := range campaigns {
+		byID[campaigns[i].ID] = &campaigns[i]
+	}
+
+	// Jobs for other organizations' campaigns, or whose campaign is gone, aren't shown
+	result := []DeadCampaignJob{}
+	for _, job := range jobs {
+		campaign, ok := byID[job.CampaignID]
+		if !ok {
+			continue
+		}
+		result = append(result, DeadCampaignJob{
+			DeadJob:        job,
+			CampaignName:   campaign.Name,
+			CampaignStatus: campaign.Status,
+		})
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"jobs": result,
+	})
+}
+
+// RequeueDeadCampaignJob puts a dead campaign job back on the queue with its
+// attempts reset. A campaign the failures marked failed is queued again.
+func (a *App) RequeueDeadCampaignJob(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	if a.Queue == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusServiceUnavailable, "Job queue is not configured", nil, "")
+	}
+	job, campaign, err := a.findDeadCampaignJob(r, orgID)
+	if err != nil {
+		return a.sendDeadJobLookupError(r, err)
+	}
+
+	if campaign.Status == "failed" {
+		if err := a.DB.Model(campaign).Update("status", "queued").Error; err != nil {
+			a.Log.Error("Failed to queue campaign", "error", err, "campaign_id", campaign.ID)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to requeue job", nil, "")
+		}
+		campaign.Status = "queued"
+	}
+
+	if err := a.Queue.RequeueDeadJob(r.RequestCtx, job.ID); err != nil {
+		if errors.Is(err, queue.ErrDeadJobNotFound) {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Dead job not found", nil, "")
+		}
+		a.Log.Error("Failed to requeue dead campaign job", "error", err, "dead_job_id", job.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to requeue job", nil, "")
+	}
+
+	a.Log.Info("Dead campaign job requeued", "dead_job_id", job.ID, "campaign_id", campaign.ID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":     "Job requeued",
+		"campaign_id": campaign.ID,
+		"status":      campaign.Status,
+	})
+}
+
+// DeleteDeadCampaignJob discards a dead campaign job
+func (a *App) DeleteDeadCampaignJob(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	if a.Queue == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusServiceUnavailable, "Job queue is not configured", nil, "")
+	}
+	job, _, err := a.findDeadCampaignJob(r, orgID)
+	if err != nil {
+		return a.sendDeadJobLookupError(r, err)
+	}
+
+	if err := a.Queue.DeleteDeadJob(r.RequestCtx, job.ID); err != nil && !errors.Is(err, queue.ErrDeadJobNotFound) {
+		a.Log.Error("Failed to delete dead campaign job", "error", err, "dead_job_id", job.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete job", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message": "Job deleted",
+	})
+}
+
+// findDeadCampaignJob loads the dead job in the path and its campaign, which must
+// belong to the organization
+func (a *App) findDeadCampaignJob(r *fastglue.Request, orgID uuid.UUID) (*queue.DeadJob, *models.BulkMessageCampaign, error) {
+	id, _ := r.RequestCtx.UserValue("id").(string)
+	if !deadJobIDPattern.MatchString(id) {
+		return nil, nil, queue.ErrDeadJobNotFound
+	}
+
+	job, err := a.Queue.GetDeadJob(r.RequestCtx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", job.CampaignID, orgID).First(&campaign).Error; err != nil {
+		return nil, nil, err
+	}
+	return job, &campaign, nil
+}
+
+// sendDeadJobLookupError responds to a failed findDeadCampaignJob
+func (a *App) sendDeadJobLookupError(r *fastglue.Request, err error) error {
+	if errors.Is(err, queue.ErrDeadJobNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Dead job not found", nil, "")
+	}
+	a.Log.Error("Failed to load dead campaign job", "error", err)
+	return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load dead job", nil, "")
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// campaignPreviewSamples is how many rendered messages a preview returns
+	campaignPreviewSamples = 10
+
+	// A recipient is frequency capped when another campaign messaged them within
+	// recentCampaignWindow, or Meta rejected a campaign message to them for its
+	// per-user marketing limit within marketingLimitWindow
+	recentCampaignWindow = 24 * time.Hour
+	marketingLimitWindow = 7 * 24 * time.Hour
+	marketingLimitError  = "131049"
+)
+
+// recipientOptedOut matches pending recipients (aliased r) whose contact replied
+// with an opt-out keyword; its arguments are the organization ID and
+// engagement.OptOutKeywords
+const recipientOptedOut = `EXISTS (SELECT 1 FROM contacts WHERE contacts.organization_id = ? AND contacts.deleted_at IS NULL
+	AND TRIM(LEADING '+' FROM contacts.phone_number) = TRIM(LEADING '+' FROM r.phone_number)
+	AND NOT (` + contactNotOptedOut + `))`
+
+// recipientFrequencyCapped matches recipients (aliased r) who are likely to be
+// frequency capped. Test numbers never are. Its arguments are the organization ID,
+// the campaign ID, the start of recentCampaignWindow, the marketing limit error
+// pattern, the start of marketingLimitWindow and the organization ID again.
+const recipientFrequencyCapped = `(EXISTS (SELECT 1 FROM contacts JOIN messages m ON m.contact_id = contacts.id
+	WHERE contacts.organization_id = ? AND contacts.deleted_at IS NULL
+	AND TRIM(LEADING '+' FROM contacts.phone_number) = TRIM(LEADING '+' FROM r.phone_number)
+	AND m.direction = 'outgoing' AND m.deleted_at IS NULL AND m.metadata->>'campaign_id' <> ?
+	AND ((m.status <> 'failed' AND m.created_at >= ?)
+		OR (m.status = 'failed' AND m.error_message LIKE ? AND m.created_at >= ?)))
+	AND NOT ` + recipientIsTestNumber + `)`
+
+// CampaignPreviewAudience counts who a campaign will reach
+type CampaignPreviewAudience struct {
+	Total           int64 `json:"total"`
+	Pending         int64 `json:"pending"`
+	WillSend        int64 `json:"will_send"`      // Pending recipients not skipped for missing parameters
+	MissingParams   int64 `json:"missing_params"` // Pending recipients missing a template parameter
+	OptedOut        int64 `json:"opted_out"`
+	FrequencyCapped int64 `json:"frequency_capped"`
+}
+
+// CampaignPreviewSample is a pending recipient's message as it would be sent
+type CampaignPreviewSample struct {
+	RecipientID     uuid.UUID    `json:"recipient_id"`
+	PhoneNumber     string       `json:"phone_number"`
+	RecipientName   string       `json:"recipient_name"`
+	ContactName     string       `json:"contact_name,omitempty"`
+	TemplateParams  models.JSONB `json:"template_params"`
+	Header          string       `json:"header,omitempty"`
+	Body            string       `json:"body"`
+	Footer          string       `json:"footer,omitempty"`
+	MissingParams   []string     `json:"missing_params,omitempty"`
+	WillSend        bool         `json:"will_send"`
+	OptedOut        bool         `json:"opted_out"`
+	FrequencyCapped bool         `json:"frequency_capped"`
+}
+
+// CampaignPreview is what a campaign would send if it were started now
+type CampaignPreview struct {
+	Audience CampaignPreviewAudience `json:"audience"`
+	Samples  []CampaignPreviewSample `json:"samples"`
+	Warnings []string                `json:"warnings"`
+}
+
+// PreviewCampaign returns a campaign's audience size, sample messages rendered with
+// recipients' parameters and contact data, and warnings to review before starting it
+func (a *App) PreviewCampaign(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Template").
+		First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+	if campaign.Template == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign has no template", nil, "")
+	}
+
+	now := time.Now()
+	optedOutArgs := []interface{}{orgID, engagement.OptOutKeywords}
+	cappedArgs := []interface{}{orgID, campaign.ID.String(), now.Add(-recentCampaignWindow),
+		"%" + marketingLimitError + "%", now.Add(-marketingLimitWindow), orgID}
+	sources := models.TemplateParamSources(a.orgSettings(orgID), campaign.Template)
+	missingSQL, missingArgs := missingParamsCondition(&campaign, sources)
+
+	var args []interface{}
+	args = append(args, missingArgs...)
+	args = append(args, optedOutArgs...)
+	args = append(args, cappedArgs...)
+	args = append(args, campaign.ID)
+
+	var audience CampaignPreviewAudience
+	if err := a.DB.Raw(`
+		SELECT COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE r.status = 'pending') AS pending,
+			COUNT(*) FILTER (WHERE r.status = 'pending' AND (`+missingSQL+`)) AS missing_params,
+			COUNT(*) FILTER (WHERE r.status = 'pending' AND `+recipientOptedOut+`) AS opted_out,
+			COUNT(*) FILTER (WHERE r.status = 'pending' AND `+recipientFrequencyCapped+`) AS frequency_capped
+		FROM bulk_message_recipients r
+		WHERE r.campaign_id = ? AND r.deleted_at IS NULL`, args...).
+		Scan(&audience).Error; err != nil {
+		a.Log.Error("Failed to count campaign audience", "error", err, "campaign_id", campaign.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to preview campaign", nil, "")
+	}
+	audience.WillSend = audience.Pending
+	if skipsMissingParams(&campaign) {
+		audience.WillSend -= audience.MissingParams
+	}
+
+	args = append(append([]interface{}{orgID, orgID}, optedOutArgs...), cappedArgs...)
+	args = append(args, campaign.ID, campaignPreviewSamples)
+
+	var rows []struct {
+		ID              uuid.UUID
+		PhoneNumber     string
+		RecipientName   string
+		TemplateParams  models.JSONB
+		ContactID       *uuid.UUID
+		ContactName     string
+		OptedOut        bool
+		FrequencyCapped bool
+	}
+	if err := a.DB.Raw(`
+		SELECT r.id, r.phone_number, r.recipient_name, r.template_params,
+			(SELECT contacts.id FROM contacts WHERE contacts.organization_id = ? AND contacts.deleted_at IS NULL
+				AND TRIM(LEADING '+' FROM contacts.phone_number) = TRIM(LEADING '+' FROM r.phone_number) LIMIT 1) AS contact_id,
+			COALESCE((SELECT contacts.profile_name FROM contacts WHERE contacts.organization_id = ? AND contacts.deleted_at IS NULL
+				AND TRIM(LEADING '+' FROM contacts.phone_number) = TRIM(LEADING '+' FROM r.phone_number) LIMIT 1), '') AS contact_name,
+			`+recipientOptedOut+` AS opted_out,
+			`+recipientFrequencyCapped+` AS frequency_capped
+		FROM bulk_message_recipients r
+		WHERE r.campaign_id = ? AND r.status = 'pending' AND r.deleted_at IS NULL
+		ORDER BY random()
+		LIMIT ?`, args...).
+		Scan(&rows).Error; err != nil {
+		a.Log.Error("Failed to sample campaign recipients", "error", err, "campaign_id", campaign.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to preview campaign", nil, "")
+	}
+
+	// Contacts of the samples, for the parameters filled from contact fields
+	contacts := map[uuid.UUID]*models.Contact{}
+	if len(sources) > 0 {
+		var contactIDs []uuid.UUID
+		for _, row := range rows {
+			if row.ContactID != nil {
+				contactIDs = append(contactIDs, *row.ContactID)
+			}
+		}
+		var found []models.Contact
+		if len(contactIDs) > 0 {
+			if err := a.DB.Where("id IN ?", contactIDs).Find(&found).Error; err != nil {
+				a.Log.Error("Failed to load sample contacts", "error", err, "campaign_id", campaign.ID)
+			}
+		}
+		for i := range found {
+			contacts[found[i].ID] = &found[i]
+		}
+	}
+
+	samples := make([]CampaignPreviewSample, 0, len(rows))
+	for _, row := range rows {
+		params := row.TemplateParams
+		if row.ContactID != nil && contacts[*row.ContactID] != nil {
+			params = models.FillTemplateParams(campaign.Template, params, sources, models.ContactParamFields(contacts[*row.ContactID]))
+		}
+		params, missing := campaign.ResolveTemplateParams(campaign.Template, params)
+		if !skipsMissingParams(&campaign) {
+			missing = missingTemplateParams(campaign.Template, params)
+		}
+		sample := CampaignPreviewSample{
+			RecipientID:     row.ID,
+			PhoneNumber:     row.PhoneNumber,
+			RecipientName:   row.RecipientName,
+			ContactName:     row.ContactName,
+			TemplateParams:  params,
+			Body:            renderTemplateText(campaign.Template.BodyContent, params),
+			Footer:          campaign.Template.FooterContent,
+			MissingParams:   missing,
+			WillSend:        len(missing) == 0 || !skipsMissingParams(&campaign),
+			OptedOut:        row.OptedOut,
+			FrequencyCapped: row.FrequencyCapped,
+		}
+		if strings.EqualFold(campaign.Template.HeaderType, "TEXT") {
+			sample.Header = campaign.Template.HeaderContent
+		}
+		samples = append(samples, sample)
+	}
+
+	return r.SendEnvelope(CampaignPreview{
+		Audience: audience,
+		Samples:  samples,
+		Warnings: a.campaignPreviewWarnings(&campaign, &audience),
+	})
+}
+
+// campaignPreviewWarnings lists what would keep the campaign from reaching its
+// audience as expected
+func (a *App) campaignPreviewWarnings(campaign *models.BulkMessageCampaign, audience *CampaignPreviewAudience) []string {
+	warnings := []string{}
+	if audience.Pending == 0 {
+		warnings = append(warnings, "Campaign has no pending recipients")
+	}
+	if campaign.Template.Status != "APPROVED" {
+		warnings = append(warnings, fmt.Sprintf("Template %q is %s; the campaign won't send until it is approved",
+			campaign.Template.Name, strings.ToLower(campaign.Template.Status)))
+	}
+	if audience.MissingParams > 0 {
+		if skipsMissingParams(campaign) {
+			warnings = append(warnings, fmt.Sprintf("%d recipients are missing template parameters and will be skipped", audience.MissingParams))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%d recipients are missing template parameters and will be sent with them left out, which Meta may reject", audience.MissingParams))
+		}
+	}
+	if audience.OptedOut > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d recipients have opted out; skip them before starting", audience.OptedOut))
+	}
+	if audience.FrequencyCapped > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d recipients received another campaign in the last %d hours or recently hit Meta's marketing message limit, and may not receive this one",
+			audience.FrequencyCapped, int(recentCampaignWindow.Hours())))
+	}
+	if hold := a.campaignHold(campaign.OrganizationID); hold != nil {
+		warnings = append(warnings, fmt.Sprintf("Campaigns are held now (%s); the campaign will wait until %s before sending",
+			hold.Reason, hold.Until.Format(time.RFC3339)))
+	}
+	return warnings
+}
+
+// skipsMissingParams reports whether the campaign skips recipients missing template
+// parameters rather than sending to them anyway
+func skipsMissingParams(campaign *models.BulkMessageCampaign) bool {
+	return campaign.MissingParamPolicy == models.MissingParamSkip || campaign.MissingParamPolicy == models.MissingParamDefault
+}
+
+// missingParamsCondition matches recipients (aliased r) missing a parameter of the
+// campaign's template that the campaign has no default for, and that their contact
+// can't fill either when the parameter has a source in sources
+func missingParamsCondition(campaign *models.BulkMessageCampaign, sources map[string]string) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	for _, key := range models.TemplateBodyParams(campaign.Template.BodyContent) {
+		if campaign.MissingParamPolicy == models.MissingParamDefault && hasParam(campaign.ParamDefaults, key) {
+			continue
+		}
+		field, ok := sources[key]
+		if !ok {
+			conds = append(conds, "COALESCE(TRIM(r.template_params->>?), '') = ''")
+			args = append(args, key)
+			continue
+		}
+		fieldSQL, fieldArgs := contactFieldSQL(field)
+		conds = append(conds, `(COALESCE(TRIM(r.template_params->>?), '') = '' AND NOT EXISTS (SELECT 1 FROM contacts c
+			WHERE c.organization_id = ? AND c.deleted_at IS NULL
+			AND TRIM(LEADING '+' FROM c.phone_number) = TRIM(LEADING '+' FROM r.phone_number)
+			AND COALESCE(TRIM(`+fieldSQL+`), '') <> ''))`)
+		args = append(args, key, campaign.OrganizationID)
+		args = append(args, fieldArgs...)
+	}
+	if len(conds) == 0 {
+		return "false", nil
+	}
+	return strings.Join(conds, " OR "), args
+}
+
+// contactFieldSQL returns the SQL for a contact field (of contacts aliased c) a
+// parameter can be filled from, as models.ContactParamFields reads it
+func contactFieldSQL(field string) (string, []interface{}) {
+	switch field {
+	case "name", "profile_name":
+		return "c.profile_name", nil
+	case "first_name":
+		return "split_part(TRIM(c.profile_name), ' ', 1)", nil
+	case "phone_number":
+		return "c.phone_number", nil
+	}
+	return "c.metadata->>?", []interface{}{field}
+}
+
+// missingTemplateParams returns the keys of the template's parameters params has no
+// value for
+func missingTemplateParams(template *models.Template, params models.JSONB) []string {
+	var missing []string
+	for _, key := range models.TemplateBodyParams(template.BodyContent) {
+		if !hasParam(params, key) {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// hasParam reports whether params has a non-empty value for key
+func hasParam(params models.JSONB, key string) bool {
+	v, ok := params[key]
+	return ok && v != nil && strings.TrimSpace(fmt.Sprintf("%v", v)) != ""
+}
+
+// renderTemplateText substitutes params into a template's {{n}} placeholders, as
+// sent messages are stored for display in chat
+func renderTemplateText(text string, params models.JSONB) string {
+	for key, val := range params {
+		if val == nil {
+			continue
+		}
+		text = strings.ReplaceAll(text, "{{"+key+"}}", fmt.Sprintf("%v", val))
+	}
+	return text
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/phonenumber"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// recipientTimelineReplyLimit caps the replies shown on a recipient's timeline
+	recipientTimelineReplyLimit = 20
+
+	// recipientTimelineReplyPreview caps the length of each reply shown
+	recipientTimelineReplyPreview = 200
+)
+
+// Campaign recipient timeline events, besides the message events of its send attempts
+const (
+	recipientEventImported  = "imported"
+	recipientEventSkipped   = "skipped"
+	recipientEventCancelled = "cancelled"
+	recipientEventClicked   = "clicked"
+	recipientEventReplied   = "replied"
+)
+
+// RecipientTimelineEvent is one thing that happened to a campaign recipient
+type RecipientTimelineEvent struct {
+	Event     string     `json:"event"`
+	At        time.Time  `json:"at"`
+	MessageID *uuid.UUID `json:"message_id,omitempty"` // The attempt or reply the event belongs to
+	Source    string     `json:"source,omitempty"`     // app or webhook, for message events
+	ErrorCode int        `json:"error_code,omitempty"`
+	Detail    string     `json:"detail,omitempty"` // Error, skip reason, clicked URL or reply text
+}
+
+// RecipientAttempt is one message sent to a campaign recipient, with its timeline
+type RecipientAttempt struct {
+	Message MessageTimelineMessage `json:"message"`
+	Events  []models.MessageEvent  `json:"events"`
+}
+
+// GetCampaignRecipientTimeline returns everything known about one campaign recipient
+// in one place: when it was imported, whether its number is valid, why it was
+// skipped, each send attempt with its statuses, and the contact's clicks and replies
+func (a *App) GetCampaignRecipientTimeline(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	campaignID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+	recipientID, err := uuid.Parse(r.RequestCtx.UserValue("recipient_id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid recipient ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaignID, orgID).First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+	var recipient models.BulkMessageRecipient
+	if err := a.DB.Where("id = ? AND campaign_id = ?", recipientID, campaign.ID).First(&recipient).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Recipient not found", nil, "")
+	}
+
+	validation, err := a.validateRecipientPhone(orgID, recipient.PhoneNumber)
+	if err != nil {
+		a.Log.Error("Failed to validate recipient phone", "error", err, "recipient_id", recipient.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load recipient timeline", nil, "")
+	}
+
+	events := []RecipientTimelineEvent{{Event: recipientEventImported, At: recipient.CreatedAt}}
+	switch recipient.Status {
+	case "skipped":
+		events = append(events, RecipientTimelineEvent{Event: recipientEventSkipped, At: recipient.UpdatedAt, Detail: recipient.ErrorMessage})
+	case "cancelled":
+		events = append(events, RecipientTimelineEvent{Event: recipientEventCancelled, At: recipient.UpdatedAt})
+	}
+
+	attempts, err := a.recipientAttempts(orgID, &recipient)
+	if err != nil {
+		a.Log.Error("Failed to load recipient attempts", "error", err, "recipient_id", recipient.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load recipient timeline", nil, "")
+	}
+	for _, attempt := range attempts {
+		messageID := attempt.Message.ID
+		for _, e := range attempt.Events {
+			events = append(events, RecipientTimelineEvent{
+				Event:     e.Event,
+				At:        e.OccurredAt,
+				MessageID: &messageID,
+				Source:    e.Source,
+				ErrorCode: e.ErrorCode,
+				Detail:    e.ErrorMessage,
+			})
+		}
+	}
+
+	// Clicks and replies are the contact's, so they need the contact the number belongs to
+	var contactID *uuid.UUID
+	phone := strings.TrimPrefix(recipient.PhoneNumber, "+")
+	if validation.Valid {
+		phone = validation.PhoneNumber
+	}
+	var contact models.Contact
+	if err := a.DB.Select("id").Where("organization_id = ? AND phone_number IN ?", orgID, []string{phone, "+" + phone}).First(&contact).Error; err == nil {
+		contactID = &contact.ID
+
+		// Clicks reported for the campaign, or for no campaign in particular after it
+		// first messaged the recipient
+		clicks := a.DB.Where("contact_id = ?", contact.ID)
+		if len(attempts) > 0 {
+			clicks = clicks.Where("campaign_id = ? OR (campaign_id IS NULL AND clicked_at >= ?)", campaign.ID, attempts[0].Message.CreatedAt)
+		} else {
+			clicks = clicks.Where("campaign_id = ?", campaign.ID)
+		}
+		var linkClicks []models.LinkClick
+		if err := clicks.Order("clicked_at ASC").Find(&linkClicks).Error; err != nil {
+			a.Log.Error("Failed to load link clicks", "error", err, "contact_id", contact.ID)
+		}
+		for _, click := range linkClicks {
+			events = append(events, RecipientTimelineEvent{Event: recipientEventClicked, At: click.ClickedAt, Detail: click.URL})
+		}
+
+		if len(attempts) > 0 {
+			var replies []models.Message
+			if err := a.DB.Select("id", "content", "message_type", "created_at").
+				Where("contact_id = ? AND direction = ? AND created_at >= ?", contact.ID, "incoming", attempts[0].Message.CreatedAt).
+				Order("created_at ASC").
+				Limit(recipientTimelineReplyLimit).
+				Find(&replies).Error; err != nil {
+				a.Log.Error("Failed to load replies", "error", err, "contact_id", contact.ID)
+			}
+			for _, reply := range replies {
+				detail := reply.Content
+				if detail == "" {
+					detail = "[" + reply.MessageType + "]"
+				}
+				events = append(events, RecipientTimelineEvent{
+					Event:     recipientEventReplied,
+					At:        reply.CreatedAt,
+					MessageID: &reply.ID,
+					Detail:    truncateString(detail, recipientTimelineReplyPreview),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+
+	return r.SendEnvelope(map[string]any{
+		"recipient":  recipient,
+		"validation": validation,
+		"contact_id": contactID,
+		"attempts":   attempts,
+		"events":     events,
+	})
+}
+
+// validateRecipientPhone checks a recipient's number as ValidatePhoneNumbers would now
+func (a *App) validateRecipientPhone(orgID uuid.UUID, input string) (PhoneValidationResult, error) {
+	result := PhoneValidationResult{Input: input}
+	n, err := phonenumber.Parse(input, "")
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Valid = true
+	result.PhoneNumber = n.Digits()
+	result.E164 = n.E164()
+	result.CallingCode = n.CallingCode
+	result.Country = n.Country
+	result.LineType = string(n.LineType)
+
+	flags, err := a.phoneNumberFlags(orgID, []string{result.PhoneNumber})
+	if err != nil {
+		return result, err
+	}
+	if f, ok := flags[result.PhoneNumber]; ok {
+		result.IsContact = true
+		result.OptedOut = f.OptedOut
+		result.KnownInvalid = f.KnownInvalid
+	}
+	return result, nil
+}
+
+// recipientAttempts returns the messages sent to a campaign recipient, oldest first,
+// with their timelines
+func (a *App) recipientAttempts(orgID uuid.UUID, recipient *models.BulkMessageRecipient) ([]RecipientAttempt, error) {
+	var messages []models.Message
+	if err := a.DB.Where("organization_id = ? AND metadata->>'recipient_id' = ?", orgID, recipient.ID.String()).
+		Order("created_at ASC").
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return []RecipientAttempt{}, nil
+	}
+
+	messageIDs := make([]uuid.UUID, len(messages))
+	for i, m := range messages {
+		messageIDs[i] = m.ID
+	}
+	var events []models.MessageEvent
+	if err := a.DB.Where("message_id IN ?", messageIDs).
+		Order("occurred_at ASC, created_at ASC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	byMessage := make(map[uuid.UUID][]models.MessageEvent, len(messages))
+	for _, e := range events {
+		byMessage[e.MessageID] = append(byMessage[e.MessageID], e)
+	}
+
+	attempts := make([]RecipientAttempt, len(messages))
+	for i := range messages {
+		messageEvents := byMessage[messages[i].ID]
+		if messageEvents == nil {
+			messageEvents = []models.MessageEvent{}
+		}
+		attempts[i] = RecipientAttempt{
+			Message: messageTimelineMessage(&messages[i]),
+			Events:  messageEvents,
+		}
+	}
+	return attempts, nil
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+)
+
+// CampaignSchedulerProcessor starts scheduled campaigns once their send time has
+// come. Campaigns due on a holiday/blackout date wait until it is over.
+type CampaignSchedulerProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewCampaignSchedulerProcessor creates a new campaign scheduler processor
+func NewCampaignSchedulerProcessor(app *App, interval time.Duration) *CampaignSchedulerProcessor {
+	return &CampaignSchedulerProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the campaign scheduler loop
+func (p *CampaignSchedulerProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Campaign scheduler started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Campaign scheduler stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Campaign scheduler stopped")
+			return
+		case <-ticker.C:
+			p.processDue(ctx)
+		}
+	}
+}
+
+// Stop stops the campaign scheduler
+func (p *CampaignSchedulerProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// processDue queues the scheduled campaigns whose send time has passed, earliest first
+func (p *CampaignSchedulerProcessor) processDue(ctx context.Context) {
+	var campaigns []models.BulkMessageCampaign
+	if err := p.app.DB.Where("status = ? AND scheduled_at <= ?", "scheduled", time.Now()).
+		Order("scheduled_at").
+		Find(&campaigns).Error; err != nil {
+		p.app.Log.Error("Failed to load scheduled campaigns", "error", err)
+		return
+	}
+
+	blocked := map[uuid.UUID]bool{}
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		isBlocked, checked := blocked[campaign.OrganizationID]
+		if !checked {
+			isBlocked = p.app.campaignBlackout(campaign.OrganizationID) != nil
+			blocked[campaign.OrganizationID] = isBlocked
+		}
+		if isBlocked {
+			continue
+		}
+		p.app.queueScheduledCampaign(ctx, campaign)
+	}
+}
+
+// queueScheduledCampaign queues a scheduled campaign whose send time has come
+func (a *App) queueScheduledCampaign(ctx context.Context, campaign *models.BulkMessageCampaign) {
+	// Only one server instance gets to queue the campaign, and not if it was
+	// rescheduled or cancelled since it was loaded
+	now := time.Now()
+	result := a.DB.Model(&models.BulkMessageCampaign{}).
+		Where("id = ? AND status = ? AND scheduled_at <= ?", campaign.ID, "scheduled", now).
+		Updates(map[string]interface{}{
+			"status":     "queued",
+			"started_at": now,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to queue scheduled campaign", "error", result.Error, "campaign_id", campaign.ID)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	a.Log.Info("Scheduled campaign started", "campaign_id", campaign.ID, "scheduled_at", campaign.ScheduledAt)
+	go a.dispatchCampaignEvent(campaign.OrganizationID, campaign.ID, EventCampaignQueued, "")
+
+	if a.Queue != nil {
+		if err := a.Queue.EnqueueCampaign(ctx, campaign.ID, queue.Priority(campaign.Priority)); err != nil {
+			a.Log.Error("Failed to enqueue campaign", "error", err, "campaign_id", campaign.ID)
+		}
+	} else {
+		go a.processCampaign(campaign.ID)
+	}
+}
+
+// campaignScheduledAhead reports whether a campaign's send time is still to come
+func campaignScheduledAhead(campaign *models.BulkMessageCampaign) bool {
+	return campaign.ScheduledAt != nil && campaign.ScheduledAt.After(time.Now())
+}
+
+// validScheduledAt reports whether a campaign can be given send time t: none, or one
+// that hasn't passed
+func validScheduledAt(t *time.Time) bool {
+	return t == nil || t.After(time.Now())
+}
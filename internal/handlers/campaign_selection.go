@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// maxSelectionCampaignContacts caps the conversations a campaign can be drafted from
+const maxSelectionCampaignContacts = 1000
+
+// contactFrequencyCapped matches contacts who are likely to be frequency capped, as
+// recipientFrequencyCapped does for recipients. Test numbers never are. Its arguments
+// are the start of recentCampaignWindow, the marketing limit error pattern and the
+// start of marketingLimitWindow.
+const contactFrequencyCapped = `(EXISTS (SELECT 1 FROM messages m WHERE m.contact_id = contacts.id
+	AND m.direction = 'outgoing' AND m.deleted_at IS NULL AND m.metadata->>'campaign_id' IS NOT NULL
+	AND ((m.status <> 'failed' AND m.created_at >= ?)
+		OR (m.status = 'failed' AND m.error_message LIKE ? AND m.created_at >= ?)))
+	AND NOT ` + contactIsTestNumber + `)`
+
+// SelectionCampaignRequest is the request body for drafting a campaign to
+// conversations selected in the inbox
+type SelectionCampaignRequest struct {
+	ContactIDs      []string          `json:"contact_ids"` // The selected conversations' contacts
+	Name            string            `json:"name"`
+	TemplateID      string            `json:"template_id"`
+	WhatsAppAccount string            `json:"whatsapp_account"` // Defaults to the contacts' account when they share one
+	TemplateParams  map[string]string `json:"template_params"`  // "1" -> "{{name}}"
+}
+
+// SelectionCampaignResponse is a campaign drafted from an inbox selection, with how
+// many of the selected contacts were left out of it and why
+type SelectionCampaignResponse struct {
+	Campaign        CampaignResponse `json:"campaign"`
+	Added           int              `json:"added"`
+	OptedOut        int              `json:"opted_out"`        // Replied with an opt-out keyword
+	FrequencyCapped int              `json:"frequency_capped"` // Recently messaged by a campaign or hit Meta's marketing limit
+	OtherAccount    int              `json:"other_account"`    // On another WhatsApp account than the campaign's
+}
+
+// CreateCampaignFromSelection drafts a campaign to the contacts of conversations
+// selected in the inbox. Contacts who opted out or are likely to be frequency capped
+// are left out, as are those on another account. The draft is reviewed and started
+// like any other campaign.
+func (a *App) CreateCampaignFromSelection(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req SelectionCampaignRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	ids := make([]uuid.UUID, 0, len(req.ContactIDs))
+	seen := make(map[uuid.UUID]bool, len(req.ContactIDs))
+	for _, s := range req.ContactIDs {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID: "+s, nil, "")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_ids is required", nil, "")
+	}
+	if len(ids) > maxSelectionCampaignContacts {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("A campaign can be drafted from at most %d conversations", maxSelectionCampaignContacts), nil, "")
+	}
+
+	var contacts []models.Contact
+	if err := a.DB.Where("organization_id = ? AND id IN ?", orgID, ids).Order("created_at ASC").Find(&contacts).Error; err != nil {
+		a.Log.Error("Failed to load selected contacts", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load conversations", nil, "")
+	}
+	if len(contacts) != len(ids) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Some conversations were not found", nil, "")
+	}
+
+	// Without an account, the selection must all be on one
+	accountName := req.WhatsAppAccount
+	if accountName == "" {
+		for i := range contacts {
+			if accountName != "" && contacts[i].WhatsAppAccount != accountName {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Selected conversations are on several WhatsApp accounts; choose whatsapp_account", nil, "")
+			}
+			accountName = contacts[i].WhatsAppAccount
+		}
+	}
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", accountName, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+	}
+
+	templateID, err := uuid.Parse(req.TemplateID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template ID", nil, "")
+	}
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ? AND whats_app_account = ?", templateID, orgID, account.Name).First(&template).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template not found for this account", nil, "")
+	}
+
+	// Leave out contacts who opted out or are likely to be frequency capped
+	now := time.Now()
+	var optedOut, capped []uuid.UUID
+	if err := a.DB.Model(&models.Contact{}).Where("id IN ?", ids).
+		Where("NOT ("+contactNotOptedOut+")", engagement.OptOutKeywords).
+		Pluck("id", &optedOut).Error; err != nil {
+		a.Log.Error("Failed to check selected contacts for opt-outs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load conversations", nil, "")
+	}
+	if err := a.DB.Model(&models.Contact{}).Where("id IN ?", ids).
+		Where(contactFrequencyCapped, now.Add(-recentCampaignWindow), "%"+marketingLimitError+"%", now.Add(-marketingLimitWindow)).
+		Pluck("id", &capped).Error; err != nil {
+		a.Log.Error("Failed to check selected contacts for frequency caps", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load conversations", nil, "")
+	}
+	excluded := make(map[uuid.UUID]*int, len(optedOut)+len(capped))
+	var resp SelectionCampaignResponse
+	for _, id := range capped {
+		excluded[id] = &resp.FrequencyCapped
+	}
+	for _, id := range optedOut {
+		excluded[id] = &resp.OptedOut
+	}
+
+	params := models.JSONB{}
+	for k, v := range req.TemplateParams {
+		params[k] = v
+	}
+	var recipients []models.BulkMessageRecipient
+	for i := range contacts {
+		if contacts[i].WhatsAppAccount != account.Name {
+			resp.OtherAccount++
+			continue
+		}
+		if count, ok := excluded[contacts[i].ID]; ok {
+			*count++
+			continue
+		}
+		recipients = append(recipients, contactRecipient(&contacts[i], params))
+	}
+	if len(recipients) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "None of the selected conversations can be sent a campaign", nil, "")
+	}
+
+	name := req.Name
+	if name == "" {
+		name = fmt.Sprintf("Inbox selection (%s)", now.In(a.orgLocation(orgID)).Format("2006-01-02 15:04"))
+	}
+	campaign := models.BulkMessageCampaign{
+		OrganizationID:  orgID,
+		WhatsAppAccount: account.Name,
+		Name:            name,
+		TemplateID:      templateID,
+		Status:          "draft",
+		TotalRecipients: len(recipients),
+		CreatedBy:       userID,
+		Priority:        string(queue.PriorityNormal),
+
+		MissingParamPolicy: models.MissingParamSend,
+		ParamDefaults:      models.JSONB{},
+	}
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		for i := range recipients {
+			recipients[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(&recipients, dateTriggerRecipientBatch).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to create campaign from selection", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create campaign", nil, "")
+	}
+
+	a.Log.Info("Campaign drafted from inbox selection", "campaign_id", campaign.ID, "recipients", len(recipients),
+		"opted_out", resp.OptedOut, "frequency_capped", resp.FrequencyCapped, "other_account", resp.OtherAccount)
+
+	resp.Added = len(recipients)
+	resp.Campaign = CampaignResponse{
+		ID:              campaign.ID,
+		Name:            campaign.Name,
+		WhatsAppAccount: campaign.WhatsAppAccount,
+		TemplateID:      campaign.TemplateID,
+		TemplateName:    template.Name,
+		Status:          campaign.Status,
+		TotalRecipients: campaign.TotalRecipients,
+		CreatedAt:       campaign.CreatedAt,
+		UpdatedAt:       campaign.UpdatedAt,
+
+		Priority: campaign.Priority,
+
+		MissingParamPolicy: campaign.MissingParamPolicy,
+		ParamDefaults:      campaign.ParamDefaults,
+	}
+	return r.SendEnvelope(resp)
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// CampaignVariantRequest is one template of a campaign's A/B split
+type CampaignVariantRequest struct {
+	Name       string `json:"name"` // Defaults to A, B, C... in order
+	TemplateID string `json:"template_id"`
+	Percent    int    `json:"percent"`
+}
+
+// CampaignVariantResponse is one template of a campaign's A/B split
+type CampaignVariantResponse struct {
+	Name         string    `json:"name"`
+	TemplateID   uuid.UUID `json:"template_id"`
+	TemplateName string    `json:"template_name,omitempty"`
+	Percent      int       `json:"percent"`
+}
+
+// CampaignVariantStats is how one variant of a split campaign performed
+type CampaignVariantStats struct {
+	CampaignVariantResponse
+	SentCount      int64   `json:"sent_count"`
+	DeliveredCount int64   `json:"delivered_count"`
+	ReadCount      int64   `json:"read_count"`
+	FailedCount    int64   `json:"failed_count"`
+	DeliveryRate   float64 `json:"delivery_rate"` // Percent of sent
+	ReadRate       float64 `json:"read_rate"`     // Percent of delivered
+}
+
+// buildCampaignVariants validates an A/B split and returns its variants, with their
+// templates. It returns a message describing an invalid split.
+func (a *App) buildCampaignVariants(orgID uuid.UUID, reqs []CampaignVariantRequest) ([]models.CampaignVariant, string) {
+	if len(reqs) == 0 {
+		return nil, ""
+	}
+	if len(reqs) < 2 || len(reqs) > models.MaxCampaignVariants {
+		return nil, fmt.Sprintf("variants must have between 2 and %d templates", models.MaxCampaignVariants)
+	}
+
+	variants := make([]models.CampaignVariant, len(reqs))
+	names := make(map[string]bool, len(reqs))
+	total := 0
+	for i, req := range reqs {
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			name = string(rune('A' + i))
+		}
+		if len(name) > 20 {
+			return nil, "Variant names must be 20 characters or fewer"
+		}
+		if names[strings.ToLower(name)] {
+			return nil, "Variant names must be unique"
+		}
+		names[strings.ToLower(name)] = true
+
+		if req.Percent < 1 || req.Percent > 99 {
+			return nil, fmt.Sprintf("Variant %s's percent must be between 1 and 99", name)
+		}
+		total += req.Percent
+
+		templateID, err := uuid.Parse(req.TemplateID)
+		if err != nil {
+			return nil, fmt.Sprintf("Invalid template ID for variant %s", name)
+		}
+		var template models.Template
+		if err := a.DB.Where("id = ? AND organization_id = ?", templateID, orgID).First(&template).Error; err != nil {
+			return nil, fmt.Sprintf("Template not found for variant %s", name)
+		}
+
+		variants[i] = models.CampaignVariant{
+			Name:       name,
+			TemplateID: templateID,
+			Percent:    req.Percent,
+			Position:   i,
+			Template:   &template,
+		}
+	}
+	if total != 100 {
+		return nil, "Variant percents must add up to 100"
+	}
+	return variants, ""
+}
+
+// saveCampaignVariants replaces a campaign's A/B split with variants, or removes it
+// when there are none
+func saveCampaignVariants(tx *gorm.DB, campaignID uuid.UUID, variants []models.CampaignVariant) error {
+	if err := tx.Where("campaign_id = ?", campaignID).Delete(&models.CampaignVariant{}).Error; err != nil {
+		return err
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+	for i := range variants {
+		variants[i].CampaignID = campaignID
+	}
+	return tx.Omit("Template").Create(&variants).Error
+}
+
+// campaignVariants loads a campaign's A/B split in order, with the templates
+func (a *App) campaignVariants(campaignID uuid.UUID) []models.CampaignVariant {
+	var variants []models.CampaignVariant
+	a.DB.Where("campaign_id = ?", campaignID).Preload("Template").Order("position").Find(&variants)
+	return variants
+}
+
+// campaignVariantResponses converts a campaign's variants for API responses
+func campaignVariantResponses(variants []models.CampaignVariant) []CampaignVariantResponse {
+	if len(variants) == 0 {
+		return nil
+	}
+	responses := make([]CampaignVariantResponse, len(variants))
+	for i, v := range variants {
+		responses[i] = CampaignVariantResponse{
+			Name:       v.Name,
+			TemplateID: v.TemplateID,
+			Percent:    v.Percent,
+		}
+		if v.Template != nil {
+			responses[i].TemplateName = v.Template.Name
+		}
+	}
+	return responses
+}
+
+// GetCampaignVariantStats returns the sent, delivered, read and failed counts of each
+// template in a split campaign
+func (a *App) GetCampaignVariantStats(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+
+	var rows []struct {
+		Variant   string
+		Sent      int64
+		Delivered int64
+		Read      int64
+		Failed    int64
+	}
+	if err := a.DB.Model(&models.Message{}).
+		Where("metadata->>'campaign_id' = ?", id.String()).
+		Select(`
+			metadata->>'campaign_variant' as variant,
+			COUNT(CASE WHEN status IN ('sent','delivered','read') THEN 1 END) as sent,
+			COUNT(CASE WHEN status IN ('delivered','read') THEN 1 END) as delivered,
+			COUNT(CASE WHEN status = 'read' THEN 1 END) as read,
+			COUNT(CASE WHEN status = 'failed' THEN 1 END) as failed
+		`).
+		Group("metadata->>'campaign_variant'").
+		Scan(&rows).Error; err != nil {
+		a.Log.Error("Failed to load campaign variant stats", "error", err, "campaign_id", id)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load variant stats", nil, "")
+	}
+
+	variants := campaignVariantResponses(a.campaignVariants(id))
+	stats := make([]CampaignVariantStats, len(variants))
+	for i, v := range variants {
+		stats[i].CampaignVariantResponse = v
+		for _, row := range rows {
+			if row.Variant != v.Name {
+				continue
+			}
+			stats[i].SentCount = row.Sent
+			stats[i].DeliveredCount = row.Delivered
+			stats[i].ReadCount = row.Read
+			stats[i].FailedCount = row.Failed
+			stats[i].DeliveryRate = percentOf(row.Delivered, row.Sent)
+			stats[i].ReadRate = percentOf(row.Read, row.Delivered)
+		}
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"campaign_id": id,
+		"variants":    stats,
+	})
+}
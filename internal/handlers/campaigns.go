@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/jobs"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
@@ -21,6 +26,23 @@ type CampaignRequest struct {
 	WhatsAppAccount string     `json:"whatsapp_account" validate:"required"`
 	TemplateID      string     `json:"template_id" validate:"required"`
 	ScheduledAt     *time.Time `json:"scheduled_at"`
+	Concurrency     *int       `json:"concurrency"` // Recipients sent to at once; 0 uses the account's setting
+
+	// Messages a minute the campaign sends at most; 0 sends as fast as the account allows
+	MaxMessagesPerMinute *int `json:"max_messages_per_minute"`
+
+	Priority string `json:"priority"` // high, normal or low; empty keeps the current one, normal on create
+
+	MissingParamPolicy string            `json:"missing_param_policy"` // send, skip, default
+	ParamDefaults      map[string]string `json:"param_defaults"`
+
+	// HH:MM in each recipient's timezone; both empty removes the window
+	SendWindowStart *string `json:"send_window_start"`
+	SendWindowEnd   *string `json:"send_window_end"`
+
+	// Templates to split recipients between for A/B testing; the first replaces
+	// template_id. An empty list removes the split.
+	Variants *[]CampaignVariantRequest `json:"variants"`
 }
 
 // CampaignResponse represents campaign in API responses
@@ -39,8 +61,25 @@ type CampaignResponse struct {
 	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
 	StartedAt       *time.Time `json:"started_at,omitempty"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	Concurrency     int        `json:"concurrency"`
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
+
+	MaxMessagesPerMinute int    `json:"max_messages_per_minute"`
+	Priority             string `json:"priority"`
+
+	MissingParamPolicy string       `json:"missing_param_policy"`
+	ParamDefaults      models.JSONB `json:"param_defaults"`
+
+	SendWindowStart string     `json:"send_window_start,omitempty"`
+	SendWindowEnd   string     `json:"send_window_end,omitempty"`
+	ResumeAt        *time.Time `json:"resume_at,omitempty"`
+	HeldReason      string     `json:"held_reason,omitempty"`
+
+	Variants []CampaignVariantResponse `json:"variants,omitempty"`
+
+	// Failed recipients by error category: invalid_number, policy, rate_limit, unknown
+	FailureBreakdown map[string]int `json:"failure_breakdown,omitempty"`
 }
 
 // RecipientRequest represents recipient import request
@@ -74,6 +113,15 @@ func (a *App) ListCampaigns(r *fastglue.Request) error {
 	if whatsappAccount != "" {
 		query = query.Where("whats_app_account = ?", whatsappAccount)
 	}
+	if dateTriggerID := string(r.RequestCtx.QueryArgs().Peek("date_trigger_id")); dateTriggerID != "" {
+		query = query.Where("date_trigger_id = ?", dateTriggerID)
+	}
+	if broadcastListID := string(r.RequestCtx.QueryArgs().Peek("broadcast_list_id")); broadcastListID != "" {
+		query = query.Where("broadcast_list_id = ?", broadcastListID)
+	}
+	if recurringID := string(r.RequestCtx.QueryArgs().Peek("recurring_campaign_id")); recurringID != "" {
+		query = query.Where("recurring_campaign_id = ?", recurringID)
+	}
 	if fromDate != "" {
 		if parsedFrom, err := time.Parse("2006-01-02", fromDate); err == nil {
 			query = query.Where("created_at >= ?", parsedFrom)
@@ -109,8 +157,20 @@ func (a *App) ListCampaigns(r *fastglue.Request) error {
 			ScheduledAt:     c.ScheduledAt,
 			StartedAt:       c.StartedAt,
 			CompletedAt:     c.CompletedAt,
+			Concurrency:     c.Concurrency,
 			CreatedAt:       c.CreatedAt,
 			UpdatedAt:       c.UpdatedAt,
+
+			MaxMessagesPerMinute: c.MaxMessagesPerMinute,
+			Priority:             c.Priority,
+
+			MissingParamPolicy: c.MissingParamPolicy,
+			ParamDefaults:      c.ParamDefaults,
+
+			SendWindowStart: c.SendWindowStart,
+			SendWindowEnd:   c.SendWindowEnd,
+			ResumeAt:        c.ResumeAt,
+			HeldReason:      c.HeldReason,
 		}
 		if c.Template != nil {
 			response[i].TemplateName = c.Template.Name
@@ -140,6 +200,18 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
+	// A split campaign's first variant is its template
+	var variants []models.CampaignVariant
+	if req.Variants != nil {
+		var msg string
+		if variants, msg = a.buildCampaignVariants(orgID, *req.Variants); msg != "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+		}
+		if len(variants) > 0 {
+			req.TemplateID = variants[0].TemplateID.String()
+		}
+	}
+
 	// Validate template exists
 	templateID, err := uuid.Parse(req.TemplateID)
 	if err != nil {
@@ -157,6 +229,10 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
 	}
 
+	if !validScheduledAt(req.ScheduledAt) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "scheduled_at must be in the future", nil, "")
+	}
+
 	campaign := models.BulkMessageCampaign{
 		OrganizationID:  orgID,
 		WhatsAppAccount: req.WhatsAppAccount,
@@ -165,9 +241,42 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 		Status:          "draft",
 		ScheduledAt:     req.ScheduledAt,
 		CreatedBy:       userID,
+
+		MissingParamPolicy: models.MissingParamSend,
+		ParamDefaults:      models.JSONB{},
+	}
+	if msg := applyMissingParamPolicy(&campaign, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if msg := applySendWindow(&campaign, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if req.Concurrency != nil {
+		if !models.ValidCampaignConcurrency(*req.Concurrency) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("concurrency must be between 0 and %d", models.MaxCampaignConcurrency), nil, "")
+		}
+		campaign.Concurrency = *req.Concurrency
+	}
+	if req.MaxMessagesPerMinute != nil {
+		if !models.ValidCampaignMessagesPerMinute(*req.MaxMessagesPerMinute) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("max_messages_per_minute must be between 0 and %d", models.MaxCampaignMessagesPerMinute), nil, "")
+		}
+		campaign.MaxMessagesPerMinute = *req.MaxMessagesPerMinute
+	}
+	campaign.Priority = string(queue.PriorityNormal)
+	if req.Priority != "" {
+		if !queue.ValidPriority(queue.Priority(req.Priority)) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "priority must be high, normal or low", nil, "")
+		}
+		campaign.Priority = req.Priority
 	}
 
-	if err := a.DB.Create(&campaign).Error; err != nil {
+	if err := a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		return saveCampaignVariants(tx, campaign.ID, variants)
+	}); err != nil {
 		a.Log.Error("Failed to create campaign", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create campaign", nil, "")
 	}
@@ -186,8 +295,22 @@ func (a *App) CreateCampaign(r *fastglue.Request) error {
 		DeliveredCount:  campaign.DeliveredCount,
 		FailedCount:     campaign.FailedCount,
 		ScheduledAt:     campaign.ScheduledAt,
+		Concurrency:     campaign.Concurrency,
 		CreatedAt:       campaign.CreatedAt,
 		UpdatedAt:       campaign.UpdatedAt,
+
+		MaxMessagesPerMinute: campaign.MaxMessagesPerMinute,
+		Priority:             campaign.Priority,
+
+		MissingParamPolicy: campaign.MissingParamPolicy,
+		ParamDefaults:      campaign.ParamDefaults,
+
+		SendWindowStart: campaign.SendWindowStart,
+		SendWindowEnd:   campaign.SendWindowEnd,
+		ResumeAt:        campaign.ResumeAt,
+		HeldReason:      campaign.HeldReason,
+
+		Variants: campaignVariantResponses(variants),
 	})
 }
 
@@ -224,8 +347,24 @@ func (a *App) GetCampaign(r *fastglue.Request) error {
 		ScheduledAt:     campaign.ScheduledAt,
 		StartedAt:       campaign.StartedAt,
 		CompletedAt:     campaign.CompletedAt,
+		Concurrency:     campaign.Concurrency,
 		CreatedAt:       campaign.CreatedAt,
 		UpdatedAt:       campaign.UpdatedAt,
+
+		MaxMessagesPerMinute: campaign.MaxMessagesPerMinute,
+		Priority:             campaign.Priority,
+
+		MissingParamPolicy: campaign.MissingParamPolicy,
+		ParamDefaults:      campaign.ParamDefaults,
+
+		SendWindowStart: campaign.SendWindowStart,
+		SendWindowEnd:   campaign.SendWindowEnd,
+		ResumeAt:        campaign.ResumeAt,
+		HeldReason:      campaign.HeldReason,
+
+		Variants: campaignVariantResponses(a.campaignVariants(campaign.ID)),
+
+		FailureBreakdown: a.campaignFailureBreakdown(campaign.ID),
 	}
 	if campaign.Template != nil {
 		response.TemplateName = campaign.Template.Name
@@ -234,6 +373,32 @@ func (a *App) GetCampaign(r *fastglue.Request) error {
 	return r.SendEnvelope(response)
 }
 
+// campaignFailureBreakdown counts the campaign's failed recipients by error category.
+// Recipients that failed before failures were categorized count as unknown.
+func (a *App) campaignFailureBreakdown(campaignID uuid.UUID) map[string]int {
+	var rows []struct {
+		Category string
+		Count    int
+	}
+	if err := a.DB.Model(&models.BulkMessageRecipient{}).
+		Select("COALESCE(NULLIF(error_category, ''), ?) AS category, COUNT(*) AS count", whatsapp.FailureUnknown).
+		Where("campaign_id = ? AND status = ?", campaignID, "failed").
+		Group("category").
+		Scan(&rows).Error; err != nil {
+		a.Log.Error("Failed to count campaign failures", "error", err, "campaign_id", campaignID)
+		return nil
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	breakdown := make(map[string]int, len(rows))
+	for _, row := range rows {
+		breakdown[row.Category] = row.Count
+	}
+	return breakdown
+}
+
 // UpdateCampaign implements campaign update
 func (a *App) UpdateCampaign(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
@@ -252,9 +417,9 @@ func (a *App) UpdateCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
 	}
 
-	// Only allow updates to draft campaigns
-	if campaign.Status != "draft" {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only update draft campaigns", nil, "")
+	// Only allow updates to campaigns that haven't started
+	if campaign.Status != "draft" && campaign.Status != "scheduled" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only update draft or scheduled campaigns", nil, "")
 	}
 
 	var req CampaignRequest
@@ -262,17 +427,56 @@ func (a *App) UpdateCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
+	if !validScheduledAt(req.ScheduledAt) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "scheduled_at must be in the future", nil, "")
+	}
+
 	// Update fields
 	updates := map[string]interface{}{
 		"name":         req.Name,
 		"scheduled_at": req.ScheduledAt,
 	}
 
+	// A scheduled campaign without a send time goes back to draft until it is started
+	if campaign.Status == "scheduled" && req.ScheduledAt == nil {
+		updates["status"] = "draft"
+	}
+
+	var variants []models.CampaignVariant
+	if req.Variants != nil {
+		var msg string
+		if variants, msg = a.buildCampaignVariants(orgID, *req.Variants); msg != "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+		}
+		if campaign.Status == "scheduled" {
+			for _, v := range variants {
+				if !strings.EqualFold(v.Template.Status, "APPROVED") {
+					return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "A scheduled campaign's templates must be approved", nil, "")
+				}
+			}
+		}
+		if len(variants) > 0 {
+			req.TemplateID = variants[0].TemplateID.String()
+		}
+	}
+
 	if req.TemplateID != "" {
 		templateID, err := uuid.Parse(req.TemplateID)
 		if err != nil {
 			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template ID", nil, "")
 		}
+
+		// A scheduled campaign's template was approved when it was scheduled; one
+		// swapped in must be too, as nothing submits it for approval later
+		if campaign.Status == "scheduled" && templateID != campaign.TemplateID {
+			var template models.Template
+			if err := a.DB.Where("id = ? AND organization_id = ?", templateID, orgID).First(&template).Error; err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template not found", nil, "")
+			}
+			if !strings.EqualFold(template.Status, "APPROVED") {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "A scheduled campaign's template must be approved", nil, "")
+			}
+		}
 		updates["template_id"] = templateID
 	}
 
@@ -280,10 +484,61 @@ func (a *App) UpdateCampaign(r *fastglue.Request) error {
 		updates["whats_app_account"] = req.WhatsAppAccount
 	}
 
-	if err := a.DB.Model(&campaign).Updates(updates).Error; err != nil {
+	if msg := applyMissingParamPolicy(&campaign, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	updates["missing_param_policy"] = campaign.MissingParamPolicy
+	updates["param_defaults"] = campaign.ParamDefaults
+
+	if msg := applySendWindow(&campaign, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	updates["send_window_start"] = campaign.SendWindowStart
+	updates["send_window_end"] = campaign.SendWindowEnd
+
+	if req.Concurrency != nil {
+		if !models.ValidCampaignConcurrency(*req.Concurrency) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("concurrency must be between 0 and %d", models.MaxCampaignConcurrency), nil, "")
+		}
+		updates["concurrency"] = *req.Concurrency
+	}
+	if req.MaxMessagesPerMinute != nil {
+		if !models.ValidCampaignMessagesPerMinute(*req.MaxMessagesPerMinute) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("max_messages_per_minute must be between 0 and %d", models.MaxCampaignMessagesPerMinute), nil, "")
+		}
+		updates["max_messages_per_minute"] = *req.MaxMessagesPerMinute
+	}
+	if req.Priority != "" {
+		if !queue.ValidPriority(queue.Priority(req.Priority)) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "priority must be high, normal or low", nil, "")
+		}
+		updates["priority"] = req.Priority
+	}
+
+	// The scheduler may have started the campaign since it was loaded
+	var started bool
+	if err := a.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.BulkMessageCampaign{}).
+			Where("id = ? AND status = ?", id, campaign.Status).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			started = true
+			return nil
+		}
+		if req.Variants == nil {
+			return nil
+		}
+		return saveCampaignVariants(tx, id, variants)
+	}); err != nil {
 		a.Log.Error("Failed to update campaign", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update campaign", nil, "")
 	}
+	if started {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Campaign has already started", nil, "")
+	}
 
 	// Reload campaign
 	a.DB.Where("id = ?", id).Preload("Template").First(&campaign)
@@ -299,8 +554,22 @@ func (a *App) UpdateCampaign(r *fastglue.Request) error {
 		DeliveredCount:  campaign.DeliveredCount,
 		FailedCount:     campaign.FailedCount,
 		ScheduledAt:     campaign.ScheduledAt,
+		Concurrency:     campaign.Concurrency,
 		CreatedAt:       campaign.CreatedAt,
 		UpdatedAt:       campaign.UpdatedAt,
+
+		MaxMessagesPerMinute: campaign.MaxMessagesPerMinute,
+		Priority:             campaign.Priority,
+
+		MissingParamPolicy: campaign.MissingParamPolicy,
+		ParamDefaults:      campaign.ParamDefaults,
+
+		SendWindowStart: campaign.SendWindowStart,
+		SendWindowEnd:   campaign.SendWindowEnd,
+		ResumeAt:        campaign.ResumeAt,
+		HeldReason:      campaign.HeldReason,
+
+		Variants: campaignVariantResponses(a.campaignVariants(campaign.ID)),
 	}
 	if campaign.Template != nil {
 		response.TemplateName = campaign.Template.Name
@@ -309,6 +578,53 @@ func (a *App) UpdateCampaign(r *fastglue.Request) error {
 	return r.SendEnvelope(response)
 }
 
+// applyMissingParamPolicy sets the campaign's missing parameter policy and defaults
+// from the request, keeping the current ones when the request leaves them out. It
+// returns a message describing an invalid policy.
+func applyMissingParamPolicy(campaign *models.BulkMessageCampaign, req *CampaignRequest) string {
+	if req.MissingParamPolicy != "" {
+		if !models.ValidMissingParamPolicy(req.MissingParamPolicy) {
+			return "missing_param_policy must be send, skip or default"
+		}
+		campaign.MissingParamPolicy = req.MissingParamPolicy
+	}
+	if req.ParamDefaults != nil {
+		defaults := models.JSONB{}
+		for k, v := range req.ParamDefaults {
+			if v = strings.TrimSpace(v); v != "" {
+				defaults[k] = v
+			}
+		}
+		campaign.ParamDefaults = defaults
+	}
+	if campaign.MissingParamPolicy == models.MissingParamDefault && len(campaign.ParamDefaults) == 0 {
+		return "param_defaults is required for the default policy"
+	}
+	return ""
+}
+
+// applySendWindow sets the campaign's send window from the request, keeping the
+// current one when the request leaves it out. It returns a message describing an
+// invalid window.
+func applySendWindow(campaign *models.BulkMessageCampaign, req *CampaignRequest) string {
+	if req.SendWindowStart == nil && req.SendWindowEnd == nil {
+		return ""
+	}
+	var start, end string
+	if req.SendWindowStart != nil {
+		start = strings.TrimSpace(*req.SendWindowStart)
+	}
+	if req.SendWindowEnd != nil {
+		end = strings.TrimSpace(*req.SendWindowEnd)
+	}
+	if !models.ValidSendWindow(start, end) {
+		return "send_window_start and send_window_end must be two different HH:MM times, or both empty"
+	}
+	campaign.SendWindowStart = start
+	campaign.SendWindowEnd = end
+	return ""
+}
+
 // DeleteCampaign implements campaign deletion
 func (a *App) DeleteCampaign(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
@@ -328,7 +644,7 @@ func (a *App) DeleteCampaign(r *fastglue.Request) error {
 	}
 
 	// Don't allow deletion of running campaigns
-	if campaign.Status == "processing" || campaign.Status == "queued" {
+	if campaign.Status == "processing" || campaign.Status == "queued" || campaign.Status == "held" {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Cannot delete running campaign", nil, "")
 	}
 
@@ -337,6 +653,14 @@ func (a *App) DeleteCampaign(r *fastglue.Request) error {
 		a.Log.Error("Failed to delete campaign recipients", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete campaign", nil, "")
 	}
+	if err := a.DB.Where("campaign_id = ?", id).Delete(&models.CampaignVariant{}).Error; err != nil {
+		a.Log.Error("Failed to delete campaign variants", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete campaign", nil, "")
+	}
+	// A dry run's rows are deleted with it
+	if err := a.DB.Unscoped().Where("campaign_id = ?", id).Delete(&models.CampaignDryRun{}).Error; err != nil {
+		a.Log.Error("Failed to delete campaign dry runs", "error", err, "campaign_id", id)
+	}
 
 	// Delete campaign
 	if err := a.DB.Delete(&campaign).Error; err != nil {
@@ -344,6 +668,17 @@ func (a *App) DeleteCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete campaign", nil, "")
 	}
 
+	// Free the campaign's external IDs so they can be reused
+	if err := a.DB.Where("entity_type = ? AND entity_id = ?", models.ExternalRefCampaign, id).Delete(&models.ExternalReference{}).Error; err != nil {
+		a.Log.Error("Failed to delete campaign external references", "error", err, "campaign_id", id)
+	}
+
+	// Unsubscribe the campaign's webhooks
+	if err := a.DB.Where("organization_id = ? AND campaign_id = ?", orgID, id).Delete(&models.Webhook{}).Error; err != nil {
+		a.Log.Error("Failed to delete campaign webhooks", "error", err, "campaign_id", id)
+	}
+	a.InvalidateWebhooksCache(orgID)
+
 	a.Log.Info("Campaign deleted", "campaign_id", id)
 
 	return r.SendEnvelope(map[string]interface{}{
@@ -381,6 +716,67 @@ func (a *App) StartCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign has no recipients", nil, "")
 	}
 
+	// A dry run goes through sending to the recipients without sending anything
+	if r.RequestCtx.QueryArgs().GetBool("dry_run") {
+		return a.startDryRun(r, &campaign)
+	}
+
+	// A draft with a send time still to come is scheduled rather than queued. Starting
+	// a scheduled campaign sends it now.
+	schedule := campaign.Status == "draft" && campaignScheduledAhead(&campaign)
+
+	// Campaigns don't send on holiday/blackout dates
+	if !schedule {
+		if blackout := a.campaignBlackout(orgID); blackout != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaigns are blocked today: "+blackout.Name, nil, "")
+		}
+	}
+
+	// Guard against launching the same campaign twice; resuming a paused one is fine
+	if campaign.Status != "paused" {
+		if handled, err := a.checkDuplicateCampaign(r, &campaign); handled {
+			return err
+		}
+	}
+
+	// A split campaign's templates must all be approved to start; approval is only
+	// awaited for a campaign's single template
+	for _, v := range a.campaignVariants(id) {
+		if v.Template == nil || !strings.EqualFold(v.Template.Status, "APPROVED") {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Variant %s's template must be approved before the campaign starts", v.Name), nil, "")
+		}
+	}
+
+	// Templates that aren't approved yet are submitted to Meta and the campaign waits for approval
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", campaign.TemplateID, orgID).First(&template).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template not found", nil, "")
+	}
+	if !strings.EqualFold(template.Status, "APPROVED") {
+		return a.awaitTemplateApproval(r, &campaign, &template)
+	}
+
+	if schedule {
+		result := a.DB.Model(&models.BulkMessageCampaign{}).
+			Where("id = ? AND status = ?", id, "draft").
+			Update("status", "scheduled")
+		if result.Error != nil {
+			a.Log.Error("Failed to schedule campaign", "error", result.Error)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to schedule campaign", nil, "")
+		}
+		if result.RowsAffected == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "Campaign has already started", nil, "")
+		}
+
+		a.Log.Info("Campaign scheduled", "campaign_id", id, "scheduled_at", campaign.ScheduledAt)
+
+		return r.SendEnvelope(map[string]interface{}{
+			"message":      "Campaign scheduled",
+			"status":       "scheduled",
+			"scheduled_at": campaign.ScheduledAt,
+		})
+	}
+
 	// Update status
 	now := time.Now()
 	updates := map[string]interface{}{
@@ -394,10 +790,11 @@ func (a *App) StartCampaign(r *fastglue.Request) error {
 	}
 
 	a.Log.Info("Campaign started", "campaign_id", id)
+	go a.dispatchCampaignEvent(orgID, id, EventCampaignQueued, "")
 
 	// Enqueue campaign for processing by worker
 	if a.Queue != nil {
-		if err := a.Queue.EnqueueCampaign(r.RequestCtx, id); err != nil {
+		if err := a.Queue.EnqueueCampaign(r.RequestCtx, id, queue.Priority(campaign.Priority)); err != nil {
 			a.Log.Error("Failed to enqueue campaign", "error", err)
 			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue campaign", nil, "")
 		}
@@ -431,14 +828,19 @@ func (a *App) PauseCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
 	}
 
-	if campaign.Status != "processing" && campaign.Status != "queued" {
+	if campaign.Status != "processing" && campaign.Status != "queued" && campaign.Status != "held" {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign is not running", nil, "")
 	}
 
-	if err := a.DB.Model(&campaign).Update("status", "paused").Error; err != nil {
+	if err := a.DB.Model(&campaign).Updates(map[string]interface{}{
+		"status":      "paused",
+		"held_reason": "",
+	}).Error; err != nil {
 		a.Log.Error("Failed to pause campaign", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to pause campaign", nil, "")
 	}
+	a.signalCampaignStopped(id, "paused")
+	go a.dispatchCampaignEvent(orgID, id, EventCampaignPaused, "")
 
 	a.Log.Info("Campaign paused", "campaign_id", id)
 
@@ -470,19 +872,77 @@ func (a *App) CancelCampaign(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign already finished", nil, "")
 	}
 
-	if err := a.DB.Model(&campaign).Update("status", "cancelled").Error; err != nil {
+	// Cancel the campaign and its pending recipients together, so a worker never
+	// finds the campaign running with nothing left to send or cancelled with recipients
+	// still pending. Recipients a worker is sending to right now keep the outcome it records.
+	var cancelledCount int64
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.BulkMessageCampaign{}).
+			Where("id = ? AND status NOT IN ?", id, []string{"completed", "cancelled"}).
+			Updates(map[string]interface{}{
+				"status":           "cancelled",
+				"resume_at":        nil,
+				"held_reason":      "",
+				"recipient_cursor": nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errCampaignFinished
+		}
+		result = tx.Model(&models.BulkMessageRecipient{}).
+			Where("campaign_id = ? AND status = ?", id, "pending").
+			Update("status", "cancelled")
+		cancelledCount = result.RowsAffected
+		return result.Error
+	})
+	if errors.Is(err, errCampaignFinished) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign already finished", nil, "")
+	}
+	if err != nil {
 		a.Log.Error("Failed to cancel campaign", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to cancel campaign", nil, "")
 	}
+	a.signalCampaignStopped(id, "cancelled")
 
-	a.Log.Info("Campaign cancelled", "campaign_id", id)
+	// Drop the campaign's jobs still waiting for a worker, in the background; a worker
+	// taking one meanwhile finds the campaign cancelled and skips it
+	if a.Queue != nil {
+		go func() {
+			if _, err := a.Queue.PurgeCampaign(context.Background(), id); err != nil {
+				a.Log.Error("Failed to purge campaign jobs", "error", err, "campaign_id", id)
+			}
+		}()
+	}
+
+	// Final stats for live views; a worker that was sending publishes its own once it stops
+	a.DB.Where("id = ?", id).First(&campaign)
+	queue.NewPublisher(a.Redis, a.Log).PublishCampaignStats(context.Background(), &queue.CampaignStatsUpdate{
+		CampaignID:     id.String(),
+		OrganizationID: orgID,
+		Status:         "cancelled",
+		SentCount:      campaign.SentCount,
+		DeliveredCount: campaign.DeliveredCount,
+		ReadCount:      campaign.ReadCount,
+		FailedCount:    campaign.FailedCount,
+		CancelledCount: int(cancelledCount),
+	})
+
+	a.expireCampaignWebhooks(orgID, id)
+
+	a.Log.Info("Campaign cancelled", "campaign_id", id, "cancelled_recipients", cancelledCount)
 
 	return r.SendEnvelope(map[string]interface{}{
-		"message": "Campaign cancelled",
-		"status":  "cancelled",
+		"message":         "Campaign cancelled",
+		"status":          "cancelled",
+		"cancelled_count": cancelledCount,
 	})
 }
 
+// errCampaignFinished stops cancelling a campaign that completed or was cancelled meanwhile
+var errCampaignFinished = errors.New("campaign already finished")
+
 // RetryFailed retries sending to all failed recipients
 func (a *App) RetryFailed(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
@@ -506,33 +966,65 @@ func (a *App) RetryFailed(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only retry failed messages on completed, paused, or failed campaigns", nil, "")
 	}
 
-	// Count failed recipients
-	var failedCount int64
-	a.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ? AND status = ?", id, "failed").Count(&failedCount)
+	// Optionally retry only some failure categories, such as rate limits
+	var req struct {
+		ErrorClasses []string `json:"error_classes"`
+	}
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+	for _, class := range req.ErrorClasses {
+		if !slices.Contains(whatsapp.FailureCategories, class) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unknown error class: "+class, nil, "")
+		}
+	}
+	// Permanent failures, such as invalid numbers, and recipients sent to as often as
+	// allowed aren't retried. Failures recorded without a category are unknown.
+	failedRecipients := func() *gorm.DB {
+		query := a.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ? AND status = ?", id, "failed")
+		if len(req.ErrorClasses) > 0 {
+			query = query.Where("COALESCE(NULLIF(error_category, ''), ?) IN ?", whatsapp.FailureUnknown, req.ErrorClasses)
+		}
+		return query
+	}
+	retriedRecipients := func() *gorm.DB {
+		return failedRecipients().Where("COALESCE(error_category, '') NOT IN ? AND attempts < ?", whatsapp.PermanentFailures, a.Config.Worker.MaxRecipientAttempts)
+	}
+	retriedMessages := `metadata->>'recipient_id' IN (?)`
+
+	// Count failed recipients, and those that will be retried
+	var failedCount, retryCount int64
+	failedRecipients().Count(&failedCount)
+	retriedRecipients().Count(&retryCount)
 
 	if failedCount == 0 {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No failed messages to retry", nil, "")
 	}
+	if retryCount == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No failed messages can be retried: they failed permanently or were sent the most times allowed", nil, "")
+	}
 
-	// Reset failed recipients to pending
-	if err := a.DB.Model(&models.BulkMessageRecipient{}).
-		Where("campaign_id = ? AND status = ?", id, "failed").
-		Updates(map[string]interface{}{
-			"status":        "pending",
-			"error_message": "",
-		}).Error; err != nil {
-		a.Log.Error("Failed to reset failed recipients", "error", err)
-		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reset failed recipients", nil, "")
+	// Note the retry on each failed message's timeline. The messages stay failed; the
+	// retry is a new attempt, sent as a new message.
+	if err := a.DB.Exec(`INSERT INTO message_events (organization_id, message_id, event, source, occurred_at, created_at)
+		SELECT organization_id, id, ?, ?, NOW(), NOW() FROM messages
+		WHERE metadata->>'campaign_id' = ? AND status = ? AND deleted_at IS NULL AND `+retriedMessages,
+		models.MessageEventRetried, models.MessageEventSourceApp, id.String(), "failed", retriedRecipients().Select("id::text")).Error; err != nil {
+		a.Log.Error("Failed to record message retries", "error", err)
 	}
 
-	// Reset failed messages in messages table to pending
-	if err := a.DB.Model(&models.Message{}).
-		Where("metadata->>'campaign_id' = ? AND status = ?", id.String(), "failed").
+	// Reset failed recipients to pending; their failed send is already in their attempts
+	if err := retriedRecipients().
 		Updates(map[string]interface{}{
-			"status":        "pending",
-			"error_message": "",
+			"status":         "pending",
+			"error_message":  "",
+			"error_code":     0,
+			"error_category": "",
 		}).Error; err != nil {
-		a.Log.Error("Failed to reset failed messages", "error", err)
+		a.Log.Error("Failed to reset failed recipients", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reset failed recipients", nil, "")
 	}
 
 	// Recalculate campaign stats from messages table
@@ -544,11 +1036,12 @@ func (a *App) RetryFailed(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update campaign", nil, "")
 	}
 
-	a.Log.Info("Retrying failed messages", "campaign_id", id, "failed_count", failedCount)
+	a.Log.Info("Retrying failed messages", "campaign_id", id, "retry_count", retryCount, "skipped_count", failedCount-retryCount, "error_classes", req.ErrorClasses)
+	go a.dispatchCampaignEvent(orgID, id, EventCampaignQueued, "")
 
 	// Enqueue campaign for processing
 	if a.Queue != nil {
-		if err := a.Queue.EnqueueCampaign(r.RequestCtx, id); err != nil {
+		if err := a.Queue.EnqueueCampaign(r.RequestCtx, id, queue.Priority(campaign.Priority)); err != nil {
 			a.Log.Error("Failed to enqueue campaign", "error", err)
 			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue campaign", nil, "")
 		}
@@ -557,13 +1050,31 @@ func (a *App) RetryFailed(r *fastglue.Request) error {
 	}
 
 	return r.SendEnvelope(map[string]interface{}{
-		"message":     "Retrying failed messages",
-		"retry_count": failedCount,
-		"status":      "queued",
+		"message":       "Retrying failed messages",
+		"retry_count":   retryCount,
+		"skipped_count": failedCount - retryCount,
+		"status":        "queued",
 	})
 }
 
-// ImportRecipients implements adding recipients to a campaign
+// recipientImportBatchSize is how many recipients an import inserts at a time
+const recipientImportBatchSize = 1000
+
+// errRecipientsNotRequeued reports recipients were added to a campaign that finished
+// meanwhile, but it couldn't be queued again to send to them
+var errRecipientsNotRequeued = errors.New("recipients added but the campaign could not be requeued")
+
+// RecipientImportResult is the outcome of adding recipients to a campaign
+type RecipientImportResult struct {
+	AddedCount      int    `json:"added_count"`
+	SkippedCount    int    `json:"skipped_count"` // Without a phone number, or already in the campaign once it was sending
+	TotalRecipients int64  `json:"total_recipients"`
+	Status          string `json:"status"` // The campaign's, queued again if it had completed
+}
+
+// ImportRecipients implements adding recipients to a campaign. With ?async=true the
+// recipients are added by a background job, whose progress can be followed at
+// /api/jobs/{id} and over the WebSocket, and the job is returned straight away.
 func (a *App) ImportRecipients(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
 	if err != nil {
@@ -581,8 +1092,8 @@ func (a *App) ImportRecipients(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
 	}
 
-	if campaign.Status != "draft" {
-		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only add recipients to draft campaigns", nil, "")
+	if !campaignUnfinished(campaign.Status) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only add recipients to draft, queued, processing or paused campaigns", nil, "")
 	}
 
 	var req struct {
@@ -592,34 +1103,252 @@ func (a *App) ImportRecipients(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
-	// Create recipients
-	recipients := make([]models.BulkMessageRecipient, len(req.Recipients))
-	for i, rec := range req.Recipients {
-		recipients[i] = models.BulkMessageRecipient{
-			CampaignID:     id,
-			PhoneNumber:    rec.PhoneNumber,
-			RecipientName:  rec.RecipientName,
-			TemplateParams: models.JSONB(rec.TemplateParams),
-			Status:         "pending",
+	if string(r.RequestCtx.QueryArgs().Peek("async")) == "true" {
+		tracker, err := a.startJob(r, orgID, models.JobTypeRecipientImport, &campaign.ID)
+		if err != nil {
+			a.Log.Error("Failed to create recipient import job", "error", err, "campaign_id", id)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to add recipients", nil, "")
 		}
+		go func() {
+			result, err := a.importRecipients(context.Background(), &campaign, req.Recipients, tracker)
+			switch {
+			case errors.Is(err, errRecipientsNotRequeued):
+				tracker.Fail("Recipients added but the campaign could not be requeued")
+			case err != nil:
+				tracker.Fail("Failed to add recipients")
+			default:
+				tracker.Complete("", models.JSONB{
+					"added_count":      result.AddedCount,
+					"skipped_count":    result.SkippedCount,
+					"total_recipients": result.TotalRecipients,
+					"status":           result.Status,
+				})
+			}
+		}()
+
+		return r.SendEnvelope(map[string]interface{}{
+			"message": "Recipients are being added",
+			"job_id":  tracker.JobID(),
+		})
 	}
 
-	if err := a.DB.Create(&recipients).Error; err != nil {
-		a.Log.Error("Failed to add recipients", "error", err)
+	result, err := a.importRecipients(r.RequestCtx, &campaign, req.Recipients, nil)
+	if errors.Is(err, errRecipientsNotRequeued) {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Recipients added but the campaign could not be requeued", nil, "")
+	}
+	if err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to add recipients", nil, "")
 	}
 
+	return r.SendEnvelope(map[string]interface{}{
+		"message":          "Recipients added successfully",
+		"added_count":      result.AddedCount,
+		"skipped_count":    result.SkippedCount,
+		"total_recipients": result.TotalRecipients,
+		"status":           result.Status,
+	})
+}
+
+// importRecipients adds recipients to the campaign in batches, reporting progress to
+// tracker if there is one. Recipients inserted before an error stay in the campaign.
+func (a *App) importRecipients(ctx context.Context, campaign *models.BulkMessageCampaign, input []RecipientRequest, tracker *jobs.Tracker) (*RecipientImportResult, error) {
+	id := campaign.ID
+	tracker.Start(len(input))
+
+	// Once a campaign is sending, numbers already in it have been or will be messaged
+	// by it, so appending them again would message them twice
+	inFlight := campaign.Status != "draft" && campaign.Status != "scheduled"
+	existing := map[string]bool{}
+	if inFlight {
+		var phones []string
+		if err := a.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ?", id).Pluck("phone_number", &phones).Error; err != nil {
+			a.Log.Error("Failed to load campaign recipients", "error", err, "campaign_id", id)
+			return nil, err
+		}
+		for _, phone := range phones {
+			existing[strings.TrimPrefix(phone, "+")] = true
+		}
+	}
+
+	// Create recipients
+	result := &RecipientImportResult{Status: campaign.Status}
+	for start := 0; start < len(input); start += recipientImportBatchSize {
+		chunk := input[start:min(start+recipientImportBatchSize, len(input))]
+		recipients := make([]models.BulkMessageRecipient, 0, len(chunk))
+		for i, rec := range chunk {
+			if strings.TrimSpace(rec.PhoneNumber) == "" {
+				tracker.AddError(fmt.Sprintf("Recipient %d: phone_number is required", start+i+1))
+				continue
+			}
+			if inFlight {
+				phone := strings.TrimPrefix(rec.PhoneNumber, "+")
+				if existing[phone] {
+					continue
+				}
+				existing[phone] = true
+			}
+			recipients = append(recipients, models.BulkMessageRecipient{
+				CampaignID:     id,
+				PhoneNumber:    rec.PhoneNumber,
+				RecipientName:  rec.RecipientName,
+				TemplateParams: models.JSONB(rec.TemplateParams),
+				Status:         "pending",
+			})
+		}
+
+		if len(recipients) > 0 {
+			if err := a.DB.Create(&recipients).Error; err != nil {
+				a.Log.Error("Failed to add recipients", "error", err)
+				return nil, err
+			}
+		}
+		result.AddedCount += len(recipients)
+		result.SkippedCount += len(chunk) - len(recipients)
+		tracker.Advance(len(chunk))
+	}
+
 	// Update total recipients count
-	var totalCount int64
-	a.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ?", id).Count(&totalCount)
-	a.DB.Model(&campaign).Update("total_recipients", totalCount)
+	a.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ?", id).Count(&result.TotalRecipients)
+	a.DB.Model(campaign).Update("total_recipients", result.TotalRecipients)
 
-	a.Log.Info("Recipients added to campaign", "campaign_id", id, "count", len(req.Recipients))
+	// A running campaign picks up new pending recipients on its next pass, but the
+	// worker may have finished between the status check and the insert
+	if inFlight && result.AddedCount > 0 {
+		requeued, err := a.requeueCompletedCampaign(ctx, campaign)
+		if err != nil {
+			a.Log.Error("Failed to requeue campaign", "error", err, "campaign_id", id)
+			return result, errRecipientsNotRequeued
+		}
+		if requeued {
+			result.Status = "queued"
+		}
+	}
+
+	a.Log.Info("Recipients added to campaign", "campaign_id", id, "count", result.AddedCount, "status", result.Status)
+	return result, nil
+}
+
+// campaignUnfinished reports whether a campaign in status can still have its
+// recipients changed: before it starts, or while it is waiting, sending or paused
+func campaignUnfinished(status string) bool {
+	switch status {
+	case "draft", "scheduled", "pending_template", "queued", "processing", "held", "paused":
+		return true
+	}
+	return false
+}
+
+// requeueCompletedCampaign queues a campaign again if it completed while recipients
+// were being appended, so they aren't left pending
+func (a *App) requeueCompletedCampaign(ctx context.Context, campaign *models.BulkMessageCampaign) (bool, error) {
+	result := a.DB.Model(&models.BulkMessageCampaign{}).
+		Where("id = ? AND status = ?", campaign.ID, "completed").
+		Updates(map[string]interface{}{"status": "queued", "completed_at": nil})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if a.Queue != nil {
+		if err := a.Queue.EnqueueCampaign(ctx, campaign.ID, queue.Priority(campaign.Priority)); err != nil {
+			return true, err
+		}
+	} else {
+		go a.processCampaign(campaign.ID)
+	}
+	return true, nil
+}
+
+// SkipRecipientsRequest is a suppression list to apply to a campaign
+type SkipRecipientsRequest struct {
+	PhoneNumbers []string `json:"phone_numbers"`
+	Reason       string   `json:"reason"` // e.g. "Legal request"
+}
+
+// SkipRecipients marks a campaign's pending recipients whose numbers are on the
+// uploaded list as skipped, so a running campaign doesn't reach them. Recipients
+// already sent are reported but left as they are.
+func (a *App) SkipRecipients(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := a.getUserIDFromContext(r)
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+	if !campaignUnfinished(campaign.Status) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Can only skip recipients of campaigns that haven't finished", nil, "")
+	}
+
+	var req SkipRecipientsRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	// Match on digits only, so "+91 98765-43210" skips a recipient stored as 919876543210
+	phones := make([]string, 0, len(req.PhoneNumbers))
+	for _, phone := range req.PhoneNumbers {
+		digits := strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, phone)
+		if digits != "" {
+			phones = append(phones, digits)
+		}
+	}
+	if len(phones) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "phone_numbers is required", nil, "")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	errMsg := "Skipped"
+	if reason != "" {
+		errMsg += ": " + reason
+	}
+
+	matchPhones := `regexp_replace(phone_number, '\D', '', 'g') IN ?`
+	result := a.DB.Model(&models.BulkMessageRecipient{}).
+		Where("campaign_id = ? AND status = ?", id, "pending").
+		Where(matchPhones, phones).
+		Updates(map[string]interface{}{
+			"status":        "skipped",
+			"error_message": errMsg,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to skip recipients", "error", result.Error, "campaign_id", id)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to skip recipients", nil, "")
+	}
+
+	// Matches the worker had already reached
+	var alreadySent int64
+	a.DB.Model(&models.BulkMessageRecipient{}).
+		Where("campaign_id = ? AND status NOT IN ?", id, []string{"pending", "skipped"}).
+		Where(matchPhones, phones).
+		Count(&alreadySent)
+
+	a.Log.Info("Recipients skipped",
+		"campaign_id", id,
+		"user_id", userID,
+		"skipped", result.RowsAffected,
+		"already_sent", alreadySent,
+		"reason", reason,
+	)
 
 	return r.SendEnvelope(map[string]interface{}{
-		"message":          "Recipients added successfully",
-		"added_count":      len(req.Recipients),
-		"total_recipients": totalCount,
+		"message":       "Recipients skipped",
+		"skipped_count": result.RowsAffected,
+		"already_sent":  alreadySent,
 	})
 }
 
@@ -684,22 +1413,29 @@ func (a *App) processCampaign(campaignID uuid.UUID) {
 	if err := a.DB.Where("name = ? AND organization_id = ?", campaign.WhatsAppAccount, campaign.OrganizationID).First(&account).Error; err != nil {
 		a.Log.Error("Failed to load WhatsApp account", "error", err, "account_name", campaign.WhatsAppAccount)
 		a.DB.Model(&campaign).Update("status", "failed")
+		a.dispatchCampaignEvent(campaign.OrganizationID, campaignID, EventCampaignFailed, "Failed to load WhatsApp account")
 		return
 	}
 
 	// Update status to processing
 	a.DB.Model(&campaign).Update("status", "processing")
+	a.dispatchCampaignEvent(campaign.OrganizationID, campaignID, EventCampaignStarted, "")
 
 	// Get all pending recipients
 	var recipients []models.BulkMessageRecipient
 	if err := a.DB.Where("campaign_id = ? AND status = ?", campaignID, "pending").Find(&recipients).Error; err != nil {
 		a.Log.Error("Failed to load recipients", "error", err, "campaign_id", campaignID)
 		a.DB.Model(&campaign).Update("status", "failed")
+		a.dispatchCampaignEvent(campaign.OrganizationID, campaignID, EventCampaignFailed, "Failed to load recipients")
 		return
 	}
 
 	a.Log.Info("Processing recipients", "campaign_id", campaignID, "count", len(recipients))
 
+	// Split campaigns send each recipient one of their variants' templates
+	variants := a.campaignVariants(campaignID)
+	settings := a.orgSettings(campaign.OrganizationID)
+
 	sentCount := 0
 	failedCount := 0
 
@@ -712,6 +1448,17 @@ func (a *App) processCampaign(campaignID uuid.UUID) {
 			return
 		}
 
+		// Skip recipients suppressed since they were loaded
+		var current models.BulkMessageRecipient
+		if err := a.DB.Select("status").Where("id = ?", recipient.ID).First(&current).Error; err == nil && current.Status != "pending" {
+			continue
+		}
+
+		template, variantName := campaign.Template, ""
+		if variant := models.PickCampaignVariant(campaignID, recipient.PhoneNumber, variants); variant != nil {
+			template, variantName = variant.Template, variant.Name
+		}
+
 		// Get or create contact for this recipient
 		contact, _ := a.getOrCreateContact(campaign.OrganizationID, recipient.PhoneNumber, recipient.RecipientName)
 		if contact == nil {
@@ -724,8 +1471,21 @@ func (a *App) processCampaign(campaignID uuid.UUID) {
 			continue
 		}
 
+		// Fill parameters the recipient doesn't give from the contact's fields, then
+		// apply the campaign's policy for the ones still missing
+		params := a.fillContactTemplateParams(settings, template, contact, recipient.TemplateParams)
+		params, missing := campaign.ResolveTemplateParams(template, params)
+		if len(missing) > 0 {
+			a.DB.Model(&recipient).Updates(map[string]interface{}{
+				"status":        "skipped",
+				"error_message": models.MissingParamsError(missing),
+			})
+			continue
+		}
+		recipient.TemplateParams = params
+
 		// Send template message
-		waMessageID, err := a.sendTemplateMessage(&account, campaign.Template, &recipient)
+		waMessageID, err := a.sendTemplateMessage(&account, template, &recipient)
 
 		// Create Message record with campaign_id in metadata
 		message := models.Message{
@@ -741,10 +1501,13 @@ func (a *App) processCampaign(campaignID uuid.UUID) {
 				"recipient_name": recipient.RecipientName,
 			},
 		}
-		if campaign.Template != nil {
-			message.TemplateName = campaign.Template.Name
+		if variantName != "" {
+			message.Metadata["campaign_variant"] = variantName
+		}
+		if template != nil {
+			message.TemplateName = template.Name
 			// Store template body with substituted values for display in chat
-			content := campaign.Template.BodyContent
+			content := template.BodyContent
 			// Replace placeholders {{1}}, {{2}}, etc. with actual values
 			if recipient.TemplateParams != nil {
 				for i := 1; i <= 10; i++ {
@@ -778,6 +1541,7 @@ func (a *App) processCampaign(campaignID uuid.UUID) {
 		recipientUpdate := map[string]interface{}{
 			"status":               message.Status,
 			"whats_app_message_id": waMessageID,
+			"variant":              variantName,
 		}
 		if message.Status == "failed" {
 			recipientUpdate["error_message"] = message.ErrorMessage
@@ -833,6 +1597,8 @@ func (a *App) processCampaign(campaignID uuid.UUID) {
 		})
 	}
 
+	a.dispatchCampaignEvent(campaign.OrganizationID, campaignID, EventCampaignCompleted, "")
+
 	a.Log.Info("Campaign completed", "campaign_id", campaignID, "sent", sentCount, "failed", failedCount)
 }
 
@@ -911,7 +1677,8 @@ func (a *App) recalculateCampaignStats(campaignID uuid.UUID) {
 	}
 }
 
-// sendTemplateMessage sends a template message via WhatsApp Cloud API
+// sendTemplateMessage sends a template message via WhatsApp Cloud API, retrying
+// transient failures
 func (a *App) sendTemplateMessage(account *models.WhatsAppAccount, template *models.Template, recipient *models.BulkMessageRecipient) (string, error) {
 	waAccount := &whatsapp.Account{
 		PhoneID:     account.PhoneID,
@@ -943,6 +1710,9 @@ func (a *App) sendTemplateMessage(account *models.WhatsAppAccount, template *mod
 		}
 	}
 
+	// Retry timeouts, throttling and outages; permanent errors fail straight away
 	ctx := context.Background()
-	return a.WhatsApp.SendTemplateMessageWithComponents(ctx, waAccount, recipient.PhoneNumber, template.Name, template.Language, components)
+	return whatsapp.WithRetry(ctx, func() (string, error) {
+		return a.WhatsApp.SendTemplateMessageWithComponents(ctx, waAccount, recipient.PhoneNumber, template.Name, template.Language, components)
+	})
 }
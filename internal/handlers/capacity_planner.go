@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// messagingTierLimits are the business-initiated conversations each messaging limit
+// tier allows per rolling 24 hours; -1 is unlimited
+var messagingTierLimits = map[string]int{
+	"TIER_50":        50,
+	"TIER_250":       250,
+	"TIER_1K":        1000,
+	"TIER_2K":        2000,
+	"TIER_10K":       10000,
+	"TIER_100K":      100000,
+	"TIER_UNLIMITED": -1,
+}
+
+// nextMessagingTier is the tier a number is upgraded to from each tier
+var nextMessagingTier = map[string]string{
+	"TIER_50":   "TIER_250",
+	"TIER_250":  "TIER_1K",
+	"TIER_1K":   "TIER_10K",
+	"TIER_2K":   "TIER_10K",
+	"TIER_10K":  "TIER_100K",
+	"TIER_100K": "TIER_UNLIMITED",
+}
+
+const (
+	// defaultCampaignSendRate is the messages per second assumed per recipient sent to
+	// at once when an account has no campaign history, up to the account's throughput
+	defaultCampaignSendRate = 5.0
+	// maxCapacityPlanDays bounds the day-by-day projection
+	maxCapacityPlanDays = 60
+	// Campaigns measured for an account's send rate
+	sendRateSampleCampaigns = 5
+	sendRateMinRecipients   = 20
+)
+
+// CapacityPlanRequest is the request body for planning campaign capacity
+type CapacityPlanRequest struct {
+	Campaigns    []PlannedCampaign `json:"campaigns"`
+	DeadlineDays int               `json:"deadline_days"` // Days to finish all sends in (default 1)
+}
+
+// PlannedCampaign is a campaign to plan for: an existing campaign, or a size on an
+// account
+type PlannedCampaign struct {
+	CampaignID      string `json:"campaign_id"`
+	WhatsAppAccount string `json:"whatsapp_account"`
+	Recipients      int    `json:"recipients"`
+}
+
+// CapacityPlanDay is one day of an account's projected sending
+type CapacityPlanDay struct {
+	Day       int     `json:"day"` // 1 is the first day of sending
+	Tier      string  `json:"tier"`
+	Limit     int     `json:"limit"` // -1 is unlimited
+	Sent      int     `json:"sent"`
+	SendHours float64 `json:"send_hours"` // Time the worker spends sending
+}
+
+// AccountCapacityPlan projects how an account sends its planned campaigns
+type AccountCapacityPlan struct {
+	WhatsAppAccount   string            `json:"whatsapp_account"`
+	Tier              string            `json:"tier"`
+	QualityRating     string            `json:"quality_rating"`
+	UsedLast24h       int               `json:"used_last_24h"` // Business-initiated conversations already opened
+	SendRate          float64           `json:"send_rate"`     // Messages per second
+	SendRateMeasured  bool              `json:"send_rate_measured"`
+	Recipients        int               `json:"recipients"`
+	Days              int               `json:"days"`       // Days until everything is sent
+	SendHours         float64           `json:"send_hours"` // Total time spent sending
+	FitsDeadline      bool              `json:"fits_deadline"`
+	Shortfall         int               `json:"shortfall"` // Recipients left unsent at the deadline
+	AdditionalNumbers int               `json:"additional_numbers"`
+	Schedule          []CapacityPlanDay `json:"schedule"`
+	Warnings          []string          `json:"warnings"`
+}
+
+// PlanCampaignCapacity projects, for each account, how many days and how much sending
+// time planned campaigns will take given the account's messaging limit tier, quality
+// rating and Meta's ramp rules, and how many more numbers would be needed to finish
+// within the deadline
+func (a *App) PlanCampaignCapacity(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req CapacityPlanRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.Campaigns) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "campaigns is required", nil, "")
+	}
+	if req.DeadlineDays == 0 {
+		req.DeadlineDays = 1
+	}
+	if req.DeadlineDays < 0 || req.DeadlineDays > maxCapacityPlanDays {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+			fmt.Sprintf("deadline_days must be between 1 and %d", maxCapacityPlanDays), nil, "")
+	}
+
+	// Total the planned recipients per account, in the order accounts first appear
+	var order []string
+	recipients := map[string]int{}
+	for i, planned := range req.Campaigns {
+		account, size := planned.WhatsAppAccount, planned.Recipients
+		if planned.CampaignID != "" {
+			campaignID, err := uuid.Parse(planned.CampaignID)
+			if err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Campaign %d: invalid campaign ID", i+1), nil, "")
+			}
+			var campaign models.BulkMessageCampaign
+			if err := a.DB.Where("id = ? AND organization_id = ?", campaignID, orgID).First(&campaign).Error; err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusNotFound, fmt.Sprintf("Campaign %d: campaign not found", i+1), nil, "")
+			}
+			account = campaign.WhatsAppAccount
+			if size == 0 {
+				size = campaign.TotalRecipients - campaign.SentCount - campaign.FailedCount
+			}
+		}
+		if account == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Campaign %d: whatsapp_account is required", i+1), nil, "")
+		}
+		if size < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Campaign %d: recipients can't be negative", i+1), nil, "")
+		}
+		if _, ok := recipients[account]; !ok {
+			order = append(order, account)
+		}
+		recipients[account] += size
+	}
+
+	plans := make([]AccountCapacityPlan, 0, len(order))
+	totals := map[string]int{"recipients": 0, "additional_numbers": 0}
+	for _, name := range order {
+		var account models.WhatsAppAccount
+		if err := a.DB.Where("organization_id = ? AND name = ?", orgID, name).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("WhatsApp account %s not found", name), nil, "")
+		}
+		plan, err := a.planAccountCapacity(&account, recipients[name], req.DeadlineDays)
+		if err != nil {
+			a.Log.Error("Failed to plan account capacity", "error", err, "account", name)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to plan capacity", nil, "")
+		}
+		totals["recipients"] += plan.Recipients
+		totals["additional_numbers"] += plan.AdditionalNumbers
+		plans = append(plans, plan)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"deadline_days": req.DeadlineDays,
+		"accounts":      plans,
+		"totals":        totals,
+	})
+}
+
+// planAccountCapacity projects an account's sends day by day. Meta upgrades a number
+// to the next tier once it has used half its limit with a quality rating that isn't
+// low, so each day that does so is taken to raise the next day's limit. Tier and
+// quality come from the account's last status check.
+func (a *App) planAccountCapacity(account *models.WhatsAppAccount, recipients, deadlineDays int) (AccountCapacityPlan, error) {
+	plan := AccountCapacityPlan{
+		WhatsAppAccount: account.Name,
+		Tier:            account.MessagingLimitTier,
+		QualityRating:   account.QualityRating,
+		Recipients:      recipients,
+		Schedule:        []CapacityPlanDay{},
+		Warnings:        []string{},
+	}
+	if _, ok := messagingTierLimits[plan.Tier]; !ok {
+		plan.Warnings = append(plan.Warnings, "Messaging limit tier is unknown, assuming TIER_250; check the account's health to refresh it")
+		plan.Tier = "TIER_250"
+	}
+	switch plan.QualityRating {
+	case "RED":
+		plan.Warnings = append(plan.Warnings, "Quality rating is low: the tier won't be upgraded and may be lowered")
+	case "YELLOW":
+		plan.Warnings = append(plan.Warnings, "Quality rating is medium: a drop to low would stop tier upgrades")
+	}
+
+	used, err := a.businessInitiatedLast24h(account)
+	if err != nil {
+		return plan, err
+	}
+	plan.UsedLast24h = used
+
+	rate, measured, err := a.campaignSendRate(account)
+	if err != nil {
+		return plan, err
+	}
+	plan.SendRate, plan.SendRateMeasured = rate, measured
+
+	remaining := recipients
+	tier := plan.Tier
+	for day := 1; remaining > 0 && day <= maxCapacityPlanDays; day++ {
+		limit := messagingTierLimits[tier]
+		available := limit
+		if day == 1 && limit >= 0 {
+			available = max(limit-used, 0)
+		}
+		sent := remaining
+		if available >= 0 && sent > available {
+			sent = available
+		}
+		remaining -= sent
+		hours := float64(sent) / rate / 3600
+		plan.Schedule = append(plan.Schedule, CapacityPlanDay{
+			Day:       day,
+			Tier:      tier,
+			Limit:     limit,
+			Sent:      sent,
+			SendHours: math.Round(hours*100) / 100,
+		})
+		plan.SendHours += hours
+		plan.Days = day
+		if day <= deadlineDays {
+			plan.Shortfall = remaining
+		}
+
+		opened := sent
+		if day == 1 {
+			opened += used
+		}
+		if next, ok := nextMessagingTier[tier]; ok && plan.QualityRating != "RED" && opened >= limit/2 {
+			tier = next
+		}
+	}
+	plan.SendHours = math.Round(plan.SendHours*100) / 100
+	if remaining > 0 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("%d recipients would still be unsent after %d days", remaining, maxCapacityPlanDays))
+	}
+
+	// A day's sending has to fit in the day as well as in the limit
+	for _, d := range plan.Schedule {
+		if d.SendHours > 24 {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("Day %d needs %.1f hours of sending at %.1f messages per second", d.Day, d.SendHours, rate))
+			break
+		}
+	}
+
+	plan.FitsDeadline = plan.Shortfall == 0
+	if !plan.FitsDeadline {
+		plan.AdditionalNumbers = additionalNumbersNeeded(plan.Shortfall, deadlineDays, account.BusinessVerificationStatus == "verified")
+	}
+	return plan, nil
+}
+
+// additionalNumbersNeeded estimates how many new numbers would send shortfall
+// recipients within deadlineDays. New numbers start at TIER_1K once the business is
+// verified and at TIER_250 before that, ramping up like any other number.
+func additionalNumbersNeeded(shortfall, deadlineDays int, verified bool) int {
+	tier := "TIER_250"
+	if verified {
+		tier = "TIER_1K"
+	}
+
+	capacity := 0
+	for day := 1; day <= deadlineDays; day++ {
+		limit := messagingTierLimits[tier]
+		if limit < 0 {
+			return 1
+		}
+		capacity += limit
+		if next, ok := nextMessagingTier[tier]; ok {
+			tier = next
+		}
+	}
+	return int(math.Ceil(float64(shortfall) / float64(capacity)))
+}
+
+// businessInitiatedLast24h counts the contacts the account messaged with a template
+// in the last 24 hours, each of which opened a conversation counted against the limit
+func (a *App) businessInitiatedLast24h(account *models.WhatsAppAccount) (int, error) {
+	var count int64
+	if err := a.DB.Model(&models.Message{}).
+		Where("organization_id = ? AND whats_app_account = ? AND direction = ? AND message_type = ? AND status <> ? AND created_at >= ?",
+			account.OrganizationID, account.Name, "outgoing", "template", "failed", time.Now().Add(-24*time.Hour)).
+		Distinct("contact_id").
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count recent conversations: %w", err)
+	}
+	return int(count), nil
+}
+
+// campaignSendRate measures the account's messages per second from its recent
+// completed campaigns, falling back to defaultCampaignSendRate for each recipient the
+// account sends to at once, capped by its messages per second, without history
+func (a *App) campaignSendRate(account *models.WhatsAppAccount) (float64, bool, error) {
+	var rows []struct {
+		Sent    int64
+		Seconds float64
+	}
+	if err := a.DB.Raw(`
+		SELECT COUNT(r.sent_at) AS sent, EXTRACT(EPOCH FROM MAX(r.sent_at) - MIN(r.sent_at)) AS seconds
+		FROM bulk_message_campaigns c
+		JOIN bulk_message_recipients r ON r.campaign_id = c.id
+		WHERE c.organization_id = ? AND c.whats_app_account = ? AND c.status = ? AND c.deleted_at IS NULL
+		GROUP BY c.id, c.completed_at
+		HAVING COUNT(r.sent_at) >= ?
+		ORDER BY c.completed_at DESC
+		LIMIT ?`,
+		account.OrganizationID, account.Name, "completed", sendRateMinRecipients, sendRateSampleCampaigns,
+	).Scan(&rows).Error; err != nil {
+		return 0, false, fmt.Errorf("failed to measure send rate: %w", err)
+	}
+
+	var sent int64
+	var seconds float64
+	for _, row := range rows {
+		sent += row.Sent
+		seconds += row.Seconds
+	}
+	if sent == 0 || seconds <= 0 {
+		concurrency := a.Config.Worker.CampaignConcurrency
+		if account.CampaignConcurrency > 0 {
+			concurrency = account.CampaignConcurrency
+		}
+		concurrency = max(1, min(concurrency, models.MaxCampaignConcurrency))
+		throughput := a.Config.Worker.MessagesPerSecond
+		if account.MessagesPerSecond > 0 {
+			throughput = account.MessagesPerSecond
+		}
+		return math.Min(defaultCampaignSendRate*float64(concurrency), float64(max(1, throughput))), false, nil
+	}
+	return math.Round(float64(sent)/seconds*100) / 100, true, nil
+}
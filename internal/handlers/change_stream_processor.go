@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/changestream"
+)
+
+// ChangeStreamProcessor publishes captured change events to organizations' Redis
+// streams and Kafka topics
+type ChangeStreamProcessor struct {
+	app      *App
+	relay    *changestream.Relay
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewChangeStreamProcessor creates a new change stream processor
+func NewChangeStreamProcessor(app *App, interval time.Duration) *ChangeStreamProcessor {
+	relay := changestream.NewRelay(app.DB, app.Redis)
+	// Message text leaves the system with the organization's redaction rules applied,
+	// the same as in outbound webhooks
+	relay.Transform = func(e *changestream.Envelope) {
+		if e.Type != changestream.EventMessageCreated {
+			return
+		}
+		if content, ok := e.Data["content"].(string); ok {
+			e.Data["content"] = app.redactMessage(e.OrganizationID, content)
+		}
+	}
+
+	return &ChangeStreamProcessor{
+		app:      app,
+		relay:    relay,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the publishing loop
+func (p *ChangeStreamProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Change stream processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	defer p.relay.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Change stream processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Change stream processor stopped")
+			return
+		case <-ticker.C:
+			if err := p.relay.Run(ctx); err != nil && ctx.Err() == nil {
+				p.app.Log.Error("Failed to publish change events", "error", err)
+			}
+		}
+	}
+}
+
+// Stop stops the change stream processor
+func (p *ChangeStreamProcessor) Stop() {
+	close(p.stopCh)
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/changestream"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// kafkaTopicPattern matches the names Kafka accepts for topics
+var kafkaTopicPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,249}$`)
+
+// changeStreamTestTimeout bounds publishing the test event
+const changeStreamTestTimeout = 15 * time.Second
+
+// ChangeStreamRequest is the request body for configuring the organization's change
+// stream. Omitted secrets keep their saved value; an empty string clears them.
+type ChangeStreamRequest struct {
+	IsEnabled     *bool    `json:"is_enabled,omitempty"`
+	Sink          string   `json:"sink"`   // redis or kafka
+	Target        string   `json:"target"` // Stream key on an external Redis, or Kafka topic
+	Events        []string `json:"events"` // Empty publishes every event type
+	MaxLen        int64    `json:"max_len"`
+	RedisURL      *string  `json:"redis_url,omitempty"` // Empty publishes to the platform's Redis
+	KafkaRESTURL  string   `json:"kafka_rest_url"`
+	KafkaUsername string   `json:"kafka_username"`
+	KafkaPassword *string  `json:"kafka_password,omitempty"`
+}
+
+// GetChangeStream returns the organization's change stream and the events waiting to
+// be published
+func (a *App) GetChangeStream(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var stream models.ChangeStream
+	if err := a.DB.Where("organization_id = ?", orgID).First(&stream).Error; err != nil {
+		return r.SendEnvelope(map[string]any{
+			"stream":              nil,
+			"platform_stream_key": changestream.PlatformStreamKey(orgID),
+		})
+	}
+	return r.SendEnvelope(a.changeStreamResponse(&stream))
+}
+
+// SetChangeStream creates or updates the organization's change stream
+func (a *App) SetChangeStream(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req ChangeStreamRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	var stream models.ChangeStream
+	if err := a.DB.Where("organization_id = ?", orgID).First(&stream).Error; err != nil {
+		stream = models.ChangeStream{OrganizationID: orgID, IsEnabled: true}
+	}
+
+	if req.IsEnabled != nil {
+		stream.IsEnabled = *req.IsEnabled
+	}
+	if req.RedisURL != nil {
+		stream.RedisURL = strings.TrimSpace(*req.RedisURL)
+	}
+	if req.KafkaPassword != nil {
+		stream.KafkaPassword = *req.KafkaPassword
+	}
+	stream.Sink = req.Sink
+	stream.Target = strings.TrimSpace(req.Target)
+	stream.MaxLen = req.MaxLen
+	stream.KafkaRESTURL = strings.TrimSpace(req.KafkaRESTURL)
+	stream.KafkaUsername = strings.TrimSpace(req.KafkaUsername)
+
+	stream.Events = models.StringArray{}
+	for _, event := range req.Events {
+		if !changestream.IsEventType(event) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unknown event type: "+event, nil, "")
+		}
+		stream.Events = append(stream.Events, event)
+	}
+	if stream.MaxLen < 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "max_len can't be negative", nil, "")
+	}
+
+	switch stream.Sink {
+	case models.ChangeStreamRedis:
+		stream.KafkaRESTURL, stream.KafkaUsername, stream.KafkaPassword = "", "", ""
+		if stream.RedisURL == "" {
+			stream.Target = changestream.PlatformStreamKey(orgID)
+			break
+		}
+		if _, err := redis.ParseURL(stream.RedisURL); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid Redis URL", nil, "")
+		}
+		if stream.Target == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Stream key is required", nil, "")
+		}
+	case models.ChangeStreamKafka:
+		stream.RedisURL, stream.MaxLen = "", 0
+		u, err := url.Parse(stream.KafkaRESTURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Kafka REST Proxy URL must be an http(s) URL", nil, "")
+		}
+		if !kafkaTopicPattern.MatchString(stream.Target) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid Kafka topic", nil, "")
+		}
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Sink must be redis or kafka", nil, "")
+	}
+
+	// Settings changed, so retry a failing sink right away
+	stream.LastError = ""
+	stream.LastErrorAt = nil
+	if err := a.DB.Save(&stream).Error; err != nil {
+		a.Log.Error("Failed to save change stream", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save change stream", nil, "")
+	}
+
+	return r.SendEnvelope(a.changeStreamResponse(&stream))
+}
+
+// DeleteChangeStream stops publishing the organization's events and discards those
+// still waiting
+func (a *App) DeleteChangeStream(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	result := a.DB.Where("organization_id = ?", orgID).Delete(&models.ChangeStream{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete change stream", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete change stream", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Change stream not found", nil, "")
+	}
+	a.DB.Where("organization_id = ?", orgID).Delete(&models.ChangeEvent{})
+
+	return r.SendEnvelope(map[string]string{"message": "Change stream deleted successfully"})
+}
+
+// TestChangeStream publishes a change_stream.test event with the saved settings
+func (a *App) TestChangeStream(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var stream models.ChangeStream
+	if err := a.DB.Where("organization_id = ?", orgID).First(&stream).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Change stream not found", nil, "")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), changeStreamTestTimeout)
+	defer cancel()
+	if err := changestream.Test(ctx, &stream, a.Redis); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Test event failed: "+err.Error(), nil, "")
+	}
+	return r.SendEnvelope(map[string]string{"message": "Test event published"})
+}
+
+// GetChangeStreamSchemas documents every event type at its current schema version
+func (a *App) GetChangeStreamSchemas(r *fastglue.Request) error {
+	return r.SendEnvelope(map[string]any{"schemas": changestream.Schemas})
+}
+
+func (a *App) changeStreamResponse(stream *models.ChangeStream) map[string]any {
+	var pending int64
+	a.DB.Model(&models.ChangeEvent{}).Where("organization_id = ?", stream.OrganizationID).Count(&pending)
+
+	return map[string]any{
+		"stream":              stream,
+		"has_redis_url":       stream.RedisURL != "",
+		"has_kafka_password":  stream.KafkaPassword != "",
+		"pending":             pending,
+		"platform_stream_key": changestream.PlatformStreamKey(stream.OrganizationID),
+	}
+}
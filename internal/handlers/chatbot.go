@@ -46,6 +46,13 @@ type ChatbotSettingsResponse struct {
 	ClientReminderMessage  string `json:"client_reminder_message"`
 	ClientAutoCloseMinutes int    `json:"client_auto_close_minutes"`
 	ClientAutoCloseMessage string `json:"client_auto_close_message"`
+	// Flow Session Inactivity Settings
+	SessionNudgeMinutes   int    `json:"session_nudge_minutes"`
+	SessionNudgeMessage   string `json:"session_nudge_message"`
+	SessionTimeoutHandoff bool   `json:"session_timeout_handoff"`
+	// Automated Message Limit
+	BotMessageLimit         int `json:"bot_message_limit"`
+	BotMessageWindowMinutes int `json:"bot_message_window_minutes"`
 }
 
 // ChatbotStatsResponse represents chatbot statistics
@@ -75,13 +82,14 @@ type KeywordRuleResponse struct {
 
 // ChatbotFlowResponse represents a chatbot flow for API response
 type ChatbotFlowResponse struct {
-	ID              string   `json:"id"`
-	Name            string   `json:"name"`
-	Description     string   `json:"description"`
-	TriggerKeywords []string `json:"trigger_keywords"`
-	Enabled         bool     `json:"enabled"`
-	StepsCount      int      `json:"steps_count"`
-	CreatedAt       string   `json:"created_at"`
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	TriggerKeywords  []string `json:"trigger_keywords"`
+	Enabled          bool     `json:"enabled"`
+	StepsCount       int      `json:"steps_count"`
+	PublishedVersion int      `json:"published_version"`
+	CreatedAt        string   `json:"created_at"`
 }
 
 // AIContextResponse represents an AI context for API response
@@ -182,6 +190,13 @@ func (a *App) GetChatbotSettings(r *fastglue.Request) error {
 		ClientReminderMessage:  settings.ClientReminderMessage,
 		ClientAutoCloseMinutes: settings.ClientAutoCloseMinutes,
 		ClientAutoCloseMessage: settings.ClientAutoCloseMessage,
+		// Flow Session Inactivity Settings
+		SessionNudgeMinutes:   settings.SessionNudgeMinutes,
+		SessionNudgeMessage:   settings.SessionNudgeMessage,
+		SessionTimeoutHandoff: settings.SessionTimeoutHandoff,
+		// Automated Message Limit
+		BotMessageLimit:         settings.BotMessageLimit,
+		BotMessageWindowMinutes: settings.BotMessageWindowMins,
 	}
 
 	return r.SendEnvelope(map[string]interface{}{
@@ -232,6 +247,13 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		ClientReminderMessage  *string `json:"client_reminder_message"`
 		ClientAutoCloseMinutes *int    `json:"client_auto_close_minutes"`
 		ClientAutoCloseMessage *string `json:"client_auto_close_message"`
+		// Flow Session Inactivity Settings
+		SessionNudgeMinutes   *int    `json:"session_nudge_minutes"`
+		SessionNudgeMessage   *string `json:"session_nudge_message"`
+		SessionTimeoutHandoff *bool   `json:"session_timeout_handoff"`
+		// Automated Message Limit
+		BotMessageLimit         *int `json:"bot_message_limit"`
+		BotMessageWindowMinutes *int `json:"bot_message_window_minutes"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
@@ -363,6 +385,37 @@ func (a *App) UpdateChatbotSettings(r *fastglue.Request) error {
 		settings.ClientAutoCloseMessage = *req.ClientAutoCloseMessage
 	}
 
+	// Flow Session Inactivity Settings
+	if req.SessionNudgeMinutes != nil {
+		if *req.SessionNudgeMinutes < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "session_nudge_minutes cannot be negative", nil, "")
+		}
+		settings.SessionNudgeMinutes = *req.SessionNudgeMinutes
+	}
+	if req.SessionNudgeMessage != nil {
+		settings.SessionNudgeMessage = *req.SessionNudgeMessage
+	}
+	if req.SessionTimeoutHandoff != nil {
+		settings.SessionTimeoutHandoff = *req.SessionTimeoutHandoff
+	}
+	if settings.SessionNudgeMinutes > 0 && settings.SessionTimeoutMins > 0 && settings.SessionNudgeMinutes >= settings.SessionTimeoutMins {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "session_nudge_minutes must be less than session_timeout_minutes", nil, "")
+	}
+
+	// Automated Message Limit
+	if req.BotMessageLimit != nil {
+		if *req.BotMessageLimit < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "bot_message_limit cannot be negative", nil, "")
+		}
+		settings.BotMessageLimit = *req.BotMessageLimit
+	}
+	if req.BotMessageWindowMinutes != nil {
+		if *req.BotMessageWindowMinutes < 1 || *req.BotMessageWindowMinutes > 1440 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "bot_message_window_minutes must be between 1 and 1440", nil, "")
+		}
+		settings.BotMessageWindowMins = *req.BotMessageWindowMinutes
+	}
+
 	if err := a.DB.Save(&settings).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save settings", nil, "")
 	}
@@ -620,13 +673,14 @@ func (a *App) ListChatbotFlows(r *fastglue.Request) error {
 	response := make([]ChatbotFlowResponse, len(flows))
 	for i, flow := range flows {
 		response[i] = ChatbotFlowResponse{
-			ID:              flow.ID.String(),
-			Name:            flow.Name,
-			Description:     flow.Description,
-			TriggerKeywords: flow.TriggerKeywords,
-			Enabled:         flow.IsEnabled,
-			StepsCount:      len(flow.Steps),
-			CreatedAt:       flow.CreatedAt.Format(time.RFC3339),
+			ID:               flow.ID.String(),
+			Name:             flow.Name,
+			Description:      flow.Description,
+			TriggerKeywords:  flow.TriggerKeywords,
+			Enabled:          flow.IsEnabled,
+			StepsCount:       len(flow.Steps),
+			PublishedVersion: flow.PublishedVersion,
+			CreatedAt:        flow.CreatedAt.Format(time.RFC3339),
 		}
 	}
 
@@ -651,6 +705,8 @@ type FlowStepRequest struct {
 	StoreAs         string                   `json:"store_as"`
 	NextStep        string                   `json:"next_step"`
 	SkipCondition   string                   `json:"skip_condition"`
+	Branches        []map[string]interface{} `json:"branches"`
+	Assignments     []map[string]interface{} `json:"assignments"`
 	RetryOnInvalid  bool                     `json:"retry_on_invalid"`
 	MaxRetries      int                      `json:"max_retries"`
 }
@@ -663,15 +719,16 @@ func (a *App) CreateChatbotFlow(r *fastglue.Request) error {
 	}
 
 	var req struct {
-		Name              string                 `json:"name"`
-		Description       string                 `json:"description"`
-		TriggerKeywords   []string               `json:"trigger_keywords"`
-		InitialMessage    string                 `json:"initial_message"`
-		CompletionMessage string                 `json:"completion_message"`
-		OnCompleteAction  string                 `json:"on_complete_action"`
-		CompletionConfig  map[string]interface{} `json:"completion_config"`
-		Enabled           bool                   `json:"enabled"`
-		Steps             []FlowStepRequest      `json:"steps"`
+		Name              string                   `json:"name"`
+		Description       string                   `json:"description"`
+		TriggerKeywords   []string                 `json:"trigger_keywords"`
+		InitialMessage    string                   `json:"initial_message"`
+		CompletionMessage string                   `json:"completion_message"`
+		OnCompleteAction  string                   `json:"on_complete_action"`
+		CompletionConfig  map[string]interface{}   `json:"completion_config"`
+		Variables         []map[string]interface{} `json:"variables"`
+		Enabled           bool                     `json:"enabled"`
+		Steps             []FlowStepRequest        `json:"steps"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
@@ -682,9 +739,6 @@ func (a *App) CreateChatbotFlow(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
 	}
 
-	// Use transaction for flow + steps
-	tx := a.DB.Begin()
-
 	flowID := uuid.New()
 	flow := models.ChatbotFlow{
 		BaseModel:         models.BaseModel{ID: flowID},
@@ -696,8 +750,20 @@ func (a *App) CreateChatbotFlow(r *fastglue.Request) error {
 		CompletionMessage: req.CompletionMessage,
 		OnCompleteAction:  req.OnCompleteAction,
 		CompletionConfig:  models.JSONB(req.CompletionConfig),
+		Variables:         toJSONBArray(req.Variables),
 		IsEnabled:         req.Enabled,
 	}
+	steps := flowStepsFromRequest(flowID, req.Steps)
+
+	if flow.IsEnabled {
+		errs := append(validateFlowExpressions(&flow, steps), a.validateFlowHTTPSteps(flow.OrganizationID, steps)...)
+		if len(errs) > 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, "Fix the flow's errors before enabling it", map[string]interface{}{"errors": errs}, "")
+		}
+	}
+
+	// Use transaction for flow + steps
+	tx := a.DB.Begin()
 
 	if err := tx.Create(&flow).Error; err != nil {
 		tx.Rollback()
@@ -705,40 +771,8 @@ func (a *App) CreateChatbotFlow(r *fastglue.Request) error {
 	}
 
 	// Create steps
-	for i, stepReq := range req.Steps {
-		// Convert buttons to JSONBArray
-		var buttons models.JSONBArray
-		for _, btn := range stepReq.Buttons {
-			buttons = append(buttons, btn)
-		}
-
-		step := models.ChatbotFlowStep{
-			BaseModel:       models.BaseModel{ID: uuid.New()},
-			FlowID:          flowID,
-			StepName:        stepReq.StepName,
-			StepOrder:       i + 1,
-			Message:         stepReq.Message,
-			MessageType:     stepReq.MessageType,
-			InputType:       stepReq.InputType,
-			InputConfig:     models.JSONB(stepReq.InputConfig),
-			ApiConfig:       models.JSONB(stepReq.ApiConfig),
-			Buttons:         buttons,
-			TransferConfig:  models.JSONB(stepReq.TransferConfig),
-			ValidationRegex: stepReq.ValidationRegex,
-			ValidationError: stepReq.ValidationError,
-			StoreAs:         stepReq.StoreAs,
-			NextStep:        stepReq.NextStep,
-			SkipCondition:   stepReq.SkipCondition,
-			RetryOnInvalid:  stepReq.RetryOnInvalid,
-			MaxRetries:      stepReq.MaxRetries,
-		}
-		if step.MessageType == "" {
-			step.MessageType = "text"
-		}
-		if step.MaxRetries == 0 {
-			step.MaxRetries = 3
-		}
-		if err := tx.Create(&step).Error; err != nil {
+	for i := range steps {
+		if err := tx.Create(&steps[i]).Error; err != nil {
 			tx.Rollback()
 			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create flow step", nil, "")
 		}
@@ -799,23 +833,22 @@ func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 	}
 
 	var req struct {
-		Name              *string                `json:"name"`
-		Description       *string                `json:"description"`
-		TriggerKeywords   []string               `json:"trigger_keywords"`
-		InitialMessage    *string                `json:"initial_message"`
-		CompletionMessage *string                `json:"completion_message"`
-		OnCompleteAction  *string                `json:"on_complete_action"`
-		CompletionConfig  map[string]interface{} `json:"completion_config"`
-		Enabled           *bool                  `json:"enabled"`
-		Steps             []FlowStepRequest      `json:"steps"`
+		Name              *string                  `json:"name"`
+		Description       *string                  `json:"description"`
+		TriggerKeywords   []string                 `json:"trigger_keywords"`
+		InitialMessage    *string                  `json:"initial_message"`
+		CompletionMessage *string                  `json:"completion_message"`
+		OnCompleteAction  *string                  `json:"on_complete_action"`
+		CompletionConfig  map[string]interface{}   `json:"completion_config"`
+		Variables         []map[string]interface{} `json:"variables"`
+		Enabled           *bool                    `json:"enabled"`
+		Steps             []FlowStepRequest        `json:"steps"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
 	}
 
-	tx := a.DB.Begin()
-
 	if req.Name != nil {
 		flow.Name = *req.Name
 	}
@@ -837,10 +870,29 @@ func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 	if req.CompletionConfig != nil {
 		flow.CompletionConfig = models.JSONB(req.CompletionConfig)
 	}
+	if req.Variables != nil {
+		flow.Variables = toJSONBArray(req.Variables)
+	}
 	if req.Enabled != nil {
 		flow.IsEnabled = *req.Enabled
 	}
 
+	// Steps are replaced only when given
+	steps := flowStepsFromRequest(id, req.Steps)
+	if flow.IsEnabled {
+		if len(req.Steps) == 0 {
+			if err := a.DB.Where("flow_id = ?", id).Order("step_order ASC").Find(&steps).Error; err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load flow steps", nil, "")
+			}
+		}
+		errs := append(validateFlowExpressions(&flow, steps), a.validateFlowHTTPSteps(flow.OrganizationID, steps)...)
+		if len(errs) > 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, "Fix the flow's errors before enabling it", map[string]interface{}{"errors": errs}, "")
+		}
+	}
+
+	tx := a.DB.Begin()
+
 	if err := tx.Save(&flow).Error; err != nil {
 		tx.Rollback()
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update flow", nil, "")
@@ -855,40 +907,8 @@ func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 		}
 
 		// Create new steps
-		for i, stepReq := range req.Steps {
-			// Convert buttons to JSONBArray
-			var buttons models.JSONBArray
-			for _, btn := range stepReq.Buttons {
-				buttons = append(buttons, btn)
-			}
-
-			step := models.ChatbotFlowStep{
-				BaseModel:       models.BaseModel{ID: uuid.New()},
-				FlowID:          id,
-				StepName:        stepReq.StepName,
-				StepOrder:       i + 1,
-				Message:         stepReq.Message,
-				MessageType:     stepReq.MessageType,
-				InputType:       stepReq.InputType,
-				InputConfig:     models.JSONB(stepReq.InputConfig),
-				ApiConfig:       models.JSONB(stepReq.ApiConfig),
-				Buttons:         buttons,
-				TransferConfig:  models.JSONB(stepReq.TransferConfig),
-				ValidationRegex: stepReq.ValidationRegex,
-				ValidationError: stepReq.ValidationError,
-				StoreAs:         stepReq.StoreAs,
-				NextStep:        stepReq.NextStep,
-				SkipCondition:   stepReq.SkipCondition,
-				RetryOnInvalid:  stepReq.RetryOnInvalid,
-				MaxRetries:      stepReq.MaxRetries,
-			}
-			if step.MessageType == "" {
-				step.MessageType = "text"
-			}
-			if step.MaxRetries == 0 {
-				step.MaxRetries = 3
-			}
-			if err := tx.Create(&step).Error; err != nil {
+		for i := range steps {
+			if err := tx.Create(&steps[i]).Error; err != nil {
 				tx.Rollback()
 				return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create flow step", nil, "")
 			}
@@ -905,6 +925,52 @@ func (a *App) UpdateChatbotFlow(r *fastglue.Request) error {
 	})
 }
 
+// flowStepsFromRequest builds the steps of a flow from a create or update request
+func flowStepsFromRequest(flowID uuid.UUID, reqSteps []FlowStepRequest) []models.ChatbotFlowStep {
+	steps := make([]models.ChatbotFlowStep, 0, len(reqSteps))
+	for i, stepReq := range reqSteps {
+		step := models.ChatbotFlowStep{
+			BaseModel:       models.BaseModel{ID: uuid.New()},
+			FlowID:          flowID,
+			StepName:        stepReq.StepName,
+			StepOrder:       i + 1,
+			Message:         stepReq.Message,
+			MessageType:     stepReq.MessageType,
+			InputType:       stepReq.InputType,
+			InputConfig:     models.JSONB(stepReq.InputConfig),
+			ApiConfig:       models.JSONB(stepReq.ApiConfig),
+			Buttons:         toJSONBArray(stepReq.Buttons),
+			TransferConfig:  models.JSONB(stepReq.TransferConfig),
+			ValidationRegex: stepReq.ValidationRegex,
+			ValidationError: stepReq.ValidationError,
+			StoreAs:         stepReq.StoreAs,
+			NextStep:        stepReq.NextStep,
+			SkipCondition:   stepReq.SkipCondition,
+			Branches:        toJSONBArray(stepReq.Branches),
+			Assignments:     toJSONBArray(stepReq.Assignments),
+			RetryOnInvalid:  stepReq.RetryOnInvalid,
+			MaxRetries:      stepReq.MaxRetries,
+		}
+		if step.MessageType == "" {
+			step.MessageType = "text"
+		}
+		if step.MaxRetries == 0 {
+			step.MaxRetries = 3
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// toJSONBArray converts a list of objects from a request to a JSONBArray
+func toJSONBArray(items []map[string]interface{}) models.JSONBArray {
+	var arr models.JSONBArray
+	for _, item := range items {
+		arr = append(arr, item)
+	}
+	return arr
+}
+
 // DeleteChatbotFlow deletes a chatbot flow
 func (a *App) DeleteChatbotFlow(r *fastglue.Request) error {
 	orgID, err := a.getOrgIDFromContext(r)
@@ -926,6 +992,10 @@ func (a *App) DeleteChatbotFlow(r *fastglue.Request) error {
 		tx.Rollback()
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete flow steps", nil, "")
 	}
+	if err := tx.Where("flow_id = ? AND organization_id = ?", id, orgID).Delete(&models.ChatbotFlowVersion{}).Error; err != nil {
+		tx.Rollback()
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete flow versions", nil, "")
+	}
 
 	// Delete flow
 	result := tx.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.ChatbotFlow{})
@@ -15,6 +15,7 @@ import (
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"gorm.io/gorm/clause"
 )
 
 // IncomingTextMessage represents a text, interactive, or media message from the webhook
@@ -81,17 +82,19 @@ type IncomingTextMessage struct {
 		Name      string  `json:"name,omitempty"`
 		Address   string  `json:"address,omitempty"`
 	} `json:"location,omitempty"`
-	Contacts []struct {
-		Name struct {
-			FormattedName string `json:"formatted_name"`
-			FirstName     string `json:"first_name,omitempty"`
-			LastName      string `json:"last_name,omitempty"`
-		} `json:"name"`
-		Phones []struct {
-			Phone string `json:"phone"`
-			Type  string `json:"type,omitempty"`
-		} `json:"phones,omitempty"`
-	} `json:"contacts,omitempty"`
+	Contacts []whatsapp.ContactCard `json:"contacts,omitempty"`
+	Order    *whatsapp.OrderMessage `json:"order,omitempty"`
+	Referral *MessageReferral       `json:"referral,omitempty"`
+}
+
+// MessageReferral is attached to the first message of a conversation started from an ad or post
+type MessageReferral struct {
+	SourceURL  string `json:"source_url"`
+	SourceID   string `json:"source_id"`
+	SourceType string `json:"source_type"` // ad, post
+	Headline   string `json:"headline,omitempty"`
+	Body       string `json:"body,omitempty"`
+	CtwaClid   string `json:"ctwa_clid,omitempty"`
 }
 
 // processIncomingMessageFull processes incoming WhatsApp messages with chatbot logic
@@ -221,11 +224,14 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 			a.Log.Error("Failed to download audio", "error", err, "media_id", msg.Audio.ID)
 		} else {
 			mediaInfo.MediaURL = localPath
+			// Transcribe voice notes so automations and AI replies can act on them
+			mediaInfo.Transcript = a.transcribeAudio(context.Background(), localPath, msg.Audio.MimeType)
 		}
 	} else if msg.Type == "sticker" && msg.Sticker != nil {
-		// Handle sticker message (treat like image)
+		// Handle sticker message (treat like image, remembering whether it is animated)
 		mediaInfo = &MediaInfo{
 			MediaMimeType: msg.Sticker.MimeType,
+			Metadata:      models.JSONB{"animated": msg.Sticker.Animated},
 		}
 		// Download and save media locally
 		waAccount := &whatsapp.Account{
@@ -255,22 +261,13 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 			messageText = string(jsonBytes)
 		}
 	} else if msg.Type == "contacts" && len(msg.Contacts) > 0 {
-		// Handle contacts message - store as JSON in content
-		contactsData := make([]map[string]any, 0, len(msg.Contacts))
-		for _, c := range msg.Contacts {
-			contact := map[string]any{
-				"name": c.Name.FormattedName,
-			}
-			if len(c.Phones) > 0 {
-				phones := make([]string, 0, len(c.Phones))
-				for _, p := range c.Phones {
-					phones = append(phones, p.Phone)
-				}
-				contact["phones"] = phones
-			}
-			contactsData = append(contactsData, contact)
+		// Handle contacts message - store parsed vCards as JSON in content
+		if jsonBytes, err := json.Marshal(parseSharedContacts(msg.Contacts)); err == nil {
+			messageText = string(jsonBytes)
 		}
-		if jsonBytes, err := json.Marshal(contactsData); err == nil {
+	} else if msg.Type == "order" && msg.Order != nil {
+		// Handle order message - store the cart as JSON in content
+		if jsonBytes, err := json.Marshal(msg.Order); err == nil {
 			messageText = string(jsonBytes)
 		}
 	}
@@ -282,6 +279,20 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 	}
 	a.saveIncomingMessage(account, contact, msg.ID, messageType, messageText, mediaInfo, replyToWAMID)
 
+	if msg.Type == "order" && msg.Order != nil {
+		a.recordWhatsAppOrder(account, contact, msg.ID, msg.Order)
+		// The cart JSON is not something the chatbot can answer
+		messageText = ""
+	}
+
+	// Let the chatbot act on the transcript of a voice note
+	if messageText == "" && mediaInfo != nil && mediaInfo.Transcript != "" {
+		messageText = mediaInfo.Transcript
+	}
+
+	// Auto-label the conversation from the message text and any ad referral
+	a.applyMessageLabelRules(account, contact, messageText, msg.Referral)
+
 	// Clear chatbot tracking since client has replied
 	a.ClearContactChatbotTracking(contact.ID)
 
@@ -302,7 +313,7 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 	if !settings.IsEnabled {
 		a.Log.Debug("Chatbot not enabled for this account, creating transfer for agent queue", "account", account.Name, "settings_id", settings.ID)
 		// Create transfer to agent queue when chatbot is disabled
-		a.createTransferToQueue(account, contact, "chatbot_disabled")
+		a.createTransferToQueue(account, contact, "", "chatbot_disabled")
 		return
 	}
 	a.Log.Info("Chatbot settings loaded", "settings_id", settings.ID, "is_enabled", settings.IsEnabled, "ai_enabled", settings.AIEnabled, "ai_provider", settings.AIProvider, "default_response", settings.DefaultResponse)
@@ -329,7 +340,7 @@ func (a *App) processIncomingMessageFull(phoneNumberID string, msg IncomingTextM
 		return
 	}
 
-	a.Log.Info("Processing message", "text", messageText, "buttonID", buttonID, "from", msg.From)
+	a.Log.Info("Processing message", "text", a.redactMessage(account.OrganizationID, messageText), "buttonID", buttonID, "from", msg.From)
 
 	// Get or create active session for this contact
 	session, isNewSession := a.getOrCreateSession(account.OrganizationID, contact.ID, account.Name, msg.From, settings.SessionTimeoutMins)
@@ -468,43 +479,9 @@ func (a *App) matchKeywordRules(orgID uuid.UUID, accountName, messageText string
 		return nil, false
 	}
 
-	messageLower := strings.ToLower(messageText)
-
 	for _, rule := range rules {
 		for _, keyword := range rule.Keywords {
-			keywordLower := strings.ToLower(keyword)
-			matched := false
-
-			switch rule.MatchType {
-			case "exact":
-				if rule.CaseSensitive {
-					matched = messageText == keyword
-				} else {
-					matched = messageLower == keywordLower
-				}
-			case "contains":
-				if rule.CaseSensitive {
-					matched = strings.Contains(messageText, keyword)
-				} else {
-					matched = strings.Contains(messageLower, keywordLower)
-				}
-			case "starts_with":
-				if rule.CaseSensitive {
-					matched = strings.HasPrefix(messageText, keyword)
-				} else {
-					matched = strings.HasPrefix(messageLower, keywordLower)
-				}
-			case "regex":
-				re, err := regexp.Compile(keyword)
-				if err == nil {
-					matched = re.MatchString(messageText)
-				}
-			default:
-				// Default to contains
-				matched = strings.Contains(messageLower, keywordLower)
-			}
-
-			if matched {
+			if matchKeyword(messageText, keyword, rule.MatchType, rule.CaseSensitive) {
 				response := &KeywordResponse{
 					ResponseType: rule.ResponseType,
 				}
@@ -542,9 +519,37 @@ func (a *App) matchKeywordRules(orgID uuid.UUID, accountName, messageText string
 	return nil, false
 }
 
+// matchKeyword reports whether messageText matches keyword using a keyword rule match type
+func matchKeyword(messageText, keyword, matchType string, caseSensitive bool) bool {
+	switch matchType {
+	case "regex":
+		re, err := regexp.Compile(keyword)
+		return err == nil && re.MatchString(messageText)
+	case "exact", "contains", "starts_with":
+	default:
+		// Default to case-insensitive contains
+		matchType, caseSensitive = "contains", false
+	}
+
+	if !caseSensitive {
+		messageText = strings.ToLower(messageText)
+		keyword = strings.ToLower(keyword)
+	}
+
+	switch matchType {
+	case "exact":
+		return messageText == keyword
+	case "starts_with":
+		return strings.HasPrefix(messageText, keyword)
+	default:
+		return strings.Contains(messageText, keyword)
+	}
+}
+
 // sendTextMessage sends a text message via WhatsApp Cloud API
 // Returns the WhatsApp message ID and any error
 func (a *App) sendTextMessage(account *models.WhatsAppAccount, to, message string) (string, error) {
+	message = a.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, message)
 	waAccount := &whatsapp.Account{
 		PhoneID:     account.PhoneID,
 		BusinessID:  account.BusinessID,
@@ -557,6 +562,10 @@ func (a *App) sendTextMessage(account *models.WhatsAppAccount, to, message strin
 
 // sendAndSaveTextMessage sends a text message and saves it to the database
 func (a *App) sendAndSaveTextMessage(account *models.WhatsAppAccount, contact *models.Contact, message string) error {
+	if !a.allowBotMessage(account.OrganizationID, account.Name, contact, BotMessageSourceChatbot) {
+		return errBotMessageLimit
+	}
+	message = a.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, message)
 	waAccount := &whatsapp.Account{
 		PhoneID:     account.PhoneID,
 		BusinessID:  account.BusinessID,
@@ -621,6 +630,10 @@ func (a *App) sendAndSaveTextMessage(account *models.WhatsAppAccount, contact *m
 
 // sendAndSaveInteractiveButtons sends an interactive button message and saves it to the database
 func (a *App) sendAndSaveInteractiveButtons(account *models.WhatsAppAccount, contact *models.Contact, bodyText string, buttons []map[string]interface{}) error {
+	if !a.allowBotMessage(account.OrganizationID, account.Name, contact, BotMessageSourceChatbot) {
+		return errBotMessageLimit
+	}
+	bodyText = a.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, bodyText)
 	wamid, err := a.sendInteractiveButtons(account, contact.PhoneNumber, bodyText, buttons)
 
 	// Create message record with interactive data
@@ -728,6 +741,7 @@ func (a *App) sendInteractiveButtons(account *models.WhatsAppAccount, to, bodyTe
 	if len(waButtons) == 0 {
 		return a.sendTextMessage(account, to, bodyText)
 	}
+	bodyText = a.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, bodyText)
 
 	waAccount := &whatsapp.Account{
 		PhoneID:     account.PhoneID,
@@ -752,36 +766,50 @@ func (a *App) getOrCreateContact(orgID uuid.UUID, phoneNumber, profileName strin
 		return &contact, false
 	}
 
-	// Create new contact
+	// Create new contact; the upsert resolves a concurrent create to the existing row
+	newID := uuid.New()
 	contact = models.Contact{
-		BaseModel:      models.BaseModel{ID: uuid.New()},
+		BaseModel:      models.BaseModel{ID: newID},
 		OrganizationID: orgID,
 		PhoneNumber:    phoneNumber,
 		ProfileName:    profileName,
 	}
-	if err := a.DB.Create(&contact).Error; err != nil {
+	if err := a.DB.Clauses(models.ContactUpsert(), clause.Returning{}).Create(&contact).Error; err != nil {
 		a.Log.Error("Failed to create contact", "error", err)
 		// Try to fetch again in case of race condition
 		a.DB.Where("organization_id = ? AND phone_number = ?", orgID, phoneNumber).First(&contact)
 		return &contact, false
 	}
-	return &contact, true
+	return &contact, contact.ID == newID
 }
 
-// getOrCreateSession finds an active session or creates a new one
-// Returns the session and a boolean indicating if it's a new session
+// getOrCreateSession finds an active session or creates a new one. Sessions inactive
+// for longer than the timeout are expired first, so a contact writing again days
+// later starts over instead of resuming mid-flow.
 func (a *App) getOrCreateSession(orgID, contactID uuid.UUID, accountName, phoneNumber string, timeoutMins int) (*models.ChatbotSession, bool) {
 	now := time.Now()
 
+	// Expire sessions the session timeout processor hasn't caught up with yet
+	timeout := now.Add(-time.Duration(timeoutMins) * time.Minute)
+	a.DB.Model(&models.ChatbotSession{}).
+		Where("organization_id = ? AND contact_id = ? AND whats_app_account = ? AND status = ? AND last_activity_at <= ?",
+			orgID, contactID, accountName, "active", timeout).
+		Updates(map[string]interface{}{
+			"status":       "timeout",
+			"completed_at": now,
+		})
+
 	// Look for an active session that hasn't timed out
 	var session models.ChatbotSession
-	timeout := now.Add(-time.Duration(timeoutMins) * time.Minute)
-	result := a.DB.Where("organization_id = ? AND contact_id = ? AND whats_app_account = ? AND status = ? AND last_activity_at > ?",
-		orgID, contactID, accountName, "active", timeout).First(&session)
+	result := a.DB.Where("organization_id = ? AND contact_id = ? AND whats_app_account = ? AND status = ?",
+		orgID, contactID, accountName, "active").Order("last_activity_at DESC").First(&session)
 
 	if result.Error == nil {
-		// Update last activity
-		a.DB.Model(&session).Update("last_activity_at", now)
+		// Update last activity; a reply makes the contact eligible for another nudge
+		a.DB.Model(&session).Updates(map[string]interface{}{
+			"last_activity_at": now,
+			"nudged_at":        nil,
+		})
 		return &session, false // existing session
 	}
 
@@ -849,10 +877,12 @@ func (a *App) startFlow(account *models.WhatsAppAccount, session *models.Chatbot
 
 	// Update session with flow info
 	session.CurrentFlowID = &flow.ID
+	session.FlowVersion = flow.PublishedVersion
 	session.CurrentStep = ""
 	session.StepRetries = 0
-	session.SessionData = models.JSONB{}
+	session.SessionData = flowVariableDefaults(flow)
 	a.DB.Save(session)
+	a.recordFlowEvent(session, flow.ID, "", models.FlowEventStarted, "")
 
 	// Send initial message if configured
 	if flow.InitialMessage != "" {
@@ -876,11 +906,11 @@ func (a *App) startFlow(account *models.WhatsAppAccount, session *models.Chatbot
 
 // processFlowResponse handles user response within a flow
 func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *models.ChatbotSession, contact *models.Contact, userInput string, buttonID string) {
-	// Load the current flow from cache
-	flow, err := a.getChatbotFlowByIDCached(account.OrganizationID, *session.CurrentFlowID)
+	// Load the current flow from cache, at the version the session started on
+	flow, err := a.getSessionFlow(session)
 	if err != nil {
 		a.Log.Error("Failed to load flow", "error", err)
-		a.exitFlow(session)
+		a.exitFlow(session, models.FlowExitError)
 		return
 	}
 
@@ -890,7 +920,7 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 		if strings.Contains(userInputLower, strings.ToLower(cancelKw)) {
 			a.sendAndSaveTextMessage(account, contact, "Flow cancelled.")
 			a.logSessionMessage(session.ID, "outgoing", "Flow cancelled.", "flow_cancel")
-			a.exitFlow(session)
+			a.exitFlow(session, models.FlowExitCancelled)
 			return
 		}
 	}
@@ -908,28 +938,15 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 
 	if currentStep == nil {
 		a.Log.Error("Current step not found", "step_name", session.CurrentStep)
-		a.exitFlow(session)
+		a.exitFlow(session, models.FlowExitError)
 		return
 	}
 
 	// Validate input if required (skip validation for button/list responses)
 	if currentStep.ValidationRegex != "" && buttonID == "" {
 		re, err := regexp.Compile(currentStep.ValidationRegex)
-		if err == nil && !re.MatchString(userInput) {
-			// Invalid input
-			session.StepRetries++
-			if currentStep.RetryOnInvalid && session.StepRetries < currentStep.MaxRetries {
-				a.DB.Model(session).Update("step_retries", session.StepRetries)
-				errorMsg := currentStep.ValidationError
-				if errorMsg == "" {
-					errorMsg = "Invalid input. Please try again."
-				}
-				a.sendAndSaveTextMessage(account, contact, errorMsg)
-				a.logSessionMessage(session.ID, "outgoing", errorMsg, currentStep.StepName+"_retry")
-				return
-			}
-			// Max retries exceeded, continue anyway or exit
-			a.Log.Warn("Max retries exceeded", "step", currentStep.StepName)
+		if err == nil && !re.MatchString(userInput) && a.retryInvalidInput(account, session, contact, currentStep) {
+			return
 		}
 	}
 
@@ -984,17 +1001,21 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 				// Max retries exceeded - exit flow and close conversation
 				a.Log.Warn("Max button retries exceeded, closing conversation", "step", currentStep.StepName)
 				a.sendAndSaveTextMessage(account, contact, "Sorry, we couldn't continue. Please try again later.")
-				a.exitFlow(session)
+				a.exitFlow(session, models.FlowExitMaxRetries)
 				a.closeSession(session)
 				return
 			}
 
 			// Resend the step message with buttons
-			a.sendStepMessage(account, session, contact, currentStep)
+			a.sendStepMessage(account, session, contact, currentStep, flow)
 			return
 		}
 	}
 
+	if buttonID != "" && len(currentStep.Buttons) > 0 {
+		a.recordFlowEvent(session, flow.ID, currentStep.StepName, models.FlowEventChosen, buttonID)
+	}
+
 	// Store the user's response (use buttonID if available, otherwise userInput)
 	if currentStep.StoreAs != "" {
 		sessionData := session.SessionData
@@ -1004,9 +1025,20 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 		// Store both the ID and the title for button responses
 		if buttonID != "" {
 			sessionData[currentStep.StoreAs] = buttonID
+			if value, err := coerceStoredInput(flow, currentStep.StoreAs, buttonID); err == nil {
+				sessionData[currentStep.StoreAs] = value
+			}
 			sessionData[currentStep.StoreAs+"_title"] = userInput
 		} else {
-			sessionData[currentStep.StoreAs] = userInput
+			// Typed input that doesn't convert to the variable's type is invalid
+			value, err := coerceStoredInput(flow, currentStep.StoreAs, userInput)
+			if err != nil {
+				if a.retryInvalidInput(account, session, contact, currentStep) {
+					return
+				}
+				value = userInput
+			}
+			sessionData[currentStep.StoreAs] = value
 		}
 		a.DB.Model(session).Update("session_data", sessionData)
 		session.SessionData = sessionData
@@ -1070,13 +1102,32 @@ func (a *App) processFlowResponse(account *models.WhatsAppAccount, session *mode
 	a.sendStepWithSkipCheck(account, session, contact, nextStep, flow, nil)
 }
 
+// retryInvalidInput asks the contact to answer a step again after invalid input,
+// returning false once the step's retries are used up
+func (a *App) retryInvalidInput(account *models.WhatsAppAccount, session *models.ChatbotSession, contact *models.Contact, step *models.ChatbotFlowStep) bool {
+	session.StepRetries++
+	if step.RetryOnInvalid && session.StepRetries < step.MaxRetries {
+		a.DB.Model(session).Update("step_retries", session.StepRetries)
+		errorMsg := step.ValidationError
+		if errorMsg == "" {
+			errorMsg = "Invalid input. Please try again."
+		}
+		a.sendAndSaveTextMessage(account, contact, errorMsg)
+		a.logSessionMessage(session.ID, "outgoing", errorMsg, step.StepName+"_retry")
+		return true
+	}
+	// Max retries exceeded, continue anyway
+	a.Log.Warn("Max retries exceeded", "step", step.StepName)
+	return false
+}
+
 // completeFlow finishes a flow and sends completion message
 func (a *App) completeFlow(account *models.WhatsAppAccount, session *models.ChatbotSession, contact *models.Contact, flow *models.ChatbotFlow) {
 	a.Log.Info("Completing flow", "flow_id", flow.ID, "session_id", session.ID)
 
 	// Send completion message
 	if flow.CompletionMessage != "" {
-		message := a.replaceVariables(flow.CompletionMessage, session.SessionData)
+		message := a.renderFlowText(flow.CompletionMessage, flow, session)
 		a.sendAndSaveTextMessage(account, contact, message)
 		a.logSessionMessage(session.ID, "outgoing", message, "flow_complete")
 	}
@@ -1086,6 +1137,8 @@ func (a *App) completeFlow(account *models.WhatsAppAccount, session *models.Chat
 		go a.sendFlowCompletionWebhook(flow, session, contact)
 	}
 
+	a.recordFlowEvent(session, flow.ID, session.CurrentStep, models.FlowEventCompleted, "")
+
 	// Update session
 	now := time.Now()
 	a.DB.Model(session).Updates(map[string]interface{}{
@@ -1095,6 +1148,8 @@ func (a *App) completeFlow(account *models.WhatsAppAccount, session *models.Chat
 		"completed_at":    now,
 	})
 
+	a.applyFlowOutcomeLabelRules(session, flow.ID, "completed")
+
 	// Clear chatbot tracking so SLA doesn't fire after flow completion
 	a.ClearContactChatbotTracking(contact.ID)
 }
@@ -1194,13 +1249,22 @@ func (a *App) sendFlowCompletionWebhook(flow *models.ChatbotFlow, session *model
 	}
 }
 
-// exitFlow clears flow state from session without completion
-func (a *App) exitFlow(session *models.ChatbotSession) {
+// exitFlow clears flow state from session without completion, counting the exit at the
+// current step with the given reason
+func (a *App) exitFlow(session *models.ChatbotSession, reason string) {
+	flowID := session.CurrentFlowID
+	if flowID != nil {
+		a.recordFlowEvent(session, *flowID, session.CurrentStep, models.FlowEventExited, reason)
+	}
 	a.DB.Model(session).Updates(map[string]interface{}{
 		"current_flow_id": nil,
 		"current_step":    "",
 		"step_retries":    0,
 	})
+
+	if flowID != nil {
+		a.applyFlowOutcomeLabelRules(session, *flowID, "exited")
+	}
 }
 
 // closeSession ends the chatbot session and clears contact tracking
@@ -1294,11 +1358,53 @@ func (a *App) sendStepWithSkipCheck(account *models.WhatsAppAccount, session *mo
 		return
 	}
 
+	a.recordFlowEvent(session, flow.ID, step.StepName, models.FlowEventEntered, "")
+
+	// Assignments run as the step is entered, so its message can use them
+	a.applyStepAssignments(flow, session, step)
+
+	// Condition and HTTP request steps send nothing and go straight to the next step
+	if step.MessageType == "condition" || step.MessageType == "http_request" {
+		skippedSteps[step.StepName] = true
+
+		var nextStepName string
+		if step.MessageType == "condition" {
+			nextStepName = a.conditionNextStep(flow, session, step)
+		} else {
+			nextStepName = a.runHTTPRequestStep(flow, session, step)
+		}
+		var nextStep *models.ChatbotFlowStep
+		for i := range flow.Steps {
+			if flow.Steps[i].StepName == nextStepName {
+				nextStep = &flow.Steps[i]
+				break
+			}
+		}
+
+		if nextStep == nil {
+			if nextStepName != "" {
+				a.Log.Warn("Next step not found after branching, completing flow", "step", step.StepName, "next_step", nextStepName)
+			}
+			a.completeFlow(account, session, contact, flow)
+			return
+		}
+
+		a.Log.Info("Step branched", "step", step.StepName, "type", step.MessageType, "next_step", nextStep.StepName)
+		session.CurrentStep = nextStep.StepName
+		a.DB.Model(session).Update("current_step", nextStep.StepName)
+		a.sendStepWithSkipCheck(account, session, contact, nextStep, flow, skippedSteps)
+		return
+	}
+
 	// Not skipping - send the step message normally
-	a.sendStepMessage(account, session, contact, step)
+	a.sendStepMessage(account, session, contact, step, flow)
 
 	// If input type is "none", automatically advance to next step without waiting for user input
 	if step.InputType == "none" {
+		// Steps that advance on their own can loop; the message limit ends the flow
+		if a.botMessageLimited(contact.ID) {
+			return
+		}
 
 		// Find next step
 		nextStepName := step.NextStep
@@ -1343,7 +1449,7 @@ func (a *App) sendStepWithSkipCheck(account *models.WhatsAppAccount, session *mo
 }
 
 // sendStepMessage sends the appropriate message based on step message_type
-func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.ChatbotSession, contact *models.Contact, step *models.ChatbotFlowStep) {
+func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.ChatbotSession, contact *models.Contact, step *models.ChatbotFlowStep, flow *models.ChatbotFlow) {
 	var message string
 
 	switch step.MessageType {
@@ -1384,7 +1490,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 
 	case "buttons":
 		// Send interactive buttons message
-		message = processTemplate(step.Message, session.SessionData)
+		message = a.renderFlowText(step.Message, flow, session)
 		if len(step.Buttons) > 0 {
 			// Convert JSONBArray to []map[string]interface{}
 			buttons := make([]map[string]interface{}, 0, len(step.Buttons))
@@ -1402,7 +1508,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 
 	case "transfer":
 		// Transfer to team/agent queue
-		message = processTemplate(step.Message, session.SessionData)
+		message = a.renderFlowText(step.Message, flow, session)
 		if message != "" {
 			a.sendAndSaveTextMessage(account, contact, message)
 			a.logSessionMessage(session.ID, "outgoing", message, step.StepName)
@@ -1418,7 +1524,7 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 				}
 			}
 			if n, ok := step.TransferConfig["notes"].(string); ok {
-				notes = processTemplate(n, session.SessionData)
+				notes = a.renderFlowText(n, flow, session)
 			}
 		}
 
@@ -1427,16 +1533,16 @@ func (a *App) sendStepMessage(account *models.WhatsAppAccount, session *models.C
 			a.createTransferToTeam(account, contact, *teamID, notes, "flow")
 		} else {
 			// General queue transfer
-			a.createTransferToQueue(account, contact, "flow")
+			a.createTransferToQueue(account, contact, notes, "flow")
 		}
 
 		// End the flow session (transfer takes over)
-		a.exitFlow(session)
+		a.exitFlow(session, models.FlowExitTransferred)
 		return
 
 	default:
 		// Default: use the step message with template processing
-		message = processTemplate(step.Message, session.SessionData)
+		message = a.renderFlowText(step.Message, flow, session)
 		a.sendAndSaveTextMessage(account, contact, message)
 		a.logSessionMessage(session.ID, "outgoing", message, step.StepName)
 	}
@@ -2211,6 +2317,8 @@ type MediaInfo struct {
 	MediaURL      string
 	MediaMimeType string
 	MediaFilename string
+	Transcript    string
+	Metadata      models.JSONB // Extra attributes stored on the message (e.g. sticker animation)
 }
 
 // saveIncomingMessage saves an incoming message to the messages table
@@ -2245,6 +2353,10 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 		message.MediaURL = mediaInfo.MediaURL
 		message.MediaMimeType = mediaInfo.MediaMimeType
 		message.MediaFilename = mediaInfo.MediaFilename
+		message.Transcript = mediaInfo.Transcript
+		if mediaInfo.Metadata != nil {
+			message.Metadata = mediaInfo.Metadata
+		}
 	}
 
 	if err := a.DB.Create(&message).Error; err != nil {
@@ -2270,6 +2382,9 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 
 	a.Log.Info("Saved incoming message", "message_id", message.ID, "contact_id", contact.ID, "media_url", message.MediaURL)
 
+	// Agents to alert now under their notification preferences; digest agents get it later
+	alertUserIDs := a.newMessageAlerts(account.OrganizationID, contact, &message, preview)
+
 	// Broadcast new message via WebSocket
 	if a.WSHub != nil {
 		var assignedUserIDStr string
@@ -2280,6 +2395,7 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 			"id":               message.ID.String(),
 			"contact_id":       contact.ID.String(),
 			"assigned_user_id": assignedUserIDStr,
+			"notify_user_ids":  alertUserIDs,
 			"profile_name":     contact.ProfileName,
 			"direction":        message.Direction,
 			"message_type":     message.MessageType,
@@ -2287,6 +2403,7 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 			"media_url":        message.MediaURL,
 			"media_mime_type":  message.MediaMimeType,
 			"media_filename":   message.MediaFilename,
+			"transcript":       message.Transcript,
 			"status":           message.Status,
 			"wamid":            message.WhatsAppMessageID,
 			"created_at":       message.CreatedAt,
@@ -2313,6 +2430,9 @@ func (a *App) saveIncomingMessage(account *models.WhatsAppAccount, contact *mode
 		})
 	}
 
+	// Notify the alerted agents' mobile devices
+	a.pushNewMessage(account.OrganizationID, alertUserIDs, contact, &message, preview)
+
 	// Dispatch webhook for incoming message
 	a.DispatchWebhook(account.OrganizationID, EventMessageIncoming, MessageEventData{
 		MessageID:       message.ID.String(),
@@ -0,0 +1,1449 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/holidays"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/redact"
+	"github.com/shridarpatil/whatomate/internal/reports"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// configBundleVersion is the format version of exported configuration bundles
+const configBundleVersion = 1
+
+// configRedacted replaces secret values in an exported bundle. Importing it keeps the
+// value already stored in the target organization.
+const configRedacted = "[redacted]"
+
+// Config import actions
+const (
+	configActionCreate    = "create"
+	configActionUpdate    = "update"
+	configActionUnchanged = "unchanged"
+	configActionInvalid   = "invalid"
+)
+
+// sensitiveHeaderWords mark webhook headers whose values are redacted on export
+var sensitiveHeaderWords = []string{"auth", "token", "key", "secret", "password", "signature", "cookie"}
+
+// ConfigBundle is an organization's configuration exported for promotion to another
+// environment, e.g. from staging to production. Records are matched by name rather
+// than ID and refer to templates, labels and flows by name. Secrets are left out:
+// webhook signing secrets, AI keys and the egress proxy are never exported, and
+// sensitive webhook headers are redacted. Sections left empty aren't imported.
+type ConfigBundle struct {
+	Version          int                       `json:"version"`
+	ExportedAt       time.Time                 `json:"exported_at"`
+	Organization     string                    `json:"organization"` // Name of the exporting organization
+	Settings         *ConfigSettings           `json:"settings,omitempty"`
+	Labels           []ConfigLabel             `json:"labels"`
+	WorkingHours     []ConfigWorkingHours      `json:"working_hours"`
+	Routing          []ConfigRouting           `json:"routing"`
+	HolidayCalendars []ConfigHolidayCalendar   `json:"holiday_calendars"`
+	BlackoutDates    []ConfigBlackoutDate      `json:"blackout_dates"` // Added manually, not from a calendar
+	CannedResponses  []ConfigCannedResponse    `json:"canned_responses"`
+	KeywordRules     []ConfigKeywordRule       `json:"keyword_rules"`
+	LabelRules       []ConfigLabelRule         `json:"label_rules"`
+	WinBack          []ConfigWinBackAutomation `json:"win_back_automations"`
+	DateTriggers     []ConfigDateTrigger       `json:"date_triggers"`
+	Teams            []ConfigTeam              `json:"teams"`
+	Webhooks         []ConfigWebhook           `json:"webhooks"`
+}
+
+// ConfigSettings are the promoted organization settings. The egress proxy holds
+// credentials and is specific to an environment, so it isn't included, and neither
+// are conversation share partners since they name organizations by ID.
+type ConfigSettings struct {
+	MaskPhoneNumbers             bool         `json:"mask_phone_numbers"`
+	Timezone                     string       `json:"timezone"`
+	DateFormat                   string       `json:"date_format"`
+	MessageAudit                 string       `json:"message_audit"`
+	BroadcastDailyLimit          int          `json:"broadcast_daily_limit"`
+	DuplicateCampaignCheck       string       `json:"duplicate_campaign_check"`
+	DuplicateCampaignWindowHours int          `json:"duplicate_campaign_window_hours"`
+	DuplicateCampaignOverlap     int          `json:"duplicate_campaign_overlap"`
+	MessageFooter                string       `json:"message_footer"`
+	MessageFooterCategories      []string     `json:"message_footer_categories"`
+	RedactionRules               redact.Rules `json:"redaction_rules"`
+
+	// Missing from bundles exported before they were promoted, which leave them as they are
+	WeekStart       string `json:"week_start,omitempty"`
+	FiscalYearStart int    `json:"fiscal_year_start,omitempty"`
+}
+
+// ConfigLabel is a conversation label
+type ConfigLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// ConfigWorkingHours are an account's business hours. An empty account is the
+// organization default.
+type ConfigWorkingHours struct {
+	WhatsAppAccount            string            `json:"whatsapp_account"`
+	BusinessHoursEnabled       bool              `json:"business_hours_enabled"`
+	BusinessHours              models.JSONBArray `json:"business_hours"`
+	OutOfHoursMessage          string            `json:"out_of_hours_message"`
+	AllowAutomatedOutsideHours bool              `json:"allow_automated_outside_hours"`
+}
+
+// ConfigRouting is how an account's transfers are assigned to agents and held to SLAs.
+// Escalation contacts are users of one environment, so they aren't included.
+type ConfigRouting struct {
+	WhatsAppAccount              string `json:"whatsapp_account"`
+	AllowAgentQueuePickup        bool   `json:"allow_agent_queue_pickup"`
+	AssignToSameAgent            bool   `json:"assign_to_same_agent"`
+	AgentCurrentConversationOnly bool   `json:"agent_current_conversation_only"`
+	SLAEnabled                   bool   `json:"sla_enabled"`
+	SLAResponseMinutes           int    `json:"sla_response_minutes"`
+	SLAResolutionMinutes         int    `json:"sla_resolution_minutes"`
+	SLAEscalationMinutes         int    `json:"sla_escalation_minutes"`
+	SLAAutoCloseHours            int    `json:"sla_auto_close_hours"`
+	SLAAutoCloseMessage          string `json:"sla_auto_close_message"`
+	SLAWarningMessage            string `json:"sla_warning_message"`
+}
+
+// ConfigHolidayCalendar is a holiday feed. Its dates are synced again after import.
+type ConfigHolidayCalendar struct {
+	Name     string `json:"name"`
+	Region   string `json:"region"`
+	URL      string `json:"url"`
+	IsActive bool   `json:"is_active"`
+}
+
+// ConfigBlackoutDate is a manually added campaign blackout date
+type ConfigBlackoutDate struct {
+	Date   string `json:"date"` // YYYY-MM-DD
+	Name   string `json:"name"`
+	Region string `json:"region"`
+}
+
+// ConfigCannedResponse is a canned response
+type ConfigCannedResponse struct {
+	Name     string `json:"name"`
+	Shortcut string `json:"shortcut"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+	IsActive bool   `json:"is_active"`
+}
+
+// ConfigKeywordRule is a chatbot keyword rule
+type ConfigKeywordRule struct {
+	WhatsAppAccount string       `json:"whatsapp_account"`
+	Name            string       `json:"name"`
+	IsEnabled       bool         `json:"is_enabled"`
+	Priority        int          `json:"priority"`
+	Keywords        []string     `json:"keywords"`
+	MatchType       string       `json:"match_type"`
+	CaseSensitive   bool         `json:"case_sensitive"`
+	ResponseType    string       `json:"response_type"`
+	ResponseContent models.JSONB `json:"response_content"`
+	Conditions      string       `json:"conditions"`
+	ActiveFrom      *time.Time   `json:"active_from,omitempty"`
+	ActiveUntil     *time.Time   `json:"active_until,omitempty"`
+}
+
+// ConfigLabelRule is a label rule with its label and flow referenced by name
+type ConfigLabelRule struct {
+	WhatsAppAccount string   `json:"whatsapp_account"`
+	Name            string   `json:"name"`
+	Label           string   `json:"label"`
+	IsEnabled       bool     `json:"is_enabled"`
+	TriggerType     string   `json:"trigger_type"`
+	Keywords        []string `json:"keywords"`
+	MatchType       string   `json:"match_type"`
+	CaseSensitive   bool     `json:"case_sensitive"`
+	AdSourceIDs     []string `json:"ad_source_ids"`
+	Flow            string   `json:"flow,omitempty"` // Empty matches any flow
+	FlowOutcome     string   `json:"flow_outcome"`
+}
+
+// ConfigWinBackAutomation is a win-back automation with its templates and exit label
+// referenced by name
+type ConfigWinBackAutomation struct {
+	WhatsAppAccount string              `json:"whatsapp_account"`
+	Name            string              `json:"name"`
+	IsEnabled       bool                `json:"is_enabled"`
+	InactiveDays    int                 `json:"inactive_days"`
+	MinPastReplies  int                 `json:"min_past_replies"`
+	Steps           []ConfigWinBackStep `json:"steps"`
+	DailyCap        int                 `json:"daily_cap"`
+	CooldownDays    int                 `json:"cooldown_days"`
+	ExitLabel       string              `json:"exit_label,omitempty"`
+}
+
+// ConfigWinBackStep is a win-back step with its template referenced by name
+type ConfigWinBackStep struct {
+	Template       string            `json:"template"`
+	DelayDays      int               `json:"delay_days"`
+	TemplateParams map[string]string `json:"template_params,omitempty"`
+}
+
+// ConfigDateTrigger is a date trigger with its template referenced by name
+type ConfigDateTrigger struct {
+	WhatsAppAccount string       `json:"whatsapp_account"`
+	Name            string       `json:"name"`
+	IsEnabled       bool         `json:"is_enabled"`
+	DateField       string       `json:"date_field"`
+	Annual          bool         `json:"annual"`
+	OffsetDays      int          `json:"offset_days"`
+	SendHour        int          `json:"send_hour"`
+	Template        string       `json:"template"`
+	TemplateParams  models.JSONB `json:"template_params"`
+}
+
+// ConfigTeam is a team and how it assigns transfers. Members are users of one
+// environment, so they aren't included.
+type ConfigTeam struct {
+	Name               string `json:"name"`
+	Description        string `json:"description"`
+	AssignmentStrategy string `json:"assignment_strategy"`
+	IsActive           bool   `json:"is_active"`
+}
+
+// ConfigWebhook is a webhook without its signing secret
+type ConfigWebhook struct {
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Events   []string          `json:"events"`
+	Headers  map[string]string `json:"headers"` // Sensitive values are redacted
+	IsActive bool              `json:"is_active"`
+}
+
+// configRecord is a bundle record, matched to the organization's records by its key
+type configRecord interface {
+	configKey() string
+}
+
+func (ConfigSettings) configKey() string            { return "organization" }
+func (c ConfigLabel) configKey() string             { return c.Name }
+func (c ConfigWorkingHours) configKey() string      { return configAccountKey(c.WhatsAppAccount) }
+func (c ConfigRouting) configKey() string           { return configAccountKey(c.WhatsAppAccount) }
+func (c ConfigHolidayCalendar) configKey() string   { return c.Name }
+func (c ConfigBlackoutDate) configKey() string      { return c.Date }
+func (c ConfigCannedResponse) configKey() string    { return c.Name }
+func (c ConfigKeywordRule) configKey() string       { return templateKey(c.WhatsAppAccount, c.Name) }
+func (c ConfigLabelRule) configKey() string         { return templateKey(c.WhatsAppAccount, c.Name) }
+func (c ConfigWinBackAutomation) configKey() string { return templateKey(c.WhatsAppAccount, c.Name) }
+func (c ConfigDateTrigger) configKey() string       { return templateKey(c.WhatsAppAccount, c.Name) }
+func (c ConfigTeam) configKey() string              { return c.Name }
+func (c ConfigWebhook) configKey() string           { return c.Name }
+
+// configAccountKey names per-account settings, "default" being the organization's
+func configAccountKey(account string) string {
+	if account == "" {
+		return "default"
+	}
+	return account
+}
+
+// ConfigFieldChange is a field an import changes
+type ConfigFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// ConfigImportItem is what importing one bundle record does
+type ConfigImportItem struct {
+	Section  string              `json:"section"`
+	Key      string              `json:"key"`
+	Action   string              `json:"action"` // create, update, unchanged, invalid
+	Changes  []ConfigFieldChange `json:"changes,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Warnings []string            `json:"warnings,omitempty"`
+}
+
+// ConfigImportResult is the diff of a bundle against the organization and whether it
+// was applied
+type ConfigImportResult struct {
+	DryRun  bool               `json:"dry_run"`
+	Applied bool               `json:"applied"`
+	Summary map[string]int     `json:"summary"` // Records per action
+	Items   []ConfigImportItem `json:"items"`   // Every record that isn't unchanged
+}
+
+// configRefs maps the accounts, templates, labels and flows that config records
+// reference between IDs and names
+type configRefs struct {
+	accounts    map[string]bool
+	templates   map[uuid.UUID]string // ID -> name
+	templateIDs map[string]uuid.UUID // templateKey -> ID
+	labels      map[uuid.UUID]string
+	labelIDs    map[string]uuid.UUID
+	flows       map[uuid.UUID]string
+	flowIDs     map[string]uuid.UUID
+}
+
+// ExportConfig exports the organization's configuration for import into another
+// environment
+func (a *App) ExportConfig(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	refs, err := loadConfigRefs(a.DB, orgID)
+	if err != nil {
+		a.Log.Error("Failed to load config references", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export configuration", nil, "")
+	}
+	bundle, err := exportConfig(a.DB, orgID, refs)
+	if err != nil {
+		a.Log.Error("Failed to export configuration", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export configuration", nil, "")
+	}
+
+	return r.SendEnvelope(bundle)
+}
+
+// ImportConfig diffs a config bundle against the organization and, unless
+// dry_run=true, applies it. Records are created or updated by name; ones missing from
+// the bundle are left alone. Nothing is applied while any record is invalid.
+func (a *App) ImportConfig(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var bundle ConfigBundle
+	if err := json.Unmarshal(r.RequestCtx.PostBody(), &bundle); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if bundle.Version != configBundleVersion {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Unsupported config bundle version %d", bundle.Version), nil, "")
+	}
+	dryRun := string(r.RequestCtx.QueryArgs().Peek("dry_run")) == "true"
+
+	refs, err := loadConfigRefs(a.DB, orgID)
+	if err != nil {
+		a.Log.Error("Failed to load config references", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to compare configuration", nil, "")
+	}
+	current, err := exportConfig(a.DB, orgID, refs)
+	if err != nil {
+		a.Log.Error("Failed to export current configuration", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to compare configuration", nil, "")
+	}
+
+	result := planConfigImport(&bundle, current, refs)
+	result.DryRun = dryRun
+	if dryRun {
+		return r.SendEnvelope(result)
+	}
+	if result.Summary[configActionInvalid] > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, "Fix the invalid records before importing", result, "")
+	}
+
+	if err := a.DB.Transaction(func(tx *gorm.DB) error {
+		return applyConfigImport(tx, orgID, userID, &bundle, result.Items)
+	}); err != nil {
+		a.Log.Error("Failed to import configuration", "error", err, "organization_id", orgID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to import configuration", nil, "")
+	}
+	result.Applied = true
+
+	a.InvalidateChatbotSettingsCache(orgID)
+	a.InvalidateSLASettingsCache()
+	a.InvalidateKeywordRulesCache(orgID)
+	a.InvalidateLabelRulesCache(orgID)
+	a.InvalidateWebhooksCache(orgID)
+	a.InvalidateMessageFooterCache(orgID)
+	a.InvalidateRedactionRulesCache(orgID)
+	if bundle.Settings != nil {
+		a.rescheduleReports(orgID, a.orgCalendar(orgID))
+	}
+
+	a.Log.Info("Imported configuration",
+		"organization_id", orgID,
+		"source", bundle.Organization,
+		"created", result.Summary[configActionCreate],
+		"updated", result.Summary[configActionUpdate],
+	)
+	return r.SendEnvelope(result)
+}
+
+// loadConfigRefs loads the names of everything config records may reference
+func loadConfigRefs(db *gorm.DB, orgID uuid.UUID) (*configRefs, error) {
+	refs := &configRefs{
+		accounts:    map[string]bool{},
+		templates:   map[uuid.UUID]string{},
+		templateIDs: map[string]uuid.UUID{},
+		labels:      map[uuid.UUID]string{},
+		labelIDs:    map[string]uuid.UUID{},
+		flows:       map[uuid.UUID]string{},
+		flowIDs:     map[string]uuid.UUID{},
+	}
+
+	var accounts []models.WhatsAppAccount
+	if err := db.Select("name").Where("organization_id = ?", orgID).Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load accounts: %w", err)
+	}
+	for _, acc := range accounts {
+		refs.accounts[acc.Name] = true
+	}
+
+	var templates []models.Template
+	if err := db.Select("id", "whats_app_account", "name").Where("organization_id = ?", orgID).Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load templates: %w", err)
+	}
+	for _, t := range templates {
+		refs.templates[t.ID] = t.Name
+		refs.templateIDs[templateKey(t.WhatsAppAccount, t.Name)] = t.ID
+	}
+
+	var labels []models.Label
+	if err := db.Select("id", "name").Where("organization_id = ?", orgID).Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load labels: %w", err)
+	}
+	for _, l := range labels {
+		refs.labels[l.ID] = l.Name
+		refs.labelIDs[l.Name] = l.ID
+	}
+
+	var flows []models.ChatbotFlow
+	if err := db.Select("id", "name").Where("organization_id = ?", orgID).Find(&flows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load chatbot flows: %w", err)
+	}
+	for _, f := range flows {
+		refs.flows[f.ID] = f.Name
+		refs.flowIDs[f.Name] = f.ID
+	}
+
+	return refs, nil
+}
+
+// exportConfig builds the organization's config bundle
+func exportConfig(db *gorm.DB, orgID uuid.UUID, refs *configRefs) (*ConfigBundle, error) {
+	var org models.Organization
+	if err := db.Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("failed to load organization: %w", err)
+	}
+	settings := organizationSettings(&org)
+
+	bundle := &ConfigBundle{
+		Version:      configBundleVersion,
+		ExportedAt:   time.Now().UTC(),
+		Organization: org.Name,
+		Settings: &ConfigSettings{
+			MaskPhoneNumbers:             settings.MaskPhoneNumbers,
+			Timezone:                     settings.Timezone,
+			DateFormat:                   settings.DateFormat,
+			MessageAudit:                 settings.MessageAudit,
+			BroadcastDailyLimit:          settings.BroadcastDailyLimit,
+			DuplicateCampaignCheck:       settings.DuplicateCampaignCheck,
+			DuplicateCampaignWindowHours: settings.DuplicateCampaignWindowHours,
+			DuplicateCampaignOverlap:     settings.DuplicateCampaignOverlap,
+			MessageFooter:                settings.MessageFooter,
+			MessageFooterCategories:      settings.MessageFooterCategories,
+			RedactionRules:               settings.RedactionRules,
+			WeekStart:                    settings.WeekStart,
+			FiscalYearStart:              settings.FiscalYearStart,
+		},
+		Labels:           []ConfigLabel{},
+		WorkingHours:     []ConfigWorkingHours{},
+		Routing:          []ConfigRouting{},
+		HolidayCalendars: []ConfigHolidayCalendar{},
+		BlackoutDates:    []ConfigBlackoutDate{},
+		CannedResponses:  []ConfigCannedResponse{},
+		KeywordRules:     []ConfigKeywordRule{},
+		LabelRules:       []ConfigLabelRule{},
+		WinBack:          []ConfigWinBackAutomation{},
+		DateTriggers:     []ConfigDateTrigger{},
+		Teams:            []ConfigTeam{},
+		Webhooks:         []ConfigWebhook{},
+	}
+
+	var labels []models.Label
+	if err := db.Where("organization_id = ?", orgID).Order("name").Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load labels: %w", err)
+	}
+	for _, l := range labels {
+		bundle.Labels = append(bundle.Labels, ConfigLabel{Name: l.Name, Color: l.Color, Description: l.Description})
+	}
+
+	var chatbotSettings []models.ChatbotSettings
+	if err := db.Where("organization_id = ?", orgID).Order("whats_app_account").Find(&chatbotSettings).Error; err != nil {
+		return nil, fmt.Errorf("failed to load chatbot settings: %w", err)
+	}
+	for _, s := range chatbotSettings {
+		bundle.WorkingHours = append(bundle.WorkingHours, ConfigWorkingHours{
+			WhatsAppAccount:            s.WhatsAppAccount,
+			BusinessHoursEnabled:       s.BusinessHoursEnabled,
+			BusinessHours:              s.BusinessHours,
+			OutOfHoursMessage:          s.OutOfHoursMessage,
+			AllowAutomatedOutsideHours: s.AllowAutomatedOutsideHours,
+		})
+		bundle.Routing = append(bundle.Routing, ConfigRouting{
+			WhatsAppAccount:              s.WhatsAppAccount,
+			AllowAgentQueuePickup:        s.AllowAgentQueuePickup,
+			AssignToSameAgent:            s.AssignToSameAgent,
+			AgentCurrentConversationOnly: s.AgentCurrentConversationOnly,
+			SLAEnabled:                   s.SLAEnabled,
+			SLAResponseMinutes:           s.SLAResponseMinutes,
+			SLAResolutionMinutes:         s.SLAResolutionMinutes,
+			SLAEscalationMinutes:         s.SLAEscalationMinutes,
+			SLAAutoCloseHours:            s.SLAAutoCloseHours,
+			SLAAutoCloseMessage:          s.SLAAutoCloseMessage,
+			SLAWarningMessage:            s.SLAWarningMessage,
+		})
+	}
+
+	var calendars []models.HolidayCalendar
+	if err := db.Where("organization_id = ?", orgID).Order("name").Find(&calendars).Error; err != nil {
+		return nil, fmt.Errorf("failed to load holiday calendars: %w", err)
+	}
+	for _, c := range calendars {
+		bundle.HolidayCalendars = append(bundle.HolidayCalendars, ConfigHolidayCalendar{
+			Name: c.Name, Region: c.Region, URL: c.URL, IsActive: c.IsActive,
+		})
+	}
+
+	var dates []models.CampaignBlackoutDate
+	if err := db.Where("organization_id = ? AND calendar_id IS NULL", orgID).Order("date").Find(&dates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load blackout dates: %w", err)
+	}
+	for _, d := range dates {
+		bundle.BlackoutDates = append(bundle.BlackoutDates, ConfigBlackoutDate{
+			Date: d.Date.Format("2006-01-02"), Name: d.Name, Region: d.Region,
+		})
+	}
+
+	var responses []models.CannedResponse
+	if err := db.Where("organization_id = ?", orgID).Order("name").Find(&responses).Error; err != nil {
+		return nil, fmt.Errorf("failed to load canned responses: %w", err)
+	}
+	for _, c := range responses {
+		bundle.CannedResponses = append(bundle.CannedResponses, ConfigCannedResponse{
+			Name: c.Name, Shortcut: c.Shortcut, Content: c.Content, Category: c.Category, IsActive: c.IsActive,
+		})
+	}
+
+	var keywordRules []models.KeywordRule
+	if err := db.Where("organization_id = ?", orgID).Order("whats_app_account, name").Find(&keywordRules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load keyword rules: %w", err)
+	}
+	for _, k := range keywordRules {
+		bundle.KeywordRules = append(bundle.KeywordRules, ConfigKeywordRule{
+			WhatsAppAccount: k.WhatsAppAccount,
+			Name:            k.Name,
+			IsEnabled:       k.IsEnabled,
+			Priority:        k.Priority,
+			Keywords:        k.Keywords,
+			MatchType:       k.MatchType,
+			CaseSensitive:   k.CaseSensitive,
+			ResponseType:    k.ResponseType,
+			ResponseContent: k.ResponseContent,
+			Conditions:      k.Conditions,
+			ActiveFrom:      configTime(k.ActiveFrom),
+			ActiveUntil:     configTime(k.ActiveUntil),
+		})
+	}
+
+	var labelRules []models.LabelRule
+	if err := db.Where("organization_id = ?", orgID).Order("whats_app_account, name").Find(&labelRules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load label rules: %w", err)
+	}
+	for _, l := range labelRules {
+		rule := ConfigLabelRule{
+			WhatsAppAccount: l.WhatsAppAccount,
+			Name:            l.Name,
+			Label:           refs.labels[l.LabelID],
+			IsEnabled:       l.IsEnabled,
+			TriggerType:     l.TriggerType,
+			Keywords:        l.Keywords,
+			MatchType:       l.MatchType,
+			CaseSensitive:   l.CaseSensitive,
+			AdSourceIDs:     l.AdSourceIDs,
+			FlowOutcome:     l.FlowOutcome,
+		}
+		if l.FlowID != nil {
+			rule.Flow = refs.flows[*l.FlowID]
+		}
+		bundle.LabelRules = append(bundle.LabelRules, rule)
+	}
+
+	var automations []models.WinBackAutomation
+	if err := db.Where("organization_id = ?", orgID).Order("whats_app_account, name").Find(&automations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load win-back automations: %w", err)
+	}
+	for _, w := range automations {
+		steps, err := w.StepList()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode steps of win-back automation %s: %w", w.Name, err)
+		}
+		automation := ConfigWinBackAutomation{
+			WhatsAppAccount: w.WhatsAppAccount,
+			Name:            w.Name,
+			IsEnabled:       w.IsEnabled,
+			InactiveDays:    w.InactiveDays,
+			MinPastReplies:  w.MinPastReplies,
+			Steps:           make([]ConfigWinBackStep, 0, len(steps)),
+			DailyCap:        w.DailyCap,
+			CooldownDays:    w.CooldownDays,
+		}
+		for _, step := range steps {
+			automation.Steps = append(automation.Steps, ConfigWinBackStep{
+				Template:       refs.templates[step.TemplateID],
+				DelayDays:      step.DelayDays,
+				TemplateParams: step.TemplateParams,
+			})
+		}
+		if w.ExitLabelID != nil {
+			automation.ExitLabel = refs.labels[*w.ExitLabelID]
+		}
+		bundle.WinBack = append(bundle.WinBack, automation)
+	}
+
+	var triggers []models.DateTrigger
+	if err := db.Where("organization_id = ?", orgID).Order("whats_app_account, name").Find(&triggers).Error; err != nil {
+		return nil, fmt.Errorf("failed to load date triggers: %w", err)
+	}
+	for _, t := range triggers {
+		bundle.DateTriggers = append(bundle.DateTriggers, ConfigDateTrigger{
+			WhatsAppAccount: t.WhatsAppAccount,
+			Name:            t.Name,
+			IsEnabled:       t.IsEnabled,
+			DateField:       t.DateField,
+			Annual:          t.Annual,
+			OffsetDays:      t.OffsetDays,
+			SendHour:        t.SendHour,
+			Template:        refs.templates[t.TemplateID],
+			TemplateParams:  t.TemplateParams,
+		})
+	}
+
+	var teams []models.Team
+	if err := db.Where("organization_id = ?", orgID).Order("name").Find(&teams).Error; err != nil {
+		return nil, fmt.Errorf("failed to load teams: %w", err)
+	}
+	for _, t := range teams {
+		bundle.Teams = append(bundle.Teams, ConfigTeam{
+			Name: t.Name, Description: t.Description, AssignmentStrategy: t.AssignmentStrategy, IsActive: t.IsActive,
+		})
+	}
+
+	var webhooks []models.Webhook
+	if err := db.Where("organization_id = ?", orgID).Order("name").Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	for _, w := range webhooks {
+		headers := make(map[string]string, len(w.Headers))
+		for k, v := range w.Headers {
+			headers[k] = fmt.Sprint(v)
+			if sensitiveHeader(k) {
+				headers[k] = configRedacted
+			}
+		}
+		bundle.Webhooks = append(bundle.Webhooks, ConfigWebhook{
+			Name: w.Name, URL: w.URL, Events: w.Events, Headers: headers, IsActive: w.IsActive,
+		})
+	}
+
+	return bundle, nil
+}
+
+// planConfigImport diffs every section of the bundle against the organization's
+// current configuration and checks the records that would change
+func planConfigImport(bundle, current *ConfigBundle, refs *configRefs) *ConfigImportResult {
+	result := &ConfigImportResult{
+		Summary: map[string]int{
+			configActionCreate:    0,
+			configActionUpdate:    0,
+			configActionUnchanged: 0,
+			configActionInvalid:   0,
+		},
+		Items: []ConfigImportItem{},
+	}
+
+	// Labels and the account's templates may be created by the import or already exist
+	bundleLabels := map[string]bool{}
+	for _, l := range bundle.Labels {
+		bundleLabels[l.Name] = true
+	}
+	labelExists := func(name string) bool {
+		_, ok := refs.labelIDs[name]
+		return ok || bundleLabels[name]
+	}
+	checkAccount := func(account string) string {
+		if account != "" && !refs.accounts[account] {
+			return fmt.Sprintf("WhatsApp account %s not found", account)
+		}
+		return ""
+	}
+	checkTemplate := func(account, name string) string {
+		if _, ok := refs.templateIDs[templateKey(account, name)]; !ok {
+			return fmt.Sprintf("Template %s not found on account %s", name, account)
+		}
+		return ""
+	}
+
+	var items []ConfigImportItem
+	if bundle.Settings != nil {
+		items = append(items, planConfigSection("settings", []ConfigSettings{*bundle.Settings}, []ConfigSettings{*current.Settings},
+			func(s ConfigSettings, _ *ConfigSettings) (string, []string) {
+				if _, err := time.LoadLocation(s.Timezone); err != nil {
+					return fmt.Sprintf("Unknown timezone %s", s.Timezone), nil
+				}
+				switch s.MessageAudit {
+				case models.MessageAuditOff, models.MessageAuditHash, models.MessageAuditImmutable:
+				default:
+					return "Invalid message audit mode", nil
+				}
+				if s.BroadcastDailyLimit < 0 {
+					return "Broadcast daily limit can't be negative", nil
+				}
+				switch s.DuplicateCampaignCheck {
+				case DuplicateCampaignOff, DuplicateCampaignConfirm, DuplicateCampaignBlock:
+				default:
+					return "Invalid duplicate campaign check mode", nil
+				}
+				if s.DuplicateCampaignWindowHours <= 0 {
+					return "Duplicate campaign window must be positive", nil
+				}
+				if s.DuplicateCampaignOverlap < 1 || s.DuplicateCampaignOverlap > 100 {
+					return "Duplicate campaign overlap must be between 1 and 100", nil
+				}
+				if len([]rune(s.MessageFooter)) > maxMessageFooterLength {
+					return fmt.Sprintf("Message footer can be at most %d characters", maxMessageFooterLength), nil
+				}
+				if err := validateFooterCategories(s.MessageFooterCategories); err != nil {
+					return err.Error(), nil
+				}
+				if err := s.RedactionRules.Validate(); err != nil {
+					return err.Error(), nil
+				}
+				if s.WeekStart != "" && !slices.Contains(reports.WeekStarts, s.WeekStart) {
+					return "Invalid week start", nil
+				}
+				if s.FiscalYearStart < 0 || s.FiscalYearStart > 12 {
+					return "Fiscal year start must be a month from 1 to 12", nil
+				}
+				return "", nil
+			})...)
+	}
+
+	items = append(items, planConfigSection("labels", bundle.Labels, current.Labels,
+		func(l ConfigLabel, _ *ConfigLabel) (string, []string) {
+			if l.Name == "" {
+				return "Name is required", nil
+			}
+			return "", nil
+		})...)
+
+	items = append(items, planConfigSection("working_hours", bundle.WorkingHours, current.WorkingHours,
+		func(w ConfigWorkingHours, _ *ConfigWorkingHours) (string, []string) {
+			return checkAccount(w.WhatsAppAccount), nil
+		})...)
+
+	items = append(items, planConfigSection("routing", bundle.Routing, current.Routing,
+		func(c ConfigRouting, _ *ConfigRouting) (string, []string) {
+			return checkAccount(c.WhatsAppAccount), nil
+		})...)
+
+	items = append(items, planConfigSection("holiday_calendars", bundle.HolidayCalendars, current.HolidayCalendars,
+		func(c ConfigHolidayCalendar, _ *ConfigHolidayCalendar) (string, []string) {
+			if c.Name == "" {
+				return "Name is required", nil
+			}
+			if _, err := holidays.FeedURL(c.URL); err != nil {
+				return err.Error(), nil
+			}
+			return "", nil
+		})...)
+
+	items = append(items, planConfigSection("blackout_dates", bundle.BlackoutDates, current.BlackoutDates,
+		func(d ConfigBlackoutDate, _ *ConfigBlackoutDate) (string, []string) {
+			if _, err := time.Parse("2006-01-02", d.Date); err != nil {
+				return "Date must be YYYY-MM-DD", nil
+			}
+			return "", nil
+		})...)
+
+	items = append(items, planConfigSection("canned_responses", bundle.CannedResponses, current.CannedResponses,
+		func(c ConfigCannedResponse, _ *ConfigCannedResponse) (string, []string) {
+			if c.Name == "" || c.Content == "" {
+				return "Name and content are required", nil
+			}
+			return "", nil
+		})...)
+
+	items = append(items, planConfigSection("keyword_rules", bundle.KeywordRules, current.KeywordRules,
+		func(k ConfigKeywordRule, _ *ConfigKeywordRule) (string, []string) {
+			if k.WhatsAppAccount == "" {
+				return "WhatsApp account is required", nil
+			}
+			if k.Name == "" || len(k.Keywords) == 0 || k.ResponseType == "" {
+				return "Name, keywords and response type are required", nil
+			}
+			return checkAccount(k.WhatsAppAccount), nil
+		})...)
+
+	items = append(items, planConfigSection("label_rules", bundle.LabelRules, current.LabelRules,
+		func(l ConfigLabelRule, _ *ConfigLabelRule) (string, []string) {
+			switch l.TriggerType {
+			case models.LabelTriggerKeyword, models.LabelTriggerAdReferral, models.LabelTriggerFlowOutcome:
+			default:
+				return "Invalid trigger type", nil
+			}
+			if !labelExists(l.Label) {
+				return fmt.Sprintf("Label %s not found", l.Label), nil
+			}
+			if _, ok := refs.flowIDs[l.Flow]; l.Flow != "" && !ok {
+				return fmt.Sprintf("Chatbot flow %s not found", l.Flow), nil
+			}
+			return checkAccount(l.WhatsAppAccount), nil
+		})...)
+
+	items = append(items, planConfigSection("win_back_automations", bundle.WinBack, current.WinBack,
+		func(w ConfigWinBackAutomation, _ *ConfigWinBackAutomation) (string, []string) {
+			if w.WhatsAppAccount == "" {
+				return "WhatsApp account is required", nil
+			}
+			if msg := checkAccount(w.WhatsAppAccount); msg != "" {
+				return msg, nil
+			}
+			if len(w.Steps) == 0 {
+				return "At least one step is required", nil
+			}
+			for _, step := range w.Steps {
+				if msg := checkTemplate(w.WhatsAppAccount, step.Template); msg != "" {
+					return msg, nil
+				}
+			}
+			if w.ExitLabel != "" && !labelExists(w.ExitLabel) {
+				return fmt.Sprintf("Label %s not found", w.ExitLabel), nil
+			}
+			return "", nil
+		})...)
+
+	items = append(items, planConfigSection("date_triggers", bundle.DateTriggers, current.DateTriggers,
+		func(t ConfigDateTrigger, _ *ConfigDateTrigger) (string, []string) {
+			if t.WhatsAppAccount == "" {
+				return "WhatsApp account is required", nil
+			}
+			if msg := checkAccount(t.WhatsAppAccount); msg != "" {
+				return msg, nil
+			}
+			if t.DateField == "" {
+				return "Date field is required", nil
+			}
+			return checkTemplate(t.WhatsAppAccount, t.Template), nil
+		})...)
+
+	items = append(items, planConfigSection("teams", bundle.Teams, current.Teams,
+		func(t ConfigTeam, existing *ConfigTeam) (string, []string) {
+			switch t.AssignmentStrategy {
+			case "round_robin", "load_balanced", "manual":
+			default:
+				return "Invalid assignment strategy", nil
+			}
+			if existing == nil {
+				return "", []string{"Add members to the team after importing"}
+			}
+			return "", nil
+		})...)
+
+	items = append(items, planConfigSection("webhooks", bundle.Webhooks, current.Webhooks,
+		func(w ConfigWebhook, existing *ConfigWebhook) (string, []string) {
+			if w.URL == "" || len(w.Events) == 0 {
+				return "URL and at least one event are required", nil
+			}
+			var warnings []string
+			if existing == nil {
+				warnings = append(warnings, "Signing secrets aren't exported; rotate the secret after importing")
+			}
+			for name, value := range w.Headers {
+				if value != configRedacted {
+					continue
+				}
+				if existing == nil || existing.Headers[name] == "" {
+					warnings = append(warnings, fmt.Sprintf("Header %s is redacted and won't be set; add its value after importing", name))
+				}
+			}
+			sort.Strings(warnings)
+			return "", warnings
+		})...)
+
+	for _, item := range items {
+		result.Summary[item.Action]++
+		if item.Action != configActionUnchanged {
+			result.Items = append(result.Items, item)
+		}
+	}
+	return result
+}
+
+// planConfigSection matches a section's bundle records to the organization's current
+// records by key and diffs them. check validates a record that would be written;
+// existing is nil when the record would be created.
+func planConfigSection[T configRecord](section string, records, current []T, check func(rec T, existing *T) (string, []string)) []ConfigImportItem {
+	byKey := make(map[string]T, len(current))
+	for _, c := range current {
+		byKey[c.configKey()] = c
+	}
+
+	seen := make(map[string]bool, len(records))
+	items := make([]ConfigImportItem, 0, len(records))
+	for _, rec := range records {
+		item := ConfigImportItem{Section: section, Key: rec.configKey()}
+		if seen[item.Key] {
+			item.Action, item.Error = configActionInvalid, "Duplicate record in bundle"
+			items = append(items, item)
+			continue
+		}
+		seen[item.Key] = true
+
+		var existing *T
+		if c, ok := byKey[item.Key]; ok {
+			existing = &c
+			item.Changes = diffConfigRecord(c, rec)
+		}
+		switch {
+		case existing == nil:
+			item.Action = configActionCreate
+		case len(item.Changes) > 0:
+			item.Action = configActionUpdate
+		default:
+			item.Action = configActionUnchanged
+		}
+
+		if item.Action != configActionUnchanged {
+			item.Error, item.Warnings = check(rec, existing)
+			if item.Error != "" {
+				item.Action = configActionInvalid
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// diffConfigRecord lists the fields that differ between two records, comparing their
+// JSON so that values decoded from a bundle match ones loaded from the database
+func diffConfigRecord(from, to interface{}) []ConfigFieldChange {
+	fromFields, toFields := configFields(from), configFields(to)
+
+	names := make([]string, 0, len(toFields))
+	for name := range toFields {
+		names = append(names, name)
+	}
+	for name := range fromFields {
+		if _, ok := toFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []ConfigFieldChange
+	for _, name := range names {
+		if !reflect.DeepEqual(fromFields[name], toFields[name]) {
+			changes = append(changes, ConfigFieldChange{Field: name, From: fromFields[name], To: toFields[name]})
+		}
+	}
+	return changes
+}
+
+func configFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	_ = json.Unmarshal(data, &fields)
+
+	// Treat empty lists and objects like missing ones
+	for name, value := range fields {
+		switch val := value.(type) {
+		case []interface{}:
+			if len(val) == 0 {
+				fields[name] = nil
+			}
+		case map[string]interface{}:
+			if len(val) == 0 {
+				fields[name] = nil
+			}
+		}
+	}
+	return fields
+}
+
+// applyConfigImport writes the bundle records that planConfigImport found to be new or
+// changed. Labels are written first so rules and automations can reference them.
+func applyConfigImport(tx *gorm.DB, orgID, userID uuid.UUID, bundle *ConfigBundle, items []ConfigImportItem) error {
+	pending := map[string]bool{}
+	for _, item := range items {
+		if item.Action == configActionCreate || item.Action == configActionUpdate {
+			pending[item.Section+"/"+item.Key] = true
+		}
+	}
+	write := func(section string, rec configRecord) bool {
+		return pending[section+"/"+rec.configKey()]
+	}
+
+	if bundle.Settings != nil && write("settings", *bundle.Settings) {
+		if err := applyConfigSettings(tx, orgID, *bundle.Settings); err != nil {
+			return fmt.Errorf("settings: %w", err)
+		}
+	}
+	for _, rec := range bundle.Labels {
+		if write("labels", rec) {
+			if err := applyConfigLabel(tx, orgID, rec); err != nil {
+				return fmt.Errorf("label %s: %w", rec.Name, err)
+			}
+		}
+	}
+
+	refs, err := loadConfigRefs(tx, orgID)
+	if err != nil {
+		return err
+	}
+
+	for _, rec := range bundle.WorkingHours {
+		if write("working_hours", rec) {
+			if err := applyConfigChatbotSettings(tx, orgID, rec.WhatsAppAccount, map[string]interface{}{
+				"business_hours_enabled":        rec.BusinessHoursEnabled,
+				"business_hours":                rec.BusinessHours,
+				"out_of_hours_message":          rec.OutOfHoursMessage,
+				"allow_automated_outside_hours": rec.AllowAutomatedOutsideHours,
+			}); err != nil {
+				return fmt.Errorf("working hours for %s: %w", rec.configKey(), err)
+			}
+		}
+	}
+	for _, rec := range bundle.Routing {
+		if write("routing", rec) {
+			if err := applyConfigChatbotSettings(tx, orgID, rec.WhatsAppAccount, map[string]interface{}{
+				"allow_agent_queue_pickup":        rec.AllowAgentQueuePickup,
+				"assign_to_same_agent":            rec.AssignToSameAgent,
+				"agent_current_conversation_only": rec.AgentCurrentConversationOnly,
+				"sla_enabled":                     rec.SLAEnabled,
+				"sla_response_minutes":            rec.SLAResponseMinutes,
+				"sla_resolution_minutes":          rec.SLAResolutionMinutes,
+				"sla_escalation_minutes":          rec.SLAEscalationMinutes,
+				"sla_auto_close_hours":            rec.SLAAutoCloseHours,
+				"sla_auto_close_message":          rec.SLAAutoCloseMessage,
+				"sla_warning_message":             rec.SLAWarningMessage,
+			}); err != nil {
+				return fmt.Errorf("routing for %s: %w", rec.configKey(), err)
+			}
+		}
+	}
+	for _, rec := range bundle.HolidayCalendars {
+		if write("holiday_calendars", rec) {
+			if err := applyConfigHolidayCalendar(tx, orgID, rec); err != nil {
+				return fmt.Errorf("holiday calendar %s: %w", rec.Name, err)
+			}
+		}
+	}
+	for _, rec := range bundle.BlackoutDates {
+		if write("blackout_dates", rec) {
+			if err := applyConfigBlackoutDate(tx, orgID, rec); err != nil {
+				return fmt.Errorf("blackout date %s: %w", rec.Date, err)
+			}
+		}
+	}
+	for _, rec := range bundle.CannedResponses {
+		if write("canned_responses", rec) {
+			if err := applyConfigCannedResponse(tx, orgID, userID, rec); err != nil {
+				return fmt.Errorf("canned response %s: %w", rec.Name, err)
+			}
+		}
+	}
+	for _, rec := range bundle.KeywordRules {
+		if write("keyword_rules", rec) {
+			if err := applyConfigKeywordRule(tx, orgID, rec); err != nil {
+				return fmt.Errorf("keyword rule %s: %w", rec.Name, err)
+			}
+		}
+	}
+	for _, rec := range bundle.LabelRules {
+		if write("label_rules", rec) {
+			if err := applyConfigLabelRule(tx, orgID, refs, rec); err != nil {
+				return fmt.Errorf("label rule %s: %w", rec.Name, err)
+			}
+		}
+	}
+	for _, rec := range bundle.WinBack {
+		if write("win_back_automations", rec) {
+			if err := applyConfigWinBack(tx, orgID, refs, rec); err != nil {
+				return fmt.Errorf("win-back automation %s: %w", rec.Name, err)
+			}
+		}
+	}
+	for _, rec := range bundle.DateTriggers {
+		if write("date_triggers", rec) {
+			if err := applyConfigDateTrigger(tx, orgID, userID, refs, rec); err != nil {
+				return fmt.Errorf("date trigger %s: %w", rec.Name, err)
+			}
+		}
+	}
+	for _, rec := range bundle.Teams {
+		if write("teams", rec) {
+			if err := applyConfigTeam(tx, orgID, rec); err != nil {
+				return fmt.Errorf("team %s: %w", rec.Name, err)
+			}
+		}
+	}
+	for _, rec := range bundle.Webhooks {
+		if write("webhooks", rec) {
+			if err := applyConfigWebhook(tx, orgID, rec); err != nil {
+				return fmt.Errorf("webhook %s: %w", rec.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func applyConfigSettings(tx *gorm.DB, orgID uuid.UUID, s ConfigSettings) error {
+	var org models.Organization
+	if err := tx.Where("id = ?", orgID).First(&org).Error; err != nil {
+		return err
+	}
+	if org.Settings == nil {
+		org.Settings = models.JSONB{}
+	}
+	org.Settings["mask_phone_numbers"] = s.MaskPhoneNumbers
+	org.Settings["timezone"] = s.Timezone
+	org.Settings["date_format"] = s.DateFormat
+	org.Settings["message_audit"] = s.MessageAudit
+	org.Settings["broadcast_daily_limit"] = s.BroadcastDailyLimit
+	org.Settings["duplicate_campaign_check"] = s.DuplicateCampaignCheck
+	org.Settings["duplicate_campaign_window_hours"] = s.DuplicateCampaignWindowHours
+	org.Settings["duplicate_campaign_overlap"] = s.DuplicateCampaignOverlap
+	org.Settings["message_footer"] = s.MessageFooter
+	org.Settings["message_footer_categories"] = s.MessageFooterCategories
+	org.Settings["redaction_rules"] = s.RedactionRules
+	if s.WeekStart != "" {
+		org.Settings[reports.WeekStartSetting] = s.WeekStart
+	}
+	if s.FiscalYearStart != 0 {
+		org.Settings[reports.FiscalYearStartSetting] = s.FiscalYearStart
+	}
+	return tx.Save(&org).Error
+}
+
+func applyConfigLabel(tx *gorm.DB, orgID uuid.UUID, rec ConfigLabel) error {
+	var label models.Label
+	found, err := findConfigRecord(tx, &label, "organization_id = ? AND name = ?", orgID, rec.Name)
+	if err != nil {
+		return err
+	}
+	label.OrganizationID = orgID
+	label.Name = rec.Name
+	label.Color = rec.Color
+	label.Description = rec.Description
+	return saveConfigRecord(tx, &label, found)
+}
+
+// applyConfigChatbotSettings updates columns of an account's chatbot settings,
+// creating the settings with their defaults first if the account has none
+func applyConfigChatbotSettings(tx *gorm.DB, orgID uuid.UUID, account string, updates map[string]interface{}) error {
+	var settings models.ChatbotSettings
+	found, err := findConfigRecord(tx, &settings, "organization_id = ? AND whats_app_account = ?", orgID, account)
+	if err != nil {
+		return err
+	}
+	if !found {
+		settings = models.ChatbotSettings{OrganizationID: orgID, WhatsAppAccount: account}
+		if err := tx.Create(&settings).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&settings).Updates(updates).Error
+}
+
+func applyConfigHolidayCalendar(tx *gorm.DB, orgID uuid.UUID, rec ConfigHolidayCalendar) error {
+	var calendar models.HolidayCalendar
+	found, err := findConfigRecord(tx, &calendar, "organization_id = ? AND name = ?", orgID, rec.Name)
+	if err != nil {
+		return err
+	}
+	feedURL, err := holidays.FeedURL(rec.URL)
+	if err != nil {
+		return err
+	}
+
+	// Sync a new or changed feed on the next worker run; a disabled calendar no longer
+	// blocks campaigns
+	if !found || calendar.URL != feedURL || (rec.IsActive && !calendar.IsActive) {
+		calendar.LastSyncedAt = nil
+	}
+	if found && calendar.IsActive && !rec.IsActive {
+		if err := tx.Unscoped().Where("calendar_id = ?", calendar.ID).Delete(&models.CampaignBlackoutDate{}).Error; err != nil {
+			return err
+		}
+		calendar.EventCount = 0
+	}
+
+	calendar.OrganizationID = orgID
+	calendar.Name = rec.Name
+	calendar.Region = rec.Region
+	calendar.URL = feedURL
+	calendar.IsActive = rec.IsActive
+	return saveConfigRecord(tx, &calendar, found)
+}
+
+func applyConfigBlackoutDate(tx *gorm.DB, orgID uuid.UUID, rec ConfigBlackoutDate) error {
+	date, err := time.Parse("2006-01-02", rec.Date)
+	if err != nil {
+		return err
+	}
+	var blackout models.CampaignBlackoutDate
+	found, err := findConfigRecord(tx, &blackout, "organization_id = ? AND calendar_id IS NULL AND date = ?", orgID, date)
+	if err != nil {
+		return err
+	}
+	blackout.OrganizationID = orgID
+	blackout.Date = date
+	blackout.Name = rec.Name
+	blackout.Region = rec.Region
+	return saveConfigRecord(tx, &blackout, found)
+}
+
+func applyConfigCannedResponse(tx *gorm.DB, orgID, userID uuid.UUID, rec ConfigCannedResponse) error {
+	var response models.CannedResponse
+	found, err := findConfigRecord(tx, &response, "organization_id = ? AND name = ?", orgID, rec.Name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		response.CreatedByID = userID
+	}
+	response.OrganizationID = orgID
+	response.Name = rec.Name
+	response.Shortcut = rec.Shortcut
+	response.Content = rec.Content
+	response.Category = rec.Category
+	response.IsActive = rec.IsActive
+	return saveConfigRecord(tx, &response, found)
+}
+
+func applyConfigKeywordRule(tx *gorm.DB, orgID uuid.UUID, rec ConfigKeywordRule) error {
+	var rule models.KeywordRule
+	found, err := findConfigRecord(tx, &rule, "organization_id = ? AND whats_app_account = ? AND name = ?", orgID, rec.WhatsAppAccount, rec.Name)
+	if err != nil {
+		return err
+	}
+	rule.OrganizationID = orgID
+	rule.WhatsAppAccount = rec.WhatsAppAccount
+	rule.Name = rec.Name
+	rule.IsEnabled = rec.IsEnabled
+	rule.Priority = rec.Priority
+	rule.Keywords = rec.Keywords
+	rule.MatchType = rec.MatchType
+	rule.CaseSensitive = rec.CaseSensitive
+	rule.ResponseType = rec.ResponseType
+	rule.ResponseContent = rec.ResponseContent
+	if rule.ResponseContent == nil {
+		rule.ResponseContent = models.JSONB{}
+	}
+	rule.Conditions = rec.Conditions
+	rule.ActiveFrom = rec.ActiveFrom
+	rule.ActiveUntil = rec.ActiveUntil
+	return saveConfigRecord(tx, &rule, found)
+}
+
+func applyConfigLabelRule(tx *gorm.DB, orgID uuid.UUID, refs *configRefs, rec ConfigLabelRule) error {
+	var rule models.LabelRule
+	found, err := findConfigRecord(tx, &rule, "organization_id = ? AND whats_app_account = ? AND name = ?", orgID, rec.WhatsAppAccount, rec.Name)
+	if err != nil {
+		return err
+	}
+	labelID, ok := refs.labelIDs[rec.Label]
+	if !ok {
+		return fmt.Errorf("label %s not found", rec.Label)
+	}
+	rule.FlowID = nil
+	if rec.Flow != "" {
+		flowID, ok := refs.flowIDs[rec.Flow]
+		if !ok {
+			return fmt.Errorf("chatbot flow %s not found", rec.Flow)
+		}
+		rule.FlowID = &flowID
+	}
+
+	rule.OrganizationID = orgID
+	rule.WhatsAppAccount = rec.WhatsAppAccount
+	rule.Name = rec.Name
+	rule.LabelID = labelID
+	rule.IsEnabled = rec.IsEnabled
+	rule.TriggerType = rec.TriggerType
+	rule.Keywords = rec.Keywords
+	rule.MatchType = rec.MatchType
+	rule.CaseSensitive = rec.CaseSensitive
+	rule.AdSourceIDs = rec.AdSourceIDs
+	rule.FlowOutcome = rec.FlowOutcome
+	return saveConfigRecord(tx, &rule, found)
+}
+
+func applyConfigWinBack(tx *gorm.DB, orgID uuid.UUID, refs *configRefs, rec ConfigWinBackAutomation) error {
+	var automation models.WinBackAutomation
+	found, err := findConfigRecord(tx, &automation, "organization_id = ? AND whats_app_account = ? AND name = ?", orgID, rec.WhatsAppAccount, rec.Name)
+	if err != nil {
+		return err
+	}
+
+	steps := make(models.JSONBArray, len(rec.Steps))
+	for i, step := range rec.Steps {
+		templateID, ok := refs.templateIDs[templateKey(rec.WhatsAppAccount, step.Template)]
+		if !ok {
+			return fmt.Errorf("template %s not found", step.Template)
+		}
+		params := make(map[string]interface{}, len(step.TemplateParams))
+		for k, v := range step.TemplateParams {
+			params[k] = v
+		}
+		steps[i] = map[string]interface{}{
+			"template_id":     templateID.String(),
+			"delay_days":      step.DelayDays,
+			"template_params": params,
+		}
+	}
+	automation.ExitLabelID = nil
+	if rec.ExitLabel != "" {
+		labelID, ok := refs.labelIDs[rec.ExitLabel]
+		if !ok {
+			return fmt.Errorf("label %s not found", rec.ExitLabel)
+		}
+		automation.ExitLabelID = &labelID
+	}
+
+	automation.OrganizationID = orgID
+	automation.WhatsAppAccount = rec.WhatsAppAccount
+	automation.Name = rec.Name
+	automation.IsEnabled = rec.IsEnabled
+	automation.InactiveDays = rec.InactiveDays
+	automation.MinPastReplies = rec.MinPastReplies
+	automation.Steps = steps
+	automation.DailyCap = rec.DailyCap
+	automation.CooldownDays = rec.CooldownDays
+	return saveConfigRecord(tx, &automation, found)
+}
+
+func applyConfigDateTrigger(tx *gorm.DB, orgID, userID uuid.UUID, refs *configRefs, rec ConfigDateTrigger) error {
+	var trigger models.DateTrigger
+	found, err := findConfigRecord(tx, &trigger, "organization_id = ? AND whats_app_account = ? AND name = ?", orgID, rec.WhatsAppAccount, rec.Name)
+	if err != nil {
+		return err
+	}
+	templateID, ok := refs.templateIDs[templateKey(rec.WhatsAppAccount, rec.Template)]
+	if !ok {
+		return fmt.Errorf("template %s not found", rec.Template)
+	}
+	if !found {
+		trigger.CreatedBy = userID
+	}
+
+	trigger.OrganizationID = orgID
+	trigger.WhatsAppAccount = rec.WhatsAppAccount
+	trigger.Name = rec.Name
+	trigger.IsEnabled = rec.IsEnabled
+	trigger.DateField = rec.DateField
+	trigger.Annual = rec.Annual
+	trigger.OffsetDays = rec.OffsetDays
+	trigger.SendHour = rec.SendHour
+	trigger.TemplateID = templateID
+	trigger.TemplateParams = rec.TemplateParams
+	if trigger.TemplateParams == nil {
+		trigger.TemplateParams = models.JSONB{}
+	}
+	return saveConfigRecord(tx, &trigger, found)
+}
+
+func applyConfigTeam(tx *gorm.DB, orgID uuid.UUID, rec ConfigTeam) error {
+	var team models.Team
+	found, err := findConfigRecord(tx, &team, "organization_id = ? AND name = ?", orgID, rec.Name)
+	if err != nil {
+		return err
+	}
+	team.OrganizationID = orgID
+	team.Name = rec.Name
+	team.Description = rec.Description
+	team.AssignmentStrategy = rec.AssignmentStrategy
+	team.IsActive = rec.IsActive
+	return saveConfigRecord(tx, &team, found)
+}
+
+// applyConfigWebhook writes a webhook, keeping the stored value of headers redacted in
+// the bundle and dropping ones it has no value for. The signing secret is untouched.
+func applyConfigWebhook(tx *gorm.DB, orgID uuid.UUID, rec ConfigWebhook) error {
+	var webhook models.Webhook
+	found, err := findConfigRecord(tx, &webhook, "organization_id = ? AND name = ?", orgID, rec.Name)
+	if err != nil {
+		return err
+	}
+
+	headers := models.JSONB{}
+	for name, value := range rec.Headers {
+		if value != configRedacted {
+			headers[name] = value
+		} else if stored, ok := webhook.Headers[name]; ok {
+			headers[name] = stored
+		}
+	}
+
+	webhook.OrganizationID = orgID
+	webhook.Name = rec.Name
+	webhook.URL = rec.URL
+	webhook.Events = rec.Events
+	webhook.Headers = headers
+	webhook.IsActive = rec.IsActive
+	return saveConfigRecord(tx, &webhook, found)
+}
+
+// findConfigRecord loads the record an import would update, reporting whether it exists
+func findConfigRecord(tx *gorm.DB, dest interface{}, query string, args ...interface{}) (bool, error) {
+	err := tx.Where(query, args...).First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// saveConfigRecord writes an imported record. A new record is saved again after it is
+// created so false and zero values aren't replaced by column defaults.
+func saveConfigRecord(tx *gorm.DB, value interface{}, found bool) error {
+	if !found {
+		if err := tx.Create(value).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Save(value).Error
+}
+
+// configTime normalizes a time to UTC so exports from different servers compare equal
+func configTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func sensitiveHeader(name string) bool {
+	lower := strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// SharedContact is the structured form of a vCard shared in a contacts message.
+// It is stored as a JSON array in Message.Content for both directions.
+type SharedContact struct {
+	Name         string   `json:"name"`
+	FirstName    string   `json:"first_name,omitempty"`
+	LastName     string   `json:"last_name,omitempty"`
+	Phones       []string `json:"phones,omitempty"`
+	WaID         string   `json:"wa_id,omitempty"` // First phone that is on WhatsApp
+	Emails       []string `json:"emails,omitempty"`
+	Organization string   `json:"organization,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	URLs         []string `json:"urls,omitempty"`
+	Birthday     string   `json:"birthday,omitempty"`
+}
+
+// SendContactCardsRequest represents a request to share address book contacts with a contact
+type SendContactCardsRequest struct {
+	ContactIDs []string `json:"contact_ids"`
+}
+
+// parseSharedContacts converts WhatsApp contact cards into SharedContact entries
+func parseSharedContacts(cards []whatsapp.ContactCard) []SharedContact {
+	result := make([]SharedContact, 0, len(cards))
+	for _, c := range cards {
+		sc := SharedContact{
+			Name:      c.Name.FormattedName,
+			FirstName: c.Name.FirstName,
+			LastName:  c.Name.LastName,
+			Birthday:  c.Birthday,
+		}
+		if sc.Name == "" {
+			sc.Name = strings.TrimSpace(c.Name.FirstName + " " + c.Name.LastName)
+		}
+		for _, p := range c.Phones {
+			if p.Phone != "" {
+				sc.Phones = append(sc.Phones, p.Phone)
+			}
+			if sc.WaID == "" && p.WaID != "" {
+				sc.WaID = p.WaID
+			}
+		}
+		for _, e := range c.Emails {
+			if e.Email != "" {
+				sc.Emails = append(sc.Emails, e.Email)
+			}
+		}
+		for _, u := range c.URLs {
+			if u.URL != "" {
+				sc.URLs = append(sc.URLs, u.URL)
+			}
+		}
+		if c.Org != nil {
+			sc.Organization = c.Org.Company
+			sc.Title = c.Org.Title
+		}
+		result = append(result, sc)
+	}
+	return result
+}
+
+// sharedContactPhone returns the phone number to use when saving a shared contact,
+// preferring the WhatsApp ID and falling back to the digits of the first phone
+func sharedContactPhone(sc SharedContact) string {
+	if sc.WaID != "" {
+		return sc.WaID
+	}
+	if len(sc.Phones) == 0 {
+		return ""
+	}
+	var digits strings.Builder
+	for _, ch := range sc.Phones[0] {
+		if ch >= '0' && ch <= '9' {
+			digits.WriteRune(ch)
+		}
+	}
+	return digits.String()
+}
+
+// contactToCard builds a WhatsApp contact card from an address book contact
+func contactToCard(contact *models.Contact) whatsapp.ContactCard {
+	name := contact.ProfileName
+	if name == "" {
+		name = "+" + contact.PhoneNumber
+	}
+	phone := strings.TrimPrefix(contact.PhoneNumber, "+")
+	return whatsapp.ContactCard{
+		Name: whatsapp.ContactName{FormattedName: name},
+		Phones: []whatsapp.ContactPhone{
+			{Phone: "+" + phone, Type: "CELL", WaID: phone},
+		},
+	}
+}
+
+// SaveSharedContact creates an address book contact from a vCard shared in a message
+// The index path parameter selects the card when several were shared at once
+func (a *App) SaveSharedContact(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	userRole, _ := r.RequestCtx.UserValue("role").(string)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+	messageID, err := uuid.Parse(r.RequestCtx.UserValue("message_id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid message ID", nil, "")
+	}
+	index, err := strconv.Atoi(r.RequestCtx.UserValue("index").(string))
+	if err != nil || index < 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact card index", nil, "")
+	}
+
+	// Verify contact access (agents can only use their assigned contacts)
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	if userRole == "agent" {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var message models.Message
+	if err := a.DB.Where("id = ? AND contact_id = ? AND message_type = ?", messageID, contactID, "contacts").First(&message).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Message not found", nil, "")
+	}
+
+	var shared []SharedContact
+	if err := json.Unmarshal([]byte(message.Content), &shared); err != nil || index >= len(shared) {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact card not found", nil, "")
+	}
+	card := shared[index]
+
+	phone := sharedContactPhone(card)
+	if phone == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Contact card has no phone number", nil, "")
+	}
+
+	saved, created := a.getOrCreateContact(orgID, phone, card.Name)
+
+	// Keep the extra vCard fields so they show up in the contact's custom fields
+	if created {
+		metadata := models.JSONB{"source": "shared_contact", "shared_by_contact_id": contactID.String()}
+		if len(card.Emails) > 0 {
+			metadata["email"] = card.Emails[0]
+		}
+		if card.Organization != "" {
+			metadata["company"] = card.Organization
+		}
+		if card.Birthday != "" {
+			metadata["birthday"] = card.Birthday
+		}
+		if err := a.DB.Model(saved).Update("metadata", metadata).Error; err != nil {
+			a.Log.Error("Failed to store shared contact details", "error", err, "contact_id", saved.ID)
+		}
+
+		a.DispatchWebhook(orgID, EventContactCreated, ContactEventData{
+			ContactID:       saved.ID.String(),
+			ContactPhone:    saved.PhoneNumber,
+			ContactName:     saved.ProfileName,
+			WhatsAppAccount: saved.WhatsAppAccount,
+		})
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"contact_id":   saved.ID,
+		"phone_number": saved.PhoneNumber,
+		"name":         saved.ProfileName,
+		"created":      created,
+	})
+}
+
+// SendContactCards shares contacts from the organization's address book as contact cards
+// Agents can only send to their assigned contacts
+func (a *App) SendContactCards(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	userRole, _ := r.RequestCtx.UserValue("role").(string)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var req SendContactCardsRequest
+	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.ContactIDs) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_ids is required", nil, "")
+	}
+
+	sharedIDs := make([]uuid.UUID, 0, len(req.ContactIDs))
+	for _, idStr := range req.ContactIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID in contact_ids", nil, "")
+		}
+		sharedIDs = append(sharedIDs, id)
+	}
+
+	// Get recipient contact (agents can only message their assigned contacts)
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	if userRole == "agent" {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var sharedContacts []models.Contact
+	if err := a.DB.Where("id IN ? AND organization_id = ?", sharedIDs, orgID).Find(&sharedContacts).Error; err != nil {
+		a.Log.Error("Failed to load contacts to share", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load contacts", nil, "")
+	}
+	if len(sharedContacts) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No contacts to share", nil, "")
+	}
+
+	cards := make([]whatsapp.ContactCard, len(sharedContacts))
+	for i := range sharedContacts {
+		cards[i] = contactToCard(&sharedContacts[i])
+	}
+
+	// Get WhatsApp account
+	var account models.WhatsAppAccount
+	if contact.WhatsAppAccount != "" {
+		if err := a.DB.Where("name = ? AND organization_id = ?", contact.WhatsAppAccount, orgID).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+		}
+	} else {
+		if err := a.DB.Where("organization_id = ? AND is_default_outgoing = ?", orgID, true).First(&account).Error; err != nil {
+			if err := a.DB.Where("organization_id = ?", orgID).First(&account).Error; err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No WhatsApp account configured", nil, "")
+			}
+		}
+	}
+
+	contentBytes, _ := json.Marshal(parseSharedContacts(cards))
+
+	message := models.Message{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  orgID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contactID,
+		Direction:       "outgoing",
+		MessageType:     "contacts",
+		Content:         string(contentBytes),
+		Status:          "pending",
+		SentByUserID:    &userID,
+	}
+
+	if err := a.DB.Create(&message).Error; err != nil {
+		a.Log.Error("Failed to create message", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create message", nil, "")
+	}
+	go a.recordConversationParticipation(orgID, contactID, userID)
+
+	waAccount := &whatsapp.Account{
+		PhoneID:     account.PhoneID,
+		BusinessID:  account.BusinessID,
+		APIVersion:  account.APIVersion,
+		AccessToken: account.AccessToken,
+	}
+	go func() {
+		wamID, err := a.WhatsApp.SendContactsMessage(context.Background(), waAccount, contact.PhoneNumber, cards)
+		if err != nil {
+			a.Log.Error("Failed to send contact cards", "error", err, "contact_id", contact.ID)
+			a.DB.Model(&message).Updates(map[string]any{
+				"status":        "failed",
+				"error_message": err.Error(),
+			})
+			return
+		}
+		a.DB.Model(&message).Updates(map[string]any{
+			"status":               "sent",
+			"whats_app_message_id": wamID,
+		})
+	}()
+
+	now := time.Now()
+	a.DB.Model(&contact).Updates(map[string]any{
+		"last_message_at":      now,
+		"last_message_preview": "[contacts]",
+	})
+
+	response := MessageResponse{
+		ID:          message.ID,
+		ContactID:   message.ContactID,
+		Direction:   message.Direction,
+		MessageType: message.MessageType,
+		Content:     map[string]string{"body": message.Content},
+		Status:      message.Status,
+		CreatedAt:   message.CreatedAt,
+		UpdatedAt:   message.UpdatedAt,
+	}
+
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
+			Type: websocket.TypeNewMessage,
+			Payload: map[string]any{
+				"id":           message.ID.String(),
+				"contact_id":   message.ContactID.String(),
+				"direction":    message.Direction,
+				"message_type": message.MessageType,
+				"content":      map[string]string{"body": message.Content},
+				"status":       message.Status,
+				"created_at":   message.CreatedAt,
+				"updated_at":   message.UpdatedAt,
+			},
+		})
+	}
+
+	return r.SendEnvelope(response)
+}
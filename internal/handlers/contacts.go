@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/internal/websocket"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
@@ -23,20 +24,23 @@ import (
 
 // ContactResponse represents a contact with additional fields for the frontend
 type ContactResponse struct {
-	ID                 uuid.UUID  `json:"id"`
-	PhoneNumber        string     `json:"phone_number"`
-	Name               string     `json:"name"`
-	ProfileName        string     `json:"profile_name"`
-	AvatarURL          string     `json:"avatar_url"`
-	Status             string     `json:"status"`
-	Tags               []string   `json:"tags"`
-	CustomFields       any        `json:"custom_fields"`
-	LastMessageAt      *time.Time `json:"last_message_at"`
-	LastMessagePreview string     `json:"last_message_preview"`
-	UnreadCount        int        `json:"unread_count"`
-	AssignedUserID     *uuid.UUID `json:"assigned_user_id,omitempty"`
-	CreatedAt          time.Time  `json:"created_at"`
-	UpdatedAt          time.Time  `json:"updated_at"`
+	ID                 uuid.UUID      `json:"id"`
+	PhoneNumber        string         `json:"phone_number"`
+	Name               string         `json:"name"`
+	ProfileName        string         `json:"profile_name"`
+	AvatarURL          string         `json:"avatar_url"`
+	Status             string         `json:"status"`
+	Tags               []string       `json:"tags"`
+	Labels             []LabelSummary `json:"labels"`
+	EngagementScore    int            `json:"engagement_score"`
+	CustomFields       any            `json:"custom_fields"`
+	Timezone           string         `json:"timezone"`
+	LastMessageAt      *time.Time     `json:"last_message_at"`
+	LastMessagePreview string         `json:"last_message_preview"`
+	UnreadCount        int            `json:"unread_count"`
+	AssignedUserID     *uuid.UUID     `json:"assigned_user_id,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
 }
 
 // MessageResponse represents a message for the frontend
@@ -49,6 +53,7 @@ type MessageResponse struct {
 	MediaURL         string         `json:"media_url,omitempty"`
 	MediaMimeType    string         `json:"media_mime_type,omitempty"`
 	MediaFilename    string         `json:"media_filename,omitempty"`
+	Transcript       string         `json:"transcript,omitempty"`
 	InteractiveData  models.JSONB   `json:"interactive_data,omitempty"`
 	Status           string         `json:"status"`
 	WAMID            string         `json:"wamid"`
@@ -87,6 +92,8 @@ func (a *App) ListContacts(r *fastglue.Request) error {
 	page, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("page")))
 	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
 	search := string(r.RequestCtx.QueryArgs().Peek("search"))
+	labelFilter := string(r.RequestCtx.QueryArgs().Peek("label"))
+	sortBy := string(r.RequestCtx.QueryArgs().Peek("sort"))
 
 	if page < 1 {
 		page = 1
@@ -109,8 +116,46 @@ func (a *App) ListContacts(r *fastglue.Request) error {
 		query = query.Where("phone_number LIKE ? OR profile_name LIKE ?", searchPattern, searchPattern)
 	}
 
-	// Order by last message time (most recent first)
-	query = query.Order("last_message_at DESC NULLS LAST, created_at DESC")
+	// Filter by a saved inbox view the user can see
+	if viewFilter := string(r.RequestCtx.QueryArgs().Peek("view")); viewFilter != "" {
+		viewID, err := uuid.Parse(viewFilter)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid view ID", nil, "")
+		}
+		view, err := a.visibleInboxView(orgID, userID, viewID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Inbox view not found", nil, "")
+		}
+		query = applyInboxViewFilters(query, view, userID)
+	}
+
+	// Filter by conversation label
+	if labelFilter != "" {
+		labelID, err := uuid.Parse(labelFilter)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid label ID", nil, "")
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM conversation_labels cl WHERE cl.contact_id = contacts.id AND cl.label_id = ? AND cl.deleted_at IS NULL)", labelID)
+	}
+
+	// Engagement segments: a minimum score, or the top N percent of the organization
+	if minScore := r.RequestCtx.QueryArgs().GetUintOrZero("min_engagement"); minScore > 0 {
+		query = query.Where("engagement_score >= ?", minScore)
+	}
+	if top := r.RequestCtx.QueryArgs().GetUintOrZero("engagement_top"); top > 0 {
+		threshold, err := engagement.TopThreshold(a.DB, orgID, top)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		query = query.Where("engagement_score >= ?", threshold)
+	}
+
+	if sortBy == "engagement" {
+		query = query.Order("engagement_score DESC, last_message_at DESC NULLS LAST")
+	} else {
+		// Order by last message time (most recent first)
+		query = query.Order("last_message_at DESC NULLS LAST, created_at DESC")
+	}
 
 	var total int64
 	query.Model(&models.Contact{}).Count(&total)
@@ -123,6 +168,12 @@ func (a *App) ListContacts(r *fastglue.Request) error {
 	// Check if phone masking is enabled
 	shouldMask := a.ShouldMaskPhoneNumbers(orgID)
 
+	contactIDs := make([]uuid.UUID, len(contacts))
+	for i, c := range contacts {
+		contactIDs[i] = c.ID
+	}
+	labels := a.conversationLabels(contactIDs)
+
 	// Convert to response format
 	response := make([]ContactResponse, len(contacts))
 	for i, c := range contacts {
@@ -155,7 +206,10 @@ func (a *App) ListContacts(r *fastglue.Request) error {
 			ProfileName:        profileName,
 			Status:             "active",
 			Tags:               tags,
+			Labels:             labels[c.ID],
+			EngagementScore:    c.EngagementScore,
 			CustomFields:       c.Metadata,
+			Timezone:           c.Timezone,
 			LastMessageAt:      c.LastMessageAt,
 			LastMessagePreview: c.LastMessagePreview,
 			UnreadCount:        int(unreadCount),
@@ -228,7 +282,10 @@ func (a *App) GetContact(r *fastglue.Request) error {
 		ProfileName:        profileName,
 		Status:             "active",
 		Tags:               tags,
+		Labels:             a.conversationLabels([]uuid.UUID{contact.ID})[contact.ID],
+		EngagementScore:    contact.EngagementScore,
 		CustomFields:       contact.Metadata,
+		Timezone:           contact.Timezone,
 		LastMessageAt:      contact.LastMessageAt,
 		LastMessagePreview: contact.LastMessagePreview,
 		UnreadCount:        int(unreadCount),
@@ -240,6 +297,135 @@ func (a *App) GetContact(r *fastglue.Request) error {
 	return r.SendEnvelope(response)
 }
 
+// UpdateContactRequest is the request body for updating a contact. Tags replace the
+// contact's tags; metadata keys are merged into its custom fields, and a null value
+// removes a key.
+type UpdateContactRequest struct {
+	Name     *string                `json:"name"`
+	Tags     *[]string              `json:"tags"`
+	Metadata map[string]interface{} `json:"metadata"`
+	Timezone *string                `json:"timezone"` // IANA name such as Asia/Kolkata; empty uses the organization's
+}
+
+// UpdateContact updates a contact's name, tags, custom fields and timezone, running
+// the automation triggers for the tags added and custom fields changed
+// Agents can only update contacts assigned to them
+func (a *App) UpdateContact(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	userRole, _ := r.RequestCtx.UserValue("role").(string)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var req UpdateContactRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	if userRole == "agent" {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	updates := map[string]interface{}{}
+	var events []AutomationEvent
+
+	if req.Name != nil {
+		contact.ProfileName = strings.TrimSpace(*req.Name)
+		updates["profile_name"] = contact.ProfileName
+	}
+
+	if req.Tags != nil {
+		existing := make(map[string]bool, len(contact.Tags))
+		for _, t := range contact.Tags {
+			if s, ok := t.(string); ok {
+				existing[s] = true
+			}
+		}
+		tags := models.JSONBArray{}
+		seen := make(map[string]bool, len(*req.Tags))
+		for _, tag := range *req.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+			if !existing[tag] {
+				events = append(events, AutomationEvent{Type: models.AutomationEventTagAdded, Tag: tag})
+			}
+		}
+		contact.Tags = tags
+		updates["tags"] = tags
+	}
+
+	if len(req.Metadata) > 0 {
+		metadata := models.JSONB{}
+		for k, v := range contact.Metadata {
+			metadata[k] = v
+		}
+		for key, value := range req.Metadata {
+			old, existed := metadata[key]
+			if value == nil {
+				if existed {
+					delete(metadata, key)
+					events = append(events, AutomationEvent{Type: models.AutomationEventAttributeChanged, Attribute: key})
+				}
+				continue
+			}
+			metadata[key] = value
+			if !existed || fmt.Sprint(old) != fmt.Sprint(value) {
+				events = append(events, AutomationEvent{Type: models.AutomationEventAttributeChanged, Attribute: key, Value: fmt.Sprint(value)})
+			}
+		}
+		contact.Metadata = metadata
+		updates["metadata"] = metadata
+	}
+
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid timezone", nil, "")
+			}
+		}
+		contact.Timezone = timezone
+		updates["timezone"] = timezone
+	}
+
+	if len(updates) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Nothing to update", nil, "")
+	}
+	if err := a.DB.Model(&contact).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to update contact", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update contact", nil, "")
+	}
+
+	if len(events) > 0 {
+		go func() {
+			for _, event := range events {
+				a.fireAutomationTriggers(&contact, event)
+			}
+		}()
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"id":         contact.ID,
+		"name":       contact.ProfileName,
+		"tags":       contact.Tags,
+		"metadata":   contact.Metadata,
+		"timezone":   contact.Timezone,
+		"updated_at": contact.UpdatedAt,
+	})
+}
+
 // GetMessages returns messages for a contact
 // Agents can only access messages for their assigned contacts
 // Supports cursor-based pagination with before_id for loading older messages
@@ -346,6 +532,7 @@ func (a *App) GetMessages(r *fastglue.Request) error {
 
 	// Mark messages as read
 	a.markMessagesAsRead(orgID, contactID, &contact)
+	go a.recordConversationView(orgID, contactID, userID)
 
 	response := a.buildMessagesResponse(messages)
 	return r.SendEnvelope(map[string]any{
@@ -364,6 +551,9 @@ func (a *App) buildMessagesResponse(messages []models.Message) []MessageResponse
 		var content any
 		if m.MessageType == "text" {
 			content = map[string]string{"body": m.Content}
+		} else if m.MessageType == "sticker" {
+			animated, _ := m.Metadata["animated"].(bool)
+			content = map[string]any{"body": m.Content, "animated": animated}
 		} else {
 			content = map[string]string{"body": m.Content}
 		}
@@ -377,6 +567,7 @@ func (a *App) buildMessagesResponse(messages []models.Message) []MessageResponse
 			MediaURL:        m.MediaURL,
 			MediaMimeType:   m.MediaMimeType,
 			MediaFilename:   m.MediaFilename,
+			Transcript:      m.Transcript,
 			InteractiveData: m.InteractiveData,
 			Status:          m.Status,
 			WAMID:           m.WhatsAppMessageID,
@@ -435,27 +626,35 @@ func (a *App) markMessagesAsRead(orgID uuid.UUID, contactID uuid.UUID, contact *
 
 	a.DB.Model(contact).Update("is_read", true)
 
-	if len(unreadMessages) > 0 && contact.WhatsAppAccount != "" {
-		var account models.WhatsAppAccount
-		if err := a.DB.Where("organization_id = ? AND name = ?", orgID, contact.WhatsAppAccount).First(&account).Error; err == nil {
-			if account.AutoReadReceipt {
-				go func() {
-					waAccount := &whatsapp.Account{
-						PhoneID:     account.PhoneID,
-						AccessToken: account.AccessToken,
-						APIVersion:  a.Config.WhatsApp.APIVersion,
-					}
-					for _, msg := range unreadMessages {
-						if msg.WhatsAppMessageID != "" {
-							if err := a.WhatsApp.MarkMessageRead(context.Background(), waAccount, msg.WhatsAppMessageID); err != nil {
-								a.Log.Error("Failed to send read receipt", "error", err, "message_id", msg.WhatsAppMessageID)
-							}
-						}
-					}
-				}()
+	a.sendReadReceipts(orgID, contact.WhatsAppAccount, unreadMessages)
+}
+
+// sendReadReceipts sends read receipts for incoming messages when the account has
+// automatic read receipts enabled
+func (a *App) sendReadReceipts(orgID uuid.UUID, accountName string, messages []models.Message) {
+	if len(messages) == 0 || accountName == "" {
+		return
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("organization_id = ? AND name = ?", orgID, accountName).First(&account).Error; err != nil || !account.AutoReadReceipt {
+		return
+	}
+
+	go func() {
+		waAccount := &whatsapp.Account{
+			PhoneID:     account.PhoneID,
+			AccessToken: account.AccessToken,
+			APIVersion:  account.APIVersion,
+		}
+		for _, msg := range messages {
+			if msg.WhatsAppMessageID != "" {
+				if err := a.WhatsApp.MarkMessageRead(context.Background(), waAccount, msg.WhatsAppMessageID); err != nil {
+					a.Log.Error("Failed to send read receipt", "error", err, "message_id", msg.WhatsAppMessageID)
+				}
 			}
 		}
-	}
+	}()
 }
 
 // SendMessageRequest represents a send message request
@@ -465,6 +664,7 @@ type SendMessageRequest struct {
 		Body string `json:"body"`
 	} `json:"content"`
 	ReplyToMessageID string `json:"reply_to_message_id,omitempty"`
+	PreviewURL       bool   `json:"preview_url,omitempty"` // Have WhatsApp show a preview of the first link
 }
 
 // SendMessage sends a message to a contact
@@ -512,6 +712,11 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 		}
 	}
 
+	content := req.Content.Body
+	if req.Type == "text" {
+		content = a.applyMessageFooter(orgID, FooterCategoryAgent, content)
+	}
+
 	// Create message record
 	message := models.Message{
 		BaseModel:       models.BaseModel{ID: uuid.New()},
@@ -520,10 +725,13 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 		ContactID:       contactID,
 		Direction:       "outgoing",
 		MessageType:     req.Type,
-		Content:         req.Content.Body,
+		Content:         content,
 		Status:          "pending",
 		SentByUserID:    &userID,
 	}
+	if req.Type == "text" && req.PreviewURL {
+		message.Metadata = models.JSONB{"preview_url": true}
+	}
 
 	// Handle reply context
 	var replyToMessage *models.Message
@@ -543,9 +751,13 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 		a.Log.Error("Failed to create message", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create message", nil, "")
 	}
+	go a.recordConversationParticipation(orgID, contactID, userID)
 
 	// Send via WhatsApp API
 	go a.sendWhatsAppMessage(&account, &contact, &message)
+	if message.Metadata["preview_url"] == true {
+		go a.attachLinkPreview(message.ID, message.Content)
+	}
 
 	// Update contact's last message
 	now := time.Now()
@@ -611,7 +823,7 @@ func (a *App) SendMessage(r *fastglue.Request) error {
 
 // sendWhatsAppMessage sends a message via the WhatsApp Cloud API
 func (a *App) sendWhatsAppMessage(account *models.WhatsAppAccount, contact *models.Contact, message *models.Message) {
-	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", account.APIVersion, account.PhoneID)
+	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", whatsapp.ResolveAPIVersion(account.APIVersion), account.PhoneID)
 
 	payload := map[string]any{
 		"messaging_product": "whatsapp",
@@ -622,7 +834,7 @@ func (a *App) sendWhatsAppMessage(account *models.WhatsAppAccount, contact *mode
 
 	if message.MessageType == "text" {
 		payload["text"] = map[string]any{
-			"preview_url": false,
+			"preview_url": message.Metadata["preview_url"] == true,
 			"body":        message.Content,
 		}
 	}
@@ -647,7 +859,8 @@ func (a *App) sendWhatsAppMessage(account *models.WhatsAppAccount, contact *mode
 		return
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	ctx := whatsapp.WithAccount(context.Background(), &whatsapp.Account{PhoneID: account.PhoneID, BusinessID: account.BusinessID})
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		a.Log.Error("Failed to create request", "error", err)
 		a.DB.Model(message).Updates(map[string]any{
@@ -660,8 +873,7 @@ func (a *App) sendWhatsAppMessage(account *models.WhatsAppAccount, contact *mode
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := a.WhatsApp.HTTPClient.Do(req)
 	if err != nil {
 		a.Log.Error("Failed to send message", "error", err)
 		a.DB.Model(message).Updates(map[string]any{
@@ -734,7 +946,7 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
-// SendMediaMessage sends a media message (image, document, video, audio) to a contact
+// SendMediaMessage sends a media message (image, document, video, audio, sticker) to a contact
 func (a *App) SendMediaMessage(r *fastglue.Request) error {
 	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
 	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
@@ -756,7 +968,7 @@ func (a *App) SendMediaMessage(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
 	}
 
-	// Get media type (image, document, video, audio)
+	// Get media type (image, document, video, audio, sticker)
 	mediaType := "image"
 	if typeValues := form.Value["type"]; len(typeValues) > 0 {
 		mediaType = typeValues[0]
@@ -794,6 +1006,17 @@ func (a *App) SendMediaMessage(r *fastglue.Request) error {
 		mimeType = "application/octet-stream"
 	}
 
+	// Stickers must be WebP within WhatsApp's size limits and carry no caption
+	var metadata models.JSONB
+	if mediaType == "sticker" {
+		animated, err := validateSticker(fileData, mimeType)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		caption = ""
+		metadata = models.JSONB{"animated": animated}
+	}
+
 	// Get contact (agents can only message their assigned contacts)
 	var contact models.Contact
 	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
@@ -848,12 +1071,14 @@ func (a *App) SendMediaMessage(r *fastglue.Request) error {
 		MediaFilename:   fileHeader.Filename,
 		Status:          "pending",
 		SentByUserID:    &userID,
+		Metadata:        metadata,
 	}
 
 	if err := a.DB.Create(&message).Error; err != nil {
 		a.Log.Error("Failed to create message", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create message", nil, "")
 	}
+	go a.recordConversationParticipation(orgID, contactID, userID)
 
 	// Upload to WhatsApp and send asynchronously
 	go a.uploadAndSendMediaMessage(waAccount, &account, &contact, &message, fileData, mimeType, fileHeader.Filename, caption)
@@ -1099,7 +1324,7 @@ func (a *App) sendWhatsAppReaction(account *models.WhatsAppAccount, contact *mod
 		return
 	}
 
-	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", account.APIVersion, account.PhoneID)
+	url := fmt.Sprintf("https://graph.facebook.com/%s/%s/messages", whatsapp.ResolveAPIVersion(account.APIVersion), account.PhoneID)
 
 	payload := map[string]any{
 		"messaging_product": "whatsapp",
@@ -1118,7 +1343,8 @@ func (a *App) sendWhatsAppReaction(account *models.WhatsAppAccount, contact *mod
 		return
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
+	ctx := whatsapp.WithAccount(context.Background(), &whatsapp.Account{PhoneID: account.PhoneID, BusinessID: account.BusinessID})
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		a.Log.Error("Failed to create reaction request", "error", err)
 		return
@@ -1127,8 +1353,7 @@ func (a *App) sendWhatsAppReaction(account *models.WhatsAppAccount, contact *mod
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := a.WhatsApp.HTTPClient.Do(req)
 	if err != nil {
 		a.Log.Error("Failed to send reaction", "error", err)
 		return
@@ -1170,6 +1395,8 @@ func (a *App) uploadAndSendMediaMessage(waAccount *whatsapp.Account, account *mo
 		wamID, err = a.WhatsApp.SendVideoMessage(ctx, waAccount, contact.PhoneNumber, mediaID, caption)
 	case "audio":
 		wamID, err = a.WhatsApp.SendAudioMessage(ctx, waAccount, contact.PhoneNumber, mediaID)
+	case "sticker":
+		wamID, err = a.WhatsApp.SendStickerMessage(ctx, waAccount, contact.PhoneNumber, mediaID)
 	default:
 		err = fmt.Errorf("unsupported media type: %s", message.MessageType)
 	}
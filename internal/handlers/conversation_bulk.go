@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bulk conversation actions
+const (
+	BulkActionResolve  = "resolve"   // Hand active transfers back to the chatbot and mark read
+	BulkActionAssign   = "assign"    // Assign to user_id, or unassign when it is null
+	BulkActionLabel    = "label"     // Apply label_id
+	BulkActionUnlabel  = "unlabel"   // Remove label_id
+	BulkActionMarkRead = "mark_read" // Mark incoming messages read
+)
+
+// maxBulkConversations caps how many conversations one bulk action may touch
+const maxBulkConversations = 100
+
+// BulkConversationRequest is the request body for a bulk conversation action
+type BulkConversationRequest struct {
+	ContactIDs []string   `json:"contact_ids"`
+	Action     string     `json:"action"`
+	UserID     *uuid.UUID `json:"user_id"`  // assign
+	LabelID    string     `json:"label_id"` // label, unlabel
+}
+
+// BulkConversationResult is the outcome of a bulk action for one conversation
+type BulkConversationResult struct {
+	ContactID string `json:"contact_id"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BulkConversationAction applies one action to several conversations at once. Contacts
+// that can't be found (or, for agents, aren't assigned to them) are reported as failed;
+// the rest are updated in a single transaction, so they either all change or none do.
+func (a *App) BulkConversationAction(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	var req BulkConversationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.ContactIDs) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_ids is required", nil, "")
+	}
+	if len(req.ContactIDs) > maxBulkConversations {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Too many conversations in one request", nil, "")
+	}
+
+	var label models.Label
+	switch req.Action {
+	case BulkActionResolve, BulkActionMarkRead:
+	case BulkActionAssign:
+		if role == "agent" {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only admin and manager can assign contacts", nil, "")
+		}
+		if req.UserID != nil {
+			var user models.User
+			if err := a.DB.Where("id = ? AND organization_id = ?", req.UserID, orgID).First(&user).Error; err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "User not found", nil, "")
+			}
+		}
+	case BulkActionLabel, BulkActionUnlabel:
+		labelID, err := uuid.Parse(req.LabelID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid label ID", nil, "")
+		}
+		if err := a.DB.Where("id = ? AND organization_id = ?", labelID, orgID).First(&label).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Label not found", nil, "")
+		}
+	default:
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid action", nil, "")
+	}
+
+	// Resolve the requested contacts, keeping the caller's order for the results
+	results := make([]BulkConversationResult, 0, len(req.ContactIDs))
+	seen := make(map[uuid.UUID]bool, len(req.ContactIDs))
+	ids := make([]uuid.UUID, 0, len(req.ContactIDs))
+	for _, idStr := range req.ContactIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			results = append(results, BulkConversationResult{ContactID: idStr, Error: "Invalid contact ID"})
+			continue
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		results = append(results, BulkConversationResult{ContactID: id.String()})
+	}
+
+	var contacts []models.Contact
+	if len(ids) > 0 {
+		query := a.DB.Where("id IN ? AND organization_id = ?", ids, orgID)
+		if role == "agent" {
+			query = query.Where("assigned_user_id = ?", userID)
+		}
+		if err := query.Find(&contacts).Error; err != nil {
+			a.Log.Error("Failed to load contacts for bulk action", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load contacts", nil, "")
+		}
+	}
+
+	found := make(map[string]*models.Contact, len(contacts))
+	contactIDs := make([]uuid.UUID, len(contacts))
+	for i := range contacts {
+		found[contacts[i].ID.String()] = &contacts[i]
+		contactIDs[i] = contacts[i].ID
+	}
+
+	var (
+		transfers      []models.AgentTransfer
+		unreadMessages []models.Message
+	)
+	if len(contactIDs) > 0 {
+		now := time.Now()
+		err = a.DB.Transaction(func(tx *gorm.DB) error {
+			switch req.Action {
+			case BulkActionResolve:
+				if err := tx.Where("organization_id = ? AND contact_id IN ? AND status = ?", orgID, contactIDs, "active").
+					Find(&transfers).Error; err != nil {
+					return err
+				}
+				if len(transfers) > 0 {
+					transferIDs := make([]uuid.UUID, len(transfers))
+					for i := range transfers {
+						transferIDs[i] = transfers[i].ID
+						transfers[i].Status = "resumed"
+						transfers[i].ResumedAt = &now
+						transfers[i].ResumedBy = &userID
+					}
+					if err := tx.Model(&models.AgentTransfer{}).Where("id IN ?", transferIDs).Updates(map[string]any{
+						"status":     "resumed",
+						"resumed_at": now,
+						"resumed_by": userID,
+					}).Error; err != nil {
+						return err
+					}
+				}
+
+				// Unassign like a single resume does when AssignToSameAgent is off
+				var unassign []uuid.UUID
+				for _, transfer := range transfers {
+					settings, _ := a.getChatbotSettingsCached(orgID, transfer.WhatsAppAccount)
+					if settings != nil && !settings.AssignToSameAgent {
+						unassign = append(unassign, transfer.ContactID)
+					}
+				}
+				if len(unassign) > 0 {
+					if err := tx.Model(&models.Contact{}).Where("id IN ?", unassign).Update("assigned_user_id", nil).Error; err != nil {
+						return err
+					}
+				}
+				return tx.Model(&models.Contact{}).Where("id IN ?", contactIDs).Update("is_read", true).Error
+
+			case BulkActionMarkRead:
+				if err := tx.Where("contact_id IN ? AND direction = ? AND status != ?", contactIDs, "incoming", "read").
+					Find(&unreadMessages).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.Message{}).Where("contact_id IN ? AND direction = ?", contactIDs, "incoming").
+					Update("status", "read").Error; err != nil {
+					return err
+				}
+				return tx.Model(&models.Contact{}).Where("id IN ?", contactIDs).Update("is_read", true).Error
+
+			case BulkActionAssign:
+				return tx.Model(&models.Contact{}).Where("id IN ?", contactIDs).Update("assigned_user_id", req.UserID).Error
+
+			case BulkActionLabel:
+				rows := make([]models.ConversationLabel, len(contactIDs))
+				for i, id := range contactIDs {
+					rows[i] = models.ConversationLabel{
+						OrganizationID: orgID,
+						ContactID:      id,
+						LabelID:        label.ID,
+						Source:         "manual",
+						AppliedByID:    &userID,
+					}
+				}
+				return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+
+			case BulkActionUnlabel:
+				return tx.Where("contact_id IN ? AND label_id = ?", contactIDs, label.ID).Delete(&models.ConversationLabel{}).Error
+			}
+			return nil
+		})
+		if err != nil {
+			a.Log.Error("Failed to apply bulk conversation action", "error", err, "action", req.Action)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update conversations", nil, "")
+		}
+	}
+
+	succeeded, failed := 0, 0
+	for i := range results {
+		switch {
+		case results[i].Error != "":
+		case found[results[i].ContactID] == nil:
+			results[i].Error = "Contact not found"
+		default:
+			results[i].Success = true
+		}
+		if results[i].Success {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+
+	a.afterBulkConversationAction(orgID, userID, &req, contacts, transfers, unreadMessages)
+
+	a.Log.Info("Bulk conversation action applied", "action", req.Action, "succeeded", succeeded, "failed", failed)
+
+	return r.SendEnvelope(map[string]any{
+		"action":    req.Action,
+		"succeeded": succeeded,
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// afterBulkConversationAction sends the broadcasts, webhooks, notifications and read
+// receipts the single-conversation endpoints send, once the bulk change is committed
+func (a *App) afterBulkConversationAction(orgID, userID uuid.UUID, req *BulkConversationRequest, contacts []models.Contact, transfers []models.AgentTransfer, unreadMessages []models.Message) {
+	byID := make(map[uuid.UUID]*models.Contact, len(contacts))
+	for i := range contacts {
+		byID[contacts[i].ID] = &contacts[i]
+	}
+
+	switch req.Action {
+	case BulkActionResolve:
+		for i := range transfers {
+			transfer := &transfers[i]
+			a.broadcastTransferResumed(transfer)
+
+			data := TransferEventData{
+				TransferID:      transfer.ID.String(),
+				ContactID:       transfer.ContactID.String(),
+				Source:          transfer.Source,
+				WhatsAppAccount: transfer.WhatsAppAccount,
+			}
+			if contact := byID[transfer.ContactID]; contact != nil {
+				data.ContactPhone = contact.PhoneNumber
+				data.ContactName = contact.ProfileName
+			}
+			a.DispatchWebhook(orgID, EventTransferResumed, data)
+		}
+
+	case BulkActionMarkRead:
+		byAccount := make(map[string][]models.Message)
+		for _, msg := range unreadMessages {
+			if contact := byID[msg.ContactID]; contact != nil {
+				byAccount[contact.WhatsAppAccount] = append(byAccount[contact.WhatsAppAccount], msg)
+			}
+		}
+		for accountName, messages := range byAccount {
+			a.sendReadReceipts(orgID, accountName, messages)
+		}
+
+	case BulkActionAssign:
+		for _, contact := range contacts {
+			go a.notifyConversationFollowers(orgID, contact.ID, userID, "assigned", map[string]any{
+				"assigned_user_id": req.UserID,
+			})
+		}
+
+	case BulkActionLabel, BulkActionUnlabel:
+		for _, contact := range contacts {
+			a.broadcastConversationLabels(orgID, contact.ID)
+		}
+	}
+}
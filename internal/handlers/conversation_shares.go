@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	// defaultShareMessageLimit is how many recent messages a snapshot includes by default
+	defaultShareMessageLimit = 50
+	// maxShareMessageLimit caps the messages a snapshot can include
+	maxShareMessageLimit = 200
+)
+
+// ShareConversationRequest is the request body for sharing a conversation with
+// another organization
+type ShareConversationRequest struct {
+	TargetOrganizationID string `json:"target_organization_id"`
+	Note                 string `json:"note"`
+	MessageLimit         int    `json:"message_limit"`    // Recent messages to include, default 50
+	CustomerConsent      bool   `json:"customer_consent"` // Required: the customer agreed to the handover
+}
+
+// ConversationShareResponse is a conversation share with its organizations' names
+type ConversationShareResponse struct {
+	models.ConversationShare
+	SourceOrganizationName string `json:"source_organization_name"`
+	TargetOrganizationName string `json:"target_organization_name"`
+}
+
+// sharePartnersFromSettings reads the organizations allowed to share conversations
+// into an organization
+func sharePartnersFromSettings(settings models.JSONB) []string {
+	partners := []string{}
+	values, _ := settings["conversation_share_partners"].([]any)
+	for _, v := range values {
+		if id, ok := v.(string); ok {
+			partners = append(partners, id)
+		}
+	}
+	return partners
+}
+
+// validateSharePartners checks partner IDs name other existing organizations and
+// returns them deduplicated
+func (a *App) validateSharePartners(orgID uuid.UUID, ids []string) ([]string, error) {
+	partners := make([]string, 0, len(ids))
+	seen := map[uuid.UUID]bool{}
+	for _, raw := range ids {
+		id, err := uuid.Parse(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid partner organization ID: %s", raw)
+		}
+		if id == orgID {
+			return nil, errors.New("an organization can't be its own partner")
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		partners = append(partners, id.String())
+	}
+	if len(partners) > 0 {
+		var count int64
+		if err := a.DB.Model(&models.Organization{}).Where("id IN ?", partners).Count(&count).Error; err != nil {
+			return nil, errors.New("failed to check partner organizations")
+		}
+		if int(count) != len(partners) {
+			return nil, errors.New("partner organization not found")
+		}
+	}
+	return partners, nil
+}
+
+// sharePartnersContain returns a condition matching organizations that list orgID
+// as a conversation share partner
+func sharePartnersContain(orgID uuid.UUID) (string, string) {
+	return "settings->'conversation_share_partners' @> ?::jsonb", fmt.Sprintf(`[%q]`, orgID.String())
+}
+
+// ListConversationShareTargets returns the organizations that accept conversations
+// shared by the caller's organization
+func (a *App) ListConversationShareTargets(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	cond, arg := sharePartnersContain(orgID)
+	var orgs []models.Organization
+	if err := a.DB.Select("id", "name").Where(cond, arg).Where("id <> ?", orgID).Order("name").Find(&orgs).Error; err != nil {
+		a.Log.Error("Failed to list conversation share targets", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list organizations", nil, "")
+	}
+
+	targets := make([]map[string]any, len(orgs))
+	for i, org := range orgs {
+		targets[i] = map[string]any{"id": org.ID, "name": org.Name}
+	}
+	return r.SendEnvelope(map[string]any{"organizations": targets})
+}
+
+// ShareConversation sends a snapshot of a contact's conversation to a partner
+// organization. Agents can only share their assigned contacts.
+func (a *App) ShareConversation(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userRole, _ := r.RequestCtx.UserValue("role").(string)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var req ShareConversationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if !req.CustomerConsent {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "The customer's consent is required to share a conversation", nil, "")
+	}
+	targetID, err := uuid.Parse(req.TargetOrganizationID)
+	if err != nil || targetID == orgID {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid target organization", nil, "")
+	}
+	if req.MessageLimit <= 0 {
+		req.MessageLimit = defaultShareMessageLimit
+	}
+	if req.MessageLimit > maxShareMessageLimit {
+		req.MessageLimit = maxShareMessageLimit
+	}
+
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	if userRole == "agent" {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	// The receiving organization must have agreed to receive shares from this one
+	cond, arg := sharePartnersContain(orgID)
+	var target models.Organization
+	if err := a.DB.Select("id", "name").Where("id = ?", targetID).Where(cond, arg).First(&target).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "This organization doesn't accept shared conversations from yours", nil, "")
+	}
+
+	var pending int64
+	a.DB.Model(&models.ConversationShare{}).
+		Where("source_contact_id = ? AND target_organization_id = ? AND status = ?", contact.ID, targetID, models.ConversationSharePending).
+		Count(&pending)
+	if pending > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "This conversation is already waiting to be accepted", nil, "")
+	}
+
+	snapshot, err := a.conversationSnapshot(orgID, &contact, req.MessageLimit)
+	if err != nil {
+		a.Log.Error("Failed to build conversation snapshot", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to share conversation", nil, "")
+	}
+
+	share := models.ConversationShare{
+		SourceOrganizationID: orgID,
+		SourceContactID:      contact.ID,
+		TargetOrganizationID: targetID,
+		Status:               models.ConversationSharePending,
+		Note:                 strings.TrimSpace(req.Note),
+		CustomerConsent:      true,
+		Snapshot:             snapshot,
+		SharedByID:           userID,
+	}
+	if err := a.DB.Create(&share).Error; err != nil {
+		a.Log.Error("Failed to create conversation share", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to share conversation", nil, "")
+	}
+
+	a.Log.Info("Conversation shared",
+		"share_id", share.ID,
+		"organization_id", orgID,
+		"target_organization_id", targetID,
+		"contact_id", contact.ID,
+		"shared_by", userID,
+	)
+	a.broadcastConversationShare(&share)
+
+	return r.SendEnvelope(share)
+}
+
+// conversationSnapshot captures the contact and its recent messages, oldest first.
+// Message text has the organization's redaction rules applied since it leaves the
+// organization.
+func (a *App) conversationSnapshot(orgID uuid.UUID, contact *models.Contact, limit int) (models.JSONB, error) {
+	var org models.Organization
+	if err := a.DB.Select("id", "name").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	if err := a.DB.Preload("SentByUser").Where("contact_id = ? AND organization_id = ?", contact.ID, orgID).
+		Order("created_at DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]map[string]any, 0, len(messages))
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		entry := map[string]any{
+			"direction":    msg.Direction,
+			"message_type": msg.MessageType,
+			"content":      a.redactMessage(orgID, msg.Content),
+			"created_at":   msg.CreatedAt,
+		}
+		if msg.SentByUser != nil {
+			entry["sent_by"] = msg.SentByUser.FullName
+		}
+		entries = append(entries, entry)
+	}
+
+	return models.JSONB{
+		"organization": org.Name,
+		"contact": map[string]any{
+			"phone_number":     contact.PhoneNumber,
+			"profile_name":     contact.ProfileName,
+			"whatsapp_account": contact.WhatsAppAccount,
+			"tags":             contact.Tags,
+		},
+		"messages": entries,
+	}, nil
+}
+
+// ListConversationShares returns the organization's shares, newest first.
+// Query params: direction (incoming or outgoing, default incoming), status
+func (a *App) ListConversationShares(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	column := "target_organization_id"
+	if string(r.RequestCtx.QueryArgs().Peek("direction")) == "outgoing" {
+		column = "source_organization_id"
+	}
+	query := a.DB.Omit("snapshot").Where(column+" = ?", orgID).Order("created_at DESC")
+	if status := string(r.RequestCtx.QueryArgs().Peek("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var shares []models.ConversationShare
+	if err := query.Find(&shares).Error; err != nil {
+		a.Log.Error("Failed to list conversation shares", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list conversation shares", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{"shares": a.conversationShareResponses(shares)})
+}
+
+// GetConversationShare returns a share with its snapshot to either organization
+func (a *App) GetConversationShare(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	share, err := a.findConversationShare(r, "(source_organization_id = ? OR target_organization_id = ?)", orgID, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Conversation share not found", nil, "")
+	}
+
+	return r.SendEnvelope(a.conversationShareResponses([]models.ConversationShare{*share})[0])
+}
+
+// AcceptConversationShare accepts a share into the receiving organization, creating
+// the contact there so the team can take over the conversation
+func (a *App) AcceptConversationShare(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	share, err := a.findConversationShare(r, "target_organization_id = ?", orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Conversation share not found", nil, "")
+	}
+	if share.Status != models.ConversationSharePending {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Only pending shares can be accepted", nil, "")
+	}
+
+	snapshotContact, _ := share.Snapshot["contact"].(map[string]any)
+	phone, _ := snapshotContact["phone_number"].(string)
+	name, _ := snapshotContact["profile_name"].(string)
+	if phone == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Shared conversation has no phone number", nil, "")
+	}
+	contact, _ := a.getOrCreateContact(orgID, phone, name)
+	if contact == nil || contact.ID == uuid.Nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create contact", nil, "")
+	}
+
+	now := time.Now()
+	result := a.DB.Model(&models.ConversationShare{}).
+		Where("id = ? AND status = ?", share.ID, models.ConversationSharePending).
+		Updates(map[string]any{
+			"status":            models.ConversationShareAccepted,
+			"target_contact_id": contact.ID,
+			"responded_by_id":   userID,
+			"responded_at":      now,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to accept conversation share", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to accept conversation share", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Conversation share was changed meanwhile", nil, "")
+	}
+	share.Status = models.ConversationShareAccepted
+	share.TargetContactID = &contact.ID
+	share.RespondedByID = &userID
+	share.RespondedAt = &now
+
+	a.Log.Info("Conversation share accepted", "share_id", share.ID, "organization_id", orgID, "contact_id", contact.ID, "accepted_by", userID)
+	a.broadcastConversationShare(share)
+
+	return r.SendEnvelope(share)
+}
+
+// DeclineConversationShare declines a pending share
+func (a *App) DeclineConversationShare(r *fastglue.Request) error {
+	return a.closeConversationShare(r, "target_organization_id = ?", models.ConversationShareDeclined)
+}
+
+// RevokeConversationShare withdraws a share and erases its snapshot. A contact the
+// receiving organization already created is theirs to keep.
+func (a *App) RevokeConversationShare(r *fastglue.Request) error {
+	return a.closeConversationShare(r, "source_organization_id = ?", models.ConversationShareRevoked)
+}
+
+// closeConversationShare moves a share the caller's organization may close, matched
+// by orgCondition, to status
+func (a *App) closeConversationShare(r *fastglue.Request, orgCondition, status string) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	share, err := a.findConversationShare(r, orgCondition, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Conversation share not found", nil, "")
+	}
+
+	now := time.Now()
+	updates := map[string]any{"status": status}
+	allowed := []string{models.ConversationSharePending}
+	if status == models.ConversationShareRevoked {
+		allowed = append(allowed, models.ConversationShareAccepted)
+		updates["snapshot"] = models.JSONB{}
+		updates["revoked_by_id"] = userID
+		updates["revoked_at"] = now
+	} else {
+		updates["responded_by_id"] = userID
+		updates["responded_at"] = now
+	}
+
+	result := a.DB.Model(&models.ConversationShare{}).Where("id = ? AND status IN ?", share.ID, allowed).Updates(updates)
+	if result.Error != nil {
+		a.Log.Error("Failed to update conversation share", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update conversation share", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Conversation share can't be "+status, nil, "")
+	}
+	share.Status = status
+	if status == models.ConversationShareRevoked {
+		share.Snapshot = models.JSONB{}
+	}
+
+	a.Log.Info("Conversation share "+status, "share_id", share.ID, "organization_id", orgID, "by", userID)
+	a.broadcastConversationShare(share)
+
+	return r.SendEnvelope(share)
+}
+
+// findConversationShare loads the share named by the {id} path parameter, matching
+// orgCondition
+func (a *App) findConversationShare(r *fastglue.Request, orgCondition string, args ...any) (*models.ConversationShare, error) {
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	var share models.ConversationShare
+	if err := a.DB.Where("id = ?", id).Where(orgCondition, args...).First(&share).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// conversationShareResponses adds organization names to shares
+func (a *App) conversationShareResponses(shares []models.ConversationShare) []ConversationShareResponse {
+	ids := make([]uuid.UUID, 0, len(shares)*2)
+	for _, s := range shares {
+		ids = append(ids, s.SourceOrganizationID, s.TargetOrganizationID)
+	}
+	names := map[uuid.UUID]string{}
+	if len(ids) > 0 {
+		var orgs []models.Organization
+		a.DB.Select("id", "name").Where("id IN ?", ids).Find(&orgs)
+		for _, org := range orgs {
+			names[org.ID] = org.Name
+		}
+	}
+
+	responses := make([]ConversationShareResponse, len(shares))
+	for i, s := range shares {
+		responses[i] = ConversationShareResponse{
+			ConversationShare:      s,
+			SourceOrganizationName: names[s.SourceOrganizationID],
+			TargetOrganizationName: names[s.TargetOrganizationID],
+		}
+	}
+	return responses
+}
+
+// broadcastConversationShare tells both organizations a share was created or changed
+func (a *App) broadcastConversationShare(share *models.ConversationShare) {
+	if a.WSHub == nil {
+		return
+	}
+	payload := map[string]any{
+		"id":                     share.ID,
+		"status":                 share.Status,
+		"source_organization_id": share.SourceOrganizationID,
+		"target_organization_id": share.TargetOrganizationID,
+	}
+	for _, orgID := range []uuid.UUID{share.SourceOrganizationID, share.TargetOrganizationID} {
+		a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
+			Type:    websocket.TypeConversationShare,
+			Payload: payload,
+		})
+	}
+}
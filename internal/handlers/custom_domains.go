@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// hostnamePattern matches a fully qualified hostname with at least two labels
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// customDomainLookupTimeout bounds the DNS lookup when verifying a domain
+const customDomainLookupTimeout = 5 * time.Second
+
+// CustomDomainRequest is the request body for setting the organization's custom domain
+type CustomDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+// GetCustomDomain returns the organization's custom domain, the CNAME target it must
+// point to and the base URL generated links currently use
+func (a *App) GetCustomDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var domain *models.CustomDomain
+	var d models.CustomDomain
+	if err := a.DB.Where("organization_id = ?", orgID).First(&d).Error; err == nil {
+		domain = &d
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"domain":       domain,
+		"cname_target": a.Config.Server.CustomDomainTarget,
+		"base_url":     a.publicBaseURL(orgID),
+	})
+}
+
+// SetCustomDomain sets the organization's custom domain. Changing the domain resets
+// its verification.
+func (a *App) SetCustomDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if a.Config.Server.CustomDomainTarget == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Custom domains are not enabled on this server", nil, "")
+	}
+
+	var req CustomDomainRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	host := normalizeHostname(req.Domain)
+	if !hostnamePattern.MatchString(host) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid domain", nil, "")
+	}
+	if host == normalizeHostname(a.Config.Server.CustomDomainTarget) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Domain must differ from the CNAME target", nil, "")
+	}
+
+	var count int64
+	a.DB.Model(&models.CustomDomain{}).Where("domain = ? AND organization_id <> ?", host, orgID).Count(&count)
+	if count > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Domain is already in use", nil, "")
+	}
+
+	var domain models.CustomDomain
+	err = a.DB.Where("organization_id = ?", orgID).First(&domain).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		a.Log.Error("Failed to load custom domain", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save custom domain", nil, "")
+	}
+	if err == nil && domain.Domain == host {
+		return r.SendEnvelope(domain)
+	}
+
+	domain.OrganizationID = orgID
+	domain.Domain = host
+	domain.IsVerified = false
+	domain.VerifiedAt = nil
+	domain.LastCheckedAt = nil
+	domain.LastError = ""
+	if err := a.DB.Save(&domain).Error; err != nil {
+		a.Log.Error("Failed to save custom domain", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save custom domain", nil, "")
+	}
+
+	return r.SendEnvelope(domain)
+}
+
+// VerifyCustomDomain checks that the organization's domain has a CNAME record
+// pointing at the server's custom domain target
+func (a *App) VerifyCustomDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	target := normalizeHostname(a.Config.Server.CustomDomainTarget)
+	if target == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Custom domains are not enabled on this server", nil, "")
+	}
+
+	var domain models.CustomDomain
+	if err := a.DB.Where("organization_id = ?", orgID).First(&domain).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Custom domain not found", nil, "")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), customDomainLookupTimeout)
+	defer cancel()
+	now := time.Now()
+	updates := map[string]interface{}{
+		"last_checked_at": now,
+		"is_verified":     false,
+		"verified_at":     nil,
+	}
+
+	cname, err := net.DefaultResolver.LookupCNAME(ctx, domain.Domain)
+	switch {
+	case err != nil:
+		updates["last_error"] = fmt.Sprintf("DNS lookup failed: %v", err)
+	case normalizeHostname(cname) != target:
+		updates["last_error"] = fmt.Sprintf("CNAME points to %s, expected %s", normalizeHostname(cname), target)
+	default:
+		updates["last_error"] = ""
+		updates["is_verified"] = true
+		updates["verified_at"] = now
+	}
+
+	if err := a.DB.Model(&domain).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to update custom domain verification", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to verify custom domain", nil, "")
+	}
+	a.DB.First(&domain, "id = ?", domain.ID)
+
+	return r.SendEnvelope(domain)
+}
+
+// DeleteCustomDomain removes the organization's custom domain; generated links fall
+// back to the platform URL
+func (a *App) DeleteCustomDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	result := a.DB.Where("organization_id = ?", orgID).Delete(&models.CustomDomain{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete custom domain", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete custom domain", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Custom domain not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Custom domain deleted successfully"})
+}
+
+// CheckCustomDomain answers whether a hostname is a verified custom domain. It is
+// public so a reverse proxy can ask before issuing an on-demand TLS certificate.
+// Query params: domain
+func (a *App) CheckCustomDomain(r *fastglue.Request) error {
+	host := normalizeHostname(string(r.RequestCtx.QueryArgs().Peek("domain")))
+	if host == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Missing domain", nil, "")
+	}
+
+	var count int64
+	a.DB.Model(&models.CustomDomain{}).Where("domain = ? AND is_verified = true", host).Count(&count)
+	if count == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Domain not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"domain": host})
+}
+
+// publicBaseURL returns the base URL for links and pages generated for the
+// organization: its verified custom domain, or the server's public URL
+func (a *App) publicBaseURL(orgID uuid.UUID) string {
+	var domain models.CustomDomain
+	if err := a.DB.Where("organization_id = ? AND is_verified = true", orgID).First(&domain).Error; err == nil {
+		return "https://" + domain.Domain
+	}
+	return strings.TrimSuffix(a.Config.Server.PublicURL, "/")
+}
+
+// normalizeHostname lowercases a hostname and strips a trailing dot, any scheme and any path
+func normalizeHostname(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.IndexAny(host, "/:"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"gorm.io/gorm"
+)
+
+// dateTriggerRecipientBatch is how many recipients are inserted per statement
+const dateTriggerRecipientBatch = 500
+
+// DateTriggerProcessor runs each enabled date trigger once a day at its send hour,
+// turning the day's matching contacts into a campaign
+type DateTriggerProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewDateTriggerProcessor creates a new date trigger processor
+func NewDateTriggerProcessor(app *App, interval time.Duration) *DateTriggerProcessor {
+	return &DateTriggerProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the date trigger processing loop
+func (p *DateTriggerProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Date trigger processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Date trigger processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Date trigger processor stopped")
+			return
+		case <-ticker.C:
+			p.processTriggers(ctx)
+		}
+	}
+}
+
+// Stop stops the date trigger processor
+func (p *DateTriggerProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// processTriggers runs every enabled trigger that hasn't run yet today and whose
+// send hour has passed in its organization's timezone
+func (p *DateTriggerProcessor) processTriggers(ctx context.Context) {
+	var triggers []models.DateTrigger
+	if err := p.app.DB.Where("is_enabled = true").Find(&triggers).Error; err != nil {
+		p.app.Log.Error("Failed to load date triggers", "error", err)
+		return
+	}
+
+	locations := make(map[uuid.UUID]*time.Location)
+	for i := range triggers {
+		trigger := &triggers[i]
+
+		loc, ok := locations[trigger.OrganizationID]
+		if !ok {
+			loc = p.app.orgLocation(trigger.OrganizationID)
+			locations[trigger.OrganizationID] = loc
+		}
+
+		now := time.Now().In(loc)
+		today := now.Format("2006-01-02")
+		if now.Hour() < trigger.SendHour || trigger.LastRunDate == today {
+			continue
+		}
+
+		// Claim the day so other server instances skip this trigger
+		result := p.app.DB.Model(&models.DateTrigger{}).
+			Where("id = ? AND (last_run_date IS NULL OR last_run_date <> ?)", trigger.ID, today).
+			Update("last_run_date", today)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := p.runTrigger(ctx, trigger, now); err != nil {
+			p.app.Log.Error("Failed to run date trigger", "error", err, "trigger_id", trigger.ID)
+		}
+	}
+}
+
+// runTrigger creates and queues a campaign for the contacts whose date matches
+func (p *DateTriggerProcessor) runTrigger(ctx context.Context, trigger *models.DateTrigger, now time.Time) error {
+	if blackout := p.app.campaignBlackout(trigger.OrganizationID); blackout != nil {
+		p.app.Log.Info("Skipping date trigger on blackout date", "trigger_id", trigger.ID, "blackout", blackout.Name)
+		return nil
+	}
+
+	var template models.Template
+	if err := p.app.DB.Where("id = ? AND organization_id = ?", trigger.TemplateID, trigger.OrganizationID).First(&template).Error; err != nil {
+		return fmt.Errorf("template not found: %w", err)
+	}
+	if template.Status != "APPROVED" {
+		return fmt.Errorf("template %s is not approved", template.Name)
+	}
+
+	contacts, err := p.app.dateTriggerContacts(trigger, now)
+	if err != nil {
+		return err
+	}
+	if len(contacts) == 0 {
+		return nil
+	}
+
+	recipients := make([]models.BulkMessageRecipient, len(contacts))
+	for i := range contacts {
+		recipients[i] = contactRecipient(&contacts[i], trigger.TemplateParams)
+	}
+
+	startedAt := time.Now()
+	campaign := models.BulkMessageCampaign{
+		OrganizationID:  trigger.OrganizationID,
+		WhatsAppAccount: trigger.WhatsAppAccount,
+		Name:            fmt.Sprintf("%s (%s)", trigger.Name, now.Format("2006-01-02")),
+		TemplateID:      trigger.TemplateID,
+		Status:          "queued",
+		TotalRecipients: len(recipients),
+		StartedAt:       &startedAt,
+		CreatedBy:       trigger.CreatedBy,
+		DateTriggerID:   &trigger.ID,
+	}
+	err = p.app.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		for i := range recipients {
+			recipients[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(&recipients, dateTriggerRecipientBatch).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create campaign: %w", err)
+	}
+
+	p.app.Log.Info("Date trigger campaign created", "trigger_id", trigger.ID, "campaign_id", campaign.ID, "recipients", len(recipients))
+	go p.app.dispatchCampaignEvent(trigger.OrganizationID, campaign.ID, EventCampaignQueued, "")
+
+	if p.app.Queue != nil {
+		if err := p.app.Queue.EnqueueCampaign(ctx, campaign.ID, queue.Priority(campaign.Priority)); err != nil {
+			return fmt.Errorf("failed to enqueue campaign: %w", err)
+		}
+	} else {
+		go p.app.processCampaign(campaign.ID)
+	}
+	return nil
+}
+
+// contactNotOptedOut excludes contacts who replied with an opt-out keyword; its
+// argument is engagement.OptOutKeywords
+const contactNotOptedOut = engagement.NotOptedOut
+
+// contactRecipient builds a campaign recipient for contact, filling placeholders such
+// as {{name}} or a metadata key in each template parameter
+func contactRecipient(contact *models.Contact, templateParams models.JSONB) models.BulkMessageRecipient {
+	data := map[string]interface{}{
+		"name":         contact.ProfileName,
+		"profile_name": contact.ProfileName,
+		"phone_number": contact.PhoneNumber,
+	}
+	for k, v := range contact.Metadata {
+		if _, exists := data[k]; !exists {
+			data[k] = v
+		}
+	}
+
+	params := models.JSONB{}
+	for key, value := range templateParams {
+		if s, ok := value.(string); ok {
+			params[key] = processTemplate(s, data)
+		}
+	}
+	return models.BulkMessageRecipient{
+		PhoneNumber:    contact.PhoneNumber,
+		RecipientName:  contact.ProfileName,
+		TemplateParams: params,
+	}
+}
+
+// dateTriggerContacts returns the trigger account's contacts whose date field falls
+// on day shifted by the trigger's offset, excluding contacts who opted out
+func (a *App) dateTriggerContacts(trigger *models.DateTrigger, day time.Time) ([]models.Contact, error) {
+	target := day.AddDate(0, 0, trigger.OffsetDays)
+
+	query := a.DB.Where("organization_id = ? AND whats_app_account = ?", trigger.OrganizationID, trigger.WhatsAppAccount).
+		Where("metadata->>? ~ '^[0-9]{4}-[0-9]{2}-[0-9]{2}'", trigger.DateField).
+		Where(contactNotOptedOut, engagement.OptOutKeywords)
+
+	if trigger.Annual {
+		days := []string{target.Format("01-02")}
+		// Feb 29 dates are celebrated on Feb 28 in non-leap years
+		if target.Month() == time.February && target.Day() == 28 && target.AddDate(0, 0, 1).Month() == time.March {
+			days = append(days, "02-29")
+		}
+		query = query.Where("SUBSTRING(metadata->>? FROM 6 FOR 5) IN ?", trigger.DateField, days)
+	} else {
+		query = query.Where("LEFT(metadata->>?, 10) = ?", trigger.DateField, target.Format("2006-01-02"))
+	}
+
+	var contacts []models.Contact
+	if err := query.Find(&contacts).Error; err != nil {
+		return nil, fmt.Errorf("failed to find matching contacts: %w", err)
+	}
+	return contacts, nil
+}
+
+// orgLocation returns the timezone configured in the organization's settings, or UTC
+func (a *App) orgLocation(orgID uuid.UUID) *time.Location {
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return time.UTC
+	}
+	timezone, _ := org.Settings["timezone"].(string)
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// DateTriggerRequest is the request body for creating or updating a date trigger
+type DateTriggerRequest struct {
+	Name            string            `json:"name"`
+	WhatsAppAccount string            `json:"whatsapp_account"`
+	IsEnabled       *bool             `json:"is_enabled"`
+	DateField       string            `json:"date_field"`
+	Annual          bool              `json:"annual"`
+	OffsetDays      int               `json:"offset_days"`
+	SendHour        int               `json:"send_hour"`
+	TemplateID      string            `json:"template_id"`
+	TemplateParams  map[string]string `json:"template_params"`
+}
+
+// ListDateTriggers returns all date triggers for the organization
+func (a *App) ListDateTriggers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var triggers []models.DateTrigger
+	if err := a.DB.Where("organization_id = ?", orgID).Preload("Template").Order("created_at DESC").Find(&triggers).Error; err != nil {
+		a.Log.Error("Failed to list date triggers", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list date triggers", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"triggers": triggers,
+	})
+}
+
+// CreateDateTrigger creates a date trigger
+func (a *App) CreateDateTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req DateTriggerRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	trigger := models.DateTrigger{
+		OrganizationID: orgID,
+		IsEnabled:      true,
+		CreatedBy:      userID,
+	}
+	if err := a.applyDateTriggerRequest(orgID, &trigger, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Create(&trigger).Error; err != nil {
+		a.Log.Error("Failed to create date trigger", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create date trigger", nil, "")
+	}
+
+	return r.SendEnvelope(trigger)
+}
+
+// GetDateTrigger returns a date trigger
+func (a *App) GetDateTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findDateTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Date trigger not found", nil, "")
+	}
+
+	return r.SendEnvelope(trigger)
+}
+
+// UpdateDateTrigger updates a date trigger
+func (a *App) UpdateDateTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findDateTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Date trigger not found", nil, "")
+	}
+
+	var req DateTriggerRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := a.applyDateTriggerRequest(orgID, trigger, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	trigger.Template = nil
+	if err := a.DB.Save(trigger).Error; err != nil {
+		a.Log.Error("Failed to update date trigger", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update date trigger", nil, "")
+	}
+
+	return r.SendEnvelope(trigger)
+}
+
+// DeleteDateTrigger deletes a date trigger. Campaigns it already created are kept.
+func (a *App) DeleteDateTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findDateTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Date trigger not found", nil, "")
+	}
+
+	if err := a.DB.Delete(trigger).Error; err != nil {
+		a.Log.Error("Failed to delete date trigger", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete date trigger", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Date trigger deleted successfully"})
+}
+
+// PreviewDateTrigger lists the contacts the trigger would send to on a given day.
+// Query params: date (YYYY-MM-DD, defaults to today in the organization's timezone)
+func (a *App) PreviewDateTrigger(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	trigger, err := a.findDateTrigger(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Date trigger not found", nil, "")
+	}
+
+	day := time.Now().In(a.orgLocation(orgID))
+	if dateStr := string(r.RequestCtx.QueryArgs().Peek("date")); dateStr != "" {
+		day, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid date, expected YYYY-MM-DD", nil, "")
+		}
+	}
+
+	contacts, err := a.dateTriggerContacts(trigger, day)
+	if err != nil {
+		a.Log.Error("Failed to preview date trigger", "error", err, "trigger_id", trigger.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to preview date trigger", nil, "")
+	}
+
+	type previewContact struct {
+		ID          uuid.UUID `json:"id"`
+		PhoneNumber string    `json:"phone_number"`
+		ProfileName string    `json:"profile_name"`
+		Date        string    `json:"date"`
+	}
+	preview := make([]previewContact, len(contacts))
+	for i, c := range contacts {
+		date, _ := c.Metadata[trigger.DateField].(string)
+		preview[i] = previewContact{
+			ID:          c.ID,
+			PhoneNumber: c.PhoneNumber,
+			ProfileName: c.ProfileName,
+			Date:        date,
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"date":     day.Format("2006-01-02"),
+		"contacts": preview,
+		"total":    len(preview),
+	})
+}
+
+// applyDateTriggerRequest validates req and copies it onto trigger
+func (a *App) applyDateTriggerRequest(orgID uuid.UUID, trigger *models.DateTrigger, req *DateTriggerRequest) error {
+	if req.Name == "" || req.WhatsAppAccount == "" || req.DateField == "" {
+		return fmt.Errorf("name, whatsapp_account and date_field are required")
+	}
+	if req.SendHour < 0 || req.SendHour > 23 {
+		return fmt.Errorf("send_hour must be between 0 and 23")
+	}
+	if req.OffsetDays < -365 || req.OffsetDays > 365 {
+		return fmt.Errorf("offset_days must be between -365 and 365")
+	}
+
+	var count int64
+	a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("WhatsApp account not found")
+	}
+
+	templateID, err := uuid.Parse(req.TemplateID)
+	if err != nil {
+		return fmt.Errorf("invalid template_id")
+	}
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ? AND whats_app_account = ?", templateID, orgID, req.WhatsAppAccount).First(&template).Error; err != nil {
+		return fmt.Errorf("template not found for this account")
+	}
+
+	params := models.JSONB{}
+	for k, v := range req.TemplateParams {
+		params[k] = v
+	}
+
+	trigger.Name = req.Name
+	trigger.WhatsAppAccount = req.WhatsAppAccount
+	if req.IsEnabled != nil {
+		trigger.IsEnabled = *req.IsEnabled
+	}
+	trigger.DateField = req.DateField
+	trigger.Annual = req.Annual
+	trigger.OffsetDays = req.OffsetDays
+	trigger.SendHour = req.SendHour
+	trigger.TemplateID = templateID
+	trigger.TemplateParams = params
+	return nil
+}
+
+func (a *App) findDateTrigger(r *fastglue.Request, orgID uuid.UUID) (*models.DateTrigger, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var trigger models.DateTrigger
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Preload("Template").First(&trigger).Error; err != nil {
+		return nil, err
+	}
+	return &trigger, nil
+}
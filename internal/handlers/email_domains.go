@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/mailer"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// emailLocalPartPattern limits sender addresses to simple mailbox names
+var emailLocalPartPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,62}[a-z0-9])?$`)
+
+// EmailSenderDomainRequest is the request body for setting the organization's email sender domain
+type EmailSenderDomainRequest struct {
+	Domain        string `json:"domain"`
+	FromName      string `json:"from_name"`
+	FromLocalPart string `json:"from_local_part"` // Defaults to "notifications"
+}
+
+// DNSRecord is a record the organization must publish
+type DNSRecord struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// GetEmailSenderDomain returns the organization's email sender domain and the DNS
+// record that verifies it
+func (a *App) GetEmailSenderDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var domain models.EmailSenderDomain
+	if err := a.DB.Where("organization_id = ?", orgID).First(&domain).Error; err != nil {
+		return r.SendEnvelope(map[string]any{"domain": nil})
+	}
+
+	return r.SendEnvelope(emailSenderDomainResponse(&domain))
+}
+
+// SetEmailSenderDomain sets the domain the organization's emails are sent from and
+// generates a DKIM key for it. Changing the domain issues a new key and resets its
+// verification.
+func (a *App) SetEmailSenderDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req EmailSenderDomainRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	host := normalizeHostname(req.Domain)
+	if !hostnamePattern.MatchString(host) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid domain", nil, "")
+	}
+	req.FromLocalPart = strings.ToLower(strings.TrimSpace(req.FromLocalPart))
+	if req.FromLocalPart == "" {
+		req.FromLocalPart = "notifications"
+	}
+	if !emailLocalPartPattern.MatchString(req.FromLocalPart) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid sender address", nil, "")
+	}
+	req.FromName = strings.TrimSpace(req.FromName)
+	if len([]rune(req.FromName)) > 100 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Sender name can be at most 100 characters", nil, "")
+	}
+
+	var domain models.EmailSenderDomain
+	err = a.DB.Where("organization_id = ?", orgID).First(&domain).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		a.Log.Error("Failed to load email sender domain", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save email sender domain", nil, "")
+	}
+
+	domain.OrganizationID = orgID
+	domain.FromName = req.FromName
+	domain.FromLocalPart = req.FromLocalPart
+	if domain.Domain != host {
+		privateKey, publicKey, err := mailer.GenerateDKIMKey()
+		if err != nil {
+			a.Log.Error("Failed to generate DKIM key", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save email sender domain", nil, "")
+		}
+		domain.Domain = host
+		domain.DKIMSelector = "wm" + time.Now().Format("20060102")
+		domain.DKIMPrivateKey = privateKey
+		domain.DKIMPublicKey = publicKey
+		domain.IsVerified = false
+		domain.VerifiedAt = nil
+		domain.LastCheckedAt = nil
+		domain.LastError = ""
+	}
+	if err := a.DB.Save(&domain).Error; err != nil {
+		a.Log.Error("Failed to save email sender domain", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save email sender domain", nil, "")
+	}
+
+	return r.SendEnvelope(emailSenderDomainResponse(&domain))
+}
+
+// VerifyEmailSenderDomain checks that the domain publishes the DKIM public key.
+// Emails use the domain only once it is verified.
+func (a *App) VerifyEmailSenderDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var domain models.EmailSenderDomain
+	if err := a.DB.Where("organization_id = ?", orgID).First(&domain).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Email sender domain not found", nil, "")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), customDomainLookupTimeout)
+	defer cancel()
+	now := time.Now()
+	updates := map[string]any{
+		"last_checked_at": now,
+		"is_verified":     false,
+		"verified_at":     nil,
+	}
+
+	name := dkimRecordName(&domain)
+	records, err := net.DefaultResolver.LookupTXT(ctx, name)
+	switch {
+	case err != nil:
+		updates["last_error"] = fmt.Sprintf("DNS lookup of %s failed: %v", name, err)
+	case !dkimRecordPublished(records, domain.DKIMPublicKey):
+		updates["last_error"] = fmt.Sprintf("%s doesn't have the expected DKIM key", name)
+	default:
+		updates["last_error"] = ""
+		updates["is_verified"] = true
+		updates["verified_at"] = now
+	}
+
+	if err := a.DB.Model(&domain).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to update email sender domain verification", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to verify email sender domain", nil, "")
+	}
+	a.DB.First(&domain, "id = ?", domain.ID)
+
+	return r.SendEnvelope(emailSenderDomainResponse(&domain))
+}
+
+// DeleteEmailSenderDomain removes the organization's email sender domain; emails fall
+// back to the platform's address
+func (a *App) DeleteEmailSenderDomain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	result := a.DB.Where("organization_id = ?", orgID).Delete(&models.EmailSenderDomain{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete email sender domain", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete email sender domain", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Email sender domain not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Email sender domain deleted successfully"})
+}
+
+func emailSenderDomainResponse(domain *models.EmailSenderDomain) map[string]any {
+	return map[string]any{
+		"domain":       domain,
+		"from_address": domain.FromAddress(),
+		"dns_records": []DNSRecord{{
+			Type:  "TXT",
+			Name:  dkimRecordName(domain),
+			Value: mailer.DKIMRecord(domain.DKIMPublicKey),
+		}},
+	}
+}
+
+func dkimRecordName(domain *models.EmailSenderDomain) string {
+	return domain.DKIMSelector + "._domainkey." + domain.Domain
+}
+
+// dkimRecordPublished reports whether any TXT record carries the public key. Long
+// records come back as one string per lookup result, already joined.
+func dkimRecordPublished(records []string, publicKey string) bool {
+	for _, record := range records {
+		if mailer.DKIMRecordKey(record) == publicKey {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// externalRefKeyPattern limits keys to identifiers like crm_id or deal_id
+var externalRefKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// maxExternalRefValueLength matches the value column
+const maxExternalRefValueLength = 255
+
+// ExternalReferenceRequest is the request body for setting an external reference
+type ExternalReferenceRequest struct {
+	EntityType string `json:"entity_type"` // contact, conversation, campaign
+	EntityID   string `json:"entity_id"`   // Contact ID for contacts and conversations
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+}
+
+// ListExternalReferences returns the external references of one record.
+// Query params: entity_type, entity_id (both required)
+func (a *App) ListExternalReferences(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	entityType := string(r.RequestCtx.QueryArgs().Peek("entity_type"))
+	if !validExternalRefEntity(entityType) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "entity_type must be contact, conversation or campaign", nil, "")
+	}
+	entityID, err := uuid.Parse(string(r.RequestCtx.QueryArgs().Peek("entity_id")))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid entity_id", nil, "")
+	}
+
+	var refs []models.ExternalReference
+	if err := a.DB.Where("organization_id = ? AND entity_type = ? AND entity_id = ?", orgID, entityType, entityID).
+		Order("key").Find(&refs).Error; err != nil {
+		a.Log.Error("Failed to list external references", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list external references", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{"references": refs})
+}
+
+// SetExternalReference stores a record's value for a key, replacing any previous
+// value. A value already naming another record of the same type is a conflict.
+func (a *App) SetExternalReference(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req ExternalReferenceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Value = strings.TrimSpace(req.Value)
+	if !validExternalRefEntity(req.EntityType) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "entity_type must be contact, conversation or campaign", nil, "")
+	}
+	entityID, err := uuid.Parse(req.EntityID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid entity_id", nil, "")
+	}
+	if !externalRefKeyPattern.MatchString(req.Key) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "key must be lowercase letters, digits or underscores, e.g. crm_id", nil, "")
+	}
+	if req.Value == "" || len(req.Value) > maxExternalRefValueLength {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "value is required and can be at most 255 characters", nil, "")
+	}
+
+	exists, err := a.externalRefEntityExists(orgID, req.EntityType, entityID)
+	if err != nil {
+		a.Log.Error("Failed to check external reference entity", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to set external reference", nil, "")
+	}
+	if !exists {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Record not found", nil, "")
+	}
+
+	var taken models.ExternalReference
+	err = a.DB.Where("organization_id = ? AND entity_type = ? AND key = ? AND value = ? AND entity_id <> ?",
+		orgID, req.EntityType, req.Key, req.Value, entityID).First(&taken).Error
+	if err == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "This "+req.Key+" already refers to another "+req.EntityType,
+			map[string]any{"entity_id": taken.EntityID}, "")
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		a.Log.Error("Failed to check external reference", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to set external reference", nil, "")
+	}
+
+	var ref models.ExternalReference
+	err = a.DB.Where("organization_id = ? AND entity_type = ? AND entity_id = ? AND key = ?",
+		orgID, req.EntityType, entityID, req.Key).First(&ref).Error
+	switch {
+	case err == nil:
+		ref.Value = req.Value
+		err = a.DB.Model(&ref).Update("value", req.Value).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ref = models.ExternalReference{
+			OrganizationID: orgID,
+			EntityType:     req.EntityType,
+			EntityID:       entityID,
+			Key:            req.Key,
+			Value:          req.Value,
+		}
+		if userID, uerr := a.getUserIDFromContext(r); uerr == nil {
+			ref.CreatedByID = &userID
+		}
+		err = a.DB.Create(&ref).Error
+	}
+	if err != nil {
+		// The unique indexes also catch a concurrent request claiming the same value
+		a.Log.Error("Failed to set external reference", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to set external reference", nil, "")
+	}
+
+	return r.SendEnvelope(ref)
+}
+
+// DeleteExternalReference removes an external reference
+func (a *App) DeleteExternalReference(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid reference ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.ExternalReference{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete external reference", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete external reference", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Reference not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Reference deleted"})
+}
+
+// LookupExternalReference finds the record an external ID refers to and returns it
+// with the reference. Contacts and conversations return the contact, campaigns the
+// campaign.
+// Query params: entity_type, key, value (all required)
+func (a *App) LookupExternalReference(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	args := r.RequestCtx.QueryArgs()
+	entityType := string(args.Peek("entity_type"))
+	key := string(args.Peek("key"))
+	value := strings.TrimSpace(string(args.Peek("value")))
+	if !validExternalRefEntity(entityType) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "entity_type must be contact, conversation or campaign", nil, "")
+	}
+	if key == "" || value == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "key and value are required", nil, "")
+	}
+
+	var ref models.ExternalReference
+	if err := a.DB.Where("organization_id = ? AND entity_type = ? AND key = ? AND value = ?", orgID, entityType, key, value).
+		First(&ref).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No record with this external ID", nil, "")
+	}
+
+	var record any
+	switch entityType {
+	case models.ExternalRefCampaign:
+		var campaign models.BulkMessageCampaign
+		if err := a.DB.Where("id = ? AND organization_id = ?", ref.EntityID, orgID).First(&campaign).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+		}
+		record = campaign
+	default:
+		var contact models.Contact
+		if err := a.DB.Where("id = ? AND organization_id = ?", ref.EntityID, orgID).First(&contact).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+		}
+		if a.ShouldMaskPhoneNumbers(orgID) {
+			contact.PhoneNumber = MaskPhoneNumber(contact.PhoneNumber)
+			contact.ProfileName = MaskIfPhoneNumber(contact.ProfileName)
+		}
+		record = contact
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"reference": ref,
+		"record":    record,
+	})
+}
+
+func validExternalRefEntity(entityType string) bool {
+	switch entityType {
+	case models.ExternalRefContact, models.ExternalRefConversation, models.ExternalRefCampaign:
+		return true
+	}
+	return false
+}
+
+// externalRefEntityExists reports whether the organization has the record a
+// reference would point at
+func (a *App) externalRefEntityExists(orgID uuid.UUID, entityType string, entityID uuid.UUID) (bool, error) {
+	var model any = &models.Contact{}
+	if entityType == models.ExternalRefCampaign {
+		model = &models.BulkMessageCampaign{}
+	}
+	var count int64
+	if err := a.DB.Model(model).Where("id = ? AND organization_id = ?", entityID, orgID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
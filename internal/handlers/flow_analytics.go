@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// FlowAnalytics summarizes how sessions went through a chatbot flow in a period
+type FlowAnalytics struct {
+	FlowID         uuid.UUID           `json:"flow_id"`
+	Name           string              `json:"name"`
+	Enabled        bool                `json:"enabled"`
+	Started        int64               `json:"started"`
+	Completed      int64               `json:"completed"`
+	Transferred    int64               `json:"transferred"`
+	DroppedOff     int64               `json:"dropped_off"`     // Exited early other than by a transfer
+	CompletionRate float64             `json:"completion_rate"` // Percent of starts
+	Steps          []FlowStepAnalytics `json:"steps,omitempty"`
+}
+
+// FlowStepAnalytics summarizes how sessions went through one step of a flow
+type FlowStepAnalytics struct {
+	StepName    string             `json:"step_name"`
+	Entered     int64              `json:"entered"`
+	Completed   int64              `json:"completed"` // Flows that completed after this step
+	Exits       map[string]int64   `json:"exits"`     // Flows exited at this step, by reason
+	DroppedOff  int64              `json:"dropped_off"`
+	DropOffRate float64            `json:"drop_off_rate"` // Percent of entries
+	Choices     []FlowButtonChoice `json:"choices,omitempty"`
+}
+
+// FlowButtonChoice is how often a step's button was chosen
+type FlowButtonChoice struct {
+	ButtonID string  `json:"button_id"`
+	Title    string  `json:"title"`
+	Count    int64   `json:"count"`
+	Share    float64 `json:"share"` // Percent of the step's choices
+}
+
+// flowEventCount is a summed rollup counter
+type flowEventCount struct {
+	FlowID   uuid.UUID
+	StepName string
+	Event    string
+	Value    string
+	Count    int64
+}
+
+// recordFlowEvent counts an event of the session's flow version in the rollup of today
+// in the organization's timezone
+func (a *App) recordFlowEvent(session *models.ChatbotSession, flowID uuid.UUID, stepName, event, value string) {
+	if len(value) > 100 {
+		value = value[:100]
+	}
+	now := time.Now()
+	if err := a.DB.Exec(`INSERT INTO chatbot_flow_daily_rollups (id, organization_id, flow_id, flow_version, date, step_name, event, value, count, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT (flow_id, flow_version, date, step_name, event, value) DO UPDATE SET
+			count = chatbot_flow_daily_rollups.count + 1,
+			updated_at = EXCLUDED.updated_at`,
+		uuid.New(), session.OrganizationID, flowID, session.FlowVersion, now.In(a.orgLocation(session.OrganizationID)).Format("2006-01-02"),
+		stepName, event, value, now, now).Error; err != nil {
+		a.Log.Error("Failed to record flow event", "error", err, "flow_id", flowID, "event", event)
+	}
+}
+
+// GetChatbotAnalytics reports how sessions went through each of the organization's flows.
+// Query params: from, to (YYYY-MM-DD; defaults to the current month)
+func (a *App) GetChatbotAnalytics(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	from, to, err := a.flowAnalyticsPeriod(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var flows []models.ChatbotFlow
+	if err := a.DB.Select("id", "name", "is_enabled").Where("organization_id = ?", orgID).
+		Order("name").Find(&flows).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to fetch flows", nil, "")
+	}
+
+	var counts []flowEventCount
+	if err := a.DB.Model(&models.ChatbotFlowDailyRollup{}).
+		Select("flow_id, event, value, SUM(count) AS count").
+		Where("organization_id = ? AND date >= ? AND date <= ? AND event IN ?", orgID, from, to,
+			[]string{models.FlowEventStarted, models.FlowEventCompleted, models.FlowEventExited}).
+		Group("flow_id, event, value").
+		Scan(&counts).Error; err != nil {
+		a.Log.Error("Failed to load flow analytics", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load flow analytics", nil, "")
+	}
+
+	byFlow := make(map[uuid.UUID][]flowEventCount)
+	for _, c := range counts {
+		byFlow[c.FlowID] = append(byFlow[c.FlowID], c)
+	}
+
+	response := make([]FlowAnalytics, len(flows))
+	for i := range flows {
+		response[i] = summarizeFlow(&flows[i], byFlow[flows[i].ID])
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"flows": response,
+		"from":  from,
+		"to":    to,
+	})
+}
+
+// GetChatbotFlowAnalytics reports how sessions went through each step of a flow: how
+// many reached it, where they dropped off and which buttons they chose.
+// Query params: from, to (YYYY-MM-DD; defaults to the current month), version (a
+// published version; defaults to all)
+func (a *App) GetChatbotFlowAnalytics(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flow ID", nil, "")
+	}
+
+	from, to, err := a.flowAnalyticsPeriod(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var flow models.ChatbotFlow
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("step_order ASC")
+		}).
+		First(&flow).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
+	}
+
+	query := a.DB.Model(&models.ChatbotFlowDailyRollup{}).
+		Where("flow_id = ? AND organization_id = ? AND date >= ? AND date <= ?", id, orgID, from, to)
+
+	// Steps are listed in the order of the version asked for, else of the flow as edited
+	steps := flow.Steps
+	var version *int
+	if v := string(r.RequestCtx.QueryArgs().Peek("version")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid version", nil, "")
+		}
+		version = &n
+		query = query.Where("flow_version = ?", n)
+
+		if n > 0 {
+			var published models.ChatbotFlowVersion
+			if err := a.DB.Where("flow_id = ? AND version = ?", id, n).First(&published).Error; err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow version not found", nil, "")
+			}
+			versionFlow, err := publishedFlow(&flow, &published)
+			if err != nil {
+				a.Log.Error("Failed to read flow version", "error", err, "flow_id", id, "version", n)
+				return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read flow version", nil, "")
+			}
+			steps = versionFlow.Steps
+		}
+	}
+
+	var counts []flowEventCount
+	if err := query.Select("flow_id, step_name, event, value, SUM(count) AS count").
+		Group("flow_id, step_name, event, value").
+		Scan(&counts).Error; err != nil {
+		a.Log.Error("Failed to load flow analytics", "error", err, "flow_id", id)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load flow analytics", nil, "")
+	}
+
+	analytics := summarizeFlow(&flow, counts)
+	analytics.Steps = summarizeFlowSteps(steps, counts)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"flow":    analytics,
+		"version": version,
+		"from":    from,
+		"to":      to,
+	})
+}
+
+// flowAnalyticsPeriod reads the from and to dates of an analytics request, defaulting
+// to the current month in the organization's timezone
+func (a *App) flowAnalyticsPeriod(r *fastglue.Request, orgID uuid.UUID) (string, string, error) {
+	fromStr := string(r.RequestCtx.QueryArgs().Peek("from"))
+	toStr := string(r.RequestCtx.QueryArgs().Peek("to"))
+
+	if fromStr == "" || toStr == "" {
+		now := time.Now().In(a.orgLocation(orgID))
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02"), now.Format("2006-01-02"), nil
+	}
+	if _, err := time.Parse("2006-01-02", fromStr); err != nil {
+		return "", "", fmt.Errorf("Invalid 'from' date format. Use YYYY-MM-DD")
+	}
+	if _, err := time.Parse("2006-01-02", toStr); err != nil {
+		return "", "", fmt.Errorf("Invalid 'to' date format. Use YYYY-MM-DD")
+	}
+	return fromStr, toStr, nil
+}
+
+// summarizeFlow adds up a flow's starts and outcomes
+func summarizeFlow(flow *models.ChatbotFlow, counts []flowEventCount) FlowAnalytics {
+	summary := FlowAnalytics{FlowID: flow.ID, Name: flow.Name, Enabled: flow.IsEnabled}
+	for _, c := range counts {
+		switch c.Event {
+		case models.FlowEventStarted:
+			summary.Started += c.Count
+		case models.FlowEventCompleted:
+			summary.Completed += c.Count
+		case models.FlowEventExited:
+			if c.Value == models.FlowExitTransferred {
+				summary.Transferred += c.Count
+			} else {
+				summary.DroppedOff += c.Count
+			}
+		}
+	}
+	summary.CompletionRate = percentOf(summary.Completed, summary.Started)
+	return summary
+}
+
+// summarizeFlowSteps adds up the counts of each step, in the order of steps. Steps no
+// longer in the flow that have counts come last.
+func summarizeFlowSteps(steps []models.ChatbotFlowStep, counts []flowEventCount) []FlowStepAnalytics {
+	byName := make(map[string]*FlowStepAnalytics)
+	var order []string
+	titles := make(map[string]map[string]string)
+	step := func(name string) *FlowStepAnalytics {
+		if s, ok := byName[name]; ok {
+			return s
+		}
+		s := &FlowStepAnalytics{StepName: name, Exits: map[string]int64{}}
+		byName[name] = s
+		order = append(order, name)
+		return s
+	}
+	for i := range steps {
+		step(steps[i].StepName)
+		titles[steps[i].StepName] = flowButtonTitles(&steps[i])
+	}
+	known := len(order)
+
+	var removed []string
+	for _, c := range counts {
+		if c.StepName == "" {
+			continue
+		}
+		if _, ok := byName[c.StepName]; !ok {
+			removed = append(removed, c.StepName)
+		}
+		s := step(c.StepName)
+		switch c.Event {
+		case models.FlowEventEntered:
+			s.Entered += c.Count
+		case models.FlowEventCompleted:
+			s.Completed += c.Count
+		case models.FlowEventExited:
+			s.Exits[c.Value] += c.Count
+			if c.Value != models.FlowExitTransferred {
+				s.DroppedOff += c.Count
+			}
+		case models.FlowEventChosen:
+			s.Choices = append(s.Choices, FlowButtonChoice{ButtonID: c.Value, Title: titles[c.StepName][c.Value], Count: c.Count})
+		}
+	}
+
+	// Keep the flow's own steps in order and list removed steps after them by name
+	sort.Strings(removed)
+	order = append(order[:known], removed...)
+
+	result := make([]FlowStepAnalytics, 0, len(order))
+	for _, name := range order {
+		s := byName[name]
+		s.DropOffRate = percentOf(s.DroppedOff, s.Entered)
+
+		var chosen int64
+		for _, choice := range s.Choices {
+			chosen += choice.Count
+		}
+		for i := range s.Choices {
+			s.Choices[i].Share = percentOf(s.Choices[i].Count, chosen)
+		}
+		sort.Slice(s.Choices, func(i, j int) bool { return s.Choices[i].Count > s.Choices[j].Count })
+		result = append(result, *s)
+	}
+	return result
+}
+
+// flowButtonTitles maps the IDs of a step's buttons to their titles, with IDs generated
+// for buttons without one as they are when sent
+func flowButtonTitles(step *models.ChatbotFlowStep) map[string]string {
+	titles := make(map[string]string, len(step.Buttons))
+	for i, btn := range step.Buttons {
+		btnMap, ok := btn.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := btnMap["id"].(string)
+		if id == "" {
+			id = fmt.Sprintf("btn_%d", i+1)
+		}
+		titles[id], _ = btnMap["title"].(string)
+	}
+	return titles
+}
+
+// percentOf returns part as a percentage of whole, to one decimal place
+func percentOf(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 10
+}
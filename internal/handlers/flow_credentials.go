@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// FlowCredentialRequest is the request body for creating or updating a flow credential
+type FlowCredentialRequest struct {
+	Name       string `json:"name"`
+	AuthType   string `json:"auth_type"` // bearer, basic, header
+	BaseURL    string `json:"base_url"`
+	HeaderName string `json:"header_name"`
+	Username   string `json:"username"`
+	Secret     string `json:"secret"` // Left empty on update to keep the current secret
+}
+
+// ListFlowCredentials lists the organization's flow credentials, without their secrets
+func (a *App) ListFlowCredentials(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var credentials []models.FlowCredential
+	if err := a.DB.Where("organization_id = ?", orgID).Order("name").Find(&credentials).Error; err != nil {
+		a.Log.Error("Failed to list flow credentials", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list credentials", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{"credentials": credentials})
+}
+
+// CreateFlowCredential stores a credential for flows' HTTP request steps
+func (a *App) CreateFlowCredential(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req FlowCredentialRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Secret == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "secret is required", nil, "")
+	}
+
+	credential := models.FlowCredential{OrganizationID: orgID}
+	if msg := applyFlowCredentialRequest(&credential, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if taken, err := a.flowCredentialNameTaken(orgID, credential.Name, uuid.Nil); err != nil {
+		a.Log.Error("Failed to check flow credential name", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create credential", nil, "")
+	} else if taken {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A credential with this name already exists", nil, "")
+	}
+	if userID, uerr := a.getUserIDFromContext(r); uerr == nil {
+		credential.CreatedByID = &userID
+	}
+
+	if err := a.DB.Create(&credential).Error; err != nil {
+		a.Log.Error("Failed to create flow credential", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create credential", nil, "")
+	}
+
+	a.Log.Info("Flow credential created", "credential_id", credential.ID, "name", credential.Name)
+
+	return r.SendEnvelope(credential)
+}
+
+// UpdateFlowCredential updates a flow credential. Its secret is kept unless a new one
+// is given.
+func (a *App) UpdateFlowCredential(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid credential ID", nil, "")
+	}
+
+	var credential models.FlowCredential
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&credential).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Credential not found", nil, "")
+	}
+
+	var req FlowCredentialRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := applyFlowCredentialRequest(&credential, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if taken, err := a.flowCredentialNameTaken(orgID, credential.Name, credential.ID); err != nil {
+		a.Log.Error("Failed to check flow credential name", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update credential", nil, "")
+	} else if taken {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A credential with this name already exists", nil, "")
+	}
+
+	if err := a.DB.Save(&credential).Error; err != nil {
+		a.Log.Error("Failed to update flow credential", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update credential", nil, "")
+	}
+
+	a.Log.Info("Flow credential updated", "credential_id", credential.ID, "name", credential.Name, "secret_changed", req.Secret != "")
+
+	return r.SendEnvelope(credential)
+}
+
+// DeleteFlowCredential deletes a flow credential. Steps still using it fail and take
+// their error branch.
+func (a *App) DeleteFlowCredential(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid credential ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.FlowCredential{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete flow credential", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete credential", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Credential not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Credential deleted"})
+}
+
+// applyFlowCredentialRequest validates the request and copies it onto the credential,
+// keeping its secret when the request leaves it out. It returns a message describing
+// an invalid request.
+func applyFlowCredentialRequest(credential *models.FlowCredential, req *FlowCredentialRequest) string {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return "name is required and can be at most 100 characters"
+	}
+
+	base, err := url.Parse(strings.TrimSpace(req.BaseURL))
+	if err != nil || (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return "base_url must be an http or https URL"
+	}
+
+	credential.HeaderName, credential.Username = "", ""
+	switch req.AuthType {
+	case models.FlowCredentialBearer:
+	case models.FlowCredentialBasic:
+		if req.Username == "" {
+			return "username is required for basic credentials"
+		}
+		credential.Username = req.Username
+	case models.FlowCredentialHeader:
+		if req.HeaderName == "" {
+			return "header_name is required for header credentials"
+		}
+		credential.HeaderName = req.HeaderName
+	default:
+		return "auth_type must be bearer, basic or header"
+	}
+
+	credential.Name = name
+	credential.AuthType = req.AuthType
+	credential.BaseURL = base.String()
+	if req.Secret != "" {
+		credential.Secret = req.Secret
+	}
+	return ""
+}
+
+// flowCredentialNameTaken reports whether another of the organization's credentials
+// has the name
+func (a *App) flowCredentialNameTaken(orgID uuid.UUID, name string, exceptID uuid.UUID) (bool, error) {
+	var existing models.FlowCredential
+	err := a.DB.Where("organization_id = ? AND name = ? AND id <> ?", orgID, name, exceptID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shridarpatil/whatomate/internal/flowexpr"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// flowVariableNamePattern matches the names variables can be declared with
+var flowVariableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedFlowVariableNames are words of the expression language
+var reservedFlowVariableNames = map[string]bool{
+	"and": true, "or": true, "not": true, "true": true, "false": true, "null": true,
+}
+
+// flowVariable is a typed variable declared on a flow
+type flowVariable struct {
+	Name    string
+	Type    flowexpr.Type
+	Default interface{}
+}
+
+// flowVariables reads a flow's variable declarations. Malformed ones, which the flow
+// can't be enabled with, are skipped.
+func flowVariables(flow *models.ChatbotFlow) []flowVariable {
+	vars := make([]flowVariable, 0, len(flow.Variables))
+	for _, item := range flow.Variables {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["name"].(string)
+		typeName, _ := m["type"].(string)
+		t := flowexpr.Any
+		if typeName != "" {
+			if t, ok = flowexpr.ParseType(typeName); !ok {
+				continue
+			}
+		}
+		if name == "" {
+			continue
+		}
+		vars = append(vars, flowVariable{Name: name, Type: t, Default: m["default"]})
+	}
+	return vars
+}
+
+// flowVariableTypes returns the declared type of each of a flow's variables
+func flowVariableTypes(flow *models.ChatbotFlow) map[string]flowexpr.Type {
+	types := make(map[string]flowexpr.Type, len(flow.Variables))
+	for _, v := range flowVariables(flow) {
+		types[v.Name] = v.Type
+	}
+	return types
+}
+
+// flowVariableDefaults returns the session data a flow starts with: the default of
+// each variable that has one, converted to the variable's type
+func flowVariableDefaults(flow *models.ChatbotFlow) models.JSONB {
+	data := models.JSONB{}
+	for _, v := range flowVariables(flow) {
+		if v.Default == nil || v.Default == "" {
+			continue
+		}
+		if value, err := flowexpr.Coerce(v.Default, v.Type); err == nil {
+			data[v.Name] = value
+		}
+	}
+	return data
+}
+
+// flowEnv returns the environment a flow's expressions are evaluated in for a
+// session. Dates are in the organization's timezone.
+func (a *App) flowEnv(flow *models.ChatbotFlow, session *models.ChatbotSession) flowexpr.Env {
+	return flowexpr.Env{
+		Data:     session.SessionData,
+		Types:    flowVariableTypes(flow),
+		Location: a.orgLocation(session.OrganizationID),
+	}
+}
+
+// renderFlowText processes a template in a flow's message. Only text with {{= }}
+// expressions needs the flow's environment.
+func (a *App) renderFlowText(text string, flow *models.ChatbotFlow, session *models.ChatbotSession) string {
+	if !strings.Contains(text, "{{=") {
+		return processTemplate(text, session.SessionData)
+	}
+	return renderTemplate(text, a.flowEnv(flow, session))
+}
+
+// applyStepAssignments evaluates a step's assignments and saves the session
+func (a *App) applyStepAssignments(flow *models.ChatbotFlow, session *models.ChatbotSession, step *models.ChatbotFlowStep) {
+	if len(step.Assignments) == 0 {
+		return
+	}
+	a.assignStepVariables(flow, session, step)
+	a.DB.Model(session).Update("session_data", session.SessionData)
+}
+
+// assignStepVariables evaluates a step's assignments in order, each seeing the ones
+// before it, and sets the results in the session. One that fails leaves its variable
+// unchanged.
+func (a *App) assignStepVariables(flow *models.ChatbotFlow, session *models.ChatbotSession, step *models.ChatbotFlowStep) {
+	if len(step.Assignments) == 0 {
+		return
+	}
+	if session.SessionData == nil {
+		session.SessionData = models.JSONB{}
+	}
+
+	env := a.flowEnv(flow, session)
+	for i, item := range step.Assignments {
+		m, _ := item.(map[string]interface{})
+		variable, _ := m["variable"].(string)
+		src, _ := m["expr"].(string)
+		if variable == "" {
+			continue
+		}
+
+		value, err := evalFlowExpression(src, env)
+		if err == nil {
+			value, err = flowexpr.Coerce(value, env.Types[variable])
+		}
+		if err != nil {
+			a.Log.Warn("Flow assignment failed", "error", err, "step", step.StepName, "assignment", i+1, "variable", variable)
+			continue
+		}
+		session.SessionData[variable] = value
+	}
+}
+
+// conditionNextStep returns the step a condition step leads to: that of its first
+// branch whose expression is true, else its next step, else the step after it. A
+// branch that fails to evaluate counts as false.
+func (a *App) conditionNextStep(flow *models.ChatbotFlow, session *models.ChatbotSession, step *models.ChatbotFlowStep) string {
+	env := a.flowEnv(flow, session)
+	for i, item := range step.Branches {
+		m, _ := item.(map[string]interface{})
+		src, _ := m["if"].(string)
+		next, _ := m["next"].(string)
+
+		value, err := evalFlowExpression(src, env)
+		if err != nil {
+			a.Log.Warn("Flow condition failed", "error", err, "step", step.StepName, "branch", i+1)
+			continue
+		}
+		if flowexpr.Truthy(value) {
+			return next
+		}
+	}
+
+	return nextStepAfter(flow, step)
+}
+
+// evalFlowExpression parses and evaluates an expression
+func evalFlowExpression(src string, env flowexpr.Env) (interface{}, error) {
+	expr, err := flowexpr.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Eval(env)
+}
+
+// coerceStoredInput converts a reply stored by a step to the type the flow declares
+// for it. Replies to variables without a declared type are stored as they are.
+func coerceStoredInput(flow *models.ChatbotFlow, name, input string) (interface{}, error) {
+	t, ok := flowVariableTypes(flow)[name]
+	if !ok {
+		return input, nil
+	}
+	return flowexpr.Coerce(input, t)
+}
+
+// validateFlowExpressions checks a flow's variable declarations and every expression
+// in it against the variables in scope, returning a message for each problem
+func validateFlowExpressions(flow *models.ChatbotFlow, steps []models.ChatbotFlowStep) []string {
+	var errs []string
+	vars := map[string]flowexpr.Type{}
+
+	seen := map[string]bool{}
+	for i, item := range flow.Variables {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			errs = append(errs, fmt.Sprintf("Variable %d: must be an object", i+1))
+			continue
+		}
+		name, _ := m["name"].(string)
+		switch {
+		case !flowVariableNamePattern.MatchString(name) || reservedFlowVariableNames[strings.ToLower(name)]:
+			errs = append(errs, fmt.Sprintf("Variable %d: invalid name %q", i+1, name))
+			continue
+		case seen[name]:
+			errs = append(errs, fmt.Sprintf("Variable %s: declared more than once", name))
+			continue
+		}
+		seen[name] = true
+
+		t := flowexpr.Any
+		if typeName, _ := m["type"].(string); typeName != "" {
+			if t, ok = flowexpr.ParseType(typeName); !ok {
+				errs = append(errs, fmt.Sprintf("Variable %s: unknown type %q", name, typeName))
+				continue
+			}
+		}
+		if def := m["default"]; def != nil && def != "" {
+			if _, err := flowexpr.Coerce(def, t); err != nil {
+				errs = append(errs, fmt.Sprintf("Variable %s: default %v", name, err))
+			}
+		}
+		vars[name] = t
+	}
+
+	// Replies and API results are stored without a declared type unless given one
+	stepNames := map[string]bool{}
+	for _, step := range steps {
+		stepNames[step.StepName] = true
+		if step.MessageType == "http_request" {
+			vars[flowHTTPStatusVariable] = flowexpr.Number
+			vars[flowHTTPErrorVariable] = flowexpr.String
+		}
+		if step.StoreAs != "" {
+			if _, ok := vars[step.StoreAs]; !ok {
+				vars[step.StoreAs] = flowexpr.Any
+			}
+			vars[step.StoreAs+"_title"] = flowexpr.Any
+		}
+		if mapping, ok := step.ApiConfig["response_mapping"].(map[string]interface{}); ok {
+			for name := range mapping {
+				if _, ok := vars[name]; !ok {
+					vars[name] = flowexpr.Any
+				}
+			}
+		}
+	}
+
+	errs = append(errs, checkTemplateExpressions("Completion message", flow.CompletionMessage, vars)...)
+
+	for _, step := range steps {
+		prefix := fmt.Sprintf("Step %q", step.StepName)
+
+		// API steps' messages are rendered with the API's response, and HTTP request
+		// steps send none
+		if step.MessageType != "api_fetch" && step.MessageType != "http_request" {
+			errs = append(errs, checkTemplateExpressions(prefix+" message", step.Message, vars)...)
+		}
+		if notes, ok := step.TransferConfig["notes"].(string); ok {
+			errs = append(errs, checkTemplateExpressions(prefix+" transfer notes", notes, vars)...)
+		}
+
+		for i, item := range step.Assignments {
+			m, _ := item.(map[string]interface{})
+			variable, _ := m["variable"].(string)
+			src, _ := m["expr"].(string)
+			where := fmt.Sprintf("%s assignment %d", prefix, i+1)
+
+			if !seen[variable] {
+				errs = append(errs, fmt.Sprintf("%s: %q is not a declared variable", where, variable))
+				continue
+			}
+			got, err := checkFlowExpression(src, vars)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", where, err))
+				continue
+			}
+			if want := vars[variable]; !assignable(want, got) {
+				errs = append(errs, fmt.Sprintf("%s: can't assign a %s to %s, a %s", where, got, variable, want))
+			}
+		}
+
+		if step.MessageType != "condition" {
+			continue
+		}
+		if len(step.Branches) == 0 {
+			errs = append(errs, prefix+": a condition step needs at least one branch")
+		}
+		for i, item := range step.Branches {
+			m, _ := item.(map[string]interface{})
+			src, _ := m["if"].(string)
+			next, _ := m["next"].(string)
+			where := fmt.Sprintf("%s branch %d", prefix, i+1)
+
+			if _, err := checkFlowExpression(src, vars); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", where, err))
+			}
+			if !stepNames[next] {
+				errs = append(errs, fmt.Sprintf("%s: next step %q doesn't exist", where, next))
+			}
+		}
+		if step.NextStep != "" && !stepNames[step.NextStep] {
+			errs = append(errs, fmt.Sprintf("%s: next step %q doesn't exist", prefix, step.NextStep))
+		}
+	}
+
+	return errs
+}
+
+// checkFlowExpression parses and type-checks an expression
+func checkFlowExpression(src string, vars map[string]flowexpr.Type) (flowexpr.Type, error) {
+	expr, err := flowexpr.Parse(src)
+	if err != nil {
+		return "", err
+	}
+	return expr.Check(vars)
+}
+
+// checkTemplateExpressions checks the {{= }} expressions in a template. Loop items
+// are in scope throughout it.
+func checkTemplateExpressions(where, template string, vars map[string]flowexpr.Type) []string {
+	if !strings.Contains(template, "{{=") {
+		return nil
+	}
+
+	scope := vars
+	if loops := forLoopPattern.FindAllStringSubmatch(template, -1); len(loops) > 0 {
+		scope = make(map[string]flowexpr.Type, len(vars)+2*len(loops))
+		for name, t := range vars {
+			scope[name] = t
+		}
+		for _, loop := range loops {
+			scope[loop[1]] = flowexpr.Any
+			scope[loop[1]+"_index"] = flowexpr.Number
+		}
+	}
+
+	var errs []string
+	for _, match := range expressionPattern.FindAllStringSubmatch(template, -1) {
+		if _, err := checkFlowExpression(match[1], scope); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: {{=%s}}: %v", where, match[1], err))
+		}
+	}
+	return errs
+}
+
+// assignable reports whether a value of type got can be stored in a variable of type
+// want. Anything can be stored as text.
+func assignable(want, got flowexpr.Type) bool {
+	switch {
+	case want == flowexpr.Any || want == flowexpr.String || got == flowexpr.Any || got == want:
+		return true
+	case want == flowexpr.Date && got == flowexpr.String:
+		return true
+	}
+	return false
+}
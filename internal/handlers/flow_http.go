@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/flowexpr"
+	"github.com/shridarpatil/whatomate/internal/linkpreview"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+const (
+	// defaultFlowHTTPTimeout and maxFlowHTTPTimeout bound how long an HTTP request step
+	// holds up the flow
+	defaultFlowHTTPTimeout = 10 * time.Second
+	maxFlowHTTPTimeout     = 30 * time.Second
+
+	// maxFlowHTTPResponse caps the response body read, 1MB
+	maxFlowHTTPResponse = 1024 * 1024
+
+	// maxFlowHTTPRedirects is how many redirects a request follows
+	maxFlowHTTPRedirects = 3
+)
+
+// flowHTTPTransport only connects to public addresses, so flows can't reach the
+// server's internal network through URLs built from what contacts send
+var flowHTTPTransport = linkpreview.NewPublicTransport(maxFlowHTTPTimeout)
+
+// Session variables every HTTP request step sets: the response's status code, 0 when
+// there was no response, and why the request failed, empty when it succeeded
+const (
+	flowHTTPStatusVariable = "http_status"
+	flowHTTPErrorVariable  = "http_error"
+)
+
+// runHTTPRequestStep calls the API an http_request step is configured with and
+// stores the response fields it maps in the session. It returns the step to go to:
+// the step's error_step if the request failed and one is set, else the next step.
+func (a *App) runHTTPRequestStep(flow *models.ChatbotFlow, session *models.ChatbotSession, step *models.ChatbotFlowStep) string {
+	status, mapped, err := a.doFlowHTTPRequest(flow, session, step)
+	if err != nil {
+		a.Log.Warn("Flow HTTP request failed", "error", err, "step", step.StepName, "status", status)
+	}
+	next := recordFlowHTTPResult(flow, session, step, status, mapped, err)
+	a.DB.Model(session).Update("session_data", session.SessionData)
+	return next
+}
+
+// recordFlowHTTPResult stores the outcome of an http_request step's request in the
+// session and returns the step to go to
+func recordFlowHTTPResult(flow *models.ChatbotFlow, session *models.ChatbotSession, step *models.ChatbotFlowStep, status int, mapped map[string]interface{}, err error) string {
+	if session.SessionData == nil {
+		session.SessionData = models.JSONB{}
+	}
+
+	session.SessionData[flowHTTPStatusVariable] = status
+	if err != nil {
+		session.SessionData[flowHTTPErrorVariable] = err.Error()
+	} else {
+		session.SessionData[flowHTTPErrorVariable] = ""
+		for name, value := range mapped {
+			session.SessionData[name] = value
+		}
+	}
+
+	if errorStep, _ := step.ApiConfig["error_step"].(string); err != nil && errorStep != "" {
+		return errorStep
+	}
+	return nextStepAfter(flow, step)
+}
+
+// doFlowHTTPRequest makes an HTTP request step's request and returns the response's
+// status code and the fields its response_mapping picks out, converted to the flow's
+// declared variable types. Responses outside 2xx, and fields that can't be converted,
+// are errors.
+func (a *App) doFlowHTTPRequest(flow *models.ChatbotFlow, session *models.ChatbotSession, step *models.ChatbotFlowStep) (int, map[string]interface{}, error) {
+	config := step.ApiConfig
+
+	rawURL, _ := config["url"].(string)
+	if rawURL == "" {
+		return 0, nil, fmt.Errorf("URL is required")
+	}
+	target, err := url.Parse(a.renderFlowText(rawURL, flow, session))
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+		return 0, nil, fmt.Errorf("invalid URL")
+	}
+
+	method := http.MethodGet
+	if m, ok := config["method"].(string); ok && m != "" {
+		method = strings.ToUpper(m)
+	}
+
+	var body io.Reader
+	if b, ok := config["body"].(string); ok && b != "" {
+		body = strings.NewReader(a.renderFlowText(b, flow, session))
+	}
+
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if headers, ok := config["headers"].(map[string]interface{}); ok {
+		for key, value := range headers {
+			if s, ok := value.(string); ok {
+				req.Header.Set(key, a.renderFlowText(s, flow, session))
+			}
+		}
+	}
+
+	// The credential is applied last so headers can't override it
+	var credential *models.FlowCredential
+	if name, _ := config["credential"].(string); name != "" {
+		if credential, err = a.applyFlowCredential(req, session.OrganizationID, name); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	timeout := defaultFlowHTTPTimeout
+	if seconds, ok := config["timeout_seconds"].(float64); ok && seconds > 0 {
+		timeout = min(time.Duration(seconds*float64(time.Second)), maxFlowHTTPTimeout)
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: flowHTTPTransport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFlowHTTPRedirects {
+				return errors.New("too many redirects")
+			}
+			// Redirects copy the request's headers; keep the secret from URLs the
+			// credential doesn't cover
+			if credential != nil && !flowCredentialCovers(credential, req.URL) {
+				removeFlowCredential(req, credential)
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxFlowHTTPResponse))
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	mapped, err := mapFlowHTTPResponse(flow, config, respBody)
+	return resp.StatusCode, mapped, err
+}
+
+// mapFlowHTTPResponse picks out the fields of a response body an HTTP request step's
+// response_mapping names, converted to the flow's declared variable types
+func mapFlowHTTPResponse(flow *models.ChatbotFlow, config models.JSONB, body []byte) (map[string]interface{}, error) {
+	mapping, _ := config["response_mapping"].(map[string]interface{})
+	if len(mapping) == 0 {
+		return nil, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("response is not a JSON object")
+	}
+
+	paths := make(map[string]string, len(mapping))
+	for name, path := range mapping {
+		if p, ok := path.(string); ok {
+			paths[name] = p
+		}
+	}
+
+	types := flowVariableTypes(flow)
+	mapped := extractResponseMapping(data, paths)
+	for name, value := range mapped {
+		v, err := flowexpr.Coerce(value, types[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		mapped[name] = v
+	}
+	return mapped, nil
+}
+
+// applyFlowCredential authenticates a request with one of the organization's flow
+// credentials, refusing URLs outside the credential's base URL so a flow can't send
+// the secret elsewhere. It returns the credential applied.
+func (a *App) applyFlowCredential(req *http.Request, orgID uuid.UUID, name string) (*models.FlowCredential, error) {
+	var credential models.FlowCredential
+	if err := a.DB.Where("organization_id = ? AND name = ?", orgID, name).First(&credential).Error; err != nil {
+		return nil, fmt.Errorf("credential %q not found", name)
+	}
+	if !flowCredentialCovers(&credential, req.URL) {
+		return nil, fmt.Errorf("URL is outside credential %q's base URL", name)
+	}
+
+	switch credential.AuthType {
+	case models.FlowCredentialBearer:
+		req.Header.Set("Authorization", "Bearer "+credential.Secret)
+	case models.FlowCredentialBasic:
+		req.SetBasicAuth(credential.Username, credential.Secret)
+	case models.FlowCredentialHeader:
+		req.Header.Set(credential.HeaderName, credential.Secret)
+	}
+	return &credential, nil
+}
+
+// removeFlowCredential takes the header applyFlowCredential set off a request
+func removeFlowCredential(req *http.Request, credential *models.FlowCredential) {
+	if credential.AuthType == models.FlowCredentialHeader {
+		req.Header.Del(credential.HeaderName)
+	} else {
+		req.Header.Del("Authorization")
+	}
+}
+
+// flowCredentialCovers reports whether u is under the credential's base URL: the same
+// scheme and host, and a path within the base URL's once dot segments are resolved
+func flowCredentialCovers(credential *models.FlowCredential, u *url.URL) bool {
+	base, err := url.Parse(credential.BaseURL)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+		return false
+	}
+	prefix := strings.TrimSuffix(path.Clean("/"+base.Path), "/")
+	p := path.Clean("/" + u.Path)
+	return p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// nextStepAfter returns the step a step leads to when it doesn't branch: its next
+// step, else the step after it
+func nextStepAfter(flow *models.ChatbotFlow, step *models.ChatbotFlowStep) string {
+	if step.NextStep != "" {
+		return step.NextStep
+	}
+	for i := range flow.Steps {
+		if flow.Steps[i].StepName == step.StepName && i+1 < len(flow.Steps) {
+			return flow.Steps[i+1].StepName
+		}
+	}
+	return ""
+}
+
+// validateFlowHTTPSteps checks a flow's HTTP request steps, returning a message for
+// each problem
+func (a *App) validateFlowHTTPSteps(orgID uuid.UUID, steps []models.ChatbotFlowStep) []string {
+	stepNames := map[string]bool{}
+	for _, step := range steps {
+		stepNames[step.StepName] = true
+	}
+
+	var errs []string
+	for _, step := range steps {
+		if step.MessageType != "http_request" {
+			continue
+		}
+		prefix := fmt.Sprintf("Step %q", step.StepName)
+
+		if u, _ := step.ApiConfig["url"].(string); u == "" {
+			errs = append(errs, prefix+": a URL is required")
+		}
+		if errorStep, _ := step.ApiConfig["error_step"].(string); errorStep != "" && !stepNames[errorStep] {
+			errs = append(errs, fmt.Sprintf("%s: error step %q doesn't exist", prefix, errorStep))
+		}
+		if name, _ := step.ApiConfig["credential"].(string); name != "" {
+			var count int64
+			a.DB.Model(&models.FlowCredential{}).Where("organization_id = ? AND name = ?", orgID, name).Count(&count)
+			if count == 0 {
+				errs = append(errs, fmt.Sprintf("%s: credential %q doesn't exist", prefix, name))
+			}
+		}
+	}
+	return errs
+}
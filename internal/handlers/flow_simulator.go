@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxFlowSimulationMessages caps the test messages one simulation takes
+	maxFlowSimulationMessages = 50
+
+	// maxFlowSimulationSteps caps the steps entered in one turn, so flows whose steps
+	// lead back to each other without waiting for input stop
+	maxFlowSimulationSteps = 100
+)
+
+// Simulated flow statuses
+const (
+	flowSimulationWaiting     = "waiting"     // Waiting for input at the current step
+	flowSimulationCompleted   = "completed"   // The flow ran to its end
+	flowSimulationTransferred = "transferred" // A transfer step handed the contact to agents
+	flowSimulationCancelled   = "cancelled"   // The contact sent a cancel keyword
+	flowSimulationExited      = "exited"      // The flow gave up, e.g. after too many invalid buttons
+)
+
+// SimulateFlowRequest is the request body for simulating a flow
+type SimulateFlowRequest struct {
+	Messages      []FlowSimulationInput                 `json:"messages"`
+	SessionData   map[string]interface{}                `json:"session_data"`   // Variables set before the flow starts
+	Steps         []FlowStepRequest                     `json:"steps"`          // Unsaved steps to run instead of the saved ones
+	HTTPResponses map[string]FlowSimulationHTTPResponse `json:"http_responses"` // Keyed by step name
+}
+
+// FlowSimulationInput is a test message from the contact. ButtonID simulates tapping
+// a button.
+type FlowSimulationInput struct {
+	Text     string `json:"text"`
+	ButtonID string `json:"button_id,omitempty"`
+}
+
+// FlowSimulationHTTPResponse stands in for the response to an HTTP request step,
+// which is never called in a simulation
+type FlowSimulationHTTPResponse struct {
+	Status int         `json:"status"` // 0 means 200
+	Body   interface{} `json:"body"`
+}
+
+// FlowSimulationTurn is what the flow did at its start or in response to one test message
+type FlowSimulationTurn struct {
+	Input       *FlowSimulationInput  `json:"input"` // nil for the flow's start
+	Events      []FlowSimulationEvent `json:"events"`
+	Status      string                `json:"status"`
+	CurrentStep string                `json:"current_step"`
+	SessionData models.JSONB          `json:"session_data"`
+}
+
+// FlowSimulationEvent is a message the bot would send or a step it moved through.
+// Types: step (entered), skip, branch, http_request, message, buttons, retry,
+// transfer, webhook, complete, cancel, exit, error.
+type FlowSimulationEvent struct {
+	Type     string                   `json:"type"`
+	Step     string                   `json:"step,omitempty"`
+	Text     string                   `json:"text,omitempty"`
+	Buttons  []map[string]interface{} `json:"buttons,omitempty"`
+	NextStep string                   `json:"next_step,omitempty"`
+	Detail   map[string]interface{}   `json:"detail,omitempty"`
+	Error    string                   `json:"error,omitempty"`
+}
+
+// SimulateChatbotFlow runs a flow against test messages without sending anything or
+// saving a session, returning what the bot would send and the steps it moves through
+// for the start and each message. Messages after the flow ends are ignored.
+func (a *App) SimulateChatbotFlow(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flow ID", nil, "")
+	}
+
+	var req SimulateFlowRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+	if len(req.Messages) > maxFlowSimulationMessages {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("A simulation can take at most %d messages", maxFlowSimulationMessages), nil, "")
+	}
+
+	var flow models.ChatbotFlow
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("step_order ASC")
+		}).
+		First(&flow).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
+	}
+	if len(req.Steps) > 0 {
+		flow.Steps = flowStepsFromRequest(flow.ID, req.Steps)
+	}
+
+	sim := &flowSimulation{
+		app:       a,
+		flow:      &flow,
+		session:   &models.ChatbotSession{OrganizationID: orgID, CurrentFlowID: &flow.ID},
+		responses: req.HTTPResponses,
+	}
+
+	turns := []FlowSimulationTurn{sim.turn(nil, func() { sim.start(req.SessionData) })}
+	for i := range req.Messages {
+		if sim.status != flowSimulationWaiting {
+			break
+		}
+		input := req.Messages[i]
+		turns = append(turns, sim.turn(&input, func() { sim.respond(input.Text, input.ButtonID) }))
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"status":       sim.status,
+		"current_step": sim.session.CurrentStep,
+		"session_data": sim.session.SessionData,
+		"turns":        turns,
+	})
+}
+
+// flowSimulation runs a flow in memory the way the chatbot processor runs it for a
+// session, recording what it would send instead of sending it
+type flowSimulation struct {
+	app       *App
+	flow      *models.ChatbotFlow
+	session   *models.ChatbotSession
+	responses map[string]FlowSimulationHTTPResponse
+	status    string
+	events    []FlowSimulationEvent
+	entered   int // Steps entered this turn
+}
+
+// turn runs fn and returns the events it recorded and the state it left
+func (s *flowSimulation) turn(input *FlowSimulationInput, fn func()) FlowSimulationTurn {
+	s.events = []FlowSimulationEvent{}
+	s.entered = 0
+	fn()
+	return FlowSimulationTurn{
+		Input:       input,
+		Events:      s.events,
+		Status:      s.status,
+		CurrentStep: s.session.CurrentStep,
+		SessionData: maps.Clone(s.session.SessionData),
+	}
+}
+
+func (s *flowSimulation) record(event FlowSimulationEvent) {
+	s.events = append(s.events, event)
+}
+
+// start starts the flow with the given variables set, as startFlow does
+func (s *flowSimulation) start(data map[string]interface{}) {
+	s.status = flowSimulationWaiting
+	s.session.SessionData = flowVariableDefaults(s.flow)
+	maps.Copy(s.session.SessionData, data)
+
+	if s.flow.InitialMessage != "" {
+		s.record(FlowSimulationEvent{Type: "message", Step: "flow_start", Text: s.flow.InitialMessage})
+	}
+	if len(s.flow.Steps) == 0 {
+		s.complete()
+		return
+	}
+	s.enter(&s.flow.Steps[0], nil)
+}
+
+// respond handles a message from the contact at the current step, as
+// processFlowResponse does
+func (s *flowSimulation) respond(userInput, buttonID string) {
+	userInputLower := strings.ToLower(userInput)
+	for _, cancelKw := range s.flow.CancelKeywords {
+		if strings.Contains(userInputLower, strings.ToLower(cancelKw)) {
+			s.record(FlowSimulationEvent{Type: "message", Step: "flow_cancel", Text: "Flow cancelled."})
+			s.record(FlowSimulationEvent{Type: "cancel", Detail: map[string]interface{}{"keyword": cancelKw}})
+			s.end(flowSimulationCancelled)
+			return
+		}
+	}
+
+	step := findFlowStep(s.flow, s.session.CurrentStep)
+	if step == nil {
+		s.record(FlowSimulationEvent{Type: "error", Error: fmt.Sprintf("current step %q not found", s.session.CurrentStep)})
+		s.end(flowSimulationExited)
+		return
+	}
+
+	if step.ValidationRegex != "" && buttonID == "" {
+		re, err := regexp.Compile(step.ValidationRegex)
+		if err == nil && !re.MatchString(userInput) && s.retry(step) {
+			return
+		}
+	}
+
+	if len(step.Buttons) > 0 && (step.InputType == "button" || step.InputType == "select" || buttonID != "") {
+		id, ok := matchFlowButton(step, userInput, buttonID)
+		if !ok {
+			s.session.StepRetries++
+			maxRetries := step.MaxRetries
+			if maxRetries == 0 {
+				maxRetries = 3
+			}
+			if s.session.StepRetries >= maxRetries {
+				s.record(FlowSimulationEvent{Type: "message", Step: step.StepName, Text: "Sorry, we couldn't continue. Please try again later."})
+				s.record(FlowSimulationEvent{Type: "exit", Step: step.StepName, Error: "too many invalid button replies"})
+				s.end(flowSimulationExited)
+				return
+			}
+			s.record(FlowSimulationEvent{Type: "retry", Step: step.StepName, Error: "reply doesn't match a button"})
+			s.sendStep(step)
+			return
+		}
+		buttonID = id
+	}
+
+	if step.StoreAs != "" {
+		if buttonID != "" {
+			s.session.SessionData[step.StoreAs] = buttonID
+			if value, err := coerceStoredInput(s.flow, step.StoreAs, buttonID); err == nil {
+				s.session.SessionData[step.StoreAs] = value
+			}
+			s.session.SessionData[step.StoreAs+"_title"] = userInput
+		} else {
+			value, err := coerceStoredInput(s.flow, step.StoreAs, userInput)
+			if err != nil {
+				if s.retry(step) {
+					return
+				}
+				value = userInput
+			}
+			s.session.SessionData[step.StoreAs] = value
+		}
+	}
+
+	next := nextStepAfter(s.flow, step)
+	if len(step.ConditionalNext) > 0 {
+		if n, ok := step.ConditionalNext[buttonID].(string); ok && buttonID != "" {
+			next = n
+		} else if n, ok := step.ConditionalNext[userInput].(string); ok {
+			next = n
+		} else if n, ok := step.ConditionalNext["default"].(string); ok {
+			next = n
+		}
+	}
+
+	s.session.StepRetries = 0
+	s.goTo(step, next, nil)
+}
+
+// retry asks for the step's input again after invalid input, as retryInvalidInput
+// does, returning false once the step's retries are used up
+func (s *flowSimulation) retry(step *models.ChatbotFlowStep) bool {
+	s.session.StepRetries++
+	if step.RetryOnInvalid && s.session.StepRetries < step.MaxRetries {
+		msg := step.ValidationError
+		if msg == "" {
+			msg = "Invalid input. Please try again."
+		}
+		s.record(FlowSimulationEvent{Type: "retry", Step: step.StepName, Text: msg})
+		return true
+	}
+	return false
+}
+
+// enter moves the flow to a step, as sendStepWithSkipCheck does: skipping it,
+// branching past it, or sending its message and, unless it takes no input,
+// waiting for a reply
+func (s *flowSimulation) enter(step *models.ChatbotFlowStep, skipped map[string]bool) {
+	if skipped == nil {
+		skipped = make(map[string]bool)
+	}
+	s.entered++
+	if skipped[step.StepName] || s.entered > maxFlowSimulationSteps {
+		s.record(FlowSimulationEvent{Type: "error", Step: step.StepName, Error: "the flow loops back to this step without waiting for input"})
+		s.complete()
+		return
+	}
+
+	s.session.CurrentStep = step.StepName
+	s.record(FlowSimulationEvent{Type: "step", Step: step.StepName, Detail: map[string]interface{}{"message_type": step.MessageType}})
+
+	if s.app.shouldSkipStep(step, s.session.SessionData) {
+		skipped[step.StepName] = true
+		next := nextStepAfter(s.flow, step)
+		s.record(FlowSimulationEvent{Type: "skip", Step: step.StepName, NextStep: next, Detail: map[string]interface{}{"condition": step.SkipCondition}})
+		s.goTo(step, next, skipped)
+		return
+	}
+
+	s.app.assignStepVariables(s.flow, s.session, step)
+
+	switch step.MessageType {
+	case "condition":
+		skipped[step.StepName] = true
+		next := s.app.conditionNextStep(s.flow, s.session, step)
+		s.record(FlowSimulationEvent{Type: "branch", Step: step.StepName, NextStep: next})
+		s.goTo(step, next, skipped)
+		return
+	case "http_request":
+		skipped[step.StepName] = true
+		next := s.httpRequest(step)
+		s.goTo(step, next, skipped)
+		return
+	}
+
+	s.sendStep(step)
+	if s.status != flowSimulationWaiting {
+		return
+	}
+	if step.InputType == "none" {
+		s.goTo(step, nextStepAfter(s.flow, step), skipped)
+	}
+}
+
+// goTo enters the named step, completing the flow if there is none
+func (s *flowSimulation) goTo(from *models.ChatbotFlowStep, name string, skipped map[string]bool) {
+	if name == "" {
+		s.complete()
+		return
+	}
+	next := findFlowStep(s.flow, name)
+	if next == nil {
+		s.record(FlowSimulationEvent{Type: "error", Step: from.StepName, Error: fmt.Sprintf("next step %q not found", name)})
+		s.complete()
+		return
+	}
+	s.enter(next, skipped)
+}
+
+// sendStep records the message a step sends, as sendStepMessage does
+func (s *flowSimulation) sendStep(step *models.ChatbotFlowStep) {
+	switch step.MessageType {
+	case "api_fetch":
+		// Sent as it would be if the API failed
+		text := "Sorry, there was an error processing your request."
+		if fallback, ok := step.ApiConfig["fallback_message"].(string); ok && fallback != "" {
+			text = processTemplate(fallback, s.session.SessionData)
+		} else if step.Message != "" {
+			text = processTemplate(step.Message, s.session.SessionData)
+		}
+		s.record(FlowSimulationEvent{Type: "message", Step: step.StepName, Text: text, Error: "API fetch steps aren't called in a simulation"})
+
+	case "buttons":
+		text := s.app.renderFlowText(step.Message, s.flow, s.session)
+		buttons := flowStepButtons(step)
+		if len(buttons) == 0 {
+			s.record(FlowSimulationEvent{Type: "message", Step: step.StepName, Text: text})
+			return
+		}
+		s.record(FlowSimulationEvent{Type: "buttons", Step: step.StepName, Text: text, Buttons: buttons})
+
+	case "transfer":
+		if text := s.app.renderFlowText(step.Message, s.flow, s.session); text != "" {
+			s.record(FlowSimulationEvent{Type: "message", Step: step.StepName, Text: text})
+		}
+		detail := map[string]interface{}{"team_id": "", "notes": ""}
+		if step.TransferConfig != nil {
+			if teamID, ok := step.TransferConfig["team_id"].(string); ok && teamID != "_general" {
+				detail["team_id"] = teamID
+			}
+			if notes, ok := step.TransferConfig["notes"].(string); ok {
+				detail["notes"] = s.app.renderFlowText(notes, s.flow, s.session)
+			}
+		}
+		s.record(FlowSimulationEvent{Type: "transfer", Step: step.StepName, Detail: detail})
+		s.end(flowSimulationTransferred)
+
+	default:
+		s.record(FlowSimulationEvent{Type: "message", Step: step.StepName, Text: s.app.renderFlowText(step.Message, s.flow, s.session)})
+	}
+}
+
+// httpRequest answers an http_request step with the response given for it in the
+// request, or an empty 200 response, and returns the step to go to
+func (s *flowSimulation) httpRequest(step *models.ChatbotFlowStep) string {
+	resp, mocked := s.responses[step.StepName]
+	status := resp.Status
+	if status == 0 {
+		status = 200
+	}
+
+	var mapped map[string]interface{}
+	var err error
+	if status < 200 || status >= 300 {
+		err = fmt.Errorf("API returned status %d", status)
+	} else {
+		body, _ := json.Marshal(resp.Body)
+		mapped, err = mapFlowHTTPResponse(s.flow, step.ApiConfig, body)
+	}
+	next := recordFlowHTTPResult(s.flow, s.session, step, status, mapped, err)
+
+	rawURL, _ := step.ApiConfig["url"].(string)
+	method, _ := step.ApiConfig["method"].(string)
+	if method == "" {
+		method = "GET"
+	}
+	event := FlowSimulationEvent{
+		Type:     "http_request",
+		Step:     step.StepName,
+		NextStep: next,
+		Detail: map[string]interface{}{
+			"method": strings.ToUpper(method),
+			"url":    s.app.renderFlowText(rawURL, s.flow, s.session),
+			"status": status,
+			"mocked": mocked,
+		},
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.record(event)
+	return next
+}
+
+// complete finishes the flow, as completeFlow does
+func (s *flowSimulation) complete() {
+	if s.flow.CompletionMessage != "" {
+		s.record(FlowSimulationEvent{Type: "message", Step: "flow_complete", Text: s.app.renderFlowText(s.flow.CompletionMessage, s.flow, s.session)})
+	}
+	if s.flow.OnCompleteAction == "webhook" && len(s.flow.CompletionConfig) > 0 {
+		url, _ := s.flow.CompletionConfig["url"].(string)
+		s.record(FlowSimulationEvent{Type: "webhook", Detail: map[string]interface{}{"url": s.app.replaceVariables(url, s.session.SessionData)}, Error: "webhooks aren't sent in a simulation"})
+	}
+	s.record(FlowSimulationEvent{Type: "complete"})
+	s.end(flowSimulationCompleted)
+}
+
+// end stops the flow with a final status
+func (s *flowSimulation) end(status string) {
+	s.status = status
+	s.session.CurrentStep = ""
+}
+
+// findFlowStep returns the flow's step with the name, or nil
+func findFlowStep(flow *models.ChatbotFlow, name string) *models.ChatbotFlowStep {
+	for i := range flow.Steps {
+		if flow.Steps[i].StepName == name {
+			return &flow.Steps[i]
+		}
+	}
+	return nil
+}
+
+// flowStepButtons returns the buttons a step sends, with the IDs
+// sendInteractiveButtons gives buttons that have none
+func flowStepButtons(step *models.ChatbotFlowStep) []map[string]interface{} {
+	buttons := make([]map[string]interface{}, 0, len(step.Buttons))
+	for i, btn := range step.Buttons {
+		m, ok := btn.(map[string]interface{})
+		if !ok || i >= 10 {
+			continue
+		}
+		id, _ := m["id"].(string)
+		title, _ := m["title"].(string)
+		if title == "" {
+			continue
+		}
+		if id == "" {
+			id = fmt.Sprintf("btn_%d", i+1)
+		}
+		buttons = append(buttons, map[string]interface{}{"id": id, "title": title})
+	}
+	return buttons
+}
+
+// matchFlowButton finds the step's button a reply picks, by ID or by title, as
+// processFlowResponse does, and returns its ID
+func matchFlowButton(step *models.ChatbotFlowStep, userInput, buttonID string) (string, bool) {
+	userInputLower := strings.ToLower(userInput)
+	for i, btn := range step.Buttons {
+		m, ok := btn.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		id, _ := m["id"].(string)
+		title, _ := m["title"].(string)
+		if id == "" {
+			id = fmt.Sprintf("btn_%d", i+1)
+		}
+		if buttonID != "" && buttonID == id {
+			return id, true
+		}
+		if strings.ToLower(title) == userInputLower || id == userInput {
+			if buttonID == "" {
+				return id, true
+			}
+			return buttonID, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// flowVersionCachePrefix caches published flow versions, which never change
+const flowVersionCachePrefix = "chatbot:flow_version:"
+
+// ChatbotFlowVersionResponse represents a published version of a flow for API response
+type ChatbotFlowVersionResponse struct {
+	Version       int        `json:"version"`
+	Notes         string     `json:"notes"`
+	PublishedByID *uuid.UUID `json:"published_by_id,omitempty"`
+	PublishedAt   string     `json:"published_at"`
+	IsPublished   bool       `json:"is_published"` // The version new sessions start on
+}
+
+// PublishChatbotFlow publishes the flow as edited as its next version. New sessions
+// start on it; sessions already in the flow carry on with the version they started on.
+func (a *App) PublishChatbotFlow(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := a.getUserIDFromContext(r)
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flow ID", nil, "")
+	}
+
+	var req struct {
+		Notes string `json:"notes"`
+	}
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+
+	var flow models.ChatbotFlow
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("step_order ASC")
+		}).
+		First(&flow).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
+	}
+
+	errs := append(validateFlowExpressions(&flow, flow.Steps), a.validateFlowHTTPSteps(orgID, flow.Steps)...)
+	if len(errs) > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusUnprocessableEntity, "Fix the flow's errors before publishing it", map[string]interface{}{"errors": errs}, "")
+	}
+
+	definition, err := flowDefinition(&flow)
+	if err != nil {
+		a.Log.Error("Failed to snapshot flow", "error", err, "flow_id", id)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to publish flow", nil, "")
+	}
+
+	version := models.ChatbotFlowVersion{
+		OrganizationID: orgID,
+		FlowID:         id,
+		Definition:     definition,
+		Notes:          req.Notes,
+	}
+	if userID != uuid.Nil {
+		version.PublishedByID = &userID
+	}
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.ChatbotFlowVersion{}).Where("flow_id = ?", id).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		version.Version = latest + 1
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		return tx.Model(&flow).Update("published_version", version.Version).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to publish flow", "error", err, "flow_id", id)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to publish flow", nil, "")
+	}
+
+	a.InvalidateChatbotFlowsCache(orgID)
+	a.Log.Info("Flow published", "flow_id", id, "version", version.Version)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"version": version.Version,
+		"message": "Flow published successfully",
+	})
+}
+
+// ListChatbotFlowVersions lists a flow's published versions, newest first
+func (a *App) ListChatbotFlowVersions(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flow ID", nil, "")
+	}
+
+	var flow models.ChatbotFlow
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&flow).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
+	}
+
+	var versions []models.ChatbotFlowVersion
+	if err := a.DB.Select("version", "notes", "published_by_id", "created_at").
+		Where("flow_id = ?", id).
+		Order("version DESC").
+		Find(&versions).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to fetch flow versions", nil, "")
+	}
+
+	response := make([]ChatbotFlowVersionResponse, len(versions))
+	for i, v := range versions {
+		response[i] = ChatbotFlowVersionResponse{
+			Version:       v.Version,
+			Notes:         v.Notes,
+			PublishedByID: v.PublishedByID,
+			PublishedAt:   v.CreatedAt.Format(time.RFC3339),
+			IsPublished:   v.Version == flow.PublishedVersion,
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"versions":          response,
+		"published_version": flow.PublishedVersion,
+	})
+}
+
+// RollbackChatbotFlow makes an earlier published version the one new sessions start
+// on, by default the one before the current. The flow as edited is left alone.
+func (a *App) RollbackChatbotFlow(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid flow ID", nil, "")
+	}
+
+	var req struct {
+		Version *int `json:"version"`
+	}
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+
+	var flow models.ChatbotFlow
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&flow).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow not found", nil, "")
+	}
+
+	var target models.ChatbotFlowVersion
+	query := a.DB.Select("version").Where("flow_id = ?", id)
+	if req.Version != nil {
+		query = query.Where("version = ?", *req.Version)
+	} else {
+		query = query.Where("version < ?", flow.PublishedVersion).Order("version DESC")
+	}
+	if err := query.First(&target).Error; err != nil {
+		if req.Version != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Flow version not found", nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No earlier version to roll back to", nil, "")
+	}
+
+	if err := a.DB.Model(&flow).Update("published_version", target.Version).Error; err != nil {
+		a.Log.Error("Failed to roll back flow", "error", err, "flow_id", id)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to roll back flow", nil, "")
+	}
+
+	a.InvalidateChatbotFlowsCache(orgID)
+	a.Log.Info("Flow rolled back", "flow_id", id, "from_version", flow.PublishedVersion, "to_version", target.Version)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"version": target.Version,
+		"message": fmt.Sprintf("Flow rolled back to version %d", target.Version),
+	})
+}
+
+// flowDefinition snapshots a flow and its steps for a published version
+func flowDefinition(flow *models.ChatbotFlow) (models.JSONB, error) {
+	data, err := json.Marshal(flow)
+	if err != nil {
+		return nil, err
+	}
+	var definition models.JSONB
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return nil, err
+	}
+	return definition, nil
+}
+
+// publishedFlow returns the flow as published at version. The flow's own state, such
+// as whether it is enabled, is kept from flow.
+func publishedFlow(flow *models.ChatbotFlow, version *models.ChatbotFlowVersion) (*models.ChatbotFlow, error) {
+	data, err := json.Marshal(version.Definition)
+	if err != nil {
+		return nil, err
+	}
+	var published models.ChatbotFlow
+	if err := json.Unmarshal(data, &published); err != nil {
+		return nil, err
+	}
+	published.BaseModel = flow.BaseModel
+	published.OrganizationID = flow.OrganizationID
+	published.IsEnabled = flow.IsEnabled
+	published.PublishedVersion = version.Version
+	return &published, nil
+}
+
+// applyPublishedFlows replaces each published flow with the version new sessions
+// start on. Flows never published run as edited.
+func (a *App) applyPublishedFlows(flows []models.ChatbotFlow) error {
+	var keys [][]interface{}
+	for _, flow := range flows {
+		if flow.PublishedVersion > 0 {
+			keys = append(keys, []interface{}{flow.ID, flow.PublishedVersion})
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	var versions []models.ChatbotFlowVersion
+	if err := a.DB.Where("(flow_id, version) IN ?", keys).Find(&versions).Error; err != nil {
+		return err
+	}
+	byFlow := make(map[uuid.UUID]*models.ChatbotFlowVersion, len(versions))
+	for i := range versions {
+		byFlow[versions[i].FlowID] = &versions[i]
+	}
+
+	for i := range flows {
+		version, ok := byFlow[flows[i].ID]
+		if !ok {
+			continue
+		}
+		published, err := publishedFlow(&flows[i], version)
+		if err != nil {
+			return err
+		}
+		flows[i] = *published
+	}
+	return nil
+}
+
+// getSessionFlow returns the flow a session is in, at the version the session started
+// on. Sessions started before the flow was published continue on the published version.
+func (a *App) getSessionFlow(session *models.ChatbotSession) (*models.ChatbotFlow, error) {
+	flow, err := a.getChatbotFlowByIDCached(session.OrganizationID, *session.CurrentFlowID)
+	if err != nil || session.FlowVersion == 0 || session.FlowVersion == flow.PublishedVersion {
+		return flow, err
+	}
+
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("%s%s:%d", flowVersionCachePrefix, flow.ID.String(), session.FlowVersion)
+
+	var version models.ChatbotFlowVersion
+	if cached, err := a.Redis.Get(ctx, cacheKey).Result(); err == nil && cached != "" {
+		if err := json.Unmarshal([]byte(cached), &version); err == nil {
+			return publishedFlow(flow, &version)
+		}
+	}
+
+	if err := a.DB.Where("flow_id = ? AND version = ?", flow.ID, session.FlowVersion).First(&version).Error; err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(version); err == nil {
+		a.Redis.Set(ctx, cacheKey, data, flowsCacheTTL)
+	}
+	return publishedFlow(flow, &version)
+}
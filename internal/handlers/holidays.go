@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/holidays"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// HolidayCalendarRequest represents the request body for creating/updating a holiday calendar
+type HolidayCalendarRequest struct {
+	Name     string `json:"name"`
+	Region   string `json:"region"`
+	URL      string `json:"url"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// BlackoutDateRequest represents the request body for adding a manual blackout date
+type BlackoutDateRequest struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	Name string `json:"name"`
+}
+
+// BlackoutDateResponse represents a campaign blackout date in API responses
+type BlackoutDateResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Date       string     `json:"date"`
+	Name       string     `json:"name"`
+	Region     string     `json:"region"`
+	CalendarID *uuid.UUID `json:"calendar_id,omitempty"`
+	Source     string     `json:"source"` // calendar, manual
+}
+
+// ListHolidayCalendars returns the organization's imported holiday calendars
+func (a *App) ListHolidayCalendars(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var calendars []models.HolidayCalendar
+	if err := a.DB.Where("organization_id = ?", orgID).Order("name ASC").Find(&calendars).Error; err != nil {
+		a.Log.Error("Failed to list holiday calendars", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list holiday calendars", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"calendars": calendars,
+	})
+}
+
+// CreateHolidayCalendar adds an iCal holiday feed and imports it immediately
+func (a *App) CreateHolidayCalendar(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req HolidayCalendarRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.Name == "" || req.URL == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name and url are required", nil, "")
+	}
+	feedURL, err := holidays.FeedURL(req.URL)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	calendar := models.HolidayCalendar{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Region:         req.Region,
+		URL:            feedURL,
+		IsActive:       true,
+	}
+	if err := a.DB.Create(&calendar).Error; err != nil {
+		a.Log.Error("Failed to create holiday calendar", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create holiday calendar", nil, "")
+	}
+
+	// Import now so the dates are visible right away; failures are recorded on the calendar
+	if _, err := holidays.Sync(context.Background(), a.DB, &calendar); err != nil {
+		a.Log.Warn("Initial holiday calendar import failed", "error", err, "calendar_id", calendar.ID)
+	}
+	a.DB.First(&calendar, calendar.ID)
+
+	return r.SendEnvelope(calendar)
+}
+
+// UpdateHolidayCalendar updates a holiday calendar
+func (a *App) UpdateHolidayCalendar(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	calendar, err := a.findHolidayCalendar(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Holiday calendar not found", nil, "")
+	}
+
+	var req HolidayCalendarRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Region != "" {
+		updates["region"] = req.Region
+	}
+	if req.URL != "" {
+		feedURL, err := holidays.FeedURL(req.URL)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		updates["url"] = feedURL
+		// Force a refresh with the new feed on the next worker run
+		updates["last_synced_at"] = nil
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+		if *req.IsActive && !calendar.IsActive {
+			updates["last_synced_at"] = nil
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := a.DB.Model(calendar).Updates(updates).Error; err != nil {
+			a.Log.Error("Failed to update holiday calendar", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update holiday calendar", nil, "")
+		}
+	}
+
+	// A disabled calendar no longer blocks campaigns
+	if req.IsActive != nil && !*req.IsActive {
+		a.DB.Unscoped().Where("calendar_id = ?", calendar.ID).Delete(&models.CampaignBlackoutDate{})
+		a.DB.Model(calendar).Update("event_count", 0)
+	}
+
+	a.DB.First(calendar, calendar.ID)
+	return r.SendEnvelope(calendar)
+}
+
+// SyncHolidayCalendar re-imports a holiday calendar immediately
+func (a *App) SyncHolidayCalendar(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	calendar, err := a.findHolidayCalendar(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Holiday calendar not found", nil, "")
+	}
+
+	count, err := holidays.Sync(context.Background(), a.DB, calendar)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to import calendar: "+err.Error(), nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":     "Calendar imported",
+		"event_count": count,
+	})
+}
+
+// DeleteHolidayCalendar removes a holiday calendar and the blackout dates it created
+func (a *App) DeleteHolidayCalendar(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	calendar, err := a.findHolidayCalendar(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Holiday calendar not found", nil, "")
+	}
+
+	a.DB.Unscoped().Where("calendar_id = ?", calendar.ID).Delete(&models.CampaignBlackoutDate{})
+	if err := a.DB.Delete(calendar).Error; err != nil {
+		a.Log.Error("Failed to delete holiday calendar", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete holiday calendar", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{
+		"message": "Holiday calendar deleted",
+	})
+}
+
+// ListBlackoutDates returns campaign blackout dates, optionally within ?from=&to= (YYYY-MM-DD)
+func (a *App) ListBlackoutDates(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if from := string(r.RequestCtx.QueryArgs().Peek("from")); from != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid from date", nil, "")
+		}
+		query = query.Where("date >= ?", from)
+	} else {
+		query = query.Where("date >= ?", time.Now().AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	if to := string(r.RequestCtx.QueryArgs().Peek("to")); to != "" {
+		if _, err := time.Parse("2006-01-02", to); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid to date", nil, "")
+		}
+		query = query.Where("date <= ?", to)
+	}
+
+	var dates []models.CampaignBlackoutDate
+	if err := query.Order("date ASC").Find(&dates).Error; err != nil {
+		a.Log.Error("Failed to list blackout dates", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list blackout dates", nil, "")
+	}
+
+	result := make([]BlackoutDateResponse, len(dates))
+	for i, d := range dates {
+		result[i] = blackoutDateToResponse(d)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"blackout_dates": result,
+	})
+}
+
+// CreateBlackoutDate adds a manual campaign blackout date
+func (a *App) CreateBlackoutDate(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req BlackoutDateRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "date must be in YYYY-MM-DD format", nil, "")
+	}
+
+	blackout := models.CampaignBlackoutDate{
+		OrganizationID: orgID,
+		Date:           date,
+		Name:           req.Name,
+	}
+	if err := a.DB.Create(&blackout).Error; err != nil {
+		a.Log.Error("Failed to create blackout date", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create blackout date", nil, "")
+	}
+
+	return r.SendEnvelope(blackoutDateToResponse(blackout))
+}
+
+// DeleteBlackoutDate removes a blackout date
+func (a *App) DeleteBlackoutDate(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid ID", nil, "")
+	}
+
+	result := a.DB.Unscoped().Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.CampaignBlackoutDate{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete blackout date", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete blackout date", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Blackout date not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{
+		"message": "Blackout date deleted",
+	})
+}
+
+// findHolidayCalendar loads the organization's calendar named by the {id} path parameter
+func (a *App) findHolidayCalendar(r *fastglue.Request, orgID uuid.UUID) (*models.HolidayCalendar, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendar models.HolidayCalendar
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&calendar).Error; err != nil {
+		return nil, err
+	}
+	return &calendar, nil
+}
+
+// campaignBlackout reports whether campaigns for the organization are blocked today in its timezone
+func (a *App) campaignBlackout(orgID uuid.UUID) *holidays.Blackout {
+	timezone := ""
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err == nil {
+		timezone, _ = org.Settings["timezone"].(string)
+	}
+
+	blackout, err := holidays.Check(a.DB, orgID, holidays.Today(timezone))
+	if err != nil {
+		a.Log.Error("Failed to check campaign blackout dates", "error", err, "organization_id", orgID)
+		return nil
+	}
+	return blackout
+}
+
+// campaignHold returns the hold the organization's quiet hours and blackout dates put
+// on its campaigns now, or nil if they may send
+func (a *App) campaignHold(orgID uuid.UUID) *holidays.Hold {
+	hold, err := holidays.CheckHold(a.DB, orgID, a.orgSettings(orgID), time.Now())
+	if err != nil {
+		a.Log.Error("Failed to check campaign quiet hours and blackout dates", "error", err, "organization_id", orgID)
+		return nil
+	}
+	return hold
+}
+
+func blackoutDateToResponse(d models.CampaignBlackoutDate) BlackoutDateResponse {
+	source := "manual"
+	if d.CalendarID != nil {
+		source = "calendar"
+	}
+	return BlackoutDateResponse{
+		ID:         d.ID,
+		Date:       d.Date.Format("2006-01-02"),
+		Name:       d.Name,
+		Region:     d.Region,
+		CalendarID: d.CalendarID,
+		Source:     source,
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/i18n"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// LocaleRequest is the request body for changing the current user's locale
+type LocaleRequest struct {
+	Locale string `json:"locale"`
+}
+
+// GetLocaleStrings returns localized enum labels and error messages.
+// Query params: locale (defaults to the Accept-Language header)
+func (a *App) GetLocaleStrings(r *fastglue.Request) error {
+	locale := i18n.Normalize(string(r.RequestCtx.QueryArgs().Peek("locale")))
+	if locale == "" {
+		locale = i18n.FromAcceptLanguage(string(r.RequestCtx.Request.Header.Peek("Accept-Language")))
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"locale":    locale,
+		"supported": i18n.Supported(),
+		"strings":   i18n.Strings(locale),
+	})
+}
+
+// UpdateCurrentUserLocale sets the current user's preferred locale
+func (a *App) UpdateCurrentUserLocale(r *fastglue.Request) error {
+	userID, ok := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req LocaleRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	locale := i18n.Normalize(req.Locale)
+	if locale == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unsupported locale", i18n.Supported(), "")
+	}
+
+	if err := a.DB.Model(&models.User{}).Where("id = ?", userID).Update("locale", locale).Error; err != nil {
+		a.Log.Error("Failed to update user locale", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update locale", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{
+		"message": "Locale updated successfully",
+		"locale":  locale,
+	})
+}
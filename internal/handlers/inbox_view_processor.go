@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+)
+
+// InboxViewCountProcessor pushes the counts of subscribed inbox views to users
+// connected to this server whenever they change. Every server runs it for its own
+// WebSocket clients, so it takes no lock.
+type InboxViewCountProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+
+	// last holds the counts last pushed, by user and view
+	last map[uuid.UUID]map[uuid.UUID]InboxViewCount
+}
+
+// NewInboxViewCountProcessor creates a new inbox view count processor
+func NewInboxViewCountProcessor(app *App, interval time.Duration) *InboxViewCountProcessor {
+	return &InboxViewCountProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		last:     make(map[uuid.UUID]map[uuid.UUID]InboxViewCount),
+	}
+}
+
+// Start begins the inbox view count loop
+func (p *InboxViewCountProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Inbox view count processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Inbox view count processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Inbox view count processor stopped")
+			return
+		case <-ticker.C:
+			p.pushCounts()
+		}
+	}
+}
+
+// Stop stops the inbox view count processor
+func (p *InboxViewCountProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// pushCounts recounts the subscribed views of connected users and sends each user the
+// views whose counts changed
+func (p *InboxViewCountProcessor) pushCounts() {
+	if p.app.WSHub == nil {
+		return
+	}
+
+	var users []models.User
+	if err := p.app.DB.Select("id", "organization_id", "role").
+		Where("id IN (?)", p.app.DB.Model(&models.InboxViewSubscription{}).Select("user_id")).
+		Where("is_active = ?", true).
+		Find(&users).Error; err != nil {
+		p.app.Log.Error("Failed to load inbox view subscribers", "error", err)
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(users))
+	for _, user := range users {
+		if !p.app.WSHub.IsUserConnected(user.OrganizationID, user.ID) {
+			continue
+		}
+		seen[user.ID] = true
+
+		views, err := p.app.subscribedInboxViews(user.OrganizationID, user.ID)
+		if err != nil {
+			p.app.Log.Error("Failed to load subscribed inbox views", "error", err, "user_id", user.ID)
+			continue
+		}
+
+		last := p.last[user.ID]
+		current := make(map[uuid.UUID]InboxViewCount, len(views))
+		changed := []InboxViewCount{}
+		for i := range views {
+			count := p.app.inboxViewCount(&views[i], user.ID, user.Role)
+			current[count.ViewID] = count
+			if previous, ok := last[count.ViewID]; !ok || previous != count {
+				changed = append(changed, count)
+			}
+		}
+		p.last[user.ID] = current
+		if len(changed) == 0 {
+			continue
+		}
+
+		p.app.WSHub.BroadcastToUsers(user.OrganizationID, []uuid.UUID{user.ID}, websocket.WSMessage{
+			Type:    websocket.TypeInboxViewCounts,
+			Payload: map[string]any{"views": changed},
+		})
+	}
+
+	// Users who disconnected get every count again when they're back
+	for userID := range p.last {
+		if !seen[userID] {
+			delete(p.last, userID)
+		}
+	}
+}
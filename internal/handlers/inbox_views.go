@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxInboxViewLabels caps the labels one view filters on
+const maxInboxViewLabels = 20
+
+// InboxViewRequest is the request body for creating or updating an inbox view
+type InboxViewRequest struct {
+	Name            string   `json:"name"`
+	TeamID          *string  `json:"team_id"` // Share with this team; null or empty keeps the view private
+	Status          string   `json:"status"`
+	LabelIDs        []string `json:"label_ids"`
+	Assignee        string   `json:"assignee"`
+	WhatsAppAccount string   `json:"whatsapp_account"`
+	SLAState        string   `json:"sla_state"`
+}
+
+// InboxViewResponse is an inbox view with the current user's relation to it
+type InboxViewResponse struct {
+	models.InboxView
+	Subscribed bool `json:"subscribed"`
+	CanEdit    bool `json:"can_edit"`
+}
+
+// InboxViewCount is how many conversations an inbox view shows a user
+type InboxViewCount struct {
+	ViewID uuid.UUID `json:"view_id"`
+	Count  int64     `json:"count"`
+	Unread int64     `json:"unread"`
+}
+
+// ListInboxViews returns the current user's own views and those shared with their teams
+func (a *App) ListInboxViews(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	var views []models.InboxView
+	if err := a.visibleInboxViews(orgID, userID).Order("name ASC").Find(&views).Error; err != nil {
+		a.Log.Error("Failed to list inbox views", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list inbox views", nil, "")
+	}
+
+	var subscribed []uuid.UUID
+	a.DB.Model(&models.InboxViewSubscription{}).Where("user_id = ?", userID).Pluck("view_id", &subscribed)
+	isSubscribed := make(map[uuid.UUID]bool, len(subscribed))
+	for _, id := range subscribed {
+		isSubscribed[id] = true
+	}
+
+	response := make([]InboxViewResponse, len(views))
+	for i, view := range views {
+		response[i] = InboxViewResponse{
+			InboxView:  view,
+			Subscribed: isSubscribed[view.ID],
+			CanEdit:    canEditInboxView(&view, userID, role),
+		}
+	}
+
+	return r.SendEnvelope(map[string]any{"views": response})
+}
+
+// CreateInboxView saves a set of inbox filters for the current user
+func (a *App) CreateInboxView(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	var req InboxViewRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	view := models.InboxView{
+		OrganizationID: orgID,
+		OwnerID:        userID,
+	}
+	if err := a.applyInboxViewRequest(orgID, userID, role, &view, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Create(&view).Error; err != nil {
+		a.Log.Error("Failed to create inbox view", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create inbox view", nil, "")
+	}
+
+	return r.SendEnvelope(InboxViewResponse{InboxView: view, CanEdit: true})
+}
+
+// UpdateInboxView replaces a view's name, sharing and filters. Only its owner or an
+// admin can change it.
+func (a *App) UpdateInboxView(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	view, err := a.findInboxView(r, orgID, userID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Inbox view not found", nil, "")
+	}
+	if !canEditInboxView(view, userID, role) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only the view's owner can change it", nil, "")
+	}
+
+	var req InboxViewRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := a.applyInboxViewRequest(orgID, userID, role, view, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Save(view).Error; err != nil {
+		a.Log.Error("Failed to update inbox view", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update inbox view", nil, "")
+	}
+
+	// Users who can no longer see the view stop getting its counts
+	a.DB.Where("view_id = ? AND user_id <> ?", view.ID, view.OwnerID).
+		Where("user_id NOT IN (?)", a.DB.Model(&models.TeamMember{}).Select("user_id").Where("team_id = ?", view.TeamID)).
+		Delete(&models.InboxViewSubscription{})
+
+	return r.SendEnvelope(InboxViewResponse{InboxView: *view, CanEdit: true})
+}
+
+// DeleteInboxView deletes a view and its subscriptions
+func (a *App) DeleteInboxView(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	view, err := a.findInboxView(r, orgID, userID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Inbox view not found", nil, "")
+	}
+	if !canEditInboxView(view, userID, role) {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Only the view's owner can delete it", nil, "")
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("view_id = ?", view.ID).Delete(&models.InboxViewSubscription{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(view).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to delete inbox view", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete inbox view", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Inbox view deleted successfully"})
+}
+
+// SubscribeInboxView makes the server push the view's counts to the current user over
+// WebSocket. It returns the current counts.
+func (a *App) SubscribeInboxView(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	view, err := a.findInboxView(r, orgID, userID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Inbox view not found", nil, "")
+	}
+
+	subscription := models.InboxViewSubscription{
+		OrganizationID: orgID,
+		ViewID:         view.ID,
+		UserID:         userID,
+	}
+	if err := a.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&subscription).Error; err != nil {
+		a.Log.Error("Failed to subscribe to inbox view", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to subscribe", nil, "")
+	}
+
+	return r.SendEnvelope(a.inboxViewCount(view, userID, role))
+}
+
+// UnsubscribeInboxView stops pushing the view's counts to the current user
+func (a *App) UnsubscribeInboxView(r *fastglue.Request) error {
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid view ID", nil, "")
+	}
+
+	if err := a.DB.Where("view_id = ? AND user_id = ?", id, userID).Delete(&models.InboxViewSubscription{}).Error; err != nil {
+		a.Log.Error("Failed to unsubscribe from inbox view", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to unsubscribe", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Unsubscribed"})
+}
+
+// GetInboxViewCounts returns the counts of the views the current user subscribed to,
+// for badges when the inbox loads; changes then arrive over WebSocket
+func (a *App) GetInboxViewCounts(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	views, err := a.subscribedInboxViews(orgID, userID)
+	if err != nil {
+		a.Log.Error("Failed to load subscribed inbox views", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load counts", nil, "")
+	}
+
+	counts := make([]InboxViewCount, len(views))
+	for i := range views {
+		counts[i] = a.inboxViewCount(&views[i], userID, role)
+	}
+
+	return r.SendEnvelope(map[string]any{"views": counts})
+}
+
+// applyInboxViewRequest validates req and copies it onto view
+func (a *App) applyInboxViewRequest(orgID, userID uuid.UUID, role string, view *models.InboxView, req *InboxViewRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return fmt.Errorf("a name of up to 100 characters is required")
+	}
+
+	var count int64
+	view.TeamID = nil
+	if req.TeamID != nil && *req.TeamID != "" {
+		teamID, err := uuid.Parse(*req.TeamID)
+		if err != nil {
+			return fmt.Errorf("invalid team_id")
+		}
+		a.DB.Model(&models.Team{}).Where("id = ? AND organization_id = ?", teamID, orgID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("team not found")
+		}
+		// Agents can only share with teams they're on
+		if role == "agent" {
+			a.DB.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&count)
+			if count == 0 {
+				return fmt.Errorf("you can only share views with your own teams")
+			}
+		}
+		view.TeamID = &teamID
+	}
+
+	switch req.Status {
+	case "", models.InboxViewStatusUnread, models.InboxViewStatusRead:
+	default:
+		return fmt.Errorf("status must be unread or read")
+	}
+
+	if len(req.LabelIDs) > maxInboxViewLabels {
+		return fmt.Errorf("a view can filter on at most %d labels", maxInboxViewLabels)
+	}
+	labelIDs := models.StringArray{}
+	for _, id := range req.LabelIDs {
+		labelID, err := uuid.Parse(id)
+		if err != nil {
+			return fmt.Errorf("invalid label ID: %s", id)
+		}
+		labelIDs = append(labelIDs, labelID.String())
+	}
+	if len(labelIDs) > 0 {
+		a.DB.Model(&models.Label{}).Where("organization_id = ? AND id IN ?", orgID, []string(labelIDs)).Count(&count)
+		if int(count) != len(labelIDs) {
+			return fmt.Errorf("label not found")
+		}
+	}
+
+	switch req.Assignee {
+	case "", models.InboxViewAssigneeMe, models.InboxViewAssigneeUnassigned:
+	default:
+		assigneeID, err := uuid.Parse(req.Assignee)
+		if err != nil {
+			return fmt.Errorf("assignee must be me, unassigned or a user ID")
+		}
+		a.DB.Model(&models.User{}).Where("id = ? AND organization_id = ?", assigneeID, orgID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("assignee not found")
+		}
+		req.Assignee = assigneeID.String()
+	}
+
+	if req.WhatsAppAccount != "" {
+		a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("WhatsApp account not found")
+		}
+	}
+
+	switch req.SLAState {
+	case "", models.InboxViewSLAOnTrack, models.InboxViewSLAWarning, models.InboxViewSLABreached:
+	default:
+		return fmt.Errorf("sla_state must be on_track, warning or breached")
+	}
+
+	view.Name = req.Name
+	view.Status = req.Status
+	view.LabelIDs = labelIDs
+	view.Assignee = req.Assignee
+	view.WhatsAppAccount = req.WhatsAppAccount
+	view.SLAState = req.SLAState
+	return nil
+}
+
+// visibleInboxViews selects the views a user owns or that are shared with their teams
+func (a *App) visibleInboxViews(orgID, userID uuid.UUID) *gorm.DB {
+	return a.DB.Model(&models.InboxView{}).
+		Where("organization_id = ?", orgID).
+		Where("owner_id = ? OR team_id IN (?)", userID,
+			a.DB.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", userID))
+}
+
+// subscribedInboxViews returns the views a user subscribed to and can still see
+func (a *App) subscribedInboxViews(orgID, userID uuid.UUID) ([]models.InboxView, error) {
+	var views []models.InboxView
+	err := a.visibleInboxViews(orgID, userID).
+		Where("id IN (?)", a.DB.Model(&models.InboxViewSubscription{}).Select("view_id").Where("user_id = ?", userID)).
+		Order("name ASC").
+		Find(&views).Error
+	return views, err
+}
+
+// findInboxView loads a view the user can see by the request's id
+func (a *App) findInboxView(r *fastglue.Request, orgID, userID uuid.UUID) (*models.InboxView, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+	return a.visibleInboxView(orgID, userID, id)
+}
+
+// visibleInboxView loads a view by ID if the user can see it
+func (a *App) visibleInboxView(orgID, userID, id uuid.UUID) (*models.InboxView, error) {
+	var view models.InboxView
+	if err := a.visibleInboxViews(orgID, userID).Where("id = ?", id).First(&view).Error; err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// canEditInboxView reports whether a user can change or delete a view
+func canEditInboxView(view *models.InboxView, userID uuid.UUID, role string) bool {
+	return view.OwnerID == userID || role == "admin"
+}
+
+// applyInboxViewFilters narrows a contacts query to the conversations a view shows
+// userID, who "me" refers to
+func applyInboxViewFilters(query *gorm.DB, view *models.InboxView, userID uuid.UUID) *gorm.DB {
+	switch view.Status {
+	case models.InboxViewStatusUnread:
+		query = query.Where("contacts.is_read = ?", false)
+	case models.InboxViewStatusRead:
+		query = query.Where("contacts.is_read = ?", true)
+	}
+
+	if len(view.LabelIDs) > 0 {
+		query = query.Where(`EXISTS (SELECT 1 FROM conversation_labels cl WHERE cl.contact_id = contacts.id
+			AND cl.label_id IN ? AND cl.deleted_at IS NULL)`, []string(view.LabelIDs))
+	}
+
+	switch view.Assignee {
+	case "":
+	case models.InboxViewAssigneeMe:
+		query = query.Where("contacts.assigned_user_id = ?", userID)
+	case models.InboxViewAssigneeUnassigned:
+		query = query.Where("contacts.assigned_user_id IS NULL")
+	default:
+		query = query.Where("contacts.assigned_user_id = ?", view.Assignee)
+	}
+
+	if view.WhatsAppAccount != "" {
+		query = query.Where("contacts.whats_app_account = ?", view.WhatsAppAccount)
+	}
+
+	slaConditions := map[string]string{
+		models.InboxViewSLAOnTrack:  "NOT t.sla_breached AND t.escalation_level = 0",
+		models.InboxViewSLAWarning:  "NOT t.sla_breached AND t.escalation_level > 0",
+		models.InboxViewSLABreached: "t.sla_breached",
+	}
+	if condition, ok := slaConditions[view.SLAState]; ok {
+		query = query.Where(`EXISTS (SELECT 1 FROM agent_transfers t WHERE t.contact_id = contacts.id
+			AND t.status = 'active' AND t.deleted_at IS NULL AND ` + condition + `)`)
+	}
+	return query
+}
+
+// inboxViewCount counts the conversations a view shows a user, and how many are unread.
+// Agents only count conversations assigned to them, as in the contact list.
+func (a *App) inboxViewCount(view *models.InboxView, userID uuid.UUID, role string) InboxViewCount {
+	query := a.DB.Model(&models.Contact{}).Where("contacts.organization_id = ?", view.OrganizationID)
+	if role == "agent" {
+		query = query.Where("contacts.assigned_user_id = ?", userID)
+	}
+	query = applyInboxViewFilters(query, view, userID)
+
+	result := InboxViewCount{ViewID: view.ID}
+	if err := query.Select(`COUNT(*) AS count,
+		COUNT(CASE WHEN NOT contacts.is_read THEN 1 END) AS unread`).
+		Scan(&result).Error; err != nil {
+		a.Log.Error("Failed to count inbox view", "error", err, "view_id", view.ID)
+	}
+	return result
+}
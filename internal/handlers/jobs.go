@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/jobs"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// ListJobs returns the organization's most recent background jobs, optionally of one
+// ?type and ?status. Agents only see the jobs they started.
+func (a *App) ListJobs(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	query := a.visibleJobs(r, orgID)
+	if jobType := string(r.RequestCtx.QueryArgs().Peek("type")); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+	if status := string(r.RequestCtx.QueryArgs().Peek("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var list []models.Job
+	if err := query.Omit("errors").Order("created_at DESC").Limit(100).Find(&list).Error; err != nil {
+		a.Log.Error("Failed to list jobs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list jobs", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"jobs": list,
+	})
+}
+
+// GetJob returns a background job with its progress, result and errors
+func (a *App) GetJob(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid job ID", nil, "")
+	}
+
+	var job models.Job
+	if err := a.visibleJobs(r, orgID).Where("id = ?", id).First(&job).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Job not found", nil, "")
+	}
+
+	return r.SendEnvelope(job)
+}
+
+// visibleJobs scopes a query to the jobs of the organization the user may see
+func (a *App) visibleJobs(r *fastglue.Request, orgID uuid.UUID) *gorm.DB {
+	query := a.DB.Where("organization_id = ?", orgID)
+	if role, _ := r.RequestCtx.UserValue("role").(string); role == "agent" {
+		userID, _ := a.getUserIDFromContext(r)
+		query = query.Where("created_by_id = ?", userID)
+	}
+	return query
+}
+
+// startJob records a job for the request's user and returns its tracker
+func (a *App) startJob(r *fastglue.Request, orgID uuid.UUID, jobType string, referenceID *uuid.UUID) (*jobs.Tracker, error) {
+	var createdBy *uuid.UUID
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		createdBy = &userID
+	}
+	job, err := jobs.Create(a.DB, orgID, jobType, referenceID, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	return jobs.NewTracker(a.DB, queue.NewPublisher(a.Redis, a.Log), job), nil
+}
+
+// StartJobUpdatesSubscriber pushes background job progress published by workers and
+// server instances to the job's starter over the WebSocket, or to the whole
+// organization for jobs nobody started, such as scheduled report runs
+func (a *App) StartJobUpdatesSubscriber() error {
+	if a.WSHub == nil {
+		a.Log.Warn("WebSocket hub not initialized, skipping job updates subscriber")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.JobUpdatesCancel = cancel
+
+	subscriber := queue.NewSubscriber(a.Redis, a.Log)
+	err := subscriber.SubscribeJobUpdates(ctx, func(update *queue.JobUpdate) {
+		msg := websocket.WSMessage{Type: websocket.TypeJobProgress, Payload: update}
+		if update.CreatedByID != nil {
+			a.WSHub.BroadcastToUsers(update.OrganizationID, []uuid.UUID{*update.CreatedByID}, msg)
+			return
+		}
+		a.WSHub.BroadcastToOrg(update.OrganizationID, msg)
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	a.Log.Info("Job updates subscriber started")
+	return nil
+}
+
+// StopJobUpdatesSubscriber stops the job updates subscriber
+func (a *App) StopJobUpdatesSubscriber() {
+	if a.JobUpdatesCancel != nil {
+		a.JobUpdatesCancel()
+	}
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+const (
+	labelRulesCacheTTL    = 6 * time.Hour
+	labelRulesCachePrefix = "labels:rules:"
+)
+
+// LabelRuleRequest is the request body for creating or updating a label rule
+type LabelRuleRequest struct {
+	Name            string   `json:"name"`
+	WhatsAppAccount string   `json:"whatsapp_account"`
+	LabelID         string   `json:"label_id"`
+	IsEnabled       *bool    `json:"is_enabled"`
+	TriggerType     string   `json:"trigger_type"`
+	Keywords        []string `json:"keywords"`
+	MatchType       string   `json:"match_type"`
+	CaseSensitive   bool     `json:"case_sensitive"`
+	AdSourceIDs     []string `json:"ad_source_ids"`
+	FlowID          *string  `json:"flow_id"`
+	FlowOutcome     string   `json:"flow_outcome"`
+}
+
+// ListLabelRules returns all label rules for the organization
+func (a *App) ListLabelRules(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var rules []models.LabelRule
+	if err := a.DB.Where("organization_id = ?", orgID).Preload("Label").Order("created_at ASC").Find(&rules).Error; err != nil {
+		a.Log.Error("Failed to list label rules", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list label rules", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"rules": rules,
+	})
+}
+
+// CreateLabelRule creates a label rule
+func (a *App) CreateLabelRule(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req LabelRuleRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	rule := models.LabelRule{OrganizationID: orgID, IsEnabled: true}
+	if err := a.applyLabelRuleRequest(orgID, &rule, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Create(&rule).Error; err != nil {
+		a.Log.Error("Failed to create label rule", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create label rule", nil, "")
+	}
+	a.InvalidateLabelRulesCache(orgID)
+
+	return r.SendEnvelope(rule)
+}
+
+// UpdateLabelRule replaces a label rule's configuration
+func (a *App) UpdateLabelRule(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	rule, err := a.findLabelRule(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Label rule not found", nil, "")
+	}
+
+	var req LabelRuleRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := a.applyLabelRuleRequest(orgID, rule, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Save(rule).Error; err != nil {
+		a.Log.Error("Failed to update label rule", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update label rule", nil, "")
+	}
+	a.InvalidateLabelRulesCache(orgID)
+
+	return r.SendEnvelope(rule)
+}
+
+// DeleteLabelRule deletes a label rule
+func (a *App) DeleteLabelRule(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	rule, err := a.findLabelRule(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Label rule not found", nil, "")
+	}
+
+	if err := a.DB.Delete(rule).Error; err != nil {
+		a.Log.Error("Failed to delete label rule", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete label rule", nil, "")
+	}
+	a.InvalidateLabelRulesCache(orgID)
+
+	return r.SendEnvelope(map[string]string{"message": "Label rule deleted successfully"})
+}
+
+// applyLabelRuleRequest validates req and copies it onto rule
+func (a *App) applyLabelRuleRequest(orgID uuid.UUID, rule *models.LabelRule, req *LabelRuleRequest) error {
+	labelID, err := uuid.Parse(req.LabelID)
+	if err != nil {
+		return fmt.Errorf("label_id is required")
+	}
+	var count int64
+	a.DB.Model(&models.Label{}).Where("id = ? AND organization_id = ?", labelID, orgID).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("label not found")
+	}
+
+	rule.LabelID = labelID
+	rule.Name = req.Name
+	rule.WhatsAppAccount = req.WhatsAppAccount
+	rule.TriggerType = req.TriggerType
+	rule.Keywords = models.StringArray{}
+	rule.MatchType = "contains"
+	rule.CaseSensitive = false
+	rule.AdSourceIDs = models.StringArray{}
+	rule.FlowID = nil
+	rule.FlowOutcome = ""
+	if req.IsEnabled != nil {
+		rule.IsEnabled = *req.IsEnabled
+	}
+
+	switch req.TriggerType {
+	case models.LabelTriggerKeyword:
+		if len(req.Keywords) == 0 {
+			return fmt.Errorf("at least one keyword is required")
+		}
+		if req.MatchType != "" {
+			if !slices.Contains([]string{"exact", "contains", "starts_with", "regex"}, req.MatchType) {
+				return fmt.Errorf("invalid match_type")
+			}
+			rule.MatchType = req.MatchType
+		}
+		if rule.MatchType == "regex" {
+			for _, keyword := range req.Keywords {
+				if _, err := regexp.Compile(keyword); err != nil {
+					return fmt.Errorf("invalid regex %q", keyword)
+				}
+			}
+		}
+		rule.Keywords = req.Keywords
+		rule.CaseSensitive = req.CaseSensitive
+
+	case models.LabelTriggerAdReferral:
+		if req.AdSourceIDs != nil {
+			rule.AdSourceIDs = req.AdSourceIDs
+		}
+
+	case models.LabelTriggerFlowOutcome:
+		if req.FlowOutcome != "" && req.FlowOutcome != "completed" && req.FlowOutcome != "exited" {
+			return fmt.Errorf("flow_outcome must be completed or exited")
+		}
+		rule.FlowOutcome = req.FlowOutcome
+		if req.FlowID != nil && *req.FlowID != "" {
+			flowID, err := uuid.Parse(*req.FlowID)
+			if err != nil {
+				return fmt.Errorf("invalid flow_id")
+			}
+			a.DB.Model(&models.ChatbotFlow{}).Where("id = ? AND organization_id = ?", flowID, orgID).Count(&count)
+			if count == 0 {
+				return fmt.Errorf("flow not found")
+			}
+			rule.FlowID = &flowID
+		}
+
+	default:
+		return fmt.Errorf("trigger_type must be keyword, ad_referral or flow_outcome")
+	}
+
+	if rule.Name == "" {
+		rule.Name = rule.TriggerType
+	}
+	return nil
+}
+
+func (a *App) findLabelRule(r *fastglue.Request, orgID uuid.UUID) (*models.LabelRule, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var rule models.LabelRule
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// getLabelRulesCached retrieves the organization's enabled label rules from cache or database
+func (a *App) getLabelRulesCached(orgID uuid.UUID) ([]models.LabelRule, error) {
+	ctx := context.Background()
+	cacheKey := labelRulesCachePrefix + orgID.String()
+
+	cached, err := a.Redis.Get(ctx, cacheKey).Result()
+	if err == nil && cached != "" {
+		var rules []models.LabelRule
+		if err := json.Unmarshal([]byte(cached), &rules); err == nil {
+			return rules, nil
+		}
+	}
+
+	var rules []models.LabelRule
+	if err := a.DB.Where("organization_id = ? AND is_enabled = true", orgID).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(rules); err == nil {
+		a.Redis.Set(ctx, cacheKey, data, labelRulesCacheTTL)
+	}
+	return rules, nil
+}
+
+// InvalidateLabelRulesCache invalidates the label rules cache for an organization
+func (a *App) InvalidateLabelRulesCache(orgID uuid.UUID) {
+	a.Redis.Del(context.Background(), labelRulesCachePrefix+orgID.String())
+}
+
+// applyMessageLabelRules labels a conversation from keyword and ad referral rules
+// matching an incoming message
+func (a *App) applyMessageLabelRules(account *models.WhatsAppAccount, contact *models.Contact, messageText string, referral *MessageReferral) {
+	if messageText == "" && referral == nil {
+		return
+	}
+
+	a.applyLabelRules(account.OrganizationID, account.Name, contact.ID, func(rule *models.LabelRule) bool {
+		switch rule.TriggerType {
+		case models.LabelTriggerKeyword:
+			if messageText == "" {
+				return false
+			}
+			for _, keyword := range rule.Keywords {
+				if matchKeyword(messageText, keyword, rule.MatchType, rule.CaseSensitive) {
+					return true
+				}
+			}
+		case models.LabelTriggerAdReferral:
+			if referral == nil {
+				return false
+			}
+			return len(rule.AdSourceIDs) == 0 || slices.Contains(rule.AdSourceIDs, referral.SourceID)
+		}
+		return false
+	})
+}
+
+// applyFlowOutcomeLabelRules labels a conversation when a chatbot flow completes or is exited
+func (a *App) applyFlowOutcomeLabelRules(session *models.ChatbotSession, flowID uuid.UUID, outcome string) {
+	a.applyLabelRules(session.OrganizationID, session.WhatsAppAccount, session.ContactID, func(rule *models.LabelRule) bool {
+		return rule.TriggerType == models.LabelTriggerFlowOutcome &&
+			(rule.FlowID == nil || *rule.FlowID == flowID) &&
+			(rule.FlowOutcome == "" || rule.FlowOutcome == outcome)
+	})
+}
+
+// applyLabelRules applies the label of every rule for the account that matches
+func (a *App) applyLabelRules(orgID uuid.UUID, accountName string, contactID uuid.UUID, matches func(rule *models.LabelRule) bool) {
+	rules, err := a.getLabelRulesCached(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load label rules", "error", err, "org_id", orgID)
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.WhatsAppAccount != "" && rule.WhatsAppAccount != accountName {
+			continue
+		}
+		if !matches(rule) {
+			continue
+		}
+
+		ruleID := rule.ID
+		applied, err := a.applyConversationLabel(orgID, contactID, rule.LabelID, "rule", nil, &ruleID)
+		if err != nil {
+			a.Log.Error("Failed to apply label rule", "error", err, "rule_id", rule.ID, "contact_id", contactID)
+			continue
+		}
+		if applied {
+			a.Log.Info("Label rule applied", "rule_id", rule.ID, "label_id", rule.LabelID, "contact_id", contactID)
+		}
+	}
+}
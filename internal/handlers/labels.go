@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LabelRequest is the request body for creating or updating a label
+type LabelRequest struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// LabelSummary is a label as shown on a conversation
+type LabelSummary struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Color  string    `json:"color"`
+	Source string    `json:"source"`
+}
+
+// ConversationLabelsPayload is the WebSocket payload sent when a conversation's labels change
+type ConversationLabelsPayload struct {
+	ContactID string         `json:"contact_id"`
+	Labels    []LabelSummary `json:"labels"`
+}
+
+// ListLabels returns all labels for the organization
+func (a *App) ListLabels(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var labels []models.Label
+	if err := a.DB.Where("organization_id = ?", orgID).Order("name ASC").Find(&labels).Error; err != nil {
+		a.Log.Error("Failed to list labels", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list labels", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"labels": labels,
+	})
+}
+
+// CreateLabel creates a label. Agents may apply labels but not define them.
+func (a *App) CreateLabel(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if role, _ := r.RequestCtx.UserValue("role").(string); role == "agent" {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access denied", nil, "")
+	}
+
+	var req LabelRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name is required", nil, "")
+	}
+	if a.labelNameTaken(orgID, req.Name, uuid.Nil) {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A label with this name already exists", nil, "")
+	}
+
+	label := models.Label{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Color:          req.Color,
+		Description:    req.Description,
+	}
+	if err := a.DB.Create(&label).Error; err != nil {
+		a.Log.Error("Failed to create label", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create label", nil, "")
+	}
+
+	return r.SendEnvelope(label)
+}
+
+// UpdateLabel updates a label
+func (a *App) UpdateLabel(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if role, _ := r.RequestCtx.UserValue("role").(string); role == "agent" {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access denied", nil, "")
+	}
+
+	label, err := a.findLabel(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Label not found", nil, "")
+	}
+
+	var req LabelRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	updates := map[string]interface{}{
+		"color":       req.Color,
+		"description": req.Description,
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		if a.labelNameTaken(orgID, name, label.ID) {
+			return r.SendErrorEnvelope(fasthttp.StatusConflict, "A label with this name already exists", nil, "")
+		}
+		updates["name"] = name
+	}
+
+	if err := a.DB.Model(label).Updates(updates).Error; err != nil {
+		a.Log.Error("Failed to update label", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update label", nil, "")
+	}
+
+	a.DB.First(label, label.ID)
+	return r.SendEnvelope(label)
+}
+
+// DeleteLabel deletes a label, removing it from conversations and deleting its rules
+func (a *App) DeleteLabel(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if role, _ := r.RequestCtx.UserValue("role").(string); role == "agent" {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Access denied", nil, "")
+	}
+
+	label, err := a.findLabel(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Label not found", nil, "")
+	}
+
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("label_id = ?", label.ID).Delete(&models.ConversationLabel{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("label_id = ?", label.ID).Delete(&models.LabelRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(label).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to delete label", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete label", nil, "")
+	}
+	a.InvalidateLabelRulesCache(orgID)
+
+	return r.SendEnvelope(map[string]string{"message": "Label deleted successfully"})
+}
+
+// GetContactLabels returns the labels on a contact's conversation
+func (a *App) GetContactLabels(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	contact, err := a.findLabelableContact(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"labels": a.conversationLabels([]uuid.UUID{contact.ID})[contact.ID],
+	})
+}
+
+// AddContactLabel applies a label to a contact's conversation
+func (a *App) AddContactLabel(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	contact, err := a.findLabelableContact(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var req struct {
+		LabelID string `json:"label_id"`
+	}
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	labelID, err := uuid.Parse(req.LabelID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid label ID", nil, "")
+	}
+
+	var label models.Label
+	if err := a.DB.Where("id = ? AND organization_id = ?", labelID, orgID).First(&label).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Label not found", nil, "")
+	}
+
+	var appliedBy *uuid.UUID
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		appliedBy = &userID
+	}
+	if _, err := a.applyConversationLabel(orgID, contact.ID, label.ID, "manual", appliedBy, nil); err != nil {
+		a.Log.Error("Failed to apply label", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to apply label", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"labels": a.conversationLabels([]uuid.UUID{contact.ID})[contact.ID],
+	})
+}
+
+// RemoveContactLabel removes a label from a contact's conversation
+func (a *App) RemoveContactLabel(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	contact, err := a.findLabelableContact(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	labelIDStr, _ := r.RequestCtx.UserValue("label_id").(string)
+	labelID, err := uuid.Parse(labelIDStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid label ID", nil, "")
+	}
+
+	if err := a.DB.Where("contact_id = ? AND label_id = ?", contact.ID, labelID).Delete(&models.ConversationLabel{}).Error; err != nil {
+		a.Log.Error("Failed to remove label", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to remove label", nil, "")
+	}
+	a.broadcastConversationLabels(orgID, contact.ID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"labels": a.conversationLabels([]uuid.UUID{contact.ID})[contact.ID],
+	})
+}
+
+// GetLabelAnalytics reports how often each label was applied in a period and how many
+// conversations carry it now.
+// Query params: from, to (YYYY-MM-DD; defaults to the current month)
+func (a *App) GetLabelAnalytics(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	now := time.Now()
+	fromStr := string(r.RequestCtx.QueryArgs().Peek("from"))
+	toStr := string(r.RequestCtx.QueryArgs().Peek("to"))
+
+	var periodStart, periodEnd time.Time
+	if fromStr != "" && toStr != "" {
+		periodStart, err = time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
+		}
+		periodEnd, err = time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
+		}
+		periodEnd = periodEnd.Add(24*time.Hour - time.Nanosecond)
+	} else {
+		periodStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		periodEnd = now
+	}
+
+	type labelStats struct {
+		LabelID  uuid.UUID `json:"label_id"`
+		Name     string    `json:"name"`
+		Color    string    `json:"color"`
+		Applied  int64     `json:"applied"`
+		ByRule   int64     `json:"applied_by_rule"`
+		Manually int64     `json:"applied_manually"`
+		Active   int64     `json:"active"`
+		Contacts int64     `json:"contacts"`
+	}
+
+	// Removed labels are soft-deleted, so applications in the period still count
+	var stats []labelStats
+	err = a.DB.Table("labels l").
+		Select(`l.id AS label_id, l.name, l.color,
+			COUNT(cl.id) FILTER (WHERE cl.created_at BETWEEN ? AND ?) AS applied,
+			COUNT(cl.id) FILTER (WHERE cl.created_at BETWEEN ? AND ? AND cl.source = 'rule') AS by_rule,
+			COUNT(cl.id) FILTER (WHERE cl.created_at BETWEEN ? AND ? AND cl.source = 'manual') AS manually,
+			COUNT(cl.id) FILTER (WHERE cl.deleted_at IS NULL) AS active,
+			COUNT(DISTINCT cl.contact_id) FILTER (WHERE cl.created_at BETWEEN ? AND ?) AS contacts`,
+			periodStart, periodEnd, periodStart, periodEnd, periodStart, periodEnd, periodStart, periodEnd).
+		Joins("LEFT JOIN conversation_labels cl ON cl.label_id = l.id").
+		Where("l.organization_id = ? AND l.deleted_at IS NULL", orgID).
+		Group("l.id, l.name, l.color").
+		Order("applied DESC, l.name ASC").
+		Scan(&stats).Error
+	if err != nil {
+		a.Log.Error("Failed to load label analytics", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load label analytics", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"labels": stats,
+		"from":   periodStart.Format("2006-01-02"),
+		"to":     periodEnd.Format("2006-01-02"),
+	})
+}
+
+// applyConversationLabel labels a conversation, reporting whether the label was new
+func (a *App) applyConversationLabel(orgID, contactID, labelID uuid.UUID, source string, appliedBy, ruleID *uuid.UUID) (bool, error) {
+	result := a.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ConversationLabel{
+		OrganizationID: orgID,
+		ContactID:      contactID,
+		LabelID:        labelID,
+		Source:         source,
+		AppliedByID:    appliedBy,
+		RuleID:         ruleID,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	a.broadcastConversationLabels(orgID, contactID)
+	return true, nil
+}
+
+// broadcastConversationLabels pushes a conversation's current labels to the inbox
+func (a *App) broadcastConversationLabels(orgID, contactID uuid.UUID) {
+	if a.WSHub == nil {
+		return
+	}
+	a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
+		Type: websocket.TypeConversationLabels,
+		Payload: ConversationLabelsPayload{
+			ContactID: contactID.String(),
+			Labels:    a.conversationLabels([]uuid.UUID{contactID})[contactID],
+		},
+	})
+}
+
+// conversationLabels loads the labels on each of the given contacts' conversations
+func (a *App) conversationLabels(contactIDs []uuid.UUID) map[uuid.UUID][]LabelSummary {
+	result := make(map[uuid.UUID][]LabelSummary, len(contactIDs))
+	for _, id := range contactIDs {
+		result[id] = []LabelSummary{}
+	}
+	if len(contactIDs) == 0 {
+		return result
+	}
+
+	var rows []struct {
+		ContactID uuid.UUID
+		LabelSummary
+	}
+	a.DB.Table("conversation_labels cl").
+		Select("cl.contact_id, l.id, l.name, l.color, cl.source").
+		Joins("JOIN labels l ON l.id = cl.label_id AND l.deleted_at IS NULL").
+		Where("cl.contact_id IN ? AND cl.deleted_at IS NULL", contactIDs).
+		Order("l.name ASC").
+		Scan(&rows)
+
+	for _, row := range rows {
+		result[row.ContactID] = append(result[row.ContactID], row.LabelSummary)
+	}
+	return result
+}
+
+// labelNameTaken reports whether another label in the organization already uses name
+func (a *App) labelNameTaken(orgID uuid.UUID, name string, excludeID uuid.UUID) bool {
+	var count int64
+	a.DB.Model(&models.Label{}).
+		Where("organization_id = ? AND LOWER(name) = LOWER(?) AND id != ?", orgID, name, excludeID).
+		Count(&count)
+	return count > 0
+}
+
+func (a *App) findLabel(r *fastglue.Request, orgID uuid.UUID) (*models.Label, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var label models.Label
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&label).Error; err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// findLabelableContact loads the contact in the path, limiting agents to their assigned contacts
+func (a *App) findLabelableContact(r *fastglue.Request, orgID uuid.UUID) (*models.Contact, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	query := a.DB.Where("id = ? AND organization_id = ?", id, orgID)
+	if role, _ := r.RequestCtx.UserValue("role").(string); role == "agent" {
+		userID, err := a.getUserIDFromContext(r)
+		if err != nil {
+			return nil, errors.New("user not found in context")
+		}
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+
+	var contact models.Contact
+	if err := query.First(&contact).Error; err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/linkpreview"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// GetLinkPreview returns the preview of a URL so agents can see what a message's
+// link will look like before choosing to send it with a preview.
+// Query params: url (required)
+func (a *App) GetLinkPreview(r *fastglue.Request) error {
+	if _, err := getOrganizationID(r); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	rawURL := string(r.RequestCtx.QueryArgs().Peek("url"))
+	if rawURL == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "url is required", nil, "")
+	}
+	if _, err := linkpreview.Validate(rawURL); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "URL can't be previewed", nil, "")
+	}
+
+	preview, err := a.getLinkPreviewCached(r.RequestCtx, rawURL)
+	if err != nil {
+		if errors.Is(err, linkpreview.ErrBlockedURL) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "URL can't be previewed", nil, "")
+		}
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch link preview", nil, "")
+	}
+
+	return r.SendEnvelope(preview)
+}
+
+// getLinkPreviewCached returns a URL's preview from cache, fetching it on a miss.
+// Failures are cached briefly so a broken link isn't fetched on every keystroke.
+func (a *App) getLinkPreviewCached(ctx context.Context, rawURL string) (*linkpreview.Preview, error) {
+	sum := sha256.Sum256([]byte(rawURL))
+	cacheKey := linkPreviewCachePrefix + hex.EncodeToString(sum[:])
+
+	cached, err := a.Redis.Get(ctx, cacheKey).Result()
+	if err == nil && cached != "" {
+		var preview linkpreview.Preview
+		if err := json.Unmarshal([]byte(cached), &preview); err == nil {
+			if preview.URL == "" {
+				return nil, errors.New("link preview failed recently")
+			}
+			return &preview, nil
+		}
+	}
+
+	preview, err := linkpreview.Fetch(ctx, rawURL)
+	if err != nil {
+		a.Log.Debug("Failed to fetch link preview", "error", err, "url", rawURL)
+		a.Redis.Set(context.Background(), cacheKey, "{}", linkPreviewFailureCacheTTL)
+		return nil, err
+	}
+	if data, err := json.Marshal(preview); err == nil {
+		a.Redis.Set(context.Background(), cacheKey, data, linkPreviewCacheTTL)
+	}
+	return preview, nil
+}
+
+// attachLinkPreview stores the preview of the first link in a sent message on the
+// message's metadata, for the chat view to render
+func (a *App) attachLinkPreview(messageID uuid.UUID, body string) {
+	rawURL := linkpreview.FirstURL(body)
+	if rawURL == "" {
+		return
+	}
+	preview, err := a.getLinkPreviewCached(context.Background(), rawURL)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(map[string]any{"link_preview": preview})
+	if err != nil {
+		return
+	}
+	if err := a.DB.Model(&models.Message{}).Where("id = ?", messageID).
+		Update("metadata", gorm.Expr("COALESCE(metadata, '{}'::jsonb) || ?::jsonb", string(data))).Error; err != nil {
+		a.Log.Error("Failed to save link preview", "error", err, "message_id", messageID)
+	}
+}
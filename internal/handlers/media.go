@@ -85,7 +85,7 @@ func (a *App) DownloadAndSaveMedia(ctx context.Context, mediaID string, mimeType
 	}
 
 	// Download the media content
-	data, err := a.WhatsApp.DownloadMedia(ctx, mediaURL, account.AccessToken)
+	data, err := a.WhatsApp.DownloadMedia(whatsapp.WithAccount(ctx, account), mediaURL, account.AccessToken)
 	if err != nil {
 		return "", fmt.Errorf("failed to download media: %w", err)
 	}
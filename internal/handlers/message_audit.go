@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// auditVerifyBatchSize is how many records are loaded at a time while walking the chain
+const auditVerifyBatchSize = 1000
+
+// MessageAuditVerification is the result of checking a message against its audit record
+type MessageAuditVerification struct {
+	Record         models.MessageAuditRecord `json:"record"`
+	ContentMatches bool                      `json:"content_matches"` // Stored message still hashes to the recorded value
+	PayloadMatches *bool                     `json:"payload_matches,omitempty"`
+	ChainValid     bool                      `json:"chain_valid"` // Record hash is consistent with its predecessor
+}
+
+// ListMessageAuditRecords returns audit records, newest first.
+// Query params: contact_id, from, to (YYYY-MM-DD), limit (default 50)
+func (a *App) ListMessageAuditRecords(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if contactID := string(r.RequestCtx.QueryArgs().Peek("contact_id")); contactID != "" {
+		id, err := uuid.Parse(contactID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+		}
+		query = query.Where("contact_id = ?", id)
+	}
+	if from := string(r.RequestCtx.QueryArgs().Peek("from")); from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid from date", nil, "")
+		}
+		query = query.Where("sent_at >= ?", t)
+	}
+	if to := string(r.RequestCtx.QueryArgs().Peek("to")); to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid to date", nil, "")
+		}
+		query = query.Where("sent_at < ?", t.AddDate(0, 0, 1))
+	}
+
+	limit := r.RequestCtx.QueryArgs().GetUintOrZero("limit")
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var records []models.MessageAuditRecord
+	if err := query.Order("sequence DESC").Limit(limit).Find(&records).Error; err != nil {
+		a.Log.Error("Failed to list message audit records", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list audit records", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"records": records,
+	})
+}
+
+// GetMessageAuditRecord returns a message's audit record and checks that the stored
+// message, and the immutable payload if kept, still match the recorded hash
+func (a *App) GetMessageAuditRecord(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	idStr, _ := r.RequestCtx.UserValue("message_id").(string)
+	messageID, err := uuid.Parse(idStr)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid message ID", nil, "")
+	}
+
+	var record models.MessageAuditRecord
+	if err := a.DB.Where("message_id = ? AND organization_id = ?", messageID, orgID).First(&record).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Audit record not found", nil, "")
+	}
+
+	result := MessageAuditVerification{Record: record}
+
+	// Deleted messages are still checked; the audit log outlives the inbox
+	var message models.Message
+	if err := a.DB.Unscoped().Where("id = ?", messageID).First(&message).Error; err == nil {
+		if _, hash, err := models.MessageAuditPayload(&message, record.RecipientPhone); err == nil {
+			result.ContentMatches = hash == record.ContentHash
+		}
+	}
+
+	if record.Payload != "" {
+		matches := models.MessageAuditContentHash(record.Payload) == record.ContentHash
+		result.PayloadMatches = &matches
+	}
+
+	var prevHash string
+	if record.Sequence > 1 {
+		var prev models.MessageAuditRecord
+		if err := a.DB.Where("organization_id = ? AND sequence = ?", orgID, record.Sequence-1).First(&prev).Error; err == nil {
+			prevHash = prev.RecordHash
+		}
+	}
+	result.ChainValid = record.PrevHash == prevHash &&
+		record.RecordHash == models.MessageAuditRecordHash(prevHash, record.Sequence, record.ContentHash)
+
+	return r.SendEnvelope(result)
+}
+
+// VerifyMessageAuditChain walks the organization's audit log in order and reports the
+// first record whose hashes or sequence do not line up
+func (a *App) VerifyMessageAuditChain(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var (
+		checked   int64
+		prevHash  string
+		brokenAt  int64
+		reason    string
+		lastSeq   int64
+		lastBatch = auditVerifyBatchSize
+	)
+
+	for lastBatch == auditVerifyBatchSize && reason == "" {
+		var records []models.MessageAuditRecord
+		err := a.DB.Where("organization_id = ? AND sequence > ?", orgID, lastSeq).
+			Order("sequence ASC").
+			Limit(auditVerifyBatchSize).
+			Find(&records).Error
+		if err != nil {
+			a.Log.Error("Failed to load message audit records", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to verify audit log", nil, "")
+		}
+		lastBatch = len(records)
+
+		for _, record := range records {
+			switch {
+			case record.Sequence != lastSeq+1:
+				reason = "missing records before this sequence"
+			case record.PrevHash != prevHash:
+				reason = "previous hash does not match"
+			case record.RecordHash != models.MessageAuditRecordHash(prevHash, record.Sequence, record.ContentHash):
+				reason = "record hash does not match"
+			case record.Payload != "" && models.MessageAuditContentHash(record.Payload) != record.ContentHash:
+				reason = "payload does not match content hash"
+			}
+			if reason != "" {
+				brokenAt = record.Sequence
+				break
+			}
+			checked++
+			lastSeq = record.Sequence
+			prevHash = record.RecordHash
+		}
+	}
+
+	response := map[string]interface{}{
+		"valid":   reason == "",
+		"checked": checked,
+		"head":    prevHash,
+	}
+	if reason != "" {
+		response["broken_at_sequence"] = brokenAt
+		response["reason"] = reason
+	}
+	return r.SendEnvelope(response)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// Message footer categories. Session messages are agent or automated replies;
+// templates are matched by their Meta category when submitted for approval.
+const (
+	FooterCategoryAgent     = "agent"     // Messages agents send from the inbox
+	FooterCategoryAutomated = "automated" // Chatbot, flow, SLA and out-of-hours replies
+	FooterCategoryMarketing = "marketing" // MARKETING templates
+	FooterCategoryUtility   = "utility"   // UTILITY templates
+)
+
+// FooterCategories are the categories a message footer can apply to. Authentication
+// templates can't carry a custom footer, so they are never included.
+var FooterCategories = []string{FooterCategoryAgent, FooterCategoryAutomated, FooterCategoryMarketing, FooterCategoryUtility}
+
+const (
+	// maxMessageFooterLength keeps room for the message in a 4096 character text body
+	maxMessageFooterLength = 300
+	// maxTemplateFooterLength is Meta's limit on a template footer
+	maxTemplateFooterLength = 60
+)
+
+// MessageFooter is an organization's footer and the categories it is appended to
+type MessageFooter struct {
+	Text       string   `json:"text"`
+	Categories []string `json:"categories"`
+}
+
+// appliesTo reports whether the footer is added to messages of category
+func (f MessageFooter) appliesTo(category string) bool {
+	if f.Text == "" {
+		return false
+	}
+	for _, c := range f.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// messageFooterFromSettings reads the footer from an organization's settings
+func messageFooterFromSettings(settings models.JSONB) MessageFooter {
+	footer := MessageFooter{Categories: []string{}}
+	if settings == nil {
+		return footer
+	}
+	if v, ok := settings["message_footer"].(string); ok {
+		footer.Text = v
+	}
+	if v, ok := settings["message_footer_categories"].([]interface{}); ok {
+		for _, c := range v {
+			if s, ok := c.(string); ok {
+				footer.Categories = append(footer.Categories, s)
+			}
+		}
+	}
+	return footer
+}
+
+// validateFooterCategories returns an error naming the first unknown category
+func validateFooterCategories(categories []string) error {
+	for _, c := range categories {
+		known := false
+		for _, k := range FooterCategories {
+			if c == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown message footer category %s", c)
+		}
+	}
+	return nil
+}
+
+// applyMessageFooter appends the organization's footer to a session message body of
+// category, unless the body already ends with it. Every session send path goes
+// through here so the footer is enforced in one place.
+func (a *App) applyMessageFooter(orgID uuid.UUID, category, body string) string {
+	footer, err := a.getMessageFooterCached(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load message footer", "error", err, "organization_id", orgID)
+		return body
+	}
+	if !footer.appliesTo(category) || strings.HasSuffix(strings.TrimSpace(body), footer.Text) {
+		return body
+	}
+	if body == "" {
+		return footer.Text
+	}
+	return body + "\n\n" + footer.Text
+}
+
+// applyTemplateFooter gives a template submitted for approval the organization's
+// footer when its category is included and it has no footer of its own. Footers too
+// long for a template are left off.
+func (a *App) applyTemplateFooter(template *models.Template) {
+	if template.FooterContent != "" {
+		return
+	}
+	footer, err := a.getMessageFooterCached(template.OrganizationID)
+	if err != nil {
+		a.Log.Error("Failed to load message footer", "error", err, "organization_id", template.OrganizationID)
+		return
+	}
+	if !footer.appliesTo(strings.ToLower(template.Category)) {
+		return
+	}
+	if len([]rune(footer.Text)) > maxTemplateFooterLength {
+		a.Log.Warn("Message footer too long for template footer", "template", template.Name, "length", len([]rune(footer.Text)))
+		return
+	}
+	template.FooterContent = footer.Text
+}
+
+// getMessageFooterCached retrieves an organization's message footer from cache or database
+func (a *App) getMessageFooterCached(orgID uuid.UUID) (MessageFooter, error) {
+	ctx := context.Background()
+	cacheKey := messageFooterCachePrefix + orgID.String()
+
+	cached, err := a.Redis.Get(ctx, cacheKey).Result()
+	if err == nil && cached != "" {
+		var footer MessageFooter
+		if err := json.Unmarshal([]byte(cached), &footer); err == nil {
+			return footer, nil
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return MessageFooter{}, err
+	}
+	footer := messageFooterFromSettings(org.Settings)
+
+	if data, err := json.Marshal(footer); err == nil {
+		a.Redis.Set(ctx, cacheKey, data, messageFooterCacheTTL)
+	}
+	return footer, nil
+}
+
+// InvalidateMessageFooterCache invalidates the message footer cache for an organization
+func (a *App) InvalidateMessageFooterCache(orgID uuid.UUID) {
+	a.Redis.Del(context.Background(), messageFooterCachePrefix+orgID.String())
+}
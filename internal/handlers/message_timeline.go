@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// MessageTimelineMessage is the message a timeline belongs to, as it is now
+type MessageTimelineMessage struct {
+	ID                uuid.UUID `json:"id"`
+	ContactID         uuid.UUID `json:"contact_id"`
+	Direction         string    `json:"direction"`
+	MessageType       string    `json:"message_type"`
+	Status            string    `json:"status"`
+	WhatsAppMessageID string    `json:"whatsapp_message_id,omitempty"`
+	WhatsAppAccount   string    `json:"whatsapp_account"`
+	ErrorMessage      string    `json:"error_message,omitempty"`
+	CampaignID        string    `json:"campaign_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// GetMessageTimeline returns every state transition of a message in the order they
+// happened, so support can tell what happened to it.
+// Agents can only see messages of contacts assigned to them.
+func (a *App) GetMessageTimeline(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	messageID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid message ID", nil, "")
+	}
+
+	query := a.DB.Where("messages.id = ? AND messages.organization_id = ?", messageID, orgID)
+	if role == "agent" {
+		query = query.Joins("JOIN contacts ON contacts.id = messages.contact_id").
+			Where("contacts.assigned_user_id = ?", userID)
+	}
+	var message models.Message
+	if err := query.First(&message).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Message not found", nil, "")
+	}
+
+	var events []models.MessageEvent
+	if err := a.DB.Where("message_id = ?", message.ID).
+		Order("occurred_at ASC, created_at ASC").
+		Find(&events).Error; err != nil {
+		a.Log.Error("Failed to load message events", "error", err, "message_id", message.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load message timeline", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"message": messageTimelineMessage(&message),
+		"events":  events,
+	})
+}
+
+// messageTimelineMessage summarizes a message for its timeline
+func messageTimelineMessage(m *models.Message) MessageTimelineMessage {
+	campaignID, _ := m.Metadata["campaign_id"].(string)
+	return MessageTimelineMessage{
+		ID:                m.ID,
+		ContactID:         m.ContactID,
+		Direction:         m.Direction,
+		MessageType:       m.MessageType,
+		Status:            m.Status,
+		WhatsAppMessageID: m.WhatsAppMessageID,
+		WhatsAppAccount:   m.WhatsAppAccount,
+		ErrorMessage:      m.ErrorMessage,
+		CampaignID:        campaignID,
+		CreatedAt:         m.CreatedAt,
+		UpdatedAt:         m.UpdatedAt,
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/mailer"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/push"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+)
+
+// notificationDigestLockKey keeps server instances from sending the same digests twice
+const notificationDigestLockKey = "notifications:digest_processor_lock"
+
+// NotificationDigestProcessor sends agents who get notifications as a digest the new
+// messages queued for them once their digest interval has passed
+type NotificationDigestProcessor struct {
+	app      *App
+	mailer   *mailer.Mailer
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// DigestConversation is one conversation's new messages in a notification digest
+type DigestConversation struct {
+	ContactID     uuid.UUID `json:"contact_id"`
+	ContactName   string    `json:"contact_name"`
+	MessageCount  int       `json:"message_count"`
+	LastPreview   string    `json:"last_preview"`
+	LastMessageAt time.Time `json:"last_message_at"`
+}
+
+// NewNotificationDigestProcessor creates a new notification digest processor
+func NewNotificationDigestProcessor(app *App, interval time.Duration) *NotificationDigestProcessor {
+	return &NotificationDigestProcessor{
+		app:      app,
+		mailer:   mailer.New(app.Config.SMTP),
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the notification digest loop
+func (p *NotificationDigestProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Notification digest processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Notification digest processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Notification digest processor stopped")
+			return
+		case <-ticker.C:
+			p.sendDigests(ctx)
+		}
+	}
+}
+
+// Stop stops the notification digest processor
+func (p *NotificationDigestProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// sendDigests sends the digests that are due
+func (p *NotificationDigestProcessor) sendDigests(ctx context.Context) {
+	acquired, err := p.app.Redis.SetNX(ctx, notificationDigestLockKey, 1, p.interval/2).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	pending, err := p.app.Redis.SMembers(ctx, notificationDigestPendingKey).Result()
+	if err != nil {
+		p.app.Log.Error("Failed to load pending notification digests", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, id := range pending {
+		userID, err := uuid.Parse(id)
+		if err != nil {
+			p.app.Redis.SRem(ctx, notificationDigestPendingKey, id)
+			continue
+		}
+		prefs, err := p.app.notificationPreferences(userID)
+		if err != nil {
+			p.app.Log.Error("Failed to load notification preferences", "error", err, "user_id", userID)
+			continue
+		}
+
+		switch prefs.Mode {
+		case models.NotificationModeMuted:
+			// Muted since the messages were queued
+			p.drain(ctx, userID)
+			continue
+		case models.NotificationModeDigest:
+			if !prefs.DigestDue(now) {
+				continue
+			}
+		}
+
+		items := p.drain(ctx, userID)
+		if len(items) == 0 {
+			continue
+		}
+		p.sendDigest(userID, items)
+		if prefs.ID != uuid.Nil {
+			p.app.DB.Model(prefs).Update("digested_at", now)
+		}
+	}
+}
+
+// drain removes and returns the messages queued for a user's digest
+func (p *NotificationDigestProcessor) drain(ctx context.Context, userID uuid.UUID) []notificationDigestItem {
+	key := notificationDigestPrefix + userID.String()
+	var queued *redis.StringSliceCmd
+	if _, err := p.app.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		queued = pipe.LRange(ctx, key, 0, -1)
+		pipe.Del(ctx, key)
+		pipe.SRem(ctx, notificationDigestPendingKey, userID.String())
+		return nil
+	}); err != nil {
+		p.app.Log.Error("Failed to load notification digest", "error", err, "user_id", userID)
+		return nil
+	}
+
+	items := make([]notificationDigestItem, 0, len(queued.Val()))
+	for _, raw := range queued.Val() {
+		var item notificationDigestItem
+		if err := json.Unmarshal([]byte(raw), &item); err == nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sendDigest delivers a user's queued messages, per organization, to their open inbox,
+// their mobile devices and, unless they turned email notifications off, their email
+func (p *NotificationDigestProcessor) sendDigest(userID uuid.UUID, items []notificationDigestItem) {
+	var user models.User
+	if err := p.app.DB.Where("id = ?", userID).First(&user).Error; err != nil || !user.IsActive {
+		return
+	}
+	emailEnabled, ok := user.Settings["email_notifications"].(bool)
+	emailEnabled = (emailEnabled || !ok) && user.Email != "" && p.mailer.Enabled()
+
+	byOrg := map[uuid.UUID][]notificationDigestItem{}
+	for _, item := range items {
+		byOrg[item.OrganizationID] = append(byOrg[item.OrganizationID], item)
+	}
+	for orgID, orgItems := range byOrg {
+		conversations := digestConversations(orgItems)
+		title := fmt.Sprintf("%d new messages", len(orgItems))
+		body := fmt.Sprintf("In %d conversations", len(conversations))
+		if len(orgItems) == 1 {
+			title = "1 new message"
+		}
+		if len(conversations) == 1 {
+			body = "From " + conversations[0].ContactName
+		}
+
+		if p.app.WSHub != nil {
+			p.app.WSHub.BroadcastToUsers(orgID, []uuid.UUID{userID}, websocket.WSMessage{
+				Type: websocket.TypeNotificationDigest,
+				Payload: map[string]any{
+					"title":              title,
+					"body":               body,
+					"message_count":      len(orgItems),
+					"conversation_count": len(conversations),
+					"conversations":      conversations,
+				},
+			})
+		}
+
+		p.app.pushToUsers(orgID, []uuid.UUID{userID}, push.Notification{
+			Title:       title,
+			Body:        body,
+			CollapseKey: "digest",
+			Data:        map[string]string{"type": "message_digest"},
+		})
+
+		if emailEnabled {
+			p.emailDigest(orgID, &user, len(orgItems), conversations)
+		}
+	}
+}
+
+// emailDigest emails a user their digest
+func (p *NotificationDigestProcessor) emailDigest(orgID uuid.UUID, user *models.User, messageCount int, conversations []DigestConversation) {
+	msg, err := notify.Email(p.app.DB, orgID, notify.KeyMessageDigest, map[string]any{
+		"user_name":          user.FullName,
+		"message_count":      messageCount,
+		"conversation_count": len(conversations),
+		"conversations":      conversations,
+	})
+	if err != nil {
+		p.app.Log.Error("Failed to render notification digest email", "error", err, "user_id", user.ID)
+		return
+	}
+	msg.To = []string{user.Email}
+	if err := p.mailer.SendMessage(msg); err != nil {
+		p.app.Log.Error("Failed to email notification digest", "error", err, "user_id", user.ID)
+	}
+}
+
+// digestConversations groups a digest's messages by conversation, latest first
+func digestConversations(items []notificationDigestItem) []DigestConversation {
+	byContact := map[uuid.UUID]*DigestConversation{}
+	conversations := []*DigestConversation{}
+	for _, item := range items {
+		c, ok := byContact[item.ContactID]
+		if !ok {
+			c = &DigestConversation{ContactID: item.ContactID}
+			byContact[item.ContactID] = c
+			conversations = append(conversations, c)
+		}
+		c.MessageCount++
+		if !item.CreatedAt.Before(c.LastMessageAt) {
+			c.ContactName = item.ContactName
+			c.LastPreview = item.Preview
+			c.LastMessageAt = item.CreatedAt
+		}
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].LastMessageAt.After(conversations[j].LastMessageAt)
+	})
+
+	result := make([]DigestConversation, len(conversations))
+	for i, c := range conversations {
+		result[i] = *c
+	}
+	return result
+}
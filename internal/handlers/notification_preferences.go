@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// notificationDigestPrefix keys the list of messages waiting for a user's next digest
+	notificationDigestPrefix = "notifications:digest:"
+
+	// notificationDigestPendingKey is the set of users with messages waiting for a digest
+	notificationDigestPendingKey = "notifications:digest_pending"
+
+	// notificationDigestMaxItems caps the messages kept for one digest; older ones drop off
+	notificationDigestMaxItems = 500
+)
+
+// NotificationPreferencesRequest is the request body for updating notification preferences
+type NotificationPreferencesRequest struct {
+	Mode               string     `json:"mode"`                 // instant, digest, muted
+	DigestIntervalMins int        `json:"digest_interval_mins"` // Defaults to 15
+	MutedUntil         *time.Time `json:"muted_until"`
+	MutedTeamIDs       []string   `json:"muted_team_ids"`
+	WorkingHoursStart  string     `json:"working_hours_start"`
+	WorkingHoursEnd    string     `json:"working_hours_end"`
+	WorkingDays        []string   `json:"working_days"`
+	Timezone           string     `json:"timezone"`
+}
+
+// notificationDigestItem is a new message waiting for an agent's next digest
+type notificationDigestItem struct {
+	OrganizationID uuid.UUID `json:"organization_id"`
+	ContactID      uuid.UUID `json:"contact_id"`
+	ContactName    string    `json:"contact_name"`
+	MessageID      uuid.UUID `json:"message_id"`
+	Preview        string    `json:"preview"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GetNotificationPreferences returns the current user's notification preferences
+func (a *App) GetNotificationPreferences(r *fastglue.Request) error {
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	prefs, err := a.notificationPreferences(userID)
+	if err != nil {
+		a.Log.Error("Failed to load notification preferences", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load notification preferences", nil, "")
+	}
+
+	return r.SendEnvelope(prefs)
+}
+
+// UpdateNotificationPreferences sets how and when the current user hears about new
+// messages. The inbox, push notifications and digest emails all follow it.
+func (a *App) UpdateNotificationPreferences(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req NotificationPreferencesRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Mode == "" {
+		req.Mode = models.NotificationModeInstant
+	}
+	if !models.ValidNotificationMode(req.Mode) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Mode must be instant, digest or muted", nil, "")
+	}
+	if req.DigestIntervalMins == 0 {
+		req.DigestIntervalMins = models.DefaultDigestIntervalMins
+	}
+	if req.DigestIntervalMins < models.MinDigestIntervalMins || req.DigestIntervalMins > models.MaxDigestIntervalMins {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+			fmt.Sprintf("Digest interval must be between %d and %d minutes", models.MinDigestIntervalMins, models.MaxDigestIntervalMins), nil, "")
+	}
+	if !models.ValidSendWindow(req.WorkingHoursStart, req.WorkingHoursEnd) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Working hours must be two different HH:MM times, or both empty", nil, "")
+	}
+	for _, day := range req.WorkingDays {
+		if !models.ValidWorkingDay(day) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Working days must be mon, tue, wed, thu, fri, sat or sun", nil, "")
+		}
+	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid timezone", nil, "")
+		}
+	}
+
+	mutedTeamIDs := models.StringArray{}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range req.MutedTeamIDs {
+		teamID, err := uuid.Parse(id)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid team ID", nil, "")
+		}
+		if !seen[teamID] {
+			seen[teamID] = true
+			mutedTeamIDs = append(mutedTeamIDs, teamID.String())
+		}
+	}
+	if len(mutedTeamIDs) > 0 {
+		var count int64
+		a.DB.Model(&models.Team{}).Where("organization_id = ? AND id IN ?", orgID, []string(mutedTeamIDs)).Count(&count)
+		if int(count) != len(mutedTeamIDs) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Muted teams must be teams in your organization", nil, "")
+		}
+	}
+
+	prefs, err := a.notificationPreferences(userID)
+	if err != nil {
+		a.Log.Error("Failed to load notification preferences", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save notification preferences", nil, "")
+	}
+	prefs.Mode = req.Mode
+	prefs.DigestIntervalMins = req.DigestIntervalMins
+	prefs.MutedUntil = req.MutedUntil
+	prefs.MutedTeamIDs = mutedTeamIDs
+	prefs.WorkingHoursStart = req.WorkingHoursStart
+	prefs.WorkingHoursEnd = req.WorkingHoursEnd
+	prefs.WorkingDays = models.StringArray(req.WorkingDays)
+	if prefs.WorkingDays == nil {
+		prefs.WorkingDays = models.StringArray{}
+	}
+	prefs.Timezone = req.Timezone
+	if err := a.DB.Save(prefs).Error; err != nil {
+		a.Log.Error("Failed to save notification preferences", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save notification preferences", nil, "")
+	}
+
+	return r.SendEnvelope(prefs)
+}
+
+// notificationPreferences loads a user's notification preferences, or the defaults if
+// they haven't set any
+func (a *App) notificationPreferences(userID uuid.UUID) (*models.NotificationPreference, error) {
+	prefs := defaultNotificationPreference(userID)
+	err := a.DB.Where("user_id = ?", userID).First(&prefs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// defaultNotificationPreference is the preference of a user who hasn't set one
+func defaultNotificationPreference(userID uuid.UUID) models.NotificationPreference {
+	return models.NotificationPreference{
+		UserID:             userID,
+		Mode:               models.NotificationModeInstant,
+		DigestIntervalMins: models.DefaultDigestIntervalMins,
+		MutedTeamIDs:       models.StringArray{},
+		WorkingDays:        models.StringArray{},
+	}
+}
+
+// notificationLocation returns the time zone a user's working hours are in
+func (a *App) notificationLocation(orgID uuid.UUID, prefs *models.NotificationPreference) *time.Location {
+	if prefs.Timezone != "" {
+		if loc, err := time.LoadLocation(prefs.Timezone); err == nil {
+			return loc
+		}
+	}
+	return a.orgLocation(orgID)
+}
+
+// newMessageAlerts applies the notification preferences of the agents alerted about a
+// contact's new message (see conversationAlertUserIDs). It returns the agents to alert
+// now and queues the message for the next digest of those who get digests. Agents who
+// are muted, muted the conversation's team or are outside their working hours get
+// neither.
+func (a *App) newMessageAlerts(orgID uuid.UUID, contact *models.Contact, message *models.Message, preview string) []uuid.UUID {
+	userIDs := a.conversationAlertUserIDs(contact)
+	if len(userIDs) == 0 {
+		return []uuid.UUID{}
+	}
+
+	var prefs []models.NotificationPreference
+	if err := a.DB.Where("user_id IN ?", userIDs).Find(&prefs).Error; err != nil {
+		a.Log.Error("Failed to load notification preferences", "error", err, "contact_id", contact.ID)
+	}
+	prefsByUser := make(map[uuid.UUID]*models.NotificationPreference, len(prefs))
+	for i := range prefs {
+		prefsByUser[prefs[i].UserID] = &prefs[i]
+	}
+
+	var (
+		teamID     *uuid.UUID
+		teamLoaded bool
+	)
+	now := time.Now()
+	instant := []uuid.UUID{}
+	for _, id := range userIDs {
+		userID, err := uuid.Parse(id)
+		if err != nil {
+			continue
+		}
+		p, ok := prefsByUser[userID]
+		if !ok {
+			instant = append(instant, userID)
+			continue
+		}
+		if !p.AllowsAt(now.In(a.notificationLocation(orgID, p))) {
+			continue
+		}
+		if len(p.MutedTeamIDs) > 0 {
+			if !teamLoaded {
+				teamID = a.conversationTeamID(contact.ID)
+				teamLoaded = true
+			}
+			if teamID != nil && p.MutesTeam(*teamID) {
+				continue
+			}
+		}
+		if p.Mode == models.NotificationModeDigest {
+			a.queueNotificationDigest(userID, notificationDigestItem{
+				OrganizationID: orgID,
+				ContactID:      contact.ID,
+				ContactName:    contactDisplayName(contact),
+				MessageID:      message.ID,
+				Preview:        preview,
+				CreatedAt:      message.CreatedAt,
+			})
+			continue
+		}
+		instant = append(instant, userID)
+	}
+	return instant
+}
+
+// conversationTeamID returns the team whose queue a contact's active transfer is in, or
+// nil when it's in the general queue or not transferred
+func (a *App) conversationTeamID(contactID uuid.UUID) *uuid.UUID {
+	var transfer models.AgentTransfer
+	if err := a.DB.Select("team_id").
+		Where("contact_id = ? AND status = ?", contactID, "active").
+		Order("transferred_at DESC").
+		First(&transfer).Error; err != nil {
+		return nil
+	}
+	return transfer.TeamID
+}
+
+// queueNotificationDigest adds a message to a user's next digest
+func (a *App) queueNotificationDigest(userID uuid.UUID, item notificationDigestItem) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	key := notificationDigestPrefix + userID.String()
+	pipe := a.Redis.TxPipeline()
+	pipe.RPush(ctx, key, data)
+	pipe.LTrim(ctx, key, -notificationDigestMaxItems, -1)
+	pipe.SAdd(ctx, notificationDigestPendingKey, userID.String())
+	if _, err := pipe.Exec(ctx); err != nil {
+		a.Log.Error("Failed to queue notification digest", "error", err, "user_id", userID)
+	}
+}
+
+// contactDisplayName returns a contact's profile name, or their phone number without one
+func contactDisplayName(contact *models.Contact) string {
+	if contact.ProfileName != "" {
+		return contact.ProfileName
+	}
+	return contact.PhoneNumber
+}
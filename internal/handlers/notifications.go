@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// NotificationTemplateRequest is the request body for customizing or previewing a template
+type NotificationTemplateRequest struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// NotificationTemplateResponse is a template with its default wording and the
+// organization's override, if any
+type NotificationTemplateResponse struct {
+	notify.Definition
+	Custom *models.NotificationTemplate `json:"custom,omitempty"`
+}
+
+// ListNotificationTemplates returns every customizable email and in-app notification
+// with its default wording, variables and the organization's override
+func (a *App) ListNotificationTemplates(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var custom []models.NotificationTemplate
+	if err := a.DB.Where("organization_id = ?", orgID).Find(&custom).Error; err != nil {
+		a.Log.Error("Failed to list notification templates", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list notification templates", nil, "")
+	}
+	byKey := make(map[string]*models.NotificationTemplate, len(custom))
+	for i := range custom {
+		byKey[custom[i].Key] = &custom[i]
+	}
+
+	templates := make([]NotificationTemplateResponse, len(notify.Definitions))
+	for i, def := range notify.Definitions {
+		templates[i] = NotificationTemplateResponse{Definition: def, Custom: byKey[def.Key]}
+	}
+	return r.SendEnvelope(map[string]any{"templates": templates})
+}
+
+// UpdateNotificationTemplate sets the organization's wording for a template
+func (a *App) UpdateNotificationTemplate(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	key := r.RequestCtx.UserValue("key").(string)
+	var req NotificationTemplateRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := notify.Validate(key, req.Subject, req.Body); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var tmpl models.NotificationTemplate
+	err = a.DB.Where("organization_id = ? AND key = ?", orgID, key).First(&tmpl).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		a.Log.Error("Failed to load notification template", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save notification template", nil, "")
+	}
+	tmpl.OrganizationID = orgID
+	tmpl.Key = key
+	tmpl.Subject = req.Subject
+	tmpl.Body = req.Body
+	if err := a.DB.Save(&tmpl).Error; err != nil {
+		a.Log.Error("Failed to save notification template", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save notification template", nil, "")
+	}
+
+	return r.SendEnvelope(tmpl)
+}
+
+// ResetNotificationTemplate removes the organization's wording so the default is used
+func (a *App) ResetNotificationTemplate(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	key := r.RequestCtx.UserValue("key").(string)
+	if err := a.DB.Where("organization_id = ? AND key = ?", orgID, key).Delete(&models.NotificationTemplate{}).Error; err != nil {
+		a.Log.Error("Failed to reset notification template", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reset notification template", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Notification template reset to default"})
+}
+
+// PreviewNotificationTemplate renders a template with example values and the
+// organization's branding. Emails include their HTML version.
+func (a *App) PreviewNotificationTemplate(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	key := r.RequestCtx.UserValue("key").(string)
+	def, ok := notify.Lookup(key)
+	if !ok {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Notification template not found", nil, "")
+	}
+	var req NotificationTemplateRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Body == "" {
+		req.Subject, req.Body = def.Subject, def.Body
+	}
+	if err := notify.Validate(key, req.Subject, req.Body); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id", "name", "settings", "partner_id").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+	branding := notify.OrganizationBranding(a.DB, &org)
+
+	subject, body, err := notify.Render(branding, org.Name, req.Subject, req.Body, def.SampleVars())
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	preview := map[string]any{"subject": subject, "body": body}
+	if def.Channel == notify.ChannelEmail {
+		html, err := notify.HTML(branding, org.Name, body)
+		if err != nil {
+			a.Log.Error("Failed to render email preview", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to render preview", nil, "")
+		}
+		preview["html"] = html
+	}
+
+	return r.SendEnvelope(preview)
+}
+
+// withNotification adds the organization's in-app notification for an event to its
+// websocket payload, as "notification" next to the event's own fields
+func (a *App) withNotification(orgID uuid.UUID, key string, data any) any {
+	n, err := notify.InApp(a.DB, orgID, key, data)
+	if err != nil {
+		a.Log.Error("Failed to render notification", "error", err, "key", key, "organization_id", orgID)
+		return data
+	}
+	payload := notify.Vars(data)
+	payload["notification"] = n
+	return payload
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/offboarding"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultOffboardingGraceDays is how long the data archive can be downloaded before
+	// the organization's data is purged
+	defaultOffboardingGraceDays = 30
+	maxOffboardingGraceDays     = 90
+)
+
+// OffboardingRequest is the request body for deleting the organization
+type OffboardingRequest struct {
+	Confirm   string `json:"confirm"` // Must be the organization's slug
+	Reason    string `json:"reason"`
+	GraceDays int    `json:"grace_days"` // Days between the archive being ready and the purge
+}
+
+// GetOffboarding returns the organization's latest offboarding with its audit trail
+func (a *App) GetOffboarding(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var ob models.OrganizationOffboarding
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").First(&ob).Error; err != nil {
+		return r.SendEnvelope(map[string]any{"offboarding": nil})
+	}
+	return r.SendEnvelope(map[string]any{"offboarding": ob})
+}
+
+// RequestOffboarding starts deleting the organization. Sending stops immediately; the
+// worker then revokes access, unsubscribes webhooks and builds a data archive, and
+// purges the organization's data once the grace period after that ends.
+func (a *App) RequestOffboarding(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req OffboardingRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.GraceDays == 0 {
+		req.GraceDays = defaultOffboardingGraceDays
+	}
+	if req.GraceDays < 1 || req.GraceDays > maxOffboardingGraceDays {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Grace period must be between 1 and %d days", maxOffboardingGraceDays), nil, "")
+	}
+
+	var org models.Organization
+	if err := a.DB.Where("id = ?", orgID).First(&org).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+	if strings.TrimSpace(req.Confirm) != org.Slug {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Type the organization's slug to confirm", nil, "")
+	}
+
+	var user models.User
+	if err := a.DB.Select("id", "email").Where("id = ?", userID).First(&user).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var active int64
+	a.DB.Model(&models.OrganizationOffboarding{}).
+		Where("organization_id = ? AND status IN ?", orgID, models.OffboardingActiveStatuses).
+		Count(&active)
+	if active > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Offboarding is already in progress", nil, "")
+	}
+
+	ob := models.OrganizationOffboarding{
+		OrganizationID:   orgID,
+		OrganizationName: org.Name,
+		OrganizationSlug: org.Slug,
+		Status:           models.OffboardingExporting,
+		Reason:           strings.TrimSpace(req.Reason),
+		GraceDays:        req.GraceDays,
+		RequestedByID:    userID,
+		RequestedByEmail: user.Email,
+		Events: models.JSONBArray{offboarding.Event("requested",
+			fmt.Sprintf("Requested by %s; data is purged %d days after the archive is ready", user.Email, req.GraceDays))},
+	}
+	if err := a.DB.Create(&ob).Error; err != nil {
+		a.Log.Error("Failed to create offboarding", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start offboarding", nil, "")
+	}
+
+	campaigns, accounts := a.suspendSending(&ob)
+	a.Log.Info("Organization offboarding requested", "organization_id", orgID, "offboarding_id", ob.ID, "requested_by", user.Email)
+	_ = offboarding.RecordEvent(a.DB, ob.ID, "sending_suspended",
+		fmt.Sprintf("%d campaigns paused, %d WhatsApp accounts suspended", campaigns, accounts))
+
+	a.DB.First(&ob, "id = ?", ob.ID)
+	return r.SendEnvelope(map[string]any{"offboarding": ob})
+}
+
+// CancelOffboarding stops an offboarding before the purge starts, restoring the access
+// it revoked. Paused campaigns stay paused, and the webhook subscription check
+// re-subscribes the organization's accounts.
+func (a *App) CancelOffboarding(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	ob, err := a.activeOffboarding(orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No offboarding in progress", nil, "")
+	}
+
+	now := time.Now()
+	result := a.DB.Model(&models.OrganizationOffboarding{}).
+		Where("id = ? AND status IN ?", ob.ID, []string{models.OffboardingExporting, models.OffboardingScheduled}).
+		Updates(map[string]any{
+			"status":          models.OffboardingCancelled,
+			"cancelled_by_id": userID,
+			"cancelled_at":    now,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to cancel offboarding", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to cancel offboarding", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "The purge has started and can no longer be cancelled", nil, "")
+	}
+	if a.Offboarding != nil {
+		a.Offboarding.Invalidate()
+	}
+
+	a.restoreAccess(ob)
+	if ob.ArchivePath != "" {
+		if err := os.Remove(filepath.Join(a.getMediaStoragePath(), ob.ArchivePath)); err != nil && !os.IsNotExist(err) {
+			a.Log.Error("Failed to delete offboarding archive", "error", err, "offboarding_id", ob.ID)
+		}
+	}
+
+	var email string
+	a.DB.Model(&models.User{}).Select("email").Where("id = ?", userID).Scan(&email)
+	_ = offboarding.RecordEvent(a.DB, ob.ID, "cancelled", "Cancelled by "+email)
+	a.Log.Info("Organization offboarding cancelled", "organization_id", orgID, "offboarding_id", ob.ID)
+
+	a.DB.First(ob, "id = ?", ob.ID)
+	return r.SendEnvelope(map[string]any{"offboarding": ob})
+}
+
+// DownloadOffboardingArchive sends the data archive built for the offboarding
+func (a *App) DownloadOffboardingArchive(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	ob, err := a.activeOffboarding(orgID)
+	if err != nil || ob.Status != models.OffboardingScheduled || ob.ArchivePath == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No archive is ready", nil, "")
+	}
+
+	path := filepath.Join(a.getMediaStoragePath(), ob.ArchivePath)
+	if _, err := os.Stat(path); err != nil {
+		a.Log.Error("Offboarding archive missing", "error", err, "offboarding_id", ob.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No archive is ready", nil, "")
+	}
+
+	var email string
+	a.DB.Model(&models.User{}).Select("email").Where("id = ?", userID).Scan(&email)
+	_ = offboarding.RecordEvent(a.DB, ob.ID, "archive_downloaded", "Downloaded by "+email)
+
+	r.RequestCtx.Response.Header.Set("Content-Type", "application/zip")
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-export.zip"`, ob.OrganizationSlug))
+	r.RequestCtx.SendFile(path)
+	return nil
+}
+
+// activeOffboarding returns the organization's offboarding in progress
+func (a *App) activeOffboarding(orgID uuid.UUID) (*models.OrganizationOffboarding, error) {
+	var ob models.OrganizationOffboarding
+	if err := a.DB.Where("organization_id = ? AND status IN ?", orgID, models.OffboardingActiveStatuses).First(&ob).Error; err != nil {
+		return nil, err
+	}
+	return &ob, nil
+}
+
+// suspendSending pauses the organization's running campaigns and suspends its
+// WhatsApp accounts, which also keeps the status and webhook checks off them. Every
+// other send is refused by the offboarding guard on the WhatsApp client.
+func (a *App) suspendSending(ob *models.OrganizationOffboarding) (int64, int64) {
+	if a.Offboarding != nil {
+		a.Offboarding.Invalidate()
+	}
+
+	var paused []models.BulkMessageCampaign
+	campaigns := a.DB.Model(&paused).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("organization_id = ? AND status IN ?", ob.OrganizationID, []string{"queued", "processing", "held"}).
+		Updates(map[string]interface{}{"status": "paused", "held_reason": ""})
+	if campaigns.Error != nil {
+		a.Log.Error("Failed to pause campaigns for offboarding", "error", campaigns.Error, "organization_id", ob.OrganizationID)
+	}
+	for _, campaign := range paused {
+		a.signalCampaignStopped(campaign.ID, "paused")
+	}
+
+	accounts := a.DB.Model(&models.WhatsAppAccount{}).
+		Where("organization_id = ? AND status = ?", ob.OrganizationID, "active").
+		Update("status", "suspended")
+	if accounts.Error != nil {
+		a.Log.Error("Failed to suspend accounts for offboarding", "error", accounts.Error, "organization_id", ob.OrganizationID)
+	}
+
+	return campaigns.RowsAffected, accounts.RowsAffected
+}
+
+// restoreAccess undoes what suspending sending and revoking access switched off
+func (a *App) restoreAccess(ob *models.OrganizationOffboarding) {
+	restores := []struct {
+		model any
+		ids   []string
+	}{
+		{&models.User{}, ob.DeactivatedUserIDs},
+		{&models.APIKey{}, ob.RevokedAPIKeyIDs},
+		{&models.Webhook{}, ob.DisabledWebhookIDs},
+		{&models.BIServiceAccount{}, ob.RevokedBIAccountIDs},
+	}
+	for _, restore := range restores {
+		if len(restore.ids) == 0 {
+			continue
+		}
+		if err := a.DB.Model(restore.model).
+			Where("organization_id = ? AND id IN ?", ob.OrganizationID, restore.ids).
+			Update("is_active", true).Error; err != nil {
+			a.Log.Error("Failed to restore access after cancelled offboarding", "error", err, "offboarding_id", ob.ID)
+		}
+	}
+
+	if err := a.DB.Model(&models.WhatsAppAccount{}).
+		Where("organization_id = ? AND status = ?", ob.OrganizationID, "suspended").
+		Update("status", "active").Error; err != nil {
+		a.Log.Error("Failed to reactivate accounts after cancelled offboarding", "error", err, "offboarding_id", ob.ID)
+	}
+}
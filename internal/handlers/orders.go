@@ -0,0 +1,751 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// orderAttributionWindow is how far back a campaign message is credited with an order
+const orderAttributionWindow = 7 * 24 * time.Hour
+
+// orderStatuses lists the statuses an order can have
+var orderStatuses = []string{"pending", "paid", "fulfilled", "cancelled", "refunded"}
+
+// OrderRequest is the request body for recording or updating an order by hand
+type OrderRequest struct {
+	ContactID   string            `json:"contact_id"`
+	OrderNumber *string           `json:"order_number"`
+	Status      *string           `json:"status"`
+	Currency    *string           `json:"currency"`
+	TotalAmount *int64            `json:"total_amount"` // In cents
+	Items       models.JSONBArray `json:"items"`
+	CampaignID  *string           `json:"campaign_id"` // Optional: overrides the automatic attribution
+	Notes       *string           `json:"notes"`
+	OrderedAt   string            `json:"ordered_at"` // RFC 3339, defaults to now
+}
+
+// OrderConnectorRequest is the request body for connecting a store
+type OrderConnectorRequest struct {
+	Name            string `json:"name"`
+	Source          string `json:"source"` // shopify, woocommerce
+	Secret          string `json:"secret"`
+	WhatsAppAccount string `json:"whatsapp_account"`
+}
+
+// ListOrders returns the organization's orders, newest first.
+// Query params: contact_id, campaign_id, agent_id, source, status, from, to (YYYY-MM-DD), page, limit
+func (a *App) ListOrders(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	args := r.RequestCtx.QueryArgs()
+	query := a.DB.Model(&models.Order{}).Where("organization_id = ?", orgID)
+	for _, param := range []string{"contact_id", "campaign_id", "agent_id", "source", "status"} {
+		if value := string(args.Peek(param)); value != "" {
+			query = query.Where(param+" = ?", value)
+		}
+	}
+	if from := string(args.Peek("from")); from != "" {
+		if parsed, err := time.Parse("2006-01-02", from); err == nil {
+			query = query.Where("ordered_at >= ?", parsed)
+		}
+	}
+	if to := string(args.Peek("to")); to != "" {
+		if parsed, err := time.Parse("2006-01-02", to); err == nil {
+			query = query.Where("ordered_at < ?", parsed.AddDate(0, 0, 1))
+		}
+	}
+
+	page, _ := strconv.Atoi(string(args.Peek("page")))
+	limit, _ := strconv.Atoi(string(args.Peek("limit")))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	var total int64
+	query.Count(&total)
+
+	// Revenue per currency across the whole filtered set, not just this page
+	var revenue []struct {
+		Currency string `json:"currency"`
+		Orders   int64  `json:"orders"`
+		Amount   int64  `json:"amount"`
+	}
+	query.Session(&gorm.Session{}).
+		Select("currency, COUNT(*) AS orders, COALESCE(SUM(total_amount), 0) AS amount").
+		Where("status NOT IN ?", []string{"cancelled", "refunded"}).
+		Group("currency").Order("currency").
+		Scan(&revenue)
+
+	var orders []models.Order
+	if err := query.Preload("Contact").Order("ordered_at DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&orders).Error; err != nil {
+		a.Log.Error("Failed to list orders", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list orders", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"orders":  orders,
+		"revenue": revenue,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// GetOrder returns a single order
+func (a *App) GetOrder(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	order, err := a.findOrder(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Order not found", nil, "")
+	}
+
+	return r.SendEnvelope(order)
+}
+
+// CreateOrder records an order placed outside a connected store against a conversation
+func (a *App) CreateOrder(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req OrderRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	contactID, err := uuid.Parse(req.ContactID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+	var contact models.Contact
+	if err := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID).First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	order := models.Order{
+		OrganizationID: orgID,
+		Source:         models.OrderSourceManual,
+		Status:         "paid",
+		Items:          models.JSONBArray{},
+		CustomerPhone:  contact.PhoneNumber,
+		OrderedAt:      time.Now(),
+	}
+	if req.OrderedAt != "" {
+		if order.OrderedAt, err = time.Parse(time.RFC3339, req.OrderedAt); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid ordered_at, use RFC 3339", nil, "")
+		}
+	}
+	if msg := a.applyOrderRequest(orgID, &order, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+	if order.Currency == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "currency is required", nil, "")
+	}
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		order.CreatedByID = &userID
+	}
+
+	a.attributeOrder(&order, &contact)
+	if err := a.DB.Create(&order).Error; err != nil {
+		a.Log.Error("Failed to create order", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create order", nil, "")
+	}
+
+	return r.SendEnvelope(order)
+}
+
+// UpdateOrder updates an order's status, amount, items or notes
+func (a *App) UpdateOrder(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	order, err := a.findOrder(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Order not found", nil, "")
+	}
+
+	var req OrderRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if msg := a.applyOrderRequest(orgID, order, &req); msg != "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, msg, nil, "")
+	}
+
+	order.Contact = nil
+	if err := a.DB.Save(order).Error; err != nil {
+		a.Log.Error("Failed to update order", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update order", nil, "")
+	}
+
+	return r.SendEnvelope(order)
+}
+
+// DeleteOrder deletes an order
+func (a *App) DeleteOrder(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	order, err := a.findOrder(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Order not found", nil, "")
+	}
+
+	if err := a.DB.Delete(order).Error; err != nil {
+		a.Log.Error("Failed to delete order", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete order", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{
+		"message": "Order deleted",
+	})
+}
+
+// ListContactOrders returns the orders linked to a contact's conversation
+func (a *App) ListContactOrders(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	contact, err := a.findParticipantContact(r, orgID, true)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var orders []models.Order
+	if err := a.DB.Where("organization_id = ? AND contact_id = ?", orgID, contact.ID).
+		Order("ordered_at DESC").Limit(100).Find(&orders).Error; err != nil {
+		a.Log.Error("Failed to list contact orders", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list orders", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"orders": orders,
+	})
+}
+
+// ListOrderConnectors returns the organization's connected stores
+func (a *App) ListOrderConnectors(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var connectors []models.OrderConnector
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&connectors).Error; err != nil {
+		a.Log.Error("Failed to list order connectors", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list connectors", nil, "")
+	}
+
+	response := make([]map[string]any, len(connectors))
+	for i := range connectors {
+		response[i] = a.orderConnectorResponse(&connectors[i])
+	}
+	return r.SendEnvelope(map[string]any{
+		"connectors": response,
+	})
+}
+
+// CreateOrderConnector connects a Shopify or WooCommerce store. The response includes
+// the webhook URL to configure in the store for order create and update events.
+func (a *App) CreateOrderConnector(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req OrderConnectorRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Name == "" || req.Secret == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name and secret are required", nil, "")
+	}
+	if req.Source != models.OrderSourceShopify && req.Source != models.OrderSourceWooCommerce {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "source must be shopify or woocommerce", nil, "")
+	}
+	if req.WhatsAppAccount != "" {
+		var count int64
+		a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+		if count == 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+		}
+	}
+
+	connector := models.OrderConnector{
+		OrganizationID:  orgID,
+		Name:            req.Name,
+		Source:          req.Source,
+		Secret:          req.Secret,
+		WhatsAppAccount: req.WhatsAppAccount,
+		IsActive:        true,
+	}
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		connector.CreatedByID = &userID
+	}
+	if err := a.DB.Create(&connector).Error; err != nil {
+		a.Log.Error("Failed to create order connector", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create connector", nil, "")
+	}
+
+	return r.SendEnvelope(a.orderConnectorResponse(&connector))
+}
+
+// DeleteOrderConnector disconnects a store; orders already received are kept
+func (a *App) DeleteOrderConnector(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid connector ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.OrderConnector{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete order connector", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete connector", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Connector not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{
+		"message": "Connector deleted",
+	})
+}
+
+// ReceiveOrderWebhook records an order delivered by a connected store. It is public;
+// deliveries are authenticated by the store's HMAC-SHA256 signature of the body.
+func (a *App) ReceiveOrderWebhook(r *fastglue.Request) error {
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Connector not found", nil, "")
+	}
+
+	var connector models.OrderConnector
+	if err := a.DB.Where("id = ? AND is_active = ?", id, true).First(&connector).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Connector not found", nil, "")
+	}
+
+	// WooCommerce pings a new webhook with a form-encoded, unsigned body
+	body := r.RequestCtx.PostBody()
+	if len(body) == 0 || body[0] != '{' {
+		return r.SendEnvelope(map[string]string{"status": "ignored"})
+	}
+
+	signatureHeader := "X-Shopify-Hmac-Sha256"
+	if connector.Source == models.OrderSourceWooCommerce {
+		signatureHeader = "X-WC-Webhook-Signature"
+	}
+	if !validStoreSignature(connector.Secret, body, string(r.RequestCtx.Request.Header.Peek(signatureHeader))) {
+		a.Log.Warn("Rejected order webhook with invalid signature", "connector_id", connector.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid signature", nil, "")
+	}
+
+	var order *models.Order
+	if connector.Source == models.OrderSourceWooCommerce {
+		order, err = parseWooCommerceOrder(body)
+	} else {
+		order, err = parseShopifyOrder(body)
+	}
+	if err != nil {
+		a.Log.Error("Failed to parse order webhook", "error", err, "connector_id", connector.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid order payload", nil, "")
+	}
+
+	order.OrganizationID = connector.OrganizationID
+	order.Source = connector.Source
+	order.WhatsAppAccount = connector.WhatsAppAccount
+	if contact := a.findOrderContact(connector.OrganizationID, order.CustomerPhone); contact != nil {
+		a.attributeOrder(order, contact)
+	}
+
+	if err := a.DB.Clauses(models.OrderUpsert()).Create(order).Error; err != nil {
+		a.Log.Error("Failed to save store order", "error", err, "connector_id", connector.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save order", nil, "")
+	}
+	a.DB.Model(&connector).Update("last_received_at", time.Now())
+
+	return r.SendEnvelope(map[string]string{"status": "ok"})
+}
+
+// recordWhatsAppOrder stores an order message sent from a catalog or cart
+func (a *App) recordWhatsAppOrder(account *models.WhatsAppAccount, contact *models.Contact, whatsappMsgID string, msg *whatsapp.OrderMessage) {
+	order := models.Order{
+		OrganizationID:  account.OrganizationID,
+		WhatsAppAccount: account.Name,
+		Source:          models.OrderSourceWhatsApp,
+		ExternalID:      whatsappMsgID,
+		Status:          "pending",
+		Items:           make(models.JSONBArray, 0, len(msg.ProductItems)),
+		CustomerPhone:   contact.PhoneNumber,
+		Notes:           msg.Text,
+		OrderedAt:       time.Now(),
+	}
+
+	var total float64
+	for _, item := range msg.ProductItems {
+		total += float64(item.Quantity) * float64(item.ItemPrice)
+		if order.Currency == "" {
+			order.Currency = item.Currency
+		}
+		order.Items = append(order.Items, map[string]any{
+			"sku":      item.ProductRetailerID,
+			"quantity": float64(item.Quantity),
+			"price":    toCents(float64(item.ItemPrice)),
+		})
+	}
+	order.TotalAmount = toCents(total)
+
+	var message models.Message
+	if err := a.DB.Select("id").Where("whats_app_message_id = ?", whatsappMsgID).First(&message).Error; err == nil {
+		order.MessageID = &message.ID
+	}
+
+	a.attributeOrder(&order, contact)
+	if err := a.DB.Clauses(models.OrderUpsert()).Create(&order).Error; err != nil {
+		a.Log.Error("Failed to save WhatsApp order", "error", err, "contact_id", contact.ID)
+		return
+	}
+	a.Log.Info("WhatsApp order recorded", "contact_id", contact.ID, "amount", order.TotalAmount, "currency", order.Currency)
+}
+
+// attributeOrder links an order to its contact's conversation, the agent assigned to
+// it and the last campaign sent to the contact within the attribution window
+func (a *App) attributeOrder(order *models.Order, contact *models.Contact) {
+	order.ContactID = &contact.ID
+	if order.WhatsAppAccount == "" {
+		order.WhatsAppAccount = contact.WhatsAppAccount
+	}
+	if order.AgentID == nil {
+		order.AgentID = contact.AssignedUserID
+	}
+	if order.CampaignID != nil {
+		return
+	}
+
+	var campaignID string
+	a.DB.Model(&models.Message{}).
+		Select("metadata->>'campaign_id'").
+		Where("contact_id = ? AND direction = ? AND metadata->>'campaign_id' <> ''", contact.ID, "outgoing").
+		Where("created_at BETWEEN ? AND ?", order.OrderedAt.Add(-orderAttributionWindow), order.OrderedAt).
+		Order("created_at DESC").
+		Limit(1).
+		Scan(&campaignID)
+	if id, err := uuid.Parse(campaignID); err == nil {
+		order.CampaignID = &id
+	}
+}
+
+// findOrderContact finds the contact whose number matches a store customer's phone
+func (a *App) findOrderContact(orgID uuid.UUID, phone string) *models.Contact {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+	if digits == "" {
+		return nil
+	}
+
+	var contact models.Contact
+	if err := a.DB.Where("organization_id = ? AND ltrim(phone_number, '+') = ?", orgID, digits).First(&contact).Error; err != nil {
+		return nil
+	}
+	return &contact
+}
+
+// applyOrderRequest copies the fields set in req onto order, returning a validation
+// message if any is invalid
+func (a *App) applyOrderRequest(orgID uuid.UUID, order *models.Order, req *OrderRequest) string {
+	if req.Status != nil {
+		if !slices.Contains(orderStatuses, *req.Status) {
+			return "status must be one of " + strings.Join(orderStatuses, ", ")
+		}
+		order.Status = *req.Status
+	}
+	if req.Currency != nil {
+		if len(*req.Currency) != 3 {
+			return "currency must be a 3-letter ISO code"
+		}
+		order.Currency = strings.ToUpper(*req.Currency)
+	}
+	if req.TotalAmount != nil {
+		if *req.TotalAmount < 0 {
+			return "total_amount must not be negative"
+		}
+		order.TotalAmount = *req.TotalAmount
+	}
+	if req.OrderNumber != nil {
+		order.OrderNumber = *req.OrderNumber
+	}
+	if req.Items != nil {
+		order.Items = req.Items
+	}
+	if req.Notes != nil {
+		order.Notes = *req.Notes
+	}
+	if req.CampaignID != nil {
+		if *req.CampaignID == "" {
+			order.CampaignID = nil
+		} else {
+			campaignID, err := uuid.Parse(*req.CampaignID)
+			if err != nil {
+				return "Invalid campaign ID"
+			}
+			var count int64
+			a.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND organization_id = ?", campaignID, orgID).Count(&count)
+			if count == 0 {
+				return "Campaign not found"
+			}
+			order.CampaignID = &campaignID
+		}
+	}
+	return ""
+}
+
+func (a *App) orderConnectorResponse(connector *models.OrderConnector) map[string]any {
+	return map[string]any{
+		"id":               connector.ID,
+		"name":             connector.Name,
+		"source":           connector.Source,
+		"whatsapp_account": connector.WhatsAppAccount,
+		"is_active":        connector.IsActive,
+		"last_received_at": connector.LastReceivedAt,
+		"webhook_url":      a.publicBaseURL(connector.OrganizationID) + "/api/orders/webhook/" + connector.ID.String(),
+		"created_at":       connector.CreatedAt,
+	}
+}
+
+// findOrder loads the organization's order named by the {id} path parameter
+func (a *App) findOrder(r *fastglue.Request, orgID uuid.UUID) (*models.Order, error) {
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	var order models.Order
+	if err := a.DB.Preload("Contact").Where("id = ? AND organization_id = ?", id, orgID).First(&order).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// validStoreSignature checks a base64 HMAC-SHA256 of body, as Shopify and WooCommerce send
+func validStoreSignature(secret string, body []byte, signature string) bool {
+	got, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hmac.Equal(got, h.Sum(nil))
+}
+
+// parseShopifyOrder reads an orders/create or orders/updated webhook body
+func parseShopifyOrder(body []byte) (*models.Order, error) {
+	var payload struct {
+		ID                int64   `json:"id"`
+		Name              string  `json:"name"`
+		Phone             string  `json:"phone"`
+		Currency          string  `json:"currency"`
+		TotalPrice        string  `json:"total_price"`
+		FinancialStatus   string  `json:"financial_status"`
+		FulfillmentStatus *string `json:"fulfillment_status"`
+		CancelledAt       *string `json:"cancelled_at"`
+		CreatedAt         string  `json:"created_at"`
+		Customer          *struct {
+			Phone string `json:"phone"`
+		} `json:"customer"`
+		BillingAddress *struct {
+			Phone string `json:"phone"`
+		} `json:"billing_address"`
+		ShippingAddress *struct {
+			Phone string `json:"phone"`
+		} `json:"shipping_address"`
+		LineItems []struct {
+			Title    string `json:"title"`
+			SKU      string `json:"sku"`
+			Quantity int    `json:"quantity"`
+			Price    string `json:"price"`
+		} `json:"line_items"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.ID == 0 {
+		return nil, errors.New("missing order id")
+	}
+
+	order := &models.Order{
+		ExternalID:    strconv.FormatInt(payload.ID, 10),
+		OrderNumber:   payload.Name,
+		Currency:      strings.ToUpper(payload.Currency),
+		TotalAmount:   parseCents(payload.TotalPrice),
+		CustomerPhone: payload.Phone,
+		Items:         make(models.JSONBArray, 0, len(payload.LineItems)),
+		OrderedAt:     parseStoreTime(payload.CreatedAt),
+	}
+	if order.CustomerPhone == "" && payload.Customer != nil {
+		order.CustomerPhone = payload.Customer.Phone
+	}
+	if order.CustomerPhone == "" && payload.BillingAddress != nil {
+		order.CustomerPhone = payload.BillingAddress.Phone
+	}
+	if order.CustomerPhone == "" && payload.ShippingAddress != nil {
+		order.CustomerPhone = payload.ShippingAddress.Phone
+	}
+
+	switch {
+	case payload.CancelledAt != nil:
+		order.Status = "cancelled"
+	case payload.FinancialStatus == "refunded" || payload.FinancialStatus == "partially_refunded":
+		order.Status = "refunded"
+	case payload.FinancialStatus == "voided":
+		order.Status = "cancelled"
+	case payload.FulfillmentStatus != nil && *payload.FulfillmentStatus == "fulfilled":
+		order.Status = "fulfilled"
+	case payload.FinancialStatus == "paid":
+		order.Status = "paid"
+	default:
+		order.Status = "pending"
+	}
+
+	for _, item := range payload.LineItems {
+		order.Items = append(order.Items, map[string]any{
+			"name":     item.Title,
+			"sku":      item.SKU,
+			"quantity": item.Quantity,
+			"price":    parseCents(item.Price),
+		})
+	}
+	return order, nil
+}
+
+// parseWooCommerceOrder reads an order.created or order.updated webhook body
+func parseWooCommerceOrder(body []byte) (*models.Order, error) {
+	var payload struct {
+		ID             int64  `json:"id"`
+		Number         string `json:"number"`
+		Status         string `json:"status"`
+		Currency       string `json:"currency"`
+		Total          string `json:"total"`
+		DateCreatedGMT string `json:"date_created_gmt"`
+		Billing        struct {
+			Phone string `json:"phone"`
+		} `json:"billing"`
+		Shipping struct {
+			Phone string `json:"phone"`
+		} `json:"shipping"`
+		LineItems []struct {
+			Name     string  `json:"name"`
+			SKU      string  `json:"sku"`
+			Quantity int     `json:"quantity"`
+			Price    float64 `json:"price"`
+		} `json:"line_items"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.ID == 0 {
+		return nil, errors.New("missing order id")
+	}
+
+	order := &models.Order{
+		ExternalID:    strconv.FormatInt(payload.ID, 10),
+		OrderNumber:   payload.Number,
+		Currency:      strings.ToUpper(payload.Currency),
+		TotalAmount:   parseCents(payload.Total),
+		CustomerPhone: payload.Billing.Phone,
+		Items:         make(models.JSONBArray, 0, len(payload.LineItems)),
+		OrderedAt:     parseStoreTime(payload.DateCreatedGMT),
+	}
+	if order.CustomerPhone == "" {
+		order.CustomerPhone = payload.Shipping.Phone
+	}
+
+	switch payload.Status {
+	case "processing":
+		order.Status = "paid"
+	case "completed":
+		order.Status = "fulfilled"
+	case "cancelled", "failed":
+		order.Status = "cancelled"
+	case "refunded":
+		order.Status = "refunded"
+	default:
+		order.Status = "pending"
+	}
+
+	for _, item := range payload.LineItems {
+		order.Items = append(order.Items, map[string]any{
+			"name":     item.Name,
+			"sku":      item.SKU,
+			"quantity": item.Quantity,
+			"price":    toCents(item.Price),
+		})
+	}
+	return order, nil
+}
+
+// parseStoreTime parses a store timestamp (RFC 3339, or WooCommerce's zone-less GMT
+// form), falling back to now
+func parseStoreTime(value string) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05", value); err == nil {
+		return t
+	}
+	return time.Now()
+}
+
+// parseCents converts a decimal amount such as "19.99" to cents
+func parseCents(value string) int64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0
+	}
+	return toCents(v)
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
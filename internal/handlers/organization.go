@@ -2,17 +2,55 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/shridarpatil/whatomate/internal/egress"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/redact"
+	"github.com/shridarpatil/whatomate/internal/reports"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 )
 
 // OrganizationSettings represents the settings structure
 type OrganizationSettings struct {
-	MaskPhoneNumbers bool   `json:"mask_phone_numbers"`
-	Timezone         string `json:"timezone"`
-	DateFormat       string `json:"date_format"`
+	MaskPhoneNumbers    bool   `json:"mask_phone_numbers"`
+	Timezone            string `json:"timezone"`
+	DateFormat          string `json:"date_format"`
+	MessageAudit        string `json:"message_audit"`         // "", hash, immutable
+	EgressProxy         string `json:"egress_proxy"`          // Proxy password is redacted
+	BroadcastDailyLimit int    `json:"broadcast_daily_limit"` // Broadcast list recipients per user per day
+
+	// Duplicate campaign protection
+	DuplicateCampaignCheck       string `json:"duplicate_campaign_check"`        // off, confirm, block
+	DuplicateCampaignWindowHours int    `json:"duplicate_campaign_window_hours"` // How far back to look
+	DuplicateCampaignOverlap     int    `json:"duplicate_campaign_overlap"`      // Percent of recipients shared
+
+	// Footer appended to messages, e.g. "Reply STOP to opt out"
+	MessageFooter           string   `json:"message_footer"`
+	MessageFooterCategories []string `json:"message_footer_categories"` // agent, automated, marketing, utility
+
+	// What is masked in message text sent to logs, webhooks and exports
+	RedactionRules redact.Rules `json:"redaction_rules"`
+
+	// Organizations allowed to share conversations into this one
+	ConversationSharePartners []string `json:"conversation_share_partners"`
+
+	// Contact field each template parameter is filled from by default, e.g. {"1": "first_name"}
+	TemplateParamSources map[string]string `json:"template_param_sources"`
+
+	// Times of day (HH:MM, in Timezone) campaigns are held through; both empty for none
+	QuietHoursStart string `json:"quiet_hours_start"`
+	QuietHoursEnd   string `json:"quiet_hours_end"`
+
+	// How analytics and reports are bucketed, besides days in Timezone
+	WeekStart       string `json:"week_start"`        // monday, sunday or saturday
+	FiscalYearStart int    `json:"fiscal_year_start"` // Month the fiscal year starts in, 1 to 12
 }
 
 // GetOrganizationSettings returns the organization settings
@@ -27,13 +65,36 @@ func (a *App) GetOrganizationSettings(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
 	}
 
-	// Parse settings from JSONB
+	return r.SendEnvelope(map[string]interface{}{
+		"settings": organizationSettings(&org),
+		"name":     org.Name,
+	})
+}
+
+// organizationSettings parses an organization's settings from JSONB, filling in defaults
+func organizationSettings(org *models.Organization) OrganizationSettings {
 	settings := OrganizationSettings{
-		MaskPhoneNumbers: false,
-		Timezone:         "UTC",
-		DateFormat:       "YYYY-MM-DD",
+		MaskPhoneNumbers:    false,
+		Timezone:            "UTC",
+		DateFormat:          "YYYY-MM-DD",
+		BroadcastDailyLimit: defaultBroadcastDailyLimit,
+
+		DuplicateCampaignCheck:       DuplicateCampaignConfirm,
+		DuplicateCampaignWindowHours: defaultDuplicateCampaignWindowHours,
+		DuplicateCampaignOverlap:     defaultDuplicateCampaignOverlap,
+
+		WeekStart:       "monday",
+		FiscalYearStart: 1,
 	}
 
+	footer := messageFooterFromSettings(org.Settings)
+	settings.MessageFooter = footer.Text
+	settings.MessageFooterCategories = footer.Categories
+	settings.RedactionRules = redactionRulesFromSettings(org.Settings)
+	settings.ConversationSharePartners = sharePartnersFromSettings(org.Settings)
+	settings.TemplateParamSources = models.TemplateParamSources(org.Settings, nil)
+	settings.QuietHoursStart, settings.QuietHoursEnd = models.QuietHours(org.Settings)
+
 	if org.Settings != nil {
 		if v, ok := org.Settings["mask_phone_numbers"].(bool); ok {
 			settings.MaskPhoneNumbers = v
@@ -44,12 +105,35 @@ func (a *App) GetOrganizationSettings(r *fastglue.Request) error {
 		if v, ok := org.Settings["date_format"].(string); ok && v != "" {
 			settings.DateFormat = v
 		}
+		if v, ok := org.Settings[reports.WeekStartSetting].(string); ok && v != "" {
+			settings.WeekStart = v
+		}
+		if v, ok := org.Settings[reports.FiscalYearStartSetting].(float64); ok && v >= 1 && v <= 12 {
+			settings.FiscalYearStart = int(v)
+		}
+		if v, ok := org.Settings["message_audit"].(string); ok {
+			settings.MessageAudit = v
+		}
+		if v, ok := org.Settings["broadcast_daily_limit"].(float64); ok && v >= 0 {
+			settings.BroadcastDailyLimit = int(v)
+		}
+		if v, ok := org.Settings["duplicate_campaign_check"].(string); ok && v != "" {
+			settings.DuplicateCampaignCheck = v
+		}
+		if v, ok := org.Settings["duplicate_campaign_window_hours"].(float64); ok && v > 0 {
+			settings.DuplicateCampaignWindowHours = int(v)
+		}
+		if v, ok := org.Settings["duplicate_campaign_overlap"].(float64); ok && v > 0 && v <= 100 {
+			settings.DuplicateCampaignOverlap = int(v)
+		}
+		if v, ok := org.Settings[egress.SettingKey].(string); ok && v != "" {
+			if u, err := url.Parse(v); err == nil {
+				settings.EgressProxy = u.Redacted()
+			}
+		}
 	}
 
-	return r.SendEnvelope(map[string]interface{}{
-		"settings": settings,
-		"name":     org.Name,
-	})
+	return settings
 }
 
 // UpdateOrganizationSettings updates the organization settings
@@ -60,10 +144,26 @@ func (a *App) UpdateOrganizationSettings(r *fastglue.Request) error {
 	}
 
 	var req struct {
-		MaskPhoneNumbers *bool   `json:"mask_phone_numbers"`
-		Timezone         *string `json:"timezone"`
-		DateFormat       *string `json:"date_format"`
-		Name             *string `json:"name"`
+		MaskPhoneNumbers    *bool   `json:"mask_phone_numbers"`
+		Timezone            *string `json:"timezone"`
+		DateFormat          *string `json:"date_format"`
+		MessageAudit        *string `json:"message_audit"`
+		EgressProxy         *string `json:"egress_proxy"`
+		BroadcastDailyLimit *int    `json:"broadcast_daily_limit"`
+		Name                *string `json:"name"`
+
+		DuplicateCampaignCheck       *string            `json:"duplicate_campaign_check"`
+		DuplicateCampaignWindowHours *int               `json:"duplicate_campaign_window_hours"`
+		DuplicateCampaignOverlap     *int               `json:"duplicate_campaign_overlap"`
+		MessageFooter                *string            `json:"message_footer"`
+		MessageFooterCategories      *[]string          `json:"message_footer_categories"`
+		RedactionRules               *redact.Rules      `json:"redaction_rules"`
+		ConversationSharePartners    *[]string          `json:"conversation_share_partners"`
+		TemplateParamSources         *map[string]string `json:"template_param_sources"`
+		QuietHoursStart              *string            `json:"quiet_hours_start"`
+		QuietHoursEnd                *string            `json:"quiet_hours_end"`
+		WeekStart                    *string            `json:"week_start"`
+		FiscalYearStart              *int               `json:"fiscal_year_start"`
 	}
 
 	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
@@ -79,16 +179,142 @@ func (a *App) UpdateOrganizationSettings(r *fastglue.Request) error {
 	if org.Settings == nil {
 		org.Settings = models.JSONB{}
 	}
+	calendar := reports.OrgCalendar(org.Settings)
 
 	if req.MaskPhoneNumbers != nil {
 		org.Settings["mask_phone_numbers"] = *req.MaskPhoneNumbers
 	}
 	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unknown timezone "+*req.Timezone, nil, "")
+		}
 		org.Settings["timezone"] = *req.Timezone
 	}
+	if req.WeekStart != nil {
+		if !slices.Contains(reports.WeekStarts, *req.WeekStart) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Week start must be one of "+strings.Join(reports.WeekStarts, ", "), nil, "")
+		}
+		org.Settings[reports.WeekStartSetting] = *req.WeekStart
+	}
+	if req.FiscalYearStart != nil {
+		if *req.FiscalYearStart < 1 || *req.FiscalYearStart > 12 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Fiscal year start must be a month from 1 to 12", nil, "")
+		}
+		org.Settings[reports.FiscalYearStartSetting] = *req.FiscalYearStart
+	}
 	if req.DateFormat != nil {
 		org.Settings["date_format"] = *req.DateFormat
 	}
+	if req.MessageAudit != nil {
+		switch *req.MessageAudit {
+		case models.MessageAuditOff, models.MessageAuditHash, models.MessageAuditImmutable:
+			org.Settings["message_audit"] = *req.MessageAudit
+		default:
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid message audit mode", nil, "")
+		}
+	}
+	if req.BroadcastDailyLimit != nil {
+		if *req.BroadcastDailyLimit < 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Broadcast daily limit can't be negative", nil, "")
+		}
+		org.Settings["broadcast_daily_limit"] = *req.BroadcastDailyLimit
+	}
+	if req.DuplicateCampaignCheck != nil {
+		switch *req.DuplicateCampaignCheck {
+		case DuplicateCampaignOff, DuplicateCampaignConfirm, DuplicateCampaignBlock:
+			org.Settings["duplicate_campaign_check"] = *req.DuplicateCampaignCheck
+		default:
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid duplicate campaign check", nil, "")
+		}
+	}
+	if req.DuplicateCampaignWindowHours != nil {
+		if *req.DuplicateCampaignWindowHours <= 0 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Duplicate campaign window must be at least an hour", nil, "")
+		}
+		org.Settings["duplicate_campaign_window_hours"] = *req.DuplicateCampaignWindowHours
+	}
+	if req.DuplicateCampaignOverlap != nil {
+		if *req.DuplicateCampaignOverlap <= 0 || *req.DuplicateCampaignOverlap > 100 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Duplicate campaign overlap must be between 1 and 100", nil, "")
+		}
+		org.Settings["duplicate_campaign_overlap"] = *req.DuplicateCampaignOverlap
+	}
+	if req.MessageFooter != nil {
+		footer := strings.TrimSpace(*req.MessageFooter)
+		if len([]rune(footer)) > maxMessageFooterLength {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+				fmt.Sprintf("Message footer can be at most %d characters", maxMessageFooterLength), nil, "")
+		}
+		org.Settings["message_footer"] = footer
+	}
+	if req.MessageFooterCategories != nil {
+		if err := validateFooterCategories(*req.MessageFooterCategories); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		org.Settings["message_footer_categories"] = *req.MessageFooterCategories
+	}
+	if req.RedactionRules != nil {
+		if err := req.RedactionRules.Validate(); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		org.Settings["redaction_rules"] = *req.RedactionRules
+	}
+	if req.ConversationSharePartners != nil {
+		partners, err := a.validateSharePartners(orgID, *req.ConversationSharePartners)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		org.Settings["conversation_share_partners"] = partners
+	}
+	if req.TemplateParamSources != nil {
+		if err := models.ValidateTemplateParamSources(*req.TemplateParamSources); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		sources := map[string]string{}
+		for key, field := range *req.TemplateParamSources {
+			if field != "" {
+				sources[key] = field
+			}
+		}
+		org.Settings[models.TemplateParamSourcesSetting] = sources
+	}
+	if req.QuietHoursStart != nil || req.QuietHoursEnd != nil {
+		start, end := models.QuietHours(org.Settings)
+		if req.QuietHoursStart != nil {
+			start = *req.QuietHoursStart
+		}
+		if req.QuietHoursEnd != nil {
+			end = *req.QuietHoursEnd
+		}
+		if !models.ValidSendWindow(start, end) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Quiet hours must be two different HH:MM times, or both empty", nil, "")
+		}
+		if start == "" {
+			delete(org.Settings, models.QuietHoursStartSetting)
+			delete(org.Settings, models.QuietHoursEndSetting)
+		} else {
+			org.Settings[models.QuietHoursStartSetting] = start
+			org.Settings[models.QuietHoursEndSetting] = end
+		}
+	}
+	if req.EgressProxy != nil {
+		if role, _ := r.RequestCtx.UserValue("role").(string); role != "admin" {
+			return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Admin access required", nil, "")
+		}
+		current, _ := org.Settings[egress.SettingKey].(string)
+		currentURL, _ := url.Parse(current)
+		switch {
+		case *req.EgressProxy == "":
+			delete(org.Settings, egress.SettingKey)
+		case current != "" && currentURL != nil && *req.EgressProxy == currentURL.Redacted():
+			// Unchanged value echoed back from GET; keep the stored password
+		default:
+			if _, err := whatsapp.ParseProxyURL(*req.EgressProxy); err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+			}
+			org.Settings[egress.SettingKey] = *req.EgressProxy
+		}
+	}
 	if req.Name != nil && *req.Name != "" {
 		org.Name = *req.Name
 	}
@@ -96,6 +322,21 @@ func (a *App) UpdateOrganizationSettings(r *fastglue.Request) error {
 	if err := a.DB.Save(&org).Error; err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update settings", nil, "")
 	}
+	if req.EgressProxy != nil && a.Egress != nil {
+		a.Egress.Invalidate()
+	}
+	if req.MessageFooter != nil || req.MessageFooterCategories != nil {
+		a.InvalidateMessageFooterCache(orgID)
+	}
+	if req.RedactionRules != nil {
+		a.InvalidateRedactionRulesCache(orgID)
+	}
+
+	// Scheduled reports fire in the organization's timezone and week; the worker
+	// rebuilds the rollups for a new timezone by itself
+	if updated := reports.OrgCalendar(org.Settings); updated.Location.String() != calendar.Location.String() || updated.WeekStart != calendar.WeekStart {
+		a.rescheduleReports(orgID, updated)
+	}
 
 	return r.SendEnvelope(map[string]interface{}{
 		"message": "Settings updated successfully",
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// ParticipantResponse describes an agent's involvement in a conversation
+type ParticipantResponse struct {
+	UserID             uuid.UUID  `json:"user_id"`
+	FullName           string     `json:"full_name"`
+	IsAssignee         bool       `json:"is_assignee"`
+	LastViewedAt       *time.Time `json:"last_viewed_at,omitempty"`
+	LastParticipatedAt *time.Time `json:"last_participated_at,omitempty"`
+	MessageCount       int        `json:"message_count"`
+	Following          bool       `json:"following"`
+	SeenLatest         bool       `json:"seen_latest"` // Viewed since the conversation's last message
+}
+
+// ListConversationParticipants returns the agents who have viewed or replied in a
+// contact's conversation, most recently active first
+func (a *App) ListConversationParticipants(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	contact, err := a.findParticipantContact(r, orgID, false)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var participants []models.ConversationParticipant
+	if err := a.DB.Where("contact_id = ?", contact.ID).Preload("User").
+		Order("GREATEST(COALESCE(last_viewed_at, 'epoch'), COALESCE(last_participated_at, 'epoch')) DESC").
+		Find(&participants).Error; err != nil {
+		a.Log.Error("Failed to list conversation participants", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list participants", nil, "")
+	}
+
+	response := make([]ParticipantResponse, len(participants))
+	for i, p := range participants {
+		response[i] = ParticipantResponse{
+			UserID:             p.UserID,
+			IsAssignee:         contact.AssignedUserID != nil && *contact.AssignedUserID == p.UserID,
+			LastViewedAt:       p.LastViewedAt,
+			LastParticipatedAt: p.LastParticipatedAt,
+			MessageCount:       p.MessageCount,
+			Following:          p.Following,
+			SeenLatest:         p.LastViewedAt != nil && (contact.LastMessageAt == nil || !p.LastViewedAt.Before(*contact.LastMessageAt)),
+		}
+		if p.User != nil {
+			response[i].FullName = p.User.FullName
+		}
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"participants": response,
+	})
+}
+
+// FollowConversation makes the current user a follower of a contact's conversation, so
+// they are alerted about it without being assigned. Agents can follow conversations
+// they are assigned to or have taken part in.
+func (a *App) FollowConversation(r *fastglue.Request) error {
+	return a.setConversationFollowing(r, true)
+}
+
+// UnfollowConversation stops the current user following a contact's conversation
+func (a *App) UnfollowConversation(r *fastglue.Request) error {
+	return a.setConversationFollowing(r, false)
+}
+
+func (a *App) setConversationFollowing(r *fastglue.Request, following bool) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	contact, err := a.findParticipantContact(r, orgID, true)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	now := time.Now()
+	var followedAt *time.Time
+	if following {
+		followedAt = &now
+	}
+	if err := a.DB.Exec(`INSERT INTO conversation_participants (id, organization_id, contact_id, user_id, following, followed_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (contact_id, user_id) DO UPDATE SET
+			following = EXCLUDED.following,
+			followed_at = EXCLUDED.followed_at,
+			updated_at = EXCLUDED.updated_at`,
+		uuid.New(), orgID, contact.ID, userID, following, followedAt, now, now).Error; err != nil {
+		a.Log.Error("Failed to update conversation following", "error", err, "contact_id", contact.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update following", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"contact_id": contact.ID,
+		"following":  following,
+	})
+}
+
+// ListConversationFollowers returns the users following a contact's conversation
+func (a *App) ListConversationFollowers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	contact, err := a.findParticipantContact(r, orgID, false)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	var participants []models.ConversationParticipant
+	if err := a.DB.Where("contact_id = ? AND following = true", contact.ID).Preload("User").
+		Order("followed_at ASC").Find(&participants).Error; err != nil {
+		a.Log.Error("Failed to list conversation followers", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list followers", nil, "")
+	}
+
+	type followerResponse struct {
+		UserID     uuid.UUID  `json:"user_id"`
+		FullName   string     `json:"full_name"`
+		FollowedAt *time.Time `json:"followed_at,omitempty"`
+	}
+	followers := make([]followerResponse, len(participants))
+	for i, p := range participants {
+		followers[i] = followerResponse{UserID: p.UserID, FollowedAt: p.FollowedAt}
+		if p.User != nil {
+			followers[i].FullName = p.User.FullName
+		}
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"followers": followers,
+	})
+}
+
+// ListFollowedConversations returns the contacts whose conversations the current user
+// follows, most recent activity first
+func (a *App) ListFollowedConversations(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var contacts []models.Contact
+	if err := a.DB.Where("organization_id = ?", orgID).
+		Where("id IN (SELECT contact_id FROM conversation_participants WHERE user_id = ? AND following = true)", userID).
+		Order("last_message_at DESC NULLS LAST").Find(&contacts).Error; err != nil {
+		a.Log.Error("Failed to list followed conversations", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list followed conversations", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"contacts": contacts,
+	})
+}
+
+// recordConversationView notes that a user opened a contact's conversation and tells
+// everyone else looking at it
+func (a *App) recordConversationView(orgID, contactID, userID uuid.UUID) {
+	if userID == uuid.Nil {
+		return
+	}
+
+	now := time.Now()
+	if err := a.DB.Exec(`INSERT INTO conversation_participants (id, organization_id, contact_id, user_id, last_viewed_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (contact_id, user_id) DO UPDATE SET
+			last_viewed_at = EXCLUDED.last_viewed_at,
+			updated_at = EXCLUDED.updated_at`,
+		uuid.New(), orgID, contactID, userID, now, now, now).Error; err != nil {
+		a.Log.Error("Failed to record conversation view", "error", err, "contact_id", contactID)
+		return
+	}
+
+	a.broadcastConversationSeen(orgID, contactID, userID, now)
+}
+
+// recordConversationParticipation notes that a user sent a message in a contact's
+// conversation. Sending implies having seen the conversation.
+func (a *App) recordConversationParticipation(orgID, contactID, userID uuid.UUID) {
+	if userID == uuid.Nil {
+		return
+	}
+
+	now := time.Now()
+	if err := a.DB.Exec(`INSERT INTO conversation_participants (id, organization_id, contact_id, user_id, last_viewed_at, last_participated_at, message_count, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT (contact_id, user_id) DO UPDATE SET
+			last_viewed_at = EXCLUDED.last_viewed_at,
+			last_participated_at = EXCLUDED.last_participated_at,
+			message_count = conversation_participants.message_count + 1,
+			updated_at = EXCLUDED.updated_at`,
+		uuid.New(), orgID, contactID, userID, now, now, now, now).Error; err != nil {
+		a.Log.Error("Failed to record conversation participation", "error", err, "contact_id", contactID)
+	}
+
+	a.notifyConversationFollowers(orgID, contactID, userID, "reply", nil)
+}
+
+// broadcastConversationSeen sends "seen by" updates to clients viewing the conversation
+func (a *App) broadcastConversationSeen(orgID, contactID, userID uuid.UUID, seenAt time.Time) {
+	if a.WSHub == nil {
+		return
+	}
+
+	var user models.User
+	if err := a.DB.Select("id", "full_name").Where("id = ?", userID).First(&user).Error; err != nil {
+		return
+	}
+
+	a.WSHub.BroadcastToContact(orgID, contactID, websocket.WSMessage{
+		Type: websocket.TypeConversationSeen,
+		Payload: map[string]any{
+			"contact_id": contactID.String(),
+			"user_id":    userID.String(),
+			"full_name":  user.FullName,
+			"seen_at":    seenAt,
+		},
+	})
+}
+
+// conversationAlertUserIDs returns the users who should be alerted about new activity
+// in a contact's conversation: its assignee and followers. Everyone else in the
+// organization still receives the update to keep their inbox current, but without an alert.
+func (a *App) conversationAlertUserIDs(contact *models.Contact) []string {
+	userIDs := []string{}
+	if contact.AssignedUserID != nil {
+		userIDs = append(userIDs, contact.AssignedUserID.String())
+	}
+	for _, followerID := range a.conversationFollowerIDs(contact.ID) {
+		if contact.AssignedUserID == nil || followerID != *contact.AssignedUserID {
+			userIDs = append(userIDs, followerID.String())
+		}
+	}
+	return userIDs
+}
+
+// conversationFollowerIDs returns the IDs of the users following a contact's conversation
+func (a *App) conversationFollowerIDs(contactID uuid.UUID) []uuid.UUID {
+	var userIDs []uuid.UUID
+	a.DB.Model(&models.ConversationParticipant{}).
+		Where("contact_id = ? AND following = true", contactID).
+		Pluck("user_id", &userIDs)
+	return userIDs
+}
+
+// notifyConversationFollowers sends a conversation_update to the conversation's
+// followers, except the user who caused it
+func (a *App) notifyConversationFollowers(orgID, contactID, actorID uuid.UUID, event string, data map[string]any) {
+	if a.WSHub == nil {
+		return
+	}
+
+	recipients := []uuid.UUID{}
+	for _, followerID := range a.conversationFollowerIDs(contactID) {
+		if followerID != actorID {
+			recipients = append(recipients, followerID)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	payload := map[string]any{
+		"contact_id": contactID.String(),
+		"event":      event,
+	}
+	if actorID != uuid.Nil {
+		payload["user_id"] = actorID.String()
+	}
+	for k, v := range data {
+		payload[k] = v
+	}
+
+	a.WSHub.BroadcastToUsers(orgID, recipients, websocket.WSMessage{
+		Type:    websocket.TypeConversationUpdate,
+		Payload: payload,
+	})
+}
+
+// findParticipantContact loads the contact in the request path. Agents only get contacts
+// assigned to them, or with allowParticipated, contacts they have taken part in.
+func (a *App) findParticipantContact(r *fastglue.Request, orgID uuid.UUID, allowParticipated bool) (*models.Contact, error) {
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	userRole, _ := r.RequestCtx.UserValue("role").(string)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	if userRole == "agent" {
+		if allowParticipated {
+			query = query.Where(`assigned_user_id = ? OR EXISTS (SELECT 1 FROM conversation_participants p
+				WHERE p.contact_id = contacts.id AND p.user_id = ? AND p.last_participated_at IS NOT NULL)`, userID, userID)
+		} else {
+			query = query.Where("assigned_user_id = ?", userID)
+		}
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
@@ -0,0 +1,794 @@
+package handlers
+
+import (
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/middleware"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// CreatePartnerRequest is the request body for creating a partner
+type CreatePartnerRequest struct {
+	Name         string `json:"name"`
+	BillingEmail string `json:"billing_email"`
+}
+
+// UpdatePartnerRequest is the request body for updating a partner
+type UpdatePartnerRequest struct {
+	Name         *string          `json:"name"`
+	BillingEmail *string          `json:"billing_email"`
+	Branding     *notify.Branding `json:"branding"`
+}
+
+// PartnerOrganizationRequest is the request body for creating a client organization
+type PartnerOrganizationRequest struct {
+	Name          string `json:"name"`
+	AdminEmail    string `json:"admin_email"`
+	AdminPassword string `json:"admin_password"`
+	AdminFullName string `json:"admin_full_name"`
+}
+
+// PartnerMemberRequest is the request body for adding a partner member
+type PartnerMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"` // owner, manager
+}
+
+// PartnerAPIKeyRequest is the request body for creating a partner API key
+type PartnerAPIKeyRequest struct {
+	Name            string   `json:"name"`
+	ExpiresAt       *string  `json:"expires_at,omitempty"`
+	OrganizationIDs []string `json:"organization_ids,omitempty"` // Empty = all client organizations
+	Scopes          []string `json:"scopes,omitempty"`
+}
+
+// PartnerOrganizationStats are one client organization's numbers on the partner dashboard
+type PartnerOrganizationStats struct {
+	OrganizationID   uuid.UUID `json:"organization_id"`
+	Name             string    `json:"name"`
+	Contacts         int64     `json:"contacts"`
+	NewContacts      int64     `json:"new_contacts"`
+	MessagesIncoming int64     `json:"messages_incoming"`
+	MessagesOutgoing int64     `json:"messages_outgoing"`
+	MessagesFailed   int64     `json:"messages_failed"`
+	Campaigns        int64     `json:"campaigns"`
+	WhatsAppAccounts int64     `json:"whatsapp_accounts"`
+}
+
+// PartnerOrganizationUsage is one client organization's billable usage for a month
+type PartnerOrganizationUsage struct {
+	OrganizationID   uuid.UUID `json:"organization_id"`
+	Name             string    `json:"name"`
+	TemplateMessages int64     `json:"template_messages"` // Sent templates, which open paid conversations
+	SessionMessages  int64     `json:"session_messages"`  // Other sent messages
+	IncomingMessages int64     `json:"incoming_messages"`
+	ActiveContacts   int64     `json:"active_contacts"` // Contacts that sent or received a message
+}
+
+// CreatePartner creates a partner managed by the caller, with the caller's
+// organization as its first client (admin only)
+func (a *App) CreatePartner(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	if role, _ := r.RequestCtx.UserValue("role").(string); role != "admin" {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Admin access required", nil, "")
+	}
+
+	var req CreatePartnerRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
+	}
+	if req.BillingEmail != "" {
+		if _, err := mail.ParseAddress(req.BillingEmail); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid billing email", nil, "")
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Where("id = ?", orgID).First(&org).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+	if org.PartnerID != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Organization is already managed by a partner", nil, "")
+	}
+	var memberships int64
+	a.DB.Model(&models.PartnerMember{}).Where("user_id = ?", userID).Count(&memberships)
+	if memberships > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "You already belong to a partner", nil, "")
+	}
+
+	partner := models.Partner{
+		Name:         req.Name,
+		Slug:         generateSlug(req.Name),
+		BillingEmail: req.BillingEmail,
+		Branding:     models.JSONB{},
+	}
+	err = a.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&partner).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.PartnerMember{PartnerID: partner.ID, UserID: userID, Role: models.PartnerRoleOwner}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Organization{}).Where("id = ?", orgID).Update("partner_id", partner.ID).Error
+	})
+	if err != nil {
+		a.Log.Error("Failed to create partner", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create partner", nil, "")
+	}
+
+	a.Log.Info("Created partner", "partner_id", partner.ID, "organization_id", orgID, "created_by", userID)
+	return r.SendEnvelope(partner)
+}
+
+// GetPartner returns the caller's partner with its members
+func (a *App) GetPartner(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+
+	var members []models.PartnerMember
+	a.DB.Preload("User").Where("partner_id = ?", partner.ID).Order("created_at").Find(&members)
+
+	return r.SendEnvelope(map[string]any{
+		"partner": partner,
+		"role":    member.Role,
+		"members": members,
+	})
+}
+
+// UpdatePartner updates the partner's name, billing email and default branding (owners only)
+func (a *App) UpdatePartner(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+	if member.Role != models.PartnerRoleOwner {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner owner access required", nil, "")
+	}
+
+	var req UpdatePartnerRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	updates := map[string]any{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
+		}
+		updates["name"] = name
+		partner.Name = name
+	}
+	if req.BillingEmail != nil {
+		if *req.BillingEmail != "" {
+			if _, err := mail.ParseAddress(*req.BillingEmail); err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid billing email", nil, "")
+			}
+		}
+		updates["billing_email"] = *req.BillingEmail
+		partner.BillingEmail = *req.BillingEmail
+	}
+	if req.Branding != nil {
+		if err := req.Branding.Validate(); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		partner.Branding = req.Branding.JSONB()
+		updates["branding"] = partner.Branding
+	}
+
+	if len(updates) > 0 {
+		if err := a.DB.Model(&models.Partner{}).Where("id = ?", partner.ID).Updates(updates).Error; err != nil {
+			a.Log.Error("Failed to update partner", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update partner", nil, "")
+		}
+	}
+
+	return r.SendEnvelope(partner)
+}
+
+// ListPartnerOrganizations returns the partner's client organizations with their branding
+func (a *App) ListPartnerOrganizations(r *fastglue.Request) error {
+	partner, _, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+
+	var orgs []models.Organization
+	if err := a.DB.Where("partner_id = ?", partner.ID).Order("name").Find(&orgs).Error; err != nil {
+		a.Log.Error("Failed to list partner organizations", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list organizations", nil, "")
+	}
+
+	result := make([]map[string]any, len(orgs))
+	for i, org := range orgs {
+		result[i] = map[string]any{
+			"id":         org.ID,
+			"name":       org.Name,
+			"slug":       org.Slug,
+			"branding":   notify.BrandingFromJSONB(org.Settings["branding"]),
+			"created_at": org.CreatedAt,
+		}
+	}
+	return r.SendEnvelope(map[string]any{"organizations": result})
+}
+
+// CreatePartnerOrganization creates a client organization with its first admin user
+func (a *App) CreatePartnerOrganization(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+
+	var req PartnerOrganizationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.AdminEmail = strings.TrimSpace(req.AdminEmail)
+	if req.Name == "" || req.AdminFullName == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name and admin name are required", nil, "")
+	}
+	if _, err := mail.ParseAddress(req.AdminEmail); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid admin email", nil, "")
+	}
+	if len(req.AdminPassword) < 8 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Admin password must be at least 8 characters", nil, "")
+	}
+
+	var existing int64
+	a.DB.Model(&models.User{}).Where("email = ?", req.AdminEmail).Count(&existing)
+	if existing > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Email already registered", nil, "")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		a.Log.Error("Failed to hash password", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create organization", nil, "")
+	}
+
+	org := models.Organization{
+		Name:      req.Name,
+		Slug:      generateSlug(req.Name),
+		PartnerID: &partner.ID,
+	}
+	user := models.User{
+		Email:        req.AdminEmail,
+		PasswordHash: string(hashedPassword),
+		FullName:     req.AdminFullName,
+		Role:         "admin",
+		IsActive:     true,
+	}
+	if err := a.createOrganizationWithAdmin(&org, &user); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create organization", nil, "")
+	}
+
+	a.Log.Info("Partner created client organization", "partner_id", partner.ID, "organization_id", org.ID, "created_by", member.UserID)
+	return r.SendEnvelope(map[string]any{
+		"organization": org,
+		"admin":        user,
+	})
+}
+
+// RemovePartnerOrganization stops the partner managing a client organization. The
+// organization itself is kept. (owners only)
+func (a *App) RemovePartnerOrganization(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+	if member.Role != models.PartnerRoleOwner {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner owner access required", nil, "")
+	}
+
+	org, err := a.partnerOrganization(r, partner.ID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+
+	if err := a.DB.Model(&models.Organization{}).Where("id = ?", org.ID).Update("partner_id", nil).Error; err != nil {
+		a.Log.Error("Failed to remove partner organization", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to remove organization", nil, "")
+	}
+
+	a.Log.Info("Partner removed client organization", "partner_id", partner.ID, "organization_id", org.ID, "removed_by", member.UserID)
+	return r.SendEnvelope(map[string]string{"message": "Organization removed from partner"})
+}
+
+// UpdatePartnerOrganizationBranding sets a client organization's white-label branding
+func (a *App) UpdatePartnerOrganizationBranding(r *fastglue.Request) error {
+	partner, _, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+
+	org, err := a.partnerOrganization(r, partner.ID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+
+	var req notify.Branding
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := req.Validate(); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if org.Settings == nil {
+		org.Settings = models.JSONB{}
+	}
+	org.Settings["branding"] = req.JSONB()
+	if err := a.DB.Model(org).Update("settings", org.Settings).Error; err != nil {
+		a.Log.Error("Failed to update branding", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update branding", nil, "")
+	}
+
+	return r.SendEnvelope(req)
+}
+
+// GetBranding returns the caller's organization branding, with its partner's
+// defaults filled in
+func (a *App) GetBranding(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id", "settings", "partner_id").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Organization not found", nil, "")
+	}
+
+	return r.SendEnvelope(notify.OrganizationBranding(a.DB, &org))
+}
+
+// AddPartnerMember gives a user of one of the partner's organizations access to the
+// partner (owners only)
+func (a *App) AddPartnerMember(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+	if member.Role != models.PartnerRoleOwner {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner owner access required", nil, "")
+	}
+
+	var req PartnerMemberRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Role == "" {
+		req.Role = models.PartnerRoleManager
+	}
+	if req.Role != models.PartnerRoleOwner && req.Role != models.PartnerRoleManager {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "role must be owner or manager", nil, "")
+	}
+
+	var user models.User
+	if err := a.DB.Where("email = ?", strings.TrimSpace(req.Email)).
+		Where("organization_id IN (?)", a.DB.Model(&models.Organization{}).Select("id").Where("partner_id = ?", partner.ID)).
+		First(&user).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No user with this email in the partner's organizations", nil, "")
+	}
+
+	var memberships int64
+	a.DB.Model(&models.PartnerMember{}).Where("user_id = ?", user.ID).Count(&memberships)
+	if memberships > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "User already belongs to a partner", nil, "")
+	}
+
+	newMember := models.PartnerMember{PartnerID: partner.ID, UserID: user.ID, Role: req.Role}
+	if err := a.DB.Create(&newMember).Error; err != nil {
+		a.Log.Error("Failed to add partner member", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to add member", nil, "")
+	}
+	newMember.User = &user
+
+	a.Log.Info("Added partner member", "partner_id", partner.ID, "user_id", user.ID, "role", req.Role, "added_by", member.UserID)
+	return r.SendEnvelope(newMember)
+}
+
+// RemovePartnerMember removes a user's access to the partner (owners only). The last
+// owner can't be removed.
+func (a *App) RemovePartnerMember(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+	if member.Role != models.PartnerRoleOwner {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner owner access required", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid member ID", nil, "")
+	}
+
+	var target models.PartnerMember
+	if err := a.DB.Where("id = ? AND partner_id = ?", id, partner.ID).First(&target).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Member not found", nil, "")
+	}
+	if target.Role == models.PartnerRoleOwner {
+		var owners int64
+		a.DB.Model(&models.PartnerMember{}).Where("partner_id = ? AND role = ?", partner.ID, models.PartnerRoleOwner).Count(&owners)
+		if owners <= 1 {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "A partner needs at least one owner", nil, "")
+		}
+	}
+
+	if err := a.DB.Delete(&target).Error; err != nil {
+		a.Log.Error("Failed to remove partner member", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to remove member", nil, "")
+	}
+
+	a.Log.Info("Removed partner member", "partner_id", partner.ID, "user_id", target.UserID, "removed_by", member.UserID)
+	return r.SendEnvelope(map[string]string{"message": "Member removed"})
+}
+
+// GetPartnerDashboard returns each client organization's activity for a period.
+// Query params: from, to (YYYY-MM-DD, default the current month)
+func (a *App) GetPartnerDashboard(r *fastglue.Request) error {
+	partner, _, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+
+	now := time.Now()
+	periodStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	periodEnd := now
+	fromStr := string(r.RequestCtx.QueryArgs().Peek("from"))
+	toStr := string(r.RequestCtx.QueryArgs().Peek("to"))
+	if fromStr != "" && toStr != "" {
+		if periodStart, err = time.Parse("2006-01-02", fromStr); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
+		}
+		if periodEnd, err = time.Parse("2006-01-02", toStr); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
+		}
+		periodEnd = periodEnd.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	orgs, orgIDs := a.partnerOrganizations(partner.ID)
+
+	contacts := countByOrganization(a.DB.Model(&models.Contact{}).Where("organization_id IN ?", orgIDs))
+	newContacts := countByOrganization(a.DB.Model(&models.Contact{}).
+		Where("organization_id IN ? AND created_at >= ? AND created_at <= ?", orgIDs, periodStart, periodEnd))
+	campaigns := countByOrganization(a.DB.Model(&models.BulkMessageCampaign{}).
+		Where("organization_id IN ? AND created_at >= ? AND created_at <= ?", orgIDs, periodStart, periodEnd))
+	accounts := countByOrganization(a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id IN ?", orgIDs))
+
+	var messageRows []struct {
+		OrganizationID uuid.UUID
+		Incoming       int64
+		Outgoing       int64
+		Failed         int64
+	}
+	a.DB.Model(&models.Message{}).
+		Select(`organization_id,
+			COUNT(*) FILTER (WHERE direction = 'incoming') AS incoming,
+			COUNT(*) FILTER (WHERE direction = 'outgoing') AS outgoing,
+			COUNT(*) FILTER (WHERE direction = 'outgoing' AND status = 'failed') AS failed`).
+		Where("organization_id IN ? AND created_at >= ? AND created_at <= ?", orgIDs, periodStart, periodEnd).
+		Group("organization_id").
+		Scan(&messageRows)
+
+	stats := make([]PartnerOrganizationStats, len(orgs))
+	index := map[uuid.UUID]int{}
+	var totals PartnerOrganizationStats
+	for i, org := range orgs {
+		index[org.ID] = i
+		stats[i] = PartnerOrganizationStats{
+			OrganizationID:   org.ID,
+			Name:             org.Name,
+			Contacts:         contacts[org.ID],
+			NewContacts:      newContacts[org.ID],
+			Campaigns:        campaigns[org.ID],
+			WhatsAppAccounts: accounts[org.ID],
+		}
+	}
+	for _, row := range messageRows {
+		if i, ok := index[row.OrganizationID]; ok {
+			stats[i].MessagesIncoming = row.Incoming
+			stats[i].MessagesOutgoing = row.Outgoing
+			stats[i].MessagesFailed = row.Failed
+		}
+	}
+	for _, s := range stats {
+		totals.Contacts += s.Contacts
+		totals.NewContacts += s.NewContacts
+		totals.MessagesIncoming += s.MessagesIncoming
+		totals.MessagesOutgoing += s.MessagesOutgoing
+		totals.MessagesFailed += s.MessagesFailed
+		totals.Campaigns += s.Campaigns
+		totals.WhatsAppAccounts += s.WhatsAppAccounts
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"from":          periodStart.Format("2006-01-02"),
+		"to":            periodEnd.Format("2006-01-02"),
+		"organizations": stats,
+		"totals":        totals,
+	})
+}
+
+// GetPartnerBilling returns the billable usage of every client organization for a
+// month, for invoicing the partner in one statement.
+// Query params: month (YYYY-MM, default the current month)
+func (a *App) GetPartnerBilling(r *fastglue.Request) error {
+	partner, _, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+
+	now := time.Now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if monthStr := string(r.RequestCtx.QueryArgs().Peek("month")); monthStr != "" {
+		if monthStart, err = time.Parse("2006-01", monthStr); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid month format. Use YYYY-MM", nil, "")
+		}
+	}
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	orgs, orgIDs := a.partnerOrganizations(partner.ID)
+
+	var rows []struct {
+		OrganizationID   uuid.UUID
+		TemplateMessages int64
+		SessionMessages  int64
+		IncomingMessages int64
+		ActiveContacts   int64
+	}
+	a.DB.Model(&models.Message{}).
+		Select(`organization_id,
+			COUNT(*) FILTER (WHERE direction = 'outgoing' AND status <> 'failed' AND message_type = 'template') AS template_messages,
+			COUNT(*) FILTER (WHERE direction = 'outgoing' AND status <> 'failed' AND message_type <> 'template') AS session_messages,
+			COUNT(*) FILTER (WHERE direction = 'incoming') AS incoming_messages,
+			COUNT(DISTINCT contact_id) AS active_contacts`).
+		Where("organization_id IN ? AND created_at >= ? AND created_at < ?", orgIDs, monthStart, monthEnd).
+		Group("organization_id").
+		Scan(&rows)
+
+	usage := make([]PartnerOrganizationUsage, len(orgs))
+	index := map[uuid.UUID]int{}
+	for i, org := range orgs {
+		index[org.ID] = i
+		usage[i] = PartnerOrganizationUsage{OrganizationID: org.ID, Name: org.Name}
+	}
+	var totals PartnerOrganizationUsage
+	for _, row := range rows {
+		i, ok := index[row.OrganizationID]
+		if !ok {
+			continue
+		}
+		usage[i].TemplateMessages = row.TemplateMessages
+		usage[i].SessionMessages = row.SessionMessages
+		usage[i].IncomingMessages = row.IncomingMessages
+		usage[i].ActiveContacts = row.ActiveContacts
+		totals.TemplateMessages += row.TemplateMessages
+		totals.SessionMessages += row.SessionMessages
+		totals.IncomingMessages += row.IncomingMessages
+		totals.ActiveContacts += row.ActiveContacts
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"month":         monthStart.Format("2006-01"),
+		"partner":       partner.Name,
+		"billing_email": partner.BillingEmail,
+		"organizations": usage,
+		"totals":        totals,
+	})
+}
+
+// ListPartnerAPIKeys returns the partner's API keys (owners only)
+func (a *App) ListPartnerAPIKeys(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+	if member.Role != models.PartnerRoleOwner {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner owner access required", nil, "")
+	}
+
+	var keys []models.PartnerAPIKey
+	if err := a.DB.Where("partner_id = ?", partner.ID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		a.Log.Error("Failed to list partner API keys", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list API keys", nil, "")
+	}
+
+	return r.SendEnvelope(keys)
+}
+
+// CreatePartnerAPIKey creates a partner API key, optionally limited to some client
+// organizations (owners only). Requests name the organization with X-Organization-ID.
+func (a *App) CreatePartnerAPIKey(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+	if member.Role != models.PartnerRoleOwner {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner owner access required", nil, "")
+	}
+
+	var req PartnerAPIKeyRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Name is required", nil, "")
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil && *req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid expires_at format. Use RFC3339 format", nil, "")
+		}
+		expiresAt = &t
+	}
+	if err := middleware.ValidateScopes(req.Scopes); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	_, partnerOrgIDs := a.partnerOrganizations(partner.ID)
+	orgIDs := models.StringArray{}
+	for _, raw := range req.OrganizationIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil || !slices.Contains(partnerOrgIDs, id) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Organization %s isn't managed by this partner", raw), nil, "")
+		}
+		orgIDs = append(orgIDs, id.String())
+	}
+
+	fullKey, err := generateAPIKey()
+	if err != nil {
+		a.Log.Error("Failed to generate API key", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to generate API key", nil, "")
+	}
+	fullKey = middleware.PartnerAPIKeyPrefix + strings.TrimPrefix(fullKey, "whm_")
+
+	hashedKey, err := bcrypt.GenerateFromPassword([]byte(fullKey), bcrypt.DefaultCost)
+	if err != nil {
+		a.Log.Error("Failed to hash API key", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create API key", nil, "")
+	}
+
+	key := models.PartnerAPIKey{
+		PartnerID:       partner.ID,
+		UserID:          member.UserID,
+		Name:            req.Name,
+		KeyPrefix:       fullKey[4:12],
+		KeyHash:         string(hashedKey),
+		ExpiresAt:       expiresAt,
+		IsActive:        true,
+		OrganizationIDs: orgIDs,
+		Scopes:          models.StringArray(req.Scopes),
+	}
+	if key.Scopes == nil {
+		key.Scopes = models.StringArray{}
+	}
+	if err := a.DB.Create(&key).Error; err != nil {
+		a.Log.Error("Failed to create partner API key", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create API key", nil, "")
+	}
+
+	a.Log.Info("Created partner API key", "partner_id", partner.ID, "key_id", key.ID, "created_by", member.UserID)
+
+	// The full key is only returned on creation
+	return r.SendEnvelope(map[string]any{
+		"id":               key.ID,
+		"name":             key.Name,
+		"key":              fullKey,
+		"key_prefix":       key.KeyPrefix,
+		"expires_at":       key.ExpiresAt,
+		"organization_ids": key.OrganizationIDs,
+		"scopes":           key.Scopes,
+		"created_at":       key.CreatedAt,
+	})
+}
+
+// DeletePartnerAPIKey deletes a partner API key (owners only)
+func (a *App) DeletePartnerAPIKey(r *fastglue.Request) error {
+	partner, member, err := a.currentPartner(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner access required", nil, "")
+	}
+	if member.Role != models.PartnerRoleOwner {
+		return r.SendErrorEnvelope(fasthttp.StatusForbidden, "Partner owner access required", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid API key ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND partner_id = ?", id, partner.ID).Delete(&models.PartnerAPIKey{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete partner API key", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete API key", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "API key not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "API key deleted successfully"})
+}
+
+// currentPartner returns the partner the calling user belongs to and their membership
+func (a *App) currentPartner(r *fastglue.Request) (*models.Partner, *models.PartnerMember, error) {
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var member models.PartnerMember
+	if err := a.DB.Where("user_id = ?", userID).First(&member).Error; err != nil {
+		return nil, nil, err
+	}
+	var partner models.Partner
+	if err := a.DB.Where("id = ?", member.PartnerID).First(&partner).Error; err != nil {
+		return nil, nil, err
+	}
+	return &partner, &member, nil
+}
+
+// partnerOrganization loads the client organization named by the {id} path parameter
+func (a *App) partnerOrganization(r *fastglue.Request, partnerID uuid.UUID) (*models.Organization, error) {
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return nil, err
+	}
+	var org models.Organization
+	if err := a.DB.Where("id = ? AND partner_id = ?", id, partnerID).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// partnerOrganizations returns a partner's client organizations and their IDs
+func (a *App) partnerOrganizations(partnerID uuid.UUID) ([]models.Organization, []uuid.UUID) {
+	var orgs []models.Organization
+	a.DB.Select("id", "name").Where("partner_id = ?", partnerID).Order("name").Find(&orgs)
+	ids := make([]uuid.UUID, len(orgs))
+	for i, org := range orgs {
+		ids[i] = org.ID
+	}
+	return orgs, ids
+}
+
+// countByOrganization counts query's rows per organization
+func countByOrganization(query *gorm.DB) map[uuid.UUID]int64 {
+	var rows []struct {
+		OrganizationID uuid.UUID
+		Count          int64
+	}
+	query.Select("organization_id, COUNT(*) AS count").Group("organization_id").Scan(&rows)
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.OrganizationID] = row.Count
+	}
+	return counts
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// errPhoneMigrationInput marks migration step errors caused by the request rather than Meta
+var errPhoneMigrationInput = errors.New("invalid request")
+
+// PhoneMigrationRequest is the request body for starting a phone number migration
+type PhoneMigrationRequest struct {
+	TargetBusinessID string `json:"target_business_id"`
+	AccessToken      string `json:"access_token"` // Token with access to the target WABA; defaults to the account's token
+	AppID            string `json:"app_id"`
+	CountryCode      string `json:"country_code"`
+	PhoneNumber      string `json:"phone_number"`   // Without the country code
+	CopyTemplates    bool   `json:"copy_templates"` // Submit the current WABA's templates to the target WABA
+}
+
+// PhoneMigrationStepRequest is the request body for the verification and registration steps
+type PhoneMigrationStepRequest struct {
+	CodeMethod string `json:"code_method"` // SMS or VOICE
+	Language   string `json:"language"`
+	Code       string `json:"code"`
+	Pin        string `json:"pin"` // Six-digit two-step verification PIN
+}
+
+// GetPhoneMigration returns the account's latest phone number migration along with
+// the records currently linked to the account
+func (a *App) GetPhoneMigration(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	account, err := a.findMigrationAccount(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var migration models.PhoneNumberMigration
+	if err := a.DB.Where("whats_app_account_id = ?", account.ID).Order("created_at DESC").First(&migration).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No migration found for this account", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"migration": migration,
+		"linkage":   a.accountLinkage(orgID, account.Name),
+	})
+}
+
+// StartPhoneMigration adds the account's number to the target WABA. The number keeps
+// working on its current WABA until the migration is completed.
+func (a *App) StartPhoneMigration(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	account, err := a.findMigrationAccount(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	var req PhoneMigrationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.TargetBusinessID == "" || req.CountryCode == "" || req.PhoneNumber == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "target_business_id, country_code and phone_number are required", nil, "")
+	}
+	if req.TargetBusinessID == account.BusinessID {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "The number is already on this WhatsApp Business Account", nil, "")
+	}
+	if _, err := a.activePhoneMigration(account.ID); err == nil {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "A migration is already in progress for this account", nil, "")
+	}
+
+	migration := models.PhoneNumberMigration{
+		OrganizationID:    orgID,
+		WhatsAppAccountID: account.ID,
+		WhatsAppAccount:   account.Name,
+		CountryCode:       req.CountryCode,
+		PhoneNumber:       req.PhoneNumber,
+		FromBusinessID:    account.BusinessID,
+		FromPhoneID:       account.PhoneID,
+		ToBusinessID:      req.TargetBusinessID,
+		ToAppID:           req.AppID,
+		ToAccessToken:     req.AccessToken,
+		Status:            models.PhoneMigrationStarted,
+		Linkage:           a.accountLinkage(orgID, account.Name),
+		CreatedBy:         userID,
+	}
+	if migration.ToAccessToken == "" {
+		migration.ToAccessToken = account.AccessToken
+	}
+
+	target := migrationTargetAccount(account, &migration)
+	phoneID, err := a.WhatsApp.MigratePhoneNumber(context.Background(), target, req.CountryCode, req.PhoneNumber)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to add the number to the target WABA: "+err.Error(), nil, "")
+	}
+	migration.ToPhoneID = phoneID
+
+	if err := a.DB.Create(&migration).Error; err != nil {
+		a.Log.Error("Failed to create phone number migration", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create migration", nil, "")
+	}
+
+	a.Log.Info("Phone number migration started", "account", account.Name, "from_waba", migration.FromBusinessID, "to_waba", migration.ToBusinessID)
+
+	resp := map[string]interface{}{
+		"migration": migration,
+	}
+
+	// Templates belong to the WABA, so submit them early to have them approved by cutover
+	if req.CopyTemplates {
+		targetModel := *account
+		targetModel.PhoneID = migration.ToPhoneID
+		targetModel.BusinessID = migration.ToBusinessID
+		targetModel.AccessToken = migration.ToAccessToken
+
+		results, err := a.copyTemplatesToWABA(account, &targetModel, nil)
+		if err != nil {
+			a.Log.Error("Failed to copy templates to target WABA", "error", err, "account", account.Name)
+			resp["templates_error"] = err.Error()
+		} else {
+			resp["templates"] = results
+		}
+	}
+
+	return r.SendEnvelope(resp)
+}
+
+// RequestPhoneMigrationCode asks Meta to send the verification code for the migrating
+// number. It can be called again to resend the code.
+func (a *App) RequestPhoneMigrationCode(r *fastglue.Request) error {
+	return a.phoneMigrationStep(r, []string{models.PhoneMigrationStarted, models.PhoneMigrationCodeSent},
+		func(account *models.WhatsAppAccount, migration *models.PhoneNumberMigration, req *PhoneMigrationStepRequest) error {
+			method := req.CodeMethod
+			if method == "" {
+				method = "SMS"
+			}
+			if method != "SMS" && method != "VOICE" {
+				return fmt.Errorf("%w: code_method must be SMS or VOICE", errPhoneMigrationInput)
+			}
+			language := req.Language
+			if language == "" {
+				language = "en_US"
+			}
+
+			if err := a.WhatsApp.RequestVerificationCode(context.Background(), migrationTargetAccount(account, migration), method, language); err != nil {
+				return err
+			}
+			migration.Status = models.PhoneMigrationCodeSent
+			return nil
+		})
+}
+
+// VerifyPhoneMigrationCode verifies the migrating number with the code Meta sent
+func (a *App) VerifyPhoneMigrationCode(r *fastglue.Request) error {
+	return a.phoneMigrationStep(r, []string{models.PhoneMigrationCodeSent},
+		func(account *models.WhatsAppAccount, migration *models.PhoneNumberMigration, req *PhoneMigrationStepRequest) error {
+			if req.Code == "" {
+				return fmt.Errorf("%w: code is required", errPhoneMigrationInput)
+			}
+			if err := a.WhatsApp.VerifyCode(context.Background(), migrationTargetAccount(account, migration), req.Code); err != nil {
+				return err
+			}
+			migration.Status = models.PhoneMigrationVerified
+			return nil
+		})
+}
+
+// CompletePhoneMigration registers the number on the target WABA, subscribes the app to
+// its webhooks and re-points the account at the new WABA. The account keeps its name,
+// so its contacts, conversations and campaigns carry over unchanged.
+func (a *App) CompletePhoneMigration(r *fastglue.Request) error {
+	return a.phoneMigrationStep(r, []string{models.PhoneMigrationVerified},
+		func(account *models.WhatsAppAccount, migration *models.PhoneNumberMigration, req *PhoneMigrationStepRequest) error {
+			if len(req.Pin) != 6 {
+				return fmt.Errorf("%w: pin must be 6 digits", errPhoneMigrationInput)
+			}
+
+			target := migrationTargetAccount(account, migration)
+			ctx := context.Background()
+			if err := a.WhatsApp.RegisterPhoneNumber(ctx, target, req.Pin); err != nil {
+				return err
+			}
+			if err := a.WhatsApp.SubscribeApp(ctx, target); err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{
+				"business_id":  migration.ToBusinessID,
+				"phone_id":     migration.ToPhoneID,
+				"access_token": migration.ToAccessToken,
+			}
+			if migration.ToAppID != "" {
+				updates["app_id"] = migration.ToAppID
+			}
+			if err := a.DB.Model(account).Updates(updates).Error; err != nil {
+				return fmt.Errorf("number registered but failed to update account: %w", err)
+			}
+			account.BusinessID = migration.ToBusinessID
+			account.PhoneID = migration.ToPhoneID
+			account.AccessToken = migration.ToAccessToken
+
+			a.InvalidateWhatsAppAccountCache(migration.FromPhoneID)
+			a.InvalidateWhatsAppAccountCache(migration.ToPhoneID)
+			if a.Egress != nil {
+				a.Egress.Invalidate()
+			}
+
+			now := time.Now()
+			migration.Status = models.PhoneMigrationCompleted
+			migration.CompletedAt = &now
+			a.Log.Info("Phone number migration completed", "account", account.Name, "waba_id", migration.ToBusinessID, "phone_id", migration.ToPhoneID)
+
+			// Point local templates at the new WABA's copies
+			if templates, err := a.fetchTemplatesFromMeta(account); err != nil {
+				a.Log.Error("Failed to sync templates after migration", "error", err, "account", account.Name)
+			} else {
+				for i := range templates {
+					a.upsertMetaTemplate(account.OrganizationID, account.Name, &templates[i])
+				}
+			}
+			return nil
+		})
+}
+
+// CancelPhoneMigration abandons the account's in-progress migration. The account is
+// left on its current WABA.
+func (a *App) CancelPhoneMigration(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	account, err := a.findMigrationAccount(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	migration, err := a.activePhoneMigration(account.ID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No migration in progress", nil, "")
+	}
+
+	if err := a.DB.Model(migration).Update("status", models.PhoneMigrationCancelled).Error; err != nil {
+		a.Log.Error("Failed to cancel phone number migration", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to cancel migration", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Migration cancelled"})
+}
+
+// ExportPhoneMigrationLinkage exports the account's contacts with their conversation
+// history counts as CSV, as a record of what is linked to the number before cutover
+func (a *App) ExportPhoneMigrationLinkage(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	account, err := a.findMigrationAccount(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	rows, err := a.DB.Raw(`
+		SELECT c.id, c.phone_number, c.profile_name, c.assigned_user_id, c.last_message_at, COUNT(m.id) AS message_count
+		FROM contacts c
+		LEFT JOIN messages m ON m.contact_id = c.id AND m.deleted_at IS NULL
+		WHERE c.organization_id = ? AND c.whats_app_account = ? AND c.deleted_at IS NULL
+		GROUP BY c.id
+		ORDER BY c.phone_number`, orgID, account.Name).Rows()
+	if err != nil {
+		a.Log.Error("Failed to export account linkage", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export linkage", nil, "")
+	}
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"contact_id", "phone_number", "profile_name", "assigned_user_id", "last_message_at", "message_count"})
+	for rows.Next() {
+		var (
+			id             uuid.UUID
+			phone, name    string
+			assignedUserID *uuid.UUID
+			lastMessageAt  *time.Time
+			messageCount   int64
+		)
+		if err := rows.Scan(&id, &phone, &name, &assignedUserID, &lastMessageAt, &messageCount); err != nil {
+			a.Log.Error("Failed to scan account linkage", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to export linkage", nil, "")
+		}
+
+		assigned, last := "", ""
+		if assignedUserID != nil {
+			assigned = assignedUserID.String()
+		}
+		if lastMessageAt != nil {
+			last = lastMessageAt.UTC().Format(time.RFC3339)
+		}
+		_ = w.Write([]string{id.String(), phone, name, assigned, last, strconv.FormatInt(messageCount, 10)})
+	}
+	w.Flush()
+
+	r.RequestCtx.Response.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-linkage.csv"`, account.Name))
+	r.RequestCtx.SetBody(buf.Bytes())
+	return nil
+}
+
+// phoneMigrationStep runs one step of the account's in-progress migration if it is in
+// one of the allowed statuses, recording the step's error or new status
+func (a *App) phoneMigrationStep(r *fastglue.Request, allowed []string,
+	step func(*models.WhatsAppAccount, *models.PhoneNumberMigration, *PhoneMigrationStepRequest) error) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	account, err := a.findMigrationAccount(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	migration, err := a.activePhoneMigration(account.ID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "No migration in progress", nil, "")
+	}
+
+	var req PhoneMigrationStepRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	permitted := false
+	for _, status := range allowed {
+		if migration.Status == status {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Migration is not at this step (status: "+migration.Status+")", nil, "")
+	}
+
+	if err := step(account, migration, &req); err != nil {
+		if errors.Is(err, errPhoneMigrationInput) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		a.DB.Model(migration).Update("last_error", err.Error())
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Migration step failed: "+err.Error(), nil, "")
+	}
+
+	migration.LastError = ""
+	if err := a.DB.Save(migration).Error; err != nil {
+		a.Log.Error("Failed to update phone number migration", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update migration", nil, "")
+	}
+
+	return r.SendEnvelope(migration)
+}
+
+// accountLinkage counts the records that reference an account by name
+func (a *App) accountLinkage(orgID uuid.UUID, accountName string) models.JSONB {
+	linkage := models.JSONB{}
+	for key, model := range map[string]interface{}{
+		"contacts":  &models.Contact{},
+		"messages":  &models.Message{},
+		"campaigns": &models.BulkMessageCampaign{},
+		"templates": &models.Template{},
+	} {
+		var count int64
+		a.DB.Model(model).Where("organization_id = ? AND whats_app_account = ?", orgID, accountName).Count(&count)
+		linkage[key] = count
+	}
+	return linkage
+}
+
+// activePhoneMigration returns the account's migration that is neither completed nor cancelled
+func (a *App) activePhoneMigration(accountID uuid.UUID) (*models.PhoneNumberMigration, error) {
+	var migration models.PhoneNumberMigration
+	err := a.DB.Where("whats_app_account_id = ? AND status NOT IN ?", accountID,
+		[]string{models.PhoneMigrationCompleted, models.PhoneMigrationCancelled}).First(&migration).Error
+	if err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+func (a *App) findMigrationAccount(r *fastglue.Request, orgID uuid.UUID) (*models.WhatsAppAccount, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// migrationTargetAccount is the account as it will be on the target WABA
+func migrationTargetAccount(account *models.WhatsAppAccount, migration *models.PhoneNumberMigration) *whatsapp.Account {
+	return &whatsapp.Account{
+		PhoneID:     migration.ToPhoneID,
+		BusinessID:  migration.ToBusinessID,
+		APIVersion:  account.APIVersion,
+		AccessToken: migration.ToAccessToken,
+	}
+}
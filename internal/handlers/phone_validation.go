@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/phonenumber"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// maxPhoneValidationBatch caps the numbers validated per request
+const maxPhoneValidationBatch = 1000
+
+// undeliverableErrors are send failures meaning the number can't receive WhatsApp
+// messages: the Cloud API's "Message undeliverable" (131026) and the older "User is
+// not valid" (1013)
+var undeliverableErrors = []string{"131026", "undeliverable", "API error 1013:", "not a valid WhatsApp user"}
+
+// PhoneValidationRequest is the request body for validating phone numbers
+type PhoneValidationRequest struct {
+	PhoneNumbers   []string `json:"phone_numbers"`
+	DefaultCountry string   `json:"default_country"` // ISO code for numbers written without a country code
+}
+
+// PhoneValidationResult is the outcome of validating one phone number
+type PhoneValidationResult struct {
+	Input        string `json:"input"`
+	Valid        bool   `json:"valid"`
+	PhoneNumber  string `json:"phone_number,omitempty"` // Digits with country code, as contacts are stored
+	E164         string `json:"e164,omitempty"`
+	CallingCode  string `json:"calling_code,omitempty"`
+	Country      string `json:"country,omitempty"`
+	LineType     string `json:"line_type,omitempty"` // A hint from the numbering plan
+	Error        string `json:"error,omitempty"`
+	Duplicate    bool   `json:"duplicate"`     // Same number as an earlier input
+	IsContact    bool   `json:"is_contact"`    // A contact of the organization
+	OptedOut     bool   `json:"opted_out"`     // The contact replied with an opt-out keyword
+	KnownInvalid bool   `json:"known_invalid"` // The last message sent to it was undeliverable
+}
+
+// ValidatePhoneNumbers normalizes a batch of phone numbers and flags the ones that
+// are invalid, duplicated, opted out or known to be undeliverable, so integrators can
+// clean a recipient list before creating a campaign
+func (a *App) ValidatePhoneNumbers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req PhoneValidationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.PhoneNumbers) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "phone_numbers is required", nil, "")
+	}
+	if len(req.PhoneNumbers) > maxPhoneValidationBatch {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest,
+			fmt.Sprintf("At most %d phone numbers can be validated at once", maxPhoneValidationBatch), nil, "")
+	}
+	if req.DefaultCountry != "" && !phonenumber.KnownCountry(req.DefaultCountry) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Unknown default_country", nil, "")
+	}
+
+	results := make([]PhoneValidationResult, len(req.PhoneNumbers))
+	seen := make(map[string]bool, len(req.PhoneNumbers))
+	phones := make([]string, 0, len(req.PhoneNumbers))
+	for i, input := range req.PhoneNumbers {
+		result := PhoneValidationResult{Input: input}
+		n, err := phonenumber.Parse(input, req.DefaultCountry)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Valid = true
+			result.PhoneNumber = n.Digits()
+			result.E164 = n.E164()
+			result.CallingCode = n.CallingCode
+			result.Country = n.Country
+			result.LineType = string(n.LineType)
+			result.Duplicate = seen[result.PhoneNumber]
+			if !seen[result.PhoneNumber] {
+				seen[result.PhoneNumber] = true
+				phones = append(phones, result.PhoneNumber)
+			}
+		}
+		results[i] = result
+	}
+
+	flags, err := a.phoneNumberFlags(orgID, phones)
+	if err != nil {
+		a.Log.Error("Failed to look up phone number flags", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to validate phone numbers", nil, "")
+	}
+
+	summary := map[string]int{"total": len(results), "valid": 0, "invalid": 0, "duplicate": 0, "opted_out": 0, "known_invalid": 0}
+	for i := range results {
+		result := &results[i]
+		if !result.Valid {
+			summary["invalid"]++
+			continue
+		}
+		summary["valid"]++
+		if result.Duplicate {
+			summary["duplicate"]++
+		}
+		if f, ok := flags[result.PhoneNumber]; ok {
+			result.IsContact = true
+			result.OptedOut = f.OptedOut
+			result.KnownInvalid = f.KnownInvalid
+			if f.OptedOut {
+				summary["opted_out"]++
+			}
+			if f.KnownInvalid {
+				summary["known_invalid"]++
+			}
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"results": results,
+		"summary": summary,
+	})
+}
+
+type phoneNumberFlag struct {
+	OptedOut     bool
+	KnownInvalid bool
+}
+
+// phoneNumberFlags returns what is known about the numbers that belong to contacts,
+// keyed by phone number
+func (a *App) phoneNumberFlags(orgID uuid.UUID, phones []string) (map[string]phoneNumberFlag, error) {
+	flags := make(map[string]phoneNumberFlag, len(phones))
+	if len(phones) == 0 {
+		return flags, nil
+	}
+
+	// Contacts may have been stored with a leading +
+	lookup := make([]string, 0, len(phones)*2)
+	for _, phone := range phones {
+		lookup = append(lookup, phone, "+"+phone)
+	}
+
+	var rows []struct {
+		PhoneNumber string
+		OptedOut    bool
+		LastStatus  string
+		LastError   string
+	}
+	if err := a.DB.Model(&models.Contact{}).
+		Select(`contacts.phone_number,
+			NOT (`+contactNotOptedOut+`) AS opted_out,
+			COALESCE(last.status, '') AS last_status,
+			COALESCE(last.error_message, '') AS last_error`, engagement.OptOutKeywords).
+		Joins(`LEFT JOIN LATERAL (
+			SELECT m.status, m.error_message FROM messages m
+			WHERE m.contact_id = contacts.id AND m.direction = 'outgoing' AND m.deleted_at IS NULL
+			ORDER BY m.created_at DESC LIMIT 1
+		) last ON true`).
+		Where("contacts.organization_id = ? AND contacts.phone_number IN ?", orgID, lookup).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		phone := strings.TrimPrefix(row.PhoneNumber, "+")
+		f := flags[phone]
+		f.OptedOut = f.OptedOut || row.OptedOut
+		f.KnownInvalid = f.KnownInvalid || (row.LastStatus == "failed" && undeliverable(row.LastError))
+		flags[phone] = f
+	}
+	return flags, nil
+}
+
+func undeliverable(errMsg string) bool {
+	lower := strings.ToLower(errMsg)
+	for _, pattern := range undeliverableErrors {
+		if strings.Contains(lower, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+	return false
+}
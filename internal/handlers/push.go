@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/push"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+// pushSendTimeout bounds sending one notification's pushes
+const pushSendTimeout = 30 * time.Second
+
+// RegisterPushDeviceRequest is the request body for registering a device token
+type RegisterPushDeviceRequest struct {
+	Platform   string `json:"platform"` // fcm, apns
+	Token      string `json:"token"`
+	DeviceName string `json:"device_name"`
+}
+
+// ListPushDevices returns the current user's registered devices
+func (a *App) ListPushDevices(r *fastglue.Request) error {
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var devices []models.PushDevice
+	if err := a.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&devices).Error; err != nil {
+		a.Log.Error("Failed to list push devices", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list devices", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{"devices": devices})
+}
+
+// RegisterPushDevice registers a device token for the current user. Apps call it on
+// every sign-in and whenever the provider issues a new token.
+func (a *App) RegisterPushDevice(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req RegisterPushDeviceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if !push.ValidPlatform(req.Platform) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Platform must be fcm or apns", nil, "")
+	}
+	if req.Token == "" || len(req.Token) > 512 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "A device token of up to 512 characters is required", nil, "")
+	}
+	if len(req.DeviceName) > 255 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Device name must be 255 characters or fewer", nil, "")
+	}
+
+	// A token is one app install, so it moves to whoever signed in on the device last
+	device := models.PushDevice{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Platform:       req.Platform,
+		Token:          req.Token,
+		DeviceName:     req.DeviceName,
+	}
+	if err := a.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"organization_id", "user_id", "platform", "device_name", "updated_at"}),
+	}).Create(&device).Error; err != nil {
+		a.Log.Error("Failed to register push device", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to register device", nil, "")
+	}
+	if err := a.DB.Where("token = ?", req.Token).First(&device).Error; err != nil {
+		a.Log.Error("Failed to load push device", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to register device", nil, "")
+	}
+
+	return r.SendEnvelope(device)
+}
+
+// DeletePushDevice stops push notifications to one of the current user's devices, e.g.
+// when they sign out of the app
+func (a *App) DeletePushDevice(r *fastglue.Request) error {
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid device ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.PushDevice{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete push device", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete device", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Device not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Device removed"})
+}
+
+// pushNewMessage notifies agents of a contact's incoming message on their devices. A
+// conversation's notifications collapse into one on the device.
+func (a *App) pushNewMessage(orgID uuid.UUID, userIDs []uuid.UUID, contact *models.Contact, message *models.Message, preview string) {
+	a.pushToUsers(orgID, userIDs, push.Notification{
+		Title:       contactDisplayName(contact),
+		Body:        preview,
+		CollapseKey: "conversation:" + contact.ID.String(),
+		Data: map[string]string{
+			"type":       "new_message",
+			"contact_id": contact.ID.String(),
+			"message_id": message.ID.String(),
+		},
+	})
+}
+
+// pushToUsers sends a notification to the devices of active users in the background,
+// removing devices whose tokens have expired
+func (a *App) pushToUsers(orgID uuid.UUID, userIDs []uuid.UUID, notification push.Notification) {
+	if len(userIDs) == 0 || (!a.Push.Enabled(push.PlatformFCM) && !a.Push.Enabled(push.PlatformAPNs)) {
+		return
+	}
+
+	go func() {
+		var devices []models.PushDevice
+		if err := a.DB.Where("organization_id = ? AND user_id IN ?", orgID, userIDs).
+			Where("user_id IN (?)", a.DB.Model(&models.User{}).Select("id").Where("is_active = ?", true)).
+			Find(&devices).Error; err != nil || len(devices) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), pushSendTimeout)
+		defer cancel()
+		for _, device := range devices {
+			if !a.Push.Enabled(device.Platform) {
+				continue
+			}
+			err := a.Push.Send(ctx, device.Platform, device.Token, notification)
+			if errors.Is(err, push.ErrInvalidToken) {
+				a.Log.Info("Removing push device with an expired token", "device_id", device.ID, "user_id", device.UserID)
+				a.DB.Delete(&device)
+				continue
+			}
+			if err != nil {
+				a.Log.Error("Failed to send push notification", "error", err, "device_id", device.ID, "platform", device.Platform)
+				continue
+			}
+			a.DB.Model(&device).Update("last_pushed_at", time.Now())
+		}
+	}()
+}
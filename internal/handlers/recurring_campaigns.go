@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/cron"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// RecurringCampaignRequest is the request body for creating or updating a recurring campaign
+type RecurringCampaignRequest struct {
+	Name            string            `json:"name"`
+	WhatsAppAccount string            `json:"whatsapp_account"`
+	IsEnabled       *bool             `json:"is_enabled"`
+	Schedule        string            `json:"schedule"`
+	TemplateID      string            `json:"template_id"`
+	TemplateParams  map[string]string `json:"template_params"`
+	Tag             string            `json:"tag"`
+	LabelID         *string           `json:"label_id"`
+	MinEngagement   int               `json:"min_engagement"`
+}
+
+// ListRecurringCampaigns returns all recurring campaigns for the organization
+func (a *App) ListRecurringCampaigns(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var recurring []models.RecurringCampaign
+	if err := a.DB.Where("organization_id = ?", orgID).Preload("Template").Order("created_at DESC").Find(&recurring).Error; err != nil {
+		a.Log.Error("Failed to list recurring campaigns", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list recurring campaigns", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"recurring_campaigns": recurring,
+	})
+}
+
+// CreateRecurringCampaign creates a recurring campaign
+func (a *App) CreateRecurringCampaign(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req RecurringCampaignRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	recurring := models.RecurringCampaign{
+		OrganizationID: orgID,
+		IsEnabled:      true,
+		CreatedBy:      userID,
+	}
+	if err := a.applyRecurringCampaignRequest(orgID, &recurring, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Create(&recurring).Error; err != nil {
+		a.Log.Error("Failed to create recurring campaign", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create recurring campaign", nil, "")
+	}
+
+	return r.SendEnvelope(recurring)
+}
+
+// GetRecurringCampaign returns a recurring campaign
+func (a *App) GetRecurringCampaign(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	recurring, err := a.findRecurringCampaign(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Recurring campaign not found", nil, "")
+	}
+
+	return r.SendEnvelope(recurring)
+}
+
+// UpdateRecurringCampaign updates a recurring campaign. Its next run is worked out
+// again from the new schedule.
+func (a *App) UpdateRecurringCampaign(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	recurring, err := a.findRecurringCampaign(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Recurring campaign not found", nil, "")
+	}
+
+	var req RecurringCampaignRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := a.applyRecurringCampaignRequest(orgID, recurring, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	recurring.Template = nil
+	if err := a.DB.Save(recurring).Error; err != nil {
+		a.Log.Error("Failed to update recurring campaign", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update recurring campaign", nil, "")
+	}
+
+	return r.SendEnvelope(recurring)
+}
+
+// DeleteRecurringCampaign deletes a recurring campaign. Campaigns it already created are kept.
+func (a *App) DeleteRecurringCampaign(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	recurring, err := a.findRecurringCampaign(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Recurring campaign not found", nil, "")
+	}
+
+	if err := a.DB.Delete(recurring).Error; err != nil {
+		a.Log.Error("Failed to delete recurring campaign", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete recurring campaign", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Recurring campaign deleted successfully"})
+}
+
+// applyRecurringCampaignRequest validates req and copies it onto recurring, scheduling
+// its next run if it is enabled
+func (a *App) applyRecurringCampaignRequest(orgID uuid.UUID, recurring *models.RecurringCampaign, req *RecurringCampaignRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Schedule = strings.TrimSpace(req.Schedule)
+	if req.Name == "" || req.WhatsAppAccount == "" || req.Schedule == "" {
+		return fmt.Errorf("name, whatsapp_account and schedule are required")
+	}
+	schedule, err := cron.Parse(req.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %v", err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("invalid schedule: it never fires")
+	}
+	if req.MinEngagement < 0 || req.MinEngagement > 100 {
+		return fmt.Errorf("min_engagement must be between 0 and 100")
+	}
+
+	var count int64
+	a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("WhatsApp account not found")
+	}
+
+	templateID, err := uuid.Parse(req.TemplateID)
+	if err != nil {
+		return fmt.Errorf("invalid template_id")
+	}
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ? AND whats_app_account = ?", templateID, orgID, req.WhatsAppAccount).First(&template).Error; err != nil {
+		return fmt.Errorf("template not found for this account")
+	}
+
+	var labelID *uuid.UUID
+	if req.LabelID != nil && *req.LabelID != "" {
+		id, err := uuid.Parse(*req.LabelID)
+		if err != nil {
+			return fmt.Errorf("invalid label_id")
+		}
+		a.DB.Model(&models.Label{}).Where("id = ? AND organization_id = ?", id, orgID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("label not found")
+		}
+		labelID = &id
+	}
+
+	params := models.JSONB{}
+	for k, v := range req.TemplateParams {
+		params[k] = v
+	}
+
+	recurring.Name = req.Name
+	recurring.WhatsAppAccount = req.WhatsAppAccount
+	if req.IsEnabled != nil {
+		recurring.IsEnabled = *req.IsEnabled
+	}
+	recurring.Schedule = req.Schedule
+	recurring.TemplateID = templateID
+	recurring.TemplateParams = params
+	recurring.Tag = strings.TrimSpace(req.Tag)
+	recurring.LabelID = labelID
+	recurring.MinEngagement = req.MinEngagement
+
+	recurring.NextRunAt = nil
+	if recurring.IsEnabled {
+		next := schedule.Next(time.Now().In(a.orgLocation(orgID)))
+		recurring.NextRunAt = &next
+	}
+	return nil
+}
+
+func (a *App) findRecurringCampaign(r *fastglue.Request, orgID uuid.UUID) (*models.RecurringCampaign, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var recurring models.RecurringCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Preload("Template").First(&recurring).Error; err != nil {
+		return nil, err
+	}
+	return &recurring, nil
+}
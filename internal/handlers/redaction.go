@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/redact"
+)
+
+// redactionRulesFromSettings reads the redaction rules from an organization's settings
+func redactionRulesFromSettings(settings models.JSONB) redact.Rules {
+	rules := redact.Rules{Custom: []redact.Rule{}}
+	if settings == nil {
+		return rules
+	}
+	v, ok := settings["redaction_rules"]
+	if !ok {
+		return rules
+	}
+	// Round-trip through JSON since JSONB holds the rules as generic maps
+	data, err := json.Marshal(v)
+	if err != nil {
+		return rules
+	}
+	_ = json.Unmarshal(data, &rules)
+	if rules.Custom == nil {
+		rules.Custom = []redact.Rule{}
+	}
+	return rules
+}
+
+// redactMessage masks what the organization's redaction rules match in message text
+// that leaves the system: log lines, outbound webhook payloads and analytics
+// responses. The stored message keeps the original.
+func (a *App) redactMessage(orgID uuid.UUID, text string) string {
+	if text == "" {
+		return text
+	}
+	rules, err := a.getRedactionRulesCached(orgID)
+	if err != nil {
+		a.Log.Error("Failed to load redaction rules", "error", err, "organization_id", orgID)
+		return text
+	}
+	redactor, err := redact.New(rules)
+	if err != nil {
+		// Rules are validated on save, so this only happens for hand-edited settings
+		a.Log.Error("Invalid redaction rules", "error", err, "organization_id", orgID)
+		return text
+	}
+	return redactor.String(text)
+}
+
+// getRedactionRulesCached retrieves an organization's redaction rules from cache or database
+func (a *App) getRedactionRulesCached(orgID uuid.UUID) (redact.Rules, error) {
+	ctx := context.Background()
+	cacheKey := redactionRulesCachePrefix + orgID.String()
+
+	cached, err := a.Redis.Get(ctx, cacheKey).Result()
+	if err == nil && cached != "" {
+		var rules redact.Rules
+		if err := json.Unmarshal([]byte(cached), &rules); err == nil {
+			return rules, nil
+		}
+	}
+
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return redact.Rules{}, err
+	}
+	rules := redactionRulesFromSettings(org.Settings)
+
+	if data, err := json.Marshal(rules); err == nil {
+		a.Redis.Set(ctx, cacheKey, data, redactionRulesCacheTTL)
+	}
+	return rules, nil
+}
+
+// InvalidateRedactionRulesCache invalidates the redaction rules cache for an organization
+func (a *App) InvalidateRedactionRulesCache(orgID uuid.UUID) {
+	a.Redis.Del(context.Background(), redactionRulesCachePrefix+orgID.String())
+}
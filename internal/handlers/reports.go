@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/reports"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// ReportRequest represents the request body for creating/updating a report
+type ReportRequest struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Type        string       `json:"type"`   // custom (default), campaign_summary, agent_performance
+	Format      string       `json:"format"` // csv (default), pdf
+	Dimensions  []string     `json:"dimensions"`
+	Metrics     []string     `json:"metrics"`
+	Filters     models.JSONB `json:"filters"`
+	Schedule    *string      `json:"schedule"`
+	Recipients  []string     `json:"recipients"`
+}
+
+// RunReportRequest represents the request body for running a report
+type RunReportRequest struct {
+	From string `json:"from"` // YYYY-MM-DD, defaults to 30 days ago
+	To   string `json:"to"`   // YYYY-MM-DD, defaults to today in the organization's timezone
+}
+
+// GetReportFields returns the types, dimensions, metrics, schedules and formats reports can use
+func (a *App) GetReportFields(r *fastglue.Request) error {
+	return r.SendEnvelope(map[string]interface{}{
+		"types":      reports.Types,
+		"dimensions": reports.Dimensions,
+		"metrics":    reports.Metrics,
+		"schedules":  reports.Schedules,
+		"formats":    reports.Formats,
+		"filters":    []string{"templates", "agents", "tags", "accounts"},
+	})
+}
+
+// ListReports returns the organization's report definitions
+func (a *App) ListReports(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var list []models.Report
+	if err := a.DB.Where("organization_id = ?", orgID).Order("name ASC").Find(&list).Error; err != nil {
+		a.Log.Error("Failed to list reports", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list reports", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"reports": list,
+	})
+}
+
+// CreateReport creates a report definition
+func (a *App) CreateReport(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req ReportRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.Name == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "name is required", nil, "")
+	}
+	schedule := ""
+	if req.Schedule != nil {
+		schedule = *req.Schedule
+	}
+	if req.Filters == nil {
+		req.Filters = models.JSONB{}
+	}
+	if req.Type == "" {
+		req.Type = reports.TypeCustom
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+
+	report := models.Report{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Type:           req.Type,
+		Format:         req.Format,
+		Dimensions:     models.StringArray(req.Dimensions),
+		Metrics:        models.StringArray(req.Metrics),
+		Filters:        req.Filters,
+		Schedule:       schedule,
+		Recipients:     models.StringArray(req.Recipients),
+		NextRunAt:      reports.NextRun(schedule, a.orgCalendar(orgID), time.Now()),
+	}
+	if report.Dimensions == nil {
+		report.Dimensions = models.StringArray{}
+	}
+	if report.Recipients == nil {
+		report.Recipients = models.StringArray{}
+	}
+	if err := reports.Validate(&report); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		report.CreatedByID = &userID
+	}
+
+	if err := a.DB.Create(&report).Error; err != nil {
+		a.Log.Error("Failed to create report", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create report", nil, "")
+	}
+
+	return r.SendEnvelope(report)
+}
+
+// GetReport returns a report definition with its most recent runs
+func (a *App) GetReport(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	report, err := a.findReport(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Report not found", nil, "")
+	}
+
+	var runs []models.ReportRun
+	a.DB.Omit("rows").Where("report_id = ?", report.ID).Order("created_at DESC").Limit(10).Find(&runs)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"report": report,
+		"runs":   runs,
+	})
+}
+
+// UpdateReport updates a report definition
+func (a *App) UpdateReport(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	report, err := a.findReport(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Report not found", nil, "")
+	}
+
+	var req ReportRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	if req.Name != "" {
+		report.Name = req.Name
+	}
+	if req.Description != "" {
+		report.Description = req.Description
+	}
+	if req.Type != "" {
+		report.Type = req.Type
+	}
+	if req.Format != "" {
+		report.Format = req.Format
+	}
+	if req.Dimensions != nil {
+		report.Dimensions = models.StringArray(req.Dimensions)
+	}
+	if req.Metrics != nil {
+		report.Metrics = models.StringArray(req.Metrics)
+	}
+	if req.Filters != nil {
+		report.Filters = req.Filters
+	}
+	if req.Recipients != nil {
+		report.Recipients = models.StringArray(req.Recipients)
+	}
+	if req.Schedule != nil && *req.Schedule != report.Schedule {
+		report.Schedule = *req.Schedule
+		report.NextRunAt = reports.NextRun(report.Schedule, a.orgCalendar(orgID), time.Now())
+	}
+
+	if err := reports.Validate(report); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Save(report).Error; err != nil {
+		a.Log.Error("Failed to update report", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update report", nil, "")
+	}
+
+	return r.SendEnvelope(report)
+}
+
+// DeleteReport deletes a report definition and its runs
+func (a *App) DeleteReport(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	report, err := a.findReport(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Report not found", nil, "")
+	}
+
+	a.DB.Where("report_id = ?", report.ID).Delete(&models.ReportRun{})
+	if err := a.DB.Delete(report).Error; err != nil {
+		a.Log.Error("Failed to delete report", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete report", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{
+		"message": "Report deleted",
+	})
+}
+
+// RunReport queues a run of the report for the workers
+func (a *App) RunReport(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	report, err := a.findReport(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Report not found", nil, "")
+	}
+
+	var req RunReportRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+
+	to := a.orgCalendar(orgID).Date(time.Now())
+	if req.To != "" {
+		if to, err = time.Parse("2006-01-02", req.To); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'to' date format. Use YYYY-MM-DD", nil, "")
+		}
+	}
+	from := to.AddDate(0, 0, -29)
+	if req.From != "" {
+		if from, err = time.Parse("2006-01-02", req.From); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid 'from' date format. Use YYYY-MM-DD", nil, "")
+		}
+	}
+	if from.After(to) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "'from' must not be after 'to'", nil, "")
+	}
+	if to.Sub(from) > reports.MaxPeriodDays*24*time.Hour {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("Date range cannot exceed %d days", reports.MaxPeriodDays), nil, "")
+	}
+
+	run := models.ReportRun{
+		OrganizationID: orgID,
+		ReportID:       report.ID,
+		Trigger:        "manual",
+		Status:         "queued",
+		PeriodStart:    from,
+		PeriodEnd:      to,
+	}
+	if userID, err := a.getUserIDFromContext(r); err == nil {
+		run.CreatedByID = &userID
+	}
+	if err := a.DB.Create(&run).Error; err != nil {
+		a.Log.Error("Failed to queue report run", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to queue report run", nil, "")
+	}
+	if tracker, err := a.startJob(r, orgID, models.JobTypeReport, &run.ID); err == nil {
+		jobID := tracker.JobID()
+		run.JobID = &jobID
+		a.DB.Model(&run).Update("job_id", jobID)
+	} else {
+		a.Log.Error("Failed to record report run job", "error", err, "run_id", run.ID)
+	}
+
+	return r.SendEnvelope(run)
+}
+
+// ListReportRuns returns a report's runs without their rows
+func (a *App) ListReportRuns(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	report, err := a.findReport(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Report not found", nil, "")
+	}
+
+	var runs []models.ReportRun
+	if err := a.DB.Omit("rows").Where("report_id = ?", report.ID).Order("created_at DESC").Limit(100).Find(&runs).Error; err != nil {
+		a.Log.Error("Failed to list report runs", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list report runs", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"runs": runs,
+	})
+}
+
+// GetReportRun returns a report run including its rows
+func (a *App) GetReportRun(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	run, err := a.findReportRun(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Report run not found", nil, "")
+	}
+
+	return r.SendEnvelope(run)
+}
+
+// DownloadReportRun returns a completed report run as CSV, or as PDF with ?format=pdf
+func (a *App) DownloadReportRun(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	run, err := a.findReportRun(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Report run not found", nil, "")
+	}
+	if run.Status != "completed" {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Report run has not completed", nil, "")
+	}
+
+	format := string(r.RequestCtx.QueryArgs().Peek("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if !slices.Contains(reports.Formats, format) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid format", nil, "")
+	}
+
+	var report models.Report
+	a.DB.Unscoped().Select("id", "name").Where("id = ?", run.ReportID).First(&report)
+
+	period := run.PeriodStart.Format("2006-01-02") + " to " + run.PeriodEnd.Format("2006-01-02")
+	data, contentType, err := reports.Render(format, []string{report.Name, period}, run.Columns, run.Rows)
+	if err != nil {
+		a.Log.Error("Failed to render report", "error", err, "format", format)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to render report", nil, "")
+	}
+
+	if format == "csv" {
+		contentType += "; charset=utf-8"
+	}
+	r.RequestCtx.Response.Header.Set("Content-Type", contentType)
+	r.RequestCtx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, reports.Filename(report.Name, run.PeriodEnd, format)))
+	r.RequestCtx.SetBody(data)
+
+	return nil
+}
+
+// findReport loads the organization's report named by the {id} path parameter
+func (a *App) findReport(r *fastglue.Request, orgID uuid.UUID) (*models.Report, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var report models.Report
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// findReportRun loads the run named by the {run_id} path parameter of the report named by {id}
+func (a *App) findReportRun(r *fastglue.Request, orgID uuid.UUID) (*models.ReportRun, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	reportID, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+	runIDStr, _ := r.RequestCtx.UserValue("run_id").(string)
+	runID, err := uuid.Parse(runIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var run models.ReportRun
+	if err := a.DB.Where("id = ? AND report_id = ? AND organization_id = ?", runID, reportID, orgID).First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// orgCalendar returns the calendar the organization's analytics are bucketed by
+func (a *App) orgCalendar(orgID uuid.UUID) reports.Calendar {
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return reports.UTCCalendar
+	}
+	return reports.OrgCalendar(org.Settings)
+}
+
+// rescheduleReports moves the organization's scheduled reports to their next run in
+// its calendar, after the calendar changed
+func (a *App) rescheduleReports(orgID uuid.UUID, cal reports.Calendar) {
+	var scheduled []models.Report
+	if err := a.DB.Where("organization_id = ? AND schedule <> ''", orgID).Find(&scheduled).Error; err != nil {
+		a.Log.Error("Failed to load scheduled reports", "error", err, "organization_id", orgID)
+		return
+	}
+	now := time.Now()
+	for i := range scheduled {
+		a.DB.Model(&scheduled[i]).Update("next_run_at", reports.NextRun(scheduled[i].Schedule, cal, now))
+	}
+}
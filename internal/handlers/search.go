@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/search"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+const (
+	// searchConversationLimit caps the conversations returned with a search
+	searchConversationLimit = 50
+
+	// searchDateFacetLimit caps the days in the date facet, most recent first
+	searchDateFacetLimit = 30
+)
+
+// SearchConversation is a conversation with messages matching a search
+type SearchConversation struct {
+	ContactID      uuid.UUID      `json:"contact_id"`
+	PhoneNumber    string         `json:"phone_number"`
+	Name           string         `json:"name"`
+	AssignedUserID *uuid.UUID     `json:"assigned_user_id,omitempty"`
+	Matches        int64          `json:"matches"`
+	LastMatchAt    time.Time      `json:"last_match_at"`
+	Labels         []LabelSummary `json:"labels"`
+}
+
+// SearchFacets counts the matching messages by the values each qualifier can take,
+// so a search can be narrowed down
+type SearchFacets struct {
+	Agents   []SearchFacet `json:"agents"`
+	Labels   []SearchFacet `json:"labels"`
+	Accounts []SearchFacet `json:"accounts"`
+	Dates    []SearchFacet `json:"dates"`
+	HasMedia int64         `json:"has_media"`
+	From     SearchSenders `json:"from"`
+}
+
+// SearchFacet is one facet value, with the qualifier that selects it
+type SearchFacet struct {
+	Value string `json:"value"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+	Query string `json:"query"`
+}
+
+// SearchSenders counts the matching messages by sender
+type SearchSenders struct {
+	Contact  int64 `json:"contact"`
+	Agent    int64 `json:"agent"`
+	Campaign int64 `json:"campaign"`
+}
+
+// SearchConversations searches messages across conversations with the search query
+// syntax, returning the matching messages, their conversations and facets.
+// Agents only search contacts assigned to them.
+func (a *App) SearchConversations(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	role, _ := r.RequestCtx.UserValue("role").(string)
+
+	query, err := search.Parse(string(r.RequestCtx.QueryArgs().Peek("q")), a.orgLocation(orgID))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid search query: "+err.Error(), nil, "")
+	}
+	if query.IsEmpty() {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Search query is required", nil, "")
+	}
+
+	page, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(r.RequestCtx.QueryArgs().Peek("limit")))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	matches := a.searchMessages(orgID, userID, role, query)
+
+	var total int64
+	if err := matches.Count(&total).Error; err != nil {
+		a.Log.Error("Failed to count search results", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to search", nil, "")
+	}
+
+	var messages []models.Message
+	if err := matches.Select("messages.*").
+		Order("messages.created_at DESC").
+		Offset((page - 1) * limit).Limit(limit).
+		Find(&messages).Error; err != nil {
+		a.Log.Error("Failed to search messages", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to search", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"messages":      a.buildMessagesResponse(messages),
+		"conversations": a.searchConversations(orgID, matches),
+		"facets":        a.searchFacets(orgID, matches),
+		"total":         total,
+		"page":          page,
+		"limit":         limit,
+	})
+}
+
+// searchMessages returns the organization's messages matching query, joined with
+// their contacts. The returned query can be reused.
+func (a *App) searchMessages(orgID, userID uuid.UUID, role string, query *search.Query) *gorm.DB {
+	db := a.DB.Table("messages").
+		Joins("JOIN contacts ON contacts.id = messages.contact_id AND contacts.deleted_at IS NULL").
+		Where("messages.organization_id = ? AND messages.deleted_at IS NULL", orgID)
+
+	if role == "agent" {
+		db = db.Where("contacts.assigned_user_id = ?", userID)
+	}
+
+	for _, term := range query.Terms {
+		pattern := "%" + term + "%"
+		db = db.Where("(messages.content ILIKE ? OR messages.transcript ILIKE ?)", pattern, pattern)
+	}
+
+	switch query.Agent {
+	case "":
+	case search.AgentMe:
+		db = db.Where("contacts.assigned_user_id = ?", userID)
+	case search.AgentUnassigned:
+		db = db.Where("contacts.assigned_user_id IS NULL")
+	default:
+		if agentID, err := uuid.Parse(query.Agent); err == nil {
+			db = db.Where("contacts.assigned_user_id = ?", agentID)
+		} else {
+			db = db.Where("contacts.assigned_user_id IN (SELECT id FROM users WHERE organization_id = ? AND LOWER(email) = LOWER(?) AND deleted_at IS NULL)", orgID, query.Agent)
+		}
+	}
+
+	for _, label := range query.Labels {
+		db = db.Where(`EXISTS (SELECT 1 FROM conversation_labels cl JOIN labels l ON l.id = cl.label_id AND l.deleted_at IS NULL
+			WHERE cl.contact_id = contacts.id AND cl.deleted_at IS NULL AND LOWER(l.name) = LOWER(?))`, label)
+	}
+
+	if query.Account != "" {
+		db = db.Where("messages.whats_app_account = ?", query.Account)
+	}
+	if query.After != nil {
+		db = db.Where("messages.created_at >= ?", *query.After)
+	}
+	if query.Before != nil {
+		db = db.Where("messages.created_at < ?", *query.Before)
+	}
+	if query.HasMedia {
+		db = db.Where("messages.media_url <> ''")
+	}
+
+	switch query.From {
+	case search.FromContact:
+		db = db.Where("messages.direction = ?", "incoming")
+	case search.FromAgent:
+		db = db.Where("messages.direction = ? AND messages.sent_by_user_id IS NOT NULL", "outgoing")
+	case search.FromCampaign:
+		db = db.Where("messages.direction = ? AND messages.metadata->>'campaign_id' IS NOT NULL", "outgoing")
+	}
+
+	return db.Session(&gorm.Session{})
+}
+
+// searchConversations groups the matching messages by conversation, most recent
+// match first
+func (a *App) searchConversations(orgID uuid.UUID, matches *gorm.DB) []SearchConversation {
+	var rows []struct {
+		ContactID      uuid.UUID
+		PhoneNumber    string
+		ProfileName    string
+		AssignedUserID *uuid.UUID
+		Matches        int64
+		LastMatchAt    time.Time
+	}
+	if err := matches.Select(`contacts.id AS contact_id, contacts.phone_number, contacts.profile_name,
+		contacts.assigned_user_id, COUNT(*) AS matches, MAX(messages.created_at) AS last_match_at`).
+		Group("contacts.id").
+		Order("last_match_at DESC").
+		Limit(searchConversationLimit).
+		Scan(&rows).Error; err != nil {
+		a.Log.Error("Failed to group search results", "error", err)
+		return []SearchConversation{}
+	}
+
+	contactIDs := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		contactIDs[i] = row.ContactID
+	}
+	labels := a.conversationLabels(contactIDs)
+	shouldMask := a.ShouldMaskPhoneNumbers(orgID)
+
+	conversations := make([]SearchConversation, len(rows))
+	for i, row := range rows {
+		phoneNumber, name := row.PhoneNumber, row.ProfileName
+		if shouldMask {
+			phoneNumber = MaskPhoneNumber(phoneNumber)
+			name = MaskIfPhoneNumber(name)
+		}
+		conversations[i] = SearchConversation{
+			ContactID:      row.ContactID,
+			PhoneNumber:    phoneNumber,
+			Name:           name,
+			AssignedUserID: row.AssignedUserID,
+			Matches:        row.Matches,
+			LastMatchAt:    row.LastMatchAt,
+			Labels:         labels[row.ContactID],
+		}
+	}
+	return conversations
+}
+
+// searchFacets counts the matching messages by agent, label, account, day, media
+// and sender
+func (a *App) searchFacets(orgID uuid.UUID, matches *gorm.DB) SearchFacets {
+	facets := SearchFacets{
+		Agents:   []SearchFacet{},
+		Labels:   []SearchFacet{},
+		Accounts: []SearchFacet{},
+		Dates:    []SearchFacet{},
+	}
+
+	var agents []struct {
+		ID    *uuid.UUID
+		Email string
+		Name  string
+		Count int64
+	}
+	if err := matches.Select("users.id, users.email, users.full_name AS name, COUNT(*) AS count").
+		Joins("LEFT JOIN users ON users.id = contacts.assigned_user_id").
+		Group("users.id, users.email, users.full_name").
+		Order("count DESC").
+		Scan(&agents).Error; err != nil {
+		a.Log.Error("Failed to load agent facet", "error", err)
+	}
+	for _, agent := range agents {
+		if agent.ID == nil {
+			facets.Agents = append(facets.Agents, SearchFacet{
+				Value: search.AgentUnassigned, Name: "Unassigned", Count: agent.Count, Query: "agent:" + search.AgentUnassigned,
+			})
+			continue
+		}
+		facets.Agents = append(facets.Agents, SearchFacet{
+			Value: agent.ID.String(), Name: agent.Name, Count: agent.Count, Query: "agent:" + agent.Email,
+		})
+	}
+
+	var labels []struct {
+		ID    uuid.UUID
+		Name  string
+		Count int64
+	}
+	if err := matches.Select("l.id, l.name, COUNT(*) AS count").
+		Joins("JOIN conversation_labels cl ON cl.contact_id = contacts.id AND cl.deleted_at IS NULL").
+		Joins("JOIN labels l ON l.id = cl.label_id AND l.deleted_at IS NULL").
+		Group("l.id, l.name").
+		Order("count DESC").
+		Scan(&labels).Error; err != nil {
+		a.Log.Error("Failed to load label facet", "error", err)
+	}
+	for _, label := range labels {
+		facets.Labels = append(facets.Labels, SearchFacet{
+			Value: label.ID.String(), Name: label.Name, Count: label.Count, Query: "label:" + searchQueryValue(label.Name),
+		})
+	}
+
+	var accounts []struct {
+		Name  string
+		Count int64
+	}
+	if err := matches.Select("messages.whats_app_account AS name, COUNT(*) AS count").
+		Group("messages.whats_app_account").
+		Order("count DESC").
+		Scan(&accounts).Error; err != nil {
+		a.Log.Error("Failed to load account facet", "error", err)
+	}
+	for _, account := range accounts {
+		facets.Accounts = append(facets.Accounts, SearchFacet{
+			Value: account.Name, Name: account.Name, Count: account.Count, Query: "account:" + searchQueryValue(account.Name),
+		})
+	}
+
+	// Days are counted in the organization's timezone, like the date qualifiers
+	var dates []struct {
+		Day   string
+		Count int64
+	}
+	if err := matches.Select("TO_CHAR(messages.created_at AT TIME ZONE ?, 'YYYY-MM-DD') AS day, COUNT(*) AS count", a.orgLocation(orgID).String()).
+		Group("day").
+		Order("day DESC").
+		Limit(searchDateFacetLimit).
+		Scan(&dates).Error; err != nil {
+		a.Log.Error("Failed to load date facet", "error", err)
+	}
+	for _, date := range dates {
+		facets.Dates = append(facets.Dates, SearchFacet{
+			Value: date.Day, Name: date.Day, Count: date.Count, Query: "on:" + date.Day,
+		})
+	}
+
+	var counts struct {
+		HasMedia     int64
+		FromContact  int64
+		FromAgent    int64
+		FromCampaign int64
+	}
+	if err := matches.Select(`COUNT(CASE WHEN messages.media_url <> '' THEN 1 END) AS has_media,
+		COUNT(CASE WHEN messages.direction = 'incoming' THEN 1 END) AS from_contact,
+		COUNT(CASE WHEN messages.direction = 'outgoing' AND messages.sent_by_user_id IS NOT NULL THEN 1 END) AS from_agent,
+		COUNT(CASE WHEN messages.direction = 'outgoing' AND messages.metadata->>'campaign_id' IS NOT NULL THEN 1 END) AS from_campaign`).
+		Scan(&counts).Error; err != nil {
+		a.Log.Error("Failed to load message facets", "error", err)
+	}
+	facets.HasMedia = counts.HasMedia
+	facets.From = SearchSenders{
+		Contact:  counts.FromContact,
+		Agent:    counts.FromAgent,
+		Campaign: counts.FromCampaign,
+	}
+
+	return facets
+}
+
+// searchQueryValue quotes a qualifier value that contains spaces
+func searchQueryValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
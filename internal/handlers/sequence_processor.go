@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+const (
+	// sequenceLockKey keeps server instances from running the same cycle twice
+	sequenceLockKey = "sequences:processor_lock"
+
+	// sequenceSendBatch caps messages sent per sequence per cycle
+	sequenceSendBatch = 100
+)
+
+// SequenceProcessor advances enrolled contacts through their sequences, sending each
+// step when it comes due and ending enrollments whose contact replied or opted out
+type SequenceProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewSequenceProcessor creates a new sequence processor
+func NewSequenceProcessor(app *App, interval time.Duration) *SequenceProcessor {
+	return &SequenceProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the sequence processing loop
+func (p *SequenceProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Sequence processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Sequence processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Sequence processor stopped")
+			return
+		case <-ticker.C:
+			p.processSequences(ctx)
+		}
+	}
+}
+
+// Stop stops the sequence processor
+func (p *SequenceProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// processSequences runs one cycle of every enabled sequence
+func (p *SequenceProcessor) processSequences(ctx context.Context) {
+	acquired, err := p.app.Redis.SetNX(ctx, sequenceLockKey, 1, p.interval/2).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	var sequences []models.Sequence
+	if err := p.app.DB.Where("is_enabled = true").Find(&sequences).Error; err != nil {
+		p.app.Log.Error("Failed to load sequences", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range sequences {
+		sequence := &sequences[i]
+		steps, err := sequence.StepList()
+		if err != nil || len(steps) == 0 {
+			p.app.Log.Warn("Skipping sequence without valid steps", "sequence_id", sequence.ID)
+			continue
+		}
+
+		p.exitEnrollments(sequence, now)
+		p.sendDueSteps(sequence, steps, now)
+	}
+}
+
+// exitEnrollments ends active enrollments whose contact opted out or replied since
+// they were enrolled
+func (p *SequenceProcessor) exitEnrollments(sequence *models.Sequence, now time.Time) {
+	end := func(status, reason, condition string, args ...interface{}) {
+		result := p.app.DB.Model(&models.SequenceEnrollment{}).
+			Where("sequence_id = ? AND status = ?", sequence.ID, models.SequenceStatusActive).
+			Where(condition, args...).
+			Updates(map[string]interface{}{
+				"status":       status,
+				"exit_reason":  reason,
+				"ended_at":     now,
+				"next_send_at": nil,
+			})
+		if result.Error != nil {
+			p.app.Log.Error("Failed to end sequence enrollments", "error", result.Error, "sequence_id", sequence.ID, "reason", reason)
+		} else if result.RowsAffected > 0 {
+			p.app.Log.Info("Ended sequence enrollments", "sequence_id", sequence.ID, "reason", reason, "count", result.RowsAffected)
+		}
+	}
+
+	// Opt-outs are checked before replies since an opt-out is also a reply
+	end(models.SequenceStatusOptedOut, "opted out",
+		`EXISTS (SELECT 1 FROM messages m WHERE m.contact_id = sequence_enrollments.contact_id
+			AND m.direction = 'incoming' AND m.deleted_at IS NULL
+			AND m.created_at > sequence_enrollments.created_at AND UPPER(TRIM(m.content)) IN ?)`,
+		engagement.OptOutKeywords)
+
+	end(models.SequenceStatusReplied, "replied",
+		`EXISTS (SELECT 1 FROM messages m WHERE m.contact_id = sequence_enrollments.contact_id
+			AND m.direction = 'incoming' AND m.deleted_at IS NULL
+			AND m.created_at > sequence_enrollments.created_at)`)
+}
+
+// sendDueSteps sends the next step to enrollments that are due
+func (p *SequenceProcessor) sendDueSteps(sequence *models.Sequence, steps []models.SequenceStep, now time.Time) {
+	var due []models.SequenceEnrollment
+	err := p.app.DB.Where("sequence_id = ? AND status = ? AND next_send_at <= ?", sequence.ID, models.SequenceStatusActive, now).
+		Preload("Contact").
+		Order("next_send_at ASC").
+		Limit(sequenceSendBatch).
+		Find(&due).Error
+	if err != nil || len(due) == 0 {
+		return
+	}
+
+	var account models.WhatsAppAccount
+	if err := p.app.DB.Where("organization_id = ? AND name = ?", sequence.OrganizationID, sequence.WhatsAppAccount).First(&account).Error; err != nil {
+		p.app.Log.Error("Sequence account not found", "error", err, "sequence_id", sequence.ID, "account", sequence.WhatsAppAccount)
+		return
+	}
+
+	templates := make(map[uuid.UUID]*models.Template)
+	for i := range due {
+		enrollment := &due[i]
+		if enrollment.CurrentStep >= len(steps) || enrollment.Contact == nil {
+			p.endEnrollment(enrollment, models.SequenceStatusCompleted, "", now)
+			continue
+		}
+		step := steps[enrollment.CurrentStep]
+
+		template, ok := templates[step.TemplateID]
+		if !ok {
+			var t models.Template
+			if err := p.app.DB.Where("id = ? AND organization_id = ?", step.TemplateID, sequence.OrganizationID).First(&t).Error; err == nil {
+				template = &t
+			}
+			templates[step.TemplateID] = template
+		}
+		if template == nil || template.Status != "APPROVED" {
+			p.endEnrollment(enrollment, models.SequenceStatusFailed, fmt.Sprintf("step %d: template not found or not approved", enrollment.CurrentStep+1), now)
+			continue
+		}
+
+		if err := p.sendStep(sequence, &account, template, step, enrollment); err != nil {
+			p.app.Log.Error("Failed to send sequence message", "error", err, "enrollment_id", enrollment.ID)
+			p.endEnrollment(enrollment, models.SequenceStatusFailed, err.Error(), now)
+			continue
+		}
+
+		next := enrollment.CurrentStep + 1
+		if next >= len(steps) {
+			p.app.DB.Model(enrollment).Updates(map[string]interface{}{
+				"current_step": next,
+				"last_sent_at": now,
+				"status":       models.SequenceStatusCompleted,
+				"ended_at":     now,
+				"next_send_at": nil,
+			})
+			continue
+		}
+		// Step days count from enrollment, so a late send doesn't push back later steps
+		nextSend := enrollment.CreatedAt.AddDate(0, 0, steps[next].Day)
+		p.app.DB.Model(enrollment).Updates(map[string]interface{}{
+			"current_step": next,
+			"last_sent_at": now,
+			"next_send_at": nextSend,
+		})
+	}
+}
+
+// sendStep sends one step's template to the enrolled contact and records the message
+func (p *SequenceProcessor) sendStep(sequence *models.Sequence, account *models.WhatsAppAccount, template *models.Template, step models.SequenceStep, enrollment *models.SequenceEnrollment) error {
+	contact := enrollment.Contact
+
+	templateParams := models.JSONB{}
+	for k, v := range step.TemplateParams {
+		templateParams[k] = v
+	}
+	recipient := contactRecipient(contact, templateParams)
+	content := template.BodyContent
+	for key, value := range recipient.TemplateParams {
+		content = strings.ReplaceAll(content, fmt.Sprintf("{{%s}}", key), fmt.Sprint(value))
+	}
+
+	waMessageID, err := p.app.sendTemplateMessage(account, template, &recipient)
+	if err != nil {
+		return err
+	}
+
+	message := models.Message{
+		OrganizationID:    sequence.OrganizationID,
+		WhatsAppAccount:   account.Name,
+		ContactID:         contact.ID,
+		WhatsAppMessageID: waMessageID,
+		Direction:         "outgoing",
+		MessageType:       "template",
+		Content:           content,
+		TemplateName:      template.Name,
+		TemplateParams:    recipient.TemplateParams,
+		Status:            "sent",
+		Metadata: models.JSONB{
+			"sequence_id":            sequence.ID.String(),
+			"sequence_enrollment_id": enrollment.ID.String(),
+			"sequence_step":          enrollment.CurrentStep,
+		},
+	}
+	if err := p.app.DB.Create(&message).Error; err != nil {
+		p.app.Log.Error("Failed to save sequence message", "error", err, "enrollment_id", enrollment.ID)
+	}
+	return nil
+}
+
+// endEnrollment moves an enrollment to a final status
+func (p *SequenceProcessor) endEnrollment(enrollment *models.SequenceEnrollment, status, reason string, now time.Time) {
+	p.app.DB.Model(enrollment).Updates(map[string]interface{}{
+		"status":       status,
+		"exit_reason":  reason,
+		"ended_at":     now,
+		"next_send_at": nil,
+	})
+}
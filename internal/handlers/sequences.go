@@ -0,0 +1,478 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxSequenceSteps caps the template messages in one sequence
+	maxSequenceSteps = 20
+
+	// maxSequenceEnrollment caps the contacts enrolled by one request
+	maxSequenceEnrollment = 10000
+)
+
+// SequenceRequest is the request body for creating or updating a sequence
+type SequenceRequest struct {
+	Name            string                `json:"name"`
+	Description     string                `json:"description"`
+	WhatsAppAccount string                `json:"whatsapp_account"`
+	IsEnabled       bool                  `json:"is_enabled"`
+	Steps           []models.SequenceStep `json:"steps"`
+}
+
+// EnrollSequenceRequest is the request body for enrolling contacts into a sequence.
+// Contacts can be listed, taken from a broadcast list, or both.
+type EnrollSequenceRequest struct {
+	ContactIDs      []string `json:"contact_ids"`
+	BroadcastListID string   `json:"broadcast_list_id"`
+}
+
+// ListSequences returns all sequences for the organization
+func (a *App) ListSequences(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var sequences []models.Sequence
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&sequences).Error; err != nil {
+		a.Log.Error("Failed to list sequences", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list sequences", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"sequences": sequences,
+	})
+}
+
+// CreateSequence creates a sequence
+func (a *App) CreateSequence(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req SequenceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	sequence := models.Sequence{
+		OrganizationID: orgID,
+		CreatedBy:      userID,
+	}
+	if err := a.applySequenceRequest(orgID, &sequence, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Create(&sequence).Error; err != nil {
+		a.Log.Error("Failed to create sequence", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create sequence", nil, "")
+	}
+
+	return r.SendEnvelope(sequence)
+}
+
+// GetSequence returns a sequence with enrollment counts by status and the number of
+// contacts sent each step
+func (a *App) GetSequence(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	sequence, err := a.findSequence(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sequence not found", nil, "")
+	}
+
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	a.DB.Model(&models.SequenceEnrollment{}).
+		Select("status, COUNT(*) AS count").
+		Where("sequence_id = ?", sequence.ID).
+		Group("status").
+		Scan(&counts)
+
+	stats := map[string]int64{
+		models.SequenceStatusActive:     0,
+		models.SequenceStatusCompleted:  0,
+		models.SequenceStatusReplied:    0,
+		models.SequenceStatusOptedOut:   0,
+		models.SequenceStatusUnenrolled: 0,
+		models.SequenceStatusFailed:     0,
+	}
+	for _, c := range counts {
+		stats[c.Status] = c.Count
+	}
+
+	// current_step is the next step to send, so an enrollment past step i was sent it
+	var stepCounts []struct {
+		CurrentStep int
+		Count       int64
+	}
+	a.DB.Model(&models.SequenceEnrollment{}).
+		Select("current_step, COUNT(*) AS count").
+		Where("sequence_id = ?", sequence.ID).
+		Group("current_step").
+		Scan(&stepCounts)
+	steps, _ := sequence.StepList()
+	stepsSent := make([]int64, len(steps))
+	for _, c := range stepCounts {
+		for i := 0; i < c.CurrentStep && i < len(stepsSent); i++ {
+			stepsSent[i] += c.Count
+		}
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"sequence":   sequence,
+		"stats":      stats,
+		"steps_sent": stepsSent,
+	})
+}
+
+// UpdateSequence replaces a sequence's configuration. Active enrollments continue
+// with the new steps from where they are.
+func (a *App) UpdateSequence(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	sequence, err := a.findSequence(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sequence not found", nil, "")
+	}
+
+	var req SequenceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := a.applySequenceRequest(orgID, sequence, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Save(sequence).Error; err != nil {
+		a.Log.Error("Failed to update sequence", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update sequence", nil, "")
+	}
+
+	return r.SendEnvelope(sequence)
+}
+
+// DeleteSequence deletes a sequence and unenrolls its active enrollments
+func (a *App) DeleteSequence(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	sequence, err := a.findSequence(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sequence not found", nil, "")
+	}
+
+	a.DB.Model(&models.SequenceEnrollment{}).
+		Where("sequence_id = ? AND status = ?", sequence.ID, models.SequenceStatusActive).
+		Updates(map[string]interface{}{
+			"status":       models.SequenceStatusUnenrolled,
+			"exit_reason":  "sequence deleted",
+			"ended_at":     time.Now(),
+			"next_send_at": nil,
+		})
+
+	if err := a.DB.Delete(sequence).Error; err != nil {
+		a.Log.Error("Failed to delete sequence", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete sequence", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Sequence deleted successfully"})
+}
+
+// ListSequenceEnrollments returns a sequence's enrollments, newest first.
+// Query params: status, page, limit (default 50)
+func (a *App) ListSequenceEnrollments(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	sequence, err := a.findSequence(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sequence not found", nil, "")
+	}
+
+	page := r.RequestCtx.QueryArgs().GetUintOrZero("page")
+	limit := r.RequestCtx.QueryArgs().GetUintOrZero("limit")
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := a.DB.Where("sequence_id = ?", sequence.ID)
+	if status := string(r.RequestCtx.QueryArgs().Peek("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Model(&models.SequenceEnrollment{}).Count(&total)
+
+	var enrollments []models.SequenceEnrollment
+	if err := query.Preload("Contact").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&enrollments).Error; err != nil {
+		a.Log.Error("Failed to list sequence enrollments", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list enrollments", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"enrollments": enrollments,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+// EnrollSequenceContacts enrolls contacts of the sequence's account into it. Contacts who
+// opted out or are already going through the sequence are skipped.
+func (a *App) EnrollSequenceContacts(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, err := a.getUserIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	sequence, err := a.findSequence(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sequence not found", nil, "")
+	}
+	steps, err := sequence.StepList()
+	if err != nil || len(steps) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Sequence has no steps", nil, "")
+	}
+
+	var req EnrollSequenceRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if len(req.ContactIDs) == 0 && req.BroadcastListID == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "contact_ids or broadcast_list_id is required", nil, "")
+	}
+	if len(req.ContactIDs) > maxSequenceEnrollment {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("At most %d contacts can be enrolled at once", maxSequenceEnrollment), nil, "")
+	}
+
+	contactIDs := make([]uuid.UUID, 0, len(req.ContactIDs))
+	for _, id := range req.ContactIDs {
+		contactID, err := uuid.Parse(id)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID: "+id, nil, "")
+		}
+		contactIDs = append(contactIDs, contactID)
+	}
+
+	// Contacts on the sequence's account, from the request and the broadcast list
+	query := a.DB.Model(&models.Contact{}).Where("organization_id = ? AND whats_app_account = ?", orgID, sequence.WhatsAppAccount)
+	if req.BroadcastListID != "" {
+		listID, err := a.sequenceBroadcastList(orgID, sequence, req.BroadcastListID)
+		if err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+		}
+		members := a.DB.Model(&models.BroadcastListMember{}).Select("contact_id").Where("list_id = ?", listID)
+		if len(contactIDs) > 0 {
+			query = query.Where("id IN ? OR id IN (?)", contactIDs, members)
+		} else {
+			query = query.Where("id IN (?)", members)
+		}
+	} else {
+		query = query.Where("id IN ?", contactIDs)
+	}
+
+	var found []uuid.UUID
+	if err := query.Pluck("id", &found).Error; err != nil {
+		a.Log.Error("Failed to load contacts to enroll", "error", err, "sequence_id", sequence.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to enroll contacts", nil, "")
+	}
+	if len(found) > maxSequenceEnrollment {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, fmt.Sprintf("At most %d contacts can be enrolled at once", maxSequenceEnrollment), nil, "")
+	}
+
+	var eligible []uuid.UUID
+	if len(found) > 0 {
+		if err := a.DB.Model(&models.Contact{}).
+			Where("id IN ?", found).
+			Where(contactNotOptedOut, engagement.OptOutKeywords).
+			Pluck("id", &eligible).Error; err != nil {
+			a.Log.Error("Failed to check opt-outs", "error", err, "sequence_id", sequence.ID)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to enroll contacts", nil, "")
+		}
+	}
+
+	now := time.Now()
+	firstSend := now.AddDate(0, 0, steps[0].Day)
+	var enrolled int64
+	if len(eligible) > 0 {
+		enrollments := make([]models.SequenceEnrollment, len(eligible))
+		for i, contactID := range eligible {
+			enrollments[i] = models.SequenceEnrollment{
+				OrganizationID: orgID,
+				SequenceID:     sequence.ID,
+				ContactID:      contactID,
+				Status:         models.SequenceStatusActive,
+				NextSendAt:     &firstSend,
+				EnrolledBy:     &userID,
+			}
+		}
+		// Contacts already going through the sequence hit its active enrollment index
+		result := a.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&enrollments, 500)
+		if result.Error != nil {
+			a.Log.Error("Failed to enroll contacts", "error", result.Error, "sequence_id", sequence.ID)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to enroll contacts", nil, "")
+		}
+		enrolled = result.RowsAffected
+	}
+
+	notFound := 0
+	if req.BroadcastListID == "" {
+		notFound = len(contactIDs) - len(found)
+	}
+	return r.SendEnvelope(map[string]interface{}{
+		"enrolled":                 enrolled,
+		"skipped_opted_out":        len(found) - len(eligible),
+		"skipped_already_enrolled": int64(len(eligible)) - enrolled,
+		"not_found":                notFound,
+	})
+}
+
+// UnenrollSequenceContact stops a sequence for one enrollment
+func (a *App) UnenrollSequenceContact(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	sequence, err := a.findSequence(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sequence not found", nil, "")
+	}
+	enrollmentID, err := uuid.Parse(r.RequestCtx.UserValue("enrollment_id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid enrollment ID", nil, "")
+	}
+
+	result := a.DB.Model(&models.SequenceEnrollment{}).
+		Where("id = ? AND sequence_id = ? AND status = ?", enrollmentID, sequence.ID, models.SequenceStatusActive).
+		Updates(map[string]interface{}{
+			"status":       models.SequenceStatusUnenrolled,
+			"exit_reason":  "unenrolled",
+			"ended_at":     time.Now(),
+			"next_send_at": nil,
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to unenroll contact", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to unenroll contact", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Active enrollment not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Contact unenrolled"})
+}
+
+// applySequenceRequest validates req and copies it onto sequence
+func (a *App) applySequenceRequest(orgID uuid.UUID, sequence *models.Sequence, req *SequenceRequest) error {
+	if req.Name == "" || req.WhatsAppAccount == "" {
+		return fmt.Errorf("name and whatsapp_account are required")
+	}
+	var count int64
+	a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("WhatsApp account not found")
+	}
+
+	if len(req.Steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+	if len(req.Steps) > maxSequenceSteps {
+		return fmt.Errorf("a sequence can have at most %d steps", maxSequenceSteps)
+	}
+	steps := make(models.JSONBArray, len(req.Steps))
+	for i, step := range req.Steps {
+		if step.Day < 0 {
+			return fmt.Errorf("step %d: day cannot be negative", i+1)
+		}
+		if i > 0 && step.Day < req.Steps[i-1].Day {
+			return fmt.Errorf("step %d: day must not be before the previous step's", i+1)
+		}
+		var template models.Template
+		err := a.DB.Where("id = ? AND organization_id = ? AND whats_app_account = ?", step.TemplateID, orgID, req.WhatsAppAccount).First(&template).Error
+		if err != nil {
+			return fmt.Errorf("step %d: template not found for this account", i+1)
+		}
+		params := make(map[string]interface{}, len(step.TemplateParams))
+		for k, v := range step.TemplateParams {
+			params[k] = v
+		}
+		steps[i] = map[string]interface{}{
+			"template_id":     step.TemplateID.String(),
+			"day":             step.Day,
+			"template_params": params,
+		}
+	}
+
+	sequence.Name = req.Name
+	sequence.Description = req.Description
+	sequence.WhatsAppAccount = req.WhatsAppAccount
+	sequence.IsEnabled = req.IsEnabled
+	sequence.Steps = steps
+	return nil
+}
+
+// sequenceBroadcastList checks a broadcast list belongs to the organization and the
+// sequence's account
+func (a *App) sequenceBroadcastList(orgID uuid.UUID, sequence *models.Sequence, id string) (uuid.UUID, error) {
+	listID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid broadcast_list_id")
+	}
+	var count int64
+	a.DB.Model(&models.BroadcastList{}).
+		Where("id = ? AND organization_id = ? AND whats_app_account = ?", listID, orgID, sequence.WhatsAppAccount).
+		Count(&count)
+	if count == 0 {
+		return uuid.Nil, fmt.Errorf("broadcast list not found for this account")
+	}
+	return listID, nil
+}
+
+func (a *App) findSequence(r *fastglue.Request, orgID uuid.UUID) (*models.Sequence, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var sequence models.Sequence
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&sequence).Error; err != nil {
+		return nil, err
+	}
+	return &sequence, nil
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+const (
+	// sessionTimeoutLockKey keeps server instances from running the same cycle twice
+	sessionTimeoutLockKey = "chatbot:session_timeout_lock"
+
+	// sessionTimeoutBatch caps sessions expired, and nudged, per account per cycle
+	sessionTimeoutBatch = 200
+)
+
+// SessionTimeoutProcessor expires chatbot sessions after the configured inactivity,
+// nudging contacts stuck in a flow first and handing timed-out flows to agents
+type SessionTimeoutProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewSessionTimeoutProcessor creates a new session timeout processor
+func NewSessionTimeoutProcessor(app *App, interval time.Duration) *SessionTimeoutProcessor {
+	return &SessionTimeoutProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the session timeout loop
+func (p *SessionTimeoutProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Session timeout processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Session timeout processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Session timeout processor stopped")
+			return
+		case <-ticker.C:
+			p.processSessions(ctx)
+		}
+	}
+}
+
+// Stop stops the session timeout processor
+func (p *SessionTimeoutProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// processSessions runs one cycle for every account with active sessions, using the
+// account's chatbot settings
+func (p *SessionTimeoutProcessor) processSessions(ctx context.Context) {
+	acquired, err := p.app.Redis.SetNX(ctx, sessionTimeoutLockKey, 1, p.interval/2).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	var accounts []struct {
+		OrganizationID  uuid.UUID
+		WhatsAppAccount string
+	}
+	if err := p.app.DB.Model(&models.ChatbotSession{}).
+		Distinct("organization_id", "whats_app_account").
+		Where("status = ?", "active").
+		Scan(&accounts).Error; err != nil {
+		p.app.Log.Error("Failed to load accounts with active chatbot sessions", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, acc := range accounts {
+		settings, err := p.app.getChatbotSettingsCached(acc.OrganizationID, acc.WhatsAppAccount)
+		if err != nil || settings.SessionTimeoutMins <= 0 {
+			continue
+		}
+
+		timeout := now.Add(-time.Duration(settings.SessionTimeoutMins) * time.Minute)
+		p.expireSessions(acc.OrganizationID, acc.WhatsAppAccount, settings, timeout, now)
+
+		if settings.SessionNudgeMinutes > 0 && settings.SessionNudgeMinutes < settings.SessionTimeoutMins &&
+			strings.TrimSpace(settings.SessionNudgeMessage) != "" {
+			nudgeAfter := now.Add(-time.Duration(settings.SessionNudgeMinutes) * time.Minute)
+			p.nudgeSessions(acc.OrganizationID, acc.WhatsAppAccount, settings, nudgeAfter, now)
+		}
+	}
+}
+
+// expireSessions marks sessions inactive since before cutoff as timed out. A flow
+// left mid-way counts as exited, and is handed to agents if the settings ask for it.
+func (p *SessionTimeoutProcessor) expireSessions(orgID uuid.UUID, accountName string, settings *models.ChatbotSettings, cutoff, now time.Time) {
+	var sessions []models.ChatbotSession
+	if err := p.app.DB.Where("organization_id = ? AND whats_app_account = ? AND status = ? AND last_activity_at <= ?",
+		orgID, accountName, "active", cutoff).
+		Order("last_activity_at").Limit(sessionTimeoutBatch).
+		Find(&sessions).Error; err != nil {
+		p.app.Log.Error("Failed to load timed out chatbot sessions", "error", err, "org_id", orgID)
+		return
+	}
+
+	for i := range sessions {
+		session := &sessions[i]
+
+		// Skip sessions the contact became active in since they were loaded
+		result := p.app.DB.Model(&models.ChatbotSession{}).
+			Where("id = ? AND status = ? AND last_activity_at <= ?", session.ID, "active", cutoff).
+			Updates(map[string]interface{}{
+				"status":       "timeout",
+				"completed_at": now,
+			})
+		if result.Error != nil {
+			p.app.Log.Error("Failed to time out chatbot session", "error", result.Error, "session_id", session.ID)
+			continue
+		}
+		if result.RowsAffected == 0 || session.CurrentFlowID == nil {
+			continue
+		}
+
+		p.app.recordFlowEvent(session, *session.CurrentFlowID, session.CurrentStep, models.FlowEventExited, models.FlowExitTimeout)
+		p.app.applyFlowOutcomeLabelRules(session, *session.CurrentFlowID, "exited")
+		if settings.SessionTimeoutHandoff {
+			p.handOff(session, now)
+		}
+		p.app.Log.Info("Chatbot flow session timed out", "session_id", session.ID, "contact_id", session.ContactID, "step", session.CurrentStep)
+	}
+}
+
+// nudgeSessions sends the nudge message once to contacts inactive in a flow since
+// before cutoff. The nudge doesn't count as activity, so the timeout still applies.
+func (p *SessionTimeoutProcessor) nudgeSessions(orgID uuid.UUID, accountName string, settings *models.ChatbotSettings, cutoff, now time.Time) {
+	var sessions []models.ChatbotSession
+	if err := p.app.DB.Where("organization_id = ? AND whats_app_account = ? AND status = ? AND current_flow_id IS NOT NULL AND nudged_at IS NULL AND last_activity_at <= ?",
+		orgID, accountName, "active", cutoff).
+		Order("last_activity_at").Limit(sessionTimeoutBatch).
+		Find(&sessions).Error; err != nil {
+		p.app.Log.Error("Failed to load chatbot sessions to nudge", "error", err, "org_id", orgID)
+		return
+	}
+
+	for i := range sessions {
+		session := &sessions[i]
+
+		// Claim the nudge first so a reply or another cycle can't send it twice
+		result := p.app.DB.Model(&models.ChatbotSession{}).
+			Where("id = ? AND status = ? AND nudged_at IS NULL AND last_activity_at <= ?", session.ID, "active", cutoff).
+			Update("nudged_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		account, contact, err := p.sessionAccountAndContact(session)
+		if err != nil {
+			p.app.Log.Error("Failed to load account or contact for session nudge", "error", err, "session_id", session.ID)
+			continue
+		}
+
+		message := processTemplate(settings.SessionNudgeMessage, session.SessionData)
+		if err := p.app.sendAndSaveTextMessage(account, contact, message); err != nil {
+			p.app.Log.Error("Failed to send session nudge", "error", err, "session_id", session.ID)
+			continue
+		}
+		p.app.logSessionMessage(session.ID, "outgoing", message, "inactivity_nudge")
+	}
+}
+
+// handOff queues a timed-out flow for agents, with a summary of where the contact
+// stopped and what they had answered
+func (p *SessionTimeoutProcessor) handOff(session *models.ChatbotSession, now time.Time) {
+	account, contact, err := p.sessionAccountAndContact(session)
+	if err != nil {
+		p.app.Log.Error("Failed to load account or contact for session handoff", "error", err, "session_id", session.ID)
+		return
+	}
+
+	flowName := "Flow"
+	if flow, err := p.app.getChatbotFlowByIDCached(session.OrganizationID, *session.CurrentFlowID); err == nil {
+		flowName = fmt.Sprintf("Flow %q", flow.Name)
+	}
+	p.app.createTransferToQueue(account, contact, sessionTimeoutSummary(flowName, session, now), "flow_timeout")
+}
+
+// sessionAccountAndContact loads the WhatsApp account and contact of a session
+func (p *SessionTimeoutProcessor) sessionAccountAndContact(session *models.ChatbotSession) (*models.WhatsAppAccount, *models.Contact, error) {
+	var account models.WhatsAppAccount
+	if err := p.app.DB.Where("organization_id = ? AND name = ?", session.OrganizationID, session.WhatsAppAccount).First(&account).Error; err != nil {
+		return nil, nil, err
+	}
+	var contact models.Contact
+	if err := p.app.DB.Where("id = ? AND organization_id = ?", session.ContactID, session.OrganizationID).First(&contact).Error; err != nil {
+		return nil, nil, err
+	}
+	return &account, &contact, nil
+}
+
+// sessionTimeoutSummary describes a timed-out flow for the agent picking it up
+func sessionTimeoutSummary(flowName string, session *models.ChatbotSession, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s timed out at step %q after %d minutes without a reply.",
+		flowName, session.CurrentStep, int(now.Sub(session.LastActivityAt).Minutes()))
+
+	keys := make([]string, 0, len(session.SessionData))
+	for key := range session.SessionData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		b.WriteString("\nAnswers so far:")
+		for _, key := range keys {
+			fmt.Fprintf(&b, "\n- %s: %v", key, session.SessionData[key])
+		}
+	}
+	return b.String()
+}
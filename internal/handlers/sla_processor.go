@@ -273,6 +273,7 @@ func (p *SLAProcessor) sendSLAWarningToCustomer(transfer models.AgentTransfer, m
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	message = p.app.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, message)
 	_, err := p.app.WhatsApp.SendTextMessage(ctx, waAccount, transfer.PhoneNumber, message)
 	if err != nil {
 		p.app.Log.Error("Failed to send SLA warning message", "error", err, "phone", transfer.PhoneNumber)
@@ -302,6 +303,7 @@ func (p *SLAProcessor) sendSLAAutoCloseToCustomer(transfer models.AgentTransfer,
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	message = p.app.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, message)
 	_, err := p.app.WhatsApp.SendTextMessage(ctx, waAccount, transfer.PhoneNumber, message)
 	if err != nil {
 		p.app.Log.Error("Failed to send SLA auto-close message", "error", err, "phone", transfer.PhoneNumber)
@@ -464,7 +466,8 @@ func (p *SLAProcessor) sendChatbotReminder(contact models.Contact, settings mode
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	wamid, err := p.app.WhatsApp.SendTextMessage(ctx, waAccount, contact.PhoneNumber, settings.ClientReminderMessage)
+	reminder := p.app.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, settings.ClientReminderMessage)
+	wamid, err := p.app.WhatsApp.SendTextMessage(ctx, waAccount, contact.PhoneNumber, reminder)
 
 	// Save message to database
 	msg := models.Message{
@@ -473,7 +476,7 @@ func (p *SLAProcessor) sendChatbotReminder(contact models.Contact, settings mode
 		ContactID:       contact.ID,
 		Direction:       "outgoing",
 		MessageType:     "text",
-		Content:         settings.ClientReminderMessage,
+		Content:         reminder,
 		Status:          "sent",
 	}
 	if err != nil {
@@ -548,7 +551,8 @@ func (p *SLAProcessor) autoCloseChatbotSession(contact models.Contact, settings
 			}
 
 			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			wamid, err := p.app.WhatsApp.SendTextMessage(ctx, waAccount, contact.PhoneNumber, settings.ClientAutoCloseMessage)
+			closing := p.app.applyMessageFooter(account.OrganizationID, FooterCategoryAutomated, settings.ClientAutoCloseMessage)
+			wamid, err := p.app.WhatsApp.SendTextMessage(ctx, waAccount, contact.PhoneNumber, closing)
 			cancel()
 
 			// Save message to database
@@ -558,7 +562,7 @@ func (p *SLAProcessor) autoCloseChatbotSession(contact models.Contact, settings
 				ContactID:       contact.ID,
 				Direction:       "outgoing",
 				MessageType:     "text",
-				Content:         settings.ClientAutoCloseMessage,
+				Content:         closing,
 				Status:          "sent",
 			}
 			if err != nil {
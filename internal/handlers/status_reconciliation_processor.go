@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/phonenumber"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultStatusStaleAfter is how long an outgoing message waits in sent for a
+	// delivery receipt before it is reconciled
+	defaultStatusStaleAfter = 24 * time.Hour
+
+	// reconcileLookbackDays is how far back stuck messages are compared with Meta's
+	// analytics; older ones are marked stale without a comparison
+	reconcileLookbackDays = 30
+
+	// A day's missed receipts alert the organization when there are at least
+	// missedReceiptsAlertMin of them and they are at least missedReceiptsAlertShare
+	// of Meta's delivered count, so analytics rounding doesn't raise alerts
+	missedReceiptsAlertMin   = 10
+	missedReceiptsAlertShare = 0.05
+)
+
+// StatusReconciliationProcessor periodically looks for outgoing messages that never
+// got a delivery receipt and compares each day they were sent on with Meta's
+// analytics for the account. When Meta counted enough deliveries that no webhook
+// reported, the stuck messages are marked delivered; otherwise they are marked
+// stale. Days with missed receipts are reported to the organization, as they mean
+// status webhooks aren't reaching the app.
+type StatusReconciliationProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewStatusReconciliationProcessor creates a new status reconciliation processor
+func NewStatusReconciliationProcessor(app *App, interval time.Duration) *StatusReconciliationProcessor {
+	return &StatusReconciliationProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the status reconciliation loop
+func (p *StatusReconciliationProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Status reconciliation processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Status reconciliation processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Status reconciliation processor stopped")
+			return
+		case <-ticker.C:
+			p.reconcileAccounts(ctx)
+		}
+	}
+}
+
+// Stop stops the status reconciliation processor
+func (p *StatusReconciliationProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// reconcileAccounts reconciles every active account not reconciled within the interval
+func (p *StatusReconciliationProcessor) reconcileAccounts(ctx context.Context) {
+	due := time.Now().Add(-p.interval / 2)
+
+	var accounts []models.WhatsAppAccount
+	if err := p.app.DB.Where("status = ? AND (reconciled_at IS NULL OR reconciled_at < ?)", "active", due).
+		Find(&accounts).Error; err != nil {
+		p.app.Log.Error("Failed to load accounts for status reconciliation", "error", err)
+		return
+	}
+
+	for i := range accounts {
+		account := &accounts[i]
+
+		// Claim the run so other server instances skip this account
+		result := p.app.DB.Model(&models.WhatsAppAccount{}).
+			Where("id = ? AND (reconciled_at IS NULL OR reconciled_at < ?)", account.ID, due).
+			Update("reconciled_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		if _, err := p.app.reconcileMessageStatuses(ctx, account); err != nil {
+			p.app.Log.Error("Failed to reconcile message statuses", "error", err, "account", account.Name)
+		}
+	}
+}
+
+// ReconcileMessageStatuses reconciles an account's stuck messages now and returns
+// the days that were checked
+func (a *App) ReconcileMessageStatuses(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid account ID", nil, "")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	a.DB.Model(&account).Update("reconciled_at", time.Now())
+	reports, err := a.reconcileMessageStatuses(r.RequestCtx, &account)
+	if err != nil {
+		a.Log.Error("Failed to reconcile message statuses", "error", err, "account", account.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to reconcile message statuses", nil, "")
+	}
+	return r.SendEnvelope(map[string]interface{}{
+		"reconciliations": reports,
+	})
+}
+
+// ListStatusReconciliations returns the days checked against Meta's analytics, newest
+// first. Query params: account, missed=true (only days with missed receipts),
+// limit (default 50)
+func (a *App) ListStatusReconciliations(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if account := string(r.RequestCtx.QueryArgs().Peek("account")); account != "" {
+		query = query.Where("whats_app_account = ?", account)
+	}
+	if string(r.RequestCtx.QueryArgs().Peek("missed")) == "true" {
+		query = query.Where("missed_receipts > 0")
+	}
+
+	limit := r.RequestCtx.QueryArgs().GetUintOrZero("limit")
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	var reports []models.StatusReconciliation
+	if err := query.Order("date DESC, whats_app_account").Limit(limit).Find(&reports).Error; err != nil {
+		a.Log.Error("Failed to list status reconciliations", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list status reconciliations", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"reconciliations": reports,
+	})
+}
+
+// stuckDay is the number of an account's stuck messages sent on one UTC day
+type stuckDay struct {
+	Day   time.Time
+	Count int64
+}
+
+// reconcileMessageStatuses reconciles the account's outgoing messages that have been
+// in sent for longer than the stale threshold, one day at a time, and records a
+// report for each day. Days whose analytics couldn't be fetched are left for the
+// next run, until their messages fall outside the lookback and are marked stale.
+func (a *App) reconcileMessageStatuses(ctx context.Context, account *models.WhatsAppAccount) ([]models.StatusReconciliation, error) {
+	now := time.Now().UTC()
+	cutoff := now.Add(-a.statusStaleAfter())
+	today := now.Truncate(24 * time.Hour)
+	windowStart := today.AddDate(0, 0, -reconcileLookbackDays)
+
+	// Nothing to compare messages this old with
+	if expired, err := a.markReconciled(a.stuckMessages(account, time.Time{}, windowStart), models.ReconciledStale, now); err != nil {
+		return nil, err
+	} else if expired > 0 {
+		a.Log.Info("Marked old stuck messages stale", "account", account.Name, "count", expired)
+	}
+
+	var days []stuckDay
+	if err := a.stuckMessages(account, windowStart, cutoff).
+		Select("DATE(created_at AT TIME ZONE 'UTC') AS day, COUNT(*) AS count").
+		Group("day").Order("day").
+		Scan(&days).Error; err != nil {
+		return nil, err
+	}
+	if len(days) == 0 {
+		a.DB.Model(&models.WhatsAppAccount{}).Where("id = ?", account.ID).Update("missed_receipts", 0)
+		return []models.StatusReconciliation{}, nil
+	}
+
+	analytics, analyticsErr := a.fetchMessageAnalytics(ctx, account, days[0].Day, days[len(days)-1].Day.AddDate(0, 0, 1))
+
+	reports := make([]models.StatusReconciliation, 0, len(days))
+	var missed int64
+	for _, d := range days {
+		day := d.Day.UTC()
+		report := models.StatusReconciliation{
+			OrganizationID:  account.OrganizationID,
+			WhatsAppAccount: account.Name,
+			Date:            day,
+			StuckMessages:   d.Count,
+			CheckedAt:       now,
+		}
+		if err := a.countLocalReceipts(account, day, &report); err != nil {
+			return nil, err
+		}
+
+		point, ok := analytics[day.Format("2006-01-02")]
+		switch {
+		case analyticsErr != nil:
+			report.Error = "Failed to fetch Meta's analytics: " + analyticsErr.Error()
+		case !ok:
+			report.Error = "Meta's analytics have no data for this day"
+		default:
+			report.MetaSent, report.MetaDelivered = point.Sent, point.Delivered
+			report.MissedReceipts = max(0, point.Delivered-report.LocalDelivered)
+
+			// Deliveries Meta counted beyond our receipts can only be attributed to
+			// the stuck messages when nothing else sends from this number
+			result := models.ReconciledStale
+			if report.MetaSent <= report.LocalSent && report.MissedReceipts >= d.Count {
+				result = models.ReconciledDelivered
+			}
+			marked, err := a.markReconciled(a.stuckMessages(account, day, minTime(day.AddDate(0, 0, 1), cutoff)), result, now)
+			if err != nil {
+				return nil, err
+			}
+			if result == models.ReconciledDelivered {
+				report.InferredDelivered = marked
+			} else {
+				report.MarkedStale = marked
+			}
+		}
+
+		if err := a.saveStatusReconciliation(&report); err != nil {
+			return nil, err
+		}
+		missed += report.MissedReceipts
+		a.alertMissedReceipts(&report)
+		reports = append(reports, report)
+	}
+
+	a.DB.Model(&models.WhatsAppAccount{}).Where("id = ?", account.ID).Update("missed_receipts", missed)
+	account.MissedReceipts = missed
+	return reports, nil
+}
+
+// stuckMessages returns a query for the account's outgoing messages created in
+// [from, to) that are still in sent and haven't been reconciled. A zero from has no
+// lower bound.
+func (a *App) stuckMessages(account *models.WhatsAppAccount, from, to time.Time) *gorm.DB {
+	query := a.DB.Model(&models.Message{}).
+		Where("organization_id = ? AND whats_app_account = ? AND direction = ? AND status = ?",
+			account.OrganizationID, account.Name, "outgoing", "sent").
+		Where("metadata->'status_reconciliation' IS NULL").
+		Where("created_at < ?", to)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	return query
+}
+
+// markReconciled records the reconciliation result in the metadata of the messages
+// query matches, setting their status to delivered when that was inferred
+func (a *App) markReconciled(query *gorm.DB, result string, at time.Time) (int64, error) {
+	mark, err := json.Marshal(map[string]interface{}{
+		"status_reconciliation": map[string]interface{}{"result": result, "at": at},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	updates := map[string]interface{}{
+		"metadata": gorm.Expr("COALESCE(metadata, '{}'::jsonb) || ?::jsonb", string(mark)),
+	}
+	if result == models.ReconciledDelivered {
+		updates["status"] = "delivered"
+	}
+	res := query.Updates(updates)
+	return res.RowsAffected, res.Error
+}
+
+// countLocalReceipts fills in how many of the account's messages sent on day Meta
+// accepted and how many of those got a delivery receipt. Messages marked delivered
+// by an earlier reconciliation don't count as receipts.
+func (a *App) countLocalReceipts(account *models.WhatsAppAccount, day time.Time, report *models.StatusReconciliation) error {
+	var counts struct {
+		Sent      int64
+		Delivered int64
+	}
+	err := a.DB.Model(&models.Message{}).
+		Select(`COUNT(*) FILTER (WHERE status IN ('sent', 'delivered', 'read')) AS sent,
+			COUNT(*) FILTER (WHERE status IN ('delivered', 'read')
+				AND COALESCE(metadata->'status_reconciliation'->>'result', '') <> ?) AS delivered`, models.ReconciledDelivered).
+		Where("organization_id = ? AND whats_app_account = ? AND direction = ?", account.OrganizationID, account.Name, "outgoing").
+		Where("created_at >= ? AND created_at < ?", day, day.AddDate(0, 0, 1)).
+		Scan(&counts).Error
+	report.LocalSent, report.LocalDelivered = counts.Sent, counts.Delivered
+	return err
+}
+
+// fetchMessageAnalytics returns Meta's daily counts for the account's phone number
+// between start and end, keyed by UTC date
+func (a *App) fetchMessageAnalytics(ctx context.Context, account *models.WhatsAppAccount, start, end time.Time) (map[string]whatsapp.MessageAnalytics, error) {
+	waAccount := &whatsapp.Account{
+		PhoneID:     account.PhoneID,
+		BusinessID:  account.BusinessID,
+		APIVersion:  account.APIVersion,
+		AccessToken: account.AccessToken,
+	}
+
+	// Analytics are per WABA unless filtered by display number; without the filter
+	// other numbers' traffic shows up as messages this account didn't send, which
+	// stops deliveries being inferred
+	var phoneNumbers []string
+	if phone, err := a.WhatsApp.GetPhoneNumberStatus(ctx, waAccount); err == nil {
+		if number, err := phonenumber.Parse(phone.DisplayPhoneNumber, ""); err == nil {
+			phoneNumbers = []string{number.Digits()}
+		}
+	}
+
+	points, err := a.WhatsApp.GetMessageAnalytics(ctx, waAccount, start, end, phoneNumbers)
+	if err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]whatsapp.MessageAnalytics, len(points))
+	for _, p := range points {
+		byDay[p.Start.Format("2006-01-02")] = p
+	}
+	return byDay, nil
+}
+
+// saveStatusReconciliation creates or updates the report for the account and day.
+// Inferred and stale counts add up over runs; the other counts are replaced with
+// the latest.
+func (a *App) saveStatusReconciliation(report *models.StatusReconciliation) error {
+	err := a.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "organization_id"}, {Name: "whats_app_account"}, {Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"meta_sent":          gorm.Expr("EXCLUDED.meta_sent"),
+			"meta_delivered":     gorm.Expr("EXCLUDED.meta_delivered"),
+			"local_sent":         gorm.Expr("EXCLUDED.local_sent"),
+			"local_delivered":    gorm.Expr("EXCLUDED.local_delivered"),
+			"missed_receipts":    gorm.Expr("EXCLUDED.missed_receipts"),
+			"stuck_messages":     gorm.Expr("EXCLUDED.stuck_messages"),
+			"inferred_delivered": gorm.Expr("status_reconciliations.inferred_delivered + EXCLUDED.inferred_delivered"),
+			"marked_stale":       gorm.Expr("status_reconciliations.marked_stale + EXCLUDED.marked_stale"),
+			"error":              gorm.Expr("EXCLUDED.error"),
+			"checked_at":         gorm.Expr("EXCLUDED.checked_at"),
+			"updated_at":         gorm.Expr("EXCLUDED.updated_at"),
+		}),
+	}).Create(report).Error
+	if err != nil {
+		return err
+	}
+
+	// Load the stored totals and whether the day was already alerted
+	return a.DB.Where("organization_id = ? AND whats_app_account = ? AND date = ?",
+		report.OrganizationID, report.WhatsAppAccount, report.Date).First(report).Error
+}
+
+// alertMissedReceipts tells the organization about a day with missed receipts, once.
+// Claiming alerted_at keeps server instances from alerting twice.
+func (a *App) alertMissedReceipts(report *models.StatusReconciliation) {
+	if report.AlertedAt != nil || report.MissedReceipts < missedReceiptsAlertMin ||
+		float64(report.MissedReceipts) < missedReceiptsAlertShare*float64(report.MetaDelivered) {
+		return
+	}
+
+	now := time.Now()
+	result := a.DB.Model(&models.StatusReconciliation{}).
+		Where("id = ? AND alerted_at IS NULL", report.ID).
+		Update("alerted_at", now)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return
+	}
+	report.AlertedAt = &now
+
+	a.Log.Warn("Delivery receipts missed",
+		"organization_id", report.OrganizationID,
+		"account", report.WhatsAppAccount,
+		"date", report.Date.Format("2006-01-02"),
+		"meta_delivered", report.MetaDelivered,
+		"local_delivered", report.LocalDelivered,
+		"missed_receipts", report.MissedReceipts,
+	)
+	data := MissedReceiptsEventData{
+		WhatsAppAccount:   report.WhatsAppAccount,
+		Date:              report.Date.Format("2006-01-02"),
+		MetaSent:          report.MetaSent,
+		MetaDelivered:     report.MetaDelivered,
+		LocalDelivered:    report.LocalDelivered,
+		MissedReceipts:    report.MissedReceipts,
+		StuckMessages:     report.StuckMessages,
+		InferredDelivered: report.InferredDelivered,
+		MarkedStale:       report.MarkedStale,
+	}
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(report.OrganizationID, websocket.WSMessage{
+			Type:    websocket.TypeMissedReceipts,
+			Payload: a.withNotification(report.OrganizationID, notify.KeyMissedReceipts, data),
+		})
+	}
+	a.DispatchWebhook(report.OrganizationID, EventAccountReceipts, data)
+}
+
+// statusStaleAfter is how long an outgoing message can wait for a delivery receipt
+func (a *App) statusStaleAfter() time.Duration {
+	if a.Config != nil && a.Config.WhatsApp.StatusStaleAfterHours > 0 {
+		return time.Duration(a.Config.WhatsApp.StatusStaleAfterHours) * time.Hour
+	}
+	return defaultStatusStaleAfter
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
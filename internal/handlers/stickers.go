@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+	"gorm.io/gorm"
+)
+
+// WhatsApp sticker limits (https://developers.facebook.com/docs/whatsapp/cloud-api/reference/media)
+const (
+	maxStaticStickerSize   = 100 * 1024
+	maxAnimatedStickerSize = 500 * 1024
+)
+
+// StickerResponse represents a sticker in the organization library
+type StickerResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Pack       string    `json:"pack"`
+	MimeType   string    `json:"mime_type"`
+	FileSize   int       `json:"file_size"`
+	Animated   bool      `json:"animated"`
+	UsageCount int       `json:"usage_count"`
+	CreatedAt  string    `json:"created_at"`
+}
+
+// SendStickerRequest represents a request to send a library sticker to a contact
+type SendStickerRequest struct {
+	StickerID string `json:"sticker_id"`
+}
+
+// validateSticker checks that data is a WebP image within WhatsApp's sticker limits
+// and reports whether it is animated
+func validateSticker(data []byte, mimeType string) (bool, error) {
+	if !strings.HasPrefix(mimeType, "image/webp") {
+		return false, fmt.Errorf("stickers must be image/webp")
+	}
+	if len(data) < 12 || !bytes.Equal(data[0:4], []byte("RIFF")) || !bytes.Equal(data[8:12], []byte("WEBP")) {
+		return false, fmt.Errorf("file is not a valid WebP image")
+	}
+
+	animated := isAnimatedWebP(data)
+	limit := maxStaticStickerSize
+	if animated {
+		limit = maxAnimatedStickerSize
+	}
+	if len(data) > limit {
+		return animated, fmt.Errorf("sticker exceeds %dKB limit", limit/1024)
+	}
+	return animated, nil
+}
+
+// isAnimatedWebP checks the animation flag of an extended (VP8X) WebP header
+func isAnimatedWebP(data []byte) bool {
+	if len(data) < 21 || !bytes.Equal(data[12:16], []byte("VP8X")) {
+		return false
+	}
+	return data[20]&0x02 != 0
+}
+
+// ListStickers returns the organization's sticker library
+func (a *App) ListStickers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	pack := string(r.RequestCtx.QueryArgs().Peek("pack"))
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if pack != "" {
+		query = query.Where("pack = ?", pack)
+	}
+
+	var stickers []models.Sticker
+	if err := query.Order("usage_count DESC, name ASC").Find(&stickers).Error; err != nil {
+		a.Log.Error("Failed to list stickers", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list stickers", nil, "")
+	}
+
+	result := make([]StickerResponse, len(stickers))
+	for i, s := range stickers {
+		result[i] = stickerToResponse(s)
+	}
+
+	return r.SendEnvelope(map[string]any{
+		"stickers": result,
+	})
+}
+
+// CreateSticker uploads a static or animated WebP sticker to the organization library
+func (a *App) CreateSticker(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	form, err := r.RequestCtx.MultipartForm()
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid multipart form", nil, "")
+	}
+
+	files := form.File["file"]
+	if len(files) == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "file is required", nil, "")
+	}
+	fileHeader := files[0]
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Failed to read file", nil, "")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read file data", nil, "")
+	}
+
+	mimeType := fileHeader.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "image/webp"
+	}
+
+	animated, err := validateSticker(data, mimeType)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	name := strings.TrimSuffix(fileHeader.Filename, filepath.Ext(fileHeader.Filename))
+	if v := form.Value["name"]; len(v) > 0 && v[0] != "" {
+		name = v[0]
+	}
+	pack := ""
+	if v := form.Value["pack"]; len(v) > 0 {
+		pack = v[0]
+	}
+
+	localPath, err := a.saveMediaLocally(data, "image/webp", fileHeader.Filename)
+	if err != nil {
+		a.Log.Error("Failed to save sticker", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to save sticker", nil, "")
+	}
+
+	sticker := models.Sticker{
+		OrganizationID: orgID,
+		Name:           name,
+		Pack:           pack,
+		MediaPath:      localPath,
+		MimeType:       "image/webp",
+		FileSize:       len(data),
+		Animated:       animated,
+		CreatedByID:    userID,
+	}
+
+	if err := a.DB.Create(&sticker).Error; err != nil {
+		a.Log.Error("Failed to create sticker", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create sticker", nil, "")
+	}
+
+	return r.SendEnvelope(stickerToResponse(sticker))
+}
+
+// DeleteSticker removes a sticker from the organization library
+func (a *App) DeleteSticker(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.Sticker{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete sticker", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete sticker", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sticker not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Sticker deleted"})
+}
+
+// ServeSticker serves the image of a library sticker for the sticker picker
+func (a *App) ServeSticker(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid ID", nil, "")
+	}
+
+	var sticker models.Sticker
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&sticker).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sticker not found", nil, "")
+	}
+
+	data, err := a.readStickerFile(&sticker)
+	if err != nil {
+		a.Log.Error("Failed to read sticker file", "error", err, "sticker_id", sticker.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "File not found", nil, "")
+	}
+
+	r.RequestCtx.Response.Header.Set("Content-Type", sticker.MimeType)
+	r.RequestCtx.Response.Header.Set("Cache-Control", "private, max-age=86400")
+	r.RequestCtx.SetBody(data)
+	return nil
+}
+
+// SendSticker sends a library sticker to a contact
+// Agents can only send stickers to their assigned contacts
+func (a *App) SendSticker(r *fastglue.Request) error {
+	orgID := r.RequestCtx.UserValue("organization_id").(uuid.UUID)
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	userRole, _ := r.RequestCtx.UserValue("role").(string)
+
+	contactID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid contact ID", nil, "")
+	}
+
+	var req SendStickerRequest
+	if err := json.Unmarshal(r.RequestCtx.PostBody(), &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	stickerID, err := uuid.Parse(req.StickerID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid sticker ID", nil, "")
+	}
+
+	var sticker models.Sticker
+	if err := a.DB.Where("id = ? AND organization_id = ?", stickerID, orgID).First(&sticker).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Sticker not found", nil, "")
+	}
+
+	// Get contact (agents can only message their assigned contacts)
+	var contact models.Contact
+	query := a.DB.Where("id = ? AND organization_id = ?", contactID, orgID)
+	if userRole == "agent" {
+		query = query.Where("assigned_user_id = ?", userID)
+	}
+	if err := query.First(&contact).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Contact not found", nil, "")
+	}
+
+	// Get WhatsApp account
+	var account models.WhatsAppAccount
+	if contact.WhatsAppAccount != "" {
+		if err := a.DB.Where("name = ? AND organization_id = ?", contact.WhatsAppAccount, orgID).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+		}
+	} else {
+		if err := a.DB.Where("organization_id = ? AND is_default_outgoing = ?", orgID, true).First(&account).Error; err != nil {
+			if err := a.DB.Where("organization_id = ?", orgID).First(&account).Error; err != nil {
+				return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "No WhatsApp account configured", nil, "")
+			}
+		}
+	}
+
+	data, err := a.readStickerFile(&sticker)
+	if err != nil {
+		a.Log.Error("Failed to read sticker file", "error", err, "sticker_id", sticker.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to read sticker", nil, "")
+	}
+
+	message := models.Message{
+		BaseModel:       models.BaseModel{ID: uuid.New()},
+		OrganizationID:  orgID,
+		WhatsAppAccount: account.Name,
+		ContactID:       contactID,
+		Direction:       "outgoing",
+		MessageType:     "sticker",
+		MediaURL:        sticker.MediaPath,
+		MediaMimeType:   sticker.MimeType,
+		MediaFilename:   sticker.Name + ".webp",
+		Status:          "pending",
+		SentByUserID:    &userID,
+		Metadata: models.JSONB{
+			"animated":   sticker.Animated,
+			"sticker_id": sticker.ID.String(),
+		},
+	}
+
+	if err := a.DB.Create(&message).Error; err != nil {
+		a.Log.Error("Failed to create message", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create message", nil, "")
+	}
+	go a.recordConversationParticipation(orgID, contactID, userID)
+
+	waAccount := &whatsapp.Account{
+		PhoneID:     account.PhoneID,
+		BusinessID:  account.BusinessID,
+		APIVersion:  account.APIVersion,
+		AccessToken: account.AccessToken,
+	}
+	go a.uploadAndSendMediaMessage(waAccount, &account, &contact, &message, data, sticker.MimeType, message.MediaFilename, "")
+
+	a.DB.Model(&sticker).UpdateColumn("usage_count", gorm.Expr("usage_count + 1"))
+
+	now := time.Now()
+	a.DB.Model(&contact).Updates(map[string]any{
+		"last_message_at":      now,
+		"last_message_preview": "[sticker]",
+	})
+
+	content := map[string]any{"body": "", "animated": sticker.Animated}
+	response := MessageResponse{
+		ID:            message.ID,
+		ContactID:     message.ContactID,
+		Direction:     message.Direction,
+		MessageType:   message.MessageType,
+		Content:       content,
+		MediaURL:      message.MediaURL,
+		MediaMimeType: message.MediaMimeType,
+		MediaFilename: message.MediaFilename,
+		Status:        message.Status,
+		CreatedAt:     message.CreatedAt,
+		UpdatedAt:     message.UpdatedAt,
+	}
+
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(orgID, websocket.WSMessage{
+			Type: websocket.TypeNewMessage,
+			Payload: map[string]any{
+				"id":              message.ID.String(),
+				"contact_id":      message.ContactID.String(),
+				"direction":       message.Direction,
+				"message_type":    message.MessageType,
+				"content":         content,
+				"media_url":       message.MediaURL,
+				"media_mime_type": message.MediaMimeType,
+				"media_filename":  message.MediaFilename,
+				"status":          message.Status,
+				"created_at":      message.CreatedAt,
+				"updated_at":      message.UpdatedAt,
+			},
+		})
+	}
+
+	return r.SendEnvelope(response)
+}
+
+// readStickerFile loads a library sticker from media storage
+func (a *App) readStickerFile(sticker *models.Sticker) ([]byte, error) {
+	if strings.Contains(sticker.MediaPath, "..") {
+		return nil, fmt.Errorf("invalid sticker path")
+	}
+	return os.ReadFile(filepath.Join(a.getMediaStoragePath(), sticker.MediaPath))
+}
+
+func stickerToResponse(s models.Sticker) StickerResponse {
+	return StickerResponse{
+		ID:         s.ID,
+		Name:       s.Name,
+		Pack:       s.Pack,
+		MimeType:   s.MimeType,
+		FileSize:   s.FileSize,
+		Animated:   s.Animated,
+		UsageCount: s.UsageCount,
+		CreatedAt:  s.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+}
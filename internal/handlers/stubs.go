@@ -14,10 +14,6 @@ func (a *App) CreateContact(r *fastglue.Request) error {
 	return r.SendErrorEnvelope(fasthttp.StatusNotImplemented, "Not implemented yet", nil, "")
 }
 
-func (a *App) UpdateContact(r *fastglue.Request) error {
-	return r.SendErrorEnvelope(fasthttp.StatusNotImplemented, "Not implemented yet", nil, "")
-}
-
 func (a *App) DeleteContact(r *fastglue.Request) error {
 	return r.SendErrorEnvelope(fasthttp.StatusNotImplemented, "Not implemented yet", nil, "")
 }
@@ -72,6 +68,11 @@ func (a *App) AssignContact(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to assign contact", nil, "")
 	}
 
+	actorID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+	go a.notifyConversationFollowers(orgID, contactID, actorID, "assigned", map[string]any{
+		"assigned_user_id": req.UserID,
+	})
+
 	return r.SendEnvelope(map[string]any{
 		"message":          "Contact assigned successfully",
 		"assigned_user_id": req.UserID,
@@ -93,7 +94,3 @@ func (a *App) MarkMessageRead(r *fastglue.Request) error {
 func (a *App) GetMessageAnalytics(r *fastglue.Request) error {
 	return r.SendErrorEnvelope(fasthttp.StatusNotImplemented, "Not implemented yet", nil, "")
 }
-
-func (a *App) GetChatbotAnalytics(r *fastglue.Request) error {
-	return r.SendErrorEnvelope(fasthttp.StatusNotImplemented, "Not implemented yet", nil, "")
-}
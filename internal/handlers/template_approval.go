@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// campaignStatusPendingTemplate is the status of a started campaign whose template
+// is still awaiting Meta's approval
+const campaignStatusPendingTemplate = "pending_template"
+
+// TemplateApprovalProcessor watches the templates campaigns are waiting on. Template
+// status webhooks resolve most campaigns as soon as Meta decides; the processor polls
+// Meta for the rest and retries approved campaigns held back by a blackout date.
+type TemplateApprovalProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewTemplateApprovalProcessor creates a new template approval processor
+func NewTemplateApprovalProcessor(app *App, interval time.Duration) *TemplateApprovalProcessor {
+	return &TemplateApprovalProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the template approval processing loop
+func (p *TemplateApprovalProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Template approval processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Template approval processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Template approval processor stopped")
+			return
+		case <-ticker.C:
+			p.processPending(ctx)
+		}
+	}
+}
+
+// Stop stops the template approval processor
+func (p *TemplateApprovalProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// processPending refreshes the status of every template a campaign is waiting on
+// and starts or releases those campaigns once Meta has decided
+func (p *TemplateApprovalProcessor) processPending(ctx context.Context) {
+	var templateIDs []uuid.UUID
+	if err := p.app.DB.Model(&models.BulkMessageCampaign{}).
+		Where("status = ?", campaignStatusPendingTemplate).
+		Distinct().Pluck("template_id", &templateIDs).Error; err != nil {
+		p.app.Log.Error("Failed to load campaigns awaiting template approval", "error", err)
+		return
+	}
+	if len(templateIDs) == 0 {
+		return
+	}
+
+	var templates []models.Template
+	if err := p.app.DB.Where("id IN ?", templateIDs).Find(&templates).Error; err != nil {
+		p.app.Log.Error("Failed to load templates awaiting approval", "error", err)
+		return
+	}
+
+	// Fetch each account's templates from Meta at most once per run
+	statusesByAccount := make(map[string]map[string]string)
+	for i := range templates {
+		template := &templates[i]
+		if templateDecided(template.Status) {
+			continue
+		}
+
+		key := template.OrganizationID.String() + "/" + template.WhatsAppAccount
+		statuses, ok := statusesByAccount[key]
+		if !ok {
+			statuses = p.fetchTemplateStatuses(template.OrganizationID, template.WhatsAppAccount)
+			statusesByAccount[key] = statuses
+		}
+
+		status, ok := statuses[template.Name+"/"+template.Language]
+		if !ok || strings.EqualFold(status, template.Status) {
+			continue
+		}
+		if err := p.app.DB.Model(template).Update("status", status).Error; err != nil {
+			p.app.Log.Error("Failed to update template status", "error", err, "template_id", template.ID)
+			continue
+		}
+		template.Status = status
+	}
+
+	for i := range templates {
+		p.app.resolveTemplateCampaigns(ctx, &templates[i], "")
+	}
+}
+
+// fetchTemplateStatuses returns the Meta status of an account's templates keyed by
+// name/language, or nil if they can't be fetched
+func (p *TemplateApprovalProcessor) fetchTemplateStatuses(orgID uuid.UUID, accountName string) map[string]string {
+	var account models.WhatsAppAccount
+	if err := p.app.DB.Where("name = ? AND organization_id = ?", accountName, orgID).First(&account).Error; err != nil {
+		p.app.Log.Error("Failed to load WhatsApp account for template approval", "error", err, "account", accountName)
+		return nil
+	}
+
+	metaTemplates, err := p.app.fetchTemplatesFromMeta(&account)
+	if err != nil {
+		p.app.Log.Error("Failed to fetch templates from Meta", "error", err, "account", accountName)
+		return nil
+	}
+
+	statuses := make(map[string]string, len(metaTemplates))
+	for _, t := range metaTemplates {
+		statuses[t.Name+"/"+t.Language] = t.Status
+	}
+	return statuses
+}
+
+// templateDecided reports whether Meta has approved or turned down a template
+func templateDecided(status string) bool {
+	return strings.EqualFold(status, "APPROVED") || templateRefused(status)
+}
+
+// templateRefused reports whether a template can no longer be approved without changes
+func templateRefused(status string) bool {
+	return strings.EqualFold(status, "REJECTED") || strings.EqualFold(status, "DISABLED")
+}
+
+// awaitTemplateApproval puts a campaign on hold until its template is approved,
+// submitting the template to Meta first if it hasn't been or was rejected
+func (a *App) awaitTemplateApproval(r *fastglue.Request, campaign *models.BulkMessageCampaign, template *models.Template) error {
+	resubmit := template.MetaTemplateID == "" || strings.EqualFold(template.Status, "REJECTED")
+	if !resubmit && !strings.EqualFold(template.Status, "PENDING") {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template is not approved and cannot be resubmitted", nil, "")
+	}
+
+	if resubmit {
+		var account models.WhatsAppAccount
+		if err := a.DB.Where("name = ? AND organization_id = ?", template.WhatsAppAccount, template.OrganizationID).First(&account).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+		}
+		if err := a.requestTemplateApproval(&account, template); err != nil {
+			a.Log.Error("Failed to submit template to Meta", "error", err, "campaign_id", campaign.ID)
+			return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to submit template to Meta: "+err.Error(), nil, "")
+		}
+		if err := a.DB.Save(template).Error; err != nil {
+			a.Log.Error("Failed to update template after submission", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Template submitted but failed to update local record", nil, "")
+		}
+	}
+
+	if err := a.DB.Model(campaign).Update("status", campaignStatusPendingTemplate).Error; err != nil {
+		a.Log.Error("Failed to start campaign", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to start campaign", nil, "")
+	}
+
+	a.Log.Info("Campaign waiting for template approval", "campaign_id", campaign.ID, "template", template.Name, "submitted", resubmit)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"message":         "Campaign will start when the template is approved",
+		"status":          campaignStatusPendingTemplate,
+		"template_status": template.Status,
+	})
+}
+
+// resolveTemplateCampaigns queues the campaigns waiting on template once it is
+// approved, or returns them to draft and notifies the organization if it was refused.
+// Other statuses leave the campaigns waiting.
+func (a *App) resolveTemplateCampaigns(ctx context.Context, template *models.Template, reason string) {
+	approved := strings.EqualFold(template.Status, "APPROVED")
+	if !approved && !templateRefused(template.Status) {
+		return
+	}
+
+	var campaigns []models.BulkMessageCampaign
+	if err := a.DB.Where("template_id = ? AND organization_id = ? AND status = ?",
+		template.ID, template.OrganizationID, campaignStatusPendingTemplate).Find(&campaigns).Error; err != nil {
+		a.Log.Error("Failed to load campaigns awaiting template", "error", err, "template_id", template.ID)
+		return
+	}
+	if len(campaigns) == 0 {
+		return
+	}
+
+	if approved {
+		// Campaigns don't send on holiday/blackout dates; the processor retries tomorrow.
+		// Those scheduled for later can still be scheduled.
+		blackout := a.campaignBlackout(template.OrganizationID)
+		for i := range campaigns {
+			if blackout != nil && !campaignScheduledAhead(&campaigns[i]) {
+				continue
+			}
+			a.queueApprovedCampaign(ctx, &campaigns[i], template)
+		}
+		return
+	}
+
+	if reason == "" {
+		reason = "Template " + template.Name + " was " + strings.ToLower(template.Status)
+	}
+	for i := range campaigns {
+		campaign := &campaigns[i]
+		result := a.DB.Model(&models.BulkMessageCampaign{}).
+			Where("id = ? AND status = ?", campaign.ID, campaignStatusPendingTemplate).
+			Update("status", "draft")
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		a.Log.Info("Campaign returned to draft after template was refused", "campaign_id", campaign.ID, "template", template.Name, "reason", reason)
+		go a.dispatchCampaignEvent(campaign.OrganizationID, campaign.ID, EventCampaignRejected, reason)
+		a.broadcastCampaignTemplateStatus(campaign, template, "draft", reason)
+	}
+}
+
+// queueApprovedCampaign queues a campaign whose template has just been approved, or
+// schedules it if its send time is still to come
+func (a *App) queueApprovedCampaign(ctx context.Context, campaign *models.BulkMessageCampaign, template *models.Template) {
+	if campaignScheduledAhead(campaign) {
+		result := a.DB.Model(&models.BulkMessageCampaign{}).
+			Where("id = ? AND status = ?", campaign.ID, campaignStatusPendingTemplate).
+			Update("status", "scheduled")
+		if result.Error != nil {
+			a.Log.Error("Failed to schedule campaign after template approval", "error", result.Error, "campaign_id", campaign.ID)
+			return
+		}
+		if result.RowsAffected > 0 {
+			a.Log.Info("Campaign scheduled after template approval", "campaign_id", campaign.ID, "template", template.Name, "scheduled_at", campaign.ScheduledAt)
+			a.broadcastCampaignTemplateStatus(campaign, template, "scheduled", "")
+		}
+		return
+	}
+
+	// Only one server instance (or webhook delivery) gets to queue the campaign
+	result := a.DB.Model(&models.BulkMessageCampaign{}).
+		Where("id = ? AND status = ?", campaign.ID, campaignStatusPendingTemplate).
+		Updates(map[string]interface{}{
+			"status":     "queued",
+			"started_at": time.Now(),
+		})
+	if result.Error != nil {
+		a.Log.Error("Failed to queue campaign after template approval", "error", result.Error, "campaign_id", campaign.ID)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	a.Log.Info("Campaign started after template approval", "campaign_id", campaign.ID, "template", template.Name)
+	go a.dispatchCampaignEvent(campaign.OrganizationID, campaign.ID, EventCampaignQueued, "")
+	a.broadcastCampaignTemplateStatus(campaign, template, "queued", "")
+
+	if a.Queue != nil {
+		if err := a.Queue.EnqueueCampaign(ctx, campaign.ID, queue.Priority(campaign.Priority)); err != nil {
+			a.Log.Error("Failed to enqueue campaign", "error", err, "campaign_id", campaign.ID)
+		}
+	} else {
+		go a.processCampaign(campaign.ID)
+	}
+}
+
+// broadcastCampaignTemplateStatus tells connected clients what happened to a campaign
+// that was waiting on its template
+func (a *App) broadcastCampaignTemplateStatus(campaign *models.BulkMessageCampaign, template *models.Template, status, reason string) {
+	if a.WSHub == nil {
+		return
+	}
+
+	a.WSHub.BroadcastToOrg(campaign.OrganizationID, websocket.WSMessage{
+		Type: websocket.TypeCampaignTemplateStatus,
+		Payload: map[string]interface{}{
+			"campaign_id":     campaign.ID.String(),
+			"campaign_name":   campaign.Name,
+			"status":          status,
+			"template_id":     template.ID.String(),
+			"template_name":   template.Name,
+			"template_status": template.Status,
+			"reason":          reason,
+		},
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/shridarpatil/whatomate/internal/flowexpr"
 )
 
 // Template syntax patterns
@@ -15,6 +17,9 @@ var (
 	// {{if condition}}...{{else}}...{{endif}} or {{if condition}}...{{endif}}
 	ifElsePattern = regexp.MustCompile(`\{\{if\s+([^}]+)\}\}([\s\S]*?)\{\{endif\}\}`)
 
+	// {{= expression}}, see the flowexpr package
+	expressionPattern = regexp.MustCompile(`\{\{=([\s\S]*?)\}\}`)
+
 	// {{variable}} or {{object.nested.path}} or {{array[0].field}}
 	variablePattern = regexp.MustCompile(`\{\{([a-zA-Z_][a-zA-Z0-9_]*(?:\.[a-zA-Z_][a-zA-Z0-9_]*|\[\d+\])*)\}\}`)
 
@@ -24,28 +29,38 @@ var (
 
 const maxLoopIterations = 50
 
-// processTemplate processes a template string with variables, conditionals, and loops
+// processTemplate processes a template string with variables, expressions, conditionals, and loops
 func processTemplate(template string, data map[string]interface{}) string {
-	if data == nil {
-		data = make(map[string]interface{})
+	return renderTemplate(template, flowexpr.Env{Data: data})
+}
+
+// renderTemplate processes a template whose expressions are evaluated in env, and
+// whose variables are filled from env's data
+func renderTemplate(template string, env flowexpr.Env) string {
+	if env.Data == nil {
+		env.Data = make(map[string]interface{})
 	}
 
 	result := template
 
-	// 1. Process for loops first (they may contain if blocks and variables)
-	result = processForLoops(result, data)
+	// 1. Process for loops first (they may contain if blocks, expressions and variables)
+	result = processForLoops(result, env)
 
 	// 2. Process if/else conditionals
-	result = processConditionals(result, data)
+	result = processConditionals(result, env.Data)
 
-	// 3. Process remaining variable replacements
-	result = processVariables(result, data)
+	// 3. Process expressions
+	result = processExpressions(result, env)
+
+	// 4. Process remaining variable replacements
+	result = processVariables(result, env.Data)
 
 	return result
 }
 
 // processForLoops handles {{for item in items}}...{{endfor}} blocks
-func processForLoops(template string, data map[string]interface{}) string {
+func processForLoops(template string, env flowexpr.Env) string {
+	data := env.Data
 	result := template
 
 	for {
@@ -80,6 +95,7 @@ func processForLoops(template string, data map[string]interface{}) string {
 
 				// Process the loop body with the loop context
 				processedBody := processConditionals(loopBody, loopData)
+				processedBody = processExpressions(processedBody, loopEnv(env, loopData))
 				processedBody = processVariables(processedBody, loopData)
 				output.WriteString(processedBody)
 			}
@@ -95,6 +111,7 @@ func processForLoops(template string, data map[string]interface{}) string {
 				loopData[itemVar+"_index"] = i
 
 				processedBody := processConditionals(loopBody, loopData)
+				processedBody = processExpressions(processedBody, loopEnv(env, loopData))
 				processedBody = processVariables(processedBody, loopData)
 				output.WriteString(processedBody)
 			}
@@ -149,6 +166,31 @@ func processConditionals(template string, data map[string]interface{}) string {
 	return result
 }
 
+// loopEnv returns env with the data of a loop iteration
+func loopEnv(env flowexpr.Env, loopData map[string]interface{}) flowexpr.Env {
+	env.Data = loopData
+	return env
+}
+
+// processExpressions replaces {{= expression}} with the expression's value. One that
+// fails to parse or evaluate is replaced with nothing.
+func processExpressions(template string, env flowexpr.Env) string {
+	if !strings.Contains(template, "{{=") {
+		return template
+	}
+	return expressionPattern.ReplaceAllStringFunc(template, func(match string) string {
+		expr, err := flowexpr.Parse(match[3 : len(match)-2])
+		if err != nil {
+			return ""
+		}
+		value, err := expr.Eval(env)
+		if err != nil {
+			return ""
+		}
+		return flowexpr.Format(value)
+	})
+}
+
 // processVariables replaces {{variable}} and {{object.path}} with values
 func processVariables(template string, data map[string]interface{}) string {
 	return variablePattern.ReplaceAllStringFunc(template, func(match string) string {
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// Template migration results
+const (
+	TemplateMigrationCreated = "created"
+	TemplateMigrationExists  = "exists"
+	TemplateMigrationSkipped = "skipped"
+	TemplateMigrationFailed  = "failed"
+)
+
+// TemplateMigrationRequest is the request body for copying templates between WABAs
+type TemplateMigrationRequest struct {
+	SourceAccount string   `json:"source_account"`
+	TargetAccount string   `json:"target_account"`
+	Names         []string `json:"names"` // Optional: only copy these templates
+}
+
+// TemplateMigrationResult maps one source template to its copy on the target WABA
+type TemplateMigrationResult struct {
+	Name             string `json:"name"`
+	Language         string `json:"language"`
+	Category         string `json:"category"`
+	SourceStatus     string `json:"source_status"`
+	TargetStatus     string `json:"target_status,omitempty"`
+	TargetTemplateID string `json:"target_template_id,omitempty"`
+	Result           string `json:"result"`
+	Error            string `json:"error,omitempty"`
+
+	target *whatsapp.MetaTemplate // The template as it exists on the target WABA
+}
+
+// MigrateTemplates copies every template (all languages, with their examples) from the
+// source account's WABA to the target account's WABA through the Graph API. Templates
+// that already exist on the target are left alone. The target's copies are stored
+// locally and the response reports each template's old and new status.
+func (a *App) MigrateTemplates(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req TemplateMigrationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if req.SourceAccount == "" || req.TargetAccount == "" {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "source_account and target_account are required", nil, "")
+	}
+
+	var source, target models.WhatsAppAccount
+	if err := a.DB.Where("name = ? AND organization_id = ?", req.SourceAccount, orgID).First(&source).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Source account not found", nil, "")
+	}
+	if err := a.DB.Where("name = ? AND organization_id = ?", req.TargetAccount, orgID).First(&target).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Target account not found", nil, "")
+	}
+	if source.BusinessID == target.BusinessID {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Source and target accounts share the same WhatsApp Business Account", nil, "")
+	}
+
+	results, err := a.copyTemplatesToWABA(&source, &target, req.Names)
+	if err != nil {
+		a.Log.Error("Failed to migrate templates", "error", err, "source", source.Name, "target", target.Name)
+		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to fetch templates from Meta: "+err.Error(), nil, "")
+	}
+
+	counts := map[string]int{}
+	for _, result := range results {
+		if result.target != nil {
+			a.upsertMetaTemplate(orgID, target.Name, result.target)
+		}
+		counts[result.Result]++
+	}
+
+	a.Log.Info("Templates migrated", "source", source.Name, "target", target.Name,
+		"created", counts[TemplateMigrationCreated], "failed", counts[TemplateMigrationFailed])
+
+	return r.SendEnvelope(map[string]interface{}{
+		"source_account": source.Name,
+		"target_account": target.Name,
+		"created":        counts[TemplateMigrationCreated],
+		"existing":       counts[TemplateMigrationExists],
+		"skipped":        counts[TemplateMigrationSkipped],
+		"failed":         counts[TemplateMigrationFailed],
+		"results":        results,
+	})
+}
+
+// copyTemplatesToWABA creates the source account's templates (optionally only those
+// named) on the target account's WABA, skipping deleted templates and those the
+// target already has. Nothing is stored locally.
+func (a *App) copyTemplatesToWABA(source, target *models.WhatsAppAccount, names []string) ([]TemplateMigrationResult, error) {
+	sourceTemplates, err := a.fetchTemplatesFromMeta(source)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	targetTemplates, err := a.fetchTemplatesFromMeta(target)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	existing := make(map[string]*whatsapp.MetaTemplate, len(targetTemplates))
+	for i := range targetTemplates {
+		existing[targetTemplates[i].Name+"/"+targetTemplates[i].Language] = &targetTemplates[i]
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	waTarget := &whatsapp.Account{
+		PhoneID:     target.PhoneID,
+		BusinessID:  target.BusinessID,
+		APIVersion:  target.APIVersion,
+		AccessToken: target.AccessToken,
+	}
+	ctx := context.Background()
+
+	results := make([]TemplateMigrationResult, 0, len(sourceTemplates))
+	for i := range sourceTemplates {
+		tmpl := &sourceTemplates[i]
+		if len(wanted) > 0 && !wanted[tmpl.Name] {
+			continue
+		}
+
+		result := TemplateMigrationResult{
+			Name:         tmpl.Name,
+			Language:     tmpl.Language,
+			Category:     tmpl.Category,
+			SourceStatus: tmpl.Status,
+		}
+
+		switch {
+		case strings.EqualFold(tmpl.Status, "DELETED") || strings.EqualFold(tmpl.Status, "PENDING_DELETION"):
+			result.Result = TemplateMigrationSkipped
+		case existing[tmpl.Name+"/"+tmpl.Language] != nil:
+			result.target = existing[tmpl.Name+"/"+tmpl.Language]
+			result.Result = TemplateMigrationExists
+			result.TargetStatus = result.target.Status
+			result.TargetTemplateID = result.target.ID
+		default:
+			resp, err := a.WhatsApp.CopyTemplate(ctx, waTarget, tmpl)
+			if err != nil {
+				result.Result = TemplateMigrationFailed
+				result.Error = err.Error()
+				break
+			}
+
+			copied := *tmpl
+			copied.ID = resp.ID
+			copied.Status = resp.Status
+			if copied.Status == "" {
+				copied.Status = "PENDING"
+			}
+			if resp.Category != "" {
+				copied.Category = resp.Category
+			}
+			result.target = &copied
+			result.Result = TemplateMigrationCreated
+			result.TargetStatus = copied.Status
+			result.TargetTemplateID = copied.ID
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// TemplateParamSourcesRequest sets the contact field each parameter of a template is
+// filled from
+type TemplateParamSourcesRequest struct {
+	ParamSources map[string]string `json:"param_sources"` // e.g. {"1": "first_name"}; an empty field turns the organization default off
+}
+
+// UpdateTemplateParamSources replaces the contact fields a template's parameters are
+// filled from. Unlike the rest of a template they are never sent to Meta, so they can
+// be changed after approval.
+func (a *App) UpdateTemplateParamSources(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid template ID", nil, "")
+	}
+
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&template).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
+	}
+
+	var req TemplateParamSourcesRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	sources, err := templateParamSources(template.BodyContent, req.ParamSources)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Model(&template).Update("param_sources", sources).Error; err != nil {
+		a.Log.Error("Failed to update template parameter sources", "error", err, "template_id", template.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update template", nil, "")
+	}
+	template.ParamSources = sources
+
+	return r.SendEnvelope(templateToResponse(template))
+}
+
+// templateParamSources validates the parameter sources of a template with body and
+// returns them to store. Only parameters the body uses can have a source.
+func templateParamSources(body string, sources map[string]string) (models.JSONB, error) {
+	if err := models.ValidateTemplateParamSources(sources); err != nil {
+		return nil, err
+	}
+	params := map[string]bool{}
+	for _, key := range models.TemplateBodyParams(body) {
+		params[key] = true
+	}
+
+	stored := models.JSONB{}
+	for key, field := range sources {
+		if !params[key] {
+			return nil, fmt.Errorf("template has no parameter {{%s}}", key)
+		}
+		stored[key] = field
+	}
+	return stored, nil
+}
+
+// orgSettings returns an organization's settings, or none if they can't be loaded
+func (a *App) orgSettings(orgID uuid.UUID) models.JSONB {
+	var org models.Organization
+	if err := a.DB.Select("id", "settings").Where("id = ?", orgID).First(&org).Error; err != nil {
+		a.Log.Error("Failed to load organization settings", "error", err, "organization_id", orgID)
+		return models.JSONB{}
+	}
+	return org.Settings
+}
+
+// fillContactTemplateParams fills the parameters of template that params has no value
+// for from contact's fields, per the template's and its organization's parameter
+// sources. settings are the organization's, or nil to load them.
+func (a *App) fillContactTemplateParams(settings models.JSONB, template *models.Template, contact *models.Contact, params models.JSONB) models.JSONB {
+	if settings == nil {
+		settings = a.orgSettings(template.OrganizationID)
+	}
+	sources := models.TemplateParamSources(settings, template)
+	return models.FillTemplateParams(template, params, sources, models.ContactParamFields(contact))
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// templateUnusedDays is how long a template can go without being sent before it is
+// flagged as unused
+const templateUnusedDays = 90
+
+// liveCampaignStatuses are the campaign statuses that will still send
+var liveCampaignStatuses = []string{"draft", "scheduled", "pending_template", "queued", "processing", "held", "paused"}
+
+// TemplateUsageRef is a campaign or automation that sends a template. Active refs are
+// ones that may still send it.
+type TemplateUsageRef struct {
+	Type   string    `json:"type"` // campaign, date_trigger, win_back, chatbot_flow
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Status string    `json:"status,omitempty"` // Campaign status
+	Active bool      `json:"active"`
+}
+
+// TemplateUsage describes where a template is used and how much it has been sent
+type TemplateUsage struct {
+	TemplateID  uuid.UUID          `json:"template_id"`
+	References  []TemplateUsageRef `json:"references"`
+	ActiveCount int                `json:"active_count"`
+	LastUsedOn  string             `json:"last_used_on,omitempty"` // Last day the template was sent
+	Unused      bool               `json:"unused"`                 // Not sent in templateUnusedDays
+	Sent        int64              `json:"sent"`                   // Counts over templateUnusedDays
+	Delivered   int64              `json:"delivered"`
+	Read        int64              `json:"read"`
+	Failed      int64              `json:"failed"`
+}
+
+// GetTemplateUsage returns the campaigns and automations that use a template, with its
+// send counts over the last 90 days
+func (a *App) GetTemplateUsage(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	template, err := a.findTemplate(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
+	}
+
+	usage, err := a.templateUsage(template)
+	if err != nil {
+		a.Log.Error("Failed to load template usage", "error", err, "template_id", template.ID)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to load template usage", nil, "")
+	}
+
+	return r.SendEnvelope(usage)
+}
+
+// templateUsage finds everything that references the template and its recent send
+// counts from the message rollups
+func (a *App) templateUsage(template *models.Template) (*TemplateUsage, error) {
+	usage := &TemplateUsage{TemplateID: template.ID, References: []TemplateUsageRef{}}
+	orgID := template.OrganizationID
+
+	var campaigns []models.BulkMessageCampaign
+	if err := a.DB.Select("id", "name", "status").
+		Where("organization_id = ? AND (template_id = ? OR id IN (?))", orgID, template.ID,
+			a.DB.Model(&models.CampaignVariant{}).Select("campaign_id").Where("template_id = ?", template.ID)).
+		Order("created_at DESC").Find(&campaigns).Error; err != nil {
+		return nil, fmt.Errorf("failed to load campaigns: %w", err)
+	}
+	for _, c := range campaigns {
+		usage.References = append(usage.References, TemplateUsageRef{
+			Type:   "campaign",
+			ID:     c.ID,
+			Name:   c.Name,
+			Status: c.Status,
+			Active: slices.Contains(liveCampaignStatuses, c.Status),
+		})
+	}
+
+	var triggers []models.DateTrigger
+	if err := a.DB.Select("id", "name", "is_enabled").
+		Where("organization_id = ? AND template_id = ?", orgID, template.ID).Find(&triggers).Error; err != nil {
+		return nil, fmt.Errorf("failed to load date triggers: %w", err)
+	}
+	for _, t := range triggers {
+		usage.References = append(usage.References, TemplateUsageRef{Type: "date_trigger", ID: t.ID, Name: t.Name, Active: t.IsEnabled})
+	}
+
+	var automations []models.WinBackAutomation
+	if err := a.DB.Select("id", "name", "is_enabled").
+		Where("organization_id = ? AND steps @> ?::jsonb", orgID, fmt.Sprintf(`[{"template_id": %q}]`, template.ID.String())).
+		Find(&automations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load win-back automations: %w", err)
+	}
+	for _, w := range automations {
+		usage.References = append(usage.References, TemplateUsageRef{Type: "win_back", ID: w.ID, Name: w.Name, Active: w.IsEnabled})
+	}
+
+	var flows []models.ChatbotFlow
+	if err := a.DB.Select("id", "name", "is_enabled").
+		Where("organization_id = ?", orgID).
+		Where("initial_template_id = ? OR id IN (?)", template.ID,
+			a.DB.Model(&models.ChatbotFlowStep{}).Select("flow_id").Where("template_id = ?", template.ID)).
+		Find(&flows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load chatbot flows: %w", err)
+	}
+	for _, f := range flows {
+		usage.References = append(usage.References, TemplateUsageRef{Type: "chatbot_flow", ID: f.ID, Name: f.Name, Active: f.IsEnabled})
+	}
+
+	for _, ref := range usage.References {
+		if ref.Active {
+			usage.ActiveCount++
+		}
+	}
+
+	since := time.Now().AddDate(0, 0, -templateUnusedDays)
+	var stats struct {
+		Sent      int64
+		Delivered int64
+		Read      int64
+		Failed    int64
+	}
+	if err := a.DB.Model(&models.MessageDailyRollup{}).
+		Select("COALESCE(SUM(sent_count), 0) AS sent, COALESCE(SUM(delivered_count), 0) AS delivered, COALESCE(SUM(read_count), 0) AS read, COALESCE(SUM(failed_count), 0) AS failed").
+		Where("organization_id = ? AND whats_app_account = ? AND template_name = ? AND date >= ?", orgID, template.WhatsAppAccount, template.Name, since).
+		Scan(&stats).Error; err != nil {
+		return nil, fmt.Errorf("failed to load template stats: %w", err)
+	}
+	usage.Sent, usage.Delivered, usage.Read, usage.Failed = stats.Sent, stats.Delivered, stats.Read, stats.Failed
+
+	lastUsed, err := a.templateLastUsed(orgID, []models.Template{*template})
+	if err != nil {
+		return nil, err
+	}
+	if day, ok := lastUsed[templateKey(template.WhatsAppAccount, template.Name)]; ok {
+		usage.LastUsedOn = day.Format("2006-01-02")
+	}
+	usage.Unused = templateUnused(template, lastUsed)
+
+	return usage, nil
+}
+
+// templateLastUsed returns the last day each template was sent, keyed by templateKey.
+// Templates never sent are missing from the map.
+func (a *App) templateLastUsed(orgID uuid.UUID, templates []models.Template) (map[string]time.Time, error) {
+	names := make([]string, 0, len(templates))
+	for _, t := range templates {
+		names = append(names, t.Name)
+	}
+	result := make(map[string]time.Time, len(templates))
+	if len(names) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		WhatsAppAccount string
+		TemplateName    string
+		LastUsed        time.Time
+	}
+	if err := a.DB.Model(&models.MessageDailyRollup{}).
+		Select("whats_app_account, template_name, MAX(date) AS last_used").
+		Where("organization_id = ? AND template_name IN ? AND sent_count > 0", orgID, names).
+		Group("whats_app_account, template_name").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load template last use: %w", err)
+	}
+	for _, row := range rows {
+		result[templateKey(row.WhatsAppAccount, row.TemplateName)] = row.LastUsed
+	}
+	return result, nil
+}
+
+// templateUnused reports whether a template older than templateUnusedDays hasn't been
+// sent in that time
+func templateUnused(t *models.Template, lastUsed map[string]time.Time) bool {
+	cutoff := time.Now().AddDate(0, 0, -templateUnusedDays)
+	if t.CreatedAt.After(cutoff) {
+		return false
+	}
+	day, ok := lastUsed[templateKey(t.WhatsAppAccount, t.Name)]
+	return !ok || day.Before(cutoff)
+}
+
+func templateKey(account, name string) string {
+	return account + "/" + name
+}
+
+// checkTemplateInUse stops a change to a template that active campaigns or automations
+// still send, unless the request passes force=true. It returns true when it has sent
+// the conflict response.
+func (a *App) checkTemplateInUse(r *fastglue.Request, template *models.Template, action string) (bool, error) {
+	if string(r.RequestCtx.QueryArgs().Peek("force")) == "true" {
+		return false, nil
+	}
+
+	usage, err := a.templateUsage(template)
+	if err != nil {
+		a.Log.Error("Failed to check template usage", "error", err, "template_id", template.ID)
+		return true, r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to check template usage", nil, "")
+	}
+	if usage.ActiveCount == 0 {
+		return false, nil
+	}
+
+	msg := fmt.Sprintf("Can't %s a template used by %d active campaign(s) or automation(s)", action, usage.ActiveCount)
+	return true, r.SendErrorEnvelope(fasthttp.StatusConflict, msg, usage, "")
+}
+
+func (a *App) findTemplate(r *fastglue.Request, orgID uuid.UUID) (*models.Template, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var template models.Template
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
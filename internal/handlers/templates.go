@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
@@ -25,6 +26,10 @@ type TemplateRequest struct {
 	FooterContent   string        `json:"footer_content"`
 	Buttons         []interface{} `json:"buttons"`
 	SampleValues    []interface{} `json:"sample_values"`
+
+	// Contact field each parameter is filled from, e.g. {"1": "first_name"}; set on
+	// create, and changed with UpdateTemplateParamSources
+	ParamSources map[string]string `json:"param_sources"`
 }
 
 // TemplateResponse represents the response for a template
@@ -43,6 +48,9 @@ type TemplateResponse struct {
 	FooterContent   string        `json:"footer_content"`
 	Buttons         []interface{} `json:"buttons"`
 	SampleValues    []interface{} `json:"sample_values"`
+	ParamSources    models.JSONB  `json:"param_sources"`
+	LastUsedOn      string        `json:"last_used_on,omitempty"` // Last day the template was sent
+	Unused          bool          `json:"unused"`                 // Not sent in the last 90 days
 	CreatedAt       string        `json:"created_at"`
 	UpdatedAt       string        `json:"updated_at"`
 }
@@ -77,9 +85,24 @@ func (a *App) ListTemplates(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list templates", nil, "")
 	}
 
-	response := make([]TemplateResponse, len(templates))
+	lastUsed, err := a.templateLastUsed(orgID, templates)
+	if err != nil {
+		a.Log.Error("Failed to load template usage", "error", err)
+		lastUsed = map[string]time.Time{}
+	}
+	onlyUnused := string(r.RequestCtx.QueryArgs().Peek("unused")) == "true"
+
+	response := make([]TemplateResponse, 0, len(templates))
 	for i, t := range templates {
-		response[i] = templateToResponse(t)
+		resp := templateToResponse(t)
+		if day, ok := lastUsed[templateKey(t.WhatsAppAccount, t.Name)]; ok {
+			resp.LastUsedOn = day.Format("2006-01-02")
+		}
+		resp.Unused = templateUnused(&templates[i], lastUsed)
+		if onlyUnused && !resp.Unused {
+			continue
+		}
+		response = append(response, resp)
 	}
 
 	return r.SendEnvelope(map[string]interface{}{
@@ -124,6 +147,11 @@ func (a *App) CreateTemplate(r *fastglue.Request) error {
 		displayName = req.Name
 	}
 
+	paramSources, err := templateParamSources(req.BodyContent, req.ParamSources)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
 	template := models.Template{
 		OrganizationID:  orgID,
 		WhatsAppAccount: req.WhatsAppAccount,
@@ -138,6 +166,7 @@ func (a *App) CreateTemplate(r *fastglue.Request) error {
 		FooterContent:   req.FooterContent,
 		Buttons:         convertToJSONBArray(req.Buttons),
 		SampleValues:    convertToJSONBArray(req.SampleValues),
+		ParamSources:    paramSources,
 	}
 
 	if err := a.DB.Create(&template).Error; err != nil {
@@ -198,6 +227,11 @@ func (a *App) UpdateTemplate(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Cannot edit approved templates", nil, "")
 	}
 
+	// Campaigns and automations waiting to send it would pick up the edit
+	if handled, err := a.checkTemplateInUse(r, &template, "edit"); handled {
+		return err
+	}
+
 	var req TemplateRequest
 	if err := r.Decode(&req, "json"); err != nil {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
@@ -257,6 +291,11 @@ func (a *App) DeleteTemplate(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Template not found", nil, "")
 	}
 
+	// Don't break campaigns and automations that still send it
+	if handled, err := a.checkTemplateInUse(r, &template, "delete"); handled {
+		return err
+	}
+
 	// If template exists on Meta, delete it there too
 	if template.MetaTemplateID != "" {
 		var account models.WhatsAppAccount
@@ -296,7 +335,7 @@ func (a *App) SubmitTemplate(r *fastglue.Request) error {
 	}
 
 	// Check if already submitted and not rejected
-	if template.MetaTemplateID != "" && template.Status != "REJECTED" {
+	if template.MetaTemplateID != "" && !strings.EqualFold(template.Status, "REJECTED") {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Template already submitted to Meta", nil, "")
 	}
 
@@ -306,24 +345,12 @@ func (a *App) SubmitTemplate(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
 	}
 
-	// For rejected templates, delete the old one first then create new
-	if template.Status == "REJECTED" && template.MetaTemplateID != "" {
-		a.Log.Info("Deleting rejected template before resubmission", "template", template.Name)
-		a.deleteTemplateFromMeta(&account, template.Name)
-		// Clear the old meta template ID
-		template.MetaTemplateID = ""
-	}
-
-	// Submit template to Meta
-	metaTemplateID, submitErr := a.submitTemplateToMeta(&account, &template)
-	if submitErr != nil {
+	if submitErr := a.requestTemplateApproval(&account, &template); submitErr != nil {
 		a.Log.Error("Failed to submit template to Meta", "error", submitErr)
 		return r.SendErrorEnvelope(fasthttp.StatusBadGateway, "Failed to submit template to Meta: "+submitErr.Error(), nil, "")
 	}
-	template.MetaTemplateID = metaTemplateID
+	metaTemplateID := template.MetaTemplateID
 
-	// Update template status
-	template.Status = "PENDING"
 	if err := a.DB.Save(&template).Error; err != nil {
 		a.Log.Error("Failed to update template after submission", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Template submitted but failed to update local record", nil, "")
@@ -337,6 +364,30 @@ func (a *App) SubmitTemplate(r *fastglue.Request) error {
 	})
 }
 
+// requestTemplateApproval submits template to Meta and marks it PENDING. Rejected
+// templates are deleted on Meta first so they can be resubmitted under the same name.
+// The caller saves the template.
+func (a *App) requestTemplateApproval(account *models.WhatsAppAccount, template *models.Template) error {
+	// For rejected templates, delete the old one first then create new
+	if strings.EqualFold(template.Status, "REJECTED") && template.MetaTemplateID != "" {
+		a.Log.Info("Deleting rejected template before resubmission", "template", template.Name)
+		a.deleteTemplateFromMeta(account, template.Name)
+		// Clear the old meta template ID
+		template.MetaTemplateID = ""
+	}
+
+	// Footers can't be added to an approved template, so they are added on submission
+	a.applyTemplateFooter(template)
+
+	metaTemplateID, err := a.submitTemplateToMeta(account, template)
+	if err != nil {
+		return err
+	}
+	template.MetaTemplateID = metaTemplateID
+	template.Status = "PENDING"
+	return nil
+}
+
 // submitTemplateToMeta submits a template to Meta's API
 func (a *App) submitTemplateToMeta(account *models.WhatsAppAccount, template *models.Template) (string, error) {
 	waAccount := &whatsapp.Account{
@@ -397,62 +448,8 @@ func (a *App) SyncTemplates(r *fastglue.Request) error {
 
 	// Sync to database
 	synced := 0
-	for _, metaTemplate := range templates {
-		template := models.Template{
-			OrganizationID:  orgID,
-			WhatsAppAccount: account.Name,
-			MetaTemplateID:  metaTemplate.ID,
-			Name:            metaTemplate.Name,
-			DisplayName:     metaTemplate.Name,
-			Language:        metaTemplate.Language,
-			Category:        metaTemplate.Category,
-			Status:          metaTemplate.Status,
-		}
-
-		// Parse components
-		for _, comp := range metaTemplate.Components {
-			switch comp.Type {
-			case "HEADER":
-				template.HeaderType = comp.Format
-				if comp.Text != "" {
-					template.HeaderContent = comp.Text
-				}
-			case "BODY":
-				template.BodyContent = comp.Text
-			case "FOOTER":
-				template.FooterContent = comp.Text
-			case "BUTTONS":
-				// Convert []TemplateButton to []interface{}
-				buttons := make([]interface{}, len(comp.Buttons))
-				for i, btn := range comp.Buttons {
-					buttons[i] = btn
-				}
-				template.Buttons = convertToJSONBArray(buttons)
-			}
-		}
-
-		// Upsert (including soft-deleted templates to restore them)
-		existing := models.Template{}
-		if err := a.DB.Unscoped().Where("organization_id = ? AND whats_app_account = ? AND name = ? AND language = ?",
-			orgID, account.Name, template.Name, template.Language).First(&existing).Error; err == nil {
-			// Update existing and restore if soft-deleted (explicitly set deleted_at to NULL)
-			template.ID = existing.ID
-			a.DB.Unscoped().Model(&template).Updates(map[string]interface{}{
-				"meta_template_id": template.MetaTemplateID,
-				"display_name":     template.DisplayName,
-				"category":         template.Category,
-				"status":           template.Status,
-				"header_type":      template.HeaderType,
-				"header_content":   template.HeaderContent,
-				"body_content":     template.BodyContent,
-				"footer_content":   template.FooterContent,
-				"buttons":          template.Buttons,
-				"deleted_at":       nil, // Restore soft-deleted template
-			})
-		} else {
-			// Create new
-			a.DB.Create(&template)
-		}
+	for i := range templates {
+		a.upsertMetaTemplate(orgID, account.Name, &templates[i])
 		synced++
 	}
 
@@ -462,6 +459,66 @@ func (a *App) SyncTemplates(r *fastglue.Request) error {
 	})
 }
 
+// upsertMetaTemplate stores a template fetched from Meta for an account, restoring it
+// if it was soft-deleted
+func (a *App) upsertMetaTemplate(orgID uuid.UUID, accountName string, metaTemplate *whatsapp.MetaTemplate) {
+	template := models.Template{
+		OrganizationID:  orgID,
+		WhatsAppAccount: accountName,
+		MetaTemplateID:  metaTemplate.ID,
+		Name:            metaTemplate.Name,
+		DisplayName:     metaTemplate.Name,
+		Language:        metaTemplate.Language,
+		Category:        metaTemplate.Category,
+		Status:          metaTemplate.Status,
+	}
+
+	// Parse components
+	for _, comp := range metaTemplate.Components {
+		switch comp.Type {
+		case "HEADER":
+			template.HeaderType = comp.Format
+			if comp.Text != "" {
+				template.HeaderContent = comp.Text
+			}
+		case "BODY":
+			template.BodyContent = comp.Text
+		case "FOOTER":
+			template.FooterContent = comp.Text
+		case "BUTTONS":
+			// Convert []TemplateButton to []interface{}
+			buttons := make([]interface{}, len(comp.Buttons))
+			for i, btn := range comp.Buttons {
+				buttons[i] = btn
+			}
+			template.Buttons = convertToJSONBArray(buttons)
+		}
+	}
+
+	// Upsert (including soft-deleted templates to restore them)
+	existing := models.Template{}
+	if err := a.DB.Unscoped().Where("organization_id = ? AND whats_app_account = ? AND name = ? AND language = ?",
+		orgID, accountName, template.Name, template.Language).First(&existing).Error; err == nil {
+		// Update existing and restore if soft-deleted (explicitly set deleted_at to NULL)
+		template.ID = existing.ID
+		a.DB.Unscoped().Model(&template).Updates(map[string]interface{}{
+			"meta_template_id": template.MetaTemplateID,
+			"display_name":     template.DisplayName,
+			"category":         template.Category,
+			"status":           template.Status,
+			"header_type":      template.HeaderType,
+			"header_content":   template.HeaderContent,
+			"body_content":     template.BodyContent,
+			"footer_content":   template.FooterContent,
+			"buttons":          template.Buttons,
+			"deleted_at":       nil, // Restore soft-deleted template
+		})
+	} else {
+		// Create new
+		a.DB.Create(&template)
+	}
+}
+
 func (a *App) fetchTemplatesFromMeta(account *models.WhatsAppAccount) ([]whatsapp.MetaTemplate, error) {
 	waAccount := &whatsapp.Account{
 		PhoneID:     account.PhoneID,
@@ -506,6 +563,7 @@ func templateToResponse(t models.Template) TemplateResponse {
 		FooterContent:   t.FooterContent,
 		Buttons:         convertFromJSONBArray(t.Buttons),
 		SampleValues:    convertFromJSONBArray(t.SampleValues),
+		ParamSources:    t.ParamSources,
 		CreatedAt:       t.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:       t.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/phonenumber"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// maxTestSendNumbers caps the test numbers a campaign test send goes to
+const maxTestSendNumbers = 20
+
+// recipientIsTestNumber matches recipients (aliased r) whose number is one of the
+// organization's test numbers; its argument is the organization ID
+const recipientIsTestNumber = `EXISTS (SELECT 1 FROM test_numbers tn WHERE tn.organization_id = ? AND tn.deleted_at IS NULL
+	AND tn.phone_number = TRIM(LEADING '+' FROM r.phone_number))`
+
+// contactIsTestNumber matches contacts whose number is one of their organization's
+// test numbers
+const contactIsTestNumber = `EXISTS (SELECT 1 FROM test_numbers tn WHERE tn.organization_id = contacts.organization_id
+	AND tn.deleted_at IS NULL AND tn.phone_number = TRIM(LEADING '+' FROM contacts.phone_number))`
+
+// messageToTestNumber matches messages exchanged with a contact whose number is one of
+// the organization's test numbers
+const messageToTestNumber = `EXISTS (SELECT 1 FROM contacts JOIN test_numbers tn ON tn.organization_id = contacts.organization_id
+	AND tn.deleted_at IS NULL AND tn.phone_number = TRIM(LEADING '+' FROM contacts.phone_number)
+	WHERE contacts.id = messages.contact_id)`
+
+// TestNumberRequest is the request body for registering a test number
+type TestNumberRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Label       string `json:"label"`
+}
+
+// CampaignTestSendRequest picks the test numbers a campaign test send goes to
+type CampaignTestSendRequest struct {
+	PhoneNumbers   []string               `json:"phone_numbers"`   // Registered test numbers; empty sends to all of them
+	TemplateParams map[string]interface{} `json:"template_params"` // Parameters contact fields and the campaign's defaults don't fill
+}
+
+// CampaignTestSendResult is the outcome of a test send to one number
+type CampaignTestSendResult struct {
+	PhoneNumber string     `json:"phone_number"`
+	MessageID   *uuid.UUID `json:"message_id,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// ListTestNumbers returns the organization's test numbers
+func (a *App) ListTestNumbers(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var numbers []models.TestNumber
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at ASC").Find(&numbers).Error; err != nil {
+		a.Log.Error("Failed to list test numbers", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list test numbers", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"test_numbers": numbers,
+	})
+}
+
+// CreateTestNumber registers an internal test phone number
+func (a *App) CreateTestNumber(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+	userID, _ := r.RequestCtx.UserValue("user_id").(uuid.UUID)
+
+	var req TestNumberRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	n, err := phonenumber.Parse(req.PhoneNumber, "")
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid phone number: "+err.Error(), nil, "")
+	}
+	label := strings.TrimSpace(req.Label)
+	if len(label) > 100 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Label can be at most 100 characters", nil, "")
+	}
+
+	var count int64
+	a.DB.Model(&models.TestNumber{}).Where("organization_id = ? AND phone_number = ?", orgID, n.Digits()).Count(&count)
+	if count > 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusConflict, "Phone number is already a test number", nil, "")
+	}
+
+	number := models.TestNumber{
+		OrganizationID: orgID,
+		PhoneNumber:    n.Digits(),
+		Label:          label,
+		CreatedBy:      userID,
+	}
+	if err := a.DB.Create(&number).Error; err != nil {
+		a.Log.Error("Failed to create test number", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create test number", nil, "")
+	}
+
+	return r.SendEnvelope(number)
+}
+
+// DeleteTestNumber removes a test number; its traffic counts as usual from then on
+func (a *App) DeleteTestNumber(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid test number ID", nil, "")
+	}
+
+	result := a.DB.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.TestNumber{})
+	if result.Error != nil {
+		a.Log.Error("Failed to delete test number", "error", result.Error)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete test number", nil, "")
+	}
+	if result.RowsAffected == 0 {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Test number not found", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Test number deleted"})
+}
+
+// TestSendCampaign sends a campaign's template to test numbers straight away, as a
+// recipient would get it. Test sends work whatever the campaign's status and aren't
+// held by quiet hours, blackout dates, opt-outs or quotas, so only registered test
+// numbers can be sent to.
+func (a *App) TestSendCampaign(r *fastglue.Request) error {
+	orgID, err := a.getOrgIDFromContext(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid campaign ID", nil, "")
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).
+		Preload("Template").
+		First(&campaign).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+	}
+	if campaign.Template == nil || !strings.EqualFold(campaign.Template.Status, "APPROVED") {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign template must be approved to send a test", nil, "")
+	}
+
+	var req CampaignTestSendRequest
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+	numbers, err := a.testSendNumbers(orgID, req.PhoneNumbers)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("organization_id = ? AND name = ?", orgID, campaign.WhatsAppAccount).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "WhatsApp account not found", nil, "")
+	}
+
+	settings := a.orgSettings(orgID)
+	variants := a.campaignVariants(campaign.ID)
+	results := make([]CampaignTestSendResult, len(numbers))
+	for i, phone := range numbers {
+		results[i] = a.testSendCampaign(&campaign, variants, &account, settings, phone, req.TemplateParams)
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"results": results,
+	})
+}
+
+// testSendNumbers returns the test numbers a test send goes to: the requested ones,
+// which must all be registered, or else every registered one
+func (a *App) testSendNumbers(orgID uuid.UUID, requested []string) ([]string, error) {
+	registered := a.testNumberSet(orgID)
+	if len(registered) == 0 {
+		return nil, errors.New("organization has no test numbers")
+	}
+
+	var numbers []string
+	if len(requested) == 0 {
+		for phone := range registered {
+			numbers = append(numbers, phone)
+		}
+	}
+	seen := map[string]bool{}
+	for _, input := range requested {
+		n, err := phonenumber.Parse(input, "")
+		if err != nil || !registered[n.Digits()] {
+			return nil, fmt.Errorf("%s is not a registered test number", input)
+		}
+		if !seen[n.Digits()] {
+			seen[n.Digits()] = true
+			numbers = append(numbers, n.Digits())
+		}
+	}
+	if len(numbers) > maxTestSendNumbers {
+		return nil, fmt.Errorf("a test send can go to at most %d numbers", maxTestSendNumbers)
+	}
+	return numbers, nil
+}
+
+// testSendCampaign sends the campaign's template, or the variant the number would be
+// sent, to one test number and records the message
+func (a *App) testSendCampaign(campaign *models.BulkMessageCampaign, variants []models.CampaignVariant, account *models.WhatsAppAccount, settings models.JSONB, phone string, params map[string]interface{}) CampaignTestSendResult {
+	result := CampaignTestSendResult{PhoneNumber: phone}
+
+	template := campaign.Template
+	if variant := models.PickCampaignVariant(campaign.ID, phone, variants); variant != nil && variant.Template != nil {
+		template = variant.Template
+	}
+	contact, _ := a.getOrCreateContact(campaign.OrganizationID, phone, "")
+	if contact.ID == uuid.Nil {
+		result.Error = "failed to load contact"
+		return result
+	}
+
+	recipient := contactRecipient(contact, models.JSONB(params))
+	recipient.TemplateParams = a.fillContactTemplateParams(settings, template, contact, recipient.TemplateParams)
+	recipient.TemplateParams, _ = campaign.ResolveTemplateParams(template, recipient.TemplateParams)
+	if missing := missingTemplateParams(template, recipient.TemplateParams); len(missing) > 0 {
+		result.Error = models.MissingParamsError(missing)
+		return result
+	}
+
+	waMessageID, err := a.sendTemplateMessage(account, template, &recipient)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	message := models.Message{
+		OrganizationID:    campaign.OrganizationID,
+		WhatsAppAccount:   account.Name,
+		ContactID:         contact.ID,
+		WhatsAppMessageID: waMessageID,
+		Direction:         "outgoing",
+		MessageType:       "template",
+		Content:           renderTemplateText(template.BodyContent, recipient.TemplateParams),
+		TemplateName:      template.Name,
+		TemplateParams:    recipient.TemplateParams,
+		Status:            "sent",
+		Metadata: models.JSONB{
+			"test_send_campaign_id": campaign.ID.String(),
+		},
+	}
+	if err := a.DB.Create(&message).Error; err != nil {
+		a.Log.Error("Failed to save campaign test send message", "error", err, "campaign_id", campaign.ID)
+		return result
+	}
+	result.MessageID = &message.ID
+	return result
+}
+
+// testNumberSet returns the organization's test numbers
+func (a *App) testNumberSet(orgID uuid.UUID) map[string]bool {
+	var phones []string
+	if err := a.DB.Model(&models.TestNumber{}).Where("organization_id = ?", orgID).Pluck("phone_number", &phones).Error; err != nil {
+		a.Log.Error("Failed to load test numbers", "error", err, "organization_id", orgID)
+	}
+	set := make(map[string]bool, len(phones))
+	for _, phone := range phones {
+		set[phone] = true
+	}
+	return set
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/config"
+)
+
+// Transcriber converts recorded speech into text
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, filename, mimeType string) (string, error)
+}
+
+// newTranscriber returns the configured speech-to-text provider, or nil if transcription is disabled
+func newTranscriber(cfg config.STTConfig) Transcriber {
+	switch cfg.Provider {
+	case "openai", "whisper":
+		return &whisperTranscriber{
+			baseURL:  strings.TrimRight(cfg.BaseURL, "/"),
+			apiKey:   cfg.APIKey,
+			model:    cfg.Model,
+			language: cfg.Language,
+		}
+	default:
+		return nil
+	}
+}
+
+// whisperTranscriber talks to the OpenAI audio transcription API or any compatible server
+type whisperTranscriber struct {
+	baseURL  string
+	apiKey   string
+	model    string
+	language string
+}
+
+// Transcribe uploads the audio as multipart form data and returns the recognised text
+func (t *whisperTranscriber) Transcribe(ctx context.Context, audio []byte, filename, mimeType string) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, filename))
+	header.Set("Content-Type", mimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", fmt.Errorf("failed to write audio: %w", err)
+	}
+
+	_ = writer.WriteField("model", t.model)
+	if t.language != "" {
+		_ = writer.WriteField("language", t.language)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", t.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != 200 {
+		var errResp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(respBody, &errResp)
+		return "", fmt.Errorf("transcription API error %d: %s", resp.StatusCode, errResp.Error.Message)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return strings.TrimSpace(result.Text), nil
+}
+
+// transcribeAudio transcribes a locally stored voice note, returning an empty string when
+// transcription is disabled or fails so callers can carry on treating it as plain audio
+func (a *App) transcribeAudio(ctx context.Context, relativePath, mimeType string) string {
+	transcriber := newTranscriber(a.Config.STT)
+	if transcriber == nil || relativePath == "" {
+		return ""
+	}
+
+	audio, err := os.ReadFile(filepath.Join(a.getMediaStoragePath(), relativePath))
+	if err != nil {
+		a.Log.Error("Failed to read audio for transcription", "error", err, "path", relativePath)
+		return ""
+	}
+
+	// WhatsApp voice notes arrive as "audio/ogg; codecs=opus"; providers only want the base type
+	baseMime := strings.TrimSpace(strings.Split(mimeType, ";")[0])
+
+	transcript, err := transcriber.Transcribe(ctx, audio, filepath.Base(relativePath), baseMime)
+	if err != nil {
+		a.Log.Error("Failed to transcribe audio", "error", err, "path", relativePath)
+		return ""
+	}
+
+	return transcript
+}
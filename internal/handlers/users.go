@@ -27,6 +27,7 @@ type UserResponse struct {
 	Role           string        `json:"role"`
 	IsActive       bool          `json:"is_active"`
 	IsAvailable    bool          `json:"is_available"`
+	Locale         string        `json:"locale"`
 	OrganizationID uuid.UUID     `json:"organization_id"`
 	Settings       models.JSONB  `json:"settings,omitempty"`
 	CreatedAt      string        `json:"created_at"`
@@ -408,6 +409,7 @@ func userToResponse(user models.User) UserResponse {
 		Role:           user.Role,
 		IsActive:       user.IsActive,
 		IsAvailable:    user.IsAvailable,
+		Locale:         user.Locale,
 		OrganizationID: user.OrganizationID,
 		Settings:       user.Settings,
 		CreatedAt:      user.CreatedAt.Format("2006-01-02T15:04:05Z"),
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/shridarpatil/whatomate/internal/models"
 	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/valyala/fasthttp"
 	"github.com/zerodha/fastglue"
 )
@@ -169,10 +173,12 @@ type WebhookPayload struct {
 							Type  string `json:"type,omitempty"`
 						} `json:"phones,omitempty"`
 					} `json:"contacts,omitempty"`
+					Order   *whatsapp.OrderMessage `json:"order,omitempty"`
 					Context *struct {
 						From string `json:"from"`
 						ID   string `json:"id"`
 					} `json:"context,omitempty"`
+					Referral *MessageReferral `json:"referral,omitempty"`
 				} `json:"messages,omitempty"`
 				Statuses []WebhookStatus `json:"statuses,omitempty"`
 			} `json:"value"`
@@ -189,6 +195,11 @@ func (a *App) WebhookHandler(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid payload", nil, "")
 	}
 
+	signature := string(r.RequestCtx.Request.Header.Peek(webhookSignatureHeader))
+	if !a.verifyWebhookSignature(&payload, r.RequestCtx.PostBody(), signature) {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid signature", nil, "")
+	}
+
 	// Process each entry
 	for _, entry := range payload.Entry {
 		for _, change := range entry.Changes {
@@ -204,6 +215,13 @@ func (a *App) WebhookHandler(r *fastglue.Request) error {
 				continue
 			}
 
+			// Display name decisions and account updates change statuses tracked on the account
+			if change.Field == "phone_number_name_update" || change.Field == "account_update" {
+				a.Log.Info("Received account update", "field", change.Field, "waba_id", entry.ID)
+				go a.refreshAccountStatuses(entry.ID)
+				continue
+			}
+
 			if change.Field != "messages" {
 				continue
 			}
@@ -281,11 +299,11 @@ func (a *App) processStatusUpdate(phoneNumberID string, status WebhookStatus) {
 	a.Log.Info("Processing status update", "message_id", messageID, "status", statusValue, "phone_number_id", phoneNumberID)
 
 	// Update messages table - this also handles campaign stats via incrementCampaignStat
-	a.updateMessageStatus(messageID, statusValue, status.Errors)
+	a.updateMessageStatus(messageID, statusValue, status.Timestamp, status.Errors)
 }
 
 // updateMessageStatus updates the status of a regular message in the messages table
-func (a *App) updateMessageStatus(whatsappMsgID, statusValue string, errors []WebhookStatusError) {
+func (a *App) updateMessageStatus(whatsappMsgID, statusValue, timestamp string, errors []WebhookStatusError) {
 	// Find the message by WhatsApp message ID
 	var message models.Message
 	result := a.DB.Where("whats_app_message_id = ?", whatsappMsgID).First(&message)
@@ -313,7 +331,16 @@ func (a *App) updateMessageStatus(whatsappMsgID, statusValue string, errors []We
 		return
 	}
 
-	if err := a.DB.Model(&message).Updates(updates).Error; err != nil {
+	// Record Meta's time and error code on the message's timeline
+	event := models.MessageEvent{Source: models.MessageEventSourceWebhook}
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		event.OccurredAt = time.Unix(seconds, 0)
+	}
+	if len(errors) > 0 {
+		event.ErrorCode = errors[0].Code
+	}
+
+	if err := a.DB.Set(models.MessageEventDetails, event).Model(&message).Updates(updates).Error; err != nil {
 		a.Log.Error("Failed to update message status", "error", err, "message_id", message.ID)
 		return
 	}
@@ -324,6 +351,16 @@ func (a *App) updateMessageStatus(whatsappMsgID, statusValue string, errors []We
 	if message.Metadata != nil {
 		if campaignID, ok := message.Metadata["campaign_id"].(string); ok && campaignID != "" {
 			a.incrementCampaignStat(campaignID, statusValue)
+			errMsg, _ := updates["error_message"].(string)
+			if statusValue == "failed" {
+				a.recordRecipientFailure(&message, campaignID, event.ErrorCode, errMsg)
+			}
+			a.dispatchRecipientStatus(&message, campaignID, statusValue, errMsg)
+
+			// Run campaign_delivered triggers once per message, not again when it's read
+			if statusValue == "delivered" && message.Status != "delivered" && message.Status != "read" {
+				go a.fireCampaignDeliveredTriggers(message.ContactID, campaignID)
+			}
 		}
 	}
 
@@ -387,6 +424,13 @@ func (a *App) processTemplateStatusUpdate(wabaID, event, templateName, templateL
 				"status", status,
 				"reason", reason,
 			)
+
+			// Start or release campaigns that were waiting on this template
+			var templates []models.Template
+			a.DB.Where("whats_app_account = ? AND name = ? AND language = ?", account.Name, templateName, templateLanguage).Find(&templates)
+			for i := range templates {
+				a.resolveTemplateCampaigns(context.Background(), &templates[i], reason)
+			}
 		}
 	}
 }
@@ -2,27 +2,49 @@ package handlers
 
 import (
 	"bytes"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/shridarpatil/whatomate/internal/models"
+	webhooksig "github.com/shridarpatil/whatomate/pkg/webhook"
 )
 
 // WebhookEvent types
 const (
-	EventMessageIncoming  = "message.incoming"
-	EventMessageSent      = "message.sent"
-	EventContactCreated   = "contact.created"
-	EventTransferCreated  = "transfer.created"
-	EventTransferAssigned = "transfer.assigned"
-	EventTransferResumed  = "transfer.resumed"
+	EventMessageIncoming   = "message.incoming"
+	EventMessageSent       = "message.sent"
+	EventContactCreated    = "contact.created"
+	EventTransferCreated   = "transfer.created"
+	EventTransferAssigned  = "transfer.assigned"
+	EventTransferResumed   = "transfer.resumed"
+	EventCampaignQueued    = "campaign.queued"
+	EventCampaignStarted   = "campaign.started"
+	EventCampaignPaused    = "campaign.paused"
+	EventCampaignHeld      = "campaign.held"
+	EventCampaignCompleted = "campaign.completed"
+	EventCampaignFailed    = "campaign.failed"
+	EventCampaignRejected  = "campaign.template_rejected"
+	EventAccountAnomaly    = "account.anomaly_detected"
+	EventAccountWebhook    = "account.webhook_subscription"
+	EventAccountStatus     = "account.status_changed"
+	EventAccountReceipts   = "account.receipts_missed"
+	EventAccountAPIVersion = "account.api_version_sunset"
+	EventChatbotLimit      = "chatbot.message_limit"
 )
 
+// Campaign recipient events, only sent to webhooks subscribed to the recipient's campaign
+const (
+	EventRecipientSent      = "campaign.recipient_sent"
+	EventRecipientDelivered = "campaign.recipient_delivered"
+	EventRecipientRead      = "campaign.recipient_read"
+	EventRecipientFailed    = "campaign.recipient_failed"
+)
+
+// recipientEvents are the events only campaign webhooks can subscribe to
+var recipientEvents = []string{EventRecipientSent, EventRecipientDelivered, EventRecipientRead, EventRecipientFailed}
+
 // OutboundWebhookPayload represents the structure sent to external webhook endpoints
 type OutboundWebhookPayload struct {
 	Event     string      `json:"event"`
@@ -64,6 +86,115 @@ type TransferEventData struct {
 	WhatsAppAccount string  `json:"whatsapp_account"`
 }
 
+// CampaignEventData represents data for campaign lifecycle events
+type CampaignEventData struct {
+	CampaignID      string     `json:"campaign_id"`
+	Name            string     `json:"name"`
+	Status          string     `json:"status"`
+	WhatsAppAccount string     `json:"whatsapp_account"`
+	TemplateID      string     `json:"template_id"`
+	TemplateName    string     `json:"template_name,omitempty"`
+	TotalRecipients int        `json:"total_recipients"`
+	SentCount       int        `json:"sent_count"`
+	DeliveredCount  int        `json:"delivered_count"`
+	ReadCount       int        `json:"read_count"`
+	FailedCount     int        `json:"failed_count"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// RecipientEventData represents data for campaign recipient events
+type RecipientEventData struct {
+	CampaignID        string `json:"campaign_id"`
+	RecipientID       string `json:"recipient_id"`
+	PhoneNumber       string `json:"phone_number"`
+	RecipientName     string `json:"recipient_name,omitempty"`
+	Status            string `json:"status"` // sent, delivered, read, failed
+	Variant           string `json:"variant,omitempty"`
+	MessageID         string `json:"message_id,omitempty"`
+	WhatsAppMessageID string `json:"whatsapp_message_id,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// AnomalyEventData represents data for metric anomaly alerts
+type AnomalyEventData struct {
+	AnomalyID       string  `json:"anomaly_id"`
+	WhatsAppAccount string  `json:"whatsapp_account"`
+	Date            string  `json:"date"`
+	Metric          string  `json:"metric"`
+	Direction       string  `json:"direction"`
+	Value           float64 `json:"value"`
+	Baseline        float64 `json:"baseline"`
+	Volume          int64   `json:"volume"`
+	Message         string  `json:"message"`
+}
+
+// WebhookSubscriptionEventData represents data for webhook subscription alerts
+type WebhookSubscriptionEventData struct {
+	WhatsAppAccount string `json:"whatsapp_account"`
+	BusinessID      string `json:"business_id"`
+	Status          string `json:"status"` // repaired, failed
+	Problem         string `json:"problem,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// AccountStatusEventData represents data for display name, business verification
+// and official business account status changes
+type AccountStatusEventData struct {
+	WhatsAppAccount string                `json:"whatsapp_account"`
+	PhoneID         string                `json:"phone_id"`
+	BusinessID      string                `json:"business_id"`
+	Changes         []AccountStatusChange `json:"changes"`
+}
+
+// MissedReceiptsEventData represents data for days on which Meta delivered more
+// messages than delivery receipts reached the app
+type MissedReceiptsEventData struct {
+	WhatsAppAccount   string `json:"whatsapp_account"`
+	Date              string `json:"date"`
+	MetaSent          int64  `json:"meta_sent"`
+	MetaDelivered     int64  `json:"meta_delivered"`
+	LocalDelivered    int64  `json:"local_delivered"`
+	MissedReceipts    int64  `json:"missed_receipts"`
+	StuckMessages     int64  `json:"stuck_messages"`
+	InferredDelivered int64  `json:"inferred_delivered"`
+	MarkedStale       int64  `json:"marked_stale"`
+}
+
+// APIVersionEventData represents data for accounts whose Graph API version nears
+// or passed its sunset
+type APIVersionEventData struct {
+	WhatsAppAccount string `json:"whatsapp_account"`
+	APIVersion      string `json:"api_version"`
+	Status          string `json:"status"` // deprecating, sunset
+	Sunset          string `json:"sunset,omitempty"`
+	UsedVersion     string `json:"used_version"` // Version requests are sent with
+	LatestVersion   string `json:"latest_version"`
+}
+
+// BotMessageLimitEventData represents data for contacts whose automated messages were
+// stopped for reaching the limit
+type BotMessageLimitEventData struct {
+	ContactID       string `json:"contact_id"`
+	ContactPhone    string `json:"contact_phone"`
+	ContactName     string `json:"contact_name"`
+	WhatsAppAccount string `json:"whatsapp_account"`
+	Source          string `json:"source"` // chatbot, automation_trigger
+	Limit           int    `json:"limit"`
+	WindowMinutes   int    `json:"window_minutes"`
+	FlowID          string `json:"flow_id,omitempty"` // Flow the contact was taken out of
+	FlowName        string `json:"flow_name,omitempty"`
+	Step            string `json:"step,omitempty"`
+}
+
+// AccountStatusChange is one status that changed since the previous check
+type AccountStatusChange struct {
+	Field string `json:"field"` // verified_name, display_name_status, business_verification_status, official_business_status
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
 // DispatchWebhook sends an event to all matching webhooks for the organization
 func (a *App) DispatchWebhook(orgID uuid.UUID, eventType string, data interface{}) {
 	go a.dispatchWebhookAsync(orgID, eventType, data)
@@ -77,17 +208,43 @@ func (a *App) dispatchWebhookAsync(orgID uuid.UUID, eventType string, data inter
 		return
 	}
 
+	redacted := false
+	now := time.Now()
+	campaignID := eventCampaignID(data)
 	for _, webhook := range webhooks {
 		// Check if webhook subscribes to this event
 		if !containsEvent(webhook.Events, eventType) {
 			continue
 		}
 
+		// Campaign webhooks only get their campaign's events, until they expire
+		if webhook.CampaignID != nil && (webhook.CampaignID.String() != campaignID || webhook.Expired(now)) {
+			continue
+		}
+
+		// Message text goes to third parties with the organization's redaction rules applied
+		if msg, ok := data.(MessageEventData); ok && !redacted {
+			msg.Content = a.redactMessage(orgID, msg.Content)
+			data = msg
+			redacted = true
+		}
+
 		// Send webhook asynchronously
 		go a.sendWebhook(webhook, eventType, data)
 	}
 }
 
+// eventCampaignID returns the ID of the campaign an event is about, if any
+func eventCampaignID(data interface{}) string {
+	switch d := data.(type) {
+	case CampaignEventData:
+		return d.CampaignID
+	case RecipientEventData:
+		return d.CampaignID
+	}
+	return ""
+}
+
 func containsEvent(events models.StringArray, event string) bool {
 	for _, e := range events {
 		if e == event {
@@ -163,11 +320,8 @@ func (a *App) sendWebhookRequest(webhook models.Webhook, jsonData []byte) error
 		}
 	}
 
-	// Add HMAC signature if secret is configured
-	if webhook.Secret != "" {
-		signature := computeHMACSignature(jsonData, webhook.Secret)
-		req.Header.Set("X-Webhook-Signature", signature)
-	}
+	// Add timestamped HMAC signatures if a secret is configured (both secrets while rotating)
+	webhooksig.SignRequest(req.Header, webhook.SigningSecrets(time.Now()), jsonData, time.Now())
 
 	// Send request with timeout
 	client := &http.Client{Timeout: 10 * time.Second}
@@ -185,12 +339,6 @@ func (a *App) sendWebhookRequest(webhook models.Webhook, jsonData []byte) error
 	return nil
 }
 
-func computeHMACSignature(data []byte, secret string) string {
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write(data)
-	return "sha256=" + hex.EncodeToString(h.Sum(nil))
-}
-
 // WebhookError represents a webhook delivery error
 type WebhookError struct {
 	StatusCode int
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// webhookSignatureHeader carries Meta's HMAC-SHA256 of a webhook callback's body,
+// keyed with the secret of the app the callback was sent for
+const webhookSignatureHeader = "X-Hub-Signature-256"
+
+// verifyWebhookSignature checks a webhook callback was signed by the Meta app of every
+// account it's for. Deployments can serve accounts of several apps, so each change
+// resolves its account by phone number ID, or by WABA ID for changes that have none,
+// and is checked against that account's app secret, or whatsapp.app_secret when the
+// account sets none. Changes for accounts with no secret configured anywhere are
+// accepted unsigned.
+func (a *App) verifyWebhookSignature(payload *WebhookPayload, body []byte, signature string) bool {
+	checked := map[string]bool{}
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			key := "phone:" + change.Value.Metadata.PhoneNumberID
+			if change.Value.Metadata.PhoneNumberID == "" {
+				key = "waba:" + entry.ID
+			}
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+
+			secrets := a.webhookSecrets(entry.ID, change.Value.Metadata.PhoneNumberID)
+			if len(secrets) == 0 {
+				continue
+			}
+			valid := false
+			for _, secret := range secrets {
+				if validWebhookSignature(body, signature, secret) {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				a.Log.Warn("Webhook signature mismatch", "waba_id", entry.ID, "phone_number_id", change.Value.Metadata.PhoneNumberID)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// webhookSecrets returns the app secrets a callback for the phone number, or for the
+// WABA when phoneNumberID is empty, may be signed with. A phone number ID can be
+// registered by accounts in several organizations, each possibly on its own app.
+func (a *App) webhookSecrets(wabaID, phoneNumberID string) []string {
+	var accounts []models.WhatsAppAccount
+	query := a.DB.Select("app_secret")
+	if phoneNumberID != "" {
+		query = query.Where("phone_id = ?", phoneNumberID)
+	} else {
+		query = query.Where("business_id = ?", wabaID)
+	}
+	if err := query.Find(&accounts).Error; err != nil {
+		a.Log.Error("Failed to load webhook app secrets", "error", err, "waba_id", wabaID, "phone_number_id", phoneNumberID)
+	}
+
+	var secrets []string
+	seen := map[string]bool{}
+	add := func(secret string) {
+		if secret != "" && !seen[secret] {
+			seen[secret] = true
+			secrets = append(secrets, secret)
+		}
+	}
+	for _, account := range accounts {
+		if account.AppSecret != "" {
+			add(account.AppSecret)
+		} else {
+			add(a.Config.WhatsApp.AppSecret)
+		}
+	}
+	if len(accounts) == 0 {
+		add(a.Config.WhatsApp.AppSecret)
+	}
+	return secrets
+}
+
+// validWebhookSignature reports whether signature, as sent in webhookSignatureHeader,
+// is body's HMAC-SHA256 keyed with secret
+func validWebhookSignature(body []byte, signature, secret string) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/websocket"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// WebhookSubscriptionProcessor periodically checks that each account's WABA still
+// sends webhooks to this app, re-subscribing and alerting the organization when the
+// subscription was dropped or points elsewhere. Which webhook fields are delivered is
+// configured on the Meta app and can't be read with an account's token, so only the
+// subscription and its callback URL are checked.
+type WebhookSubscriptionProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewWebhookSubscriptionProcessor creates a new webhook subscription processor
+func NewWebhookSubscriptionProcessor(app *App, interval time.Duration) *WebhookSubscriptionProcessor {
+	return &WebhookSubscriptionProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the webhook subscription check loop
+func (p *WebhookSubscriptionProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Webhook subscription processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Webhook subscription processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Webhook subscription processor stopped")
+			return
+		case <-ticker.C:
+			p.checkAccounts(ctx)
+		}
+	}
+}
+
+// Stop stops the webhook subscription processor
+func (p *WebhookSubscriptionProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// checkAccounts checks every active account not checked within the interval
+func (p *WebhookSubscriptionProcessor) checkAccounts(ctx context.Context) {
+	due := time.Now().Add(-p.interval / 2)
+
+	var accounts []models.WhatsAppAccount
+	if err := p.app.DB.Where("status = ? AND (webhook_checked_at IS NULL OR webhook_checked_at < ?)", "active", due).
+		Find(&accounts).Error; err != nil {
+		p.app.Log.Error("Failed to load accounts for webhook check", "error", err)
+		return
+	}
+
+	for i := range accounts {
+		account := &accounts[i]
+
+		// Claim the check so other server instances skip this account
+		now := time.Now()
+		result := p.app.DB.Model(&models.WhatsAppAccount{}).
+			Where("id = ? AND (webhook_checked_at IS NULL OR webhook_checked_at < ?)", account.ID, due).
+			Update("webhook_checked_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		p.app.checkWebhookSubscription(ctx, account)
+	}
+}
+
+// CheckWebhookSubscription checks an account's webhook subscription now, repairing
+// it if needed, and returns the account with the result
+func (a *App) CheckWebhookSubscription(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	id, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid account ID", nil, "")
+	}
+
+	var account models.WhatsAppAccount
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&account).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Account not found", nil, "")
+	}
+
+	a.checkWebhookSubscription(r.RequestCtx, &account)
+	return r.SendEnvelope(accountToResponse(account))
+}
+
+// checkWebhookSubscription verifies that the account's app is subscribed to its WABA
+// with the right callback URL, re-subscribes if not, and records the outcome on the
+// account. The organization is alerted when a subscription is repaired or first fails.
+func (a *App) checkWebhookSubscription(ctx context.Context, account *models.WhatsAppAccount) {
+	previous := account.WebhookStatus
+	waAccount := &whatsapp.Account{
+		PhoneID:     account.PhoneID,
+		BusinessID:  account.BusinessID,
+		APIVersion:  account.APIVersion,
+		AccessToken: account.AccessToken,
+	}
+	callbackURL := ""
+	if base := a.publicBaseURL(account.OrganizationID); base != "" {
+		callbackURL = base + "/api/webhook"
+	}
+
+	status, problem, errMsg := models.WebhookSubscriptionOK, "", ""
+	apps, err := a.WhatsApp.GetSubscribedApps(ctx, waAccount)
+	if err != nil {
+		status, errMsg = models.WebhookSubscriptionFailed, "Failed to check subscription: "+err.Error()
+	} else if problem = webhookSubscriptionProblem(account, apps, callbackURL); problem != "" {
+		status = models.WebhookSubscriptionRepaired
+		if err := a.repairWebhookSubscription(ctx, account, waAccount, apps, callbackURL); err != nil {
+			status, errMsg = models.WebhookSubscriptionFailed, "Failed to re-subscribe: "+err.Error()
+		} else if apps, err = a.WhatsApp.GetSubscribedApps(ctx, waAccount); err != nil {
+			status, errMsg = models.WebhookSubscriptionFailed, "Failed to confirm subscription: "+err.Error()
+		} else if remaining := webhookSubscriptionProblem(account, apps, callbackURL); remaining != "" {
+			status, errMsg = models.WebhookSubscriptionFailed, "Still broken after re-subscribing: "+remaining
+		}
+	}
+
+	now := time.Now()
+	account.WebhookStatus = status
+	account.WebhookError = errMsg
+	if errMsg == "" {
+		account.WebhookError = problem // What was repaired, if anything
+	}
+	account.WebhookCheckedAt = &now
+	if err := a.DB.Model(&models.WhatsAppAccount{}).Where("id = ?", account.ID).Updates(map[string]interface{}{
+		"webhook_status":     account.WebhookStatus,
+		"webhook_error":      account.WebhookError,
+		"webhook_checked_at": now,
+	}).Error; err != nil {
+		a.Log.Error("Failed to save webhook subscription status", "error", err, "account", account.Name)
+	}
+
+	if status == models.WebhookSubscriptionOK || (status == models.WebhookSubscriptionFailed && previous == status) {
+		return
+	}
+
+	a.Log.Warn("Webhook subscription problem",
+		"organization_id", account.OrganizationID,
+		"account", account.Name,
+		"status", status,
+		"problem", problem,
+		"error", errMsg,
+	)
+	data := WebhookSubscriptionEventData{
+		WhatsAppAccount: account.Name,
+		BusinessID:      account.BusinessID,
+		Status:          status,
+		Problem:         problem,
+		Error:           errMsg,
+	}
+	if a.WSHub != nil {
+		a.WSHub.BroadcastToOrg(account.OrganizationID, websocket.WSMessage{
+			Type:    websocket.TypeWebhookSubscription,
+			Payload: a.withNotification(account.OrganizationID, notify.KeyWebhookSubscription, data),
+		})
+	}
+	a.DispatchWebhook(account.OrganizationID, EventAccountWebhook, data)
+}
+
+// repairWebhookSubscription re-subscribes the account's app, overriding the callback
+// URL when Meta was delivering to a different one
+func (a *App) repairWebhookSubscription(ctx context.Context, account *models.WhatsAppAccount, waAccount *whatsapp.Account, apps []whatsapp.SubscribedApp, callbackURL string) error {
+	app := findSubscribedApp(account, apps)
+	if app == nil || app.OverrideCallbackURI == "" || callbackURL == "" {
+		return a.WhatsApp.SubscribeApp(ctx, waAccount)
+	}
+
+	verifyToken := account.WebhookVerifyToken
+	if verifyToken == "" {
+		verifyToken = a.Config.WhatsApp.WebhookVerifyToken
+	}
+	if verifyToken == "" {
+		return fmt.Errorf("no webhook verify token configured to confirm %s", callbackURL)
+	}
+	return a.WhatsApp.SubscribeAppWithCallback(ctx, waAccount, callbackURL, verifyToken)
+}
+
+// webhookSubscriptionProblem describes what is wrong with the WABA's subscriptions,
+// or returns "" if the account's app is subscribed and delivers to callbackURL. An
+// empty callbackURL (no public URL configured) skips the callback check.
+func webhookSubscriptionProblem(account *models.WhatsAppAccount, apps []whatsapp.SubscribedApp, callbackURL string) string {
+	app := findSubscribedApp(account, apps)
+	if app == nil {
+		return "App is not subscribed to the WABA's webhooks"
+	}
+	if callbackURL != "" && app.OverrideCallbackURI != "" && app.OverrideCallbackURI != callbackURL {
+		return fmt.Sprintf("Webhooks are sent to %s instead of %s", app.OverrideCallbackURI, callbackURL)
+	}
+	return ""
+}
+
+// findSubscribedApp returns the account's app among the WABA's subscribed apps. When
+// the account has no app ID, any subscribed app is taken to be it.
+func findSubscribedApp(account *models.WhatsAppAccount, apps []whatsapp.SubscribedApp) *whatsapp.SubscribedApp {
+	for i := range apps {
+		if account.AppID == "" || apps[i].ID == account.AppID {
+			return &apps[i]
+		}
+	}
+	return nil
+}
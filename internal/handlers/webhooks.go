@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,6 +21,8 @@ type WebhookRequest struct {
 	Headers  map[string]string `json:"headers"`
 	Secret   string            `json:"secret"`
 	IsActive bool              `json:"is_active"`
+	// Subscribes the webhook to one campaign's events only; set on create
+	CampaignID *uuid.UUID `json:"campaign_id"`
 }
 
 // WebhookResponse represents the API response for a webhook
@@ -29,8 +34,18 @@ type WebhookResponse struct {
 	Headers   map[string]string `json:"headers"`
 	IsActive  bool              `json:"is_active"`
 	HasSecret bool              `json:"has_secret"`
-	CreatedAt string            `json:"created_at"`
-	UpdatedAt string            `json:"updated_at"`
+	// Set while a rotated-out secret is still used to sign deliveries
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+	CampaignID              *uuid.UUID `json:"campaign_id,omitempty"`
+	ExpiresAt               *time.Time `json:"expires_at,omitempty"` // When a campaign webhook is removed, once its campaign has finished
+	CreatedAt               string     `json:"created_at"`
+	UpdatedAt               string     `json:"updated_at"`
+}
+
+// RotateWebhookSecretRequest represents a request to rotate a webhook's signing secret
+type RotateWebhookSecretRequest struct {
+	Secret           string `json:"secret"`             // New secret; generated when empty
+	GracePeriodHours int    `json:"grace_period_hours"` // How long the old secret keeps signing (default 24, 0-168)
 }
 
 // AvailableWebhookEvents returns the list of available webhook event types
@@ -41,8 +56,29 @@ var AvailableWebhookEvents = []map[string]string{
 	{"value": EventTransferCreated, "label": "Transfer Created", "description": "When a transfer to human agent is requested"},
 	{"value": EventTransferAssigned, "label": "Transfer Assigned", "description": "When a transfer is assigned to an agent"},
 	{"value": EventTransferResumed, "label": "Transfer Resumed", "description": "When chatbot is resumed (transfer closed)"},
+	{"value": EventCampaignQueued, "label": "Campaign Queued", "description": "When a campaign is queued for sending"},
+	{"value": EventCampaignStarted, "label": "Campaign Started", "description": "When a worker starts sending a campaign"},
+	{"value": EventCampaignPaused, "label": "Campaign Paused", "description": "When a running campaign is paused"},
+	{"value": EventCampaignHeld, "label": "Campaign Held", "description": "When a running campaign is held for quiet hours or a blackout date, until it may send again"},
+	{"value": EventCampaignCompleted, "label": "Campaign Completed", "description": "When a campaign finishes, with final stats"},
+	{"value": EventCampaignFailed, "label": "Campaign Failed", "description": "When a campaign fails to process"},
+	{"value": EventCampaignRejected, "label": "Campaign Template Rejected", "description": "When Meta rejects the template a campaign is waiting on"},
+	{"value": EventAccountAnomaly, "label": "Account Anomaly Detected", "description": "When an account's failure, delivery or read rate deviates sharply from its baseline"},
+	{"value": EventAccountWebhook, "label": "Webhook Subscription Problem", "description": "When an account's webhook subscription was found dropped or misdirected, and whether it was restored"},
+	{"value": EventAccountStatus, "label": "Account Status Changed", "description": "When a number's display name, business verification or official business account status changes"},
+	{"value": EventAccountReceipts, "label": "Delivery Receipts Missed", "description": "When Meta's analytics show deliveries on an account whose receipts never arrived as webhooks"},
+	{"value": EventAccountAPIVersion, "label": "API Version Sunset", "description": "When an account's Graph API version is within 90 days of its sunset, and again once it has passed"},
+	{"value": EventChatbotLimit, "label": "Automated Message Limit Reached", "description": "When the chatbot or an automation trigger is stopped for sending a contact too many messages"},
+	{"value": EventRecipientSent, "label": "Recipient Sent", "description": "When a campaign's message is sent to a recipient", "scope": "campaign"},
+	{"value": EventRecipientDelivered, "label": "Recipient Delivered", "description": "When a campaign's message is delivered to a recipient", "scope": "campaign"},
+	{"value": EventRecipientRead, "label": "Recipient Read", "description": "When a recipient reads a campaign's message", "scope": "campaign"},
+	{"value": EventRecipientFailed, "label": "Recipient Failed", "description": "When a campaign's message to a recipient fails", "scope": "campaign"},
 }
 
+// campaignWebhookRetention is how long a campaign webhook outlives its campaign, for the
+// delivery and read receipts of its last messages
+const campaignWebhookRetention = 24 * time.Hour
+
 // ListWebhooks returns all webhooks for the organization
 func (a *App) ListWebhooks(r *fastglue.Request) error {
 	orgID, err := getOrganizationID(r)
@@ -50,8 +86,16 @@ func (a *App) ListWebhooks(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
 	}
 
+	// Campaign webhooks are unsubscribed once they expire
+	a.DB.Where("organization_id = ? AND expires_at <= ?", orgID, time.Now()).Delete(&models.Webhook{})
+
+	query := a.DB.Where("organization_id = ?", orgID)
+	if campaignID := string(r.RequestCtx.QueryArgs().Peek("campaign_id")); campaignID != "" {
+		query = query.Where("campaign_id = ?", campaignID)
+	}
+
 	var webhooks []models.Webhook
-	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&webhooks).Error; err != nil {
+	if err := query.Order("created_at DESC").Find(&webhooks).Error; err != nil {
 		a.Log.Error("Failed to list webhooks", "error", err)
 		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list webhooks", nil, "")
 	}
@@ -107,6 +151,18 @@ func (a *App) CreateWebhook(r *fastglue.Request) error {
 		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "at least one event must be selected", nil, "")
 	}
 
+	if req.CampaignID != nil {
+		var campaign models.BulkMessageCampaign
+		if err := a.DB.Select("id", "status").Where("id = ? AND organization_id = ?", *req.CampaignID, orgID).First(&campaign).Error; err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Campaign not found", nil, "")
+		}
+		if campaign.Status == "completed" || campaign.Status == "cancelled" || campaign.Status == "failed" {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Campaign has already finished", nil, "")
+		}
+	} else if hasRecipientEvent(req.Events) {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Recipient events are only available to campaign webhooks", nil, "")
+	}
+
 	// Convert headers to JSONB
 	headers := models.JSONB{}
 	for k, v := range req.Headers {
@@ -121,6 +177,7 @@ func (a *App) CreateWebhook(r *fastglue.Request) error {
 		Headers:        headers,
 		Secret:         req.Secret,
 		IsActive:       true,
+		CampaignID:     req.CampaignID,
 	}
 
 	if err := a.DB.Create(&webhook).Error; err != nil {
@@ -163,6 +220,9 @@ func (a *App) UpdateWebhook(r *fastglue.Request) error {
 		webhook.URL = req.URL
 	}
 	if len(req.Events) > 0 {
+		if webhook.CampaignID == nil && hasRecipientEvent(req.Events) {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Recipient events are only available to campaign webhooks", nil, "")
+		}
 		webhook.Events = req.Events
 	}
 
@@ -262,6 +322,69 @@ func (a *App) TestWebhook(r *fastglue.Request) error {
 	return r.SendEnvelope(map[string]string{"message": "Test webhook sent successfully"})
 }
 
+// RotateWebhookSecret replaces a webhook's signing secret while keeping the old one
+// valid for a grace period; deliveries carry signatures for both until it expires
+func (a *App) RotateWebhookSecret(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	webhookID, err := uuid.Parse(r.RequestCtx.UserValue("id").(string))
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid webhook ID", nil, "")
+	}
+
+	var webhook models.Webhook
+	if err := a.DB.Where("id = ? AND organization_id = ?", webhookID, orgID).First(&webhook).Error; err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Webhook not found", nil, "")
+	}
+
+	req := RotateWebhookSecretRequest{GracePeriodHours: 24}
+	if len(r.RequestCtx.PostBody()) > 0 {
+		if err := r.Decode(&req, "json"); err != nil {
+			return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+		}
+	}
+	if req.GracePeriodHours < 0 || req.GracePeriodHours > 168 {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "grace_period_hours must be between 0 and 168", nil, "")
+	}
+
+	newSecret := req.Secret
+	if newSecret == "" {
+		bytes := make([]byte, 32)
+		if _, err := rand.Read(bytes); err != nil {
+			a.Log.Error("Failed to generate webhook secret", "error", err)
+			return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to generate secret", nil, "")
+		}
+		newSecret = "whsec_" + hex.EncodeToString(bytes)
+	}
+
+	// Keep the outgoing secret signing during the grace period
+	if webhook.Secret != "" && req.GracePeriodHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.GracePeriodHours) * time.Hour)
+		webhook.PreviousSecret = webhook.Secret
+		webhook.PreviousSecretExpiresAt = &expiresAt
+	} else {
+		webhook.PreviousSecret = ""
+		webhook.PreviousSecretExpiresAt = nil
+	}
+	webhook.Secret = newSecret
+
+	if err := a.DB.Save(&webhook).Error; err != nil {
+		a.Log.Error("Failed to rotate webhook secret", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to rotate webhook secret", nil, "")
+	}
+
+	// Invalidate cache
+	a.InvalidateWebhooksCache(orgID)
+
+	return r.SendEnvelope(map[string]interface{}{
+		"webhook": webhookToResponse(webhook),
+		"secret":  newSecret, // Only returned once
+	})
+}
+
 func webhookToResponse(wh models.Webhook) WebhookResponse {
 	// Convert events
 	events := make([]string, len(wh.Events))
@@ -275,7 +398,7 @@ func webhookToResponse(wh models.Webhook) WebhookResponse {
 		}
 	}
 
-	return WebhookResponse{
+	resp := WebhookResponse{
 		ID:        wh.ID,
 		Name:      wh.Name,
 		URL:       wh.URL,
@@ -286,4 +409,35 @@ func webhookToResponse(wh models.Webhook) WebhookResponse {
 		CreatedAt: wh.CreatedAt.Format(time.RFC3339),
 		UpdatedAt: wh.UpdatedAt.Format(time.RFC3339),
 	}
+	if wh.PreviousSecret != "" && wh.PreviousSecretExpiresAt != nil && time.Now().Before(*wh.PreviousSecretExpiresAt) {
+		resp.PreviousSecretExpiresAt = wh.PreviousSecretExpiresAt
+	}
+	resp.CampaignID = wh.CampaignID
+	resp.ExpiresAt = wh.ExpiresAt
+	return resp
+}
+
+// hasRecipientEvent reports whether events include a campaign recipient event
+func hasRecipientEvent(events []string) bool {
+	for _, event := range events {
+		if slices.Contains(recipientEvents, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// expireCampaignWebhooks schedules the campaign's webhooks to be unsubscribed once
+// campaignWebhookRetention has passed, now that the campaign has finished
+func (a *App) expireCampaignWebhooks(orgID, campaignID uuid.UUID) {
+	result := a.DB.Model(&models.Webhook{}).
+		Where("organization_id = ? AND campaign_id = ? AND expires_at IS NULL", orgID, campaignID).
+		Update("expires_at", time.Now().Add(campaignWebhookRetention))
+	if result.Error != nil {
+		a.Log.Error("Failed to expire campaign webhooks", "error", result.Error, "campaign_id", campaignID)
+		return
+	}
+	if result.RowsAffected > 0 {
+		a.InvalidateWebhooksCache(orgID)
+	}
 }
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"github.com/zerodha/fastglue"
+)
+
+// WinBackAutomationRequest is the request body for creating or updating a win-back automation
+type WinBackAutomationRequest struct {
+	Name            string               `json:"name"`
+	WhatsAppAccount string               `json:"whatsapp_account"`
+	IsEnabled       bool                 `json:"is_enabled"`
+	InactiveDays    int                  `json:"inactive_days"`
+	MinPastReplies  int                  `json:"min_past_replies"`
+	Steps           []models.WinBackStep `json:"steps"`
+	DailyCap        *int                 `json:"daily_cap"`
+	CooldownDays    *int                 `json:"cooldown_days"`
+	ExitLabelID     *string              `json:"exit_label_id"`
+}
+
+// ListWinBackAutomations returns all win-back automations for the organization
+func (a *App) ListWinBackAutomations(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var automations []models.WinBackAutomation
+	if err := a.DB.Where("organization_id = ?", orgID).Order("created_at DESC").Find(&automations).Error; err != nil {
+		a.Log.Error("Failed to list win-back automations", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list win-back automations", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"automations": automations,
+	})
+}
+
+// CreateWinBackAutomation creates a win-back automation
+func (a *App) CreateWinBackAutomation(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	var req WinBackAutomationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+
+	automation := models.WinBackAutomation{
+		OrganizationID: orgID,
+		DailyCap:       100,
+		CooldownDays:   180,
+	}
+	if err := a.applyWinBackRequest(orgID, &automation, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Create(&automation).Error; err != nil {
+		a.Log.Error("Failed to create win-back automation", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to create win-back automation", nil, "")
+	}
+
+	return r.SendEnvelope(automation)
+}
+
+// GetWinBackAutomation returns an automation with enrollment counts by status
+func (a *App) GetWinBackAutomation(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	automation, err := a.findWinBackAutomation(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Win-back automation not found", nil, "")
+	}
+
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	a.DB.Model(&models.WinBackEnrollment{}).
+		Select("status, COUNT(*) AS count").
+		Where("automation_id = ?", automation.ID).
+		Group("status").
+		Scan(&counts)
+
+	stats := map[string]int64{
+		models.WinBackStatusActive:    0,
+		models.WinBackStatusReengaged: 0,
+		models.WinBackStatusCompleted: 0,
+		models.WinBackStatusExited:    0,
+		models.WinBackStatusFailed:    0,
+	}
+	for _, c := range counts {
+		stats[c.Status] = c.Count
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"automation": automation,
+		"stats":      stats,
+	})
+}
+
+// UpdateWinBackAutomation replaces an automation's configuration. Active enrollments
+// continue with the new steps from where they are.
+func (a *App) UpdateWinBackAutomation(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	automation, err := a.findWinBackAutomation(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Win-back automation not found", nil, "")
+	}
+
+	var req WinBackAutomationRequest
+	if err := r.Decode(&req, "json"); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, "Invalid request body", nil, "")
+	}
+	if err := a.applyWinBackRequest(orgID, automation, &req); err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusBadRequest, err.Error(), nil, "")
+	}
+
+	if err := a.DB.Save(automation).Error; err != nil {
+		a.Log.Error("Failed to update win-back automation", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to update win-back automation", nil, "")
+	}
+
+	return r.SendEnvelope(automation)
+}
+
+// DeleteWinBackAutomation deletes an automation and exits its active enrollments
+func (a *App) DeleteWinBackAutomation(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	automation, err := a.findWinBackAutomation(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Win-back automation not found", nil, "")
+	}
+
+	a.DB.Model(&models.WinBackEnrollment{}).
+		Where("automation_id = ? AND status = ?", automation.ID, models.WinBackStatusActive).
+		Updates(map[string]interface{}{
+			"status":       models.WinBackStatusExited,
+			"exit_reason":  "automation deleted",
+			"ended_at":     time.Now(),
+			"next_send_at": nil,
+		})
+
+	if err := a.DB.Delete(automation).Error; err != nil {
+		a.Log.Error("Failed to delete win-back automation", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to delete win-back automation", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]string{"message": "Win-back automation deleted successfully"})
+}
+
+// ListWinBackEnrollments returns an automation's enrollments, newest first.
+// Query params: status, page, limit (default 50)
+func (a *App) ListWinBackEnrollments(r *fastglue.Request) error {
+	orgID, err := getOrganizationID(r)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Unauthorized", nil, "")
+	}
+
+	automation, err := a.findWinBackAutomation(r, orgID)
+	if err != nil {
+		return r.SendErrorEnvelope(fasthttp.StatusNotFound, "Win-back automation not found", nil, "")
+	}
+
+	page := r.RequestCtx.QueryArgs().GetUintOrZero("page")
+	limit := r.RequestCtx.QueryArgs().GetUintOrZero("limit")
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	query := a.DB.Where("automation_id = ?", automation.ID)
+	if status := string(r.RequestCtx.QueryArgs().Peek("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Model(&models.WinBackEnrollment{}).Count(&total)
+
+	var enrollments []models.WinBackEnrollment
+	if err := query.Preload("Contact").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&enrollments).Error; err != nil {
+		a.Log.Error("Failed to list win-back enrollments", "error", err)
+		return r.SendErrorEnvelope(fasthttp.StatusInternalServerError, "Failed to list enrollments", nil, "")
+	}
+
+	return r.SendEnvelope(map[string]interface{}{
+		"enrollments": enrollments,
+		"total":       total,
+		"page":        page,
+		"limit":       limit,
+	})
+}
+
+// applyWinBackRequest validates req and copies it onto automation
+func (a *App) applyWinBackRequest(orgID uuid.UUID, automation *models.WinBackAutomation, req *WinBackAutomationRequest) error {
+	if req.Name == "" || req.WhatsAppAccount == "" {
+		return fmt.Errorf("name and whatsapp_account are required")
+	}
+	var count int64
+	a.DB.Model(&models.WhatsAppAccount{}).Where("organization_id = ? AND name = ?", orgID, req.WhatsAppAccount).Count(&count)
+	if count == 0 {
+		return fmt.Errorf("WhatsApp account not found")
+	}
+
+	if req.InactiveDays <= 0 {
+		req.InactiveDays = 30
+	}
+	if req.MinPastReplies <= 0 {
+		req.MinPastReplies = 1
+	}
+
+	if len(req.Steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+	steps := make(models.JSONBArray, len(req.Steps))
+	for i, step := range req.Steps {
+		if step.DelayDays < 0 {
+			return fmt.Errorf("step %d: delay_days cannot be negative", i+1)
+		}
+		var template models.Template
+		err := a.DB.Where("id = ? AND organization_id = ? AND whats_app_account = ?", step.TemplateID, orgID, req.WhatsAppAccount).First(&template).Error
+		if err != nil {
+			return fmt.Errorf("step %d: template not found for this account", i+1)
+		}
+		params := make(map[string]interface{}, len(step.TemplateParams))
+		for k, v := range step.TemplateParams {
+			params[k] = v
+		}
+		steps[i] = map[string]interface{}{
+			"template_id":     step.TemplateID.String(),
+			"delay_days":      step.DelayDays,
+			"template_params": params,
+		}
+	}
+
+	automation.ExitLabelID = nil
+	if req.ExitLabelID != nil && *req.ExitLabelID != "" {
+		labelID, err := uuid.Parse(*req.ExitLabelID)
+		if err != nil {
+			return fmt.Errorf("invalid exit_label_id")
+		}
+		a.DB.Model(&models.Label{}).Where("id = ? AND organization_id = ?", labelID, orgID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("exit label not found")
+		}
+		automation.ExitLabelID = &labelID
+	}
+
+	automation.Name = req.Name
+	automation.WhatsAppAccount = req.WhatsAppAccount
+	automation.IsEnabled = req.IsEnabled
+	automation.InactiveDays = req.InactiveDays
+	automation.MinPastReplies = req.MinPastReplies
+	automation.Steps = steps
+	if req.DailyCap != nil && *req.DailyCap >= 0 {
+		automation.DailyCap = *req.DailyCap
+	}
+	if req.CooldownDays != nil && *req.CooldownDays >= 0 {
+		automation.CooldownDays = *req.CooldownDays
+	}
+	return nil
+}
+
+func (a *App) findWinBackAutomation(r *fastglue.Request, orgID uuid.UUID) (*models.WinBackAutomation, error) {
+	idStr, _ := r.RequestCtx.UserValue("id").(string)
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var automation models.WinBackAutomation
+	if err := a.DB.Where("id = ? AND organization_id = ?", id, orgID).First(&automation).Error; err != nil {
+		return nil, err
+	}
+	return &automation, nil
+}
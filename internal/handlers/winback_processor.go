@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+const (
+	// winBackLockKey keeps server instances from running the same cycle twice
+	winBackLockKey = "winback:processor_lock"
+
+	// winBackEnrollBatch caps new enrollments per automation per cycle
+	winBackEnrollBatch = 500
+
+	// winBackSendBatch caps messages sent per automation per cycle
+	winBackSendBatch = 100
+)
+
+// WinBackProcessor enrolls dormant contacts into win-back sequences, sends due
+// steps and ends enrollments whose exit conditions are met
+type WinBackProcessor struct {
+	app      *App
+	interval time.Duration
+	stopCh   chan struct{}
+}
+
+// NewWinBackProcessor creates a new win-back processor
+func NewWinBackProcessor(app *App, interval time.Duration) *WinBackProcessor {
+	return &WinBackProcessor{
+		app:      app,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start begins the win-back processing loop
+func (p *WinBackProcessor) Start(ctx context.Context) {
+	p.app.Log.Info("Win-back processor started", "interval", p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.app.Log.Info("Win-back processor stopped by context")
+			return
+		case <-p.stopCh:
+			p.app.Log.Info("Win-back processor stopped")
+			return
+		case <-ticker.C:
+			p.processAutomations(ctx)
+		}
+	}
+}
+
+// Stop stops the win-back processor
+func (p *WinBackProcessor) Stop() {
+	close(p.stopCh)
+}
+
+// processAutomations runs one cycle of every enabled automation
+func (p *WinBackProcessor) processAutomations(ctx context.Context) {
+	acquired, err := p.app.Redis.SetNX(ctx, winBackLockKey, 1, p.interval/2).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	var automations []models.WinBackAutomation
+	if err := p.app.DB.Where("is_enabled = true").Find(&automations).Error; err != nil {
+		p.app.Log.Error("Failed to load win-back automations", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range automations {
+		automation := &automations[i]
+		steps, err := automation.StepList()
+		if err != nil || len(steps) == 0 {
+			p.app.Log.Warn("Skipping win-back automation without valid steps", "automation_id", automation.ID)
+			continue
+		}
+
+		p.exitEnrollments(automation, now)
+		p.enrollContacts(automation, steps, now)
+		p.sendDueSteps(automation, steps, now)
+	}
+}
+
+// exitEnrollments ends active enrollments whose contact opted out, replied, or
+// received the automation's exit label
+func (p *WinBackProcessor) exitEnrollments(automation *models.WinBackAutomation, now time.Time) {
+	end := func(status, reason, condition string, args ...interface{}) {
+		result := p.app.DB.Model(&models.WinBackEnrollment{}).
+			Where("automation_id = ? AND status = ?", automation.ID, models.WinBackStatusActive).
+			Where(condition, args...).
+			Updates(map[string]interface{}{
+				"status":       status,
+				"exit_reason":  reason,
+				"ended_at":     now,
+				"next_send_at": nil,
+			})
+		if result.Error != nil {
+			p.app.Log.Error("Failed to end win-back enrollments", "error", result.Error, "automation_id", automation.ID, "reason", reason)
+		} else if result.RowsAffected > 0 {
+			p.app.Log.Info("Ended win-back enrollments", "automation_id", automation.ID, "reason", reason, "count", result.RowsAffected)
+		}
+	}
+
+	// Opt-outs are checked before replies since an opt-out is also a reply
+	end(models.WinBackStatusExited, "opted out",
+		`EXISTS (SELECT 1 FROM messages m WHERE m.contact_id = win_back_enrollments.contact_id
+			AND m.direction = 'incoming' AND m.deleted_at IS NULL
+			AND m.created_at > win_back_enrollments.created_at AND UPPER(TRIM(m.content)) IN ?)`,
+		engagement.OptOutKeywords)
+
+	end(models.WinBackStatusReengaged, "replied",
+		`EXISTS (SELECT 1 FROM messages m WHERE m.contact_id = win_back_enrollments.contact_id
+			AND m.direction = 'incoming' AND m.deleted_at IS NULL
+			AND m.created_at > win_back_enrollments.created_at)`)
+
+	if automation.ExitLabelID != nil {
+		end(models.WinBackStatusExited, "labelled",
+			`EXISTS (SELECT 1 FROM conversation_labels cl WHERE cl.contact_id = win_back_enrollments.contact_id
+				AND cl.label_id = ? AND cl.deleted_at IS NULL)`,
+			*automation.ExitLabelID)
+	}
+}
+
+// enrollContacts enrolls previously engaged contacts who haven't replied for the
+// automation's inactivity period, up to its daily cap
+func (p *WinBackProcessor) enrollContacts(automation *models.WinBackAutomation, steps []models.WinBackStep, now time.Time) {
+	limit := winBackEnrollBatch
+	if automation.DailyCap > 0 {
+		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		var today int64
+		p.app.DB.Model(&models.WinBackEnrollment{}).
+			Where("automation_id = ? AND created_at >= ?", automation.ID, startOfDay).
+			Count(&today)
+		limit = min(limit, automation.DailyCap-int(today))
+		if limit <= 0 {
+			return
+		}
+	}
+
+	// A zero cooldown means a contact is only ever enrolled once
+	cooldownSince := time.Time{}
+	if automation.CooldownDays > 0 {
+		cooldownSince = now.AddDate(0, 0, -automation.CooldownDays)
+	}
+
+	var contactIDs []uuid.UUID
+	err := p.app.DB.Raw(`
+		SELECT c.id FROM contacts c
+		JOIN LATERAL (
+			SELECT COUNT(*) AS replies, MAX(m.created_at) AS last_reply
+			FROM messages m
+			WHERE m.contact_id = c.id AND m.direction = 'incoming' AND m.deleted_at IS NULL
+		) r ON true
+		WHERE c.organization_id = ? AND c.whats_app_account = ? AND c.deleted_at IS NULL
+			AND r.replies >= ? AND r.last_reply < ?
+			AND NOT EXISTS (
+				SELECT 1 FROM win_back_enrollments e
+				WHERE e.contact_id = c.id AND e.deleted_at IS NULL
+					AND (e.status = ? OR (e.automation_id = ? AND e.created_at >= ?))
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM messages m
+				WHERE m.contact_id = c.id AND m.direction = 'incoming' AND m.deleted_at IS NULL
+					AND UPPER(TRIM(m.content)) IN ?
+			)
+		ORDER BY r.last_reply DESC
+		LIMIT ?`,
+		automation.OrganizationID, automation.WhatsAppAccount,
+		max(automation.MinPastReplies, 1), now.AddDate(0, 0, -automation.InactiveDays),
+		models.WinBackStatusActive, automation.ID, cooldownSince,
+		engagement.OptOutKeywords, limit).
+		Scan(&contactIDs).Error
+	if err != nil {
+		p.app.Log.Error("Failed to find dormant contacts", "error", err, "automation_id", automation.ID)
+		return
+	}
+	if len(contactIDs) == 0 {
+		return
+	}
+
+	firstSend := now.AddDate(0, 0, steps[0].DelayDays)
+	enrollments := make([]models.WinBackEnrollment, len(contactIDs))
+	for i, contactID := range contactIDs {
+		enrollments[i] = models.WinBackEnrollment{
+			OrganizationID: automation.OrganizationID,
+			AutomationID:   automation.ID,
+			ContactID:      contactID,
+			Status:         models.WinBackStatusActive,
+			NextSendAt:     &firstSend,
+		}
+	}
+	if err := p.app.DB.Create(&enrollments).Error; err != nil {
+		p.app.Log.Error("Failed to enroll contacts", "error", err, "automation_id", automation.ID)
+		return
+	}
+
+	p.app.Log.Info("Enrolled dormant contacts", "automation_id", automation.ID, "count", len(enrollments))
+}
+
+// sendDueSteps sends the next template to enrollments that are due
+func (p *WinBackProcessor) sendDueSteps(automation *models.WinBackAutomation, steps []models.WinBackStep, now time.Time) {
+	var due []models.WinBackEnrollment
+	err := p.app.DB.Where("automation_id = ? AND status = ? AND next_send_at <= ?", automation.ID, models.WinBackStatusActive, now).
+		Preload("Contact").
+		Order("next_send_at ASC").
+		Limit(winBackSendBatch).
+		Find(&due).Error
+	if err != nil || len(due) == 0 {
+		return
+	}
+
+	var account models.WhatsAppAccount
+	if err := p.app.DB.Where("organization_id = ? AND name = ?", automation.OrganizationID, automation.WhatsAppAccount).First(&account).Error; err != nil {
+		p.app.Log.Error("Win-back account not found", "error", err, "automation_id", automation.ID, "account", automation.WhatsAppAccount)
+		return
+	}
+
+	templates := make(map[uuid.UUID]*models.Template)
+	for i := range due {
+		enrollment := &due[i]
+		if enrollment.CurrentStep >= len(steps) || enrollment.Contact == nil {
+			p.endEnrollment(enrollment, models.WinBackStatusCompleted, "", now)
+			continue
+		}
+		step := steps[enrollment.CurrentStep]
+
+		template, ok := templates[step.TemplateID]
+		if !ok {
+			var t models.Template
+			if err := p.app.DB.Where("id = ? AND organization_id = ?", step.TemplateID, automation.OrganizationID).First(&t).Error; err == nil {
+				template = &t
+			}
+			templates[step.TemplateID] = template
+		}
+		if template == nil || template.Status != "APPROVED" {
+			p.endEnrollment(enrollment, models.WinBackStatusFailed, "template not found or not approved", now)
+			continue
+		}
+
+		if err := p.sendStep(automation, &account, template, step, enrollment); err != nil {
+			p.app.Log.Error("Failed to send win-back message", "error", err, "enrollment_id", enrollment.ID)
+			p.endEnrollment(enrollment, models.WinBackStatusFailed, err.Error(), now)
+			continue
+		}
+
+		next := enrollment.CurrentStep + 1
+		if next >= len(steps) {
+			p.app.DB.Model(enrollment).Updates(map[string]interface{}{
+				"current_step": next,
+				"last_sent_at": now,
+				"status":       models.WinBackStatusCompleted,
+				"ended_at":     now,
+				"next_send_at": nil,
+			})
+			continue
+		}
+		p.app.DB.Model(enrollment).Updates(map[string]interface{}{
+			"current_step": next,
+			"last_sent_at": now,
+			"next_send_at": now.AddDate(0, 0, steps[next].DelayDays),
+		})
+	}
+}
+
+// sendStep sends one step's template to the enrolled contact and records the message
+func (p *WinBackProcessor) sendStep(automation *models.WinBackAutomation, account *models.WhatsAppAccount, template *models.Template, step models.WinBackStep, enrollment *models.WinBackEnrollment) error {
+	contact := enrollment.Contact
+
+	data := map[string]interface{}{
+		"name":         contact.ProfileName,
+		"profile_name": contact.ProfileName,
+		"phone_number": contact.PhoneNumber,
+	}
+	for k, v := range contact.Metadata {
+		if _, exists := data[k]; !exists {
+			data[k] = v
+		}
+	}
+
+	params := models.JSONB{}
+	for key, value := range step.TemplateParams {
+		params[key] = processTemplate(value, data)
+	}
+	params = p.app.fillContactTemplateParams(nil, template, contact, params)
+	content := renderTemplateText(template.BodyContent, params)
+
+	waMessageID, err := p.app.sendTemplateMessage(account, template, &models.BulkMessageRecipient{
+		PhoneNumber:    contact.PhoneNumber,
+		TemplateParams: params,
+	})
+	if err != nil {
+		return err
+	}
+
+	message := models.Message{
+		OrganizationID:    automation.OrganizationID,
+		WhatsAppAccount:   account.Name,
+		ContactID:         contact.ID,
+		WhatsAppMessageID: waMessageID,
+		Direction:         "outgoing",
+		MessageType:       "template",
+		Content:           content,
+		TemplateName:      template.Name,
+		TemplateParams:    params,
+		Status:            "sent",
+		Metadata: models.JSONB{
+			"win_back_automation_id": automation.ID.String(),
+			"win_back_enrollment_id": enrollment.ID.String(),
+			"win_back_step":          enrollment.CurrentStep,
+		},
+	}
+	if err := p.app.DB.Create(&message).Error; err != nil {
+		p.app.Log.Error("Failed to save win-back message", "error", err, "enrollment_id", enrollment.ID)
+	}
+	return nil
+}
+
+// endEnrollment moves an enrollment to a final status
+func (p *WinBackProcessor) endEnrollment(enrollment *models.WinBackEnrollment, status, reason string, now time.Time) {
+	p.app.DB.Model(enrollment).Updates(map[string]interface{}{
+		"status":       status,
+		"exit_reason":  reason,
+		"ended_at":     now,
+		"next_send_at": nil,
+	})
+}
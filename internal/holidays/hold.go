@@ -0,0 +1,60 @@
+package holidays
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxHoldSteps bounds how many quiet hours and blackout dates in a row CheckHold
+// looks past; a hold longer than that is checked again when it ends
+const maxHoldSteps = 62
+
+// Hold describes why an organization's campaigns are held and when they may send again
+type Hold struct {
+	Reason string
+	Until  time.Time
+}
+
+// CheckHold returns the hold on the organization's campaigns at now from its quiet
+// hours and blackout dates, or nil if they may send. settings are the organization's;
+// its timezone reads both.
+func CheckHold(db *gorm.DB, orgID uuid.UUID, settings models.JSONB, now time.Time) (*Hold, error) {
+	loc := time.UTC
+	if timezone, _ := settings["timezone"].(string); timezone != "" {
+		if l, err := time.LoadLocation(timezone); err == nil {
+			loc = l
+		}
+	}
+
+	var hold *Hold
+	t := now.In(loc)
+	for i := 0; i < maxHoldSteps; i++ {
+		if ends := models.QuietHoursEndAt(settings, t); ends.After(t) {
+			if hold == nil {
+				hold = &Hold{Reason: "Quiet hours"}
+			}
+			t = ends
+			continue
+		}
+
+		blackout, err := Check(db, orgID, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			return nil, err
+		}
+		if blackout == nil {
+			break
+		}
+		if hold == nil {
+			hold = &Hold{Reason: "Blackout date: " + blackout.Name}
+		}
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+	}
+
+	if hold != nil {
+		hold.Until = t
+	}
+	return hold, nil
+}
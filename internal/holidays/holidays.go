@@ -0,0 +1,195 @@
+// Package holidays imports public holiday calendars and answers whether a
+// campaign may send on a given day.
+package holidays
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/ical"
+	"gorm.io/gorm"
+)
+
+const (
+	// RefreshInterval is how often active calendars are re-imported
+	RefreshInterval = 24 * time.Hour
+
+	// Only events within this window around today are imported
+	pastWindow   = 30 * 24 * time.Hour
+	futureWindow = 2 * 365 * 24 * time.Hour
+
+	// Upper bounds to protect against oversized or malformed feeds
+	maxCalendarSize = 5 << 20
+	maxEventDays    = 31
+	fetchTimeout    = 30 * time.Second
+)
+
+// Blackout describes why a day is blocked for campaigns
+type Blackout struct {
+	Date time.Time
+	Name string
+}
+
+// FeedURL normalizes a calendar URL, accepting webcal:// links
+func FeedURL(raw string) (string, error) {
+	u := strings.TrimSpace(raw)
+	if rest, ok := strings.CutPrefix(u, "webcal://"); ok {
+		u = "https://" + rest
+	}
+	if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		return "", fmt.Errorf("calendar URL must use http, https or webcal")
+	}
+	return u, nil
+}
+
+// Fetch downloads and parses an iCal feed
+func Fetch(ctx context.Context, rawURL string) ([]ical.Event, error) {
+	feedURL, err := FeedURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/calendar")
+	req.Header.Set("User-Agent", "Whatomate-Calendar/1.0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar server returned %d", resp.StatusCode)
+	}
+
+	events, err := ical.Parse(io.LimitReader(resp.Body, maxCalendarSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse calendar: %w", err)
+	}
+	return events, nil
+}
+
+// Sync re-imports a calendar, replacing its blackout dates, and records the outcome on the calendar
+func Sync(ctx context.Context, db *gorm.DB, calendar *models.HolidayCalendar) (int, error) {
+	now := time.Now()
+
+	events, err := Fetch(ctx, calendar.URL)
+	if err != nil {
+		db.Model(calendar).Updates(map[string]interface{}{
+			"last_synced_at":  now,
+			"last_sync_error": err.Error(),
+		})
+		return 0, err
+	}
+
+	from := now.Add(-pastWindow)
+	to := now.Add(futureWindow)
+
+	// One row per day; overlapping events on the same day are merged by name
+	byDay := make(map[string]*models.CampaignBlackoutDate)
+	var days []string
+	for _, event := range events {
+		eventDays := event.Days()
+		if len(eventDays) > maxEventDays {
+			eventDays = eventDays[:maxEventDays]
+		}
+		for _, day := range eventDays {
+			if day.Before(from) || day.After(to) {
+				continue
+			}
+			key := day.Format("2006-01-02")
+			if existing, ok := byDay[key]; ok {
+				if event.Summary != "" && !strings.Contains(existing.Name, event.Summary) {
+					existing.Name += ", " + event.Summary
+				}
+				continue
+			}
+			byDay[key] = &models.CampaignBlackoutDate{
+				OrganizationID: calendar.OrganizationID,
+				CalendarID:     &calendar.ID,
+				Date:           day,
+				Name:           event.Summary,
+				Region:         calendar.Region,
+			}
+			days = append(days, key)
+		}
+	}
+
+	dates := make([]models.CampaignBlackoutDate, 0, len(days))
+	for _, key := range days {
+		date := byDay[key]
+		if name := []rune(date.Name); len(name) > 255 {
+			date.Name = string(name[:255])
+		}
+		dates = append(dates, *date)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("calendar_id = ?", calendar.ID).Delete(&models.CampaignBlackoutDate{}).Error; err != nil {
+			return err
+		}
+		if len(dates) > 0 {
+			if err := tx.CreateInBatches(&dates, 500).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(calendar).Updates(map[string]interface{}{
+			"last_synced_at":  now,
+			"last_sync_error": "",
+			"event_count":     len(dates),
+		}).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to save blackout dates: %w", err)
+	}
+
+	return len(dates), nil
+}
+
+// Today returns the current calendar day in the given IANA timezone (UTC if empty or unknown)
+func Today(timezone string) time.Time {
+	loc := time.UTC
+	if timezone != "" {
+		if l, err := time.LoadLocation(timezone); err == nil {
+			loc = l
+		}
+	}
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Check returns the blackout covering day for the organization, or nil if campaigns may send
+func Check(db *gorm.DB, orgID uuid.UUID, day time.Time) (*Blackout, error) {
+	var date models.CampaignBlackoutDate
+	err := db.Where("organization_id = ? AND date = ?", orgID, day.Format("2006-01-02")).
+		Order("calendar_id IS NOT NULL").
+		First(&date).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Blackout{Date: day, Name: date.Name}, nil
+}
+
+// DueCalendars returns active calendars that have not been synced within RefreshInterval
+func DueCalendars(db *gorm.DB) ([]models.HolidayCalendar, error) {
+	var calendars []models.HolidayCalendar
+	err := db.Where("is_active = ? AND (last_synced_at IS NULL OR last_synced_at < ?)", true, time.Now().Add(-RefreshInterval)).
+		Find(&calendars).Error
+	return calendars, err
+}
@@ -0,0 +1,84 @@
+package i18n
+
+var en = Catalog{
+	"campaign_status": {
+		"draft":            "Draft",
+		"scheduled":        "Scheduled",
+		"pending_template": "Awaiting template approval",
+		"queued":           "Queued",
+		"processing":       "Sending",
+		"held":             "Held",
+		"paused":           "Paused",
+		"completed":        "Completed",
+		"cancelled":        "Cancelled",
+		"failed":           "Failed",
+	},
+	"message_status": {
+		"pending":   "Pending",
+		"sent":      "Sent",
+		"delivered": "Delivered",
+		"read":      "Read",
+		"failed":    "Failed",
+	},
+	"message_direction": {
+		"incoming": "Incoming",
+		"outgoing": "Outgoing",
+	},
+	"template_status": {
+		"PENDING":  "Pending review",
+		"APPROVED": "Approved",
+		"REJECTED": "Rejected",
+	},
+	"flow_status": {
+		"DRAFT":      "Draft",
+		"PUBLISHED":  "Published",
+		"DEPRECATED": "Deprecated",
+		"BLOCKED":    "Blocked",
+	},
+	"role": {
+		"admin":   "Admin",
+		"manager": "Manager",
+		"agent":   "Agent",
+	},
+	"win_back_status": {
+		"active":    "Active",
+		"reengaged": "Re-engaged",
+		"completed": "Completed",
+		"exited":    "Exited",
+		"failed":    "Failed",
+	},
+	"label_source": {
+		"manual": "Manual",
+		"rule":   "Rule",
+	},
+	"errors": {
+		"Unauthorized":                                "Unauthorized",
+		"Access denied":                               "Access denied",
+		"Admin access required":                       "Admin access required",
+		"Invalid request body":                        "Invalid request body",
+		"Name is required":                            "Name is required",
+		"Not implemented yet":                         "Not implemented yet",
+		"Invalid ID":                                  "Invalid ID",
+		"User not found":                              "User not found",
+		"Contact not found":                           "Contact not found",
+		"Campaign not found":                          "Campaign not found",
+		"Template not found":                          "Template not found",
+		"Flow not found":                              "Flow not found",
+		"Team not found":                              "Team not found",
+		"Label not found":                             "Label not found",
+		"Message not found":                           "Message not found",
+		"Account not found":                           "Account not found",
+		"WhatsApp account not found":                  "WhatsApp account not found",
+		"No WhatsApp account configured":              "No WhatsApp account configured",
+		"Invalid contact ID":                          "Invalid contact ID",
+		"Invalid campaign ID":                         "Invalid campaign ID",
+		"Invalid template ID":                         "Invalid template ID",
+		"Invalid flow ID":                             "Invalid flow ID",
+		"Invalid user ID":                             "Invalid user ID",
+		"Invalid credentials":                         "Invalid credentials",
+		"Current password is incorrect":               "Current password is incorrect",
+		"Campaign has no recipients":                  "Campaign has no recipients",
+		"Campaign cannot be started in current state": "Campaign cannot be started in current state",
+		"Internal server error":                       "Internal server error",
+	},
+}
@@ -0,0 +1,84 @@
+package i18n
+
+var es = Catalog{
+	"campaign_status": {
+		"draft":            "Borrador",
+		"scheduled":        "Programada",
+		"pending_template": "Esperando aprobación de plantilla",
+		"queued":           "En cola",
+		"processing":       "Enviando",
+		"held":             "En espera",
+		"paused":           "En pausa",
+		"completed":        "Completada",
+		"cancelled":        "Cancelada",
+		"failed":           "Fallida",
+	},
+	"message_status": {
+		"pending":   "Pendiente",
+		"sent":      "Enviado",
+		"delivered": "Entregado",
+		"read":      "Leído",
+		"failed":    "Fallido",
+	},
+	"message_direction": {
+		"incoming": "Entrante",
+		"outgoing": "Saliente",
+	},
+	"template_status": {
+		"PENDING":  "En revisión",
+		"APPROVED": "Aprobada",
+		"REJECTED": "Rechazada",
+	},
+	"flow_status": {
+		"DRAFT":      "Borrador",
+		"PUBLISHED":  "Publicado",
+		"DEPRECATED": "Obsoleto",
+		"BLOCKED":    "Bloqueado",
+	},
+	"role": {
+		"admin":   "Administrador",
+		"manager": "Gerente",
+		"agent":   "Agente",
+	},
+	"win_back_status": {
+		"active":    "Activa",
+		"reengaged": "Recuperado",
+		"completed": "Completada",
+		"exited":    "Finalizada",
+		"failed":    "Fallida",
+	},
+	"label_source": {
+		"manual": "Manual",
+		"rule":   "Regla",
+	},
+	"errors": {
+		"Unauthorized":                                "No autorizado",
+		"Access denied":                               "Acceso denegado",
+		"Admin access required":                       "Se requiere acceso de administrador",
+		"Invalid request body":                        "Cuerpo de la solicitud no válido",
+		"Name is required":                            "El nombre es obligatorio",
+		"Not implemented yet":                         "Aún no implementado",
+		"Invalid ID":                                  "ID no válido",
+		"User not found":                              "Usuario no encontrado",
+		"Contact not found":                           "Contacto no encontrado",
+		"Campaign not found":                          "Campaña no encontrada",
+		"Template not found":                          "Plantilla no encontrada",
+		"Flow not found":                              "Flujo no encontrado",
+		"Team not found":                              "Equipo no encontrado",
+		"Label not found":                             "Etiqueta no encontrada",
+		"Message not found":                           "Mensaje no encontrado",
+		"Account not found":                           "Cuenta no encontrada",
+		"WhatsApp account not found":                  "Cuenta de WhatsApp no encontrada",
+		"No WhatsApp account configured":              "No hay ninguna cuenta de WhatsApp configurada",
+		"Invalid contact ID":                          "ID de contacto no válido",
+		"Invalid campaign ID":                         "ID de campaña no válido",
+		"Invalid template ID":                         "ID de plantilla no válido",
+		"Invalid flow ID":                             "ID de flujo no válido",
+		"Invalid user ID":                             "ID de usuario no válido",
+		"Invalid credentials":                         "Credenciales no válidas",
+		"Current password is incorrect":               "La contraseña actual es incorrecta",
+		"Campaign has no recipients":                  "La campaña no tiene destinatarios",
+		"Campaign cannot be started in current state": "La campaña no se puede iniciar en su estado actual",
+		"Internal server error":                       "Error interno del servidor",
+	},
+}
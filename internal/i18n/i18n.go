@@ -0,0 +1,87 @@
+// Package i18n holds the translated labels the API serves to the dashboard: enum
+// and status labels, plus translations of common error messages. Catalogs are
+// keyed by section and then by the raw value (or, for errors, the English text).
+package i18n
+
+import (
+	"sort"
+	"strings"
+)
+
+// Default is the locale used when none is requested or the requested one is unsupported
+const Default = "en"
+
+// Catalog maps a section (e.g. "campaign_status") to its value -> label pairs
+type Catalog map[string]map[string]string
+
+var catalogs = map[string]Catalog{
+	"en": en,
+	"es": es,
+	"pt": pt,
+}
+
+// Supported returns the supported locales in sorted order
+func Supported() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Normalize maps a language tag such as "pt-BR" or "es_MX" to a supported locale,
+// or returns "" if the language isn't supported
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	return ""
+}
+
+// FromAcceptLanguage returns the first supported locale in an Accept-Language
+// header, or Default. Quality values are ignored; browsers list tags in order.
+func FromAcceptLanguage(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if locale := Normalize(tag); locale != "" {
+			return locale
+		}
+	}
+	return Default
+}
+
+// Strings returns the full catalog for locale, with any missing entries filled in
+// from the default locale
+func Strings(locale string) Catalog {
+	result := make(Catalog, len(en))
+	for section, labels := range en {
+		merged := make(map[string]string, len(labels))
+		for key, label := range labels {
+			merged[key] = label
+		}
+		if catalog, ok := catalogs[locale]; ok && locale != Default {
+			for key, label := range catalog[section] {
+				merged[key] = label
+			}
+		}
+		result[section] = merged
+	}
+	return result
+}
+
+// T translates key within section, falling back to the default locale and then to
+// the key itself
+func T(locale, section, key string) string {
+	if label, ok := catalogs[locale][section][key]; ok {
+		return label
+	}
+	if label, ok := en[section][key]; ok {
+		return label
+	}
+	return key
+}
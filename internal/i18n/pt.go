@@ -0,0 +1,84 @@
+package i18n
+
+var pt = Catalog{
+	"campaign_status": {
+		"draft":            "Rascunho",
+		"scheduled":        "Agendada",
+		"pending_template": "Aguardando aprovação do modelo",
+		"queued":           "Na fila",
+		"processing":       "Enviando",
+		"held":             "Em espera",
+		"paused":           "Pausada",
+		"completed":        "Concluída",
+		"cancelled":        "Cancelada",
+		"failed":           "Falhou",
+	},
+	"message_status": {
+		"pending":   "Pendente",
+		"sent":      "Enviada",
+		"delivered": "Entregue",
+		"read":      "Lida",
+		"failed":    "Falhou",
+	},
+	"message_direction": {
+		"incoming": "Recebida",
+		"outgoing": "Enviada",
+	},
+	"template_status": {
+		"PENDING":  "Em análise",
+		"APPROVED": "Aprovado",
+		"REJECTED": "Rejeitado",
+	},
+	"flow_status": {
+		"DRAFT":      "Rascunho",
+		"PUBLISHED":  "Publicado",
+		"DEPRECATED": "Obsoleto",
+		"BLOCKED":    "Bloqueado",
+	},
+	"role": {
+		"admin":   "Administrador",
+		"manager": "Gerente",
+		"agent":   "Agente",
+	},
+	"win_back_status": {
+		"active":    "Ativa",
+		"reengaged": "Reengajado",
+		"completed": "Concluída",
+		"exited":    "Encerrada",
+		"failed":    "Falhou",
+	},
+	"label_source": {
+		"manual": "Manual",
+		"rule":   "Regra",
+	},
+	"errors": {
+		"Unauthorized":                                "Não autorizado",
+		"Access denied":                               "Acesso negado",
+		"Admin access required":                       "Acesso de administrador necessário",
+		"Invalid request body":                        "Corpo da requisição inválido",
+		"Name is required":                            "O nome é obrigatório",
+		"Not implemented yet":                         "Ainda não implementado",
+		"Invalid ID":                                  "ID inválido",
+		"User not found":                              "Usuário não encontrado",
+		"Contact not found":                           "Contato não encontrado",
+		"Campaign not found":                          "Campanha não encontrada",
+		"Template not found":                          "Modelo não encontrado",
+		"Flow not found":                              "Fluxo não encontrado",
+		"Team not found":                              "Equipe não encontrada",
+		"Label not found":                             "Etiqueta não encontrada",
+		"Message not found":                           "Mensagem não encontrada",
+		"Account not found":                           "Conta não encontrada",
+		"WhatsApp account not found":                  "Conta do WhatsApp não encontrada",
+		"No WhatsApp account configured":              "Nenhuma conta do WhatsApp configurada",
+		"Invalid contact ID":                          "ID de contato inválido",
+		"Invalid campaign ID":                         "ID de campanha inválido",
+		"Invalid template ID":                         "ID de modelo inválido",
+		"Invalid flow ID":                             "ID de fluxo inválido",
+		"Invalid user ID":                             "ID de usuário inválido",
+		"Invalid credentials":                         "Credenciais inválidas",
+		"Current password is incorrect":               "A senha atual está incorreta",
+		"Campaign has no recipients":                  "A campanha não tem destinatários",
+		"Campaign cannot be started in current state": "A campanha não pode ser iniciada no estado atual",
+		"Internal server error":                       "Erro interno do servidor",
+	},
+}
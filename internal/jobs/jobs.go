@@ -0,0 +1,203 @@
+// Package jobs records the progress of background operations such as imports and
+// report runs. Each operation gets a models.Job its starter can poll by ID, and every
+// change is published for the server to push over the WebSocket.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"gorm.io/gorm"
+)
+
+// progressInterval is the longest a running job's progress goes unsaved and
+// unpublished while it keeps advancing
+const progressInterval = time.Second
+
+// Create records a queued job of jobType for the organization. referenceID is what
+// the job works on and createdBy who started it; either may be nil.
+func Create(db *gorm.DB, orgID uuid.UUID, jobType string, referenceID, createdBy *uuid.UUID) (*models.Job, error) {
+	job := models.Job{
+		OrganizationID: orgID,
+		Type:           jobType,
+		Status:         models.JobQueued,
+		ReferenceID:    referenceID,
+		Result:         models.JSONB{},
+		Errors:         models.StringArray{},
+		CreatedByID:    createdBy,
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Tracker saves and publishes a job's progress. Its methods do nothing on a nil
+// Tracker, so code that runs both as a job and inline can report unconditionally.
+type Tracker struct {
+	db        *gorm.DB
+	publisher *queue.Publisher
+
+	mu      sync.Mutex
+	job     *models.Job
+	savedAt time.Time
+}
+
+// NewTracker returns a Tracker for job
+func NewTracker(db *gorm.DB, publisher *queue.Publisher, job *models.Job) *Tracker {
+	return &Tracker{db: db, publisher: publisher, job: job}
+}
+
+// Open returns a Tracker for the job with the given ID, or nil if there is none
+func Open(db *gorm.DB, publisher *queue.Publisher, id uuid.UUID) *Tracker {
+	var job models.Job
+	if err := db.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil
+	}
+	return NewTracker(db, publisher, &job)
+}
+
+// Start marks the job running with total items to process, 0 if not known
+func (t *Tracker) Start(total int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.job.Status = models.JobRunning
+	t.job.Total = total
+	t.job.StartedAt = &now
+	t.save(map[string]interface{}{
+		"status":     t.job.Status,
+		"total":      total,
+		"started_at": now,
+	})
+}
+
+// Advance counts n more items processed. Progress is saved and published at most once
+// every progressInterval.
+func (t *Tracker) Advance(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.job.Processed += n
+	if t.job.Total > 0 {
+		t.job.Progress = min(t.job.Processed*100/t.job.Total, 99)
+	}
+	if time.Since(t.savedAt) < progressInterval {
+		return
+	}
+	t.save(map[string]interface{}{
+		"processed":   t.job.Processed,
+		"progress":    t.job.Progress,
+		"errors":      t.job.Errors,
+		"error_count": t.job.ErrorCount,
+	})
+}
+
+// AddError records an item that failed; the first MaxJobErrors are kept
+func (t *Tracker) AddError(msg string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.job.ErrorCount++
+	if len(t.job.Errors) < models.MaxJobErrors {
+		t.job.Errors = append(t.job.Errors, msg)
+	}
+}
+
+// Complete marks the job done, with where to fetch its result, if anywhere, and a
+// summary of it
+func (t *Tracker) Complete(resultURL string, result models.JSONB) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if result == nil {
+		result = models.JSONB{}
+	}
+	t.job.Status = models.JobCompleted
+	t.job.Progress = 100
+	t.job.ResultURL = resultURL
+	t.job.Result = result
+	t.job.CompletedAt = &now
+	t.save(map[string]interface{}{
+		"status":       t.job.Status,
+		"progress":     100,
+		"processed":    t.job.Processed,
+		"result_url":   resultURL,
+		"result":       result,
+		"errors":       t.job.Errors,
+		"error_count":  t.job.ErrorCount,
+		"completed_at": now,
+	})
+}
+
+// Fail marks the job failed with why
+func (t *Tracker) Fail(errMsg string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.job.Status = models.JobFailed
+	t.job.Error = errMsg
+	t.job.CompletedAt = &now
+	t.save(map[string]interface{}{
+		"status":       t.job.Status,
+		"error":        errMsg,
+		"processed":    t.job.Processed,
+		"errors":       t.job.Errors,
+		"error_count":  t.job.ErrorCount,
+		"completed_at": now,
+	})
+}
+
+// JobID returns the tracked job's ID, or uuid.Nil on a nil Tracker
+func (t *Tracker) JobID() uuid.UUID {
+	if t == nil {
+		return uuid.Nil
+	}
+	return t.job.ID
+}
+
+// save writes updates to the job and publishes its progress; t.mu must be held
+func (t *Tracker) save(updates map[string]interface{}) {
+	t.savedAt = time.Now()
+	t.db.Model(&models.Job{}).Where("id = ?", t.job.ID).Updates(updates)
+
+	if t.publisher == nil {
+		return
+	}
+	job := t.job
+	t.publisher.PublishJobUpdate(context.Background(), &queue.JobUpdate{
+		JobID:          job.ID,
+		OrganizationID: job.OrganizationID,
+		CreatedByID:    job.CreatedByID,
+		Type:           job.Type,
+		Status:         job.Status,
+		Progress:       job.Progress,
+		Processed:      job.Processed,
+		Total:          job.Total,
+		ErrorCount:     job.ErrorCount,
+		ResultURL:      job.ResultURL,
+		Error:          job.Error,
+	})
+}
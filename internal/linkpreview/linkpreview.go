@@ -0,0 +1,242 @@
+// Package linkpreview fetches the title, description and image a web page declares
+// for link previews (Open Graph tags, falling back to <title> and the description
+// meta tag). Only public http and https addresses are fetched, so agents can't use it
+// to reach the server's internal network.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// fetchTimeout bounds the whole fetch, redirects included
+	fetchTimeout = 5 * time.Second
+	// maxBodySize is how much of the page is read; preview tags are in the head
+	maxBodySize = 512 * 1024
+	// maxRedirects is how many redirects are followed
+	maxRedirects = 3
+	// maxFieldLength truncates overly long titles and descriptions
+	maxFieldLength = 300
+	// trailingPunct is stripped from URLs found at the end of a sentence
+	trailingPunct = ".,;:!?)]}"
+)
+
+// ErrBlockedURL is returned for URLs that aren't public http or https addresses
+var ErrBlockedURL = errors.New("url is not a public http or https address")
+
+// Preview is the metadata shown for a link
+type Preview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+var (
+	urlPattern      = regexp.MustCompile(`https?://[^\s<>"']+`)
+	metaPattern     = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern     = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*("([^"]*)"|'([^']*)')`)
+	titlePattern    = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	blockedNetworks = mustParseCIDRs(
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16", "198.18.0.0/15", "224.0.0.0/4", "240.0.0.0/4",
+		"::/128", "::1/128", "fc00::/7", "fe80::/10", "ff00::/8",
+	)
+)
+
+// FirstURL returns the first http or https URL in text, or "" if there is none
+func FirstURL(text string) string {
+	return strings.TrimRight(urlPattern.FindString(text), trailingPunct)
+}
+
+// Validate parses raw and checks it is an http or https URL on a default port
+// without credentials. Whether the host is public is checked when connecting.
+func Validate(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return nil, ErrBlockedURL
+	}
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		return nil, ErrBlockedURL
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && blocked(ip) {
+		return nil, ErrBlockedURL
+	}
+	return u, nil
+}
+
+// Fetch downloads the page at raw and returns its preview metadata
+func Fetch(ctx context.Context, raw string) (*Preview, error) {
+	u, err := Validate(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WhatomateLinkPreview/1.0)")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch url: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("url returned status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(strings.ToLower(ct), "html") {
+		// Not a page; preview it by its address only
+		return &Preview{URL: resp.Request.URL.String(), Title: resp.Request.URL.Host}, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	return Parse(resp.Request.URL, string(body)), nil
+}
+
+// Parse extracts preview metadata from a page fetched from pageURL
+func Parse(pageURL *url.URL, page string) *Preview {
+	if i := strings.Index(strings.ToLower(page), "</head>"); i >= 0 {
+		page = page[:i]
+	}
+
+	meta := map[string]string{}
+	for _, tag := range metaPattern.FindAllString(page, -1) {
+		attrs := map[string]string{}
+		for _, m := range attrPattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[3] + m[4]
+		}
+		key := strings.ToLower(attrs["property"])
+		if key == "" {
+			key = strings.ToLower(attrs["name"])
+		}
+		if _, seen := meta[key]; key != "" && !seen {
+			meta[key] = clean(attrs["content"])
+		}
+	}
+
+	p := &Preview{
+		URL:         pageURL.String(),
+		Title:       first(meta["og:title"], meta["twitter:title"]),
+		Description: first(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    meta["og:site_name"],
+	}
+	if p.Title == "" {
+		if m := titlePattern.FindStringSubmatch(page); m != nil {
+			p.Title = clean(m[1])
+		}
+	}
+	if p.Title == "" {
+		p.Title = pageURL.Host
+	}
+	if image := first(meta["og:image"], meta["twitter:image"]); image != "" {
+		// Image URLs are often relative to the page
+		if ref, err := pageURL.Parse(image); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") {
+			p.Image = ref.String()
+		}
+	}
+	return p
+}
+
+// client only fetches public addresses, redirects included
+var client = &http.Client{
+	Timeout:   fetchTimeout,
+	Transport: NewPublicTransport(fetchTimeout),
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errors.New("too many redirects")
+		}
+		_, err := Validate(req.URL.String())
+		return err
+	},
+}
+
+// NewPublicTransport returns a transport that refuses to connect to non-public
+// addresses, checked on the resolved IP so DNS can't point an allowed name at an
+// internal host. timeout bounds connecting and waiting for the response headers.
+func NewPublicTransport(timeout time.Duration) *http.Transport {
+	return &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: timeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || blocked(ip) {
+					return ErrBlockedURL
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+}
+
+func blocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range blockedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func clean(s string) string {
+	s = strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+	if r := []rune(s); len(r) > maxFieldLength {
+		s = string(r[:maxFieldLength-1]) + "…"
+	}
+	return s
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
@@ -0,0 +1,235 @@
+// Package mailer sends plain-text emails, with optional HTML alternatives and
+// attachments, over SMTP. Messages can use an organization's own From address,
+// signed with DKIM for its domain.
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/config"
+)
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Mailer sends email through the configured SMTP server
+type Mailer struct {
+	cfg config.SMTPConfig
+}
+
+// New creates a mailer
+func New(cfg config.SMTPConfig) *Mailer {
+	return &Mailer{cfg: cfg}
+}
+
+// Enabled reports whether SMTP is configured
+func (m *Mailer) Enabled() bool {
+	return m.cfg.Host != "" && m.cfg.From != ""
+}
+
+// Message is an email to send
+type Message struct {
+	From        string // Header From address; empty uses the configured one
+	To          []string
+	Subject     string
+	Text        string
+	HTML        string // Optional HTML alternative to Text
+	Attachments []Attachment
+	DKIM        *DKIM // Optional signer for the From address's domain
+}
+
+// Send delivers a plain-text email to the given recipients
+func (m *Mailer) Send(to []string, subject, body string, attachments ...Attachment) error {
+	return m.SendMessage(Message{To: to, Subject: subject, Text: body, Attachments: attachments})
+}
+
+// SendMessage delivers an email. The SMTP envelope always uses the configured From
+// address so bounces reach the platform; the header From can be the organization's.
+func (m *Mailer) SendMessage(message Message) error {
+	if !m.Enabled() {
+		return fmt.Errorf("smtp is not configured")
+	}
+	to := message.To
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	from, err := mail.ParseAddress(m.cfg.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	headerFrom := from
+	if message.From != "" {
+		if headerFrom, err = mail.ParseAddress(message.From); err != nil {
+			return fmt.Errorf("invalid from address: %w", err)
+		}
+	}
+	for _, addr := range to {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", addr, err)
+		}
+	}
+
+	msg, err := buildMessage(headerFrom.String(), to, message.Subject, message.Text, message.HTML, message.Attachments)
+	if err != nil {
+		return err
+	}
+	if message.DKIM != nil {
+		if msg, err = message.DKIM.Sign(msg); err != nil {
+			return fmt.Errorf("failed to sign message: %w", err)
+		}
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	// smtp.SendMail upgrades with STARTTLS when offered; port 465 needs TLS from the start
+	if m.cfg.Port != 465 {
+		return smtp.SendMail(addr, auth, from.Address, to, msg)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: m.cfg.Host})
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage renders a MIME message. An HTML body makes the text a
+// multipart/alternative, and attachments wrap it in multipart/mixed.
+func buildMessage(from string, to []string, subject, text, html string, attachments []Attachment) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(attachments) == 0 {
+		return buf.Bytes(), writeBody(&buf, text, html)
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	if err := writeBody(&buf, text, html); err != nil {
+		return nil, err
+	}
+
+	for _, a := range attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s\r\n", contentType)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&buf, "Content-Disposition: %s\r\n\r\n", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		writeBase64(&buf, a.Data)
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// writeBody writes the text part, or a multipart/alternative of text and HTML,
+// starting with its Content-Type header
+func writeBody(buf *bytes.Buffer, text, html string) error {
+	if html == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64(buf, []byte(text))
+		return nil
+	}
+
+	boundary, err := newBoundary()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", text},
+		{"text/html", html},
+	} {
+		fmt.Fprintf(buf, "--%s\r\n", boundary)
+		fmt.Fprintf(buf, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64(buf, []byte(part.body))
+	}
+	fmt.Fprintf(buf, "--%s--\r\n", boundary)
+	return nil
+}
+
+func newBoundary() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whatomate-" + hex.EncodeToString(b), nil
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+}
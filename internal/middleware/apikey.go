@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"errors"
+	"net"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/valyala/fasthttp"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidAPIKey      = errors.New("invalid API key")
+	errAPIKeyExpired      = errors.New("API key expired")
+	errAPIKeyIPNotAllowed = errors.New("API key not allowed from this IP")
+	errAPIKeyScope        = errors.New("API key not permitted for this endpoint")
+)
+
+// ClientIP returns the caller's IP address. Proxy headers are only honoured
+// when the server is configured to sit behind a trusted reverse proxy.
+func ClientIP(ctx *fasthttp.RequestCtx, trustProxyHeaders bool) string {
+	if trustProxyHeaders {
+		if xff := string(ctx.Request.Header.Peek("X-Forwarded-For")); xff != "" {
+			// First entry is the original client
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+		if realIP := string(ctx.Request.Header.Peek("X-Real-IP")); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+	return ctx.RemoteIP().String()
+}
+
+// ValidateIPAllowlist checks that every entry is a valid CIDR or IP address
+func ValidateIPAllowlist(entries []string) error {
+	for _, entry := range entries {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		if net.ParseIP(entry) == nil {
+			return errors.New("invalid IP or CIDR: " + entry)
+		}
+	}
+	return nil
+}
+
+// APIKeyIPAllowed reports whether ip matches the allowlist. An empty allowlist allows any IP.
+func APIKeyIPAllowed(allowlist []string, ip string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, entry := range allowlist {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateScopes checks the syntax of API key scopes
+// A scope is "[METHOD ]PATTERN" where METHOD is an HTTP method or "*" and PATTERN is a
+// path in which "*" matches one segment and a trailing "/**" matches everything below it
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		method, pattern := splitScope(scope)
+		if !strings.HasPrefix(pattern, "/api/") {
+			return errors.New("scope path must start with /api/: " + scope)
+		}
+		switch method {
+		case "*", "GET", "POST", "PUT", "PATCH", "DELETE":
+		default:
+			return errors.New("invalid scope method: " + scope)
+		}
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), "/api/"); err != nil {
+			return errors.New("invalid scope pattern: " + scope)
+		}
+	}
+	return nil
+}
+
+// APIKeyScopeAllowed reports whether a request matches any of the key's scopes.
+// An empty scope list grants access to every endpoint.
+func APIKeyScopeAllowed(scopes []string, method, requestPath string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		scopeMethod, pattern := splitScope(scope)
+		if scopeMethod != "*" && scopeMethod != method {
+			continue
+		}
+		if base, ok := strings.CutSuffix(pattern, "/**"); ok {
+			if requestPath == base || matchPathPrefix(base, requestPath) {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, requestPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// splitScope splits "GET /api/contacts" into its method and path; a bare path matches any method
+func splitScope(scope string) (string, string) {
+	scope = strings.TrimSpace(scope)
+	if method, pattern, ok := strings.Cut(scope, " "); ok {
+		return strings.ToUpper(method), strings.TrimSpace(pattern)
+	}
+	return "*", scope
+}
+
+// matchPathPrefix reports whether requestPath lies below the base pattern
+func matchPathPrefix(base, requestPath string) bool {
+	baseParts := strings.Split(base, "/")
+	pathParts := strings.Split(requestPath, "/")
+	if len(pathParts) <= len(baseParts) {
+		return false
+	}
+	for i, part := range baseParts {
+		if matched, _ := path.Match(part, pathParts[i]); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeEndpoint replaces ID-like path segments with {id} so usage is grouped per route
+func NormalizeEndpoint(requestPath string) string {
+	parts := strings.Split(requestPath, "/")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		if _, err := uuid.Parse(part); err == nil {
+			parts[i] = "{id}"
+			continue
+		}
+		if strings.Trim(part, "0123456789") == "" {
+			parts[i] = "{id}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// recordAPIKeyUsage updates the key's last use and increments its daily per-endpoint counters
+func recordAPIKeyUsage(db *gorm.DB, apiKey models.APIKey, method, requestPath, clientIP string, denied bool) {
+	now := time.Now()
+	if !denied {
+		db.Model(&models.APIKey{}).Where("id = ?", apiKey.ID).Updates(map[string]any{
+			"last_used_at": now,
+			"last_used_ip": clientIP,
+		})
+	}
+
+	var requests, deniedCount int64 = 1, 0
+	if denied {
+		deniedCount = 1
+	}
+
+	db.Exec(`INSERT INTO api_key_usages (id, organization_id, api_key_id, date, method, endpoint, request_count, denied_count, last_ip, last_used_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (api_key_id, date, method, endpoint) DO UPDATE SET
+			request_count = api_key_usages.request_count + EXCLUDED.request_count,
+			denied_count = api_key_usages.denied_count + EXCLUDED.denied_count,
+			last_ip = EXCLUDED.last_ip,
+			last_used_at = EXCLUDED.last_used_at,
+			updated_at = EXCLUDED.updated_at`,
+		uuid.New(), apiKey.OrganizationID, apiKey.ID, now.Format("2006-01-02"), method, NormalizeEndpoint(requestPath),
+		requests, deniedCount, clientIP, now, now, now)
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"encoding/json"
+
+	"github.com/shridarpatil/whatomate/internal/i18n"
+	"github.com/zerodha/fastglue"
+)
+
+// LocalizeErrors translates the message of error envelopes into the locale from
+// the Accept-Language header. Messages without a translation are left as is.
+// Register it with Fastglue.After.
+func LocalizeErrors() fastglue.FastMiddleware {
+	return func(r *fastglue.Request) *fastglue.Request {
+		if r.RequestCtx.Response.StatusCode() < 400 {
+			return r
+		}
+
+		locale := i18n.FromAcceptLanguage(string(r.RequestCtx.Request.Header.Peek("Accept-Language")))
+		if locale == i18n.Default {
+			return r
+		}
+
+		var envelope map[string]interface{}
+		if err := json.Unmarshal(r.RequestCtx.Response.Body(), &envelope); err != nil {
+			return r
+		}
+		message, ok := envelope["message"].(string)
+		if !ok {
+			return r
+		}
+		translated := i18n.T(locale, "errors", message)
+		if translated == message {
+			return r
+		}
+
+		envelope["message"] = translated
+		body, err := json.Marshal(envelope)
+		if err != nil {
+			return r
+		}
+		r.RequestCtx.Response.SetBody(body)
+		r.RequestCtx.Response.Header.Set("Content-Language", locale)
+		return r
+	}
+}
@@ -55,7 +55,7 @@ func CORS() fastglue.FastMiddleware {
 
 		r.RequestCtx.Response.Header.Set("Access-Control-Allow-Origin", origin)
 		r.RequestCtx.Response.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		r.RequestCtx.Response.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Requested-With")
+		r.RequestCtx.Response.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Organization-ID, X-Requested-With")
 		r.RequestCtx.Response.Header.Set("Access-Control-Allow-Credentials", "true")
 		r.RequestCtx.Response.Header.Set("Access-Control-Max-Age", "86400")
 
@@ -85,22 +85,40 @@ func Recovery(log logf.Logger) fastglue.FastMiddleware {
 
 // Auth validates JWT tokens (legacy - use AuthWithDB for API key support)
 func Auth(secret string) fastglue.FastMiddleware {
-	return AuthWithDB(secret, nil)
+	return AuthWithDB(secret, nil, false)
 }
 
 // AuthWithDB validates both JWT tokens and API keys
-func AuthWithDB(secret string, db *gorm.DB) fastglue.FastMiddleware {
+// trustProxyHeaders makes API key IP allowlists use X-Forwarded-For / X-Real-IP
+func AuthWithDB(secret string, db *gorm.DB, trustProxyHeaders bool) fastglue.FastMiddleware {
 	return func(r *fastglue.Request) *fastglue.Request {
 		authHeader := string(r.RequestCtx.Request.Header.Peek("Authorization"))
 		apiKey := string(r.RequestCtx.Request.Header.Peek("X-API-Key"))
 
 		// Try API key authentication first
 		if apiKey != "" && db != nil {
-			if validateAPIKey(r, apiKey, db) {
+			validate := validateAPIKey
+			if strings.HasPrefix(apiKey, PartnerAPIKeyPrefix) {
+				validate = validatePartnerAPIKey
+			}
+			err := validate(r, apiKey, db, ClientIP(r.RequestCtx, trustProxyHeaders))
+			switch err {
+			case nil:
 				return r
+			case errAPIKeyExpired:
+				r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "API key expired", nil, "")
+			case errAPIKeyIPNotAllowed:
+				r.SendErrorEnvelope(fasthttp.StatusForbidden, "API key not allowed from this IP address", nil, "")
+			case errAPIKeyScope:
+				r.SendErrorEnvelope(fasthttp.StatusForbidden, "API key not permitted for this endpoint", nil, "")
+			case errPartnerOrgRequired:
+				r.SendErrorEnvelope(fasthttp.StatusBadRequest, "X-Organization-ID header is required for partner API keys", nil, "")
+			case errPartnerOrgNotAllowed:
+				r.SendErrorEnvelope(fasthttp.StatusForbidden, "API key not permitted for this organization", nil, "")
+			default:
+				// API key was provided but invalid
+				r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid API key", nil, "")
 			}
-			// API key was provided but invalid
-			r.SendErrorEnvelope(fasthttp.StatusUnauthorized, "Invalid API key", nil, "")
 			return nil
 		}
 
@@ -145,11 +163,12 @@ func AuthWithDB(secret string, db *gorm.DB) fastglue.FastMiddleware {
 	}
 }
 
-// validateAPIKey validates an API key and sets context values
-func validateAPIKey(r *fastglue.Request, key string, db *gorm.DB) bool {
+// validateAPIKey validates an API key, enforces its IP allowlist and endpoint scopes,
+// records usage and sets context values
+func validateAPIKey(r *fastglue.Request, key string, db *gorm.DB, clientIP string) error {
 	// API key format: whm_<32 hex chars>
 	if len(key) != 36 || key[:4] != "whm_" {
-		return false
+		return errInvalidAPIKey
 	}
 
 	// Extract prefix for lookup (first 8 chars after "whm_")
@@ -158,35 +177,47 @@ func validateAPIKey(r *fastglue.Request, key string, db *gorm.DB) bool {
 	// Find API keys with matching prefix
 	var apiKeys []models.APIKey
 	if err := db.Preload("User").Where("key_prefix = ? AND is_active = ?", keyPrefix, true).Find(&apiKeys).Error; err != nil {
-		return false
+		return errInvalidAPIKey
 	}
 
+	method := string(r.RequestCtx.Method())
+	path := string(r.RequestCtx.Path())
+
 	// Check each key with bcrypt
 	for _, apiKey := range apiKeys {
-		if err := bcrypt.CompareHashAndPassword([]byte(apiKey.KeyHash), []byte(key)); err == nil {
-			// Key matches - check expiration
-			if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
-				return false // Key expired
-			}
+		if err := bcrypt.CompareHashAndPassword([]byte(apiKey.KeyHash), []byte(key)); err != nil {
+			continue
+		}
 
-			// Update last used timestamp (async to not block request)
-			go func(id uuid.UUID) {
-				now := time.Now()
-				db.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", now)
-			}(apiKey.ID)
-
-			// Set context values from the user who created the key
-			if apiKey.User != nil {
-				r.RequestCtx.SetUserValue(ContextKeyUserID, apiKey.UserID)
-				r.RequestCtx.SetUserValue(ContextKeyOrganizationID, apiKey.OrganizationID)
-				r.RequestCtx.SetUserValue(ContextKeyEmail, apiKey.User.Email)
-				r.RequestCtx.SetUserValue(ContextKeyRole, apiKey.User.Role)
-				return true
-			}
+		// Key matches - check expiration
+		if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+			return errAPIKeyExpired
+		}
+
+		if !APIKeyIPAllowed(apiKey.AllowedIPs, clientIP) {
+			go recordAPIKeyUsage(db, apiKey, method, path, clientIP, true)
+			return errAPIKeyIPNotAllowed
+		}
+
+		if !APIKeyScopeAllowed(apiKey.Scopes, method, path) {
+			go recordAPIKeyUsage(db, apiKey, method, path, clientIP, true)
+			return errAPIKeyScope
+		}
+
+		// Update last used timestamp and usage counters (async to not block request)
+		go recordAPIKeyUsage(db, apiKey, method, path, clientIP, false)
+
+		// Set context values from the user who created the key
+		if apiKey.User != nil {
+			r.RequestCtx.SetUserValue(ContextKeyUserID, apiKey.UserID)
+			r.RequestCtx.SetUserValue(ContextKeyOrganizationID, apiKey.OrganizationID)
+			r.RequestCtx.SetUserValue(ContextKeyEmail, apiKey.User.Email)
+			r.RequestCtx.SetUserValue(ContextKeyRole, apiKey.User.Role)
+			return nil
 		}
 	}
 
-	return false
+	return errInvalidAPIKey
 }
 
 // OrganizationContext loads organization and user from database
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/zerodha/fastglue"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// PartnerAPIKeyPrefix starts partner API keys, telling them apart from organization keys
+	PartnerAPIKeyPrefix = "whp_"
+	// HeaderOrganizationID names the client organization a partner API key acts on
+	HeaderOrganizationID = "X-Organization-ID"
+)
+
+var (
+	errPartnerOrgRequired   = errors.New("X-Organization-ID header required")
+	errPartnerOrgNotAllowed = errors.New("API key not permitted for this organization")
+)
+
+// validatePartnerAPIKey validates a partner API key and the client organization the
+// request names, then acts as an administrator of that organization. Partner keys
+// can't manage the partner itself, so a key scoped to one client can't mint keys for
+// the others.
+func validatePartnerAPIKey(r *fastglue.Request, key string, db *gorm.DB, clientIP string) error {
+	if len(key) != 36 || !strings.HasPrefix(key, PartnerAPIKeyPrefix) {
+		return errInvalidAPIKey
+	}
+
+	var apiKeys []models.PartnerAPIKey
+	if err := db.Where("key_prefix = ? AND is_active = ?", key[4:12], true).Find(&apiKeys).Error; err != nil {
+		return errInvalidAPIKey
+	}
+
+	method := string(r.RequestCtx.Method())
+	path := string(r.RequestCtx.Path())
+
+	for _, apiKey := range apiKeys {
+		if err := bcrypt.CompareHashAndPassword([]byte(apiKey.KeyHash), []byte(key)); err != nil {
+			continue
+		}
+
+		if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+			return errAPIKeyExpired
+		}
+		if strings.HasPrefix(path, "/api/partner") || !APIKeyScopeAllowed(apiKey.Scopes, method, path) {
+			return errAPIKeyScope
+		}
+
+		orgID, err := uuid.Parse(string(r.RequestCtx.Request.Header.Peek(HeaderOrganizationID)))
+		if err != nil {
+			return errPartnerOrgRequired
+		}
+		if len(apiKey.OrganizationIDs) > 0 && !slices.Contains(apiKey.OrganizationIDs, orgID.String()) {
+			return errPartnerOrgNotAllowed
+		}
+		var count int64
+		if err := db.Model(&models.Organization{}).Where("id = ? AND partner_id = ?", orgID, apiKey.PartnerID).Count(&count).Error; err != nil || count == 0 {
+			return errPartnerOrgNotAllowed
+		}
+
+		// The key stops working when its creator leaves the partner
+		var member models.PartnerMember
+		if err := db.Preload("User").Where("partner_id = ? AND user_id = ?", apiKey.PartnerID, apiKey.UserID).First(&member).Error; err != nil || member.User == nil {
+			return errInvalidAPIKey
+		}
+
+		go db.Model(&models.PartnerAPIKey{}).Where("id = ?", apiKey.ID).Updates(map[string]any{
+			"last_used_at": time.Now(),
+			"last_used_ip": clientIP,
+		})
+
+		r.RequestCtx.SetUserValue(ContextKeyUserID, apiKey.UserID)
+		r.RequestCtx.SetUserValue(ContextKeyOrganizationID, orgID)
+		r.RequestCtx.SetUserValue(ContextKeyEmail, member.User.Email)
+		r.RequestCtx.SetUserValue(ContextKeyRole, "admin")
+		return nil
+	}
+
+	return errInvalidAPIKey
+}
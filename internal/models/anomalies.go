@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetricAnomaly records a day on which an account's messaging rates deviated sharply
+// from their recent baseline, e.g. a failure spike or a read-rate collapse
+type MetricAnomaly struct {
+	BaseModel
+	OrganizationID   uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount  string     `gorm:"size:100;not null" json:"whatsapp_account"`
+	Date             time.Time  `gorm:"type:date;not null" json:"date"`
+	Metric           string     `gorm:"size:50;not null" json:"metric"`    // failure_rate, delivery_rate, read_rate
+	Direction        string     `gorm:"size:10;not null" json:"direction"` // spike, drop
+	Value            float64    `json:"value"`
+	Baseline         float64    `json:"baseline"`
+	StdDev           float64    `json:"std_dev"`
+	Volume           int64      `json:"volume"` // Messages the rate was computed over
+	Message          string     `gorm:"type:text" json:"message"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedByID *uuid.UUID `gorm:"type:uuid" json:"acknowledged_by_id,omitempty"`
+}
+
+func (MetricAnomaly) TableName() string {
+	return "metric_anomalies"
+}
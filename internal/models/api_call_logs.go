@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// APICallLog is a Graph API request made for an account while its debug logging
+// was on, with tokens redacted. Entries are pruned after a short retention.
+type APICallLog struct {
+	BaseModel
+	OrganizationID  uuid.UUID `gorm:"type:uuid;not null" json:"organization_id"`
+	WhatsAppAccount string    `gorm:"size:100;not null" json:"whatsapp_account"`
+	Method          string    `gorm:"size:10;not null" json:"method"`
+	URL             string    `gorm:"type:text;not null" json:"url"`
+	RequestBody     string    `gorm:"type:text" json:"request_body,omitempty"`
+	StatusCode      int       `json:"status_code"` // 0 when Meta didn't respond
+	ResponseBody    string    `gorm:"type:text" json:"response_body,omitempty"`
+	Error           string    `gorm:"type:text" json:"error,omitempty"`
+	DurationMs      int64     `json:"duration_ms"`
+}
+
+func (APICallLog) TableName() string {
+	return "api_call_logs"
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Message audit modes, stored in Organization.Settings["message_audit"]
+const (
+	MessageAuditOff       = ""
+	MessageAuditHash      = "hash"      // Record a content hash for every outgoing message
+	MessageAuditImmutable = "immutable" // Also keep the exact content in the write-once audit log
+)
+
+// messageAuditModeTTL bounds how long a settings change takes to reach other processes
+const messageAuditModeTTL = 30 * time.Second
+
+// MessageAuditRecord is an append-only, hash-chained record of an outgoing message.
+// The database rejects updates and deletes on this table (see database.getIndexes).
+type MessageAuditRecord struct {
+	ID              uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID  uuid.UUID `gorm:"type:uuid;not null" json:"organization_id"`
+	Sequence        int64     `gorm:"not null" json:"sequence"` // Position in the organization's chain, from 1
+	MessageID       uuid.UUID `gorm:"type:uuid;index;not null" json:"message_id"`
+	ContactID       uuid.UUID `gorm:"type:uuid;not null" json:"contact_id"`
+	RecipientPhone  string    `gorm:"size:20;not null" json:"recipient_phone"`
+	WhatsAppAccount string    `gorm:"size:100" json:"whatsapp_account"`
+	Mode            string    `gorm:"size:20;not null" json:"mode"`
+	ContentHash     string    `gorm:"size:64;not null" json:"content_hash"` // SHA-256 of Payload
+	PrevHash        string    `gorm:"size:64" json:"prev_hash"`             // RecordHash of the previous record
+	RecordHash      string    `gorm:"size:64;not null" json:"record_hash"`
+	Payload         string    `gorm:"type:text" json:"payload,omitempty"` // Canonical content; only kept in immutable mode
+	SentAt          time.Time `gorm:"not null" json:"sent_at"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (MessageAuditRecord) TableName() string {
+	return "message_audit_records"
+}
+
+// messageAuditPayload is the canonical form of an outgoing message that is hashed.
+// Field order is fixed and maps are marshalled with sorted keys, so the encoding is stable.
+type messageAuditPayload struct {
+	MessageID       string `json:"message_id"`
+	OrganizationID  string `json:"organization_id"`
+	WhatsAppAccount string `json:"whatsapp_account"`
+	RecipientPhone  string `json:"recipient_phone"`
+	MessageType     string `json:"message_type"`
+	Content         string `json:"content"`
+	MediaURL        string `json:"media_url,omitempty"`
+	MediaFilename   string `json:"media_filename,omitempty"`
+	TemplateName    string `json:"template_name,omitempty"`
+	TemplateParams  JSONB  `json:"template_params,omitempty"`
+	InteractiveData JSONB  `json:"interactive_data,omitempty"`
+	SentAt          string `json:"sent_at"`
+}
+
+// MessageAuditPayload returns the canonical content of an outgoing message and its SHA-256
+func MessageAuditPayload(m *Message, recipientPhone string) (string, string, error) {
+	payload, err := json.Marshal(messageAuditPayload{
+		MessageID:       m.ID.String(),
+		OrganizationID:  m.OrganizationID.String(),
+		WhatsAppAccount: m.WhatsAppAccount,
+		RecipientPhone:  recipientPhone,
+		MessageType:     m.MessageType,
+		Content:         m.Content,
+		MediaURL:        m.MediaURL,
+		MediaFilename:   m.MediaFilename,
+		TemplateName:    m.TemplateName,
+		TemplateParams:  m.TemplateParams,
+		InteractiveData: m.InteractiveData,
+		SentAt:          auditTimestamp(m.CreatedAt),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return string(payload), MessageAuditContentHash(string(payload)), nil
+}
+
+// MessageAuditContentHash hashes a canonical payload
+func MessageAuditContentHash(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// MessageAuditRecordHash chains a record to its predecessor
+func MessageAuditRecordHash(prevHash string, sequence int64, contentHash string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%d\n%s", prevHash, sequence, contentHash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// appendToAuditLog appends outgoing messages to the audit log when the organization has it
+// enabled. It runs in the message's transaction, so a message is never stored without its record.
+func (m *Message) appendToAuditLog(tx *gorm.DB) error {
+	if m.Direction != "outgoing" {
+		return nil
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	mode, err := messageAuditMode(db, m.OrganizationID)
+	if err != nil || mode == MessageAuditOff {
+		return err
+	}
+
+	var phone string
+	if err := db.Model(&Contact{}).Select("phone_number").Where("id = ?", m.ContactID).Scan(&phone).Error; err != nil {
+		return fmt.Errorf("message audit: failed to load recipient: %w", err)
+	}
+
+	payload, contentHash, err := MessageAuditPayload(m, phone)
+	if err != nil {
+		return fmt.Errorf("message audit: %w", err)
+	}
+
+	// Serialize appends per organization so the chain never forks
+	if err := db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "message_audit:"+m.OrganizationID.String()).Error; err != nil {
+		return fmt.Errorf("message audit: failed to lock chain: %w", err)
+	}
+
+	var prev MessageAuditRecord
+	err = db.Where("organization_id = ?", m.OrganizationID).Order("sequence DESC").Limit(1).Find(&prev).Error
+	if err != nil {
+		return fmt.Errorf("message audit: failed to load chain head: %w", err)
+	}
+
+	record := MessageAuditRecord{
+		OrganizationID:  m.OrganizationID,
+		Sequence:        prev.Sequence + 1,
+		MessageID:       m.ID,
+		ContactID:       m.ContactID,
+		RecipientPhone:  phone,
+		WhatsAppAccount: m.WhatsAppAccount,
+		Mode:            mode,
+		ContentHash:     contentHash,
+		PrevHash:        prev.RecordHash,
+		SentAt:          m.CreatedAt,
+	}
+	record.RecordHash = MessageAuditRecordHash(record.PrevHash, record.Sequence, contentHash)
+	if mode == MessageAuditImmutable {
+		record.Payload = payload
+	}
+
+	if err := db.Create(&record).Error; err != nil {
+		return fmt.Errorf("message audit: failed to append record: %w", err)
+	}
+	return nil
+}
+
+type cachedAuditMode struct {
+	mode    string
+	expires time.Time
+}
+
+var messageAuditModes sync.Map // uuid.UUID -> cachedAuditMode
+
+// messageAuditMode returns the organization's audit mode, cached briefly since it is read
+// for every outgoing message
+func messageAuditMode(db *gorm.DB, orgID uuid.UUID) (string, error) {
+	if v, ok := messageAuditModes.Load(orgID); ok {
+		if c := v.(cachedAuditMode); time.Now().Before(c.expires) {
+			return c.mode, nil
+		}
+	}
+
+	var mode string
+	err := db.Model(&Organization{}).
+		Select("COALESCE(settings->>'message_audit', '')").
+		Where("id = ?", orgID).
+		Scan(&mode).Error
+	if err != nil {
+		return "", fmt.Errorf("message audit: failed to load settings: %w", err)
+	}
+
+	messageAuditModes.Store(orgID, cachedAuditMode{mode: mode, expires: time.Now().Add(messageAuditModeTTL)})
+	return mode, nil
+}
+
+// auditTimestamp formats at the database's microsecond precision so hashes can be
+// recomputed from stored rows
+func auditTimestamp(t time.Time) string {
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Automation trigger events
+const (
+	AutomationEventTagAdded          = "tag_added"          // A tag was added to the contact
+	AutomationEventAttributeChanged  = "attribute_changed"  // A custom field of the contact changed
+	AutomationEventSegmentEntered    = "segment_entered"    // The contact started matching the trigger's segment
+	AutomationEventSegmentExited     = "segment_exited"     // The contact stopped matching the trigger's segment
+	AutomationEventCampaignDelivered = "campaign_delivered" // A campaign message was delivered to the contact
+	AutomationEventLinkClicked       = "link_clicked"       // A click on a link sent to the contact was reported
+)
+
+// Automation trigger actions
+const (
+	AutomationActionStartFlow    = "start_flow"
+	AutomationActionSendTemplate = "send_template"
+)
+
+// AutomationTrigger runs an action for a contact when something happens to them.
+// Guardrails bound how often one contact, and all contacts in a day, can be reached.
+type AutomationTrigger struct {
+	BaseModel
+	OrganizationID  uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string    `gorm:"size:100;not null" json:"whatsapp_account"` // Account the action is sent from
+	Name            string    `gorm:"size:255;not null" json:"name"`
+	IsEnabled       bool      `gorm:"default:true" json:"is_enabled"`
+	Event           string    `gorm:"size:30;index;not null" json:"event"`
+
+	// Event conditions; empty matches anything
+	Tag                  string     `gorm:"size:100" json:"tag"`                         // tag_added, and segments
+	AttributeKey         string     `gorm:"size:100" json:"attribute_key"`               // attribute_changed
+	AttributeValue       string     `gorm:"size:255" json:"attribute_value"`             // attribute_changed: the new value
+	SegmentMinEngagement int        `json:"segment_min_engagement"`                      // segments
+	SegmentLabelID       *uuid.UUID `gorm:"type:uuid" json:"segment_label_id,omitempty"` // segments
+	CampaignID           *uuid.UUID `gorm:"type:uuid" json:"campaign_id,omitempty"`      // campaign_delivered
+	URLContains          string     `gorm:"size:500" json:"url_contains"`                // link_clicked
+	SegmentSeededAt      *time.Time `json:"segment_seeded_at,omitempty"`                 // When segment membership was first recorded
+
+	// Action
+	Action         string     `gorm:"size:20;not null" json:"action"` // start_flow, send_template
+	FlowID         *uuid.UUID `gorm:"type:uuid" json:"flow_id,omitempty"`
+	TemplateID     *uuid.UUID `gorm:"type:uuid" json:"template_id,omitempty"`
+	TemplateParams JSONB      `gorm:"type:jsonb;default:'{}'" json:"template_params"` // "1" -> "{{name}}"
+
+	// Guardrails
+	CooldownHours int `gorm:"default:24" json:"cooldown_hours"` // Before the trigger runs for the same contact again
+	MaxPerContact int `json:"max_per_contact"`                  // Runs per contact ever; 0 means unlimited
+	DailyCap      int `json:"daily_cap"`                        // Runs per day across contacts; 0 means unlimited
+
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+}
+
+func (AutomationTrigger) TableName() string {
+	return "automation_triggers"
+}
+
+// Automation trigger run statuses
+const (
+	AutomationRunSucceeded = "succeeded"
+	AutomationRunSkipped   = "skipped" // A guardrail kept the action from running
+	AutomationRunFailed    = "failed"
+)
+
+// AutomationTriggerRun records a trigger matching a contact and what came of it
+type AutomationTriggerRun struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	TriggerID      uuid.UUID `gorm:"type:uuid;not null" json:"trigger_id"`
+	ContactID      uuid.UUID `gorm:"type:uuid;not null" json:"contact_id"`
+	Event          string    `gorm:"size:30;not null" json:"event"`
+	Status         string    `gorm:"size:20;not null" json:"status"`
+	Reason         string    `gorm:"type:text" json:"reason,omitempty"`
+}
+
+func (AutomationTriggerRun) TableName() string {
+	return "automation_trigger_runs"
+}
+
+// AutomationSegmentMember is a contact currently in a segment trigger's segment, so
+// the next evaluation can tell who entered and who exited
+type AutomationSegmentMember struct {
+	TriggerID uuid.UUID `gorm:"type:uuid;primaryKey" json:"trigger_id"`
+	ContactID uuid.UUID `gorm:"type:uuid;primaryKey" json:"contact_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (AutomationSegmentMember) TableName() string {
+	return "automation_segment_members"
+}
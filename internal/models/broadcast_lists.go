@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// MaxBroadcastListMembers caps the size of a broadcast list; larger audiences should
+// use a campaign
+const MaxBroadcastListMembers = 256
+
+// BroadcastList is a small named audience an agent keeps for sending a template
+// ad-hoc. Each send is run by the worker as a regular campaign.
+type BroadcastList struct {
+	BaseModel
+	OrganizationID  uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string    `gorm:"size:100;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
+	Name            string    `gorm:"size:255;not null" json:"name"`
+	Description     string    `gorm:"type:text" json:"description"`
+	OwnerID         uuid.UUID `gorm:"type:uuid;index;not null" json:"owner_id"`
+	MemberCount     int       `gorm:"default:0" json:"member_count"`
+
+	// Relations
+	Owner *User `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
+}
+
+func (BroadcastList) TableName() string {
+	return "broadcast_lists"
+}
+
+// BroadcastListMember adds a contact to a broadcast list. Removing a member deletes
+// the row.
+type BroadcastListMember struct {
+	BaseModel
+	ListID    uuid.UUID `gorm:"type:uuid;index;not null" json:"list_id"`
+	ContactID uuid.UUID `gorm:"type:uuid;index;not null" json:"contact_id"`
+
+	// Relations
+	Contact *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+func (BroadcastListMember) TableName() string {
+	return "broadcast_list_members"
+}
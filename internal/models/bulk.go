@@ -1,6 +1,9 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,7 +16,7 @@ type BulkMessageCampaign struct {
 	WhatsAppAccount string     `gorm:"size:100;index;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
 	Name            string     `gorm:"size:255;not null" json:"name"`
 	TemplateID      uuid.UUID  `gorm:"type:uuid;not null" json:"template_id"`
-	Status          string     `gorm:"size:20;default:'draft'" json:"status"` // draft, queued, processing, completed, failed
+	Status          string     `gorm:"size:20;default:'draft'" json:"status"` // draft, scheduled, pending_template, queued, processing, held, paused, completed, cancelled, failed
 	TotalRecipients int        `gorm:"default:0" json:"total_recipients"`
 	SentCount       int        `gorm:"default:0" json:"sent_count"`
 	DeliveredCount  int        `gorm:"default:0" json:"delivered_count"`
@@ -23,18 +26,72 @@ type BulkMessageCampaign struct {
 	StartedAt       *time.Time `json:"started_at,omitempty"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 	CreatedBy       uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	RecipientCursor *uuid.UUID `gorm:"type:uuid" json:"-"` // Last recipient ID processed by the worker, used to resume
+	DateTriggerID   *uuid.UUID `gorm:"type:uuid;index" json:"date_trigger_id,omitempty"`
+	BroadcastListID *uuid.UUID `gorm:"type:uuid;index" json:"broadcast_list_id,omitempty"`
+
+	// Set on the occurrences a recurring campaign creates
+	RecurringCampaignID *uuid.UUID `gorm:"type:uuid;index" json:"recurring_campaign_id,omitempty"`
+
+	// Local times of day recipients are sent to, in their contact's timezone or else the
+	// organization's; empty sends at any time. The window can wrap past midnight.
+	SendWindowStart string     `gorm:"size:5" json:"send_window_start"` // HH:MM
+	SendWindowEnd   string     `gorm:"size:5" json:"send_window_end"`   // HH:MM
+	ResumeAt        *time.Time `json:"resume_at,omitempty"`              // Set while every recipient left is waiting for their window, or the campaign is held
+
+	// Why a held campaign is waiting, e.g. the organization's quiet hours; it continues at ResumeAt
+	HeldReason string `gorm:"size:255" json:"held_reason,omitempty"`
+
+	// Recipients the worker sends to at once; 0 uses the account's CampaignConcurrency
+	Concurrency int `gorm:"default:0" json:"concurrency"`
+
+	// Messages a minute the campaign sends at most, below its account's throughput, so
+	// a large blast can be slowed while other campaigns on the account run at full
+	// speed; 0 sends as fast as the account allows
+	MaxMessagesPerMinute int `gorm:"default:0" json:"max_messages_per_minute"`
+
+	// Workers take queued campaigns of a higher priority first, so transactional
+	// campaigns don't wait behind large marketing blasts
+	Priority string `gorm:"size:10;default:'normal'" json:"priority"` // high, normal, low
+
+	// What happens to recipients missing a template parameter
+	MissingParamPolicy string `gorm:"size:20;default:'send'" json:"missing_param_policy"` // send, skip, default
+	ParamDefaults      JSONB  `gorm:"type:jsonb;default:'{}'" json:"param_defaults"`      // Values used by the default policy, keyed by parameter
 
 	// Relations
 	Organization *Organization          `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 	Template     *Template              `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
 	Creator      *User                  `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
 	Recipients   []BulkMessageRecipient `gorm:"foreignKey:CampaignID" json:"recipients,omitempty"`
+	Variants     []CampaignVariant      `gorm:"foreignKey:CampaignID" json:"variants,omitempty"`
 }
 
 func (BulkMessageCampaign) TableName() string {
 	return "bulk_message_campaigns"
 }
 
+// MaxCampaignConcurrency caps the recipients a campaign sends to at once
+const MaxCampaignConcurrency = 50
+
+// MaxMessagesPerSecond caps an account's campaign throughput, at Meta's highest
+const MaxMessagesPerSecond = 1000
+
+// MaxCampaignMessagesPerMinute caps a campaign's own throttle, at the highest
+// account throughput
+const MaxCampaignMessagesPerMinute = MaxMessagesPerSecond * 60
+
+// ValidCampaignConcurrency reports whether n is a valid campaign or account
+// concurrency, where 0 inherits the next setting
+func ValidCampaignConcurrency(n int) bool {
+	return n >= 0 && n <= MaxCampaignConcurrency
+}
+
+// ValidCampaignMessagesPerMinute reports whether n is a valid campaign throttle,
+// where 0 leaves the campaign unthrottled
+func ValidCampaignMessagesPerMinute(n int) bool {
+	return n >= 0 && n <= MaxCampaignMessagesPerMinute
+}
+
 // BulkMessageRecipient represents a recipient in a bulk message campaign
 type BulkMessageRecipient struct {
 	BaseModel
@@ -42,13 +99,20 @@ type BulkMessageRecipient struct {
 	PhoneNumber        string     `gorm:"size:20;not null" json:"phone_number"`
 	RecipientName      string     `gorm:"size:255" json:"recipient_name"`
 	TemplateParams     JSONB      `gorm:"type:jsonb;default:'{}'" json:"template_params"`
-	Status             string     `gorm:"size:20;default:'pending'" json:"status"` // pending, sent, delivered, read, failed
+	Status             string     `gorm:"size:20;default:'pending'" json:"status"` // pending, sent, delivered, read, failed, skipped, cancelled
 	WhatsAppMessageID  string     `gorm:"column:whats_app_message_id;size:100;index" json:"whatsapp_message_id,omitempty"`
 	MessageID          *uuid.UUID `gorm:"type:uuid" json:"message_id,omitempty"`
 	ErrorMessage       string     `gorm:"type:text" json:"error_message"`
 	SentAt             *time.Time `json:"sent_at,omitempty"`
 	DeliveredAt        *time.Time `json:"delivered_at,omitempty"`
 	ReadAt             *time.Time `json:"read_at,omitempty"`
+	DeferredUntil      *time.Time `json:"deferred_until,omitempty"` // Pending until the campaign's send window opens for the recipient
+	ClaimedBy          string     `gorm:"size:100" json:"-"` // Worker sending to the recipient, cleared once it's done
+	ClaimedAt          *time.Time `json:"-"`
+	Attempts           int        `gorm:"not null;default:0" json:"attempts"` // Sends made to the recipient, across retries
+	ErrorCode          int        `gorm:"not null;default:0" json:"error_code,omitempty"` // Meta error code of the failure, 0 when it had none
+	ErrorCategory      string     `gorm:"size:20;index" json:"error_category,omitempty"` // invalid_number, policy, rate_limit or unknown
+	Variant            string     `gorm:"size:20" json:"variant,omitempty"` // Name of the A/B variant sent, for split campaigns
 
 	// Relations
 	Campaign *BulkMessageCampaign `gorm:"foreignKey:CampaignID" json:"campaign,omitempty"`
@@ -59,6 +123,15 @@ func (BulkMessageRecipient) TableName() string {
 	return "bulk_message_recipients"
 }
 
+// IdempotencyKey identifies the recipient's current send attempt by the sends made to
+// it so far. It is stored on the campaign message saved before sending, so
+// reprocessing the recipient never sends the same attempt twice, while retrying a
+// failed recipient, whose failed send was counted, gets a new key.
+func (r *BulkMessageRecipient) IdempotencyKey() string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("campaign:%s:recipient:%s:attempt:%d", r.CampaignID, r.ID, r.Attempts)))
+	return hex.EncodeToString(sum[:])
+}
+
 // NotificationRule defines automated notification rules
 type NotificationRule struct {
 	BaseModel
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// What a dry run found each recipient would get
+const (
+	DryRunSend  = "send"  // Sent the message shown
+	DryRunDefer = "defer" // Sent the message shown once their send window opens
+	DryRunSkip  = "skip"  // Skipped for missing template parameters
+	DryRunFail  = "fail"  // Failed validation, so the send would fail
+)
+
+// CampaignDryRun is a run of a campaign's sending pipeline for its pending recipients
+// that sends nothing to Meta, reporting what each would be sent or why it would be
+// skipped or fail, as a CampaignDryRunRow per recipient. Only a campaign's latest dry run is kept.
+type CampaignDryRun struct {
+	BaseModel
+	OrganizationID uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	CampaignID     uuid.UUID   `gorm:"type:uuid;index;not null" json:"campaign_id"`
+	Status         string      `gorm:"size:20;default:'queued'" json:"status"` // queued, running, completed, failed
+	Total          int         `gorm:"default:0" json:"total"`
+	WillSend       int         `gorm:"default:0" json:"will_send"` // Including those deferred to their send window
+	Deferred       int         `gorm:"default:0" json:"deferred"`
+	Skipped        int         `gorm:"default:0" json:"skipped"`
+	Failed         int         `gorm:"default:0" json:"failed"`
+	NewContacts    int         `gorm:"default:0" json:"new_contacts"`           // Contacts sending would create
+	Warnings       StringArray `gorm:"type:jsonb;default:'[]'" json:"warnings"` // About the campaign as a whole, e.g. an unapproved template
+	Error          string      `gorm:"type:text" json:"error,omitempty"`        // Why the dry run itself failed
+	JobID          *uuid.UUID  `gorm:"type:uuid" json:"job_id,omitempty"`       // Tracks the dry run's progress
+	CreatedByID    *uuid.UUID  `gorm:"type:uuid" json:"created_by_id,omitempty"`
+	CompletedAt    *time.Time  `json:"completed_at,omitempty"`
+}
+
+func (CampaignDryRun) TableName() string {
+	return "campaign_dry_runs"
+}
+
+// CampaignDryRunRow is what a dry run found one recipient would get
+type CampaignDryRunRow struct {
+	ID             int64           `gorm:"primaryKey;autoIncrement" json:"-"` // Send order
+	DryRunID       uuid.UUID       `gorm:"type:uuid;index;not null" json:"-"`
+	DryRun         *CampaignDryRun `gorm:"foreignKey:DryRunID;constraint:OnDelete:CASCADE" json:"-"` // Rows go with their dry run
+	RecipientID    uuid.UUID       `gorm:"type:uuid;not null" json:"recipient_id"`
+	PhoneNumber    string          `gorm:"size:20" json:"phone_number"`
+	RecipientName  string          `gorm:"size:255" json:"recipient_name,omitempty"`
+	Outcome        string          `gorm:"size:20;not null" json:"outcome"` // send, defer, skip, fail
+	Variant        string          `gorm:"size:20" json:"variant,omitempty"`
+	TemplateName   string          `gorm:"size:255" json:"template_name,omitempty"`
+	Language       string          `gorm:"size:20" json:"language,omitempty"`
+	TemplateParams JSONB           `gorm:"type:jsonb" json:"template_params,omitempty"`
+	Body           string          `gorm:"type:text" json:"body,omitempty"`
+	NewContact     bool            `gorm:"default:false" json:"new_contact,omitempty"` // A contact would be created for the number
+	SendAt         *time.Time      `json:"send_at,omitempty"`                          // When a deferred recipient's window opens
+	Error          string          `gorm:"type:text" json:"error,omitempty"`           // Why the recipient would be skipped or fail
+}
+
+func (CampaignDryRunRow) TableName() string {
+	return "campaign_dry_run_rows"
+}
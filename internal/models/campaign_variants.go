@@ -0,0 +1,51 @@
+package models
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// MaxCampaignVariants caps the templates a campaign splits its recipients between
+const MaxCampaignVariants = 5
+
+// CampaignVariant is one template in a campaign's A/B split. Percent of the recipients
+// are sent its template instead of the campaign's.
+type CampaignVariant struct {
+	BaseModel
+	CampaignID uuid.UUID `gorm:"type:uuid;index;not null" json:"campaign_id"`
+	Name       string    `gorm:"size:20;not null" json:"name"` // e.g. A, B
+	TemplateID uuid.UUID `gorm:"type:uuid;not null" json:"template_id"`
+	Percent    int       `gorm:"not null" json:"percent"`
+	Position   int       `gorm:"not null;default:0" json:"position"`
+
+	// Relations
+	Template *Template `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
+}
+
+func (CampaignVariant) TableName() string {
+	return "campaign_variants"
+}
+
+// PickCampaignVariant returns the variant a recipient is sent, from variants in
+// position order, or nil when the campaign isn't split. The choice depends only on the
+// campaign and the phone number, so a recipient gets the same variant on every run
+// and retry.
+func PickCampaignVariant(campaignID uuid.UUID, phoneNumber string, variants []CampaignVariant) *CampaignVariant {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	h := fnv.New32a()
+	h.Write(campaignID[:])
+	h.Write([]byte(strings.TrimPrefix(phoneNumber, "+")))
+	bucket := int(h.Sum32() % 100)
+	for i := range variants {
+		bucket -= variants[i].Percent
+		if bucket < 0 {
+			return &variants[i]
+		}
+	}
+	return &variants[len(variants)-1]
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Change stream sinks
+const (
+	ChangeStreamRedis = "redis" // Redis stream, XADD
+	ChangeStreamKafka = "kafka" // Kafka topic, through a Kafka REST Proxy
+)
+
+// ChangeStream is where an organization's change events are published for data
+// pipelines. Events are captured by database triggers into change_events and
+// published in order by the change stream relay.
+type ChangeStream struct {
+	BaseModel
+	OrganizationID uuid.UUID   `gorm:"type:uuid;not null" json:"organization_id"`
+	IsEnabled      bool        `gorm:"default:true" json:"is_enabled"`
+	Sink           string      `gorm:"size:20;not null" json:"sink"`
+	Target         string      `gorm:"size:255;not null" json:"target"`           // Redis stream key or Kafka topic
+	Events         StringArray `gorm:"type:jsonb;default:'[]'" json:"events"`     // Event types to publish, empty = all
+	MaxLen         int64       `gorm:"default:0" json:"max_len"`                  // Approximate Redis stream length cap, 0 = default
+	RedisURL       string      `gorm:"type:text" json:"-"`                        // Empty publishes to the platform's Redis
+	KafkaRESTURL   string      `gorm:"type:text" json:"kafka_rest_url,omitempty"` // e.g. "https://kafka-rest.example.com"
+	KafkaUsername  string      `gorm:"size:255" json:"kafka_username,omitempty"`
+	KafkaPassword  string      `gorm:"type:text" json:"-"`
+
+	PublishedCount  int64      `gorm:"default:0" json:"published_count"`
+	LastPublishedAt *time.Time `json:"last_published_at,omitempty"`
+	LastError       string     `gorm:"type:text" json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+}
+
+func (ChangeStream) TableName() string {
+	return "change_streams"
+}
+
+// ChangeEvent is a change waiting to be published. Rows are written by triggers on
+// the tables being captured (see changestream.Triggers) and deleted once published.
+type ChangeEvent struct {
+	ID             int64     `gorm:"primaryKey;autoIncrement" json:"sequence"` // Publish order
+	EventID        uuid.UUID `gorm:"type:uuid;not null;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Type           string    `gorm:"size:50;not null" json:"type"`
+	SchemaVersion  int       `gorm:"not null" json:"schema_version"`
+	EntityID       uuid.UUID `gorm:"type:uuid;not null" json:"entity_id"`
+	Data           JSONB     `gorm:"type:jsonb;not null" json:"data"`
+	OccurredAt     time.Time `gorm:"not null;default:now()" json:"occurred_at"`
+}
+
+func (ChangeEvent) TableName() string {
+	return "change_events"
+}
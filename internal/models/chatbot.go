@@ -52,6 +52,11 @@ type ChatbotSettings struct {
 	AIIncludeHistory     bool        `gorm:"column:ai_include_history;default:true" json:"ai_include_history"`
 	AIHistoryLimit       int         `gorm:"column:ai_history_limit;default:4" json:"ai_history_limit"`
 	SessionTimeoutMins   int         `gorm:"default:30" json:"session_timeout_minutes"`
+	SessionNudgeMinutes  int         `gorm:"default:0" json:"session_nudge_minutes"`        // Nudge a contact this long inactive in a flow; 0 disables it
+	SessionNudgeMessage  string      `gorm:"type:text" json:"session_nudge_message"`        // e.g. "Still there?"
+	SessionTimeoutHandoff bool       `gorm:"default:false" json:"session_timeout_handoff"` // Hand a flow that times out to the agent queue with a summary
+	BotMessageLimit      int         `gorm:"default:20" json:"bot_message_limit"`           // Most automated messages a contact is sent per window; 0 disables the guard
+	BotMessageWindowMins int         `gorm:"default:5" json:"bot_message_window_minutes"`
 	ExcludedNumbers      JSONBArray  `gorm:"type:jsonb;default:'[]'" json:"excluded_numbers"`
 
 	// Relations
@@ -105,6 +110,8 @@ type ChatbotFlow struct {
 	CompletionConfig   JSONB       `gorm:"type:jsonb" json:"completion_config"`
 	TimeoutMessage     string      `gorm:"type:text" json:"timeout_message"`
 	CancelKeywords     StringArray `gorm:"type:jsonb" json:"cancel_keywords"`
+	Variables          JSONBArray  `gorm:"type:jsonb" json:"variables"` // [{name, type, default}] - typed variables for expressions
+	PublishedVersion   int         `gorm:"default:0" json:"published_version"` // Version new sessions run; 0 runs the flow as edited
 
 	// Relations
 	Organization    *Organization     `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -123,9 +130,9 @@ type ChatbotFlowStep struct {
 	StepName        string     `gorm:"size:100;not null" json:"step_name"`
 	StepOrder       int        `gorm:"not null" json:"step_order"`
 	Message         string     `gorm:"type:text;not null" json:"message"`
-	MessageType     string     `gorm:"size:20;default:'text'" json:"message_type"` // text, template, script, api_fetch, buttons, transfer
+	MessageType     string     `gorm:"size:20;default:'text'" json:"message_type"` // text, template, script, api_fetch, buttons, transfer, condition, http_request
 	TemplateID      *uuid.UUID `gorm:"type:uuid" json:"template_id,omitempty"`
-	ApiConfig       JSONB      `gorm:"type:jsonb" json:"api_config"`      // {url, method, headers, body, response_path, fallback_message}
+	ApiConfig       JSONB      `gorm:"type:jsonb" json:"api_config"`      // {url, method, headers, body, response_path, fallback_message}; http_request also credential, response_mapping, error_step, timeout_seconds
 	Buttons         JSONBArray `gorm:"type:jsonb" json:"buttons"`         // [{id, title}] - max 10 options (3=buttons, 4-10=list)
 	TransferConfig  JSONB      `gorm:"type:jsonb" json:"transfer_config"` // {team_id: uuid, notes: string} - for transfer message type
 	InputType       string     `gorm:"size:20" json:"input_type"`         // none, text, number, email, phone, date, select, button, whatsapp_flow
@@ -136,6 +143,8 @@ type ChatbotFlowStep struct {
 	NextStep        string     `gorm:"size:100" json:"next_step"`
 	ConditionalNext JSONB      `gorm:"type:jsonb" json:"conditional_next"` // {"option1": "step_a", "default": "step_b"}
 	SkipCondition   string     `gorm:"type:text" json:"skip_condition"`
+	Branches        JSONBArray `gorm:"type:jsonb" json:"branches"`    // [{if, next}] - for condition message type; the first true expression wins
+	Assignments     JSONBArray `gorm:"type:jsonb" json:"assignments"` // [{variable, expr}] - evaluated in order when the step is entered
 	RetryOnInvalid  bool       `gorm:"default:true" json:"retry_on_invalid"`
 	MaxRetries      int        `gorm:"default:3" json:"max_retries"`
 
@@ -158,10 +167,12 @@ type ChatbotSession struct {
 	Status          string     `gorm:"size:20;default:'active'" json:"status"` // active, completed, cancelled, timeout
 	CurrentFlowID   *uuid.UUID `gorm:"type:uuid" json:"current_flow_id,omitempty"`
 	CurrentStep     string     `gorm:"size:100" json:"current_step"`
+	FlowVersion     int        `gorm:"default:0" json:"flow_version"` // Published version of the current flow the session started on
 	StepRetries     int        `gorm:"default:0" json:"step_retries"`
 	SessionData     JSONB      `gorm:"type:jsonb;default:'{}'" json:"session_data"`
 	StartedAt       time.Time  `gorm:"autoCreateTime" json:"started_at"`
 	LastActivityAt  time.Time  `json:"last_activity_at"`
+	NudgedAt        *time.Time `json:"nudged_at,omitempty"` // When the inactivity nudge was sent; cleared by activity
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 
 	// Relations
@@ -220,7 +231,7 @@ type AgentTransfer struct {
 	WhatsAppAccount     string     `gorm:"size:100;index;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
 	PhoneNumber         string     `gorm:"size:20;not null" json:"phone_number"`
 	Status              string     `gorm:"size:20;default:'active'" json:"status"` // active, resumed
-	Source              string     `gorm:"size:20;default:'manual'" json:"source"` // manual, flow, keyword, chatbot_disabled
+	Source              string     `gorm:"size:20;default:'manual'" json:"source"` // manual, flow, keyword, chatbot_disabled, flow_timeout
 	AgentID             *uuid.UUID `gorm:"type:uuid" json:"agent_id,omitempty"`
 	TeamID              *uuid.UUID `gorm:"type:uuid;index" json:"team_id,omitempty"` // Team queue (null = general queue)
 	TransferredByUserID *uuid.UUID `gorm:"type:uuid" json:"transferred_by_user_id,omitempty"` // User who initiated the transfer (null for system)
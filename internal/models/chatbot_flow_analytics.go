@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Chatbot flow events counted in ChatbotFlowDailyRollup
+const (
+	FlowEventStarted   = "started"   // A session started the flow
+	FlowEventEntered   = "entered"   // A session reached the step; skipped steps aren't entered
+	FlowEventChosen    = "chosen"    // A button was chosen at the step; the value is its ID
+	FlowEventCompleted = "completed" // The flow completed after the step
+	FlowEventExited    = "exited"    // The flow ended early at the step; the value is why
+)
+
+// Reasons a flow is exited early
+const (
+	FlowExitCancelled    = "cancelled"     // The contact sent a cancel keyword
+	FlowExitTimeout      = "timeout"       // The contact stopped replying
+	FlowExitMaxRetries   = "max_retries"   // The contact ran out of retries for an invalid answer
+	FlowExitTransferred  = "transferred"   // A transfer step handed the contact to agents
+	FlowExitError        = "error"         // The flow or its current step could no longer be found
+	FlowExitMessageLimit = "message_limit" // The contact reached the automated message limit
+)
+
+// ChatbotFlowDailyRollup counts a day's events for a flow version, per step. Rows are
+// incremented as sessions move through flows.
+type ChatbotFlowDailyRollup struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	FlowID         uuid.UUID `gorm:"type:uuid;not null" json:"flow_id"`
+	FlowVersion    int       `gorm:"not null;default:0" json:"flow_version"` // 0 for sessions on a flow never published
+	Date           time.Time `gorm:"type:date;not null" json:"date"`
+	StepName       string    `gorm:"size:100;not null;default:''" json:"step_name"` // Empty for FlowEventStarted
+	Event          string    `gorm:"size:20;not null" json:"event"`
+	Value          string    `gorm:"size:100;not null;default:''" json:"value"` // Button ID or exit reason
+	Count          int64     `gorm:"not null;default:0" json:"count"`
+}
+
+func (ChatbotFlowDailyRollup) TableName() string {
+	return "chatbot_flow_daily_rollups"
+}
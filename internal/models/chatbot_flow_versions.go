@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// ChatbotFlowVersion is a published snapshot of a chatbot flow and its steps. Edits to
+// a flow are a draft until published; sessions keep running the version they started on.
+type ChatbotFlowVersion struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	FlowID         uuid.UUID  `gorm:"type:uuid;index;not null" json:"flow_id"`
+	Version        int        `gorm:"not null" json:"version"`                         // 1 for the first publish, counting up
+	Definition     JSONB      `gorm:"type:jsonb;not null" json:"definition,omitempty"` // The flow with its steps as published
+	Notes          string     `gorm:"type:text" json:"notes"`
+	PublishedByID  *uuid.UUID `gorm:"type:uuid" json:"published_by_id,omitempty"`
+}
+
+func (ChatbotFlowVersion) TableName() string {
+	return "chatbot_flow_versions"
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Conversation share statuses
+const (
+	ConversationSharePending  = "pending"  // Waiting for the receiving organization
+	ConversationShareAccepted = "accepted" // Contact created in the receiving organization
+	ConversationShareDeclined = "declined"
+	ConversationShareRevoked  = "revoked" // Withdrawn by the sharing organization; the snapshot is erased
+)
+
+// ConversationShare hands a snapshot of a conversation to another organization, e.g.
+// an agency escalating a customer to the brand's own team. The receiving organization
+// must list the sharing one as a partner and accept each share. Who shared, accepted,
+// declined or revoked it and when is kept as an audit trail.
+type ConversationShare struct {
+	BaseModel
+	SourceOrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"source_organization_id"`
+	SourceContactID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"source_contact_id"`
+	TargetOrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"target_organization_id"`
+	TargetContactID      *uuid.UUID `gorm:"type:uuid" json:"target_contact_id,omitempty"` // Set when accepted
+	Status               string     `gorm:"size:20;not null" json:"status"`
+	Note                 string     `gorm:"type:text" json:"note"`                             // Context for the receiving team
+	CustomerConsent      bool       `gorm:"not null;default:false" json:"customer_consent"`    // The customer agreed to the handover
+	Snapshot             JSONB      `gorm:"type:jsonb;default:'{}'" json:"snapshot,omitempty"` // Contact details and recent messages
+	SharedByID           uuid.UUID  `gorm:"type:uuid;not null" json:"shared_by_id"`
+	RespondedByID        *uuid.UUID `gorm:"type:uuid" json:"responded_by_id,omitempty"`
+	RespondedAt          *time.Time `json:"responded_at,omitempty"`
+	RevokedByID          *uuid.UUID `gorm:"type:uuid" json:"revoked_by_id,omitempty"`
+	RevokedAt            *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (ConversationShare) TableName() string {
+	return "conversation_shares"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CustomDomain is an organization's own hostname for platform-generated links and
+// pages (short links, opt-out pages, preference centers). It is only used once a
+// CNAME to the server's custom domain target has been verified.
+type CustomDomain struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null" json:"organization_id"`
+	Domain         string     `gorm:"size:253;not null" json:"domain"` // Lowercase hostname, e.g. "links.acme.com"
+	IsVerified     bool       `gorm:"default:false" json:"is_verified"`
+	VerifiedAt     *time.Time `json:"verified_at,omitempty"`
+	LastCheckedAt  *time.Time `json:"last_checked_at,omitempty"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+func (CustomDomain) TableName() string {
+	return "custom_domains"
+}
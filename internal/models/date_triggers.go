@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// DateTrigger sends a template to contacts whose date attribute (a birthday,
+// renewal date, etc. stored in contact metadata as YYYY-MM-DD) falls on today,
+// shifted by an offset. Each day's matches are sent as a regular campaign.
+type DateTrigger struct {
+	BaseModel
+	OrganizationID  uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string    `gorm:"size:100;not null" json:"whatsapp_account"`
+	Name            string    `gorm:"size:255;not null" json:"name"`
+	IsEnabled       bool      `gorm:"default:true" json:"is_enabled"`
+	DateField       string    `gorm:"size:100;not null" json:"date_field"` // Contact metadata key
+	Annual          bool      `json:"annual"`                              // Match month and day only, e.g. birthdays
+	OffsetDays      int       `json:"offset_days"`                         // Send this many days before the date; negative sends after
+	SendHour        int       `json:"send_hour"`                           // Hour of day in the organization's timezone
+	TemplateID      uuid.UUID `gorm:"type:uuid;not null" json:"template_id"`
+	TemplateParams  JSONB     `gorm:"type:jsonb;default:'{}'" json:"template_params"` // "1" -> "{{name}}"
+	LastRunDate     string    `gorm:"size:10" json:"last_run_date,omitempty"`         // Local date of the last run
+	CreatedBy       uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+
+	// Relations
+	Template *Template `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
+}
+
+func (DateTrigger) TableName() string {
+	return "date_triggers"
+}
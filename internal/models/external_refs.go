@@ -0,0 +1,33 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// External reference entity types. A conversation is identified by its contact, so
+// conversation references point at a contact ID but are kept apart from the
+// contact's own references (e.g. a deal_id for the conversation, a crm_id for the
+// person).
+const (
+	ExternalRefContact      = "contact"
+	ExternalRefConversation = "conversation"
+	ExternalRefCampaign     = "campaign"
+)
+
+// ExternalReference links a contact, conversation or campaign to its ID in an
+// external system such as a CRM, so integrations can look records up by their own
+// IDs. A record has one value per key, and a value names one record per entity type
+// and key.
+type ExternalReference struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	EntityType     string     `gorm:"size:20;not null" json:"entity_type"` // contact, conversation, campaign
+	EntityID       uuid.UUID  `gorm:"type:uuid;not null" json:"entity_id"`
+	Key            string     `gorm:"size:50;not null" json:"key"` // e.g. crm_id, deal_id
+	Value          string     `gorm:"size:255;not null" json:"value"`
+	CreatedByID    *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
+}
+
+func (ExternalReference) TableName() string {
+	return "external_references"
+}
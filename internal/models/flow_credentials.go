@@ -0,0 +1,31 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Flow credential auth types
+const (
+	FlowCredentialBearer = "bearer" // Authorization: Bearer <secret>
+	FlowCredentialBasic  = "basic"  // Authorization: Basic <username:secret>
+	FlowCredentialHeader = "header" // <header_name>: <secret>
+)
+
+// FlowCredential is a secret chatbot flows' HTTP request steps authenticate with.
+// Steps refer to it by name so flows never contain the secret, and it is only sent
+// to URLs under its base URL.
+type FlowCredential struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string     `gorm:"size:100;not null" json:"name"`
+	AuthType       string     `gorm:"size:20;not null" json:"auth_type"` // bearer, basic, header
+	BaseURL        string     `gorm:"size:500;not null" json:"base_url"`
+	HeaderName     string     `gorm:"size:100" json:"header_name,omitempty"` // For header credentials
+	Username       string     `gorm:"size:255" json:"username,omitempty"`    // For basic credentials
+	Secret         string     `gorm:"type:text;not null" json:"-"`           // Never exposed in JSON
+	CreatedByID    *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
+}
+
+func (FlowCredential) TableName() string {
+	return "flow_credentials"
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HolidayCalendar is an iCal feed of public holidays imported for an organization.
+// Its events are materialised as CampaignBlackoutDates and refreshed periodically.
+type HolidayCalendar struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string     `gorm:"size:255;not null" json:"name"`
+	Region         string     `gorm:"size:50" json:"region"` // e.g. "IN", "US-CA"
+	URL            string     `gorm:"type:text;not null" json:"url"`
+	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	LastSyncedAt   *time.Time `json:"last_synced_at,omitempty"`
+	LastSyncError  string     `gorm:"type:text" json:"last_sync_error"`
+	EventCount     int        `gorm:"default:0" json:"event_count"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (HolidayCalendar) TableName() string {
+	return "holiday_calendars"
+}
+
+// CampaignBlackoutDate is a day on which campaigns do not send
+type CampaignBlackoutDate struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	CalendarID     *uuid.UUID `gorm:"type:uuid;index" json:"calendar_id,omitempty"` // Nil for manually added dates
+	Date           time.Time  `gorm:"type:date;not null" json:"date"`
+	Name           string     `gorm:"size:255" json:"name"`
+	Region         string     `gorm:"size:50" json:"region"`
+
+	// Relations
+	Calendar *HolidayCalendar `gorm:"foreignKey:CalendarID" json:"calendar,omitempty"`
+}
+
+func (CampaignBlackoutDate) TableName() string {
+	return "campaign_blackout_dates"
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Cached entity kinds reported to cache invalidators
+const (
+	CacheEntityWhatsAppAccount = "whatsapp_account"
+	CacheEntityTemplate        = "template"
+	CacheEntityOrganization    = "organization"
+)
+
+// CacheInvalidator is notified after a cached model is written or deleted.
+// orgID is uuid.Nil when the change was made without a loaded model (e.g. a
+// bulk update through Model(&X{}).Where(...)), in which case the entity must
+// be invalidated for every organization.
+type CacheInvalidator func(entity string, orgID uuid.UUID)
+
+var (
+	cacheInvalidatorsMu sync.RWMutex
+	cacheInvalidators   []CacheInvalidator
+)
+
+// RegisterCacheInvalidator adds a callback that runs after cached models change
+func RegisterCacheInvalidator(fn CacheInvalidator) {
+	cacheInvalidatorsMu.Lock()
+	defer cacheInvalidatorsMu.Unlock()
+	cacheInvalidators = append(cacheInvalidators, fn)
+}
+
+func notifyCacheInvalidators(entity string, orgID uuid.UUID) {
+	cacheInvalidatorsMu.RLock()
+	defer cacheInvalidatorsMu.RUnlock()
+	for _, fn := range cacheInvalidators {
+		fn(entity, orgID)
+	}
+}
+
+// AfterSave runs on create, save and update; it invalidates cached accounts for the organization
+func (w *WhatsAppAccount) AfterSave(tx *gorm.DB) error {
+	notifyCacheInvalidators(CacheEntityWhatsAppAccount, w.OrganizationID)
+	return nil
+}
+
+// AfterDelete invalidates cached accounts for the organization
+func (w *WhatsAppAccount) AfterDelete(tx *gorm.DB) error {
+	notifyCacheInvalidators(CacheEntityWhatsAppAccount, w.OrganizationID)
+	return nil
+}
+
+// AfterSave invalidates cached templates for the organization
+func (t *Template) AfterSave(tx *gorm.DB) error {
+	notifyCacheInvalidators(CacheEntityTemplate, t.OrganizationID)
+	return nil
+}
+
+// AfterDelete invalidates cached templates for the organization
+func (t *Template) AfterDelete(tx *gorm.DB) error {
+	notifyCacheInvalidators(CacheEntityTemplate, t.OrganizationID)
+	return nil
+}
+
+// AfterSave invalidates cached settings for the organization
+func (o *Organization) AfterSave(tx *gorm.DB) error {
+	messageAuditModes.Delete(o.ID)
+	notifyCacheInvalidators(CacheEntityOrganization, o.ID)
+	return nil
+}
+
+// AfterDelete invalidates cached settings for the organization
+func (o *Organization) AfterDelete(tx *gorm.DB) error {
+	notifyCacheInvalidators(CacheEntityOrganization, o.ID)
+	return nil
+}
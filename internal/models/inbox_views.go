@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Inbox view status filters
+const (
+	InboxViewStatusUnread = "unread"
+	InboxViewStatusRead   = "read"
+)
+
+// Inbox view assignee filters, besides a user ID
+const (
+	InboxViewAssigneeMe         = "me" // Whoever is using the view
+	InboxViewAssigneeUnassigned = "unassigned"
+)
+
+// Inbox view SLA filters, matched against the conversation's active transfer
+const (
+	InboxViewSLAOnTrack  = "on_track"
+	InboxViewSLAWarning  = "warning" // Escalated but not yet breached
+	InboxViewSLABreached = "breached"
+)
+
+// InboxView is a saved set of inbox filters. It is private to its owner unless shared
+// with a team, whose members can use it and subscribe to its counts.
+type InboxView struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	OwnerID        uuid.UUID  `gorm:"type:uuid;index;not null" json:"owner_id"`
+	TeamID         *uuid.UUID `gorm:"type:uuid;index" json:"team_id,omitempty"` // Shared with this team; null keeps it private
+	Name           string     `gorm:"size:100;not null" json:"name"`
+
+	// Filters; an empty one matches every conversation
+	Status          string      `gorm:"size:20" json:"status"`                    // unread, read
+	LabelIDs        StringArray `gorm:"type:jsonb;default:'[]'" json:"label_ids"` // Conversations with any of these labels
+	Assignee        string      `gorm:"size:36" json:"assignee"`                  // me, unassigned or a user ID
+	WhatsAppAccount string      `gorm:"size:100" json:"whatsapp_account"`         // References WhatsAppAccount.Name
+	SLAState        string      `gorm:"size:20" json:"sla_state"`                 // on_track, warning, breached
+}
+
+func (InboxView) TableName() string {
+	return "inbox_views"
+}
+
+// InboxViewSubscription makes the server push a view's conversation counts to a user
+// over WebSocket, for badges
+type InboxViewSubscription struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ViewID         uuid.UUID `gorm:"type:uuid;not null" json:"view_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (InboxViewSubscription) TableName() string {
+	return "inbox_view_subscriptions"
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Background job types
+const (
+	JobTypeReport          = "report"           // A report run; the reference is the ReportRun
+	JobTypeRecipientImport = "recipient_import" // Recipients added to a campaign; the reference is the campaign
+	JobTypeCampaignDryRun  = "campaign_dry_run" // A campaign run without sending; the reference is the CampaignDryRun
+)
+
+// Background job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// MaxJobErrors caps the item errors a job keeps; ErrorCount still counts them all
+const MaxJobErrors = 100
+
+// Job tracks a background operation, so whoever started it can follow its progress by
+// ID or over the WebSocket and fetch the result once it's done
+type Job struct {
+	BaseModel
+	OrganizationID uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Type           string      `gorm:"size:30;not null" json:"type"`
+	Status         string      `gorm:"size:20;default:'queued';index" json:"status"`
+	ReferenceID    *uuid.UUID  `gorm:"type:uuid;index" json:"reference_id,omitempty"` // What the job works on, per its type
+	Progress       int         `gorm:"default:0" json:"progress"`                     // Percent done, 0-100
+	Processed      int         `gorm:"default:0" json:"processed"`
+	Total          int         `gorm:"default:0" json:"total"`               // Items to process; 0 when not known up front
+	ResultURL      string      `gorm:"size:500" json:"result_url,omitempty"` // Where to fetch the result once completed
+	Result         JSONB       `gorm:"type:jsonb;default:'{}'" json:"result"`
+	Error          string      `gorm:"type:text" json:"error,omitempty"`      // Why the job failed
+	Errors         StringArray `gorm:"type:jsonb;default:'[]'" json:"errors"` // Items that failed, up to MaxJobErrors
+	ErrorCount     int         `gorm:"default:0" json:"error_count"`
+	CreatedByID    *uuid.UUID  `gorm:"type:uuid" json:"created_by_id,omitempty"`
+	StartedAt      *time.Time  `json:"started_at,omitempty"`
+	CompletedAt    *time.Time  `json:"completed_at,omitempty"`
+}
+
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// Finished reports whether the job completed or failed
+func (j *Job) Finished() bool {
+	return j.Status == JobCompleted || j.Status == JobFailed
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Label is an organization-defined label for conversations. Unlike contact tags,
+// which describe the person, labels describe what a conversation is about.
+type Label struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string    `gorm:"size:100;not null" json:"name"`
+	Color          string    `gorm:"size:20" json:"color"`
+	Description    string    `gorm:"type:text" json:"description"`
+}
+
+func (Label) TableName() string {
+	return "labels"
+}
+
+// ConversationLabel applies a label to a contact's conversation. Removing a label
+// soft-deletes the row so label reports still count it.
+type ConversationLabel struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"contact_id"`
+	LabelID        uuid.UUID  `gorm:"type:uuid;index;not null" json:"label_id"`
+	Source         string     `gorm:"size:20;not null" json:"source"` // manual, rule
+	AppliedByID    *uuid.UUID `gorm:"type:uuid" json:"applied_by_id,omitempty"`
+	RuleID         *uuid.UUID `gorm:"type:uuid" json:"rule_id,omitempty"`
+
+	// Relations
+	Label *Label `gorm:"foreignKey:LabelID" json:"label,omitempty"`
+}
+
+func (ConversationLabel) TableName() string {
+	return "conversation_labels"
+}
+
+// Label rule trigger types
+const (
+	LabelTriggerKeyword     = "keyword"      // Incoming message text matches a keyword
+	LabelTriggerAdReferral  = "ad_referral"  // Conversation started from a click-to-WhatsApp ad
+	LabelTriggerFlowOutcome = "flow_outcome" // A chatbot flow completed or was exited
+)
+
+// LabelRule applies a label automatically when its trigger matches
+type LabelRule struct {
+	BaseModel
+	OrganizationID  uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string      `gorm:"size:100" json:"whatsapp_account"` // Empty applies to all accounts
+	Name            string      `gorm:"size:255;not null" json:"name"`
+	LabelID         uuid.UUID   `gorm:"type:uuid;not null" json:"label_id"`
+	IsEnabled       bool        `gorm:"default:true" json:"is_enabled"`
+	TriggerType     string      `gorm:"size:20;not null" json:"trigger_type"` // keyword, ad_referral, flow_outcome
+	Keywords        StringArray `gorm:"type:jsonb;default:'[]'" json:"keywords"`
+	MatchType       string      `gorm:"size:20;default:'contains'" json:"match_type"` // exact, contains, starts_with, regex
+	CaseSensitive   bool        `gorm:"default:false" json:"case_sensitive"`
+	AdSourceIDs     StringArray `gorm:"type:jsonb;default:'[]'" json:"ad_source_ids"` // Empty matches any ad
+	FlowID          *uuid.UUID  `gorm:"type:uuid" json:"flow_id,omitempty"`           // Nil matches any flow
+	FlowOutcome     string      `gorm:"size:20" json:"flow_outcome"`                  // completed, exited; empty matches both
+
+	// Relations
+	Label *Label `gorm:"foreignKey:LabelID" json:"label,omitempty"`
+}
+
+func (LabelRule) TableName() string {
+	return "label_rules"
+}
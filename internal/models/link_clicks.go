@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LinkClick is a reported click on a link sent to a contact
+type LinkClick struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"contact_id"`
+	CampaignID     *uuid.UUID `gorm:"type:uuid;index" json:"campaign_id,omitempty"` // Set when the reporter knows which campaign sent the link
+	URL            string     `gorm:"type:text;not null" json:"url"`
+	ClickedAt      time.Time  `gorm:"not null" json:"clicked_at"`
+}
+
+func (LinkClick) TableName() string {
+	return "link_clicks"
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Message timeline events
+const (
+	MessageEventReceived  = "received" // Incoming message saved
+	MessageEventQueued    = "queued"   // Outgoing message saved before sending
+	MessageEventSent      = "sent"
+	MessageEventDelivered = "delivered"
+	MessageEventRead      = "read"
+	MessageEventFailed    = "failed"
+	MessageEventRetried   = "retried" // Failed campaign message queued to send again
+)
+
+// Where a message event was observed
+const (
+	MessageEventSourceApp     = "app"     // The app saved or updated the message
+	MessageEventSourceWebhook = "webhook" // Meta reported it in a status webhook
+)
+
+// MessageEventDetails is the GORM setting a status update can carry to fill in the
+// event its message records, e.g. the time and error code of a status webhook:
+//
+//	db.Set(models.MessageEventDetails, models.MessageEvent{...}).Model(&message).Updates(...)
+const MessageEventDetails = "message_event_details"
+
+// MessageEvent is one state transition of a message, for its delivery timeline
+type MessageEvent struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"-"`
+	MessageID      uuid.UUID `gorm:"type:uuid;index;not null" json:"message_id"`
+	Event          string    `gorm:"size:20;not null" json:"event"`
+	Source         string    `gorm:"size:20;not null" json:"source"`
+	ErrorCode      int       `gorm:"default:0" json:"error_code,omitempty"` // Meta's error code, for failures reported by webhook
+	ErrorMessage   string    `gorm:"type:text" json:"error_message,omitempty"`
+	OccurredAt     time.Time `gorm:"not null" json:"occurred_at"` // When it happened, per Meta for webhook events
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (MessageEvent) TableName() string {
+	return "message_events"
+}
+
+// messageEventForStatus returns the event of a message moving to status, or "" for
+// statuses that aren't tracked
+func messageEventForStatus(status string) string {
+	switch status {
+	case "", "pending":
+		return MessageEventQueued
+	case MessageEventSent, MessageEventDelivered, MessageEventRead, MessageEventFailed:
+		return status
+	}
+	return ""
+}
+
+// AfterCreate starts the message's timeline and appends outgoing messages to the
+// audit log. Both run in the message's transaction.
+func (m *Message) AfterCreate(tx *gorm.DB) error {
+	event := MessageEventReceived
+	if m.Direction == "outgoing" {
+		event = messageEventForStatus(m.Status)
+	}
+	if event != "" {
+		if err := m.recordEvent(tx, event, m.ErrorMessage); err != nil {
+			return err
+		}
+	}
+	return m.appendToAuditLog(tx)
+}
+
+// AfterUpdate adds an event to the timeline when an update sets the message's
+// status. Updates of many messages at once don't load them, so they record their
+// own events.
+func (m *Message) AfterUpdate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil || m.OrganizationID == uuid.Nil {
+		return nil
+	}
+	updates, ok := tx.Statement.Dest.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	status, _ := updates["status"].(string)
+	if status == "" {
+		return nil
+	}
+	event := messageEventForStatus(status)
+	if event == "" {
+		return nil
+	}
+	errorMessage, _ := updates["error_message"].(string)
+	return m.recordEvent(tx, event, errorMessage)
+}
+
+// recordEvent adds an event to the message's timeline, filled in from the
+// MessageEventDetails setting when the statement carries one
+func (m *Message) recordEvent(tx *gorm.DB, event, errorMessage string) error {
+	record := MessageEvent{
+		OrganizationID: m.OrganizationID,
+		MessageID:      m.ID,
+		Event:          event,
+		Source:         MessageEventSourceApp,
+		ErrorMessage:   errorMessage,
+		OccurredAt:     time.Now(),
+	}
+	if v, ok := tx.Get(MessageEventDetails); ok {
+		if details, ok := v.(MessageEvent); ok {
+			if details.Source != "" {
+				record.Source = details.Source
+			}
+			if !details.OccurredAt.IsZero() {
+				record.OccurredAt = details.OccurredAt
+			}
+			if details.ErrorCode != 0 {
+				record.ErrorCode = details.ErrorCode
+			}
+			if details.ErrorMessage != "" {
+				record.ErrorMessage = details.ErrorMessage
+			}
+		}
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).Create(&record).Error
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // JSONB is a custom type for PostgreSQL JSONB columns
@@ -87,9 +88,10 @@ type BaseModel struct {
 // Organization represents a tenant in the multi-tenant system
 type Organization struct {
 	BaseModel
-	Name     string `gorm:"size:255;not null" json:"name"`
-	Slug     string `gorm:"size:100;uniqueIndex;not null" json:"slug"`
-	Settings JSONB  `gorm:"type:jsonb;default:'{}'" json:"settings"`
+	Name      string     `gorm:"size:255;not null" json:"name"`
+	Slug      string     `gorm:"size:100;uniqueIndex;not null" json:"slug"`
+	Settings  JSONB      `gorm:"type:jsonb;default:'{}'" json:"settings"`
+	PartnerID *uuid.UUID `gorm:"type:uuid;index" json:"partner_id,omitempty"` // Agency or reseller managing this organization
 
 	// Relations
 	Users            []User            `gorm:"foreignKey:OrganizationID" json:"users,omitempty"`
@@ -111,6 +113,7 @@ type User struct {
 	Settings       JSONB     `gorm:"type:jsonb;default:'{}'" json:"settings"`
 	IsActive       bool      `gorm:"default:true" json:"is_active"`
 	IsAvailable    bool      `gorm:"default:true" json:"is_available"` // Agent availability status (away/available)
+	Locale         string    `gorm:"size:10;default:'en'" json:"locale"`
 
 	// SSO fields
 	SSOProvider   string `gorm:"size:50" json:"sso_provider,omitempty"`    // google, microsoft, github, facebook, custom
@@ -179,14 +182,17 @@ func (TeamMember) TableName() string {
 // APIKey represents an API key for programmatic access
 type APIKey struct {
 	BaseModel
-	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
-	UserID         uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"` // Creator
-	Name           string     `gorm:"size:255;not null" json:"name"`
-	KeyPrefix      string     `gorm:"size:8;index" json:"key_prefix"` // First 8 chars for identification
-	KeyHash        string     `gorm:"size:255;not null" json:"-"`     // bcrypt hash of full key
-	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
-	ExpiresAt      *time.Time `json:"expires_at,omitempty"` // null = never expires
-	IsActive       bool       `gorm:"default:true" json:"is_active"`
+	OrganizationID uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	UserID         uuid.UUID   `gorm:"type:uuid;index;not null" json:"user_id"` // Creator
+	Name           string      `gorm:"size:255;not null" json:"name"`
+	KeyPrefix      string      `gorm:"size:8;index" json:"key_prefix"` // First 8 chars for identification
+	KeyHash        string      `gorm:"size:255;not null" json:"-"`     // bcrypt hash of full key
+	LastUsedAt     *time.Time  `json:"last_used_at,omitempty"`
+	LastUsedIP     string      `gorm:"size:45" json:"last_used_ip,omitempty"`
+	ExpiresAt      *time.Time  `json:"expires_at,omitempty"` // null = never expires
+	IsActive       bool        `gorm:"default:true" json:"is_active"`
+	AllowedIPs     StringArray `gorm:"type:jsonb;default:'[]'" json:"allowed_ips"` // CIDRs or single IPs, empty = any
+	Scopes         StringArray `gorm:"type:jsonb;default:'[]'" json:"scopes"`      // ["GET /api/contacts/**", "POST /api/messages"], empty = all endpoints
 
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
@@ -197,6 +203,24 @@ func (APIKey) TableName() string {
 	return "api_keys"
 }
 
+// APIKeyUsage is a daily per-endpoint request counter for an API key
+type APIKeyUsage struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	APIKeyID       uuid.UUID `gorm:"type:uuid;index;not null" json:"api_key_id"`
+	Date           time.Time `gorm:"type:date;not null" json:"date"`
+	Method         string    `gorm:"size:10;not null" json:"method"`
+	Endpoint       string    `gorm:"size:255;not null" json:"endpoint"` // Path with IDs replaced by {id}
+	RequestCount   int64     `gorm:"default:0" json:"request_count"`
+	DeniedCount    int64     `gorm:"default:0" json:"denied_count"` // Rejected by IP allowlist or scope
+	LastIP         string    `gorm:"size:45" json:"last_ip"`
+	LastUsedAt     time.Time `json:"last_used_at"`
+}
+
+func (APIKeyUsage) TableName() string {
+	return "api_key_usages"
+}
+
 // SSOProvider represents an SSO/OAuth provider configuration for an organization
 type SSOProvider struct {
 	BaseModel
@@ -233,10 +257,36 @@ type Webhook struct {
 	Secret         string      `gorm:"size:255" json:"-"` // For HMAC signature
 	IsActive       bool        `gorm:"default:true" json:"is_active"`
 
+	// Secret rotation: the previous secret keeps signing deliveries until it expires
+	PreviousSecret          string     `gorm:"size:255" json:"-"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+
+	// Campaign webhooks only get their campaign's events, and are removed once it has
+	// finished and the receipts for its messages have had time to arrive
+	CampaignID *uuid.UUID `gorm:"type:uuid;index" json:"campaign_id,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 }
 
+// Expired reports whether a campaign webhook has outlived its campaign
+func (w *Webhook) Expired(now time.Time) bool {
+	return w.ExpiresAt != nil && !now.Before(*w.ExpiresAt)
+}
+
+// SigningSecrets returns the secrets deliveries are signed with, current secret first
+func (w *Webhook) SigningSecrets(now time.Time) []string {
+	if w.Secret == "" {
+		return nil
+	}
+	secrets := []string{w.Secret}
+	if w.PreviousSecret != "" && w.PreviousSecretExpiresAt != nil && now.Before(*w.PreviousSecretExpiresAt) {
+		secrets = append(secrets, w.PreviousSecret)
+	}
+	return secrets
+}
+
 func (Webhook) TableName() string {
 	return "webhooks"
 }
@@ -276,6 +326,41 @@ type WhatsAppAccount struct {
 	AutoReadReceipt    bool      `gorm:"default:false" json:"auto_read_receipt"`
 	Status             string    `gorm:"size:20;default:'active'" json:"status"`
 
+	// Secret of the Meta app the account belongs to; webhook callbacks for the account
+	// must be signed with it. Empty uses whatsapp.app_secret.
+	AppSecret string `gorm:"type:text" json:"-"`
+
+	// Recipients a campaign on this account sends to at once, unless the campaign sets
+	// its own; 0 uses worker.campaign_concurrency
+	CampaignConcurrency int `gorm:"default:0" json:"campaign_concurrency"`
+	// Campaign messages sent a second, shared by all workers; 0 uses worker.messages_per_second
+	MessagesPerSecond int `gorm:"default:0" json:"messages_per_second"`
+
+	// Graph API calls are recorded as APICallLog entries until this time
+	DebugLogUntil *time.Time `json:"debug_log_until,omitempty"`
+
+	// Webhook subscription health, checked periodically against the Graph API
+	WebhookStatus    string     `gorm:"size:20" json:"webhook_status"` // ok, repaired, failed; empty until checked
+	WebhookError     string     `gorm:"type:text" json:"webhook_error,omitempty"` // Why the check failed, or what was repaired
+	WebhookCheckedAt *time.Time `json:"webhook_checked_at,omitempty"`
+
+	// Display name, business verification and green tick status as Meta last reported them
+	VerifiedName               string     `gorm:"size:255" json:"verified_name"`
+	DisplayNameStatus          string     `gorm:"size:30" json:"display_name_status"`          // e.g. APPROVED, PENDING_REVIEW, DECLINED
+	BusinessVerificationStatus string     `gorm:"size:30" json:"business_verification_status"` // e.g. verified, pending, not_verified
+	OfficialBusinessStatus     string     `gorm:"size:30" json:"official_business_status"`     // Green tick, e.g. NOT_STARTED, PENDING, APPROVED
+	QualityRating              string     `gorm:"size:20" json:"quality_rating"`               // GREEN, YELLOW, RED, UNKNOWN
+	MessagingLimitTier         string     `gorm:"size:20" json:"messaging_limit_tier"`         // e.g. TIER_1K, TIER_UNLIMITED
+	StatusError                string     `gorm:"type:text" json:"status_error,omitempty"`
+	StatusCheckedAt            *time.Time `json:"status_checked_at,omitempty"`
+
+	// Sunset stage of APIVersion the organization was last warned about: deprecating or sunset
+	APIVersionWarning string `gorm:"size:20;default:''" json:"api_version_warning,omitempty"`
+
+	// Delivery receipts checked against Meta's analytics, see StatusReconciliation
+	MissedReceipts int64      `gorm:"default:0" json:"missed_receipts"` // Over the days checked in the last run
+	ReconciledAt   *time.Time `json:"reconciled_at,omitempty"`
+
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 }
@@ -284,6 +369,13 @@ func (WhatsAppAccount) TableName() string {
 	return "whatsapp_accounts"
 }
 
+// Webhook subscription statuses
+const (
+	WebhookSubscriptionOK       = "ok"
+	WebhookSubscriptionRepaired = "repaired" // Was dropped or misdirected and has been restored
+	WebhookSubscriptionFailed   = "failed"   // Couldn't be checked or restored
+)
+
 // Contact represents a WhatsApp contact/profile
 type Contact struct {
 	BaseModel
@@ -297,6 +389,11 @@ type Contact struct {
 	IsRead             bool       `gorm:"default:true" json:"is_read"`
 	Tags               JSONBArray `gorm:"type:jsonb;default:'[]'" json:"tags"`
 	Metadata           JSONB      `gorm:"type:jsonb;default:'{}'" json:"metadata"`
+	Timezone           string     `gorm:"size:50" json:"timezone"` // IANA name, used for campaign send windows; empty uses the organization's
+
+	// Engagement scoring (0-100, recomputed periodically by the worker)
+	EngagementScore    int        `gorm:"default:0" json:"engagement_score"`
+	EngagementScoredAt *time.Time `json:"engagement_scored_at,omitempty"`
 
 	// Chatbot SLA tracking
 	ChatbotLastMessageAt *time.Time `json:"chatbot_last_message_at,omitempty"` // When chatbot last sent a message
@@ -312,6 +409,25 @@ func (Contact) TableName() string {
 	return "contacts"
 }
 
+// ContactUpsert is the ON CONFLICT clause for creating contacts. It targets the unique
+// index on (organization_id, phone number without leading +), so concurrent creates for
+// the same number resolve to one row; a soft-deleted contact is restored and an empty
+// profile name is filled in. Use it together with clause.Returning{} so the existing
+// row is loaded into the struct on conflict.
+func ContactUpsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "organization_id"},
+			{Name: "(ltrim(phone_number, '+'))", Raw: true},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"deleted_at":   nil,
+			"updated_at":   gorm.Expr("EXCLUDED.updated_at"),
+			"profile_name": gorm.Expr("COALESCE(NULLIF(contacts.profile_name, ''), EXCLUDED.profile_name)"),
+		}),
+	}
+}
+
 // Message represents a WhatsApp message
 type Message struct {
 	BaseModel
@@ -326,6 +442,7 @@ type Message struct {
 	MediaURL          string     `gorm:"type:text" json:"media_url"`
 	MediaMimeType     string     `gorm:"size:100" json:"media_mime_type"`
 	MediaFilename     string     `gorm:"size:255" json:"media_filename"`
+	Transcript        string     `gorm:"type:text" json:"transcript,omitempty"` // Speech-to-text of inbound voice notes
 	TemplateName      string     `gorm:"size:255" json:"template_name"`
 	TemplateParams    JSONB      `gorm:"type:jsonb" json:"template_params"`
 	InteractiveData   JSONB      `gorm:"type:jsonb" json:"interactive_data"`
@@ -335,6 +452,7 @@ type Message struct {
 	IsReply           bool       `gorm:"default:false" json:"is_reply"`
 	ReplyToMessageID  *uuid.UUID `gorm:"type:uuid" json:"reply_to_message_id,omitempty"`
 	SentByUserID      *uuid.UUID `gorm:"type:uuid;index" json:"sent_by_user_id,omitempty"` // User who sent outgoing message
+	IdempotencyKey    *string    `gorm:"size:64;uniqueIndex" json:"-"`                      // Set on campaign messages, see BulkMessageRecipient.IdempotencyKey
 	Metadata          JSONB      `gorm:"type:jsonb;default:'{}'" json:"metadata"`
 
 	// Relations
@@ -366,6 +484,11 @@ type Template struct {
 	Buttons         JSONBArray `gorm:"type:jsonb;default:'[]'" json:"buttons"`
 	SampleValues    JSONBArray `gorm:"type:jsonb;default:'[]'" json:"sample_values"`
 
+	// Contact field each body parameter is filled from when a send doesn't give it,
+	// keyed by parameter, e.g. {"1": "first_name"}. Overrides the organization's
+	// defaults; an empty field turns a default off for the template.
+	ParamSources JSONB `gorm:"type:jsonb;default:'{}'" json:"param_sources"`
+
 	// Relations
 	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
 }
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationTemplate overrides the wording of one of the platform's emails or in-app
+// notifications for an organization. Templates use Go template syntax with the
+// variables listed for each key.
+type NotificationTemplate struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Key            string    `gorm:"size:50;not null" json:"key"` // e.g. report_ready, metric_anomaly
+	Subject        string    `gorm:"type:text" json:"subject"`    // Email subject or notification title
+	Body           string    `gorm:"type:text;not null" json:"body"`
+}
+
+func (NotificationTemplate) TableName() string {
+	return "notification_templates"
+}
+
+// EmailSenderDomain lets an organization's emails come from its own domain. Mail is
+// signed with the DKIM key once its public half is found in DNS.
+type EmailSenderDomain struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null" json:"organization_id"`
+	Domain         string     `gorm:"size:253;not null" json:"domain"`
+	FromName       string     `gorm:"size:100" json:"from_name"`
+	FromLocalPart  string     `gorm:"size:64;not null" json:"from_local_part"` // "notifications" for notifications@domain
+	DKIMSelector   string     `gorm:"size:63;not null" json:"dkim_selector"`
+	DKIMPrivateKey string     `gorm:"type:text;not null" json:"-"`
+	DKIMPublicKey  string     `gorm:"type:text;not null" json:"dkim_public_key"`
+	IsVerified     bool       `gorm:"default:false" json:"is_verified"`
+	VerifiedAt     *time.Time `json:"verified_at,omitempty"`
+	LastCheckedAt  *time.Time `json:"last_checked_at,omitempty"`
+	LastError      string     `gorm:"type:text" json:"last_error,omitempty"`
+}
+
+func (EmailSenderDomain) TableName() string {
+	return "email_sender_domains"
+}
+
+// FromAddress returns the address emails are sent from
+func (d *EmailSenderDomain) FromAddress() string {
+	return d.FromLocalPart + "@" + d.Domain
+}
+
+// Notification modes
+const (
+	NotificationModeInstant = "instant" // Alert on every new message
+	NotificationModeDigest  = "digest"  // Batch new messages into one alert every DigestIntervalMins
+	NotificationModeMuted   = "muted"   // No new message alerts
+)
+
+// Bounds of a notification digest's interval, in minutes
+const (
+	MinDigestIntervalMins     = 5
+	MaxDigestIntervalMins     = 1440
+	DefaultDigestIntervalMins = 15
+)
+
+// NotificationPreference is how an agent wants to hear about new messages in their
+// conversations, on every channel: the inbox's WebSocket alerts, push notifications and
+// digest emails. Agents without one get instant alerts at any time.
+type NotificationPreference struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID             uuid.UUID   `gorm:"type:uuid;uniqueIndex;not null" json:"user_id"`
+	Mode               string      `gorm:"size:10;not null;default:'instant'" json:"mode"` // instant, digest, muted
+	DigestIntervalMins int         `gorm:"not null;default:15" json:"digest_interval_mins"`
+	MutedUntil         *time.Time  `json:"muted_until,omitempty"`                         // Muted until then, whatever the mode
+	MutedTeamIDs       StringArray `gorm:"type:jsonb;default:'[]'" json:"muted_team_ids"` // No alerts for conversations in these teams' queues
+
+	// Only notify between these times of day (HH:MM), on these days (mon..sun), in
+	// Timezone; empty means any time or any day
+	WorkingHoursStart string      `gorm:"size:5" json:"working_hours_start"`
+	WorkingHoursEnd   string      `gorm:"size:5" json:"working_hours_end"`
+	WorkingDays       StringArray `gorm:"type:jsonb;default:'[]'" json:"working_days"`
+	Timezone          string      `gorm:"size:50" json:"timezone"` // IANA name; empty uses the organization's
+
+	DigestedAt *time.Time `json:"digested_at,omitempty"` // When the last digest was sent
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+// ValidNotificationMode reports whether mode is instant, digest or muted
+func ValidNotificationMode(mode string) bool {
+	switch mode {
+	case NotificationModeInstant, NotificationModeDigest, NotificationModeMuted:
+		return true
+	}
+	return false
+}
+
+// ValidWorkingDay reports whether day is a working day name, mon to sun
+func ValidWorkingDay(day string) bool {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if day == weekdayName(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// MutesTeam reports whether the agent muted alerts for conversations in a team's queue
+func (p *NotificationPreference) MutesTeam(teamID uuid.UUID) bool {
+	for _, id := range p.MutedTeamIDs {
+		if id == teamID.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAt reports whether the agent wants new message alerts at t, read in t's
+// location: not muted, and within their working hours and days
+func (p *NotificationPreference) AllowsAt(t time.Time) bool {
+	if p.Mode == NotificationModeMuted || (p.MutedUntil != nil && t.Before(*p.MutedUntil)) {
+		return false
+	}
+
+	if len(p.WorkingDays) > 0 {
+		today := weekdayName(t.Weekday())
+		working := false
+		for _, day := range p.WorkingDays {
+			if day == today {
+				working = true
+				break
+			}
+		}
+		if !working {
+			return false
+		}
+	}
+
+	start, ok1 := parseTimeOfDay(p.WorkingHoursStart)
+	end, ok2 := parseTimeOfDay(p.WorkingHoursEnd)
+	if !ok1 || !ok2 || start == end {
+		return true
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start > end {
+		// Working hours wrap past midnight, e.g. 22:00-06:00
+		return now >= start || now < end
+	}
+	return now >= start && now < end
+}
+
+// DigestDue reports whether a digest agent's next digest can be sent at t
+func (p *NotificationPreference) DigestDue(t time.Time) bool {
+	if p.DigestedAt == nil {
+		return true
+	}
+	interval := p.DigestIntervalMins
+	if interval < MinDigestIntervalMins {
+		interval = DefaultDigestIntervalMins
+	}
+	return !t.Before(p.DigestedAt.Add(time.Duration(interval) * time.Minute))
+}
+
+// weekdayName returns a weekday's three-letter lowercase name, e.g. "mon"
+func weekdayName(d time.Weekday) string {
+	return strings.ToLower(d.String()[:3])
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization offboarding statuses, in the order an offboarding moves through them
+const (
+	OffboardingExporting = "exporting" // Sending suspended; revoking access and building the data archive
+	OffboardingScheduled = "scheduled" // Archive ready; data is purged at PurgeAt unless cancelled
+	OffboardingPurging   = "purging"
+	OffboardingCompleted = "completed"
+	OffboardingCancelled = "cancelled"
+)
+
+// OffboardingActiveStatuses are the statuses in which the organization can't send messages
+var OffboardingActiveStatuses = []string{OffboardingExporting, OffboardingScheduled, OffboardingPurging}
+
+// OrganizationOffboarding tracks deleting an organization: sending is suspended, access
+// revoked and webhooks unsubscribed, a data archive is built for download, and the
+// organization's data is purged once the grace period ends. The record outlives the
+// organization as confirmation of what was purged, so it keeps the organization's name
+// rather than relying on the organizations row.
+type OrganizationOffboarding struct {
+	BaseModel
+	OrganizationID   uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	OrganizationName string     `gorm:"size:255;not null" json:"organization_name"`
+	OrganizationSlug string     `gorm:"size:100;not null" json:"organization_slug"`
+	Status           string     `gorm:"size:20;not null" json:"status"`
+	Reason           string     `gorm:"type:text" json:"reason,omitempty"`
+	GraceDays        int        `gorm:"not null" json:"grace_days"`
+	PurgeAt          *time.Time `json:"purge_at,omitempty"` // Set once the archive is ready
+	RequestedByID    uuid.UUID  `gorm:"type:uuid" json:"requested_by_id"`
+	RequestedByEmail string     `gorm:"size:255" json:"requested_by_email"` // Receives the confirmation once data is purged
+	ArchivePath      string     `gorm:"size:500" json:"-"`                  // Relative to media storage
+	ArchiveSize      int64      `json:"archive_size"`
+	ArchiveSHA256    string     `gorm:"size:64" json:"archive_sha256,omitempty"`
+	ArchiveReadyAt   *time.Time `json:"archive_ready_at,omitempty"`
+	ExportedCounts   JSONB      `gorm:"type:jsonb;default:'{}'" json:"exported_counts"` // Rows per table in the archive
+	PurgedCounts     JSONB      `gorm:"type:jsonb;default:'{}'" json:"purged_counts"`   // Rows deleted per table
+	RetainedCounts   JSONB      `gorm:"type:jsonb;default:'{}'" json:"retained_counts"` // Write-once records kept after the purge
+	Events           JSONBArray `gorm:"type:jsonb;default:'[]'" json:"events"`          // Audit trail of each step: {step, detail, at}
+	LastError        string     `gorm:"type:text" json:"last_error,omitempty"`
+	CancelledByID    *uuid.UUID `gorm:"type:uuid" json:"cancelled_by_id,omitempty"`
+	CancelledAt      *time.Time `json:"cancelled_at,omitempty"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+
+	// What was switched off while revoking access, so cancelling restores exactly that
+	DeactivatedUserIDs  StringArray `gorm:"type:jsonb;default:'[]'" json:"-"`
+	RevokedAPIKeyIDs    StringArray `gorm:"type:jsonb;default:'[]'" json:"-"`
+	DisabledWebhookIDs  StringArray `gorm:"type:jsonb;default:'[]'" json:"-"`
+	RevokedBIAccountIDs StringArray `gorm:"type:jsonb;default:'[]'" json:"-"`
+}
+
+func (OrganizationOffboarding) TableName() string {
+	return "organization_offboardings"
+}
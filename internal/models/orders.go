@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// Order sources
+const (
+	OrderSourceWhatsApp    = "whatsapp" // Order message sent from a catalog or cart
+	OrderSourceShopify     = "shopify"
+	OrderSourceWooCommerce = "woocommerce"
+	OrderSourceManual      = "manual"
+)
+
+// Order is a purchase linked to a contact's conversation, so revenue can be attributed
+// to WhatsApp conversations, campaigns and agents. Amounts are in cents.
+type Order struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID       *uuid.UUID `gorm:"type:uuid;index" json:"contact_id,omitempty"` // Null when no contact matches the customer's phone
+	WhatsAppAccount string     `gorm:"size:100" json:"whatsapp_account,omitempty"`  // References WhatsAppAccount.Name
+	Source          string     `gorm:"size:20;not null" json:"source"`              // whatsapp, shopify, woocommerce, manual
+	ExternalID      string     `gorm:"size:255" json:"external_id,omitempty"`       // Order ID in the source system, or the order message's WAMID
+	OrderNumber     string     `gorm:"size:100" json:"order_number,omitempty"`
+	Status          string     `gorm:"size:20;default:'pending'" json:"status"` // pending, paid, fulfilled, cancelled, refunded
+	Currency        string     `gorm:"size:3" json:"currency"`
+	TotalAmount     int64      `gorm:"not null;default:0" json:"total_amount"` // In cents
+	Items           JSONBArray `gorm:"type:jsonb;default:'[]'" json:"items"`
+	CustomerPhone   string     `gorm:"size:20" json:"customer_phone,omitempty"`
+	MessageID       *uuid.UUID `gorm:"type:uuid" json:"message_id,omitempty"`        // Order message, for WhatsApp orders
+	CampaignID      *uuid.UUID `gorm:"type:uuid;index" json:"campaign_id,omitempty"` // Campaign last sent to the contact before the order
+	AgentID         *uuid.UUID `gorm:"type:uuid;index" json:"agent_id,omitempty"`    // Agent assigned to the conversation when the order was placed
+	Notes           string     `gorm:"type:text" json:"notes,omitempty"`
+	OrderedAt       time.Time  `gorm:"index;not null" json:"ordered_at"`
+	CreatedByID     *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
+
+	// Relations
+	Contact *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+// OrderUpsert updates an order already recorded from the same source, keeping its
+// original attribution
+func OrderUpsert() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:     []clause.Column{{Name: "organization_id"}, {Name: "source"}, {Name: "external_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "external_id <> '' AND deleted_at IS NULL"}}},
+		DoUpdates:   clause.AssignmentColumns([]string{"order_number", "status", "currency", "total_amount", "items", "updated_at"}),
+	}
+}
+
+// OrderConnector receives order webhooks from a store (Shopify or WooCommerce). The
+// store signs each delivery with Secret; deliveries go to /api/orders/webhook/{id}.
+type OrderConnector struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name            string     `gorm:"size:255;not null" json:"name"`
+	Source          string     `gorm:"size:20;not null" json:"source"` // shopify, woocommerce
+	Secret          string     `gorm:"size:255;not null" json:"-"`     // Webhook signing secret from the store
+	WhatsAppAccount string     `gorm:"size:100" json:"whatsapp_account,omitempty"`
+	IsActive        bool       `gorm:"default:true" json:"is_active"`
+	LastReceivedAt  *time.Time `json:"last_received_at,omitempty"`
+	CreatedByID     *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
+}
+
+func (OrderConnector) TableName() string {
+	return "order_connectors"
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConversationParticipant records an agent's involvement in a contact's conversation:
+// when they last opened it, when they last replied and whether they follow it. There
+// is one row per contact and user.
+type ConversationParticipant struct {
+	BaseModel
+	OrganizationID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ContactID          uuid.UUID  `gorm:"type:uuid;not null" json:"contact_id"`
+	UserID             uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	LastViewedAt       *time.Time `json:"last_viewed_at,omitempty"`
+	LastParticipatedAt *time.Time `json:"last_participated_at,omitempty"`
+	MessageCount       int        `gorm:"default:0" json:"message_count"` // Messages the user sent in the conversation
+	Following          bool       `gorm:"default:false" json:"following"`
+	FollowedAt         *time.Time `json:"followed_at,omitempty"`
+
+	// Relations
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (ConversationParticipant) TableName() string {
+	return "conversation_participants"
+}
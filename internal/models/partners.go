@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Partner member roles
+const (
+	PartnerRoleOwner   = "owner"   // Manages members, API keys and billing details
+	PartnerRoleManager = "manager" // Manages client organizations
+)
+
+// Partner is an agency or reseller that runs several client organizations. Its
+// members see the clients' dashboards and usage together, set each client's
+// branding and use partner API keys scoped to chosen clients.
+type Partner struct {
+	BaseModel
+	Name         string `gorm:"size:255;not null" json:"name"`
+	Slug         string `gorm:"size:100;uniqueIndex;not null" json:"slug"`
+	BillingEmail string `gorm:"size:255" json:"billing_email"`
+	Branding     JSONB  `gorm:"type:jsonb;default:'{}'" json:"branding"` // Defaults for clients without their own
+}
+
+func (Partner) TableName() string {
+	return "partners"
+}
+
+// PartnerMember gives a user access to a partner. A user belongs to at most one partner.
+type PartnerMember struct {
+	BaseModel
+	PartnerID uuid.UUID `gorm:"type:uuid;index;not null" json:"partner_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	Role      string    `gorm:"size:20;not null" json:"role"` // owner, manager
+
+	User *User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (PartnerMember) TableName() string {
+	return "partner_members"
+}
+
+// PartnerAPIKey authenticates as an administrator of one of the partner's client
+// organizations, chosen per request with the X-Organization-ID header
+type PartnerAPIKey struct {
+	BaseModel
+	PartnerID       uuid.UUID   `gorm:"type:uuid;index;not null" json:"partner_id"`
+	UserID          uuid.UUID   `gorm:"type:uuid;not null" json:"user_id"` // Creator
+	Name            string      `gorm:"size:255;not null" json:"name"`
+	KeyPrefix       string      `gorm:"size:8;index" json:"key_prefix"`
+	KeyHash         string      `gorm:"size:255;not null" json:"-"`
+	LastUsedAt      *time.Time  `json:"last_used_at,omitempty"`
+	LastUsedIP      string      `gorm:"size:45" json:"last_used_ip,omitempty"`
+	ExpiresAt       *time.Time  `json:"expires_at,omitempty"`
+	IsActive        bool        `gorm:"default:true" json:"is_active"`
+	OrganizationIDs StringArray `gorm:"type:jsonb;default:'[]'" json:"organization_ids"` // Client organizations, empty = all
+	Scopes          StringArray `gorm:"type:jsonb;default:'[]'" json:"scopes"`           // Same format as API key scopes, empty = all endpoints
+}
+
+func (PartnerAPIKey) TableName() string {
+	return "partner_api_keys"
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Phone number migration statuses, in the order a migration moves through them
+const (
+	PhoneMigrationStarted   = "started"   // Number added to the target WABA
+	PhoneMigrationCodeSent  = "code_sent" // Verification code requested
+	PhoneMigrationVerified  = "verified"  // Code accepted
+	PhoneMigrationCompleted = "completed" // Registered, webhooks subscribed, account re-pointed
+	PhoneMigrationCancelled = "cancelled"
+)
+
+// PhoneNumberMigration tracks moving a WhatsApp account's number to another WABA or
+// BSP. The WhatsAppAccount record keeps its name, so contacts, messages and campaigns
+// that reference it stay linked; only its WABA, phone number ID and token change once
+// the migration completes.
+type PhoneNumberMigration struct {
+	BaseModel
+	OrganizationID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccountID uuid.UUID  `gorm:"type:uuid;index;not null" json:"whatsapp_account_id"`
+	WhatsAppAccount   string     `gorm:"size:100;not null" json:"whatsapp_account"` // References WhatsAppAccount.Name
+	CountryCode       string     `gorm:"size:5;not null" json:"country_code"`
+	PhoneNumber       string     `gorm:"size:20;not null" json:"phone_number"`
+	FromBusinessID    string     `gorm:"size:100" json:"from_business_id"`
+	FromPhoneID       string     `gorm:"size:100" json:"from_phone_id"`
+	ToBusinessID      string     `gorm:"size:100;not null" json:"to_business_id"`
+	ToPhoneID         string     `gorm:"size:100" json:"to_phone_id"`
+	ToAppID           string     `gorm:"size:100" json:"to_app_id"`
+	ToAccessToken     string     `gorm:"type:text" json:"-"`
+	Status            string     `gorm:"size:20;not null" json:"status"`
+	LastError         string     `gorm:"type:text" json:"last_error,omitempty"`
+	Linkage           JSONB      `gorm:"type:jsonb;default:'{}'" json:"linkage"` // Record counts linked to the account when the migration started
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+	CreatedBy         uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+}
+
+func (PhoneNumberMigration) TableName() string {
+	return "phone_number_migrations"
+}
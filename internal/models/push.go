@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PushDevice is a mobile device an agent gets push notifications on. A token belongs to
+// one user; registering it again moves it to whoever signed in last.
+type PushDevice struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	Platform       string     `gorm:"size:10;not null" json:"platform"` // fcm, apns
+	Token          string     `gorm:"size:512;uniqueIndex;not null" json:"-"`
+	DeviceName     string     `gorm:"size:255" json:"device_name"`
+	LastPushedAt   *time.Time `json:"last_pushed_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (PushDevice) TableName() string {
+	return "push_devices"
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Organization settings holding the quiet hours (HH:MM, in the organization's
+// timezone) campaigns don't send in
+const (
+	QuietHoursStartSetting = "quiet_hours_start"
+	QuietHoursEndSetting   = "quiet_hours_end"
+)
+
+// QuietHours returns the quiet hours in an organization's settings, both empty if it
+// has none
+func QuietHours(settings JSONB) (string, string) {
+	start, _ := settings[QuietHoursStartSetting].(string)
+	end, _ := settings[QuietHoursEndSetting].(string)
+	return start, end
+}
+
+// QuietHoursEndAt returns t if it is outside the quiet hours in an organization's
+// settings, read in t's location, else the time they end
+func QuietHoursEndAt(settings JSONB, t time.Time) time.Time {
+	start, end := QuietHours(settings)
+	if start == "" || end == "" {
+		return t
+	}
+	// Sending is allowed from the end of the quiet hours until they start again
+	return windowOpensAt(end, start, t)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StatusReconciliation records one day of an account's outgoing messages checked
+// against the sent and delivered counts in Meta's analytics. Receipts Meta counted but
+// never delivered as webhooks show up as missed receipts.
+type StatusReconciliation struct {
+	BaseModel
+	OrganizationID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount   string     `gorm:"size:100;not null" json:"whatsapp_account"`
+	Date              time.Time  `gorm:"type:date;not null" json:"date"`
+	MetaSent          int64      `json:"meta_sent"`
+	MetaDelivered     int64      `json:"meta_delivered"`
+	LocalSent         int64      `json:"local_sent"`      // Outgoing messages Meta accepted, whatever their status now
+	LocalDelivered    int64      `json:"local_delivered"` // Of those, messages with a delivered or read receipt
+	MissedReceipts    int64      `json:"missed_receipts"` // Deliveries Meta counted that no webhook reported
+	StuckMessages     int64      `json:"stuck_messages"`  // Messages found stuck in sent on the latest run
+	InferredDelivered int64      `json:"inferred_delivered"`
+	MarkedStale       int64      `json:"marked_stale"`
+	Error             string     `gorm:"type:text" json:"error,omitempty"` // Why Meta's analytics couldn't be compared
+	CheckedAt         time.Time  `json:"checked_at"`
+	AlertedAt         *time.Time `json:"alerted_at,omitempty"` // When the organization was told about missed receipts
+}
+
+func (StatusReconciliation) TableName() string {
+	return "status_reconciliations"
+}
+
+// Results recorded in a reconciled message's metadata under "status_reconciliation"
+const (
+	ReconciledDelivered = "delivered" // Meta's analytics account for its delivery; status set to delivered
+	ReconciledStale     = "stale"     // No receipt and nothing to infer it from; status left as sent
+)
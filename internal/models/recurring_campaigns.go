@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Recurring campaign run outcomes
+const (
+	RecurringRunCreated = "created" // A campaign was created and queued
+	RecurringRunSkipped = "skipped" // The previous campaign was still sending, or it was a blackout date
+	RecurringRunEmpty   = "empty"   // No contacts matched the segment
+	RecurringRunFailed  = "failed"
+)
+
+// RecurringCampaign re-sends a template to a segment of contacts on a cron schedule.
+// Each occurrence is created as a regular campaign, and an occurrence is skipped
+// while the previous one is still sending.
+type RecurringCampaign struct {
+	BaseModel
+	OrganizationID  uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string    `gorm:"size:100;not null" json:"whatsapp_account"`
+	Name            string    `gorm:"size:255;not null" json:"name"`
+	IsEnabled       bool      `gorm:"default:true" json:"is_enabled"`
+	Schedule        string    `gorm:"size:100;not null" json:"schedule"` // Cron expression in the organization's timezone
+	TemplateID      uuid.UUID `gorm:"type:uuid;not null" json:"template_id"`
+	TemplateParams  JSONB     `gorm:"type:jsonb;default:'{}'" json:"template_params"` // "1" -> "{{name}}"
+	CreatedBy       uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+
+	// Segment: contacts on the account matching every filter set
+	Tag           string     `gorm:"size:100" json:"tag"`
+	LabelID       *uuid.UUID `gorm:"type:uuid" json:"label_id,omitempty"`
+	MinEngagement int        `json:"min_engagement"`
+
+	NextRunAt      *time.Time `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastRunStatus  string     `gorm:"size:20" json:"last_run_status,omitempty"` // created, skipped, empty, failed
+	LastRunMessage string     `gorm:"type:text" json:"last_run_message,omitempty"`
+	LastCampaignID *uuid.UUID `gorm:"type:uuid" json:"last_campaign_id,omitempty"`
+
+	// Relations
+	Template *Template `gorm:"foreignKey:TemplateID" json:"template,omitempty"`
+}
+
+func (RecurringCampaign) TableName() string {
+	return "recurring_campaigns"
+}
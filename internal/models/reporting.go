@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BIServiceAccount is a read-only Postgres login for BI tools such as Metabase or
+// Looker. The role can only select from the views in the reporting schema, which show
+// the rows of the organization the account belongs to while it is active.
+type BIServiceAccount struct {
+	BaseModel
+	OrganizationID    uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name              string     `gorm:"size:255;not null" json:"name"`
+	RoleName          string     `gorm:"size:63;not null" json:"role_name"` // Postgres role, used as the username
+	IsActive          bool       `gorm:"default:true" json:"is_active"`
+	CreatedByID       uuid.UUID  `gorm:"type:uuid" json:"created_by_id"`
+	PasswordRotatedAt *time.Time `json:"password_rotated_at,omitempty"`
+}
+
+func (BIServiceAccount) TableName() string {
+	return "bi_service_accounts"
+}
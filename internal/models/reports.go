@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageDailyRollup aggregates a day's messages per account, template and agent.
+// Rows are rebuilt by the worker from the messages table; reports read only rollups.
+type MessageDailyRollup struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;not null" json:"organization_id"`
+	Date            time.Time  `gorm:"type:date;not null" json:"date"`
+	WhatsAppAccount string     `gorm:"size:100" json:"whatsapp_account"`
+	TemplateName    string     `gorm:"size:255" json:"template_name"`       // Empty for non-template messages
+	AgentID         *uuid.UUID `gorm:"type:uuid" json:"agent_id,omitempty"` // User who sent the message (or the message replied to)
+	SentCount       int64      `gorm:"default:0" json:"sent_count"`
+	DeliveredCount  int64      `gorm:"default:0" json:"delivered_count"`
+	ReadCount       int64      `gorm:"default:0" json:"read_count"`
+	FailedCount     int64      `gorm:"default:0" json:"failed_count"`
+	ReplyCount      int64      `gorm:"default:0" json:"reply_count"`      // Incoming messages
+	ConversionCount int64      `gorm:"default:0" json:"conversion_count"` // Incoming button/interactive responses
+}
+
+func (MessageDailyRollup) TableName() string {
+	return "message_daily_rollups"
+}
+
+// MessageTagDailyRollup is MessageDailyRollup split by the contact's tags.
+// A message to a contact with several tags is counted once under each tag.
+type MessageTagDailyRollup struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;not null" json:"organization_id"`
+	Date            time.Time  `gorm:"type:date;not null" json:"date"`
+	Tag             string     `gorm:"size:255;not null" json:"tag"`
+	WhatsAppAccount string     `gorm:"size:100" json:"whatsapp_account"`
+	TemplateName    string     `gorm:"size:255" json:"template_name"`
+	AgentID         *uuid.UUID `gorm:"type:uuid" json:"agent_id,omitempty"`
+	SentCount       int64      `gorm:"default:0" json:"sent_count"`
+	DeliveredCount  int64      `gorm:"default:0" json:"delivered_count"`
+	ReadCount       int64      `gorm:"default:0" json:"read_count"`
+	FailedCount     int64      `gorm:"default:0" json:"failed_count"`
+	ReplyCount      int64      `gorm:"default:0" json:"reply_count"`
+	ConversionCount int64      `gorm:"default:0" json:"conversion_count"`
+}
+
+func (MessageTagDailyRollup) TableName() string {
+	return "message_tag_daily_rollups"
+}
+
+// Report is a saved report definition: either a custom report built from dimensions,
+// metrics and filters, or one of the built-in analytics reports
+type Report struct {
+	BaseModel
+	OrganizationID uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string      `gorm:"size:255;not null" json:"name"`
+	Description    string      `gorm:"type:text" json:"description"`
+	Type           string      `gorm:"size:30;default:'custom'" json:"type"`      // custom, campaign_summary, agent_performance, revenue
+	Format         string      `gorm:"size:10;default:'csv'" json:"format"`       // csv, pdf; attachment format for scheduled emails
+	Dimensions     StringArray `gorm:"type:jsonb;default:'[]'" json:"dimensions"` // day, template, agent, tag, account
+	Metrics        StringArray `gorm:"type:jsonb;default:'[]'" json:"metrics"`    // sent, delivered, read, failed, replies, conversions
+	Filters        JSONB       `gorm:"type:jsonb;default:'{}'" json:"filters"`    // {"templates": [...], "agents": [...], "tags": [...], "accounts": [...]}
+	Schedule       string      `gorm:"size:100" json:"schedule"`                  // daily, weekly, monthly or a cron expression; empty = manual only
+	Recipients     StringArray `gorm:"type:jsonb;default:'[]'" json:"recipients"` // Email addresses for scheduled runs
+	NextRunAt      *time.Time  `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time  `json:"last_run_at,omitempty"`
+	CreatedByID    *uuid.UUID  `gorm:"type:uuid" json:"created_by_id,omitempty"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (Report) TableName() string {
+	return "reports"
+}
+
+// ReportRun is one asynchronous execution of a report and its result
+type ReportRun struct {
+	BaseModel
+	OrganizationID uuid.UUID   `gorm:"type:uuid;index;not null" json:"organization_id"`
+	ReportID       uuid.UUID   `gorm:"type:uuid;index;not null" json:"report_id"`
+	Trigger        string      `gorm:"size:20;not null" json:"trigger"`              // manual, schedule
+	Status         string      `gorm:"size:20;default:'queued';index" json:"status"` // queued, running, completed, failed
+	PeriodStart    time.Time   `gorm:"type:date;not null" json:"period_start"`
+	PeriodEnd      time.Time   `gorm:"type:date;not null" json:"period_end"` // Inclusive
+	Columns        StringArray `gorm:"type:jsonb;default:'[]'" json:"columns"`
+	Rows           JSONBArray  `gorm:"type:jsonb;default:'[]'" json:"rows,omitempty"`
+	RowCount       int         `gorm:"default:0" json:"row_count"`
+	Error          string      `gorm:"type:text" json:"error,omitempty"`
+	StartedAt      *time.Time  `json:"started_at,omitempty"`
+	CompletedAt    *time.Time  `json:"completed_at,omitempty"`
+	EmailedAt      *time.Time  `json:"emailed_at,omitempty"`
+	CreatedByID    *uuid.UUID  `gorm:"type:uuid" json:"created_by_id,omitempty"`
+	JobID          *uuid.UUID  `gorm:"type:uuid" json:"job_id,omitempty"` // Tracks the run's progress
+
+	// Relations
+	Report *Report `gorm:"foreignKey:ReportID" json:"report,omitempty"`
+}
+
+func (ReportRun) TableName() string {
+	return "report_runs"
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// ValidSendWindow reports whether start and end make a campaign send window: both
+// empty for none, or two different times of day in the HH:MM form
+func ValidSendWindow(start, end string) bool {
+	if start == "" && end == "" {
+		return true
+	}
+	s, ok1 := parseTimeOfDay(start)
+	e, ok2 := parseTimeOfDay(end)
+	return ok1 && ok2 && s != e
+}
+
+// SendWindowOpensAt returns t if the campaign's send window is open at t, read in t's
+// location, else the time it next opens. Campaigns without a window are always open.
+func (c *BulkMessageCampaign) SendWindowOpensAt(t time.Time) time.Time {
+	return windowOpensAt(c.SendWindowStart, c.SendWindowEnd, t)
+}
+
+// windowOpensAt returns t if the window from start to end (HH:MM) is open at t, read
+// in t's location, else the time it next opens. An invalid window is always open.
+func windowOpensAt(startTime, endTime string, t time.Time) time.Time {
+	start, ok1 := parseTimeOfDay(startTime)
+	end, ok2 := parseTimeOfDay(endTime)
+	if !ok1 || !ok2 || start == end {
+		return t
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	open := now >= start && now < end
+	if start > end {
+		// The window wraps past midnight, e.g. 20:00-08:00
+		open = now >= start || now < end
+	}
+	if open {
+		return t
+	}
+
+	opens := time.Date(t.Year(), t.Month(), t.Day(), start/60, start%60, 0, 0, t.Location())
+	if !opens.After(t) {
+		opens = time.Date(t.Year(), t.Month(), t.Day()+1, start/60, start%60, 0, 0, t.Location())
+	}
+	return opens
+}
+
+// parseTimeOfDay parses an HH:MM time of day into minutes after midnight
+func parseTimeOfDay(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sequence is a drip campaign: an ordered list of template messages sent to each
+// enrolled contact on set days after they were enrolled
+type Sequence struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string     `gorm:"size:100;not null" json:"whatsapp_account"` // Account the steps are sent from
+	Name            string     `gorm:"size:255;not null" json:"name"`
+	Description     string     `gorm:"type:text" json:"description"`
+	IsEnabled       bool       `gorm:"default:false" json:"is_enabled"`  // Disabled sequences hold their enrollments where they are
+	Steps           JSONBArray `gorm:"type:jsonb;not null" json:"steps"` // []SequenceStep
+	CreatedBy       uuid.UUID  `gorm:"type:uuid" json:"created_by"`
+}
+
+func (Sequence) TableName() string {
+	return "sequences"
+}
+
+// SequenceStep is one template message in a sequence
+type SequenceStep struct {
+	TemplateID     uuid.UUID         `json:"template_id"`
+	Day            int               `json:"day"`                       // Days after enrollment; 0 sends right away
+	TemplateParams map[string]string `json:"template_params,omitempty"` // "1" -> "Hi {{name}}"
+}
+
+// StepList decodes the sequence's steps
+func (s *Sequence) StepList() ([]SequenceStep, error) {
+	data, err := json.Marshal(s.Steps)
+	if err != nil {
+		return nil, err
+	}
+	var steps []SequenceStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// Sequence enrollment statuses
+const (
+	SequenceStatusActive     = "active"
+	SequenceStatusCompleted  = "completed"  // Every step was sent
+	SequenceStatusReplied    = "replied"    // Contact replied, so the rest of the steps were skipped
+	SequenceStatusOptedOut   = "opted_out"  // Contact replied with an opt-out keyword
+	SequenceStatusUnenrolled = "unenrolled" // Removed by a user, or the sequence was deleted
+	SequenceStatusFailed     = "failed"
+)
+
+// SequenceEnrollment tracks one contact's progress through a sequence. A contact has at
+// most one active enrollment per sequence.
+type SequenceEnrollment struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	SequenceID     uuid.UUID  `gorm:"type:uuid;index;not null" json:"sequence_id"`
+	ContactID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"contact_id"`
+	Status         string     `gorm:"size:20;not null" json:"status"`
+	CurrentStep    int        `gorm:"default:0" json:"current_step"` // Index of the next step to send
+	NextSendAt     *time.Time `json:"next_send_at,omitempty"`
+	LastSentAt     *time.Time `json:"last_sent_at,omitempty"`
+	ExitReason     string     `gorm:"type:text" json:"exit_reason,omitempty"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+	EnrolledBy     *uuid.UUID `gorm:"type:uuid" json:"enrolled_by,omitempty"`
+
+	// Relations
+	Contact *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+func (SequenceEnrollment) TableName() string {
+	return "sequence_enrollments"
+}
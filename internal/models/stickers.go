@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Sticker represents a sticker in an organization's sticker library
+type Sticker struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Name           string    `gorm:"size:100;not null" json:"name"`
+	Pack           string    `gorm:"size:100;index" json:"pack"`  // Optional grouping shown in the sticker picker
+	MediaPath      string    `gorm:"type:text;not null" json:"-"` // Path relative to media storage
+	MimeType       string    `gorm:"size:50;not null" json:"mime_type"`
+	FileSize       int       `gorm:"default:0" json:"file_size"`
+	Animated       bool      `gorm:"default:false" json:"animated"`
+	UsageCount     int       `gorm:"default:0" json:"usage_count"`
+	CreatedByID    uuid.UUID `gorm:"type:uuid" json:"created_by_id"`
+
+	// Relations
+	Organization *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	CreatedBy    *User         `gorm:"foreignKey:CreatedByID" json:"created_by,omitempty"`
+}
+
+func (Sticker) TableName() string {
+	return "stickers"
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Missing template parameter policies of a campaign
+const (
+	MissingParamSend    = "send"    // Send with the parameter left out
+	MissingParamSkip    = "skip"    // Skip the recipient
+	MissingParamDefault = "default" // Use the campaign's default value, skipping the recipient if it has none
+)
+
+// templateParamPattern matches a positional placeholder such as {{2}}
+var templateParamPattern = regexp.MustCompile(`\{\{\s*(\d+)\s*\}\}`)
+
+// TemplateParamSourcesSetting is the organization setting with the contact field each
+// template parameter is filled from by default, keyed by parameter, e.g. {"1": "first_name"}
+const TemplateParamSourcesSetting = "template_param_sources"
+
+// paramSourcePattern matches a contact field a parameter can be filled from
+var paramSourcePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidMissingParamPolicy reports whether policy is a known missing parameter policy
+func ValidMissingParamPolicy(policy string) bool {
+	switch policy {
+	case MissingParamSend, MissingParamSkip, MissingParamDefault:
+		return true
+	}
+	return false
+}
+
+// TemplateBodyParams returns the keys of the positional parameters a template body
+// uses, in ascending order and without duplicates
+func TemplateBodyParams(body string) []string {
+	seen := map[int]bool{}
+	var nums []int
+	for _, m := range templateParamPattern.FindAllStringSubmatch(body, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || seen[n] {
+			continue
+		}
+		seen[n] = true
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	keys := make([]string, len(nums))
+	for i, n := range nums {
+		keys[i] = strconv.Itoa(n)
+	}
+	return keys
+}
+
+// ValidateTemplateParamSources checks sources maps positional parameters to contact
+// field names. Empty fields are allowed, to turn an organization default off.
+func ValidateTemplateParamSources(sources map[string]string) error {
+	for key, field := range sources {
+		if n, err := strconv.Atoi(key); err != nil || n < 1 {
+			return fmt.Errorf("parameter %q must be a number such as 1", key)
+		}
+		if field != "" && !paramSourcePattern.MatchString(field) {
+			return fmt.Errorf("parameter %s: %q is not a contact field name", key, field)
+		}
+	}
+	return nil
+}
+
+// TemplateParamSources returns the contact field each parameter of template is filled
+// from: the organization's defaults in settings, overridden by the template's own
+func TemplateParamSources(settings JSONB, template *Template) map[string]string {
+	sources := map[string]string{}
+	for key, field := range stringMap(settings[TemplateParamSourcesSetting]) {
+		if field != "" {
+			sources[key] = field
+		}
+	}
+	if template != nil {
+		for key, field := range stringMap(map[string]interface{}(template.ParamSources)) {
+			if field == "" {
+				delete(sources, key)
+			} else {
+				sources[key] = field
+			}
+		}
+	}
+	return sources
+}
+
+// ContactParamFields returns the contact fields parameters can be filled from: name,
+// profile_name, first_name (the first word of the name), phone_number and the keys
+// of the contact's metadata
+func ContactParamFields(contact *Contact) map[string]interface{} {
+	fields := map[string]interface{}{
+		"name":         contact.ProfileName,
+		"profile_name": contact.ProfileName,
+		"first_name":   firstName(contact.ProfileName),
+		"phone_number": contact.PhoneNumber,
+	}
+	for k, v := range contact.Metadata {
+		if _, exists := fields[k]; !exists {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// FillTemplateParams returns params with each of template's parameters it has no
+// value for filled from the contact field sources maps it to. Values given
+// explicitly always win, and fields the contact has no value for are left missing.
+func FillTemplateParams(template *Template, params JSONB, sources map[string]string, fields map[string]interface{}) JSONB {
+	if template == nil || len(sources) == 0 {
+		return params
+	}
+
+	filled := JSONB{}
+	for k, v := range params {
+		filled[k] = v
+	}
+	for _, key := range TemplateBodyParams(template.BodyContent) {
+		field, ok := sources[key]
+		if !ok || paramValue(filled, key) != "" {
+			continue
+		}
+		if v := paramValue(fields, field); v != "" {
+			filled[key] = v
+		}
+	}
+	return filled
+}
+
+// ResolveTemplateParams applies the campaign's missing parameter policy to a
+// recipient's parameters for template. It returns the parameters to send and the
+// keys still missing; a recipient with missing keys should be skipped. A parameter
+// with an empty value counts as missing.
+func (c *BulkMessageCampaign) ResolveTemplateParams(template *Template, params JSONB) (JSONB, []string) {
+	if template == nil || c.MissingParamPolicy == "" || c.MissingParamPolicy == MissingParamSend {
+		return params, nil
+	}
+
+	resolved := JSONB{}
+	for k, v := range params {
+		resolved[k] = v
+	}
+	var missing []string
+	for _, key := range TemplateBodyParams(template.BodyContent) {
+		if paramValue(resolved, key) != "" {
+			continue
+		}
+		if c.MissingParamPolicy == MissingParamDefault {
+			if def := paramValue(c.ParamDefaults, key); def != "" {
+				resolved[key] = def
+				continue
+			}
+		}
+		missing = append(missing, key)
+	}
+	return resolved, missing
+}
+
+// MissingParamsError is the error recorded on a recipient skipped for missing parameters
+func MissingParamsError(missing []string) string {
+	placeholders := make([]string, len(missing))
+	for i, key := range missing {
+		placeholders[i] = "{{" + key + "}}"
+	}
+	return "Skipped: missing template parameters " + strings.Join(placeholders, ", ")
+}
+
+// firstName returns the first word of name
+func firstName(name string) string {
+	if words := strings.Fields(name); len(words) > 0 {
+		return words[0]
+	}
+	return ""
+}
+
+// stringMap reads a JSON object of strings, as a setting decodes to or is set as
+func stringMap(v interface{}) map[string]string {
+	m := map[string]string{}
+	switch values := v.(type) {
+	case map[string]interface{}:
+		for k, v := range values {
+			if s, ok := v.(string); ok {
+				m[k] = s
+			}
+		}
+	case map[string]string:
+		for k, s := range values {
+			m[k] = s
+		}
+	}
+	return m
+}
+
+func paramValue(params map[string]interface{}, key string) string {
+	v, ok := params[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", v))
+}
@@ -0,0 +1,18 @@
+package models
+
+import "github.com/google/uuid"
+
+// TestNumber is an internal phone number an organization tests with. Test numbers
+// don't count toward quotas or frequency caps, can always be sent campaign test
+// sends, and are left out of analytics.
+type TestNumber struct {
+	BaseModel
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	PhoneNumber    string    `gorm:"size:20;not null" json:"phone_number"` // Digits with calling code, as contacts are stored
+	Label          string    `gorm:"size:100" json:"label"`                // e.g. "QA Android"
+	CreatedBy      uuid.UUID `gorm:"type:uuid" json:"created_by"`
+}
+
+func (TestNumber) TableName() string {
+	return "test_numbers"
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WinBackAutomation re-engages contacts who used to reply but have gone quiet, by
+// enrolling them into a sequence of template messages
+type WinBackAutomation struct {
+	BaseModel
+	OrganizationID  uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	WhatsAppAccount string     `gorm:"size:100;not null" json:"whatsapp_account"` // Account the sequence is sent from
+	Name            string     `gorm:"size:255;not null" json:"name"`
+	IsEnabled       bool       `gorm:"default:false" json:"is_enabled"`
+	InactiveDays    int        `json:"inactive_days"`                            // Days since the contact's last reply
+	MinPastReplies  int        `json:"min_past_replies"`                         // Replies ever needed to count as previously engaged
+	Steps           JSONBArray `gorm:"type:jsonb;not null" json:"steps"`         // []WinBackStep
+	DailyCap        int        `json:"daily_cap"`                                // New enrollments per day; 0 means unlimited
+	CooldownDays    int        `json:"cooldown_days"`                            // Before a contact can be enrolled again
+	ExitLabelID     *uuid.UUID `gorm:"type:uuid" json:"exit_label_id,omitempty"` // Stop when the conversation gets this label
+}
+
+func (WinBackAutomation) TableName() string {
+	return "win_back_automations"
+}
+
+// WinBackStep is one template message in a win-back sequence
+type WinBackStep struct {
+	TemplateID     uuid.UUID         `json:"template_id"`
+	DelayDays      int               `json:"delay_days"`                // After enrollment for the first step, after the previous step otherwise
+	TemplateParams map[string]string `json:"template_params,omitempty"` // "1" -> "Hi {{name}}"
+}
+
+// StepList decodes the automation's steps
+func (w *WinBackAutomation) StepList() ([]WinBackStep, error) {
+	data, err := json.Marshal(w.Steps)
+	if err != nil {
+		return nil, err
+	}
+	var steps []WinBackStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// Win-back enrollment statuses
+const (
+	WinBackStatusActive    = "active"
+	WinBackStatusReengaged = "reengaged" // Contact replied
+	WinBackStatusCompleted = "completed" // Every step was sent without a reply
+	WinBackStatusExited    = "exited"    // An exit condition other than a reply matched
+	WinBackStatusFailed    = "failed"
+)
+
+// WinBackEnrollment tracks one contact's progress through a win-back sequence
+type WinBackEnrollment struct {
+	BaseModel
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	AutomationID   uuid.UUID  `gorm:"type:uuid;index;not null" json:"automation_id"`
+	ContactID      uuid.UUID  `gorm:"type:uuid;index;not null" json:"contact_id"`
+	Status         string     `gorm:"size:20;not null" json:"status"`
+	CurrentStep    int        `gorm:"default:0" json:"current_step"` // Index of the next step to send
+	NextSendAt     *time.Time `json:"next_send_at,omitempty"`
+	LastSentAt     *time.Time `json:"last_sent_at,omitempty"`
+	ExitReason     string     `gorm:"type:text" json:"exit_reason,omitempty"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+
+	// Relations
+	Contact *Contact `gorm:"foreignKey:ContactID" json:"contact,omitempty"`
+}
+
+func (WinBackEnrollment) TableName() string {
+	return "win_back_enrollments"
+}
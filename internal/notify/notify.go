@@ -0,0 +1,140 @@
+// Package notify renders the emails and in-app notifications the platform sends on
+// an organization's behalf, using the organization's wording, branding and, for
+// email, its own DKIM-signed sender domain.
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"mime"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/mailer"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// defaultColor is the accent color of emails without a branded one
+const defaultColor = "#16a34a"
+
+// Notification is a rendered in-app notification
+type Notification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// htmlLayout wraps an email's text in the organization's branding
+var htmlLayout = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html><body style="margin:0;padding:24px;background:#f4f4f5;font-family:Arial,Helvetica,sans-serif">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0"><tr><td align="center">
+<table role="presentation" width="600" cellpadding="0" cellspacing="0" style="background:#ffffff;border-radius:6px;border-top:4px solid {{.Color}}">
+<tr><td style="padding:24px">{{if .LogoURL}}<img src="{{.LogoURL}}" alt="{{.AppName}}" height="40">{{else}}<strong style="font-size:18px;color:#18181b">{{.AppName}}</strong>{{end}}</td></tr>
+<tr><td style="padding:0 24px 24px;font-size:14px;line-height:1.6;color:#18181b;white-space:pre-wrap">{{.Body}}</td></tr>
+{{if or .SupportEmail .PoweredBy}}<tr><td style="padding:16px 24px;font-size:12px;color:#71717a;border-top:1px solid #e4e4e7">{{if .SupportEmail}}Questions? Contact <a href="mailto:{{.SupportEmail}}" style="color:{{.Color}}">{{.SupportEmail}}</a>{{end}}{{if .PoweredBy}}{{if .SupportEmail}} · {{end}}Powered by Whatomate{{end}}</td></tr>{{end}}
+</table>
+</td></tr></table>
+</body></html>`))
+
+// Email renders an email template for an organization. The caller fills in the
+// recipients and attachments. Without a verified sender domain the configured From
+// address is used.
+func Email(db *gorm.DB, orgID uuid.UUID, key string, data any) (mailer.Message, error) {
+	org, subject, body, err := renderFor(db, orgID, key, data)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	branding := OrganizationBranding(db, org)
+
+	html, err := HTML(branding, org.Name, body)
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	msg := mailer.Message{Subject: subject, Text: body, HTML: html}
+
+	var domain models.EmailSenderDomain
+	if err := db.Where("organization_id = ? AND is_verified = true", orgID).First(&domain).Error; err == nil {
+		signer, err := mailer.NewDKIM(domain.Domain, domain.DKIMSelector, domain.DKIMPrivateKey)
+		if err != nil {
+			return mailer.Message{}, err
+		}
+		name := firstNonEmpty(domain.FromName, branding.AppName, org.Name)
+		msg.From = fmt.Sprintf("%s <%s>", mime.QEncoding.Encode("utf-8", name), domain.FromAddress())
+		msg.DKIM = signer
+	}
+	return msg, nil
+}
+
+// InApp renders an in-app notification template for an organization
+func InApp(db *gorm.DB, orgID uuid.UUID, key string, data any) (*Notification, error) {
+	_, title, body, err := renderFor(db, orgID, key, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Notification{Title: title, Body: strings.TrimSpace(body)}, nil
+}
+
+// HTML renders an email body in the branded layout
+func HTML(branding Branding, orgName, body string) (string, error) {
+	var buf bytes.Buffer
+	err := htmlLayout.Execute(&buf, map[string]any{
+		"AppName":      firstNonEmpty(branding.AppName, orgName),
+		"LogoURL":      branding.LogoURL,
+		"Color":        template.CSS(firstNonEmpty(branding.PrimaryColor, defaultColor)),
+		"SupportEmail": branding.SupportEmail,
+		"PoweredBy":    !branding.HidePoweredBy,
+		"Body":         strings.TrimSpace(body),
+	})
+	return buf.String(), err
+}
+
+// Render executes a subject and body against variables, adding the common ones
+func Render(branding Branding, orgName, subject, body string, vars map[string]any) (string, string, error) {
+	vars["app_name"] = firstNonEmpty(branding.AppName, orgName)
+	vars["organization_name"] = orgName
+	renderedSubject, err := render("subject", subject, vars)
+	if err != nil {
+		return "", "", err
+	}
+	renderedBody, err := render("body", body, vars)
+	if err != nil {
+		return "", "", err
+	}
+	return renderedSubject, renderedBody, nil
+}
+
+// renderFor renders the organization's version of a template, falling back to the
+// default wording when its own fails to render
+func renderFor(db *gorm.DB, orgID uuid.UUID, key string, data any) (*models.Organization, string, string, error) {
+	def, ok := Lookup(key)
+	if !ok {
+		return nil, "", "", fmt.Errorf("unknown template %q", key)
+	}
+
+	var org models.Organization
+	if err := db.Select("id", "name", "settings", "partner_id").Where("id = ?", orgID).First(&org).Error; err != nil {
+		return nil, "", "", err
+	}
+	branding := OrganizationBranding(db, &org)
+
+	var custom models.NotificationTemplate
+	if err := db.Where("organization_id = ? AND key = ?", orgID, key).First(&custom).Error; err == nil {
+		subject, body, err := Render(branding, org.Name, custom.Subject, custom.Body, Vars(data))
+		if err == nil {
+			return &org, subject, body, nil
+		}
+	}
+
+	subject, body, err := Render(branding, org.Name, def.Subject, def.Body, Vars(data))
+	return &org, subject, body, err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,258 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"text/template"
+)
+
+// Channels a template is delivered on
+const (
+	ChannelEmail = "email"
+	ChannelInApp = "in_app"
+)
+
+// Template keys
+const (
+	KeyReportReady         = "report_ready"
+	KeyMetricAnomaly       = "metric_anomaly"
+	KeyAccountStatus       = "account_status"
+	KeyWebhookSubscription = "webhook_subscription"
+	KeyMissedReceipts      = "missed_receipts"
+	KeyAPIVersion          = "api_version"
+	KeyBotMessageLimit     = "bot_message_limit"
+	KeyMessageDigest       = "message_digest"
+)
+
+const (
+	maxSubjectLength = 200
+	maxBodyLength    = 5000
+)
+
+// Definition is one of the platform's emails or in-app notifications with its
+// default wording. Sample lists the variables it can use, with example values.
+type Definition struct {
+	Key         string         `json:"key"`
+	Channel     string         `json:"channel"`
+	Description string         `json:"description"`
+	Subject     string         `json:"subject"` // Email subject or notification title
+	Body        string         `json:"body"`
+	Sample      map[string]any `json:"sample"`
+}
+
+// commonSample holds the variables every template can use
+var commonSample = map[string]any{
+	"app_name":          "Acme Messaging",
+	"organization_name": "Acme Retail",
+}
+
+// Definitions are the templates organizations can customize, in display order
+var Definitions = []Definition{
+	{
+		Key:         KeyReportReady,
+		Channel:     ChannelEmail,
+		Description: "Scheduled report delivered by email",
+		Subject:     `{{.report_name}} ({{.period}})`,
+		Body: `Your scheduled report "{{.report_name}}" for {{.period}} is attached ({{.row_count}} rows).
+{{if .truncated}}
+The report was truncated to {{.max_rows}} rows; narrow its filters or dimensions to see everything.
+{{end}}`,
+		Sample: map[string]any{
+			"report_name": "Weekly campaign summary",
+			"period":      "2026-01-05 to 2026-01-11",
+			"row_count":   42,
+			"truncated":   false,
+			"max_rows":    10000,
+		},
+	},
+	{
+		Key:         KeyMetricAnomaly,
+		Channel:     ChannelInApp,
+		Description: "Unusual delivery, read or failure rate on a WhatsApp account",
+		Subject:     `Unusual {{.metric}} on {{.whatsapp_account}}`,
+		Body:        `{{.message}}`,
+		Sample: map[string]any{
+			"whatsapp_account": "Support line",
+			"date":             "2026-01-12",
+			"metric":           "failure_rate",
+			"direction":        "up",
+			"value":            0.18,
+			"baseline":         0.02,
+			"volume":           1200,
+			"message":          "Failure rate rose to 18% from a usual 2%",
+		},
+	},
+	{
+		Key:         KeyAccountStatus,
+		Channel:     ChannelInApp,
+		Description: "Display name, business verification or official business status changed",
+		Subject:     `{{.whatsapp_account}} status changed`,
+		Body:        `{{range .changes}}{{.field}}: {{.from}} → {{.to}}{{"\n"}}{{end}}`,
+		Sample: map[string]any{
+			"whatsapp_account": "Support line",
+			"phone_id":         "1234567890",
+			"business_id":      "9876543210",
+			"changes": []any{
+				map[string]any{"field": "display_name_status", "from": "PENDING", "to": "APPROVED"},
+			},
+		},
+	},
+	{
+		Key:         KeyWebhookSubscription,
+		Channel:     ChannelInApp,
+		Description: "Meta webhook subscription repaired or failing",
+		Subject:     `Webhook subscription {{.status}} on {{.whatsapp_account}}`,
+		Body:        `{{.problem}}{{if .error}}: {{.error}}{{end}}`,
+		Sample: map[string]any{
+			"whatsapp_account": "Support line",
+			"business_id":      "9876543210",
+			"status":           "failed",
+			"problem":          "App is not subscribed",
+			"error":            "",
+		},
+	},
+	{
+		Key:         KeyMissedReceipts,
+		Channel:     ChannelInApp,
+		Description: "Meta delivered messages whose delivery receipts never reached the app",
+		Subject:     `Delivery receipts missing on {{.whatsapp_account}}`,
+		Body: `Meta counted {{.meta_delivered}} messages delivered on {{.date}}, but only {{.local_delivered}} delivery receipts arrived as webhooks.
+Check the account's webhook subscription and that the webhook URL is reachable.`,
+		Sample: map[string]any{
+			"whatsapp_account":   "Support line",
+			"date":               "2026-01-12",
+			"meta_sent":          1250,
+			"meta_delivered":     1200,
+			"local_delivered":    950,
+			"missed_receipts":    250,
+			"stuck_messages":     260,
+			"inferred_delivered": 0,
+			"marked_stale":       260,
+		},
+	},
+	{
+		Key:         KeyAPIVersion,
+		Channel:     ChannelInApp,
+		Description: "WhatsApp account's Graph API version nearing or past its sunset",
+		Subject:     `Graph API {{.api_version}} {{if eq .status "sunset"}}has been retired{{else}}retires soon{{end}} on {{.whatsapp_account}}`,
+		Body: `{{if eq .status "sunset"}}Meta retired Graph API {{.api_version}} on {{.sunset}}; requests for {{.whatsapp_account}} are sent with {{.used_version}} until the account is upgraded.{{else}}Meta retires Graph API {{.api_version}} on {{.sunset}}.{{end}}
+Upgrade {{.whatsapp_account}} to {{.latest_version}} in its account settings.`,
+		Sample: map[string]any{
+			"whatsapp_account": "Support line",
+			"api_version":      "v20.0",
+			"status":           "deprecating",
+			"sunset":           "2026-10-02",
+			"used_version":     "v20.0",
+			"latest_version":   "v24.0",
+		},
+	},
+	{
+		Key:         KeyBotMessageLimit,
+		Channel:     ChannelInApp,
+		Description: "Chatbot or automation trigger stopped for sending a contact too many messages",
+		Subject:     `Automated messages to {{.contact_name}} stopped`,
+		Body: `{{.contact_name}} ({{.contact_phone}}) reached the limit of {{.limit}} automated messages in {{.window_minutes}} minutes on {{.whatsapp_account}}, so further automated messages to them are held back until the window ends.{{if .flow_name}}
+The contact was taken out of the flow "{{.flow_name}}" at step "{{.step}}"; check it for a loop.{{end}}`,
+		Sample: map[string]any{
+			"contact_id":       "b3c1a2d4-0000-4000-8000-000000000000",
+			"contact_phone":    "+15551234567",
+			"contact_name":     "Jane Doe",
+			"whatsapp_account": "Support line",
+			"source":           "chatbot",
+			"limit":            20,
+			"window_minutes":   5,
+			"flow_id":          "9f8e7d6c-0000-4000-8000-000000000000",
+			"flow_name":        "Order status",
+			"step":             "ask_order",
+		},
+	},
+	{
+		Key:         KeyMessageDigest,
+		Channel:     ChannelEmail,
+		Description: "Batched new messages for agents who get notifications as a digest",
+		Subject:     `{{.message_count}} new messages in {{.conversation_count}} conversations`,
+		Body: `Hi {{.user_name}}, you have new messages since your last digest:
+{{range .conversations}}
+{{.contact_name}}: {{.message_count}} new, latest "{{.last_preview}}"{{end}}`,
+		Sample: map[string]any{
+			"user_name":          "Alex",
+			"message_count":      5,
+			"conversation_count": 2,
+			"conversations": []any{
+				map[string]any{"contact_id": "b3c1a2d4-0000-4000-8000-000000000000", "contact_name": "Jane Doe", "message_count": 3, "last_preview": "Is my order on its way?"},
+				map[string]any{"contact_id": "c4d2b3e5-0000-4000-8000-000000000000", "contact_name": "+15557654321", "message_count": 2, "last_preview": "[image]"},
+			},
+		},
+	},
+}
+
+// Lookup returns the definition for a key
+func Lookup(key string) (Definition, bool) {
+	for _, d := range Definitions {
+		if d.Key == key {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Validate checks that a subject and body parse and only use the key's variables
+func Validate(key, subject, body string) error {
+	def, ok := Lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown template %q", key)
+	}
+	if len([]rune(subject)) > maxSubjectLength {
+		return fmt.Errorf("subject can be at most %d characters", maxSubjectLength)
+	}
+	if body == "" {
+		return errors.New("body is required")
+	}
+	if len([]rune(body)) > maxBodyLength {
+		return fmt.Errorf("body can be at most %d characters", maxBodyLength)
+	}
+
+	vars := def.SampleVars()
+	for name, text := range map[string]string{"subject": subject, "body": body} {
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		if err := tmpl.Execute(&bytes.Buffer{}, vars); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// SampleVars returns the definition's example variables with the common ones
+func (d Definition) SampleVars() map[string]any {
+	vars := maps.Clone(commonSample)
+	maps.Copy(vars, d.Sample)
+	return vars
+}
+
+// render executes a template against vars
+func render(name, text string, vars map[string]any) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Vars converts event data to template variables keyed by its JSON field names
+func Vars(data any) map[string]any {
+	vars := map[string]any{}
+	if raw, err := json.Marshal(data); err == nil {
+		_ = json.Unmarshal(raw, &vars)
+	}
+	return vars
+}
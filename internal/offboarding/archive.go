@@ -0,0 +1,143 @@
+package offboarding
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of rows loaded into memory at a time
+const exportBatchSize = 500
+
+// Manifest describes an archive's contents; it is written as manifest.json
+type Manifest struct {
+	OrganizationID   uuid.UUID        `json:"organization_id"`
+	OrganizationName string           `json:"organization_name"`
+	ExportedAt       time.Time        `json:"exported_at"`
+	Tables           map[string]int64 `json:"tables"` // Rows in each <table>.jsonl
+	MediaFiles       int              `json:"media_files"`
+	MissingMedia     []string         `json:"missing_media,omitempty"` // Referenced but no longer in storage
+}
+
+// Export writes a zip archive of the organization's data to w: one JSON Lines file
+// per table, with credentials left out the same way the API leaves them out, the
+// organization's media files under media/, and a manifest.
+func Export(db *gorm.DB, org *models.Organization, mediaRoot string, w io.Writer) (*Manifest, error) {
+	all, err := tables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	zw := zip.NewWriter(w)
+	manifest := &Manifest{
+		OrganizationID:   org.ID,
+		OrganizationName: org.Name,
+		ExportedAt:       time.Now().UTC(),
+		Tables:           make(map[string]int64, len(all)),
+	}
+
+	for _, t := range all {
+		count, err := exportTable(db, zw, t, org.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", t.name, err)
+		}
+		manifest.Tables[t.name] = count
+	}
+
+	paths, err := mediaPaths(db, org.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+	for _, p := range paths {
+		ok, err := exportFile(zw, filepath.Join(mediaRoot, p), path.Join("media", filepath.ToSlash(p)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to export media %s: %w", p, err)
+		}
+		if !ok {
+			manifest.MissingMedia = append(manifest.MissingMedia, p)
+			continue
+		}
+		manifest.MediaFiles++
+	}
+
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// exportTable writes the organization's rows of a table as <table>.jsonl
+func exportTable(db *gorm.DB, zw *zip.Writer, t table, orgID uuid.UUID) (int64, error) {
+	f, err := zw.Create(t.name + ".jsonl")
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(f)
+
+	var count int64
+	rows := reflect.New(reflect.SliceOf(reflect.TypeOf(t.model)))
+	write := func(tx *gorm.DB, _ int) error {
+		batch := rows.Elem()
+		for i := 0; i < batch.Len(); i++ {
+			if err := enc.Encode(batch.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		count += int64(batch.Len())
+		return nil
+	}
+
+	query := db.Model(t.model).Where(t.where, sql.Named("org", orgID))
+	if t.schema.PrioritizedPrimaryField == nil {
+		if err := query.Find(rows.Interface()).Error; err != nil {
+			return 0, err
+		}
+		return count, write(nil, 0)
+	}
+	if err := query.FindInBatches(rows.Interface(), exportBatchSize, write).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// exportFile copies a file into the archive, reporting false if it doesn't exist
+func exportFile(zw *zip.Writer, src, name string) (bool, error) {
+	in, err := os.Open(src)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	out, err := zw.Create(name)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		return false, err
+	}
+	return true, nil
+}
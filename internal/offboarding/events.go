@@ -0,0 +1,32 @@
+package offboarding
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// Event is an entry of an offboarding's audit trail
+func Event(step, detail string) map[string]interface{} {
+	return map[string]interface{}{
+		"step":   step,
+		"detail": detail,
+		"at":     time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// RecordEvent appends an entry to an offboarding's audit trail. The append happens in
+// the database so entries recorded by the API and the worker at the same time are all
+// kept.
+func RecordEvent(db *gorm.DB, offboardingID uuid.UUID, step, detail string) error {
+	entry, err := json.Marshal([]interface{}{Event(step, detail)})
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.OrganizationOffboarding{}).
+		Where("id = ?", offboardingID).
+		Update("events", gorm.Expr("COALESCE(events, '[]'::jsonb) || ?::jsonb", string(entry))).Error
+}
@@ -0,0 +1,78 @@
+// Package offboarding carries out the parts of deleting an organization that touch
+// every table: suspending its messages, exporting its data to an archive and purging
+// it once the grace period ends.
+package offboarding
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"gorm.io/gorm"
+)
+
+// ErrSendingSuspended refuses messages from an organization that is being offboarded
+var ErrSendingSuspended = errors.New("sending is suspended while the organization is offboarded")
+
+// guardTTL bounds how long a started or cancelled offboarding takes to apply on other
+// instances
+const guardTTL = 30 * time.Second
+
+type guardEntry struct {
+	suspended bool
+	expiresAt time.Time
+}
+
+// Guard refuses sends for accounts of organizations being offboarded
+type Guard struct {
+	db      *gorm.DB
+	entries sync.Map // phone ID -> guardEntry
+}
+
+// NewGuard creates a guard backed by db
+func NewGuard(db *gorm.DB) *Guard {
+	return &Guard{db: db}
+}
+
+// Check returns ErrSendingSuspended if the organization that owns account is being
+// offboarded. Use it as whatsapp.Client.SendGuard.
+func (g *Guard) Check(account *whatsapp.Account) error {
+	if account.PhoneID == "" {
+		return nil
+	}
+
+	if e, ok := g.entries.Load(account.PhoneID); ok && time.Now().Before(e.(guardEntry).expiresAt) {
+		if e.(guardEntry).suspended {
+			return ErrSendingSuspended
+		}
+		return nil
+	}
+
+	var suspended bool
+	err := g.db.Raw(`
+		SELECT EXISTS (
+			SELECT 1 FROM whatsapp_accounts wa
+			JOIN organization_offboardings oo ON oo.organization_id = wa.organization_id
+			WHERE wa.phone_id = ? AND wa.deleted_at IS NULL
+			AND oo.status IN ? AND oo.deleted_at IS NULL
+		)`, account.PhoneID, models.OffboardingActiveStatuses).
+		Scan(&suspended).Error
+	if err != nil {
+		return fmt.Errorf("failed to check offboarding status: %w", err)
+	}
+
+	g.entries.Store(account.PhoneID, guardEntry{suspended: suspended, expiresAt: time.Now().Add(guardTTL)})
+	if suspended {
+		return ErrSendingSuspended
+	}
+	return nil
+}
+
+// Invalidate drops cached results so a started or cancelled offboarding applies
+// immediately on this instance
+func (g *Guard) Invalidate() {
+	g.entries.Clear()
+}
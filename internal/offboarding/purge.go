@@ -0,0 +1,84 @@
+package offboarding
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// Result is what a purge deleted and what it had to keep
+type Result struct {
+	Purged       models.JSONB // Rows deleted per table
+	Retained     models.JSONB // Rows kept per write-once table
+	MediaDeleted int
+}
+
+// Purge permanently deletes the organization's data, including soft-deleted rows and
+// its media files. Rows of write-once tables such as the message audit log are kept
+// and counted in Result.Retained.
+//
+// Tables are deleted one statement at a time rather than in a single transaction, so
+// a large organization doesn't hold locks across the whole schema. A table whose rows
+// are still referenced by another table's is retried after the rest, and a purge that
+// fails part way can be run again to finish.
+func Purge(db *gorm.DB, orgID uuid.UUID, mediaRoot string) (*Result, error) {
+	all, err := tables(db)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Purged: models.JSONB{}, Retained: models.JSONB{}}
+
+	paths, err := mediaPaths(db, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media: %w", err)
+	}
+	for _, p := range paths {
+		err := os.Remove(filepath.Join(mediaRoot, p))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to delete media %s: %w", p, err)
+		}
+		if err == nil {
+			result.MediaDeleted++
+		}
+	}
+
+	var pending []table
+	for _, t := range all {
+		if !writeOnce[t.name] {
+			pending = append(pending, t)
+			continue
+		}
+		var kept int64
+		if err := db.Table(t.name).Where(t.where, sql.Named("org", orgID)).Count(&kept).Error; err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", t.name, err)
+		}
+		result.Retained[t.name] = kept
+	}
+
+	for len(pending) > 0 {
+		var remaining []table
+		var lastErr error
+		for _, t := range pending {
+			res := db.Exec("DELETE FROM "+t.name+" WHERE "+t.where, sql.Named("org", orgID))
+			if res.Error != nil {
+				remaining = append(remaining, t)
+				lastErr = fmt.Errorf("failed to purge %s: %w", t.name, res.Error)
+				continue
+			}
+			if res.RowsAffected > 0 {
+				result.Purged[t.name] = res.RowsAffected
+			}
+		}
+		if len(remaining) == len(pending) {
+			return result, lastErr
+		}
+		pending = remaining
+	}
+
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package offboarding
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/shridarpatil/whatomate/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// table is one table of the schema with the condition selecting an organization's rows,
+// which uses @org for the organization ID
+type table struct {
+	name   string
+	model  interface{}
+	schema *schema.Schema
+	where  string
+}
+
+// parentScopes select the organization's rows in tables that don't have an
+// organization_id column, through the rows they belong to
+var parentScopes = map[string]string{
+	"team_members":             "team_id IN (SELECT id FROM teams WHERE organization_id = @org)",
+	"bulk_message_recipients":  "campaign_id IN (SELECT id FROM bulk_message_campaigns WHERE organization_id = @org)",
+	"broadcast_list_members":   "list_id IN (SELECT id FROM broadcast_lists WHERE organization_id = @org)",
+	"chatbot_flow_steps":       "flow_id IN (SELECT id FROM chatbot_flows WHERE organization_id = @org)",
+	"chatbot_session_messages": "session_id IN (SELECT id FROM chatbot_sessions WHERE organization_id = @org)",
+	"partner_members":          "user_id IN (SELECT id FROM users WHERE organization_id = @org)",
+	"partner_api_keys":         "user_id IN (SELECT id FROM users WHERE organization_id = @org)",
+	"conversation_shares":      "(source_organization_id = @org OR target_organization_id = @org)",
+}
+
+// skipped tables don't hold the organization's data: partners are shared by many
+// organizations and offboarding records are kept as confirmation
+var skipped = map[string]bool{
+	"partners":                  true,
+	"organization_offboardings": true,
+}
+
+// writeOnce tables reject deletes; their rows are exported but kept after the purge
+var writeOnce = map[string]bool{
+	"message_audit_records": true,
+}
+
+// tables returns every table holding the organization's data, those scoped through
+// their parent rows first and the organization itself last, so parent rows are still
+// there to find children by when they are deleted. A model that can't be scoped is an
+// error rather than being left behind.
+func tables(db *gorm.DB) ([]table, error) {
+	var children, owned []table
+	var org *table
+	for _, m := range database.GetMigrationModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m.Model); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", m.Name, err)
+		}
+		t := table{name: stmt.Schema.Table, model: m.Model, schema: stmt.Schema}
+
+		switch {
+		case skipped[t.name]:
+			continue
+		case t.name == "organizations":
+			t.where = "id = @org"
+			org = &t
+		case parentScopes[t.name] != "":
+			t.where = parentScopes[t.name]
+			children = append(children, t)
+		case stmt.Schema.LookUpField("organization_id") != nil:
+			t.where = "organization_id = @org"
+			owned = append(owned, t)
+		default:
+			return nil, fmt.Errorf("no offboarding scope for table %s", t.name)
+		}
+	}
+	if org == nil {
+		return nil, fmt.Errorf("organizations table not found")
+	}
+	return append(append(children, owned...), *org), nil
+}
+
+// mediaPaths returns the organization's media files, relative to media storage
+func mediaPaths(db *gorm.DB, orgID interface{}) ([]string, error) {
+	var paths []string
+	err := db.Raw(`
+		SELECT DISTINCT media_url FROM messages WHERE organization_id = ? AND media_url <> ''
+		UNION
+		SELECT DISTINCT media_path FROM stickers WHERE organization_id = ? AND media_path <> ''`,
+		orgID, orgID).Scan(&paths).Error
+	if err != nil {
+		return nil, err
+	}
+
+	local := paths[:0]
+	for _, p := range paths {
+		// Skip remote URLs and anything that could escape media storage
+		if strings.Contains(p, "://") || strings.Contains(p, "..") || filepath.IsAbs(p) {
+			continue
+		}
+		local = append(local, p)
+	}
+	return local, nil
+}
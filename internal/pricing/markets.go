@@ -0,0 +1,115 @@
+package pricing
+
+// defaultRates are approximate USD per-message list prices of Meta's rate card. Meta
+// revises them every few months, so estimates made with them are a guide, not a quote.
+var defaultRates = map[string]Rates{
+	"Argentina":                        {Marketing: 0.0618, Utility: 0.0260, Authentication: 0.0260},
+	"Brazil":                           {Marketing: 0.0625, Utility: 0.0068, Authentication: 0.0315},
+	"Chile":                            {Marketing: 0.0889, Utility: 0.0200, Authentication: 0.0527},
+	"Colombia":                         {Marketing: 0.0125, Utility: 0.0002, Authentication: 0.0077},
+	"Egypt":                            {Marketing: 0.0644, Utility: 0.0036, Authentication: 0.0650},
+	"France":                           {Marketing: 0.0859, Utility: 0.0300, Authentication: 0.0408},
+	"Germany":                          {Marketing: 0.1365, Utility: 0.0550, Authentication: 0.0768},
+	"India":                            {Marketing: 0.0107, Utility: 0.0014, Authentication: 0.0014},
+	"Indonesia":                        {Marketing: 0.0411, Utility: 0.0250, Authentication: 0.0250},
+	"Israel":                           {Marketing: 0.0353, Utility: 0.0053, Authentication: 0.0159},
+	"Italy":                            {Marketing: 0.0691, Utility: 0.0300, Authentication: 0.0384},
+	"Malaysia":                         {Marketing: 0.0860, Utility: 0.0140, Authentication: 0.0140},
+	"Mexico":                           {Marketing: 0.0305, Utility: 0.0085, Authentication: 0.0135},
+	"Netherlands":                      {Marketing: 0.1597, Utility: 0.0500, Authentication: 0.0721},
+	"Nigeria":                          {Marketing: 0.0516, Utility: 0.0067, Authentication: 0.0067},
+	"Pakistan":                         {Marketing: 0.0473, Utility: 0.0054, Authentication: 0.0054},
+	"Peru":                             {Marketing: 0.0703, Utility: 0.0200, Authentication: 0.0383},
+	"Russia":                           {Marketing: 0.0802, Utility: 0.0400, Authentication: 0.0400},
+	"Saudi Arabia":                     {Marketing: 0.0455, Utility: 0.0115, Authentication: 0.0107},
+	"South Africa":                     {Marketing: 0.0379, Utility: 0.0076, Authentication: 0.0076},
+	"Spain":                            {Marketing: 0.0615, Utility: 0.0200, Authentication: 0.0276},
+	"Turkey":                           {Marketing: 0.0109, Utility: 0.0053, Authentication: 0.0053},
+	"United Arab Emirates":             {Marketing: 0.0384, Utility: 0.0157, Authentication: 0.0157},
+	"United Kingdom":                   {Marketing: 0.0529, Utility: 0.0220, Authentication: 0.0358},
+	"North America":                    {Marketing: 0.0250, Utility: 0.0040, Authentication: 0.0135},
+	"Rest of Africa":                   {Marketing: 0.0225, Utility: 0.0040, Authentication: 0.0040},
+	"Rest of Asia Pacific":             {Marketing: 0.0732, Utility: 0.0113, Authentication: 0.0113},
+	"Rest of Central & Eastern Europe": {Marketing: 0.0860, Utility: 0.0212, Authentication: 0.0212},
+	"Rest of Latin America":            {Marketing: 0.0740, Utility: 0.0113, Authentication: 0.0113},
+	"Rest of Middle East":              {Marketing: 0.0341, Utility: 0.0091, Authentication: 0.0091},
+	"Rest of Western Europe":           {Marketing: 0.0592, Utility: 0.0171, Authentication: 0.0171},
+	OtherMarket:                        {Marketing: 0.0604, Utility: 0.0077, Authentication: 0.0077},
+}
+
+// countryMarkets maps countries to the market they are billed in. Countries not
+// listed are billed as OtherMarket.
+var countryMarkets = map[string]string{
+	"AR": "Argentina",
+	"BR": "Brazil",
+	"CL": "Chile",
+	"CO": "Colombia",
+	"EG": "Egypt",
+	"FR": "France",
+	"DE": "Germany",
+	"IN": "India",
+	"ID": "Indonesia",
+	"IL": "Israel",
+	"IT": "Italy",
+	"MY": "Malaysia",
+	"MX": "Mexico",
+	"NL": "Netherlands",
+	"NG": "Nigeria",
+	"PK": "Pakistan",
+	"PE": "Peru",
+	"RU": "Russia",
+	"SA": "Saudi Arabia",
+	"ZA": "South Africa",
+	"ES": "Spain",
+	"TR": "Turkey",
+	"AE": "United Arab Emirates",
+	"GB": "United Kingdom",
+
+	"US": "North America",
+	"CA": "North America",
+
+	"DZ": "Rest of Africa", "AO": "Rest of Africa", "BJ": "Rest of Africa", "BW": "Rest of Africa",
+	"BF": "Rest of Africa", "BI": "Rest of Africa", "CM": "Rest of Africa", "TD": "Rest of Africa",
+	"CG": "Rest of Africa", "ER": "Rest of Africa", "ET": "Rest of Africa", "GA": "Rest of Africa",
+	"GM": "Rest of Africa", "GH": "Rest of Africa", "GW": "Rest of Africa", "CI": "Rest of Africa",
+	"KE": "Rest of Africa", "LS": "Rest of Africa", "LR": "Rest of Africa", "LY": "Rest of Africa",
+	"MG": "Rest of Africa", "MW": "Rest of Africa", "ML": "Rest of Africa", "MR": "Rest of Africa",
+	"MA": "Rest of Africa", "MZ": "Rest of Africa", "NA": "Rest of Africa", "NE": "Rest of Africa",
+	"RW": "Rest of Africa", "SN": "Rest of Africa", "SL": "Rest of Africa", "SO": "Rest of Africa",
+	"SS": "Rest of Africa", "SD": "Rest of Africa", "SZ": "Rest of Africa", "TZ": "Rest of Africa",
+	"TG": "Rest of Africa", "TN": "Rest of Africa", "UG": "Rest of Africa", "ZM": "Rest of Africa",
+	"ZW": "Rest of Africa",
+
+	"AF": "Rest of Asia Pacific", "AU": "Rest of Asia Pacific", "BD": "Rest of Asia Pacific",
+	"KH": "Rest of Asia Pacific", "CN": "Rest of Asia Pacific", "HK": "Rest of Asia Pacific",
+	"JP": "Rest of Asia Pacific", "LA": "Rest of Asia Pacific", "MN": "Rest of Asia Pacific",
+	"NP": "Rest of Asia Pacific", "NZ": "Rest of Asia Pacific", "PG": "Rest of Asia Pacific",
+	"PH": "Rest of Asia Pacific", "SG": "Rest of Asia Pacific", "LK": "Rest of Asia Pacific",
+	"TW": "Rest of Asia Pacific", "TJ": "Rest of Asia Pacific", "TH": "Rest of Asia Pacific",
+	"TM": "Rest of Asia Pacific", "UZ": "Rest of Asia Pacific", "VN": "Rest of Asia Pacific",
+
+	"AL": "Rest of Central & Eastern Europe", "AM": "Rest of Central & Eastern Europe",
+	"AZ": "Rest of Central & Eastern Europe", "BY": "Rest of Central & Eastern Europe",
+	"BG": "Rest of Central & Eastern Europe", "HR": "Rest of Central & Eastern Europe",
+	"CZ": "Rest of Central & Eastern Europe", "GE": "Rest of Central & Eastern Europe",
+	"GR": "Rest of Central & Eastern Europe", "HU": "Rest of Central & Eastern Europe",
+	"LV": "Rest of Central & Eastern Europe", "LT": "Rest of Central & Eastern Europe",
+	"MK": "Rest of Central & Eastern Europe", "MD": "Rest of Central & Eastern Europe",
+	"PL": "Rest of Central & Eastern Europe", "RO": "Rest of Central & Eastern Europe",
+	"RS": "Rest of Central & Eastern Europe", "SK": "Rest of Central & Eastern Europe",
+	"SI": "Rest of Central & Eastern Europe", "UA": "Rest of Central & Eastern Europe",
+
+	"BO": "Rest of Latin America", "CR": "Rest of Latin America", "DO": "Rest of Latin America",
+	"EC": "Rest of Latin America", "SV": "Rest of Latin America", "GT": "Rest of Latin America",
+	"HT": "Rest of Latin America", "HN": "Rest of Latin America", "JM": "Rest of Latin America",
+	"NI": "Rest of Latin America", "PA": "Rest of Latin America", "PY": "Rest of Latin America",
+	"PR": "Rest of Latin America", "UY": "Rest of Latin America", "VE": "Rest of Latin America",
+
+	"BH": "Rest of Middle East", "IQ": "Rest of Middle East", "JO": "Rest of Middle East",
+	"KW": "Rest of Middle East", "LB": "Rest of Middle East", "OM": "Rest of Middle East",
+	"QA": "Rest of Middle East", "YE": "Rest of Middle East",
+
+	"AT": "Rest of Western Europe", "BE": "Rest of Western Europe", "DK": "Rest of Western Europe",
+	"FI": "Rest of Western Europe", "IE": "Rest of Western Europe", "NO": "Rest of Western Europe",
+	"PT": "Rest of Western Europe", "SE": "Rest of Western Europe", "CH": "Rest of Western Europe",
+}
@@ -0,0 +1,124 @@
+// Package pricing estimates what Meta charges for template messages. Meta bills each
+// delivered template message at a rate set by the template's category and the market
+// of the recipient's country. The built-in rate card holds approximate USD list
+// prices; deployments on other currencies or negotiated rates load their own.
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Template categories Meta prices separately
+const (
+	Marketing      = "marketing"
+	Utility        = "utility"
+	Authentication = "authentication"
+)
+
+// OtherMarket prices countries that don't belong to a listed market
+const OtherMarket = "Other"
+
+// Rates are the per-message prices of a market
+type Rates struct {
+	Marketing      float64 `json:"marketing"`
+	Utility        float64 `json:"utility"`
+	Authentication float64 `json:"authentication"`
+}
+
+// For returns the price of a message of category. Unknown categories are priced as
+// marketing, the highest rate, so estimates err on the high side.
+func (r Rates) For(category string) float64 {
+	switch Category(category) {
+	case Utility:
+		return r.Utility
+	case Authentication:
+		return r.Authentication
+	default:
+		return r.Marketing
+	}
+}
+
+// RateCard prices template messages per market
+type RateCard struct {
+	Currency string           `json:"currency"`
+	Markets  map[string]Rates `json:"markets"`
+}
+
+// Category normalizes a template category, e.g. "MARKETING", to the one it's billed as
+func Category(category string) string {
+	switch c := strings.ToLower(category); c {
+	case Utility, Authentication:
+		return c
+	default:
+		return Marketing
+	}
+}
+
+// Market returns the market a country, as an ISO 3166-1 alpha-2 code, is billed in
+func Market(country string) string {
+	if market, ok := countryMarkets[strings.ToUpper(country)]; ok {
+		return market
+	}
+	return OtherMarket
+}
+
+// Rate returns the market of country and the price of a message of category to it
+func (c *RateCard) Rate(country, category string) (string, float64) {
+	market := Market(country)
+	rates, ok := c.Markets[market]
+	if !ok {
+		market = OtherMarket
+		rates = c.Markets[OtherMarket]
+	}
+	return market, rates.For(category)
+}
+
+// Default returns the built-in rate card
+func Default() *RateCard {
+	markets := make(map[string]Rates, len(defaultRates))
+	for market, rates := range defaultRates {
+		markets[market] = rates
+	}
+	return &RateCard{Currency: "USD", Markets: markets}
+}
+
+// Load returns the built-in rate card with the markets of the JSON rate card at path
+// replacing its own, or the built-in card when path is empty. A card that sets a
+// currency must price every market, since the built-in USD rates can't be mixed in.
+func Load(path string) (*RateCard, error) {
+	card := Default()
+	if path == "" {
+		return card, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rate card: %w", err)
+	}
+	var custom RateCard
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("parsing rate card: %w", err)
+	}
+
+	if custom.Currency != "" && !strings.EqualFold(custom.Currency, card.Currency) {
+		for market := range card.Markets {
+			if _, ok := custom.Markets[market]; !ok {
+				return nil, fmt.Errorf("rate card in %s has no rates for market %q", custom.Currency, market)
+			}
+		}
+		card.Currency = strings.ToUpper(custom.Currency)
+	}
+	for market, rates := range custom.Markets {
+		if _, ok := card.Markets[market]; !ok {
+			return nil, fmt.Errorf("unknown market %q in rate card", market)
+		}
+		if rates.Marketing < 0 || rates.Utility < 0 || rates.Authentication < 0 {
+			return nil, fmt.Errorf("negative rate for market %q in rate card", market)
+		}
+		card.Markets[market] = rates
+	}
+	return card, nil
+}
@@ -0,0 +1,152 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shridarpatil/whatomate/internal/config"
+)
+
+const (
+	apnsProductionURL = "https://api.push.apple.com"
+	apnsSandboxURL    = "https://api.sandbox.push.apple.com"
+
+	// apnsTokenLifetime is how long a provider token is reused. APNs rejects tokens
+	// older than an hour and refreshed more than once every 20 minutes.
+	apnsTokenLifetime = 50 * time.Minute
+
+	// apnsMaxCollapseID is the longest apns-collapse-id APNs accepts, in bytes
+	apnsMaxCollapseID = 64
+)
+
+// apnsSender sends through the APNs HTTP/2 API with token-based authentication
+type apnsSender struct {
+	url    string
+	topic  string
+	keyID  string
+	teamID string
+	key    *ecdsa.PrivateKey
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func newAPNsSender(cfg config.PushConfig) (*apnsSender, error) {
+	if cfg.APNsKeyID == "" || cfg.APNsTeamID == "" || cfg.APNsTopic == "" {
+		return nil, fmt.Errorf("apns_key_id, apns_team_id and apns_topic are required")
+	}
+	data, err := os.ReadFile(cfg.APNsKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	url := apnsProductionURL
+	if cfg.APNsSandbox {
+		url = apnsSandboxURL
+	}
+	return &apnsSender{
+		url:    url,
+		topic:  cfg.APNsTopic,
+		keyID:  cfg.APNsKeyID,
+		teamID: cfg.APNsTeamID,
+		key:    key,
+		// The default transport negotiates HTTP/2, which APNs requires
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// providerToken returns the signed token APNs authenticates requests with
+func (a *apnsSender) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Since(a.issuedAt) < apnsTokenLifetime {
+		return a.token, nil
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": a.teamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = a.keyID
+	signed, err := token.SignedString(a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issuedAt = signed, now
+	return signed, nil
+}
+
+func (a *apnsSender) send(ctx context.Context, token string, n Notification) error {
+	aps := map[string]any{
+		"alert": map[string]string{"title": n.Title, "body": n.Body},
+		"sound": "default",
+	}
+	if n.CollapseKey != "" {
+		// Group the conversation's notifications together too
+		aps["thread-id"] = n.CollapseKey
+	}
+	body := map[string]any{"aps": aps}
+	for k, v := range n.Data {
+		body[k] = v
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	providerToken, err := a.providerToken()
+	if err != nil {
+		return fmt.Errorf("failed to sign provider token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url+"/3/device/"+token, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	if n.CollapseKey != "" && len(n.CollapseKey) <= apnsMaxCollapseID {
+		req.Header.Set("apns-collapse-id", n.CollapseKey)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var result struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.Unmarshal(respBody, &result)
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		result.Reason == "BadDeviceToken",
+		result.Reason == "DeviceTokenNotForTopic",
+		result.Reason == "Unregistered":
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("apns returned status %d: %s", resp.StatusCode, result.Reason)
+}
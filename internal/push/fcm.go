@@ -0,0 +1,120 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// fcmSender sends through the Firebase Cloud Messaging HTTP v1 API, authenticated as
+// a service account
+type fcmSender struct {
+	url    string
+	tokens oauth2.TokenSource
+	client *http.Client
+}
+
+func newFCMSender(cfg config.PushConfig) (*fcmSender, error) {
+	data, err := os.ReadFile(cfg.FCMCredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	jwtConfig, err := google.JWTConfigFromJSON(data, fcmScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account credentials: %w", err)
+	}
+
+	projectID := cfg.FCMProjectID
+	if projectID == "" {
+		var account struct {
+			ProjectID string `json:"project_id"`
+		}
+		_ = json.Unmarshal(data, &account)
+		projectID = account.ProjectID
+	}
+	if projectID == "" {
+		return nil, fmt.Errorf("no project ID in the credentials or config")
+	}
+
+	return &fcmSender{
+		url:    "https://fcm.googleapis.com/v1/projects/" + projectID + "/messages:send",
+		tokens: jwtConfig.TokenSource(context.Background()),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (f *fcmSender) send(ctx context.Context, token string, n Notification) error {
+	android := map[string]any{"priority": "high"}
+	apns := map[string]any{}
+	if n.CollapseKey != "" {
+		// The tag replaces the notification on screen; the collapse key drops undelivered ones
+		android["collapse_key"] = n.CollapseKey
+		android["notification"] = map[string]string{"tag": n.CollapseKey}
+		apns["headers"] = map[string]string{"apns-collapse-id": n.CollapseKey}
+	}
+	message := map[string]any{
+		"token":        token,
+		"notification": map[string]string{"title": n.Title, "body": n.Body},
+		"android":      android,
+		"apns":         apns,
+	}
+	if len(n.Data) > 0 {
+		message["data"] = n.Data
+	}
+	payload, err := json.Marshal(map[string]any{"message": message})
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := f.tokens.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken.AccessToken)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var result struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &result)
+	for _, detail := range result.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return ErrInvalidToken
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("fcm returned status %d: %s", resp.StatusCode, result.Error.Message)
+}
@@ -0,0 +1,86 @@
+// Package push sends mobile push notifications to agents' devices through Firebase
+// Cloud Messaging (Android) and the Apple Push Notification service (iOS).
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shridarpatil/whatomate/internal/config"
+)
+
+// Device token platforms
+const (
+	PlatformFCM  = "fcm"
+	PlatformAPNs = "apns"
+)
+
+// ErrInvalidToken is returned when the provider no longer accepts a device token, e.g.
+// because the app was uninstalled. The token should be forgotten.
+var ErrInvalidToken = errors.New("device token is no longer valid")
+
+// Notification is a push notification to one device
+type Notification struct {
+	Title string
+	Body  string
+	// Notifications with the same collapse key replace each other on the device, so a
+	// busy conversation shows one notification rather than one per message
+	CollapseKey string
+	Data        map[string]string // Passed to the app with the notification
+}
+
+// sender delivers notifications through one provider
+type sender interface {
+	send(ctx context.Context, token string, n Notification) error
+}
+
+// Service sends push notifications through the configured providers
+type Service struct {
+	senders map[string]sender
+}
+
+// New creates a push service with each provider that has credentials configured. A
+// provider whose credentials can't be loaded is left out and reported in the error;
+// the returned service is always usable.
+func New(cfg config.PushConfig) (*Service, error) {
+	s := &Service{senders: make(map[string]sender)}
+	var errs []error
+
+	if cfg.FCMCredentialsFile != "" {
+		fcm, err := newFCMSender(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("fcm: %w", err))
+		} else {
+			s.senders[PlatformFCM] = fcm
+		}
+	}
+	if cfg.APNsKeyFile != "" {
+		apns, err := newAPNsSender(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("apns: %w", err))
+		} else {
+			s.senders[PlatformAPNs] = apns
+		}
+	}
+
+	return s, errors.Join(errs...)
+}
+
+// Enabled reports whether notifications can be sent to devices of platform
+func (s *Service) Enabled(platform string) bool {
+	return s != nil && s.senders[platform] != nil
+}
+
+// ValidPlatform reports whether platform is a supported device token platform
+func ValidPlatform(platform string) bool {
+	return platform == PlatformFCM || platform == PlatformAPNs
+}
+
+// Send delivers a notification to the device with token
+func (s *Service) Send(ctx context.Context, platform, token string, n Notification) error {
+	if !s.Enabled(platform) {
+		return fmt.Errorf("push notifications are not configured for %s", platform)
+	}
+	return s.senders[platform].send(ctx, token, n)
+}
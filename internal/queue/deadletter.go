@@ -0,0 +1,267 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DeadLetterStreamName is the Redis stream campaign jobs are moved to once they
+	// have failed MaxJobAttempts times
+	DeadLetterStreamName = "whatomate:campaigns:dead"
+
+	// DeadLetterMaxLen caps the dead letter stream; the oldest entries are trimmed first
+	DeadLetterMaxLen = 10000
+
+	// MaxJobAttempts is how many times a campaign job is handled before it is dead-lettered
+	MaxJobAttempts = 5
+
+	// JobRetryDelay is the wait before a failed job's first retry, doubled for each
+	// retry after that
+	JobRetryDelay = 5 * time.Second
+
+	// RetryQueueKey is the Redis sorted set failed campaign jobs wait out their retry
+	// backoff in, scored by when they are due in Unix milliseconds
+	RetryQueueKey = "whatomate:campaigns:retry"
+
+	// retryPromoteCount is how many due retries a consumer moves back at a time
+	retryPromoteCount = 100
+)
+
+// retryingJob is a member of RetryQueueKey: a failed job and the stream it goes back to
+type retryingJob struct {
+	ID       string `json:"id"` // Entry ID of the failed job, keeping members unique
+	Stream   string `json:"stream"`
+	Type     string `json:"type"`
+	Payload  string `json:"payload"`
+	Attempts string `json:"attempts"`
+}
+
+// promoteRetries moves up to ARGV[2] jobs of the retry set at KEYS[1] that are due by
+// ARGV[1] back onto their streams, all at once so each is added back exactly once
+var promoteRetries = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+for _, member in ipairs(due) do
+	local job = cjson.decode(member)
+	redis.call('XADD', job.stream, '*', 'type', job.type, 'payload', job.payload, 'attempts', job.attempts)
+	redis.call('ZREM', KEYS[1], member)
+end
+return #due
+`)
+
+// ErrDeadJobNotFound is returned for a dead job ID that isn't in the dead letter stream
+var ErrDeadJobNotFound = errors.New("dead job not found")
+
+// errMalformedJob marks a stream message that can never be handled, so it is
+// dead-lettered without being retried
+var errMalformedJob = errors.New("malformed job")
+
+// DeadJob is a campaign job that failed too many times to be retried automatically
+type DeadJob struct {
+	ID         string    `json:"id"` // Entry ID in the dead letter stream
+	CampaignID uuid.UUID `json:"campaign_id"`
+	Priority   Priority  `json:"priority"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error"` // From the last attempt
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	FailedAt   time.Time `json:"failed_at"`
+}
+
+// ListDeadJobs returns up to limit dead jobs, newest first
+func (q *RedisQueue) ListDeadJobs(ctx context.Context, limit int64) ([]DeadJob, error) {
+	msgs, err := q.client.XRevRangeN(ctx, DeadLetterStreamName, "+", "-", limit).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter stream: %w", err)
+	}
+
+	jobs := make([]DeadJob, 0, len(msgs))
+	for _, msg := range msgs {
+		jobs = append(jobs, parseDeadJob(msg))
+	}
+	return jobs, nil
+}
+
+// GetDeadJob returns the dead job with the given ID
+func (q *RedisQueue) GetDeadJob(ctx context.Context, id string) (*DeadJob, error) {
+	msgs, err := q.client.XRange(ctx, DeadLetterStreamName, id, id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter stream: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil, ErrDeadJobNotFound
+	}
+	job := parseDeadJob(msgs[0])
+	return &job, nil
+}
+
+// RequeueDeadJob moves a dead job back onto the campaign stream it came from with its
+// attempts reset
+func (q *RedisQueue) RequeueDeadJob(ctx context.Context, id string) error {
+	msgs, err := q.client.XRange(ctx, DeadLetterStreamName, id, id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read dead letter stream: %w", err)
+	}
+	if len(msgs) == 0 {
+		return ErrDeadJobNotFound
+	}
+
+	// Jobs dead-lettered before priorities don't record their stream and were normal
+	stream, _ := msgs[0].Values["stream"].(string)
+	if stream == "" {
+		stream = StreamName
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: stream,
+			Values: map[string]interface{}{
+				"type":    msgs[0].Values["type"],
+				"payload": msgs[0].Values["payload"],
+			},
+		})
+		pipe.XDel(ctx, DeadLetterStreamName, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to requeue dead job: %w", err)
+	}
+
+	q.log.Info("Dead campaign job requeued", "dead_job_id", id, "stream", stream)
+	return nil
+}
+
+// DeleteDeadJob discards a dead job
+func (q *RedisQueue) DeleteDeadJob(ctx context.Context, id string) error {
+	n, err := q.client.XDel(ctx, DeadLetterStreamName, id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to delete dead job: %w", err)
+	}
+	if n == 0 {
+		return ErrDeadJobNotFound
+	}
+	return nil
+}
+
+// parseDeadJob reads a dead letter stream entry. A payload that can't be read,
+// which is how some jobs end up here, leaves the campaign ID zero.
+func parseDeadJob(msg redis.XMessage) DeadJob {
+	job := DeadJob{
+		ID:       msg.ID,
+		Priority: PriorityNormal,
+		Attempts: messageAttempts(msg),
+	}
+	job.Error, _ = msg.Values["error"].(string)
+	if failedAt, ok := msg.Values["failed_at"].(string); ok {
+		job.FailedAt, _ = time.Parse(time.RFC3339, failedAt)
+	}
+	if payload, ok := msg.Values["payload"].(string); ok {
+		var campaignJob CampaignJob
+		if json.Unmarshal([]byte(payload), &campaignJob) == nil {
+			job.CampaignID = campaignJob.CampaignID
+			if campaignJob.Priority != "" {
+				job.Priority = campaignJob.Priority
+			}
+			job.EnqueuedAt = campaignJob.EnqueuedAt
+		}
+	}
+	return job
+}
+
+// messageAttempts returns how many times a stream message has failed before
+func messageAttempts(msg redis.XMessage) int {
+	s, _ := msg.Values["attempts"].(string)
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// retryOrDeadLetter handles a message of stream whose handler failed: it waits out a
+// backoff in the retry set, which consumers move it back to the stream from once it
+// is due, or is moved to the dead letter stream once it has failed MaxJobAttempts
+// times. Either way the original is acknowledged and removed, and the consumer
+// carries on with other jobs meanwhile.
+func (c *RedisConsumer) retryOrDeadLetter(ctx context.Context, stream string, msg redis.XMessage, handlerErr error) {
+	attempts := messageAttempts(msg) + 1
+
+	if attempts < MaxJobAttempts && !errors.Is(handlerErr, errMalformedJob) {
+		delay := JobRetryDelay << (attempts - 1)
+		c.log.Warn("Retrying campaign job", "message_id", msg.ID, "attempt", attempts, "max_attempts", MaxJobAttempts, "delay", delay)
+		c.deferMessage(ctx, stream, msg, attempts, time.Now().Add(delay))
+		return
+	}
+
+	c.log.Error("Moving campaign job to dead letter stream", "message_id", msg.ID, "attempts", attempts, "error", handlerErr)
+	c.moveMessage(ctx, stream, msg, &redis.XAddArgs{
+		Stream: DeadLetterStreamName,
+		MaxLen: DeadLetterMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"type":        msg.Values["type"],
+			"payload":     msg.Values["payload"],
+			"attempts":    strconv.Itoa(attempts),
+			"error":       handlerErr.Error(),
+			"failed_at":   time.Now().UTC().Format(time.RFC3339),
+			"original_id": msg.ID,
+			"stream":      stream,
+		},
+	})
+}
+
+// moveMessage adds a copy of msg as described by args and acknowledges and removes
+// the original from stream, all at once
+func (c *RedisConsumer) moveMessage(ctx context.Context, stream string, msg redis.XMessage, args *redis.XAddArgs) {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, args)
+		pipe.XAck(ctx, stream, ConsumerGroup, msg.ID)
+		pipe.XDel(ctx, stream, msg.ID)
+		return nil
+	})
+	if err != nil {
+		// The message stays pending and is claimed again on the next start
+		c.log.Error("Failed to move campaign job", "error", err, "message_id", msg.ID, "stream", args.Stream)
+	}
+}
+
+// deferMessage puts msg in the retry set, due at due, and acknowledges and removes
+// the original from stream, all at once
+func (c *RedisConsumer) deferMessage(ctx context.Context, stream string, msg redis.XMessage, attempts int, due time.Time) {
+	ctx = context.WithoutCancel(ctx)
+	typ, _ := msg.Values["type"].(string)
+	payload, _ := msg.Values["payload"].(string)
+	member, err := json.Marshal(retryingJob{ID: msg.ID, Stream: stream, Type: typ, Payload: payload, Attempts: strconv.Itoa(attempts)})
+	if err != nil {
+		c.log.Error("Failed to defer campaign job", "error", err, "message_id", msg.ID)
+		return
+	}
+
+	_, err = c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, RetryQueueKey, redis.Z{Score: float64(due.UnixMilli()), Member: string(member)})
+		pipe.XAck(ctx, stream, ConsumerGroup, msg.ID)
+		pipe.XDel(ctx, stream, msg.ID)
+		return nil
+	})
+	if err != nil {
+		// The message stays pending and is claimed again on the next start
+		c.log.Error("Failed to defer campaign job", "error", err, "message_id", msg.ID)
+	}
+}
+
+// promoteDueRetries moves the retries whose backoff has ended back onto their streams
+func (c *RedisConsumer) promoteDueRetries(ctx context.Context) {
+	n, err := promoteRetries.Run(ctx, c.client, []string{RetryQueueKey}, time.Now().UnixMilli(), retryPromoteCount).Int()
+	if err != nil {
+		if ctx.Err() == nil {
+			c.log.Error("Failed to move due campaign job retries", "error", err)
+		}
+		return
+	}
+	if n > 0 {
+		c.log.Info("Campaign job retries due", "count", n)
+	}
+}
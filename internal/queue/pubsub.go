@@ -3,6 +3,8 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
@@ -12,6 +14,17 @@ import (
 const (
 	// CampaignStatsChannel is the Redis pub/sub channel for campaign stats updates
 	CampaignStatsChannel = "whatomate:campaign_stats"
+
+	// CampaignEventsChannel is the Redis pub/sub channel for campaign lifecycle events
+	CampaignEventsChannel = "whatomate:campaign_events"
+
+	// CampaignControlChannel is the Redis pub/sub channel workers receive pause and
+	// cancel signals on
+	CampaignControlChannel = "whatomate:campaign_control"
+
+	// JobUpdatesChannel is the Redis pub/sub channel background job progress is
+	// published on, for the server to push over the WebSocket
+	JobUpdatesChannel = "whatomate:job_updates"
 )
 
 // CampaignStatsUpdate represents a campaign stats update message
@@ -23,24 +36,211 @@ type CampaignStatsUpdate struct {
 	DeliveredCount int       `json:"delivered_count"`
 	ReadCount      int       `json:"read_count"`
 	FailedCount    int       `json:"failed_count"`
+	CancelledCount int       `json:"cancelled_count,omitempty"` // Recipients left unsent, once the campaign is cancelled
+
+	// While the campaign is processing, how fast its recipients are being sent or
+	// failed across all workers sending it, and when the rest should be done at that
+	// rate. Both are missing until the worker has measured a rate.
+	TotalRecipients       int        `json:"total_recipients,omitempty"`
+	ProcessedPerSecond    float64    `json:"processed_per_second,omitempty"`
+	EstimatedCompletionAt *time.Time `json:"estimated_completion_at,omitempty"`
+}
+
+// Default coalescing limits for QueueCampaignStats
+const (
+	DefaultStatsInterval  = 500 * time.Millisecond
+	DefaultStatsBatchSize = 50
+)
+
+// CampaignEvent is a campaign lifecycle transition reported by a worker
+type CampaignEvent struct {
+	ID             string    `json:"id"` // Unique per event so only one server instance handles it
+	CampaignID     string    `json:"campaign_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Event          string    `json:"event"` // started, paused, completed, failed, recipient_sent, recipient_failed
+	Error          string    `json:"error,omitempty"`
+
+	Recipient *CampaignRecipientUpdate `json:"recipient,omitempty"` // For recipient events
+}
+
+// CampaignRecipientUpdate is the outcome of a send to one of a campaign's recipients
+type CampaignRecipientUpdate struct {
+	RecipientID       string `json:"recipient_id"`
+	PhoneNumber       string `json:"phone_number"`
+	RecipientName     string `json:"recipient_name,omitempty"`
+	Status            string `json:"status"` // sent, failed
+	Variant           string `json:"variant,omitempty"`
+	MessageID         string `json:"message_id,omitempty"`
+	WhatsAppMessageID string `json:"whatsapp_message_id,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// CampaignControl tells workers a campaign was paused or cancelled, so a run sending
+// it stops straight away
+type CampaignControl struct {
+	CampaignID string `json:"campaign_id"`
+	Status     string `json:"status"` // paused, cancelled
+}
+
+// JobUpdate is the progress of a background job
+type JobUpdate struct {
+	JobID          uuid.UUID  `json:"job_id"`
+	OrganizationID uuid.UUID  `json:"organization_id"`
+	CreatedByID    *uuid.UUID `json:"created_by_id,omitempty"` // Pushed only to this user when set
+	Type           string     `json:"type"`
+	Status         string     `json:"status"`
+	Progress       int        `json:"progress"`
+	Processed      int        `json:"processed"`
+	Total          int        `json:"total"`
+	ErrorCount     int        `json:"error_count"`
+	ResultURL      string     `json:"result_url,omitempty"`
+	Error          string     `json:"error,omitempty"`
 }
 
 // Publisher publishes messages to Redis pub/sub channels
 type Publisher struct {
 	client *redis.Client
 	log    logf.Logger
+
+	// Coalescing of per-recipient campaign stats
+	statsInterval  time.Duration
+	statsBatchSize int
+	statsMu        sync.Mutex
+	pendingStats   map[string]*pendingCampaignStats
+}
+
+// pendingCampaignStats tracks the newest unpublished update for a campaign
+type pendingCampaignStats struct {
+	update        *CampaignStatsUpdate // nil when nothing is waiting to be published
+	queued        int
+	lastPublished time.Time
 }
 
 // NewPublisher creates a new Redis publisher
 func NewPublisher(client *redis.Client, log logf.Logger) *Publisher {
 	return &Publisher{
-		client: client,
-		log:    log,
+		client:         client,
+		log:            log,
+		statsInterval:  DefaultStatsInterval,
+		statsBatchSize: DefaultStatsBatchSize,
+		pendingStats:   make(map[string]*pendingCampaignStats),
+	}
+}
+
+// SetStatsCoalescing sets how often queued campaign stats are published: at most once per
+// interval, or sooner once batchSize updates have been queued. Non-positive values keep the default.
+func (p *Publisher) SetStatsCoalescing(interval time.Duration, batchSize int) {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	if interval > 0 {
+		p.statsInterval = interval
+	}
+	if batchSize > 0 {
+		p.statsBatchSize = batchSize
 	}
 }
 
-// PublishCampaignStats publishes a campaign stats update
+// PublishCampaignStats publishes a campaign stats update immediately, superseding any queued update
 func (p *Publisher) PublishCampaignStats(ctx context.Context, update *CampaignStatsUpdate) error {
+	p.statsMu.Lock()
+	delete(p.pendingStats, update.CampaignID)
+	p.statsMu.Unlock()
+
+	return p.publishCampaignStats(ctx, update)
+}
+
+// QueueCampaignStats coalesces per-recipient stats updates. Only the newest update is kept
+// and it is published once the interval has elapsed or the batch size is reached. Call
+// FlushCampaignStats (or PublishCampaignStats with the final status) when processing stops.
+func (p *Publisher) QueueCampaignStats(ctx context.Context, update *CampaignStatsUpdate) error {
+	p.statsMu.Lock()
+	pending, ok := p.pendingStats[update.CampaignID]
+	if !ok {
+		pending = &pendingCampaignStats{}
+		p.pendingStats[update.CampaignID] = pending
+	}
+	pending.update = update
+	pending.queued++
+
+	now := time.Now()
+	if pending.queued < p.statsBatchSize && now.Sub(pending.lastPublished) < p.statsInterval {
+		p.statsMu.Unlock()
+		return nil
+	}
+	pending.update = nil
+	pending.queued = 0
+	pending.lastPublished = now
+	p.statsMu.Unlock()
+
+	return p.publishCampaignStats(ctx, update)
+}
+
+// FlushCampaignStats publishes the queued update for a campaign, if any, and forgets the campaign
+func (p *Publisher) FlushCampaignStats(ctx context.Context, campaignID string) error {
+	p.statsMu.Lock()
+	pending, ok := p.pendingStats[campaignID]
+	delete(p.pendingStats, campaignID)
+	p.statsMu.Unlock()
+
+	if !ok || pending.update == nil {
+		return nil
+	}
+	return p.publishCampaignStats(ctx, pending.update)
+}
+
+// PublishCampaignEvent publishes a campaign lifecycle event
+func (p *Publisher) PublishCampaignEvent(ctx context.Context, event *CampaignEvent) error {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Publish(ctx, CampaignEventsChannel, payload).Err(); err != nil {
+		p.log.Error("Failed to publish campaign event", "error", err, "campaign_id", event.CampaignID, "event", event.Event)
+		return err
+	}
+
+	p.log.Debug("Published campaign event", "campaign_id", event.CampaignID, "event", event.Event)
+	return nil
+}
+
+// PublishCampaignControl signals workers that a campaign was paused or cancelled
+func (p *Publisher) PublishCampaignControl(ctx context.Context, control *CampaignControl) error {
+	payload, err := json.Marshal(control)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Publish(ctx, CampaignControlChannel, payload).Err(); err != nil {
+		p.log.Error("Failed to publish campaign control", "error", err, "campaign_id", control.CampaignID, "status", control.Status)
+		return err
+	}
+
+	p.log.Debug("Published campaign control", "campaign_id", control.CampaignID, "status", control.Status)
+	return nil
+}
+
+// PublishJobUpdate publishes the progress of a background job
+func (p *Publisher) PublishJobUpdate(ctx context.Context, update *JobUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	if err := p.client.Publish(ctx, JobUpdatesChannel, payload).Err(); err != nil {
+		p.log.Error("Failed to publish job update", "error", err, "job_id", update.JobID)
+		return err
+	}
+
+	p.log.Debug("Published job update", "job_id", update.JobID, "status", update.Status, "progress", update.Progress)
+	return nil
+}
+
+func (p *Publisher) publishCampaignStats(ctx context.Context, update *CampaignStatsUpdate) error {
 	payload, err := json.Marshal(update)
 	if err != nil {
 		return err
@@ -111,6 +311,126 @@ func (s *Subscriber) SubscribeCampaignStats(ctx context.Context, handler func(up
 	return nil
 }
 
+// SubscribeCampaignEvents subscribes to campaign lifecycle events
+// The handler is called for each received event
+func (s *Subscriber) SubscribeCampaignEvents(ctx context.Context, handler func(event *CampaignEvent)) error {
+	s.pubsub = s.client.Subscribe(ctx, CampaignEventsChannel)
+
+	// Wait for subscription confirmation
+	_, err := s.pubsub.Receive(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Subscribed to campaign events channel")
+
+	ch := s.pubsub.Channel()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				s.log.Info("Campaign events subscriber shutting down")
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					s.log.Info("Campaign events channel closed")
+					return
+				}
+
+				var event CampaignEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					s.log.Error("Failed to unmarshal campaign event", "error", err)
+					continue
+				}
+
+				handler(&event)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// SubscribeCampaignControl subscribes to campaign pause and cancel signals
+// The handler is called for each received signal
+func (s *Subscriber) SubscribeCampaignControl(ctx context.Context, handler func(control *CampaignControl)) error {
+	s.pubsub = s.client.Subscribe(ctx, CampaignControlChannel)
+
+	// Wait for subscription confirmation
+	_, err := s.pubsub.Receive(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Subscribed to campaign control channel")
+
+	ch := s.pubsub.Channel()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				s.log.Info("Campaign control subscriber shutting down")
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					s.log.Info("Campaign control channel closed")
+					return
+				}
+
+				var control CampaignControl
+				if err := json.Unmarshal([]byte(msg.Payload), &control); err != nil {
+					s.log.Error("Failed to unmarshal campaign control", "error", err)
+					continue
+				}
+
+				handler(&control)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// SubscribeJobUpdates subscribes to background job progress
+// The handler is called for each received update
+func (s *Subscriber) SubscribeJobUpdates(ctx context.Context, handler func(update *JobUpdate)) error {
+	s.pubsub = s.client.Subscribe(ctx, JobUpdatesChannel)
+
+	// Wait for subscription confirmation
+	_, err := s.pubsub.Receive(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.log.Info("Subscribed to job updates channel")
+
+	ch := s.pubsub.Channel()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				s.log.Info("Job updates subscriber shutting down")
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					s.log.Info("Job updates channel closed")
+					return
+				}
+
+				var update JobUpdate
+				if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+					s.log.Error("Failed to unmarshal job update", "error", err)
+					continue
+				}
+
+				handler(&update)
+			}
+		}
+	}()
+
+	return nil
+}
+
 // Close closes the subscriber
 func (s *Subscriber) Close() error {
 	if s.pubsub != nil {
@@ -15,10 +15,38 @@ const (
 	JobTypeCampaign JobType = "campaign"
 )
 
+// Priority decides which campaign jobs workers take first
+type Priority string
+
+const (
+	// PriorityHigh is for transactional and urgent campaigns
+	PriorityHigh Priority = "high"
+	// PriorityNormal is the default
+	PriorityNormal Priority = "normal"
+	// PriorityLow is for large marketing blasts that can wait
+	PriorityLow Priority = "low"
+)
+
+// Priorities lists every priority, highest first, in the order workers drain them
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// ValidPriority reports whether p is a known priority
+func ValidPriority(p Priority) bool {
+	for _, known := range Priorities {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
 // CampaignJob represents a campaign processing job
 type CampaignJob struct {
-	CampaignID uuid.UUID `json:"campaign_id"`
-	EnqueuedAt time.Time `json:"enqueued_at"`
+	CampaignID uuid.UUID  `json:"campaign_id"`
+	Priority   Priority   `json:"priority,omitempty"`   // Empty for jobs enqueued before priorities, which are normal
+	Helper     bool       `json:"helper,omitempty"`     // Joins a run of the campaign already sending; see EnqueueCampaignHelpers
+	DryRunID   *uuid.UUID `json:"dry_run_id,omitempty"` // Set for a dry run, which sends nothing; see EnqueueCampaignDryRun
+	EnqueuedAt time.Time  `json:"enqueued_at"`
 }
 
 // Job represents a generic job in the queue
@@ -31,8 +59,30 @@ type Job struct {
 
 // Queue defines the interface for job queue operations
 type Queue interface {
-	// EnqueueCampaign adds a campaign processing job to the queue
-	EnqueueCampaign(ctx context.Context, campaignID uuid.UUID) error
+	// EnqueueCampaign adds a campaign processing job to the queue. Workers take jobs
+	// of a higher priority before any of a lower one; unknown priorities are normal.
+	EnqueueCampaign(ctx context.Context, campaignID uuid.UUID, priority Priority) error
+
+	// EnqueueCampaignDryRun adds a job running the campaign's pipeline for the dry run,
+	// without sending anything
+	EnqueueCampaignDryRun(ctx context.Context, campaignID, dryRunID uuid.UUID, priority Priority) error
+
+	// PurgeCampaign removes the campaign's jobs from the queue, including any a worker
+	// read but hasn't acknowledged and any waiting to be retried, and returns how many
+	// it removed
+	PurgeCampaign(ctx context.Context, campaignID uuid.UUID) (int, error)
+
+	// ListDeadJobs returns up to limit jobs that failed too often to be retried, newest first
+	ListDeadJobs(ctx context.Context, limit int64) ([]DeadJob, error)
+
+	// GetDeadJob returns a dead job, or ErrDeadJobNotFound
+	GetDeadJob(ctx context.Context, id string) (*DeadJob, error)
+
+	// RequeueDeadJob moves a dead job back onto the queue with its attempts reset
+	RequeueDeadJob(ctx context.Context, id string) error
+
+	// DeleteDeadJob discards a dead job
+	DeleteDeadJob(ctx context.Context, id string) error
 
 	// Close closes the queue connection
 	Close() error
@@ -40,7 +90,7 @@ type Queue interface {
 
 // Consumer defines the interface for consuming jobs from the queue
 type Consumer interface {
-	// Consume starts consuming jobs from the queue
+	// Consume starts consuming jobs from the queue, higher priorities first
 	// The handler function is called for each job
 	// Returns when context is cancelled
 	Consume(ctx context.Context, handler func(ctx context.Context, job *CampaignJob) error) error
@@ -13,9 +13,15 @@ import (
 )
 
 const (
-	// StreamName is the Redis stream for campaign jobs
+	// StreamName is the Redis stream for normal priority campaign jobs
 	StreamName = "whatomate:campaigns"
 
+	// HighPriorityStreamName is the Redis stream for high priority campaign jobs
+	HighPriorityStreamName = "whatomate:campaigns:high"
+
+	// LowPriorityStreamName is the Redis stream for low priority campaign jobs
+	LowPriorityStreamName = "whatomate:campaigns:low"
+
 	// ConsumerGroup is the consumer group name for workers
 	ConsumerGroup = "campaign-workers"
 
@@ -26,6 +32,26 @@ const (
 	ClaimMinIdleTime = 5 * time.Minute
 )
 
+// streamFor returns the stream jobs of priority go to; unknown priorities are normal
+func streamFor(priority Priority) string {
+	switch priority {
+	case PriorityHigh:
+		return HighPriorityStreamName
+	case PriorityLow:
+		return LowPriorityStreamName
+	}
+	return StreamName
+}
+
+// priorityStreams returns the campaign streams, highest priority first
+func priorityStreams() []string {
+	streams := make([]string, len(Priorities))
+	for i, p := range Priorities {
+		streams[i] = streamFor(p)
+	}
+	return streams
+}
+
 // RedisQueue implements the Queue interface using Redis Streams
 type RedisQueue struct {
 	client *redis.Client
@@ -41,11 +67,34 @@ func NewRedisQueue(client *redis.Client, log logf.Logger) *RedisQueue {
 }
 
 // EnqueueCampaign adds a campaign processing job to the queue
-func (q *RedisQueue) EnqueueCampaign(ctx context.Context, campaignID uuid.UUID) error {
-	job := CampaignJob{
-		CampaignID: campaignID,
-		EnqueuedAt: time.Now(),
+func (q *RedisQueue) EnqueueCampaign(ctx context.Context, campaignID uuid.UUID, priority Priority) error {
+	return q.enqueue(ctx, CampaignJob{CampaignID: campaignID, Priority: priority})
+}
+
+// EnqueueCampaignDryRun adds a job running the campaign's pipeline for the dry run,
+// without sending anything
+func (q *RedisQueue) EnqueueCampaignDryRun(ctx context.Context, campaignID, dryRunID uuid.UUID, priority Priority) error {
+	return q.enqueue(ctx, CampaignJob{CampaignID: campaignID, Priority: priority, DryRunID: &dryRunID})
+}
+
+// EnqueueCampaignHelpers adds n helper jobs for a campaign a worker has started
+// sending, so up to n more workers send it alongside. Helpers claim their own
+// batches of recipients and don't add helpers of their own.
+func (q *RedisQueue) EnqueueCampaignHelpers(ctx context.Context, campaignID uuid.UUID, priority Priority, n int) error {
+	for range n {
+		if err := q.enqueue(ctx, CampaignJob{CampaignID: campaignID, Priority: priority, Helper: true}); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// enqueue adds job to the stream of its priority
+func (q *RedisQueue) enqueue(ctx context.Context, job CampaignJob) error {
+	if !ValidPriority(job.Priority) {
+		job.Priority = PriorityNormal
+	}
+	job.EnqueuedAt = time.Now()
 
 	payload, err := json.Marshal(job)
 	if err != nil {
@@ -54,7 +103,7 @@ func (q *RedisQueue) EnqueueCampaign(ctx context.Context, campaignID uuid.UUID)
 
 	// Add to stream using XADD
 	result, err := q.client.XAdd(ctx, &redis.XAddArgs{
-		Stream: StreamName,
+		Stream: streamFor(job.Priority),
 		Values: map[string]interface{}{
 			"type":    string(JobTypeCampaign),
 			"payload": string(payload),
@@ -65,10 +114,126 @@ func (q *RedisQueue) EnqueueCampaign(ctx context.Context, campaignID uuid.UUID)
 		return fmt.Errorf("failed to enqueue campaign job: %w", err)
 	}
 
-	q.log.Info("Campaign job enqueued", "campaign_id", campaignID, "message_id", result)
+	q.log.Info("Campaign job enqueued", "campaign_id", job.CampaignID, "priority", job.Priority, "helper", job.Helper, "message_id", result)
 	return nil
 }
 
+// purgeScanCount is how many stream entries PurgeCampaign reads at a time
+const purgeScanCount = 500
+
+// PurgeCampaign removes the campaign's jobs from every priority stream and from the
+// retry set. Only the jobs a worker hasn't read yet and those read but not
+// acknowledged are looked at: acknowledged jobs are deleted from their stream, so
+// the work doesn't grow with the queue's history.
+func (q *RedisQueue) PurgeCampaign(ctx context.Context, campaignID uuid.UUID) (int, error) {
+	purged := 0
+	for _, stream := range priorityStreams() {
+		ids, err := q.campaignJobIDs(ctx, stream, campaignID)
+		if err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		if err := q.client.XDel(ctx, stream, ids...).Err(); err != nil {
+			return purged, fmt.Errorf("failed to remove campaign jobs: %w", err)
+		}
+		// Read but unacknowledged jobs would otherwise stay pending, to be claimed again
+		if err := q.client.XAck(ctx, stream, ConsumerGroup, ids...).Err(); err != nil {
+			q.log.Warn("Failed to acknowledge purged campaign jobs", "error", err, "stream", stream)
+		}
+		purged += len(ids)
+	}
+
+	// Jobs waiting out a retry backoff
+	members, err := q.client.ZRange(ctx, RetryQueueKey, 0, -1).Result()
+	if err != nil {
+		return purged, fmt.Errorf("failed to read campaign job retries: %w", err)
+	}
+	for _, member := range members {
+		var retrying retryingJob
+		var job CampaignJob
+		if json.Unmarshal([]byte(member), &retrying) != nil || json.Unmarshal([]byte(retrying.Payload), &job) != nil || job.CampaignID != campaignID {
+			continue
+		}
+		if n, err := q.client.ZRem(ctx, RetryQueueKey, member).Result(); err != nil {
+			return purged, fmt.Errorf("failed to remove campaign job retry: %w", err)
+		} else if n > 0 {
+			purged++
+		}
+	}
+
+	if purged > 0 {
+		q.log.Info("Campaign jobs purged", "campaign_id", campaignID, "count", purged)
+	}
+	return purged, nil
+}
+
+// campaignJobIDs returns the IDs of the campaign's jobs in stream that are pending, in
+// the consumer group's pending entries list, or not yet delivered to the group
+func (q *RedisQueue) campaignJobIDs(ctx context.Context, stream string, campaignID uuid.UUID) ([]string, error) {
+	var ids []string
+	matches := func(msg redis.XMessage) bool {
+		payload, _ := msg.Values["payload"].(string)
+		var job CampaignJob
+		return json.Unmarshal([]byte(payload), &job) == nil && job.CampaignID == campaignID
+	}
+
+	for start := "-"; ; {
+		pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: stream,
+			Group:  ConsumerGroup,
+			Start:  start,
+			End:    "+",
+			Count:  purgeScanCount,
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pending campaign jobs: %w", err)
+		}
+		for _, p := range pending {
+			msgs, err := q.client.XRange(ctx, stream, p.ID, p.ID).Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read campaign stream: %w", err)
+			}
+			if len(msgs) > 0 && matches(msgs[0]) {
+				ids = append(ids, p.ID)
+			}
+		}
+		if len(pending) < purgeScanCount {
+			break
+		}
+		start = "(" + pending[len(pending)-1].ID
+	}
+
+	// Entries after the last one delivered to the group haven't been read yet
+	start := "-"
+	groups, err := q.client.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read campaign stream: %w", err)
+	}
+	for _, g := range groups {
+		if g.Name == ConsumerGroup {
+			start = "(" + g.LastDeliveredID
+		}
+	}
+	for {
+		msgs, err := q.client.XRangeN(ctx, stream, start, "+", purgeScanCount).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read campaign stream: %w", err)
+		}
+		for _, msg := range msgs {
+			if matches(msg) {
+				ids = append(ids, msg.ID)
+			}
+		}
+		if len(msgs) < purgeScanCount {
+			return ids, nil
+		}
+		start = "(" + msgs[len(msgs)-1].ID
+	}
+}
+
 // Close closes the queue connection
 func (q *RedisQueue) Close() error {
 	return nil // Redis client is managed externally
@@ -93,18 +258,33 @@ func NewRedisConsumer(client *redis.Client, log logf.Logger) (*RedisConsumer, er
 		consumerID: consumerID,
 	}
 
-	// Create consumer group if it doesn't exist
+	// Create the consumer group of each priority's stream if it doesn't exist
 	ctx := context.Background()
-	err := client.XGroupCreateMkStream(ctx, StreamName, ConsumerGroup, "0").Err()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	for _, stream := range priorityStreams() {
+		err := client.XGroupCreateMkStream(ctx, stream, ConsumerGroup, "0").Err()
+		if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+			return nil, fmt.Errorf("failed to create consumer group for %s: %w", stream, err)
+		}
 	}
 
 	log.Info("Redis consumer initialized", "consumer_id", consumerID)
 	return consumer, nil
 }
 
-// Consume starts consuming jobs from the queue
+// ID returns the name this consumer reads from the consumer group as, unique to the process
+func (c *RedisConsumer) ID() string {
+	return c.consumerID
+}
+
+// streamMessage is a message read from one of the campaign streams
+type streamMessage struct {
+	stream string
+	msg    redis.XMessage
+}
+
+// Consume starts consuming jobs from the queue. Before each job it checks the
+// streams from the highest priority down, so a queued high priority job is always
+// taken before any normal or low one.
 func (c *RedisConsumer) Consume(ctx context.Context, handler func(ctx context.Context, job *CampaignJob) error) error {
 	c.log.Info("Starting to consume campaign jobs", "consumer_id", c.consumerID)
 
@@ -121,15 +301,10 @@ func (c *RedisConsumer) Consume(ctx context.Context, handler func(ctx context.Co
 		default:
 		}
 
-		// Read new messages from the stream
-		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
-			Group:    ConsumerGroup,
-			Consumer: c.consumerID,
-			Streams:  []string{StreamName, ">"},
-			Count:    1,
-			Block:    BlockTimeout,
-		}).Result()
+		// Retries are checked at least every BlockTimeout, while waiting for jobs
+		c.promoteDueRetries(ctx)
 
+		messages, err := c.readMessages(ctx)
 		if err != nil {
 			if err == redis.Nil {
 				// No messages available, continue waiting
@@ -143,28 +318,117 @@ func (c *RedisConsumer) Consume(ctx context.Context, handler func(ctx context.Co
 			continue
 		}
 
-		for _, stream := range streams {
-			for _, msg := range stream.Messages {
-				if err := c.processMessage(ctx, msg, handler); err != nil {
-					c.log.Error("Failed to process message", "error", err, "message_id", msg.ID)
-					// Don't ACK failed messages - they'll be reclaimed later
-					continue
+		for i, m := range messages {
+			if ctx.Err() != nil {
+				c.handBack(ctx, messages[i:])
+				return ctx.Err()
+			}
+			if err := c.processMessage(ctx, m.msg, handler); err != nil {
+				if ctx.Err() != nil {
+					// Shutting down mid-job; the handler saved its progress
+					c.handBack(ctx, messages[i:])
+					return ctx.Err()
 				}
+				c.log.Error("Failed to process message", "error", err, "message_id", m.msg.ID, "stream", m.stream)
+				c.retryOrDeadLetter(ctx, m.stream, m.msg, err)
+				continue
+			}
 
-				// Acknowledge the message
-				if err := c.client.XAck(ctx, StreamName, ConsumerGroup, msg.ID).Err(); err != nil {
-					c.log.Error("Failed to ACK message", "error", err, "message_id", msg.ID)
-				}
+			// Acknowledge the message, even if the worker began shutting down meanwhile
+			if err := c.ack(context.WithoutCancel(ctx), m.stream, m.msg.ID); err != nil {
+				c.log.Error("Failed to ACK message", "error", err, "message_id", m.msg.ID, "stream", m.stream)
 			}
 		}
 	}
 }
 
-// claimPendingMessages claims stale pending messages from crashed workers
+// ack acknowledges a finished job and deletes it from stream, so streams only hold
+// jobs that are queued or being worked on
+func (c *RedisConsumer) ack(ctx context.Context, stream, id string) error {
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, stream, ConsumerGroup, id)
+		pipe.XDel(ctx, stream, id)
+		return nil
+	})
+	return err
+}
+
+// readMessages reads the next job from the highest priority stream that has one.
+// When all are empty it blocks on all of them for up to BlockTimeout, which can
+// read one job from each stream that gets one meanwhile; those are returned
+// highest priority first, as they are already claimed by this consumer.
+func (c *RedisConsumer) readMessages(ctx context.Context) ([]streamMessage, error) {
+	streams := priorityStreams()
+	for _, stream := range streams {
+		result, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    ConsumerGroup,
+			Consumer: c.consumerID,
+			Streams:  []string{stream, ">"},
+			Count:    1,
+			Block:    -1, // Don't block
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return collectMessages(streams, result), nil
+	}
+
+	args := make([]string, 0, 2*len(streams))
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+	result, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    ConsumerGroup,
+		Consumer: c.consumerID,
+		Streams:  args,
+		Count:    1,
+		Block:    BlockTimeout,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return collectMessages(streams, result), nil
+}
+
+// collectMessages flattens the messages read from streams, in the order of streams
+func collectMessages(streams []string, result []redis.XStream) []streamMessage {
+	var messages []streamMessage
+	for _, stream := range streams {
+		for _, s := range result {
+			if s.Stream != stream {
+				continue
+			}
+			for _, msg := range s.Messages {
+				messages = append(messages, streamMessage{stream: stream, msg: msg})
+			}
+		}
+	}
+	return messages
+}
+
+// claimPendingMessages claims stale pending messages from crashed workers, highest
+// priority first
 func (c *RedisConsumer) claimPendingMessages(ctx context.Context, handler func(ctx context.Context, job *CampaignJob) error) error {
+	for _, stream := range priorityStreams() {
+		if err := c.claimStreamPendingMessages(ctx, stream, handler); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.log.Warn("Failed to claim pending messages", "error", err, "stream", stream)
+		}
+	}
+	return nil
+}
+
+// claimStreamPendingMessages claims the stale pending messages of one stream
+func (c *RedisConsumer) claimStreamPendingMessages(ctx context.Context, stream string, handler func(ctx context.Context, job *CampaignJob) error) error {
 	// Get pending messages that have been idle for too long
 	pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
-		Stream: StreamName,
+		Stream: stream,
 		Group:  ConsumerGroup,
 		Start:  "-",
 		End:    "+",
@@ -180,13 +444,13 @@ func (c *RedisConsumer) claimPendingMessages(ctx context.Context, handler func(c
 		return nil
 	}
 
-	c.log.Info("Found stale pending messages to claim", "count", len(pending))
+	c.log.Info("Found stale pending messages to claim", "count", len(pending), "stream", stream)
 
 	// Claim and process each pending message
 	for _, p := range pending {
 		// Claim the message
 		messages, err := c.client.XClaim(ctx, &redis.XClaimArgs{
-			Stream:   StreamName,
+			Stream:   stream,
 			Group:    ConsumerGroup,
 			Consumer: c.consumerID,
 			MinIdle:  ClaimMinIdleTime,
@@ -200,12 +464,17 @@ func (c *RedisConsumer) claimPendingMessages(ctx context.Context, handler func(c
 
 		for _, msg := range messages {
 			if err := c.processMessage(ctx, msg, handler); err != nil {
+				if ctx.Err() != nil {
+					c.handBack(ctx, []streamMessage{{stream: stream, msg: msg}})
+					return ctx.Err()
+				}
 				c.log.Error("Failed to process claimed message", "error", err, "message_id", msg.ID)
+				c.retryOrDeadLetter(ctx, stream, msg, err)
 				continue
 			}
 
 			// Acknowledge the message
-			if err := c.client.XAck(ctx, StreamName, ConsumerGroup, msg.ID).Err(); err != nil {
+			if err := c.ack(context.WithoutCancel(ctx), stream, msg.ID); err != nil {
 				c.log.Error("Failed to ACK claimed message", "error", err, "message_id", msg.ID)
 			}
 		}
@@ -214,28 +483,47 @@ func (c *RedisConsumer) claimPendingMessages(ctx context.Context, handler func(c
 	return nil
 }
 
+// handBack puts jobs this consumer read but won't finish, as it is shutting down, back
+// at the end of their streams, for another worker to take straight away rather than
+// once they have been idle for ClaimMinIdleTime. Jobs that can't be handed back stay
+// pending and are claimed then instead.
+func (c *RedisConsumer) handBack(ctx context.Context, messages []streamMessage) {
+	ctx = context.WithoutCancel(ctx)
+	for _, m := range messages {
+		values := map[string]interface{}{
+			"type":    m.msg.Values["type"],
+			"payload": m.msg.Values["payload"],
+		}
+		if attempts, ok := m.msg.Values["attempts"]; ok {
+			values["attempts"] = attempts
+		}
+		c.log.Info("Handing back campaign job", "message_id", m.msg.ID, "stream", m.stream)
+		c.moveMessage(ctx, m.stream, m.msg, &redis.XAddArgs{Stream: m.stream, Values: values})
+	}
+}
+
 // processMessage processes a single message from the stream
 func (c *RedisConsumer) processMessage(ctx context.Context, msg redis.XMessage, handler func(ctx context.Context, job *CampaignJob) error) error {
 	jobType, ok := msg.Values["type"].(string)
 	if !ok {
-		return fmt.Errorf("invalid message: missing type")
+		return fmt.Errorf("%w: missing type", errMalformedJob)
 	}
 
 	if JobType(jobType) != JobTypeCampaign {
-		return fmt.Errorf("unknown job type: %s", jobType)
+		return fmt.Errorf("%w: unknown job type %s", errMalformedJob, jobType)
 	}
 
 	payload, ok := msg.Values["payload"].(string)
 	if !ok {
-		return fmt.Errorf("invalid message: missing payload")
+		return fmt.Errorf("%w: missing payload", errMalformedJob)
 	}
 
 	var job CampaignJob
 	if err := json.Unmarshal([]byte(payload), &job); err != nil {
-		return fmt.Errorf("failed to unmarshal job: %w", err)
+		return fmt.Errorf("%w: failed to unmarshal job: %v", errMalformedJob, err)
 	}
 
-	c.log.Info("Processing campaign job", "campaign_id", job.CampaignID, "message_id", msg.ID)
+	c.log.Info("Processing campaign job", "campaign_id", job.CampaignID, "priority", job.Priority, "message_id", msg.ID, "attempt", messageAttempts(msg)+1)
 
 	return handler(ctx, &job)
 }
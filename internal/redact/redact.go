@@ -0,0 +1,169 @@
+// Package redact masks personal data in message text, such as card numbers and
+// one-time codes, before it leaves the system in logs, outbound webhooks and exports.
+// Stored messages are never changed.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule names used in replacements for the built-in rules
+const (
+	RuleCreditCard = "credit_card"
+	RuleOTP        = "otp"
+)
+
+const (
+	// MaxCustomRules caps the custom patterns an organization can configure
+	MaxCustomRules = 20
+	// maxPatternLength keeps custom patterns readable and cheap to compile
+	maxPatternLength = 500
+)
+
+var (
+	// cardPattern matches 13 to 19 digits, optionally grouped by spaces or dashes.
+	// Matches are only redacted when they pass the Luhn check.
+	cardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+	// otpPattern matches a 4 to 8 digit code close to a word that marks it as a
+	// one-time code, on either side: "Your OTP is 123456", "123456 is your code"
+	otpPattern = regexp.MustCompile(`(?i)\b(?:otp|code|pin|passcode|verification|one[- ]time password)\b[^\d\n]{0,20}?\b(\d{4,8})\b|\b(\d{4,8})\b[^\d\n]{0,20}?\b(?:otp|code|pin|passcode|verification)\b`)
+
+	namePattern = regexp.MustCompile(`^[a-z0-9_]{1,40}$`)
+)
+
+// Rule is a custom pattern. Text it matches is replaced with [REDACTED:name].
+type Rule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// Rules are an organization's redaction settings
+type Rules struct {
+	CreditCard bool   `json:"credit_card"`
+	OTP        bool   `json:"otp"`
+	Custom     []Rule `json:"custom"`
+}
+
+// Enabled reports whether any rule is switched on
+func (r Rules) Enabled() bool {
+	return r.CreditCard || r.OTP || len(r.Custom) > 0
+}
+
+// Validate returns an error describing the first invalid custom rule
+func (r Rules) Validate() error {
+	if len(r.Custom) > MaxCustomRules {
+		return fmt.Errorf("at most %d custom redaction rules are allowed", MaxCustomRules)
+	}
+	for _, rule := range r.Custom {
+		if !namePattern.MatchString(rule.Name) {
+			return fmt.Errorf("redaction rule name %q must be lowercase letters, digits or underscores", rule.Name)
+		}
+		if rule.Pattern == "" || len(rule.Pattern) > maxPatternLength {
+			return fmt.Errorf("redaction rule %s needs a pattern of at most %d characters", rule.Name, maxPatternLength)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("redaction rule %s has an invalid pattern: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+type customRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Redactor applies a compiled set of rules. A nil Redactor leaves text unchanged.
+type Redactor struct {
+	creditCard bool
+	otp        bool
+	custom     []customRule
+}
+
+// New compiles rules into a Redactor. It returns nil when no rule is enabled.
+func New(rules Rules) (*Redactor, error) {
+	if !rules.Enabled() {
+		return nil, nil
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Redactor{creditCard: rules.CreditCard, otp: rules.OTP}
+	for _, rule := range rules.Custom {
+		r.custom = append(r.custom, customRule{name: rule.Name, re: regexp.MustCompile(rule.Pattern)})
+	}
+	return r, nil
+}
+
+// String returns text with everything the rules match replaced
+func (r *Redactor) String(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	if r.creditCard {
+		text = cardPattern.ReplaceAllStringFunc(text, func(m string) string {
+			if luhn(m) {
+				return placeholder(RuleCreditCard)
+			}
+			return m
+		})
+	}
+	if r.otp {
+		text = replaceGroups(otpPattern, text, placeholder(RuleOTP))
+	}
+	for _, rule := range r.custom {
+		text = rule.re.ReplaceAllLiteralString(text, placeholder(rule.name))
+	}
+	return text
+}
+
+func placeholder(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// replaceGroups replaces only the capturing groups of each match, keeping the words
+// around the code so the text still reads naturally
+func replaceGroups(re *regexp.Regexp, text, replacement string) string {
+	matches := re.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		for g := 2; g+1 < len(m); g += 2 {
+			if m[g] < 0 {
+				continue
+			}
+			b.WriteString(text[last:m[g]])
+			b.WriteString(replacement)
+			last = m[g+1]
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum used by card numbers
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
@@ -0,0 +1,180 @@
+package reports
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// Report types. Custom reports are built from dimensions and metrics over the message
+// rollups; the others are fixed analytics reports that read their source tables.
+const (
+	TypeCustom           = "custom"
+	TypeCampaignSummary  = "campaign_summary"
+	TypeAgentPerformance = "agent_performance"
+	TypeRevenue          = "revenue"
+)
+
+// builtinFilters lists the filters each built-in report honours
+var builtinFilters = map[string][]string{
+	TypeCampaignSummary:  {"templates", "accounts"},
+	TypeAgentPerformance: {"agents", "accounts"},
+	TypeRevenue:          {"agents", "accounts"},
+}
+
+var campaignSummaryColumns = []string{
+	"campaign", "account", "template", "status", "started_at",
+	"recipients", "sent", "delivered", "read", "failed", "delivery_rate", "read_rate",
+}
+
+var revenueColumns = []string{
+	"campaign", "currency", "orders", "customers", "revenue", "average_order",
+}
+
+var agentPerformanceColumns = []string{
+	"agent", "agent_name", "transfers_handled", "active_transfers", "sla_breaches",
+	"avg_first_response_mins", "avg_resolution_mins", "messages_sent",
+}
+
+// campaignSummary reports each campaign started (or created, if never started) in
+// [from, to] with its delivery counters
+func campaignSummary(db *gorm.DB, report *models.Report, filters Filters, cal Calendar, from, to time.Time) (*Result, error) {
+	where := []string{
+		"c.organization_id = ?",
+		"c.deleted_at IS NULL",
+		"COALESCE(c.started_at, c.created_at) >= ?",
+		"COALESCE(c.started_at, c.created_at) < ?",
+	}
+	args := []interface{}{cal.Location.String(), report.OrganizationID, cal.Start(from), cal.Start(to.AddDate(0, 0, 1))}
+	if len(filters.Accounts) > 0 {
+		where = append(where, "c.whats_app_account IN ?")
+		args = append(args, filters.Accounts)
+	}
+	if len(filters.Templates) > 0 {
+		where = append(where, "t.name IN ?")
+		args = append(args, filters.Templates)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT c.name AS campaign, c.whats_app_account AS account, COALESCE(t.name, '') AS template, c.status,
+			COALESCE(to_char(c.started_at AT TIME ZONE ?, 'YYYY-MM-DD HH24:MI'), '') AS started_at,
+			c.total_recipients AS recipients, c.sent_count AS sent, c.delivered_count AS delivered,
+			c.read_count AS read, c.failed_count AS failed,
+			COALESCE(ROUND(100.0 * c.delivered_count / NULLIF(c.sent_count, 0), 1), 0)::float8 AS delivery_rate,
+			COALESCE(ROUND(100.0 * c.read_count / NULLIF(c.sent_count, 0), 1), 0)::float8 AS read_rate
+		FROM bulk_message_campaigns c
+		LEFT JOIN templates t ON t.id = c.template_id
+		WHERE %s
+		ORDER BY COALESCE(c.started_at, c.created_at), c.name
+		LIMIT %d`, strings.Join(where, " AND "), MaxRows)
+
+	var rows []map[string]interface{}
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	return &Result{Columns: campaignSummaryColumns, Rows: rows}, nil
+}
+
+// agentPerformance reports transfer handling and messages sent per agent in [from, to].
+// Users of any role appear once they have handled a transfer or sent a message.
+func agentPerformance(db *gorm.DB, report *models.Report, filters Filters, cal Calendar, from, to time.Time) (*Result, error) {
+	accountFilter := ""
+	if len(filters.Accounts) > 0 {
+		accountFilter = "AND whats_app_account IN @accounts"
+	}
+	agentFilter := ""
+	if len(filters.Agents) > 0 {
+		agentFilter = "AND u.id::text IN @agents"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT u.id::text AS agent, u.full_name AS agent_name,
+			COALESCE(t.handled, 0) AS transfers_handled,
+			COALESCE(t.active, 0) AS active_transfers,
+			COALESCE(t.breached, 0) AS sla_breaches,
+			COALESCE(ROUND(t.avg_first_response::numeric, 1), 0)::float8 AS avg_first_response_mins,
+			COALESCE(ROUND(t.avg_resolution::numeric, 1), 0)::float8 AS avg_resolution_mins,
+			COALESCE(m.sent, 0) AS messages_sent
+		FROM users u
+		LEFT JOIN (
+			SELECT agent_id,
+				COUNT(*) FILTER (WHERE status = 'resumed') AS handled,
+				COUNT(*) FILTER (WHERE status = 'active') AS active,
+				COUNT(*) FILTER (WHERE sla_breached) AS breached,
+				AVG(EXTRACT(EPOCH FROM (first_response_at - transferred_at)) / 60) AS avg_first_response,
+				AVG(EXTRACT(EPOCH FROM (resumed_at - transferred_at)) / 60) AS avg_resolution
+			FROM agent_transfers
+			WHERE organization_id = @org AND deleted_at IS NULL AND agent_id IS NOT NULL
+				AND transferred_at >= @from AND transferred_at < @end %[1]s
+			GROUP BY agent_id
+		) t ON t.agent_id = u.id
+		LEFT JOIN (
+			SELECT sent_by_user_id, COUNT(*) AS sent
+			FROM messages
+			WHERE organization_id = @org AND deleted_at IS NULL AND direction = 'outgoing'
+				AND sent_by_user_id IS NOT NULL AND created_at >= @from AND created_at < @end %[1]s
+			GROUP BY sent_by_user_id
+		) m ON m.sent_by_user_id = u.id
+		WHERE u.organization_id = @org AND u.deleted_at IS NULL
+			AND (u.role = 'agent' OR t.agent_id IS NOT NULL OR m.sent_by_user_id IS NOT NULL) %[2]s
+		ORDER BY u.full_name
+		LIMIT %[3]d`, accountFilter, agentFilter, MaxRows)
+
+	var rows []map[string]interface{}
+	err := db.Raw(query, map[string]interface{}{
+		"org":      report.OrganizationID,
+		"from":     cal.Start(from),
+		"end":      cal.Start(to.AddDate(0, 0, 1)),
+		"accounts": filters.Accounts,
+		"agents":   filters.Agents,
+	}).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent performance: %w", err)
+	}
+	return &Result{Columns: agentPerformanceColumns, Rows: rows}, nil
+}
+
+// revenue reports orders placed in [from, to] per attributed campaign and currency.
+// Orders with no campaign are grouped under an empty campaign; cancelled and refunded
+// orders are left out. Amounts are in the currency's main unit.
+func revenue(db *gorm.DB, report *models.Report, filters Filters, cal Calendar, from, to time.Time) (*Result, error) {
+	where := []string{
+		"o.organization_id = ?",
+		"o.deleted_at IS NULL",
+		"o.contact_id IS NOT NULL",
+		"o.status NOT IN ('cancelled', 'refunded')",
+		"o.ordered_at >= ?",
+		"o.ordered_at < ?",
+	}
+	args := []interface{}{report.OrganizationID, cal.Start(from), cal.Start(to.AddDate(0, 0, 1))}
+	if len(filters.Accounts) > 0 {
+		where = append(where, "o.whats_app_account IN ?")
+		args = append(args, filters.Accounts)
+	}
+	if len(filters.Agents) > 0 {
+		where = append(where, "o.agent_id::text IN ?")
+		args = append(args, filters.Agents)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT COALESCE(c.name, '') AS campaign, o.currency,
+			COUNT(*) AS orders,
+			COUNT(DISTINCT o.contact_id) AS customers,
+			(SUM(o.total_amount) / 100.0)::float8 AS revenue,
+			ROUND(AVG(o.total_amount) / 100.0, 2)::float8 AS average_order
+		FROM orders o
+		LEFT JOIN bulk_message_campaigns c ON c.id = o.campaign_id
+		WHERE %s
+		GROUP BY c.name, o.currency
+		ORDER BY SUM(o.total_amount) DESC
+		LIMIT %d`, strings.Join(where, " AND "), MaxRows)
+
+	var rows []map[string]interface{}
+	if err := db.Raw(query, args...).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	return &Result{Columns: revenueColumns, Rows: rows}, nil
+}
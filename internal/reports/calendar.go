@@ -0,0 +1,90 @@
+package reports
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// Organization settings, besides its timezone, that its analytics are bucketed by
+const (
+	WeekStartSetting       = "week_start"        // monday, sunday or saturday
+	FiscalYearStartSetting = "fiscal_year_start" // Month the fiscal year starts in, 1 to 12
+)
+
+// WeekStarts lists the days a week can start on
+var WeekStarts = []string{"monday", "sunday", "saturday"}
+
+var weekStartDays = map[string]time.Weekday{
+	"monday":   time.Monday,
+	"sunday":   time.Sunday,
+	"saturday": time.Saturday,
+}
+
+// Calendar is how an organization's analytics are bucketed: into days in its timezone,
+// weeks from its week start and quarters of its fiscal year
+type Calendar struct {
+	Location        *time.Location
+	WeekStart       time.Weekday
+	FiscalYearStart time.Month
+}
+
+// UTCCalendar is the calendar of an organization that hasn't set one
+var UTCCalendar = Calendar{Location: time.UTC, WeekStart: time.Monday, FiscalYearStart: time.January}
+
+// OrgCalendar reads an organization's calendar from its settings. Settings that are
+// missing or invalid keep UTCCalendar's.
+func OrgCalendar(settings models.JSONB) Calendar {
+	cal := UTCCalendar
+	if timezone, _ := settings["timezone"].(string); timezone != "" {
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			cal.Location = loc
+		}
+	}
+	weekStart, _ := settings[WeekStartSetting].(string)
+	if day, ok := weekStartDays[weekStart]; ok {
+		cal.WeekStart = day
+	}
+	if month, ok := settings[FiscalYearStartSetting].(float64); ok && month >= 1 && month <= 12 {
+		cal.FiscalYearStart = time.Month(month)
+	}
+	return cal
+}
+
+// Date returns the calendar date at t in the calendar's timezone, as midnight UTC like
+// rollup dates and report periods
+func (c Calendar) Date(t time.Time) time.Time {
+	t = t.In(c.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Start returns when date starts in the calendar's timezone
+func (c Calendar) Start(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// WeekOf returns the first date of the week date is in
+func (c Calendar) WeekOf(date time.Time) time.Time {
+	return date.AddDate(0, 0, -((int(date.Weekday()) - int(c.WeekStart) + 7) % 7))
+}
+
+// dimensionColumn returns the rollup column expression for a dimension. Weeks are
+// named by their first date and quarters by their fiscal year, which is named after
+// the calendar year it ends in, e.g. "FY2025 Q1" for April 2024 with an April start.
+func (c Calendar) dimensionColumn(dimension string) string {
+	switch dimension {
+	case "week":
+		return fmt.Sprintf("to_char(date - (EXTRACT(DOW FROM date)::int - %d + 7) %% 7, 'YYYY-MM-DD')", int(c.WeekStart))
+	case "quarter":
+		// Shifting dates back to the fiscal year's start puts it in January
+		shift := int(c.FiscalYearStart) - 1
+		yearOffset := 0
+		if shift > 0 {
+			yearOffset = 1
+		}
+		shifted := fmt.Sprintf("(date - INTERVAL '%d months')", shift)
+		return fmt.Sprintf("'FY' || (EXTRACT(YEAR FROM %[1]s)::int + %[2]d) || ' Q' || EXTRACT(QUARTER FROM %[1]s)::int", shifted, yearOffset)
+	}
+	return dimensionColumns[dimension]
+}
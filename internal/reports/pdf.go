@@ -0,0 +1,139 @@
+package reports
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PDF page layout: landscape A4 in points, monospaced so columns line up without
+// font metrics
+const (
+	pdfPageWidth   = 842
+	pdfPageHeight  = 595
+	pdfMargin      = 36
+	pdfMaxFontSize = 9.0
+	pdfMinFontSize = 5.0
+	pdfCharWidth   = 0.6 // Courier glyph width as a fraction of the font size
+	pdfMaxCellLen  = 40
+)
+
+// PDF renders report rows as a plain table, headed by the given title lines
+func PDF(title []string, columns []string, rows []interface{}) ([]byte, error) {
+	// Size each column to its widest value
+	widths := make([]int, len(columns))
+	cells := make([][]string, len(rows))
+	for i, col := range columns {
+		widths[i] = len(col)
+	}
+	for r, raw := range rows {
+		row, _ := raw.(map[string]interface{})
+		cells[r] = make([]string, len(columns))
+		for i, col := range columns {
+			v := pdfText(formatValue(row[col]))
+			if len(v) > pdfMaxCellLen {
+				v = v[:pdfMaxCellLen-2] + ".."
+			}
+			cells[r][i] = v
+			widths[i] = max(widths[i], len(v))
+		}
+	}
+
+	lineLen := 0
+	for _, w := range widths {
+		lineLen += w + 2
+	}
+	fontSize := pdfMaxFontSize
+	if lineLen > 0 {
+		fontSize = min(pdfMaxFontSize, max(pdfMinFontSize, float64(pdfPageWidth-2*pdfMargin)/(float64(lineLen)*pdfCharWidth)))
+	}
+	leading := fontSize * 1.3
+
+	formatRow := func(values []string) string {
+		var b strings.Builder
+		for i, v := range values {
+			b.WriteString(v)
+			b.WriteString(strings.Repeat(" ", widths[i]-len(v)+2))
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+	header := formatRow(columns)
+	rule := strings.Repeat("-", len(header))
+
+	// Lay the lines out on pages, repeating the column header on each
+	linesPerPage := int(float64(pdfPageHeight-2*pdfMargin) / leading)
+	var pages [][]string
+	page := make([]string, 0, linesPerPage)
+	for _, t := range title {
+		page = append(page, pdfText(t))
+	}
+	page = append(page, "", header, rule)
+	for _, values := range cells {
+		if len(page) >= linesPerPage {
+			pages = append(pages, page)
+			page = []string{header, rule}
+		}
+		page = append(page, formatRow(values))
+	}
+	pages = append(pages, page)
+
+	// Objects: 1 catalog, 2 page tree, 3 font, then a page and its content per page
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %.2f Tf\n%.2f TL\n%d %.2f Td\n", fontSize, leading, pdfMargin, pdfPageHeight-pdfMargin-fontSize)
+		for _, line := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes(), nil
+}
+
+// pdfText replaces characters the built-in Courier font can't show
+func pdfText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case r < 32 || r > 126:
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// pdfEscape escapes a string for use in a PDF string literal
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s)
+}
@@ -0,0 +1,407 @@
+// Package reports runs custom report definitions against the daily message rollups,
+// and the built-in analytics reports. All dates are calendar days in the organization's
+// timezone; see Calendar.
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/cron"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxRows caps the rows stored for a single run
+	MaxRows = 10000
+
+	// MaxPeriodDays caps the date range of a single run
+	MaxPeriodDays = 366
+
+	// Scheduled reports fire at this hour in the organization's timezone, after the
+	// previous day's rollup is complete
+	scheduleHour = 1
+)
+
+// Dimensions, Metrics, Schedules, Types and Formats list the supported values in display
+// order. A schedule may also be a five-field cron expression.
+var (
+	Dimensions = []string{"day", "week", "month", "quarter", "template", "agent", "tag", "account"}
+	Metrics    = []string{"sent", "delivered", "read", "failed", "replies", "conversions"}
+	Schedules  = []string{"daily", "weekly", "monthly"}
+	Types      = []string{TypeCustom, TypeCampaignSummary, TypeAgentPerformance, TypeRevenue}
+	Formats    = []string{"csv", "pdf"}
+)
+
+// dimensionColumns maps a dimension to its rollup column expression. Weeks and
+// quarters depend on the organization's calendar; see Calendar.dimensionColumn.
+var dimensionColumns = map[string]string{
+	"day":      "to_char(date, 'YYYY-MM-DD')",
+	"month":    "to_char(date, 'YYYY-MM')",
+	"template": "template_name",
+	"agent":    "agent_id::text",
+	"tag":      "tag",
+	"account":  "whats_app_account",
+}
+
+// metricColumns maps a metric to its rollup counter column
+var metricColumns = map[string]string{
+	"sent":        "sent_count",
+	"delivered":   "delivered_count",
+	"read":        "read_count",
+	"failed":      "failed_count",
+	"replies":     "reply_count",
+	"conversions": "conversion_count",
+}
+
+// Filters restricts which rollup rows a report includes; empty lists match everything
+type Filters struct {
+	Templates []string
+	Agents    []string
+	Tags      []string
+	Accounts  []string
+}
+
+// Result is the tabular output of a report run
+type Result struct {
+	Columns []string
+	Rows    []map[string]interface{}
+}
+
+// ParseFilters reads and validates a report's filters
+func ParseFilters(raw models.JSONB) (Filters, error) {
+	var f Filters
+	for key, value := range raw {
+		values, err := stringList(value)
+		if err != nil {
+			return f, fmt.Errorf("filter %q: %w", key, err)
+		}
+		switch key {
+		case "templates":
+			f.Templates = values
+		case "agents":
+			for _, v := range values {
+				if _, err := uuid.Parse(v); err != nil {
+					return f, fmt.Errorf("filter \"agents\": invalid user ID %q", v)
+				}
+			}
+			f.Agents = values
+		case "tags":
+			f.Tags = values
+		case "accounts":
+			f.Accounts = values
+		default:
+			return f, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+	return f, nil
+}
+
+// Validate checks a report definition
+func Validate(report *models.Report) error {
+	if !slices.Contains(Types, report.Type) {
+		return fmt.Errorf("type must be one of %s", strings.Join(Types, ", "))
+	}
+	if !slices.Contains(Formats, report.Format) {
+		return fmt.Errorf("format must be one of %s", strings.Join(Formats, ", "))
+	}
+
+	if report.Type == TypeCustom {
+		if len(report.Metrics) == 0 {
+			return fmt.Errorf("at least one metric is required")
+		}
+		for _, d := range report.Dimensions {
+			if !slices.Contains(Dimensions, d) {
+				return fmt.Errorf("unknown dimension %q", d)
+			}
+		}
+		for _, m := range report.Metrics {
+			if _, ok := metricColumns[m]; !ok {
+				return fmt.Errorf("unknown metric %q", m)
+			}
+		}
+	}
+
+	if report.Schedule != "" && !slices.Contains(Schedules, report.Schedule) {
+		if _, err := cron.Parse(report.Schedule); err != nil {
+			return fmt.Errorf("schedule must be one of %s or a cron expression: %w", strings.Join(Schedules, ", "), err)
+		}
+	}
+	for _, addr := range report.Recipients {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("invalid recipient email %q", addr)
+		}
+	}
+
+	if _, err := ParseFilters(report.Filters); err != nil {
+		return err
+	}
+	if allowed, ok := builtinFilters[report.Type]; ok {
+		for key := range report.Filters {
+			if !slices.Contains(allowed, key) {
+				return fmt.Errorf("filter %q is not supported by %s reports", key, report.Type)
+			}
+		}
+	}
+	return nil
+}
+
+// Execute runs a report over the inclusive date range [from, to] of the organization's
+// calendar
+func Execute(db *gorm.DB, report *models.Report, cal Calendar, from, to time.Time) (*Result, error) {
+	filters, err := ParseFilters(report.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	switch report.Type {
+	case TypeCampaignSummary:
+		return campaignSummary(db, report, filters, cal, from, to)
+	case TypeAgentPerformance:
+		return agentPerformance(db, report, filters, cal, from, to)
+	case TypeRevenue:
+		return revenue(db, report, filters, cal, from, to)
+	}
+
+	// The tag rollup counts a message once per tag, so it is only used when tags are asked for
+	table := models.MessageDailyRollup{}.TableName()
+	if slices.Contains(report.Dimensions, "tag") || len(filters.Tags) > 0 {
+		table = models.MessageTagDailyRollup{}.TableName()
+	}
+
+	var selects, groups, columns []string
+	for _, d := range report.Dimensions {
+		column := cal.dimensionColumn(d)
+		selects = append(selects, fmt.Sprintf(`%s AS "%s"`, column, d))
+		groups = append(groups, column)
+		columns = append(columns, d)
+		if d == "agent" {
+			columns = append(columns, "agent_name")
+		}
+	}
+	for _, m := range report.Metrics {
+		selects = append(selects, fmt.Sprintf(`COALESCE(SUM(%s), 0)::bigint AS "%s"`, metricColumns[m], m))
+		columns = append(columns, m)
+	}
+
+	query := db.Table(table).
+		Select(strings.Join(selects, ", ")).
+		Where("organization_id = ? AND date >= ? AND date <= ? AND deleted_at IS NULL",
+			report.OrganizationID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if len(filters.Templates) > 0 {
+		query = query.Where("template_name IN ?", filters.Templates)
+	}
+	if len(filters.Agents) > 0 {
+		query = query.Where("agent_id IN ?", filters.Agents)
+	}
+	if len(filters.Tags) > 0 {
+		query = query.Where("tag IN ?", filters.Tags)
+	}
+	if len(filters.Accounts) > 0 {
+		query = query.Where("whats_app_account IN ?", filters.Accounts)
+	}
+	if len(groups) > 0 {
+		query = query.Group(strings.Join(groups, ", ")).Order(strings.Join(groups, ", "))
+	}
+
+	var rows []map[string]interface{}
+	if err := query.Limit(MaxRows).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to query rollups: %w", err)
+	}
+
+	if slices.Contains(report.Dimensions, "agent") {
+		if err := fillAgentNames(db, report.OrganizationID, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Result{Columns: columns, Rows: rows}, nil
+}
+
+// fillAgentNames adds an agent_name column for rows grouped by agent
+func fillAgentNames(db *gorm.DB, orgID uuid.UUID, rows []map[string]interface{}) error {
+	var ids []string
+	for _, row := range rows {
+		if id, ok := row["agent"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	names := make(map[string]string)
+	if len(ids) > 0 {
+		var users []models.User
+		if err := db.Select("id", "full_name").Where("organization_id = ? AND id IN ?", orgID, ids).Find(&users).Error; err != nil {
+			return fmt.Errorf("failed to load agents: %w", err)
+		}
+		for _, u := range users {
+			names[u.ID.String()] = u.FullName
+		}
+	}
+
+	for _, row := range rows {
+		id, _ := row["agent"].(string)
+		row["agent_name"] = names[id]
+	}
+	return nil
+}
+
+// SchedulePeriod returns the inclusive range of the organization's calendar covered by
+// a scheduled run firing at t: the previous day, the previous week, or the previous
+// month. Cron schedules cover the whole days since the previous run (lastRun), or
+// yesterday for the first run.
+func SchedulePeriod(schedule string, cal Calendar, t time.Time, lastRun *time.Time) (time.Time, time.Time) {
+	today := cal.Date(t)
+	yesterday := today.AddDate(0, 0, -1)
+	switch schedule {
+	case "daily":
+		return yesterday, yesterday
+	case "weekly":
+		week := cal.WeekOf(today)
+		return week.AddDate(0, 0, -7), week.AddDate(0, 0, -1)
+	case "monthly":
+		firstOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return firstOfMonth.AddDate(0, -1, 0), firstOfMonth.AddDate(0, 0, -1)
+	}
+
+	from := yesterday
+	if lastRun != nil && cal.Date(*lastRun).Before(yesterday) {
+		from = cal.Date(*lastRun)
+		if earliest := today.AddDate(0, 0, -MaxPeriodDays); from.Before(earliest) {
+			from = earliest
+		}
+	}
+	return from, yesterday
+}
+
+// NextRun returns the first time after t that a schedule fires, or nil for manual reports.
+// Reports fire in the organization's timezone: weekly ones on the first day of its week
+// and monthly ones on the 1st; cron schedules are read in it too.
+func NextRun(schedule string, cal Calendar, t time.Time) *time.Time {
+	t = t.In(cal.Location)
+	today := cal.Date(t)
+
+	var next time.Time
+	switch schedule {
+	case "daily":
+		next = time.Date(today.Year(), today.Month(), today.Day(), scheduleHour, 0, 0, 0, cal.Location)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 1)
+		}
+	case "weekly":
+		week := cal.WeekOf(today)
+		next = time.Date(week.Year(), week.Month(), week.Day(), scheduleHour, 0, 0, 0, cal.Location)
+		if !next.After(t) {
+			next = next.AddDate(0, 0, 7)
+		}
+	case "monthly":
+		next = time.Date(today.Year(), today.Month(), 1, scheduleHour, 0, 0, 0, cal.Location)
+		if !next.After(t) {
+			next = next.AddDate(0, 1, 0)
+		}
+	case "":
+		return nil
+	default:
+		c, err := cron.Parse(schedule)
+		if err != nil {
+			return nil
+		}
+		if next = c.Next(t); next.IsZero() {
+			return nil
+		}
+	}
+	return &next
+}
+
+// CSV renders report rows with a header line
+func CSV(columns []string, rows []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+
+	record := make([]string, len(columns))
+	for _, r := range rows {
+		row, _ := r.(map[string]interface{})
+		for i, col := range columns {
+			record[i] = formatValue(row[col])
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Render renders report rows in the given format (csv or pdf), returning the data and
+// its content type. The title lines head PDF output.
+func Render(format string, title []string, columns []string, rows []interface{}) ([]byte, string, error) {
+	if format == "pdf" {
+		data, err := PDF(title, columns, rows)
+		return data, "application/pdf", err
+	}
+	data, err := CSV(columns, rows)
+	return data, "text/csv", err
+}
+
+// Filename returns a file name with the given extension (csv, pdf) for a report run
+// ending on the given day
+func Filename(reportName string, periodEnd time.Time, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, reportName)
+	return name + "-" + periodEnd.Format("2006-01-02") + "." + ext
+}
+
+// ToJSONBArray converts result rows for storage on a ReportRun
+func (r *Result) ToJSONBArray() models.JSONBArray {
+	rows := make(models.JSONBArray, len(r.Rows))
+	for i, row := range r.Rows {
+		rows[i] = row
+	}
+	return rows
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		// Rows reloaded from JSONB decode numbers as float64
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func stringList(value interface{}) ([]string, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be a list")
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("must contain only non-empty strings")
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
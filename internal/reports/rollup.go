@@ -0,0 +1,72 @@
+package reports
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm"
+)
+
+// rollupColumns is the shared SELECT list for both rollup tables. Outgoing messages are
+// attributed to their own template and sender; incoming messages to the message they
+// replied to, when the contact replied in context.
+const rollupColumns = `
+	m.organization_id,
+	m.whats_app_account,
+	COALESCE(CASE WHEN m.direction = 'outgoing' THEN m.template_name ELSE p.template_name END, '') AS template_name,
+	CASE WHEN m.direction = 'outgoing' THEN m.sent_by_user_id ELSE p.sent_by_user_id END AS agent_id,
+	COUNT(*) FILTER (WHERE m.direction = 'outgoing' AND m.status IN ('sent', 'delivered', 'read')) AS sent_count,
+	COUNT(*) FILTER (WHERE m.direction = 'outgoing' AND m.status IN ('delivered', 'read')) AS delivered_count,
+	COUNT(*) FILTER (WHERE m.direction = 'outgoing' AND m.status = 'read') AS read_count,
+	COUNT(*) FILTER (WHERE m.direction = 'outgoing' AND m.status = 'failed') AS failed_count,
+	COUNT(*) FILTER (WHERE m.direction = 'incoming') AS reply_count,
+	COUNT(*) FILTER (WHERE m.direction = 'incoming' AND m.message_type IN ('button', 'interactive')) AS conversion_count`
+
+// Rollup rebuilds both rollup tables for one day of the organizations, which share the
+// timezone loc the day is in. It is idempotent, so the worker re-runs it for recent
+// days to pick up late delivery and read receipts.
+func Rollup(db *gorm.DB, day time.Time, loc *time.Location, orgIDs []uuid.UUID) error {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+	date := start.Format("2006-01-02")
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("date = ? AND organization_id IN ?", date, orgIDs).Delete(&models.MessageDailyRollup{}).Error; err != nil {
+			return fmt.Errorf("failed to clear rollups: %w", err)
+		}
+		if err := tx.Unscoped().Where("date = ? AND organization_id IN ?", date, orgIDs).Delete(&models.MessageTagDailyRollup{}).Error; err != nil {
+			return fmt.Errorf("failed to clear tag rollups: %w", err)
+		}
+
+		err := tx.Exec(`
+			INSERT INTO message_daily_rollups (organization_id, whats_app_account, template_name, agent_id,
+				sent_count, delivered_count, read_count, failed_count, reply_count, conversion_count, date, created_at, updated_at)
+			SELECT `+rollupColumns+`, ?::date, NOW(), NOW()
+			FROM messages m
+			LEFT JOIN messages p ON m.direction = 'incoming' AND p.id = m.reply_to_message_id
+			WHERE m.organization_id IN ? AND m.created_at >= ? AND m.created_at < ? AND m.deleted_at IS NULL
+			GROUP BY 1, 2, 3, 4`, date, orgIDs, start, end).Error
+		if err != nil {
+			return fmt.Errorf("failed to build rollups: %w", err)
+		}
+
+		// Tags are taken from the contact as it is now, not as it was when the message was sent
+		err = tx.Exec(`
+			INSERT INTO message_tag_daily_rollups (organization_id, whats_app_account, template_name, agent_id,
+				sent_count, delivered_count, read_count, failed_count, reply_count, conversion_count, tag, date, created_at, updated_at)
+			SELECT `+rollupColumns+`, LEFT(t.tag, 255), ?::date, NOW(), NOW()
+			FROM messages m
+			JOIN contacts c ON c.id = m.contact_id
+			CROSS JOIN LATERAL jsonb_array_elements_text(CASE WHEN jsonb_typeof(c.tags) = 'array' THEN c.tags ELSE '[]'::jsonb END) AS t(tag)
+			LEFT JOIN messages p ON m.direction = 'incoming' AND p.id = m.reply_to_message_id
+			WHERE m.organization_id IN ? AND m.created_at >= ? AND m.created_at < ? AND m.deleted_at IS NULL
+			GROUP BY 1, 2, 3, 4, 11`, date, orgIDs, start, end).Error
+		if err != nil {
+			return fmt.Errorf("failed to build tag rollups: %w", err)
+		}
+
+		return nil
+	})
+}
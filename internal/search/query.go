@@ -0,0 +1,169 @@
+// Package search parses the query syntax of conversation search. A query is free
+// text plus optional qualifiers, for example:
+//
+//	refund "order 1042" agent:me label:vip after:2024-01-01 has:media
+//
+// Every word and quoted phrase must appear in a message's text or transcript, and
+// every qualifier narrows the matches down further. Words that look like a
+// qualifier but use an unknown key, such as URLs, are searched as text.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Message senders for the from: qualifier
+const (
+	FromContact  = "contact"  // Incoming messages
+	FromAgent    = "agent"    // Outgoing messages sent by a user
+	FromCampaign = "campaign" // Outgoing messages sent by a campaign
+)
+
+// Assignee values for the agent: qualifier, besides an email or user ID
+const (
+	AgentMe         = "me"
+	AgentUnassigned = "unassigned"
+)
+
+// dateLayout is the format of the after:, before: and on: qualifiers
+const dateLayout = "2006-01-02"
+
+// Query is a parsed search query
+type Query struct {
+	Terms    []string   // Words and phrases the message must contain
+	Agent    string     // Conversation's assigned agent: me, unassigned, an email or a user ID
+	Labels   []string   // Label names the conversation must all have
+	Account  string     // WhatsApp account name
+	After    *time.Time // Messages sent on or after this time
+	Before   *time.Time // Messages sent before this time
+	HasMedia bool       // Messages with an attachment
+	From     string     // contact, agent or campaign
+}
+
+// IsEmpty reports whether the query has neither text nor qualifiers
+func (q *Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && q.Agent == "" && len(q.Labels) == 0 && q.Account == "" &&
+		q.After == nil && q.Before == nil && !q.HasMedia && q.From == ""
+}
+
+// Parse parses a search query. Dates are read as days in loc.
+func Parse(input string, loc *time.Location) (*Query, error) {
+	q := &Query{}
+	for _, token := range tokenize(input) {
+		key, value, ok := qualifier(token)
+		if !ok {
+			if text := unquote(token); text != "" {
+				q.Terms = append(q.Terms, text)
+			}
+			continue
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%s: needs a value", key)
+		}
+
+		switch key {
+		case "agent":
+			q.Agent = value
+		case "label":
+			q.Labels = append(q.Labels, value)
+		case "account":
+			q.Account = value
+		case "after", "before", "on":
+			day, err := time.ParseInLocation(dateLayout, value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: expects a date like 2024-01-31", key)
+			}
+			switch key {
+			case "after":
+				q.After = &day
+			case "before":
+				q.Before = &day
+			default:
+				next := day.AddDate(0, 0, 1)
+				q.After, q.Before = &day, &next
+			}
+		case "has":
+			if value != "media" {
+				return nil, fmt.Errorf("has: only supports media")
+			}
+			q.HasMedia = true
+		case "from":
+			switch value {
+			case FromContact, FromAgent, FromCampaign:
+				q.From = value
+			default:
+				return nil, fmt.Errorf("from: expects contact, agent or campaign")
+			}
+		}
+	}
+
+	if q.After != nil && q.Before != nil && !q.Before.After(*q.After) {
+		return nil, fmt.Errorf("before: must be later than after:")
+	}
+	return q, nil
+}
+
+// qualifiers are the keys Parse understands; other key:value words are text
+var qualifiers = map[string]bool{
+	"agent": true, "label": true, "account": true,
+	"after": true, "before": true, "on": true,
+	"has": true, "from": true,
+}
+
+// qualifier splits a key:value token. Keys are case-insensitive; values keep their
+// case except for the fixed ones, which are lowercased.
+func qualifier(token string) (key, value string, ok bool) {
+	i := strings.Index(token, ":")
+	if i <= 0 || strings.HasPrefix(token, `"`) {
+		return "", "", false
+	}
+	key = strings.ToLower(token[:i])
+	if !qualifiers[key] {
+		return "", "", false
+	}
+	value = unquote(token[i+1:])
+	switch key {
+	case "has", "from":
+		value = strings.ToLower(value)
+	case "agent":
+		if lower := strings.ToLower(value); lower == AgentMe || lower == AgentUnassigned {
+			value = lower
+		}
+	}
+	return key, value, true
+}
+
+// tokenize splits input on whitespace, keeping double-quoted text together, also
+// after a qualifier key as in label:"high priority". An unclosed quote runs to the
+// end of the input.
+func tokenize(input string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// unquote strips the double quotes around s, if any
+func unquote(s string) string {
+	return strings.TrimSpace(strings.Trim(s, `"`))
+}
@@ -130,7 +130,11 @@ func (h *Hub) broadcastMessage(msg BroadcastMessage) {
 	}
 
 	// Iterate through all users in the organization
-	for _, userClients := range orgClients {
+	for userID, userClients := range orgClients {
+		if len(msg.UserIDs) > 0 && !containsUser(msg.UserIDs, userID) {
+			continue
+		}
+
 		// Iterate through all clients (tabs) for each user
 		for client := range userClients {
 			// If ContactID is specified, only send to clients viewing that contact
@@ -176,6 +180,27 @@ func (h *Hub) BroadcastToContact(orgID, contactID uuid.UUID, msg WSMessage) {
 	})
 }
 
+// BroadcastToUsers sends a message to the given users' clients in an organization
+func (h *Hub) BroadcastToUsers(orgID uuid.UUID, userIDs []uuid.UUID, msg WSMessage) {
+	if len(userIDs) == 0 {
+		return
+	}
+	h.Broadcast(BroadcastMessage{
+		OrgID:   orgID,
+		UserIDs: userIDs,
+		Message: msg,
+	})
+}
+
+func containsUser(userIDs []uuid.UUID, userID uuid.UUID) bool {
+	for _, id := range userIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
 // countClients returns the total number of connected clients
 func (h *Hub) countClients() int {
 	count := 0
@@ -194,6 +219,13 @@ func (h *Hub) GetClientCount() int {
 	return h.countClients()
 }
 
+// IsUserConnected reports whether a user has a client connected to this server
+func (h *Hub) IsUserConnected(orgID, userID uuid.UUID) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[orgID][userID]) > 0
+}
+
 // Register adds a client to the hub via the register channel
 func (h *Hub) Register(client *Client) {
 	h.register <- client
@@ -23,13 +23,36 @@ const (
 	TypeAgentTransferAssign = "agent_transfer_assign"
 
 	// Campaign types
-	TypeCampaignStatsUpdate = "campaign_stats_update"
+	TypeCampaignStatsUpdate    = "campaign_stats_update"
+	TypeCampaignTemplateStatus = "campaign_template_status"
+
+	// Conversation types
+	TypeConversationLabels = "conversation_labels"
+	TypeConversationSeen   = "conversation_seen"
+	TypeConversationUpdate = "conversation_update"
+	TypeConversationShare  = "conversation_share"
+	TypeInboxViewCounts    = "inbox_view_counts"
+
+	// Notification types
+	TypeNotificationDigest = "notification_digest"
+
+	// Background job types
+	TypeJobProgress = "job_progress"
+
+	// Alert types
+	TypeMetricAnomaly       = "metric_anomaly"
+	TypeWebhookSubscription = "webhook_subscription"
+	TypeAccountStatus       = "account_status"
+	TypeMissedReceipts      = "missed_receipts"
+	TypeAPIVersion          = "api_version"
+	TypeBotMessageLimit     = "bot_message_limit"
 )
 
 // BroadcastMessage represents a message to be broadcast to clients
 type BroadcastMessage struct {
 	OrgID     uuid.UUID
-	ContactID uuid.UUID // Optional: only send to users viewing this contact
+	ContactID uuid.UUID   // Optional: only send to users viewing this contact
+	UserIDs   []uuid.UUID // Optional: only send to these users
 	Message   WSMessage
 }
 
@@ -0,0 +1,228 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recipientClaimTimeout is how long a worker may take to send to a recipient it
+// claimed. Older claims were left behind by a worker that stopped mid-send, or belong
+// to a batch a worker is taking too long to get through.
+const recipientClaimTimeout = 10 * time.Minute
+
+// claimBatchFactor sizes the batches of recipients a run claims at a time, as a
+// multiple of its concurrency: enough to keep it busy, while leaving the rest of the
+// campaign to other workers sending it
+const claimBatchFactor = 10
+
+// claimBatchSize returns how many recipients the run claims at a time: claimBatchFactor
+// times its concurrency, but no more than its campaign's and account's throttles let
+// it send in half of recipientClaimTimeout, so a batch's claims don't lapse while its
+// tail waits its turn
+func (w *Worker) claimBatchSize(run *campaignRun) int {
+	size := min(run.concurrency*claimBatchFactor, recipientBatchSize)
+	window := recipientClaimTimeout / 2
+	if perMinute := run.campaign.MaxMessagesPerMinute; perMinute > 0 {
+		size = min(size, int(float64(perMinute)*window.Minutes()))
+	}
+	size = min(size, int(float64(w.messagesPerSecond(run.account))*window.Seconds()))
+	return max(1, size)
+}
+
+// recipientUnclaimed matches recipients no worker is sending to
+const recipientUnclaimed = "claimed_at IS NULL"
+
+// interruptedSendError is recorded on recipients whose worker stopped mid-send
+const interruptedSendError = "Sending was interrupted and may not have completed; retry to send again"
+
+// claimRecipients claims up to size of the campaign's pending recipients after cursor
+// that are ready and unclaimed, in primary key order. Recipients another worker is
+// claiming at the same moment are skipped rather than waited for, so workers sending
+// the same campaign each get a batch of their own.
+func (w *Worker) claimRecipients(campaignID uuid.UUID, cursor *uuid.UUID, size int) ([]models.BulkMessageRecipient, error) {
+	var batch []models.BulkMessageRecipient
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("campaign_id = ? AND status = ?", campaignID, "pending").
+			Where(recipientReady, time.Now()).Where(recipientUnclaimed)
+		if cursor != nil {
+			query = query.Where("id > ?", *cursor)
+		}
+		if err := query.Order("id").Limit(size).Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		return tx.Model(&models.BulkMessageRecipient{}).Where("id IN ?", recipientIDs(batch)).
+			Updates(map[string]interface{}{
+				"claimed_by": w.Consumer.ID(),
+				"claimed_at": time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// claimRecipient marks a recipient of a batch this worker claimed as being sent to
+// now. It reports false if the recipient is no longer pending, or the batch's claim
+// on it lapsed and it went to another worker.
+func (w *Worker) claimRecipient(recipient *models.BulkMessageRecipient) bool {
+	result := w.DB.Model(&models.BulkMessageRecipient{}).
+		Where("id = ? AND status = ? AND claimed_by = ?", recipient.ID, "pending", w.Consumer.ID()).
+		Update("claimed_at", time.Now())
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// releaseClaims gives up this worker's claims on the batch's recipients. Sends to them
+// must have finished.
+func (w *Worker) releaseClaims(batch []models.BulkMessageRecipient) {
+	if err := w.DB.Model(&models.BulkMessageRecipient{}).
+		Where("id IN ? AND claimed_by = ?", recipientIDs(batch), w.Consumer.ID()).
+		Updates(map[string]interface{}{
+			"claimed_by": "",
+			"claimed_at": nil,
+		}).Error; err != nil {
+		w.Log.Error("Failed to release recipient claims", "error", err, "count", len(batch))
+	}
+}
+
+// recipientIDs returns the IDs of recipients
+func recipientIDs(recipients []models.BulkMessageRecipient) []uuid.UUID {
+	ids := make([]uuid.UUID, len(recipients))
+	for i := range recipients {
+		ids[i] = recipients[i].ID
+	}
+	return ids
+}
+
+// releaseRecipient gives up the claim on a recipient left pending, so it is sent to
+// when the campaign resumes
+func (w *Worker) releaseRecipient(recipient *models.BulkMessageRecipient) {
+	w.DB.Model(recipient).Updates(map[string]interface{}{
+		"claimed_by": "",
+		"claimed_at": nil,
+	})
+}
+
+// settleAttemptedRecipient records the outcome of a send attempt an earlier run saved
+// the message for. An attempt the message is still pending for may or may not have
+// been sent, so it is failed rather than sent twice.
+func (w *Worker) settleAttemptedRecipient(ctx context.Context, run *campaignRun, recipient *models.BulkMessageRecipient, message *models.Message) {
+	update := map[string]interface{}{
+		"status":     message.Status,
+		"message_id": message.ID,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"claimed_by": "",
+		"claimed_at": nil,
+	}
+	switch message.Status {
+	case "pending":
+		w.DB.Model(message).Updates(map[string]interface{}{
+			"status":        "failed",
+			"error_message": interruptedSendError,
+		})
+		update["status"] = "failed"
+		update["error_message"] = interruptedSendError
+		update["error_category"] = whatsapp.FailureUnknown
+	case "failed":
+		update["error_message"] = message.ErrorMessage
+		update["error_category"] = whatsapp.FailureUnknown
+	default:
+		update["whats_app_message_id"] = message.WhatsAppMessageID
+		update["sent_at"] = message.CreatedAt
+	}
+	w.DB.Model(recipient).Updates(update)
+	w.recordOutcome(ctx, run, update["status"] != "failed")
+}
+
+// recoverAbandonedClaims settles the campaign's recipients claimed by a worker that
+// stopped mid-send, adding them to the campaign's counts. Recipients whose message
+// was sent take its status. Those no message was saved for since the claim weren't
+// sent to yet, as a message is saved before each send, so they are released. The
+// others may or may not have been sent to, so they are failed rather than sent to
+// twice, along with any message left pending.
+func (w *Worker) recoverAbandonedClaims(campaign *models.BulkMessageCampaign) {
+	cutoff := time.Now().Add(-recipientClaimTimeout)
+
+	sent := w.DB.Exec(`UPDATE bulk_message_recipients r
+		SET status = m.status, whats_app_message_id = m.whats_app_message_id, message_id = m.id,
+			sent_at = m.created_at, attempts = r.attempts + 1, claimed_by = '', claimed_at = NULL, updated_at = NOW()
+		FROM messages m
+		WHERE r.campaign_id = ? AND r.status = 'pending' AND r.claimed_at < ? AND r.deleted_at IS NULL
+			AND m.metadata->>'recipient_id' = r.id::text AND m.created_at >= r.claimed_at
+			AND m.status NOT IN ('pending', 'failed') AND m.deleted_at IS NULL`, campaign.ID, cutoff)
+
+	pending := w.DB.Exec(`WITH failed AS (
+			UPDATE messages m
+			SET status = 'failed', error_message = ?, updated_at = NOW()
+			FROM bulk_message_recipients r
+			WHERE r.campaign_id = ? AND r.status = 'pending' AND r.claimed_at < ? AND r.deleted_at IS NULL
+				AND m.metadata->>'recipient_id' = r.id::text AND m.idempotency_key IS NOT NULL
+				AND m.status = 'pending' AND m.deleted_at IS NULL
+			RETURNING m.id, m.organization_id
+		)
+		INSERT INTO message_events (organization_id, message_id, event, source, error_message, occurred_at, created_at)
+		SELECT organization_id, id, ?, ?, ?, NOW(), NOW() FROM failed`,
+		interruptedSendError, campaign.ID, cutoff,
+		models.MessageEventFailed, models.MessageEventSourceApp, interruptedSendError)
+
+	released := w.DB.Exec(`UPDATE bulk_message_recipients r
+		SET claimed_by = '', claimed_at = NULL, updated_at = NOW()
+		WHERE r.campaign_id = ? AND r.status = 'pending' AND r.claimed_at < ? AND r.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM messages m
+				WHERE m.metadata->>'recipient_id' = r.id::text AND m.created_at >= r.claimed_at AND m.deleted_at IS NULL)`,
+		campaign.ID, cutoff)
+
+	failed := w.DB.Model(&models.BulkMessageRecipient{}).
+		Where("campaign_id = ? AND status = ? AND claimed_at < ?", campaign.ID, "pending", cutoff).
+		Updates(map[string]interface{}{
+			"status":         "failed",
+			"error_message":  interruptedSendError,
+			"error_category": whatsapp.FailureUnknown,
+			"attempts":       gorm.Expr("attempts + 1"),
+			"claimed_by":     "",
+			"claimed_at":     nil,
+		})
+
+	if sent.Error != nil || pending.Error != nil || released.Error != nil || failed.Error != nil {
+		w.Log.Error("Failed to recover abandoned recipients", "sent_error", sent.Error, "pending_error", pending.Error, "released_error", released.Error, "failed_error", failed.Error, "campaign_id", campaign.ID)
+	}
+	if released.RowsAffected > 0 {
+		w.Log.Warn("Released recipients claimed but never sent to", "campaign_id", campaign.ID, "count", released.RowsAffected)
+	}
+	if sent.RowsAffected == 0 && failed.RowsAffected == 0 {
+		return
+	}
+
+	// Other workers may be sending the campaign, so the counts are added to
+	campaign.SentCount += int(sent.RowsAffected)
+	campaign.FailedCount += int(failed.RowsAffected)
+	w.DB.Model(campaign).Updates(map[string]interface{}{
+		"sent_count":   gorm.Expr("sent_count + ?", sent.RowsAffected),
+		"failed_count": gorm.Expr("failed_count + ?", failed.RowsAffected),
+	})
+	w.Log.Warn("Recovered recipients abandoned mid-send", "campaign_id", campaign.ID, "sent", sent.RowsAffected, "failed", failed.RowsAffected)
+}
+
+// nextClaimExpiry returns when the first claim another worker holds on one of the
+// campaign's recipients is treated as abandoned, or nil if none are claimed
+func (w *Worker) nextClaimExpiry(campaignID uuid.UUID) *time.Time {
+	var oldest models.BulkMessageRecipient
+	err := w.DB.Select("claimed_at").
+		Where("campaign_id = ? AND status = ? AND claimed_at IS NOT NULL", campaignID, "pending").
+		Order("claimed_at").First(&oldest).Error
+	if err != nil || oldest.ClaimedAt == nil {
+		return nil
+	}
+	expiry := oldest.ClaimedAt.Add(recipientClaimTimeout)
+	return &expiry
+}
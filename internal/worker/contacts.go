@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// contactPreloadBatchSize bounds the number of phone numbers per IN query / insert batch
+const contactPreloadBatchSize = 1000
+
+// normalizePhone strips the leading + so "+15551234" and "15551234" resolve to the same contact
+func normalizePhone(phoneNumber string) string {
+	return strings.TrimPrefix(strings.TrimSpace(phoneNumber), "+")
+}
+
+// preloadContacts resolves the contacts for all recipients up front, creating any that are
+// missing, so the send loop does not hit the database per recipient. The returned map is
+// keyed by normalized phone number.
+func (w *Worker) preloadContacts(orgID uuid.UUID, recipients []models.BulkMessageRecipient) (map[string]*models.Contact, error) {
+	// Unique normalized phones, keeping the first recipient name seen for each
+	names := make(map[string]string, len(recipients))
+	phones := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		phone := normalizePhone(recipient.PhoneNumber)
+		if phone == "" {
+			continue
+		}
+		if _, ok := names[phone]; ok {
+			continue
+		}
+		names[phone] = recipient.RecipientName
+		phones = append(phones, phone)
+	}
+
+	contacts := make(map[string]*models.Contact, len(phones))
+	if err := w.loadContacts(orgID, phones, contacts); err != nil {
+		return nil, err
+	}
+
+	// Create the contacts that don't exist yet
+	var missing []models.Contact
+	for _, phone := range phones {
+		if _, ok := contacts[phone]; !ok {
+			missing = append(missing, models.Contact{
+				OrganizationID: orgID,
+				PhoneNumber:    phone,
+				ProfileName:    names[phone],
+			})
+		}
+	}
+	if len(missing) == 0 {
+		return contacts, nil
+	}
+
+	// Another worker or an incoming message may create the same contact concurrently;
+	// the upsert returns the existing row in that case
+	if err := w.DB.Clauses(models.ContactUpsert(), clause.Returning{}).CreateInBatches(&missing, contactPreloadBatchSize).Error; err != nil {
+		return nil, fmt.Errorf("failed to create contacts: %w", err)
+	}
+	for i := range missing {
+		contacts[normalizePhone(missing[i].PhoneNumber)] = &missing[i]
+	}
+
+	w.Log.Info("Preloaded campaign contacts", "organization_id", orgID, "total", len(phones), "created", len(missing))
+	return contacts, nil
+}
+
+// loadContacts fetches existing contacts for normalized phones (stored with or without +) into dst
+func (w *Worker) loadContacts(orgID uuid.UUID, phones []string, dst map[string]*models.Contact) error {
+	for start := 0; start < len(phones); start += contactPreloadBatchSize {
+		end := min(start+contactPreloadBatchSize, len(phones))
+
+		candidates := make([]string, 0, (end-start)*2)
+		for _, phone := range phones[start:end] {
+			candidates = append(candidates, phone, "+"+phone)
+		}
+
+		var found []models.Contact
+		if err := w.DB.Where("organization_id = ? AND phone_number IN ?", orgID, candidates).Find(&found).Error; err != nil {
+			return fmt.Errorf("failed to load contacts: %w", err)
+		}
+		for i := range found {
+			phone := normalizePhone(found[i].PhoneNumber)
+			dst[phone] = &found[i]
+		}
+	}
+	return nil
+}
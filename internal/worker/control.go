@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+)
+
+// campaignStatusCheckInterval is how often a run reads its campaign's status, in case
+// a pause or cancel signal was missed while the worker was reconnecting to Redis
+const campaignStatusCheckInterval = 10 * time.Second
+
+// runCampaignControl stops runs as soon as their campaign is paused or cancelled, as
+// signalled on the campaign control channel. A worker may have several runs of one
+// campaign, such as a main run and a helper run; all of them are stopped.
+func (w *Worker) runCampaignControl(ctx context.Context) {
+	sub := queue.NewSubscriber(w.Redis, w.Log)
+	err := sub.SubscribeCampaignControl(ctx, func(control *queue.CampaignControl) {
+		id, err := uuid.Parse(control.CampaignID)
+		if err != nil {
+			return
+		}
+		w.runs.Range(func(key, _ interface{}) bool {
+			if run := key.(*campaignRun); run.campaign.ID == id {
+				w.stopRun(run, control.Status)
+			}
+			return true
+		})
+	})
+	if err != nil {
+		w.Log.Error("Failed to subscribe to campaign control, relying on status checks", "error", err)
+		return
+	}
+
+	<-ctx.Done()
+	sub.Close()
+}
+
+// watchCampaign registers the run for pause and cancel signals and checks the
+// campaign's status every campaignStatusCheckInterval, along with whether it has
+// webhooks for recipient events. The returned func stops both.
+func (w *Worker) watchCampaign(run *campaignRun) func() {
+	id := run.campaign.ID
+	w.runs.Store(run, struct{}{})
+	w.checkCampaignWebhooks(run)
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(campaignStatusCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				var status string
+				if err := w.DB.Model(&models.BulkMessageCampaign{}).Where("id = ?", id).Pluck("status", &status).Error; err != nil {
+					continue
+				}
+				if status == "paused" || status == "cancelled" {
+					w.stopRun(run, status)
+				}
+				w.checkCampaignWebhooks(run)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		w.runs.Delete(run)
+	}
+}
+
+// checkCampaignWebhooks records whether the run's campaign has active webhooks, which
+// its recipient events are published for
+func (w *Worker) checkCampaignWebhooks(run *campaignRun) {
+	var count int64
+	err := w.DB.Model(&models.Webhook{}).
+		Where("campaign_id = ? AND is_active = ? AND (expires_at IS NULL OR expires_at > ?)", run.campaign.ID, true, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return
+	}
+	run.recipientEvents.Store(count > 0)
+}
+
+// publishCancelledStats publishes the final stats of a run whose campaign was
+// cancelled, after any coalesced ones, so live views end on the counts it reached
+func (w *Worker) publishCancelledStats(run *campaignRun) {
+	campaign := run.campaign
+	var current models.BulkMessageCampaign
+	if err := w.DB.Select("status", "delivered_count", "read_count").Where("id = ?", campaign.ID).First(&current).Error; err != nil || current.Status != "cancelled" {
+		return
+	}
+
+	var cancelled int64
+	w.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ? AND status = ?", campaign.ID, "cancelled").Count(&cancelled)
+	run.mu.Lock()
+	sentCount, failedCount := run.sentCount, run.failedCount
+	run.mu.Unlock()
+	w.Publisher.PublishCampaignStats(context.Background(), &queue.CampaignStatsUpdate{
+		CampaignID:     campaign.ID.String(),
+		OrganizationID: campaign.OrganizationID,
+		Status:         "cancelled",
+		SentCount:      sentCount,
+		DeliveredCount: current.DeliveredCount,
+		ReadCount:      current.ReadCount,
+		FailedCount:    failedCount,
+		CancelledCount: int(cancelled),
+	})
+}
+
+// stopRun makes the run stop before its next recipient
+func (w *Worker) stopRun(run *campaignRun, status string) {
+	if run.stopped.CompareAndSwap(false, true) {
+		w.Log.Info("Campaign stopped", "campaign_id", run.campaign.ID, "status", status)
+	}
+}
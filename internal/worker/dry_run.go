@@ -0,0 +1,253 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/jobs"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// dryRunCampaign runs the campaign's sending pipeline for its pending recipients up
+// to the send, and saves what each would be sent or why it would be skipped or fail
+// as the dry run's rows. Nothing is sent and neither the campaign, its recipients nor contacts change.
+// A dry run that fails isn't retried; one interrupted by shutdown starts over on the
+// next worker.
+func (w *Worker) dryRunCampaign(ctx context.Context, campaignID, dryRunID uuid.UUID) error {
+	var dryRun models.CampaignDryRun
+	if err := w.DB.Where("id = ? AND campaign_id = ?", dryRunID, campaignID).First(&dryRun).Error; err != nil {
+		w.Log.Info("Dry run no longer exists, skipping", "campaign_id", campaignID, "dry_run_id", dryRunID)
+		return nil
+	}
+	if dryRun.Status != "queued" && dryRun.Status != "running" {
+		return nil
+	}
+	w.Log.Info("Dry running campaign", "campaign_id", campaignID, "dry_run_id", dryRunID)
+
+	var tracker *jobs.Tracker
+	if dryRun.JobID != nil {
+		tracker = jobs.Open(w.DB, w.Publisher, *dryRun.JobID)
+	}
+
+	var campaign models.BulkMessageCampaign
+	if err := w.DB.Where("id = ?", campaignID).First(&campaign).Error; err != nil {
+		w.failDryRun(&dryRun, tracker, "Campaign not found")
+		return nil
+	}
+
+	// Load what sending loads, failing the dry run where sending would fail the campaign
+	template, err := w.Cache.Template(ctx, campaign.OrganizationID, campaign.TemplateID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		w.failDryRun(&dryRun, tracker, "Failed to load template")
+		return nil
+	}
+	var variants []models.CampaignVariant
+	if err := w.DB.Where("campaign_id = ?", campaign.ID).Order("position").Find(&variants).Error; err != nil {
+		w.failDryRun(&dryRun, tracker, "Failed to load campaign variants")
+		return nil
+	}
+	for i := range variants {
+		if variants[i].Template, err = w.Cache.Template(ctx, campaign.OrganizationID, variants[i].TemplateID); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.failDryRun(&dryRun, tracker, "Failed to load template for variant "+variants[i].Name)
+			return nil
+		}
+	}
+	account, err := w.Cache.WhatsAppAccount(ctx, campaign.OrganizationID, campaign.WhatsAppAccount)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		w.failDryRun(&dryRun, tracker, "Failed to load WhatsApp account")
+		return nil
+	}
+
+	var total int64
+	if err := w.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ? AND status = ?", campaignID, "pending").Count(&total).Error; err != nil {
+		w.failDryRun(&dryRun, tracker, "Failed to load recipients")
+		return nil
+	}
+	w.DB.Model(&dryRun).Update("status", "running")
+	tracker.Start(int(total))
+
+	run := &campaignRun{
+		campaign:    &campaign,
+		template:    template,
+		variants:    variants,
+		account:     account,
+		orgSettings: w.orgSettings(ctx, campaign.OrganizationID),
+		orgLocation: w.orgLocation(ctx, campaign.OrganizationID),
+	}
+
+	// Go through the pending recipients in the order sending claims them. Rows left by
+	// an interrupted attempt are replaced.
+	if err := w.DB.Where("dry_run_id = ?", dryRun.ID).Delete(&models.CampaignDryRunRow{}).Error; err != nil {
+		w.failDryRun(&dryRun, tracker, "Failed to clear dry run")
+		return nil
+	}
+	created := make(map[string]bool) // A contact created for one recipient is found by later ones with the number
+	var cursor *uuid.UUID
+	for {
+		if err := ctx.Err(); err != nil {
+			w.Log.Info("Campaign dry run interrupted by shutdown", "campaign_id", campaignID, "dry_run_id", dryRunID)
+			return err
+		}
+
+		var batch []models.BulkMessageRecipient
+		query := w.DB.Where("campaign_id = ? AND status = ?", campaignID, "pending").Order("id").Limit(recipientBatchSize)
+		if cursor != nil {
+			query = query.Where("id > ?", *cursor)
+		}
+		if err := query.Find(&batch).Error; err != nil {
+			w.Log.Error("Failed to load recipients for dry run", "error", err, "campaign_id", campaignID)
+			w.failDryRun(&dryRun, tracker, "Failed to load recipients")
+			return nil
+		}
+		if len(batch) == 0 {
+			break
+		}
+		cursor = &batch[len(batch)-1].ID
+
+		// Only look up contacts; sending would create the missing ones
+		phones := make([]string, 0, len(batch))
+		for i := range batch {
+			if phone := normalizePhone(batch[i].PhoneNumber); phone != "" {
+				phones = append(phones, phone)
+			}
+		}
+		contacts := make(map[string]*models.Contact, len(phones))
+		if err := w.loadContacts(campaign.OrganizationID, phones, contacts); err != nil {
+			w.Log.Error("Failed to load contacts for dry run", "error", err, "campaign_id", campaignID)
+			w.failDryRun(&dryRun, tracker, "Failed to load contacts")
+			return nil
+		}
+
+		rows := make([]models.CampaignDryRunRow, len(batch))
+		for i := range batch {
+			row := &rows[i]
+			*row = dryRunRecipient(run, dryRun.ID, contacts, &batch[i])
+			if row.NewContact {
+				phone := normalizePhone(row.PhoneNumber)
+				row.NewContact = !created[phone]
+				created[phone] = true
+			}
+
+			dryRun.Total++
+			switch row.Outcome {
+			case models.DryRunSend:
+				dryRun.WillSend++
+			case models.DryRunDefer:
+				dryRun.WillSend++
+				dryRun.Deferred++
+			case models.DryRunSkip:
+				dryRun.Skipped++
+			case models.DryRunFail:
+				dryRun.Failed++
+			}
+			if row.NewContact {
+				dryRun.NewContacts++
+			}
+		}
+		if err := w.DB.Create(&rows).Error; err != nil {
+			w.Log.Error("Failed to save dry run rows", "error", err, "dry_run_id", dryRunID)
+			w.failDryRun(&dryRun, tracker, "Failed to save dry run")
+			return nil
+		}
+		tracker.Advance(len(batch))
+	}
+
+	now := time.Now()
+	if err := w.DB.Model(&dryRun).Updates(map[string]interface{}{
+		"status":       "completed",
+		"total":        dryRun.Total,
+		"will_send":    dryRun.WillSend,
+		"deferred":     dryRun.Deferred,
+		"skipped":      dryRun.Skipped,
+		"failed":       dryRun.Failed,
+		"new_contacts": dryRun.NewContacts,
+		"error":        "",
+		"completed_at": now,
+	}).Error; err != nil {
+		w.Log.Error("Failed to save dry run", "error", err, "dry_run_id", dryRunID)
+		tracker.Fail("Failed to save dry run")
+		return nil
+	}
+	tracker.Complete(fmt.Sprintf("/api/campaigns/%s/dry-run", campaignID), models.JSONB{
+		"total":        dryRun.Total,
+		"will_send":    dryRun.WillSend,
+		"deferred":     dryRun.Deferred,
+		"skipped":      dryRun.Skipped,
+		"failed":       dryRun.Failed,
+		"new_contacts": dryRun.NewContacts,
+	})
+
+	w.Log.Info("Campaign dry run completed", "campaign_id", campaignID, "dry_run_id", dryRunID,
+		"total", dryRun.Total, "will_send", dryRun.WillSend, "skipped", dryRun.Skipped, "failed", dryRun.Failed)
+	return nil
+}
+
+// dryRunRecipient works out what processRecipient would do for the recipient, short
+// of creating its contact and sending
+func dryRunRecipient(run *campaignRun, dryRunID uuid.UUID, contacts map[string]*models.Contact, recipient *models.BulkMessageRecipient) models.CampaignDryRunRow {
+	row := models.CampaignDryRunRow{
+		DryRunID:      dryRunID,
+		RecipientID:   recipient.ID,
+		PhoneNumber:   recipient.PhoneNumber,
+		RecipientName: recipient.RecipientName,
+	}
+
+	// A contact created for the recipient has only their name to fill parameters from
+	contact, ok := contacts[normalizePhone(recipient.PhoneNumber)]
+	if !ok {
+		contact = &models.Contact{
+			OrganizationID: run.campaign.OrganizationID,
+			PhoneNumber:    normalizePhone(recipient.PhoneNumber),
+			ProfileName:    recipient.RecipientName,
+		}
+		row.NewContact = true
+	}
+
+	plan := run.planRecipient(contact, recipient)
+	row.Variant = plan.variant
+	if plan.template != nil {
+		row.TemplateName, row.Language = plan.template.Name, plan.template.Language
+	}
+	if plan.invalid != "" {
+		row.Outcome = models.DryRunFail
+		row.Error = plan.invalid
+		return row
+	}
+	if len(plan.missing) > 0 {
+		row.Outcome = models.DryRunSkip
+		row.Error = models.MissingParamsError(plan.missing)
+		return row
+	}
+	row.TemplateParams = plan.params
+	if plan.template != nil {
+		row.Body = renderTemplateBody(plan.template, plan.params)
+	}
+
+	row.Outcome = models.DryRunSend
+	if plan.opensAt != nil {
+		row.Outcome = models.DryRunDefer
+		row.SendAt = plan.opensAt
+	}
+	return row
+}
+
+func (w *Worker) failDryRun(dryRun *models.CampaignDryRun, tracker *jobs.Tracker, errMsg string) {
+	w.Log.Error("Campaign dry run failed", "campaign_id", dryRun.CampaignID, "dry_run_id", dryRun.ID, "error", errMsg)
+	w.DB.Model(dryRun).Updates(map[string]interface{}{
+		"status":       "failed",
+		"error":        errMsg,
+		"completed_at": time.Now(),
+	})
+	tracker.Fail(errMsg)
+}
@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/shridarpatil/whatomate/internal/engagement"
+)
+
+// engagementLockKey makes sure only one worker rescores contacts per interval
+const engagementLockKey = "worker:engagement_refresh_lock"
+
+// runEngagementScoring periodically recomputes contact engagement scores until ctx is cancelled
+func (w *Worker) runEngagementScoring(ctx context.Context) {
+	ticker := time.NewTicker(engagement.RefreshInterval)
+	defer ticker.Stop()
+
+	w.refreshEngagementScores(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.refreshEngagementScores(ctx)
+		}
+	}
+}
+
+// refreshEngagementScores rescores every contact unless another worker did so this interval
+func (w *Worker) refreshEngagementScores(ctx context.Context) {
+	acquired, err := w.Redis.SetNX(ctx, engagementLockKey, 1, engagement.RefreshInterval-5*time.Minute).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	start := time.Now()
+	count, err := engagement.Refresh(w.DB, start)
+	if err != nil {
+		w.Log.Error("Failed to refresh engagement scores", "error", err, "scored", count)
+		return
+	}
+	w.Log.Info("Refreshed engagement scores", "contacts", count, "duration", time.Since(start))
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/holidays"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+)
+
+const (
+	// holidayRefreshCheckInterval is how often the worker looks for calendars due a refresh
+	holidayRefreshCheckInterval = time.Hour
+
+	// holidayRefreshLockKey makes sure only one worker refreshes calendars per cycle
+	holidayRefreshLockKey = "worker:holiday_refresh_lock"
+)
+
+// runHolidayRefresh periodically re-imports holiday calendars until ctx is cancelled
+func (w *Worker) runHolidayRefresh(ctx context.Context) {
+	ticker := time.NewTicker(holidayRefreshCheckInterval)
+	defer ticker.Stop()
+
+	w.refreshHolidayCalendars(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.refreshHolidayCalendars(ctx)
+		}
+	}
+}
+
+// refreshHolidayCalendars syncs every active calendar that hasn't been refreshed within holidays.RefreshInterval
+func (w *Worker) refreshHolidayCalendars(ctx context.Context) {
+	acquired, err := w.Redis.SetNX(ctx, holidayRefreshLockKey, 1, holidayRefreshCheckInterval-5*time.Minute).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	calendars, err := holidays.DueCalendars(w.DB)
+	if err != nil {
+		w.Log.Error("Failed to load holiday calendars", "error", err)
+		return
+	}
+
+	for i := range calendars {
+		count, err := holidays.Sync(ctx, w.DB, &calendars[i])
+		if err != nil {
+			w.Log.Error("Failed to refresh holiday calendar", "error", err, "calendar_id", calendars[i].ID)
+			continue
+		}
+		w.Log.Info("Refreshed holiday calendar", "calendar_id", calendars[i].ID, "dates", count)
+	}
+}
+
+// campaignBlackout reports whether campaigns for the organization are blocked today in its timezone
+func (w *Worker) campaignBlackout(ctx context.Context, orgID uuid.UUID) *holidays.Blackout {
+	timezone := ""
+	if settings, err := w.Cache.OrgSettings(ctx, orgID); err == nil {
+		timezone, _ = settings["timezone"].(string)
+	}
+
+	blackout, err := holidays.Check(w.DB, orgID, holidays.Today(timezone))
+	if err != nil {
+		w.Log.Error("Failed to check campaign blackout dates", "error", err, "organization_id", orgID)
+		return nil
+	}
+	return blackout
+}
+
+// campaignHold returns the hold the organization's quiet hours and blackout dates put
+// on its campaigns now, or nil if they may send
+func (w *Worker) campaignHold(ctx context.Context, orgID uuid.UUID) *holidays.Hold {
+	hold, err := holidays.CheckHold(w.DB, orgID, w.orgSettings(ctx, orgID), time.Now())
+	if err != nil {
+		w.Log.Error("Failed to check campaign quiet hours and blackout dates", "error", err, "organization_id", orgID)
+		return nil
+	}
+	return hold
+}
+
+// holdCampaign leaves a running campaign held until its hold ends, when
+// runDeferredCampaigns queues it again
+func (w *Worker) holdCampaign(ctx context.Context, run *campaignRun, hold *holidays.Hold) {
+	campaign := run.campaign
+	result := w.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND status = ?", campaign.ID, "processing").
+		Updates(map[string]interface{}{
+			"status":      "held",
+			"held_reason": hold.Reason,
+			"resume_at":   hold.Until,
+		})
+	if result.Error != nil {
+		w.Log.Error("Failed to hold campaign", "error", result.Error, "campaign_id", campaign.ID)
+		return
+	}
+	if result.RowsAffected == 0 {
+		// Paused or cancelled meanwhile
+		return
+	}
+	w.Log.Info("Holding campaign", "campaign_id", campaign.ID, "reason", hold.Reason, "until", hold.Until)
+
+	// Deliver the coalesced stats first so they don't follow, and hide, the held status
+	w.Publisher.FlushCampaignStats(context.Background(), campaign.ID.String())
+	run.mu.Lock()
+	sentCount, failedCount := run.sentCount, run.failedCount
+	run.mu.Unlock()
+	w.Publisher.PublishCampaignStats(ctx, &queue.CampaignStatsUpdate{
+		CampaignID:     campaign.ID.String(),
+		OrganizationID: campaign.OrganizationID,
+		Status:         "held",
+		SentCount:      sentCount,
+		FailedCount:    failedCount,
+	})
+	w.publishCampaignEvent(ctx, campaign, "held", hold.Reason)
+}
@@ -0,0 +1,371 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/database"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/offboarding"
+	"github.com/shridarpatil/whatomate/pkg/whatsapp"
+)
+
+const (
+	// offboardingPollInterval is how often the worker looks for offboardings to advance
+	offboardingPollInterval = time.Minute
+
+	// offboardingLockTTL frees an offboarding left locked by a worker that died
+	offboardingLockTTL = time.Hour
+
+	offboardingLockKey = "worker:offboarding_lock:"
+)
+
+// runOffboarding advances organization offboardings until ctx is cancelled
+func (w *Worker) runOffboarding(ctx context.Context) {
+	ticker := time.NewTicker(offboardingPollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.processOffboardings(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processOffboardings prepares newly requested offboardings and purges those whose
+// grace period has ended
+func (w *Worker) processOffboardings(ctx context.Context) {
+	var due []models.OrganizationOffboarding
+	err := w.DB.Where("status IN ? OR (status = ? AND purge_at <= ?)",
+		[]string{models.OffboardingExporting, models.OffboardingPurging}, models.OffboardingScheduled, time.Now()).
+		Find(&due).Error
+	if err != nil {
+		w.Log.Error("Failed to load offboardings", "error", err)
+		return
+	}
+
+	for i := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		ob := &due[i]
+
+		key := offboardingLockKey + ob.ID.String()
+		acquired, err := w.Redis.SetNX(ctx, key, 1, offboardingLockTTL).Result()
+		if err != nil || !acquired {
+			continue
+		}
+		if ob.Status == models.OffboardingExporting {
+			w.prepareOffboarding(ctx, ob)
+		} else {
+			w.purgeOrganization(ctx, ob)
+		}
+		w.Redis.Del(ctx, key)
+	}
+}
+
+// prepareOffboarding revokes the organization's access, unsubscribes its webhooks and
+// builds its data archive, then schedules the purge. A failed archive is retried on the
+// next poll.
+func (w *Worker) prepareOffboarding(ctx context.Context, ob *models.OrganizationOffboarding) {
+	if err := w.revokeOrganizationAccess(ob); err != nil {
+		w.failOffboardingStep(ob, "Failed to revoke access: "+err.Error())
+		return
+	}
+	w.unsubscribeOrganizationWebhooks(ctx, ob)
+
+	var org models.Organization
+	if err := w.DB.Where("id = ?", ob.OrganizationID).First(&org).Error; err != nil {
+		w.failOffboardingStep(ob, "Organization not found")
+		return
+	}
+
+	archivePath := filepath.Join("offboarding", ob.ID.String()+".zip")
+	manifest, size, checksum, err := w.writeOffboardingArchive(&org, archivePath)
+	if err != nil {
+		w.failOffboardingStep(ob, "Failed to build the data archive: "+err.Error())
+		return
+	}
+
+	var rows int64
+	exported := make(models.JSONB, len(manifest.Tables))
+	for name, count := range manifest.Tables {
+		exported[name] = count
+		rows += count
+	}
+	now := time.Now()
+	purgeAt := now.AddDate(0, 0, ob.GraceDays)
+	result := w.DB.Model(&models.OrganizationOffboarding{}).
+		Where("id = ? AND status = ?", ob.ID, models.OffboardingExporting).
+		Updates(map[string]interface{}{
+			"status":           models.OffboardingScheduled,
+			"purge_at":         purgeAt,
+			"archive_path":     archivePath,
+			"archive_size":     size,
+			"archive_sha256":   checksum,
+			"archive_ready_at": now,
+			"exported_counts":  exported,
+			"last_error":       "",
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		// Cancelled while the archive was being built
+		os.Remove(filepath.Join(w.mediaRoot(), archivePath))
+		return
+	}
+
+	detail := fmt.Sprintf("%d rows from %d tables and %d media files; data is purged on %s",
+		rows, len(manifest.Tables), manifest.MediaFiles, purgeAt.UTC().Format("2006-01-02"))
+	if len(manifest.MissingMedia) > 0 {
+		detail += fmt.Sprintf(" (%d media files were already missing from storage)", len(manifest.MissingMedia))
+	}
+	_ = offboarding.RecordEvent(w.DB, ob.ID, "archive_ready", detail)
+	w.Log.Info("Offboarding archive ready", "organization_id", ob.OrganizationID, "offboarding_id", ob.ID, "rows", rows)
+
+	w.emailOffboarding(ob, fmt.Sprintf("Your %s data archive is ready", ob.OrganizationName),
+		fmt.Sprintf("The data archive for %s is ready to download from the organization settings until %s, when the organization's data is permanently deleted.\n\nSHA-256: %s\n",
+			ob.OrganizationName, purgeAt.UTC().Format("2006-01-02"), checksum))
+}
+
+// revokeOrganizationAccess deactivates the organization's API keys, outbound webhooks,
+// BI service accounts and users other than admins, who keep access to download the
+// archive or cancel.
+// What it switches off is recorded so a cancelled offboarding can restore it.
+func (w *Worker) revokeOrganizationAccess(ob *models.OrganizationOffboarding) error {
+	revokes := []struct {
+		model interface{}
+		where string
+		ids   *models.StringArray
+		field string
+	}{
+		{&models.User{}, "role <> 'admin'", &ob.DeactivatedUserIDs, "deactivated_user_ids"},
+		{&models.APIKey{}, "", &ob.RevokedAPIKeyIDs, "revoked_api_key_ids"},
+		{&models.Webhook{}, "", &ob.DisabledWebhookIDs, "disabled_webhook_ids"},
+		{&models.BIServiceAccount{}, "", &ob.RevokedBIAccountIDs, "revoked_bi_account_ids"},
+	}
+
+	counts := make([]int, len(revokes))
+	for i, revoke := range revokes {
+		query := w.DB.Model(revoke.model).Where("organization_id = ? AND is_active = ?", ob.OrganizationID, true)
+		if revoke.where != "" {
+			query = query.Where(revoke.where)
+		}
+		var ids []uuid.UUID
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		// Record the IDs before switching them off so a crash in between can't lose them
+		for _, id := range ids {
+			*revoke.ids = append(*revoke.ids, id.String())
+		}
+		if err := w.DB.Model(ob).Update(revoke.field, *revoke.ids).Error; err != nil {
+			return err
+		}
+		if err := w.DB.Model(revoke.model).Where("id IN ?", ids).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		counts[i] = len(ids)
+	}
+
+	return offboarding.RecordEvent(w.DB, ob.ID, "access_revoked",
+		fmt.Sprintf("%d users deactivated, %d API keys revoked, %d outbound webhooks disabled, %d BI service accounts revoked; admins keep access until the purge",
+			counts[0], counts[1], counts[2], counts[3]))
+}
+
+// unsubscribeOrganizationWebhooks stops Meta delivering webhooks for the organization's
+// accounts. Failures are recorded rather than holding up the offboarding, since the
+// account's token may already have been revoked on Meta's side.
+func (w *Worker) unsubscribeOrganizationWebhooks(ctx context.Context, ob *models.OrganizationOffboarding) {
+	var accounts []models.WhatsAppAccount
+	if err := w.DB.Where("organization_id = ?", ob.OrganizationID).Find(&accounts).Error; err != nil {
+		w.Log.Error("Failed to load accounts for offboarding", "error", err, "offboarding_id", ob.ID)
+		return
+	}
+	if len(accounts) == 0 {
+		return
+	}
+
+	results := make([]string, len(accounts))
+	for i, account := range accounts {
+		err := w.WhatsApp.UnsubscribeApp(ctx, &whatsapp.Account{
+			PhoneID:     account.PhoneID,
+			BusinessID:  account.BusinessID,
+			APIVersion:  account.APIVersion,
+			AccessToken: account.AccessToken,
+		})
+		if err != nil {
+			results[i] = fmt.Sprintf("%s: failed: %v", account.Name, err)
+			continue
+		}
+		results[i] = account.Name + ": unsubscribed"
+	}
+	_ = offboarding.RecordEvent(w.DB, ob.ID, "webhooks_unsubscribed", strings.Join(results, "; "))
+}
+
+// writeOffboardingArchive exports the organization to a zip file in media storage,
+// returning its manifest, size and SHA-256
+func (w *Worker) writeOffboardingArchive(org *models.Organization, archivePath string) (*offboarding.Manifest, int64, string, error) {
+	path := filepath.Join(w.mediaRoot(), archivePath)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, 0, "", err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer os.Remove(tmp)
+
+	hash := sha256.New()
+	manifest, err := offboarding.Export(w.DB, org, w.mediaRoot(), io.MultiWriter(f, hash))
+	if err != nil {
+		f.Close()
+		return nil, 0, "", err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, "", err
+	}
+	if err := f.Close(); err != nil {
+		return nil, 0, "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, 0, "", err
+	}
+	return manifest, info.Size(), hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// purgeOrganization deletes the organization's data and archive and records what was
+// deleted. A purge that fails part way stays in "purging" and resumes on the next poll.
+func (w *Worker) purgeOrganization(ctx context.Context, ob *models.OrganizationOffboarding) {
+	if ob.Status == models.OffboardingScheduled {
+		result := w.DB.Model(&models.OrganizationOffboarding{}).
+			Where("id = ? AND status = ?", ob.ID, models.OffboardingScheduled).
+			Update("status", models.OffboardingPurging)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return
+		}
+		_ = offboarding.RecordEvent(w.DB, ob.ID, "purge_started", "")
+	}
+
+	if ob.ArchivePath != "" {
+		if err := os.Remove(filepath.Join(w.mediaRoot(), ob.ArchivePath)); err != nil && !os.IsNotExist(err) {
+			w.failOffboardingStep(ob, "Failed to delete the data archive: "+err.Error())
+			return
+		}
+	}
+
+	// BI service account roles live outside the organization's rows, so drop them first
+	var roles []string
+	w.DB.Model(&models.BIServiceAccount{}).Where("organization_id = ?", ob.OrganizationID).Pluck("role_name", &roles)
+	for _, role := range roles {
+		if err := database.DropReportingRole(w.DB, role); err != nil {
+			w.failOffboardingStep(ob, "Failed to drop BI service account "+role+": "+err.Error())
+			return
+		}
+	}
+
+	result, err := offboarding.Purge(w.DB, ob.OrganizationID, w.mediaRoot())
+	purged := ob.PurgedCounts
+	if purged == nil {
+		purged = models.JSONB{}
+	}
+	var media int
+	if result != nil {
+		for name, count := range result.Purged {
+			purged[name] = jsonCount(purged[name]) + jsonCount(count)
+		}
+		media = result.MediaDeleted
+	}
+	if err != nil {
+		w.DB.Model(ob).Update("purged_counts", purged)
+		w.failOffboardingStep(ob, "Failed to purge data: "+err.Error())
+		return
+	}
+
+	now := time.Now()
+	if err := w.DB.Model(ob).Updates(map[string]interface{}{
+		"status":          models.OffboardingCompleted,
+		"completed_at":    now,
+		"purged_counts":   purged,
+		"retained_counts": result.Retained,
+		"archive_path":    "",
+		"last_error":      "",
+	}).Error; err != nil {
+		w.Log.Error("Failed to complete offboarding", "error", err, "offboarding_id", ob.ID)
+		return
+	}
+	w.Cache.InvalidateTenant(ctx, ob.OrganizationID)
+
+	var rows, retained int64
+	for _, count := range purged {
+		rows += jsonCount(count)
+	}
+	for _, count := range result.Retained {
+		retained += jsonCount(count)
+	}
+	detail := fmt.Sprintf("%d rows deleted from %d tables and %d media files deleted", rows, len(purged), media)
+	if retained > 0 {
+		detail += fmt.Sprintf("; %d write-once audit records retained", retained)
+	}
+	_ = offboarding.RecordEvent(w.DB, ob.ID, "purged", detail)
+	w.Log.Info("Organization purged", "organization_id", ob.OrganizationID, "offboarding_id", ob.ID, "rows", rows)
+
+	w.emailOffboarding(ob, fmt.Sprintf("%s has been deleted", ob.OrganizationName),
+		fmt.Sprintf("The data of %s was permanently deleted on %s: %s.\n\nReference: %s\n",
+			ob.OrganizationName, now.UTC().Format("2006-01-02"), detail, ob.ID))
+}
+
+// failOffboardingStep records why a step failed; the step is retried on the next poll
+func (w *Worker) failOffboardingStep(ob *models.OrganizationOffboarding, errMsg string) {
+	w.Log.Error("Offboarding step failed", "error", errMsg, "offboarding_id", ob.ID)
+	w.DB.Model(&models.OrganizationOffboarding{}).Where("id = ?", ob.ID).Update("last_error", errMsg)
+}
+
+// emailOffboarding sends an offboarding notice to the admin who requested it
+func (w *Worker) emailOffboarding(ob *models.OrganizationOffboarding, subject, body string) {
+	if !w.Mailer.Enabled() || ob.RequestedByEmail == "" {
+		return
+	}
+	if err := w.Mailer.Send([]string{ob.RequestedByEmail}, subject, body); err != nil {
+		w.Log.Error("Failed to email offboarding notice", "error", err, "offboarding_id", ob.ID)
+	}
+}
+
+// mediaRoot is the media storage directory shared with the server
+func (w *Worker) mediaRoot() string {
+	if w.Config.Storage.LocalPath == "" {
+		return "./media"
+	}
+	return w.Config.Storage.LocalPath
+}
+
+// jsonCount reads a row count from a JSONB document, where it may have been decoded
+// as a float
+func jsonCount(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case int:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// runPool calls fn for each of n items on at most size goroutines at a time. The
+// first error stops new items from starting; items already running are waited for
+// and the error is returned.
+func runPool(ctx context.Context, size, n int, fn func(i int) error) error {
+	if size < 1 {
+		size = 1
+	}
+	if size > n {
+		size = n
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	poolCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	items := make(chan int)
+	for range size {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				if err := fn(i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case items <- i:
+		case <-poolCtx.Done():
+			break feed
+		}
+	}
+	close(items)
+	wg.Wait()
+
+	if firstErr == nil {
+		// Cancelled by the caller rather than by an item
+		return ctx.Err()
+	}
+	return firstErr
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/models"
+)
+
+// rateLimitKeyPrefix prefixes the token bucket each WhatsApp account's campaign
+// sends draw from, shared by all workers
+const rateLimitKeyPrefix = "worker:rate_limit:"
+
+// campaignRateLimitKeyPrefix prefixes the token bucket of each campaign with its own
+// messages-per-minute limit
+const campaignRateLimitKeyPrefix = "worker:campaign_rate_limit:"
+
+// tokenBucket takes a token from the bucket at KEYS[1], refilled at ARGV[1] tokens a
+// second up to ARGV[2], using the Redis clock so all workers agree. It returns 0
+// when a token was taken, or how many milliseconds until one will be available.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+// messagesPerSecond returns the account's campaign throughput, or the worker's
+// when the account doesn't set one
+func (w *Worker) messagesPerSecond(account *models.WhatsAppAccount) int {
+	rate := w.Config.Worker.MessagesPerSecond
+	if account.MessagesPerSecond > 0 {
+		rate = account.MessagesPerSecond
+	}
+	return max(1, min(rate, models.MaxMessagesPerSecond))
+}
+
+// throttle waits until the campaign and its account may send another message.
+// The campaign's own limit is waited on first, so a slowed campaign doesn't hold
+// the account's tokens while other campaigns on it run at full speed.
+func (w *Worker) throttle(ctx context.Context, run *campaignRun) error {
+	if perMinute := run.campaign.MaxMessagesPerMinute; perMinute > 0 {
+		// A burst of one spaces the campaign's messages evenly
+		key := campaignRateLimitKeyPrefix + run.campaign.ID.String()
+		if err := w.takeToken(ctx, key, float64(perMinute)/60, 1, run.account.Name); err != nil {
+			return err
+		}
+	}
+
+	// Up to a second's worth of messages can be sent at once after a pause
+	rate := float64(w.messagesPerSecond(run.account))
+	return w.takeToken(ctx, rateLimitKeyPrefix+run.account.ID.String(), rate, rate, run.account.Name)
+}
+
+// takeToken waits for a token from the bucket at key, refilled at rate tokens a
+// second up to burst
+func (w *Worker) takeToken(ctx context.Context, key string, rate, burst float64, accountName string) error {
+	for {
+		wait, err := tokenBucket.Run(ctx, w.Redis, []string{key}, rate, burst).Int64()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// Keep sending while Redis is unavailable, pacing this worker on its own
+			w.Log.Error("Failed to take rate limit token, pacing locally", "error", err, "account", accountName, "key", key)
+			return sleepCtx(ctx, time.Duration(float64(time.Second)/rate))
+		}
+		if wait == 0 {
+			return nil
+		}
+		if err := sleepCtx(ctx, time.Duration(wait)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepCtx sleeps for d, returning early with ctx's error if it is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,275 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/cron"
+	"github.com/shridarpatil/whatomate/internal/engagement"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+	"gorm.io/gorm"
+)
+
+const (
+	// recurringPollInterval is how often the worker looks for recurring campaigns that are due
+	recurringPollInterval = 30 * time.Second
+
+	// recurringRecipientBatch is how many recipients are inserted per statement
+	recurringRecipientBatch = 500
+)
+
+// recurringUnfinishedStatuses are the statuses of an occurrence that hasn't finished
+// sending, which holds back the next occurrence
+var recurringUnfinishedStatuses = []string{"scheduled", "pending_template", "queued", "processing", "held", "paused"}
+
+// contactPlaceholder matches {{name}}-style placeholders in recurring campaign parameters
+var contactPlaceholder = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*\}\}`)
+
+// runRecurringCampaigns creates the occurrences of recurring campaigns as they come
+// due, until ctx is cancelled
+func (w *Worker) runRecurringCampaigns(ctx context.Context) {
+	ticker := time.NewTicker(recurringPollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.scheduleRecurringCampaigns(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleRecurringCampaigns runs every enabled recurring campaign that is due.
+// Occurrences missed while no worker was running are not made up.
+func (w *Worker) scheduleRecurringCampaigns(ctx context.Context) {
+	now := time.Now()
+
+	var due []models.RecurringCampaign
+	if err := w.DB.Where("is_enabled = true AND next_run_at <= ?", now).Find(&due).Error; err != nil {
+		w.Log.Error("Failed to load recurring campaigns", "error", err)
+		return
+	}
+
+	for i := range due {
+		recurring := &due[i]
+		local := now.In(w.orgLocation(ctx, recurring.OrganizationID))
+
+		var next *time.Time
+		schedule, err := cron.Parse(recurring.Schedule)
+		if err == nil {
+			if t := schedule.Next(local); !t.IsZero() {
+				next = &t
+			} else {
+				err = errors.New("it never fires")
+			}
+		}
+
+		// Advance next_run_at first; only the worker that wins the update runs it. A
+		// schedule with no next run disables the recurrence rather than leaving it due.
+		advance := map[string]interface{}{
+			"next_run_at": next,
+			"last_run_at": now,
+		}
+		if next == nil {
+			advance["is_enabled"] = false
+		}
+		result := w.DB.Model(&models.RecurringCampaign{}).
+			Where("id = ? AND next_run_at = ?", recurring.ID, recurring.NextRunAt).
+			Updates(advance)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		status, message, campaignID := models.RecurringRunFailed, "", (*uuid.UUID)(nil)
+		if err != nil {
+			message = fmt.Sprintf("invalid schedule: %v", err)
+		} else {
+			status, message, campaignID = w.runRecurringCampaign(ctx, recurring, local)
+		}
+		if status == models.RecurringRunFailed {
+			w.Log.Error("Recurring campaign run failed", "recurring_campaign_id", recurring.ID, "error", message)
+		}
+
+		updates := map[string]interface{}{
+			"last_run_status":  status,
+			"last_run_message": message,
+		}
+		if campaignID != nil {
+			updates["last_campaign_id"] = *campaignID
+		}
+		w.DB.Model(&models.RecurringCampaign{}).Where("id = ?", recurring.ID).Updates(updates)
+	}
+}
+
+// runRecurringCampaign creates and queues one occurrence of a recurring campaign. It
+// returns the run's outcome, why it was skipped or failed, and the campaign created.
+func (w *Worker) runRecurringCampaign(ctx context.Context, recurring *models.RecurringCampaign, now time.Time) (string, string, *uuid.UUID) {
+	var unfinished int64
+	if err := w.DB.Model(&models.BulkMessageCampaign{}).
+		Where("recurring_campaign_id = ? AND status IN ?", recurring.ID, recurringUnfinishedStatuses).
+		Count(&unfinished).Error; err != nil {
+		return models.RecurringRunFailed, "failed to check the previous campaign", nil
+	}
+	if unfinished > 0 {
+		w.Log.Info("Skipping recurring campaign, previous run still sending", "recurring_campaign_id", recurring.ID)
+		return models.RecurringRunSkipped, "the previous campaign was still sending", nil
+	}
+
+	if blackout := w.campaignBlackout(ctx, recurring.OrganizationID); blackout != nil {
+		w.Log.Info("Skipping recurring campaign on blackout date", "recurring_campaign_id", recurring.ID, "blackout", blackout.Name)
+		return models.RecurringRunSkipped, fmt.Sprintf("blackout date: %s", blackout.Name), nil
+	}
+
+	template, err := w.Cache.Template(ctx, recurring.OrganizationID, recurring.TemplateID)
+	if err != nil {
+		return models.RecurringRunFailed, "template not found", nil
+	}
+	if template.Status != "APPROVED" {
+		return models.RecurringRunFailed, fmt.Sprintf("template %s is not approved", template.Name), nil
+	}
+
+	contacts, err := w.recurringCampaignContacts(recurring)
+	if err != nil {
+		return models.RecurringRunFailed, err.Error(), nil
+	}
+	if len(contacts) == 0 {
+		return models.RecurringRunEmpty, "", nil
+	}
+
+	recipients := make([]models.BulkMessageRecipient, len(contacts))
+	for i := range contacts {
+		recipients[i] = models.BulkMessageRecipient{
+			PhoneNumber:    contacts[i].PhoneNumber,
+			RecipientName:  contacts[i].ProfileName,
+			TemplateParams: contactTemplateParams(&contacts[i], recurring.TemplateParams),
+		}
+	}
+
+	startedAt := time.Now()
+	campaign := models.BulkMessageCampaign{
+		OrganizationID:      recurring.OrganizationID,
+		WhatsAppAccount:     recurring.WhatsAppAccount,
+		Name:                fmt.Sprintf("%s (%s)", recurring.Name, now.Format("2006-01-02")),
+		TemplateID:          recurring.TemplateID,
+		Status:              "queued",
+		TotalRecipients:     len(recipients),
+		StartedAt:           &startedAt,
+		CreatedBy:           recurring.CreatedBy,
+		RecurringCampaignID: &recurring.ID,
+	}
+	err = w.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		for i := range recipients {
+			recipients[i].CampaignID = campaign.ID
+		}
+		return tx.CreateInBatches(&recipients, recurringRecipientBatch).Error
+	})
+	if err != nil {
+		w.Log.Error("Failed to create recurring campaign occurrence", "error", err, "recurring_campaign_id", recurring.ID)
+		return models.RecurringRunFailed, "failed to create campaign", nil
+	}
+
+	w.Log.Info("Recurring campaign created", "recurring_campaign_id", recurring.ID, "campaign_id", campaign.ID, "recipients", len(recipients))
+	w.publishCampaignEvent(ctx, &campaign, "queued", "")
+
+	if err := w.Queue.EnqueueCampaign(ctx, campaign.ID, queue.Priority(campaign.Priority)); err != nil {
+		// Fail the campaign so it doesn't hold back the next occurrence
+		w.DB.Model(&campaign).Update("status", "failed")
+		w.publishCampaignEvent(ctx, &campaign, "failed", "Failed to enqueue campaign")
+		return models.RecurringRunFailed, "failed to enqueue campaign", &campaign.ID
+	}
+	return models.RecurringRunCreated, "", &campaign.ID
+}
+
+// recurringCampaignContacts returns the contacts in the recurring campaign's segment,
+// excluding contacts who opted out
+func (w *Worker) recurringCampaignContacts(recurring *models.RecurringCampaign) ([]models.Contact, error) {
+	query := w.DB.Where("organization_id = ? AND whats_app_account = ?", recurring.OrganizationID, recurring.WhatsAppAccount).
+		Where(engagement.NotOptedOut, engagement.OptOutKeywords)
+
+	if recurring.MinEngagement > 0 {
+		query = query.Where("engagement_score >= ?", recurring.MinEngagement)
+	}
+	if recurring.LabelID != nil {
+		query = query.Where("EXISTS (SELECT 1 FROM conversation_labels cl WHERE cl.contact_id = contacts.id AND cl.label_id = ? AND cl.deleted_at IS NULL)", *recurring.LabelID)
+	}
+	if recurring.Tag != "" {
+		tag, _ := json.Marshal([]string{recurring.Tag})
+		query = query.Where("tags @> ?::jsonb", string(tag))
+	}
+
+	var contacts []models.Contact
+	if err := query.Find(&contacts).Error; err != nil {
+		return nil, fmt.Errorf("failed to find segment contacts: %w", err)
+	}
+	return contacts, nil
+}
+
+// contactTemplateParams fills placeholders such as {{name}} or a metadata key in each
+// template parameter with the contact's values. Unknown placeholders become empty.
+func contactTemplateParams(contact *models.Contact, templateParams models.JSONB) models.JSONB {
+	data := map[string]interface{}{
+		"name":         contact.ProfileName,
+		"profile_name": contact.ProfileName,
+		"phone_number": contact.PhoneNumber,
+	}
+	for k, v := range contact.Metadata {
+		if _, exists := data[k]; !exists {
+			data[k] = v
+		}
+	}
+
+	params := models.JSONB{}
+	for key, value := range templateParams {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		params[key] = contactPlaceholder.ReplaceAllStringFunc(s, func(match string) string {
+			v, ok := data[contactPlaceholder.FindStringSubmatch(match)[1]]
+			if !ok || v == nil {
+				return ""
+			}
+			return fmt.Sprint(v)
+		})
+	}
+	return params
+}
+
+// orgSettings returns the organization's settings, or none if they can't be loaded
+func (w *Worker) orgSettings(ctx context.Context, orgID uuid.UUID) models.JSONB {
+	settings, err := w.Cache.OrgSettings(ctx, orgID)
+	if err != nil {
+		w.Log.Warn("Failed to load organization settings", "error", err, "organization_id", orgID)
+		return models.JSONB{}
+	}
+	return settings
+}
+
+// orgLocation returns the timezone configured in the organization's settings, or UTC
+func (w *Worker) orgLocation(ctx context.Context, orgID uuid.UUID) *time.Location {
+	settings, err := w.Cache.OrgSettings(ctx, orgID)
+	if err != nil {
+		return time.UTC
+	}
+	timezone, _ := settings["timezone"].(string)
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
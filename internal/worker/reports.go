@@ -0,0 +1,340 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/jobs"
+	"github.com/shridarpatil/whatomate/internal/mailer"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/notify"
+	"github.com/shridarpatil/whatomate/internal/reports"
+)
+
+const (
+	// reportPollInterval is how often the worker looks for queued and scheduled report runs
+	reportPollInterval = 30 * time.Second
+
+	// rollupInterval is how often today's and yesterday's rollups are rebuilt
+	rollupInterval = 10 * time.Minute
+
+	// rollupBackfillDays is how much history is rolled up the first time reports run
+	rollupBackfillDays = 90
+
+	// reportRunTimeout requeues runs left in "running" by a worker that died
+	reportRunTimeout = 30 * time.Minute
+
+	// rollupRebuildsPerPass bounds the organizations whose rollups are rebuilt in one
+	// pass after their timezone changed; the rest are rebuilt in the next passes
+	rollupRebuildsPerPass = 10
+
+	rollupLockKey       = "worker:report_rollup_lock"
+	rollupBackfilledKey = "worker:report_rollup_backfilled"
+	rollupZonesKey      = "worker:report_rollup_zones" // Organization ID -> timezone its rollups are in; UTC if missing
+)
+
+// runReportJobs keeps rollups fresh and executes report runs until ctx is cancelled
+func (w *Worker) runReportJobs(ctx context.Context) {
+	ticker := time.NewTicker(reportPollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.refreshRollups(ctx)
+		w.scheduleReports(ctx)
+		w.processReportRuns(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshRollups rebuilds recent rollups, at most once per rollupInterval across all
+// workers. Each organization's days are in its timezone; the rollups of one whose
+// timezone changed are rebuilt in the new one.
+func (w *Worker) refreshRollups(ctx context.Context) {
+	acquired, err := w.Redis.SetNX(ctx, rollupLockKey, 1, rollupInterval).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	var orgs []models.Organization
+	if err := w.DB.Select("id", "settings").Find(&orgs).Error; err != nil {
+		w.Log.Error("Failed to load organizations for rollups", "error", err)
+		return
+	}
+	zones, err := w.Redis.HGetAll(ctx, rollupZonesKey).Result()
+	if err != nil {
+		w.Log.Error("Failed to load rollup timezones", "error", err)
+		return
+	}
+
+	days := 2
+	backfilled, _ := w.Redis.Exists(ctx, rollupBackfilledKey).Result()
+	if backfilled == 0 {
+		days = rollupBackfillDays
+	}
+
+	// Organizations in the same timezone are rolled up together
+	locations := make(map[string]*time.Location)
+	byZone := make(map[string][]uuid.UUID)
+	var moved []models.Organization
+	for i := range orgs {
+		loc := reports.OrgCalendar(orgs[i].Settings).Location
+		zone := loc.String()
+		locations[zone] = loc
+		byZone[zone] = append(byZone[zone], orgs[i].ID)
+
+		built := zones[orgs[i].ID.String()]
+		if built == "" {
+			built = time.UTC.String()
+		}
+		if backfilled != 0 && built != zone {
+			moved = append(moved, orgs[i])
+		}
+	}
+
+	for zone, ids := range byZone {
+		if err := w.rollupDays(ctx, locations[zone], ids, days); err != nil {
+			w.Log.Error("Failed to roll up messages", "error", err, "timezone", zone)
+			return
+		}
+	}
+
+	if backfilled == 0 {
+		for zone, ids := range byZone {
+			for _, id := range ids {
+				w.Redis.HSet(ctx, rollupZonesKey, id.String(), zone)
+			}
+		}
+		w.Redis.Set(ctx, rollupBackfilledKey, 1, 0)
+		w.Log.Info("Backfilled message rollups", "days", days)
+		return
+	}
+
+	for i := range moved {
+		if i == rollupRebuildsPerPass || ctx.Err() != nil {
+			return
+		}
+		w.rebuildRollups(ctx, &moved[i])
+	}
+}
+
+// rebuildRollups rebuilds all of an organization's rollups, or the last
+// rollupBackfillDays if it has fewer, in the timezone it now has
+func (w *Worker) rebuildRollups(ctx context.Context, org *models.Organization) {
+	loc := reports.OrgCalendar(org.Settings).Location
+
+	// Go back a day further, as moving east puts the earliest messages on the day before
+	days := rollupBackfillDays
+	var earliest sql.NullTime
+	if err := w.DB.Model(&models.MessageDailyRollup{}).Where("organization_id = ?", org.ID).
+		Select("MIN(date)").Row().Scan(&earliest); err == nil && earliest.Valid {
+		days = max(days, int(time.Since(earliest.Time).Hours()/24)+2)
+	}
+
+	if err := w.rollupDays(ctx, loc, []uuid.UUID{org.ID}, days); err != nil {
+		w.Log.Error("Failed to rebuild rollups", "error", err, "organization_id", org.ID, "timezone", loc.String())
+		return
+	}
+	w.Redis.HSet(ctx, rollupZonesKey, org.ID.String(), loc.String())
+	w.Log.Info("Rebuilt message rollups for a new timezone", "organization_id", org.ID, "timezone", loc.String(), "days", days)
+}
+
+// rollupDays rolls up the organizations' messages for today and the days before it,
+// days in all, in their timezone loc
+func (w *Worker) rollupDays(ctx context.Context, loc *time.Location, orgIDs []uuid.UUID, days int) error {
+	today := time.Now().In(loc)
+	for i := 0; i < days; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		day := today.AddDate(0, 0, -i)
+		if err := reports.Rollup(w.DB, day, loc, orgIDs); err != nil {
+			return fmt.Errorf("failed to roll up %s: %w", day.Format("2006-01-02"), err)
+		}
+	}
+	return nil
+}
+
+// scheduleReports queues a run for every scheduled report that is due
+func (w *Worker) scheduleReports(ctx context.Context) {
+	now := time.Now()
+
+	var due []models.Report
+	if err := w.DB.Where("schedule <> '' AND next_run_at <= ?", now).Find(&due).Error; err != nil {
+		w.Log.Error("Failed to load scheduled reports", "error", err)
+		return
+	}
+
+	for _, report := range due {
+		cal := reports.OrgCalendar(w.orgSettings(ctx, report.OrganizationID))
+
+		// Advance next_run_at first; only the worker that wins the update queues the run
+		result := w.DB.Model(&models.Report{}).
+			Where("id = ? AND next_run_at = ?", report.ID, report.NextRunAt).
+			Updates(map[string]interface{}{
+				"next_run_at": reports.NextRun(report.Schedule, cal, now),
+				"last_run_at": now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		from, to := reports.SchedulePeriod(report.Schedule, cal, *report.NextRunAt, report.LastRunAt)
+		run := models.ReportRun{
+			OrganizationID: report.OrganizationID,
+			ReportID:       report.ID,
+			Trigger:        "schedule",
+			Status:         "queued",
+			PeriodStart:    from,
+			PeriodEnd:      to,
+		}
+		if err := w.DB.Create(&run).Error; err != nil {
+			w.Log.Error("Failed to queue scheduled report", "error", err, "report_id", report.ID)
+			continue
+		}
+		if job, err := jobs.Create(w.DB, report.OrganizationID, models.JobTypeReport, &run.ID, nil); err == nil {
+			w.DB.Model(&run).Update("job_id", job.ID)
+		} else {
+			w.Log.Error("Failed to record report run job", "error", err, "run_id", run.ID)
+		}
+	}
+}
+
+// processReportRuns executes queued runs one at a time
+func (w *Worker) processReportRuns(ctx context.Context) {
+	w.DB.Model(&models.ReportRun{}).
+		Where("status = ? AND started_at < ?", "running", time.Now().Add(-reportRunTimeout)).
+		Update("status", "queued")
+
+	for ctx.Err() == nil {
+		var run models.ReportRun
+		err := w.DB.Raw(`
+			UPDATE report_runs SET status = 'running', started_at = NOW(), updated_at = NOW()
+			WHERE id = (
+				SELECT id FROM report_runs
+				WHERE status = 'queued' AND deleted_at IS NULL
+				ORDER BY created_at
+				LIMIT 1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`).Scan(&run).Error
+		if err != nil {
+			w.Log.Error("Failed to claim report run", "error", err)
+			return
+		}
+		if run.ID == uuid.Nil {
+			return
+		}
+
+		w.executeReportRun(ctx, &run)
+	}
+}
+
+// executeReportRun runs a claimed report, stores the result and emails it for scheduled runs
+func (w *Worker) executeReportRun(ctx context.Context, run *models.ReportRun) {
+	var tracker *jobs.Tracker
+	if run.JobID != nil {
+		tracker = jobs.Open(w.DB, w.Publisher, *run.JobID)
+	}
+	tracker.Start(0)
+
+	var report models.Report
+	if err := w.DB.Where("id = ? AND organization_id = ?", run.ReportID, run.OrganizationID).First(&report).Error; err != nil {
+		w.failReportRun(run, tracker, "Report not found")
+		return
+	}
+
+	cal := reports.OrgCalendar(w.orgSettings(ctx, run.OrganizationID))
+	result, err := reports.Execute(w.DB, &report, cal, run.PeriodStart, run.PeriodEnd)
+	if err != nil {
+		w.Log.Error("Report run failed", "error", err, "report_id", report.ID, "run_id", run.ID)
+		w.failReportRun(run, tracker, err.Error())
+		return
+	}
+
+	now := time.Now()
+	run.Status = "completed"
+	run.Columns = result.Columns
+	run.Rows = result.ToJSONBArray()
+	run.RowCount = len(result.Rows)
+	run.CompletedAt = &now
+	if err := w.DB.Model(run).Updates(map[string]interface{}{
+		"status":       run.Status,
+		"columns":      run.Columns,
+		"rows":         run.Rows,
+		"row_count":    run.RowCount,
+		"error":        "",
+		"completed_at": now,
+	}).Error; err != nil {
+		w.Log.Error("Failed to save report run", "error", err, "run_id", run.ID)
+		tracker.Fail("Failed to save report run")
+		return
+	}
+	tracker.Complete(fmt.Sprintf("/api/reports/%s/runs/%s/csv", report.ID, run.ID), models.JSONB{"row_count": run.RowCount})
+
+	w.Log.Info("Report run completed", "report_id", report.ID, "run_id", run.ID, "rows", run.RowCount)
+
+	if run.Trigger == "schedule" && len(report.Recipients) > 0 {
+		w.emailReportRun(&report, run)
+	}
+}
+
+// emailReportRun sends a completed run as a CSV or PDF attachment to the report's recipients
+func (w *Worker) emailReportRun(report *models.Report, run *models.ReportRun) {
+	if !w.Mailer.Enabled() {
+		w.Log.Warn("SMTP not configured, skipping report email", "report_id", report.ID)
+		return
+	}
+
+	period := run.PeriodStart.Format("2006-01-02")
+	if !run.PeriodEnd.Equal(run.PeriodStart) {
+		period += " to " + run.PeriodEnd.Format("2006-01-02")
+	}
+
+	data, contentType, err := reports.Render(report.Format, []string{report.Name, period}, run.Columns, run.Rows)
+	if err != nil {
+		w.Log.Error("Failed to render report", "error", err, "run_id", run.ID, "format", report.Format)
+		return
+	}
+
+	msg, err := notify.Email(w.DB, report.OrganizationID, notify.KeyReportReady, map[string]any{
+		"report_name": report.Name,
+		"period":      period,
+		"row_count":   run.RowCount,
+		"truncated":   run.RowCount >= reports.MaxRows,
+		"max_rows":    reports.MaxRows,
+	})
+	if err != nil {
+		w.Log.Error("Failed to render report email", "error", err, "report_id", report.ID)
+		return
+	}
+	msg.To = report.Recipients
+	msg.Attachments = []mailer.Attachment{{
+		Filename:    reports.Filename(report.Name, run.PeriodEnd, report.Format),
+		ContentType: contentType,
+		Data:        data,
+	}}
+	if err := w.Mailer.SendMessage(msg); err != nil {
+		w.Log.Error("Failed to email report", "error", err, "report_id", report.ID, "run_id", run.ID)
+		w.DB.Model(run).Update("error", "Email delivery failed: "+err.Error())
+		return
+	}
+
+	w.DB.Model(run).Update("emailed_at", time.Now())
+}
+
+func (w *Worker) failReportRun(run *models.ReportRun, tracker *jobs.Tracker, errMsg string) {
+	w.DB.Model(run).Updates(map[string]interface{}{
+		"status":       "failed",
+		"error":        errMsg,
+		"completed_at": time.Now(),
+	})
+	tracker.Fail(errMsg)
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/queue"
+)
+
+// deferredPollInterval is how often the worker looks for campaigns whose recipients'
+// send windows have opened
+const deferredPollInterval = 30 * time.Second
+
+// recipientReady matches pending recipients that aren't waiting for their send window
+const recipientReady = "(deferred_until IS NULL OR deferred_until <= ?)"
+
+// runDeferredCampaigns queues campaigns again as their waiting recipients' send windows
+// open or claims expire, until ctx is cancelled
+func (w *Worker) runDeferredCampaigns(ctx context.Context) {
+	ticker := time.NewTicker(deferredPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.resumeDeferredCampaigns(ctx)
+		}
+	}
+}
+
+// resumeDeferredCampaigns queues every processing or held campaign whose resume time
+// has come
+func (w *Worker) resumeDeferredCampaigns(ctx context.Context) {
+	var due []models.BulkMessageCampaign
+	if err := w.DB.Select("id", "resume_at", "priority").
+		Where("status IN ? AND resume_at <= ?", []string{"processing", "held"}, time.Now()).
+		Find(&due).Error; err != nil {
+		w.Log.Error("Failed to load deferred campaigns", "error", err)
+		return
+	}
+
+	for _, campaign := range due {
+		// Clear resume_at first; only the worker that wins the update queues the campaign
+		result := w.DB.Model(&models.BulkMessageCampaign{}).
+			Where("id = ? AND resume_at = ?", campaign.ID, campaign.ResumeAt).
+			Update("resume_at", nil)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := w.Queue.EnqueueCampaign(ctx, campaign.ID, queue.Priority(campaign.Priority)); err != nil {
+			w.Log.Error("Failed to enqueue deferred campaign", "error", err, "campaign_id", campaign.ID)
+			w.DB.Model(&models.BulkMessageCampaign{}).Where("id = ?", campaign.ID).Update("resume_at", campaign.ResumeAt)
+			continue
+		}
+		w.Log.Info("Resuming campaign for waiting recipients", "campaign_id", campaign.ID)
+	}
+}
+
+// nextDeferredRecipient returns when the campaign's first recipient waiting for their
+// send window can be sent to, or nil if none are waiting
+func (w *Worker) nextDeferredRecipient(campaignID uuid.UUID) *time.Time {
+	var next sql.NullTime
+	err := w.DB.Model(&models.BulkMessageRecipient{}).
+		Where("campaign_id = ? AND status = ? AND deferred_until > ?", campaignID, "pending", time.Now()).
+		Select("MIN(deferred_until)").
+		Row().Scan(&next)
+	if err != nil || !next.Valid {
+		return nil
+	}
+	return &next.Time
+}
+
+// earliest returns the earlier of two optional times
+func earliest(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
+
+// location returns the timezone a recipient whose contact has the named one is sent
+// in, falling back to the organization's
+func (run *campaignRun) location(name string) *time.Location {
+	if name == "" {
+		return run.orgLocation
+	}
+
+	run.locMu.Lock()
+	defer run.locMu.Unlock()
+	if loc, ok := run.locations[name]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = run.orgLocation
+	}
+	if run.locations == nil {
+		run.locations = make(map[string]*time.Location)
+	}
+	run.locations[name] = loc
+	return loc
+}
@@ -2,18 +2,42 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/shridarpatil/whatomate/internal/apilog"
+	"github.com/shridarpatil/whatomate/internal/cache"
 	"github.com/shridarpatil/whatomate/internal/config"
+	"github.com/shridarpatil/whatomate/internal/egress"
+	"github.com/shridarpatil/whatomate/internal/mailer"
 	"github.com/shridarpatil/whatomate/internal/models"
+	"github.com/shridarpatil/whatomate/internal/offboarding"
 	"github.com/shridarpatil/whatomate/internal/queue"
+	"github.com/shridarpatil/whatomate/pkg/phonenumber"
 	"github.com/shridarpatil/whatomate/pkg/whatsapp"
 	"github.com/zerodha/logf"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// recipientBatchSize is the number of recipients loaded into memory at a time
+const recipientBatchSize = 500
+
+const (
+	// throughputWindow is how long recipient outcomes are counted for before the
+	// campaign's rate is updated with them
+	throughputWindow = 5 * time.Second
+
+	// throughputSmoothing is the weight a window's rate gets in the campaign's rate,
+	// so bursts and pauses shift the estimate gradually
+	throughputSmoothing = 0.3
 )
 
 // Worker processes jobs from the queue
@@ -23,8 +47,13 @@ type Worker struct {
 	Redis     *redis.Client
 	Log       logf.Logger
 	WhatsApp  *whatsapp.Client
+	Queue     *queue.RedisQueue
 	Consumer  *queue.RedisConsumer
 	Publisher *queue.Publisher
+	Cache     *cache.TenantCache
+	Mailer    *mailer.Mailer
+
+	runs sync.Map // *campaignRun -> struct{}, the runs sending campaigns, for pause and cancel signals
 }
 
 // New creates a new Worker instance
@@ -35,15 +64,29 @@ func New(cfg *config.Config, db *gorm.DB, rdb *redis.Client, log logf.Logger) (*
 	}
 
 	publisher := queue.NewPublisher(rdb, log)
+	publisher.SetStatsCoalescing(time.Duration(cfg.Worker.StatsIntervalMs)*time.Millisecond, cfg.Worker.StatsBatchSize)
+
+	waClient := whatsapp.New(log)
+	if cfg.WhatsApp.EgressProxy != "" {
+		if err := waClient.SetProxy(cfg.WhatsApp.EgressProxy); err != nil {
+			return nil, fmt.Errorf("invalid WhatsApp egress proxy: %w", err)
+		}
+	}
+	waClient.ProxyResolver = egress.NewResolver(db).Proxy
+	waClient.SendGuard = offboarding.NewGuard(db).Check
+	waClient.RequestLogger = apilog.NewRecorder(db, log)
 
 	return &Worker{
 		Config:    cfg,
 		DB:        db,
 		Redis:     rdb,
 		Log:       log,
-		WhatsApp:  whatsapp.New(log),
+		WhatsApp:  waClient,
+		Queue:     queue.NewRedisQueue(rdb, log),
 		Consumer:  consumer,
 		Publisher: publisher,
+		Cache:     cache.New(rdb, db, log),
+		Mailer:    mailer.New(cfg.SMTP),
 	}, nil
 }
 
@@ -51,6 +94,14 @@ func New(cfg *config.Config, db *gorm.DB, rdb *redis.Client, log logf.Logger) (*
 func (w *Worker) Run(ctx context.Context) error {
 	w.Log.Info("Worker starting")
 
+	go w.runHolidayRefresh(ctx)
+	go w.runReportJobs(ctx)
+	go w.runEngagementScoring(ctx)
+	go w.runOffboarding(ctx)
+	go w.runRecurringCampaigns(ctx)
+	go w.runDeferredCampaigns(ctx)
+	go w.runCampaignControl(ctx)
+
 	err := w.Consumer.Consume(ctx, w.handleCampaignJob)
 	if err != nil && ctx.Err() == nil {
 		return fmt.Errorf("consumer error: %w", err)
@@ -64,7 +115,7 @@ func (w *Worker) Run(ctx context.Context) error {
 func (w *Worker) handleCampaignJob(ctx context.Context, job *queue.CampaignJob) error {
 	w.Log.Info("Processing campaign job", "campaign_id", job.CampaignID)
 
-	if err := w.processCampaign(ctx, job.CampaignID); err != nil {
+	if err := w.processCampaign(ctx, job); err != nil {
 		w.Log.Error("Failed to process campaign", "error", err, "campaign_id", job.CampaignID)
 		return err
 	}
@@ -73,184 +124,806 @@ func (w *Worker) handleCampaignJob(ctx context.Context, job *queue.CampaignJob)
 	return nil
 }
 
-// processCampaign processes a campaign by sending messages to all recipients
-func (w *Worker) processCampaign(ctx context.Context, campaignID uuid.UUID) error {
-	w.Log.Info("Processing campaign", "campaign_id", campaignID)
+// processCampaign processes a campaign by sending messages to all recipients. Helper
+// jobs send alongside the run that started the campaign, on other workers, and dry run
+// jobs send nothing; see dryRunCampaign.
+func (w *Worker) processCampaign(ctx context.Context, job *queue.CampaignJob) error {
+	if job.DryRunID != nil {
+		return w.dryRunCampaign(ctx, job.CampaignID, *job.DryRunID)
+	}
+
+	campaignID := job.CampaignID
+	w.Log.Info("Processing campaign", "campaign_id", campaignID, "helper", job.Helper)
 
-	// Get campaign with template
+	// Get campaign
 	var campaign models.BulkMessageCampaign
-	if err := w.DB.Where("id = ?", campaignID).Preload("Template").First(&campaign).Error; err != nil {
+	if err := w.DB.Where("id = ?", campaignID).First(&campaign).Error; err != nil {
 		w.Log.Error("Failed to load campaign for processing", "error", err, "campaign_id", campaignID)
 		return fmt.Errorf("failed to load campaign: %w", err)
 	}
 
 	// Check if campaign is still in a startable state
-	if campaign.Status != "queued" && campaign.Status != "processing" {
+	if campaign.Status != "queued" && campaign.Status != "processing" && campaign.Status != "held" {
 		w.Log.Info("Campaign not in processable state", "campaign_id", campaignID, "status", campaign.Status)
 		return nil // Not an error, just skip
 	}
 
-	// Get WhatsApp account
-	var account models.WhatsAppAccount
-	if err := w.DB.Where("name = ? AND organization_id = ?", campaign.WhatsAppAccount, campaign.OrganizationID).First(&account).Error; err != nil {
+	// Get template and WhatsApp account from the tenant cache
+	template, err := w.Cache.Template(ctx, campaign.OrganizationID, campaign.TemplateID)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		w.Log.Error("Failed to load template", "error", err, "template_id", campaign.TemplateID)
+		w.DB.Model(&campaign).Update("status", "failed")
+		w.publishCampaignEvent(ctx, &campaign, "failed", "Failed to load template")
+		return fmt.Errorf("failed to load template: %w", err)
+	}
+
+	// Split campaigns send each recipient one of their variants' templates
+	var variants []models.CampaignVariant
+	if err := w.DB.Where("campaign_id = ?", campaign.ID).Order("position").Find(&variants).Error; err != nil {
+		w.Log.Error("Failed to load campaign variants", "error", err, "campaign_id", campaignID)
+		return fmt.Errorf("failed to load campaign variants: %w", err)
+	}
+	for i := range variants {
+		if variants[i].Template, err = w.Cache.Template(ctx, campaign.OrganizationID, variants[i].TemplateID); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.Log.Error("Failed to load variant template", "error", err, "template_id", variants[i].TemplateID, "variant", variants[i].Name)
+			w.DB.Model(&campaign).Update("status", "failed")
+			w.publishCampaignEvent(ctx, &campaign, "failed", "Failed to load template for variant "+variants[i].Name)
+			return fmt.Errorf("failed to load variant template: %w", err)
+		}
+	}
+
+	account, err := w.Cache.WhatsAppAccount(ctx, campaign.OrganizationID, campaign.WhatsAppAccount)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
 		w.Log.Error("Failed to load WhatsApp account", "error", err, "account_name", campaign.WhatsAppAccount)
 		w.DB.Model(&campaign).Update("status", "failed")
+		w.publishCampaignEvent(ctx, &campaign, "failed", "Failed to load WhatsApp account")
 		return fmt.Errorf("failed to load WhatsApp account: %w", err)
 	}
 
-	// Update status to processing
-	w.DB.Model(&campaign).Update("status", "processing")
+	// Update status to processing; a campaign queued again for its waiting recipients,
+	// or at the end of its hold, no longer needs resuming
+	wasProcessing := campaign.Status == "processing" || campaign.Status == "held"
+	started := w.DB.Model(&models.BulkMessageCampaign{}).
+		Where("id = ? AND status IN ?", campaignID, []string{"queued", "processing", "held"}).
+		Updates(map[string]interface{}{
+			"status":      "processing",
+			"resume_at":   nil,
+			"held_reason": "",
+		})
+	if started.Error == nil && started.RowsAffected == 0 {
+		w.Log.Info("Campaign paused or cancelled before processing", "campaign_id", campaignID)
+		return nil
+	}
+	if !wasProcessing {
+		w.publishCampaignEvent(ctx, &campaign, "started", "")
+	}
+
+	// Have more workers send the campaign alongside this run
+	if helpers := w.Config.Worker.CampaignWorkers - 1; helpers > 0 && !job.Helper {
+		if err := w.Queue.EnqueueCampaignHelpers(ctx, campaignID, queue.Priority(campaign.Priority), helpers); err != nil {
+			w.Log.Error("Failed to enqueue campaign helpers, sending on this worker only", "error", err, "campaign_id", campaignID)
+		}
+	}
 
-	// Get all pending recipients
-	var recipients []models.BulkMessageRecipient
-	if err := w.DB.Where("campaign_id = ? AND status = ?", campaignID, "pending").Find(&recipients).Error; err != nil {
-		w.Log.Error("Failed to load recipients", "error", err, "campaign_id", campaignID)
-		w.DB.Model(&campaign).Update("status", "failed")
-		return fmt.Errorf("failed to load recipients: %w", err)
+	// Settle recipients a stopped worker was sending to before picking up the rest
+	w.recoverAbandonedClaims(&campaign)
+
+	// Stream pending recipients in primary key order, resuming after the last batch
+	// a previous run finished. Recipients appended, or added or reset to pending behind
+	// the cursor, are picked up by starting another pass from the beginning.
+	run := &campaignRun{
+		campaign:    &campaign,
+		template:    template,
+		variants:    variants,
+		account:     account,
+		concurrency: w.campaignConcurrency(&campaign, account),
+		orgSettings: w.orgSettings(ctx, campaign.OrganizationID),
+		orgLocation: w.orgLocation(ctx, campaign.OrganizationID),
+		sentCount:   campaign.SentCount,
+		failedCount: campaign.FailedCount,
 	}
+	w.Log.Info("Sending to recipients", "campaign_id", campaignID, "concurrency", run.concurrency)
 
-	w.Log.Info("Processing recipients", "campaign_id", campaignID, "count", len(recipients))
+	// Counts are saved in batches while sending, and whatever is left when the run ends
+	stopSavingCounts := w.saveCountsPeriodically(run)
+	defer stopSavingCounts()
 
-	sentCount := campaign.SentCount
-	failedCount := campaign.FailedCount
+	// Pausing or cancelling the campaign stops the run before its next recipient
+	stopWatching := w.watchCampaign(run)
+	defer stopWatching()
 
-	for _, recipient := range recipients {
-		// Check context for cancellation
-		select {
-		case <-ctx.Done():
-			w.Log.Info("Campaign processing cancelled by context", "campaign_id", campaignID)
-			return ctx.Err()
-		default:
+	cursor := campaign.RecipientCursor
+	for {
+		processed, err := w.processPendingRecipients(ctx, run, cursor)
+		if err != nil {
+			// Deliver the last coalesced stats before stopping
+			w.Publisher.FlushCampaignStats(context.Background(), campaignID.String())
 		}
-
-		// Check if campaign is still active (not paused/cancelled)
-		var currentCampaign models.BulkMessageCampaign
-		w.DB.Where("id = ?", campaignID).First(&currentCampaign)
-		if currentCampaign.Status == "paused" || currentCampaign.Status == "cancelled" {
-			w.Log.Info("Campaign stopped", "campaign_id", campaignID, "status", currentCampaign.Status)
+		if errors.Is(err, errCampaignStopped) {
+			w.publishCancelledStats(run)
 			return nil
 		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// Shutting down: the sends in progress have finished and their outcomes
+				// and the counts are saved, so the job goes back on the queue for the
+				// next worker to carry on with the pending recipients
+				w.Log.Info("Campaign processing interrupted by shutdown", "campaign_id", campaignID)
+				return ctx.Err()
+			}
+			w.Log.Error("Failed to load recipients", "error", err, "campaign_id", campaignID)
+			w.DB.Model(&campaign).Update("status", "failed")
+			w.publishCampaignEvent(ctx, &campaign, "failed", "Failed to load recipients")
+			return fmt.Errorf("failed to load recipients: %w", err)
+		}
 
-		// Get or create contact for this recipient
-		contact, err := w.getOrCreateContact(campaign.OrganizationID, recipient.PhoneNumber, recipient.RecipientName)
-		if err != nil || contact == nil {
-			w.Log.Error("Failed to get or create contact", "error", err, "phone", recipient.PhoneNumber)
-			w.DB.Model(&recipient).Updates(map[string]interface{}{
-				"status":        "failed",
-				"error_message": "Failed to create contact",
-			})
-			failedCount++
+		var remaining int64
+		w.DB.Model(&models.BulkMessageRecipient{}).Where("campaign_id = ? AND status = ?", campaignID, "pending").
+			Where(recipientReady, time.Now()).Where(recipientUnclaimed).Count(&remaining)
+		if remaining > 0 && (cursor != nil || processed > 0) {
+			cursor = nil
 			continue
 		}
 
-		// Send template message
-		waMessageID, err := w.sendTemplateMessage(ctx, &account, campaign.Template, &recipient)
-
-		// Create Message record with campaign_id in metadata
-		message := models.Message{
-			OrganizationID:    campaign.OrganizationID,
-			WhatsAppAccount:   campaign.WhatsAppAccount,
-			ContactID:         contact.ID,
-			WhatsAppMessageID: waMessageID,
-			Direction:         "outgoing",
-			MessageType:       "template",
-			TemplateParams:    recipient.TemplateParams,
-			Metadata: models.JSONB{
-				"campaign_id":    campaignID.String(),
-				"recipient_name": recipient.RecipientName,
-			},
-		}
-		if campaign.Template != nil {
-			message.TemplateName = campaign.Template.Name
-			// Store template body with substituted values for display in chat
-			content := campaign.Template.BodyContent
-			// Replace placeholders {{1}}, {{2}}, etc. with actual values
-			if recipient.TemplateParams != nil {
-				for i := 1; i <= 10; i++ {
-					key := fmt.Sprintf("%d", i)
-					if val, ok := recipient.TemplateParams[key]; ok {
-						placeholder := fmt.Sprintf("{{%d}}", i)
-						content = strings.ReplaceAll(content, placeholder, fmt.Sprintf("%v", val))
-					}
-				}
-			}
-			message.Content = content
+		// Recipients outside the send window, or claimed by another worker, leave the
+		// campaign processing until the first window opens or claim expires, when
+		// runDeferredCampaigns queues it again
+		if resumeAt := earliest(w.nextDeferredRecipient(campaignID), w.nextClaimExpiry(campaignID)); resumeAt != nil {
+			w.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND status = ?", campaignID, "processing").
+				Updates(map[string]interface{}{
+					"resume_at":        *resumeAt,
+					"recipient_cursor": nil,
+				})
+			w.Publisher.FlushCampaignStats(context.Background(), campaignID.String())
+			w.Log.Info("Campaign waiting for deferred or claimed recipients", "campaign_id", campaignID, "resume_at", *resumeAt)
+			return nil
 		}
 
-		if err != nil {
-			w.Log.Error("Failed to send message", "error", err, "recipient", recipient.PhoneNumber)
-			message.Status = "failed"
-			message.ErrorMessage = err.Error()
-			failedCount++
-		} else {
-			w.Log.Info("Message sent", "recipient", recipient.PhoneNumber, "message_id", waMessageID)
-			message.Status = "sent"
-			sentCount++
+		// Mark campaign as completed, unless recipients were appended since the count.
+		// Pending recipients a full pass didn't send are left behind as before.
+		run.mu.Lock()
+		w.saveCounts(run)
+		run.mu.Unlock()
+		now := time.Now()
+		complete := w.DB.Model(&models.BulkMessageCampaign{}).Where("id = ? AND status = ?", campaignID, "processing")
+		if remaining == 0 {
+			complete = complete.Where("NOT EXISTS (SELECT 1 FROM bulk_message_recipients WHERE campaign_id = ? AND status = ? AND deleted_at IS NULL)", campaignID, "pending")
 		}
-
-		// Save message record
-		if err := w.DB.Create(&message).Error; err != nil {
-			w.Log.Error("Failed to save campaign message", "error", err, "recipient", recipient.PhoneNumber)
+		result := complete.Updates(map[string]interface{}{
+			"status":           "completed",
+			"completed_at":     now,
+			"recipient_cursor": nil,
+		})
+		if result.Error != nil {
+			w.Log.Error("Failed to complete campaign", "error", result.Error, "campaign_id", campaignID)
+			return fmt.Errorf("failed to complete campaign: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			break
 		}
 
-		// Update BulkMessageRecipient status to track which recipients have been processed
-		recipientUpdate := map[string]interface{}{
-			"status":               message.Status,
-			"whats_app_message_id": waMessageID,
+		// Either recipients were appended or the campaign was paused or cancelled meanwhile
+		var status string
+		w.DB.Model(&models.BulkMessageCampaign{}).Where("id = ?", campaignID).Pluck("status", &status)
+		if status == "completed" {
+			// Another worker sending the campaign finished it; this run's counts were
+			// saved after its completion, so publish the final ones again
+			w.Log.Info("Campaign completed by another worker", "campaign_id", campaignID)
+			w.publishCompletedStats(ctx, run)
+			return nil
 		}
-		if message.Status == "failed" {
-			recipientUpdate["error_message"] = message.ErrorMessage
-		} else {
-			recipientUpdate["sent_at"] = time.Now()
+		if status != "processing" {
+			w.Log.Info("Campaign stopped before completing", "campaign_id", campaignID, "status", status)
+			w.Publisher.FlushCampaignStats(context.Background(), campaignID.String())
+			w.publishCancelledStats(run)
+			return nil
 		}
-		w.DB.Model(&recipient).Updates(recipientUpdate)
+		cursor = nil
+	}
 
-		// Update campaign counts
-		w.DB.Model(&campaign).Updates(map[string]interface{}{
-			"sent_count":   sentCount,
-			"failed_count": failedCount,
-		})
+	// Publish completion status via Redis pub/sub
+	sentCount, failedCount := w.publishCompletedStats(ctx, run)
 
-		// Publish stats update via Redis pub/sub for real-time WebSocket broadcast
-		w.Publisher.PublishCampaignStats(ctx, &queue.CampaignStatsUpdate{
-			CampaignID:     campaignID.String(),
-			OrganizationID: campaign.OrganizationID,
-			Status:         "processing",
-			SentCount:      sentCount,
-			DeliveredCount: 0,
-			ReadCount:      0,
-			FailedCount:    failedCount,
-		})
+	w.publishCampaignEvent(ctx, &campaign, "completed", "")
 
-		// Small delay to avoid rate limiting (WhatsApp has rate limits)
-		time.Sleep(100 * time.Millisecond)
-	}
+	w.Log.Info("Campaign completed", "campaign_id", campaignID, "sent", sentCount, "failed", failedCount)
+	return nil
+}
 
-	// Mark campaign as completed
-	now := time.Now()
-	w.DB.Model(&campaign).Updates(map[string]interface{}{
-		"status":       "completed",
-		"completed_at": now,
-		"sent_count":   sentCount,
-		"failed_count": failedCount,
-	})
+// publishCompletedStats publishes the counts of a completed campaign as of the run's
+// last save, and returns them
+func (w *Worker) publishCompletedStats(ctx context.Context, run *campaignRun) (int, int) {
+	run.mu.Lock()
+	sentCount, failedCount := run.sentCount, run.failedCount
+	run.mu.Unlock()
 
-	// Publish completion status via Redis pub/sub
+	w.Publisher.FlushCampaignStats(context.Background(), run.campaign.ID.String())
 	w.Publisher.PublishCampaignStats(ctx, &queue.CampaignStatsUpdate{
-		CampaignID:     campaignID.String(),
-		OrganizationID: campaign.OrganizationID,
+		CampaignID:     run.campaign.ID.String(),
+		OrganizationID: run.campaign.OrganizationID,
 		Status:         "completed",
 		SentCount:      sentCount,
 		DeliveredCount: 0,
 		ReadCount:      0,
 		FailedCount:    failedCount,
 	})
+	return sentCount, failedCount
+}
 
-	w.Log.Info("Campaign completed", "campaign_id", campaignID, "sent", sentCount, "failed", failedCount)
+// publishCampaignEvent notifies the server of a lifecycle transition so it can fire webhooks
+func (w *Worker) publishCampaignEvent(ctx context.Context, campaign *models.BulkMessageCampaign, event, errMsg string) {
+	w.Publisher.PublishCampaignEvent(ctx, &queue.CampaignEvent{
+		CampaignID:     campaign.ID.String(),
+		OrganizationID: campaign.OrganizationID,
+		Event:          event,
+		Error:          errMsg,
+	})
+}
+
+// publishRecipientEvent notifies the server of a recipient's send outcome, for the
+// campaign's webhooks, if it has any
+func (w *Worker) publishRecipientEvent(ctx context.Context, run *campaignRun, update *queue.CampaignRecipientUpdate) {
+	if !run.recipientEvents.Load() {
+		return
+	}
+	w.Publisher.PublishCampaignEvent(ctx, &queue.CampaignEvent{
+		CampaignID:     run.campaign.ID.String(),
+		OrganizationID: run.campaign.OrganizationID,
+		Event:          "recipient_" + update.Status,
+		Error:          update.Error,
+		Recipient:      update,
+	})
+}
+
+// campaignRun holds the state shared by all recipient batches of one campaign run
+type campaignRun struct {
+	campaign    *models.BulkMessageCampaign
+	template    *models.Template
+	variants    []models.CampaignVariant // A/B split in position order, with templates; empty sends template
+	account     *models.WhatsAppAccount
+	concurrency int         // Recipients sent to at once
+	stopped     atomic.Bool // Set once the campaign is paused or cancelled
+
+	// Set while the campaign has webhooks, so recipient outcomes are published for them
+	recipientEvents atomic.Bool
+
+	// Organization settings, for the contact fields template parameters are filled from
+	orgSettings models.JSONB
+
+	// Timezones recipients are sent in for the campaign's send window
+	orgLocation *time.Location
+	locMu       sync.Mutex
+	locations   map[string]*time.Location
+
+	// Guards the counts, which recipients sent to at once all update. They are the
+	// campaign's as of the last save, including other workers', plus the run's since.
+	mu                         sync.Mutex
+	sentCount                  int
+	failedCount                int
+	unsavedSent, unsavedFailed int       // Outcomes counted since the counts were last saved
+	savedAt                    time.Time // When the counts were last saved
+	pending                    int       // Recipients still pending when last counted
+	pendingAt                  int       // Sent and failed counts when pending was counted
+
+	// Recipients processed per second, smoothed over throughputWindow windows. The
+	// window started at rateSince with rateFrom recipients processed.
+	rate      float64
+	rateSince time.Time
+	rateFrom  int
+}
+
+// campaignConcurrency returns how many recipients the campaign sends to at once: its
+// own setting, else its account's, else the worker's
+func (w *Worker) campaignConcurrency(campaign *models.BulkMessageCampaign, account *models.WhatsAppAccount) int {
+	n := w.Config.Worker.CampaignConcurrency
+	if account.CampaignConcurrency > 0 {
+		n = account.CampaignConcurrency
+	}
+	if campaign.Concurrency > 0 {
+		n = campaign.Concurrency
+	}
+	return max(1, min(n, models.MaxCampaignConcurrency))
+}
+
+// recordOutcome counts a recipient as sent or failed and queues the new counts to be
+// saved and published. Counts are written under the lock so they never go backwards.
+func (w *Worker) recordOutcome(ctx context.Context, run *campaignRun, sent bool) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	if sent {
+		run.sentCount++
+		run.unsavedSent++
+	} else {
+		run.failedCount++
+		run.unsavedFailed++
+	}
+
+	// Save the counts once enough outcomes or time have built up
+	if run.unsavedSent+run.unsavedFailed >= w.Config.Worker.CountsBatchSize || time.Since(run.savedAt) >= w.countsInterval() {
+		w.saveCounts(run)
+	}
+
+	// Queue stats update for real-time WebSocket broadcast; the publisher coalesces these
+	update := &queue.CampaignStatsUpdate{
+		CampaignID:     run.campaign.ID.String(),
+		OrganizationID: run.campaign.OrganizationID,
+		Status:         "processing",
+		SentCount:      run.sentCount,
+		DeliveredCount: 0,
+		ReadCount:      0,
+		FailedCount:    run.failedCount,
+
+		TotalRecipients: run.campaign.TotalRecipients,
+	}
+	now := time.Now()
+	if rate := run.throughput(now); rate > 0 {
+		// The recipients still pending, less those sent or failed since they were
+		// counted; skipped and cancelled recipients aren't pending, so aren't waited on
+		remaining := max(0, run.pending-(run.sentCount+run.failedCount-run.pendingAt))
+		eta := now.Add(time.Duration(float64(remaining) / rate * float64(time.Second))).Truncate(time.Second)
+		update.ProcessedPerSecond = math.Round(rate*100) / 100
+		update.EstimatedCompletionAt = &eta
+	}
+	w.Publisher.QueueCampaignStats(ctx, update)
+}
+
+// throughput updates the run's rate once a window has passed and returns it, 0 until
+// the first window has. The counts take in other workers' outcomes as they are saved,
+// so the rate is the campaign's rather than the run's. The caller holds run.mu.
+func (run *campaignRun) throughput(now time.Time) float64 {
+	processed := run.sentCount + run.failedCount
+	if run.rateSince.IsZero() {
+		run.rateSince, run.rateFrom = now, processed-1 // This outcome is the window's first
+		return 0
+	}
+	elapsed := now.Sub(run.rateSince)
+	if elapsed < throughputWindow {
+		return run.rate
+	}
+
+	windowRate := float64(processed-run.rateFrom) / elapsed.Seconds()
+	if run.rate == 0 {
+		run.rate = windowRate
+	} else {
+		run.rate = throughputSmoothing*windowRate + (1-throughputSmoothing)*run.rate
+	}
+	run.rateSince, run.rateFrom = now, processed
+	return run.rate
+}
+
+// saveCounts adds the outcomes the run counted since the last save to the campaign's
+// counts, and takes in the totals, which include what other workers sending the
+// campaign saved. The caller holds run.mu.
+func (w *Worker) saveCounts(run *campaignRun) {
+	run.savedAt = time.Now()
+	if run.unsavedSent == 0 && run.unsavedFailed == 0 {
+		return
+	}
+	var saved models.BulkMessageCampaign
+	result := w.DB.Model(&saved).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "sent_count"}, {Name: "failed_count"}}}).
+		Where("id = ?", run.campaign.ID).
+		Updates(map[string]interface{}{
+			"sent_count":   gorm.Expr("sent_count + ?", run.unsavedSent),
+			"failed_count": gorm.Expr("failed_count + ?", run.unsavedFailed),
+		})
+	if result.Error != nil {
+		w.Log.Error("Failed to save campaign counts", "error", result.Error, "campaign_id", run.campaign.ID)
+		return
+	}
+	if result.RowsAffected > 0 {
+		run.sentCount, run.failedCount = saved.SentCount, saved.FailedCount
+	}
+	run.unsavedSent, run.unsavedFailed = 0, 0
+}
+
+// countPending reads how many of the run's campaign's recipients are still pending,
+// for its completion estimate. It queries without holding run.mu, so sends carry on,
+// and takes it to store the count.
+func (w *Worker) countPending(run *campaignRun) {
+	var pending int64
+	if err := w.DB.Model(&models.BulkMessageRecipient{}).
+		Where("campaign_id = ? AND status = ?", run.campaign.ID, "pending").
+		Count(&pending).Error; err != nil {
+		return
+	}
+	run.mu.Lock()
+	run.pending, run.pendingAt = int(pending), run.sentCount+run.failedCount
+	run.mu.Unlock()
+}
+
+// saveCountsPeriodically saves the run's counts every counts interval, so they stay
+// current while sending slows down. The returned func stops it and saves the counts
+// one last time.
+func (w *Worker) saveCountsPeriodically(run *campaignRun) func() {
+	run.savedAt = time.Now()
+	w.countPending(run)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(w.countsInterval())
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				run.mu.Lock()
+				w.saveCounts(run)
+				run.mu.Unlock()
+				w.countPending(run)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		run.mu.Lock()
+		w.saveCounts(run)
+		run.mu.Unlock()
+	}
+}
+
+// countsInterval is the longest the run's counts go unsaved while sending
+func (w *Worker) countsInterval() time.Duration {
+	if w.Config.Worker.CountsIntervalMs <= 0 {
+		return 2 * time.Second
+	}
+	return time.Duration(w.Config.Worker.CountsIntervalMs) * time.Millisecond
+}
+
+// errCampaignStopped ends a run when the campaign is paused or cancelled
+var errCampaignStopped = errors.New("campaign stopped")
+
+// processPendingRecipients claims pending recipients after cursor in batches and sends
+// to them, persisting the last claimed recipient ID on the campaign after each batch so
+// a restarted run can resume. Workers sending the same campaign each claim their own
+// batches.
+func (w *Worker) processPendingRecipients(ctx context.Context, run *campaignRun, cursor *uuid.UUID) (int, error) {
+	campaignID := run.campaign.ID
+	size := w.claimBatchSize(run)
+
+	processed := 0
+	for batchNum := 1; ; batchNum++ {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+		if run.stopped.Load() {
+			return processed, errCampaignStopped
+		}
+
+		// Hold the campaign through the organization's quiet hours and blackout dates;
+		// it continues on its own once they have passed
+		if hold := w.campaignHold(ctx, run.campaign.OrganizationID); hold != nil {
+			w.holdCampaign(ctx, run, hold)
+			return processed, errCampaignStopped
+		}
+
+		batch, err := w.claimRecipients(campaignID, cursor, size)
+		if err != nil {
+			return processed, err
+		}
+		if len(batch) == 0 {
+			return processed, nil
+		}
+		cursor = &batch[len(batch)-1].ID
+
+		w.Log.Info("Processing recipient batch", "campaign_id", campaignID, "batch", batchNum, "count", len(batch))
+
+		// Resolve the batch's contacts in a few bulk queries instead of per recipient
+		contacts, err := w.preloadContacts(run.campaign.OrganizationID, batch)
+		if err != nil {
+			w.Log.Error("Failed to preload contacts, falling back to per-recipient lookup", "error", err, "campaign_id", campaignID)
+			contacts = map[string]*models.Contact{}
+		}
+
+		// Send to the batch's recipients concurrently. The cursor only moves past the
+		// batch once all of it is done, so a stopped run resends nothing it finished.
+		err = runPool(ctx, run.concurrency, len(batch), func(i int) error {
+			return w.processRecipient(ctx, run, contacts, &batch[i])
+		})
+
+		// Give back the recipients the run deferred or didn't get to before stopping
+		w.releaseClaims(batch)
+		if err != nil {
+			return processed, err
+		}
+		processed += len(batch)
+
+		w.DB.Model(run.campaign).Update("recipient_cursor", *cursor)
+	}
+}
+
+// recipientPlan is what sending does for a recipient, as worked out by planRecipient
+type recipientPlan struct {
+	template *models.Template
+	variant  string       // The campaign variant picked, if the campaign has variants
+	invalid  string       // Why the recipient can't be sent to; the recipient fails
+	params   models.JSONB // Template parameters to send
+	missing  []string     // Template parameters left without a value; the recipient is skipped
+	opensAt  *time.Time   // When the recipient's send window opens, if it isn't open now
+}
+
+// recipientTemplate returns the template the recipient is sent and the name of the
+// campaign variant it comes from, if any
+func (run *campaignRun) recipientTemplate(recipient *models.BulkMessageRecipient) (*models.Template, string) {
+	if variant := models.PickCampaignVariant(run.campaign.ID, recipient.PhoneNumber, run.variants); variant != nil {
+		return variant.Template, variant.Name
+	}
+	return run.template, ""
+}
+
+// planRecipient decides whether and what the recipient with the contact is sent.
+// Sending and dry runs both go by it, so a dry run predicts what sending does.
+func (run *campaignRun) planRecipient(contact *models.Contact, recipient *models.BulkMessageRecipient) recipientPlan {
+	var plan recipientPlan
+	plan.template, plan.variant = run.recipientTemplate(recipient)
+
+	// Meta rejects numbers that aren't valid international numbers
+	if _, err := phonenumber.Parse(recipient.PhoneNumber, ""); err != nil {
+		plan.invalid = "Invalid phone number: " + err.Error()
+		return plan
+	}
+
+	// Fill parameters the recipient doesn't give from the contact's fields, then apply
+	// the campaign's policy for the ones still missing
+	sources := models.TemplateParamSources(run.orgSettings, plan.template)
+	params := models.FillTemplateParams(plan.template, recipient.TemplateParams, sources, models.ContactParamFields(contact))
+	plan.params, plan.missing = run.campaign.ResolveTemplateParams(plan.template, params)
+	if len(plan.missing) > 0 {
+		return plan
+	}
+
+	// Recipients outside the send window in their timezone wait for it to open
+	if run.campaign.SendWindowStart != "" {
+		local := time.Now().In(run.location(contact.Timezone))
+		if opens := run.campaign.SendWindowOpensAt(local); opens.After(local) {
+			plan.opensAt = &opens
+		}
+	}
+	return plan
+}
+
+// processRecipient sends the campaign template to a single recipient and records the outcome
+func (w *Worker) processRecipient(ctx context.Context, run *campaignRun, contacts map[string]*models.Contact, recipient *models.BulkMessageRecipient) error {
+	campaign := run.campaign
+	campaignID := campaign.ID
+	template, variantName := run.recipientTemplate(recipient)
+
+	// Check context for cancellation
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	// Stop once the campaign is paused or cancelled
+	if run.stopped.Load() {
+		return errCampaignStopped
+	}
+
+	// Skip recipients suppressed since the batch was loaded
+	var current models.BulkMessageRecipient
+	if err := w.DB.Select("status").Where("id = ?", recipient.ID).First(&current).Error; err == nil && current.Status != "pending" {
+		w.Log.Info("Recipient no longer pending, skipping", "campaign_id", campaignID, "recipient_id", recipient.ID, "status", current.Status)
+		return nil
+	}
+
+	// Get or create contact for this recipient
+	var contactErr error
+	contact, ok := contacts[normalizePhone(recipient.PhoneNumber)]
+	if !ok {
+		contact, contactErr = w.getOrCreateContact(campaign.OrganizationID, recipient.PhoneNumber, recipient.RecipientName)
+	}
+	if contactErr != nil || contact == nil {
+		w.Log.Error("Failed to get or create contact", "error", contactErr, "phone", recipient.PhoneNumber)
+		w.DB.Model(recipient).Updates(map[string]interface{}{
+			"status":         "failed",
+			"error_message":  "Failed to create contact",
+			"error_category": whatsapp.FailureUnknown,
+		})
+		w.recordOutcome(ctx, run, false)
+		w.publishRecipientEvent(ctx, run, &queue.CampaignRecipientUpdate{
+			RecipientID:   recipient.ID.String(),
+			PhoneNumber:   recipient.PhoneNumber,
+			RecipientName: recipient.RecipientName,
+			Status:        "failed",
+			Variant:       variantName,
+			Error:         "Failed to create contact",
+		})
+		return nil
+	}
+
+	plan := run.planRecipient(contact, recipient)
+	if plan.invalid != "" {
+		w.Log.Info("Recipient can't be sent to, failing", "campaign_id", campaignID, "recipient_id", recipient.ID, "error", plan.invalid)
+		w.DB.Model(recipient).Updates(map[string]interface{}{
+			"status":         "failed",
+			"error_message":  plan.invalid,
+			"error_category": whatsapp.FailureInvalidNumber,
+		})
+		w.recordOutcome(ctx, run, false)
+		w.publishRecipientEvent(ctx, run, &queue.CampaignRecipientUpdate{
+			RecipientID:   recipient.ID.String(),
+			PhoneNumber:   recipient.PhoneNumber,
+			RecipientName: recipient.RecipientName,
+			Status:        "failed",
+			Variant:       variantName,
+			Error:         plan.invalid,
+		})
+		return nil
+	}
+	if len(plan.missing) > 0 {
+		w.Log.Info("Recipient missing template parameters, skipping", "campaign_id", campaignID, "recipient_id", recipient.ID, "missing", plan.missing)
+		w.DB.Model(recipient).Updates(map[string]interface{}{
+			"status":        "skipped",
+			"error_message": models.MissingParamsError(plan.missing),
+		})
+		return nil
+	}
+	recipient.TemplateParams = plan.params
+	if plan.opensAt != nil {
+		w.DB.Model(recipient).Update("deferred_until", *plan.opensAt)
+		return nil
+	}
+
+	// Claim the recipient so a restarted or concurrent run never sends to it again
+	if !w.claimRecipient(recipient) {
+		w.Log.Info("Recipient claimed or no longer pending, skipping", "campaign_id", campaignID, "recipient_id", recipient.ID)
+		return nil
+	}
+
+	// A message saved for this attempt means an earlier run already sent it, or may have
+	idempotencyKey := recipient.IdempotencyKey()
+	var attempted models.Message
+	if err := w.DB.Where("idempotency_key = ?", idempotencyKey).First(&attempted).Error; err == nil {
+		w.Log.Info("Recipient attempt already made, not sending again", "campaign_id", campaignID, "recipient_id", recipient.ID, "status", attempted.Status)
+		w.settleAttemptedRecipient(ctx, run, recipient, &attempted)
+		return nil
+	}
+
+	// Wait for the campaign's and the account's throughput to allow another message
+	if err := w.throttle(ctx, run); err != nil {
+		w.releaseRecipient(recipient)
+		return err
+	}
+	if run.stopped.Load() {
+		w.releaseRecipient(recipient)
+		return errCampaignStopped
+	}
+	// Renew the claim after waiting, so it can't lapse before the send is recorded
+	if !w.claimRecipient(recipient) {
+		w.Log.Info("Recipient claim lapsed while waiting to send, skipping", "campaign_id", campaignID, "recipient_id", recipient.ID)
+		return nil
+	}
+
+	// Save the message with the attempt's idempotency key before sending, so a worker
+	// stopping between the send and recording it can't lead to a second send
+	message := models.Message{
+		OrganizationID:  campaign.OrganizationID,
+		WhatsAppAccount: campaign.WhatsAppAccount,
+		ContactID:       contact.ID,
+		Direction:       "outgoing",
+		MessageType:     "template",
+		TemplateParams:  recipient.TemplateParams,
+		Status:          "pending",
+		IdempotencyKey:  &idempotencyKey,
+		Metadata: models.JSONB{
+			"campaign_id":    campaignID.String(),
+			"recipient_id":   recipient.ID.String(),
+			"recipient_name": recipient.RecipientName,
+		},
+	}
+	if variantName != "" {
+		message.Metadata["campaign_variant"] = variantName
+	}
+	if template != nil {
+		message.TemplateName = template.Name
+		// Store template body with substituted values for display in chat
+		message.Content = renderTemplateBody(template, recipient.TemplateParams)
+	}
+	if err := w.DB.Create(&message).Error; err != nil {
+		w.Log.Error("Failed to save campaign message, leaving recipient pending", "error", err, "recipient", recipient.PhoneNumber)
+		w.releaseRecipient(recipient)
+		return nil
+	}
+
+	// Send template message
+	waMessageID, err := w.sendTemplateMessage(ctx, run.account, template, recipient)
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		// Shutting down while waiting to retry; nothing was sent, so withdraw the attempt
+		// and leave the recipient pending. The message is soft deleted as the audit log
+		// may already record it.
+		w.DB.Model(&message).Updates(map[string]interface{}{
+			"idempotency_key": nil,
+			"deleted_at":      time.Now(),
+		})
+		w.releaseRecipient(recipient)
+		return ctx.Err()
+	}
+
+	if err != nil {
+		w.Log.Error("Failed to send message", "error", err, "recipient", recipient.PhoneNumber)
+		message.Status = "failed"
+		message.ErrorMessage = err.Error()
+	} else {
+		w.Log.Info("Message sent", "recipient", recipient.PhoneNumber, "message_id", waMessageID)
+		message.Status = "sent"
+	}
+
+	// Record the outcome on the saved message
+	if err := w.DB.Model(&message).Updates(map[string]interface{}{
+		"whats_app_message_id": waMessageID,
+		"status":               message.Status,
+		"error_message":        message.ErrorMessage,
+	}).Error; err != nil {
+		w.Log.Error("Failed to save campaign message", "error", err, "recipient", recipient.PhoneNumber)
+	}
+
+	// Update BulkMessageRecipient status to track which recipients have been processed
+	recipientUpdate := map[string]interface{}{
+		"status":               message.Status,
+		"whats_app_message_id": waMessageID,
+		"message_id":           message.ID,
+		"variant":              variantName,
+		"attempts":             gorm.Expr("attempts + 1"),
+		"claimed_by":           "",
+		"claimed_at":           nil,
+	}
+	if message.Status == "failed" {
+		code, category := whatsapp.ClassifyFailure(err)
+		recipientUpdate["error_message"] = message.ErrorMessage
+		recipientUpdate["error_code"] = code
+		recipientUpdate["error_category"] = category
+	} else {
+		recipientUpdate["sent_at"] = time.Now()
+	}
+	w.DB.Model(recipient).Updates(recipientUpdate)
+
+	// Update campaign counts
+	w.recordOutcome(context.WithoutCancel(ctx), run, message.Status == "sent")
+	w.publishRecipientEvent(context.WithoutCancel(ctx), run, &queue.CampaignRecipientUpdate{
+		RecipientID:       recipient.ID.String(),
+		PhoneNumber:       recipient.PhoneNumber,
+		RecipientName:     recipient.RecipientName,
+		Status:            message.Status,
+		Variant:           variantName,
+		MessageID:         message.ID.String(),
+		WhatsAppMessageID: waMessageID,
+		Error:             message.ErrorMessage,
+	})
 	return nil
 }
 
-// sendTemplateMessage sends a template message via WhatsApp Cloud API
+// renderTemplateBody returns the template's body with its {{1}}, {{2}}, etc.
+// placeholders replaced by the parameters' values
+func renderTemplateBody(template *models.Template, params models.JSONB) string {
+	content := template.BodyContent
+	for i := 1; i <= 10; i++ {
+		key := fmt.Sprintf("%d", i)
+		if val, ok := params[key]; ok {
+			placeholder := fmt.Sprintf("{{%d}}", i)
+			content = strings.ReplaceAll(content, placeholder, fmt.Sprintf("%v", val))
+		}
+	}
+	return content
+}
+
+// sendTemplateMessage sends a template message via WhatsApp Cloud API, retrying
+// transient failures
 func (w *Worker) sendTemplateMessage(ctx context.Context, account *models.WhatsAppAccount, template *models.Template, recipient *models.BulkMessageRecipient) (string, error) {
 	waAccount := &whatsapp.Account{
 		PhoneID:     account.PhoneID,
@@ -282,7 +955,12 @@ func (w *Worker) sendTemplateMessage(ctx context.Context, account *models.WhatsA
 		}
 	}
 
-	return w.WhatsApp.SendTemplateMessageWithComponents(ctx, waAccount, recipient.PhoneNumber, template.Name, template.Language, components)
+	// Retry timeouts, throttling and outages; permanent errors fail straight away.
+	// Shutting down only ends the wait before a retry: an attempt is never cut off, as
+	// the message may reach WhatsApp regardless.
+	return whatsapp.WithRetry(ctx, func() (string, error) {
+		return w.WhatsApp.SendTemplateMessageWithComponents(context.WithoutCancel(ctx), waAccount, recipient.PhoneNumber, template.Name, template.Language, components)
+	})
 }
 
 // Close cleans up worker resources
@@ -295,32 +973,22 @@ func (w *Worker) Close() error {
 
 // getOrCreateContact finds or creates a contact for a phone number
 func (w *Worker) getOrCreateContact(orgID uuid.UUID, phoneNumber, name string) (*models.Contact, error) {
-	// Normalize phone number (remove + prefix if present)
-	normalizedPhone := phoneNumber
-	if len(normalizedPhone) > 0 && normalizedPhone[0] == '+' {
-		normalizedPhone = normalizedPhone[1:]
-	}
+	normalizedPhone := normalizePhone(phoneNumber)
 
-	// Try to find existing contact
+	// Try to find existing contact, stored with or without + prefix
 	var contact models.Contact
-	err := w.DB.Where("organization_id = ? AND phone_number = ?", orgID, normalizedPhone).First(&contact).Error
-	if err == nil {
-		return &contact, nil
-	}
-
-	// Also try with + prefix
-	err = w.DB.Where("organization_id = ? AND phone_number = ?", orgID, "+"+normalizedPhone).First(&contact).Error
+	err := w.DB.Where("organization_id = ? AND phone_number IN ?", orgID, []string{normalizedPhone, "+" + normalizedPhone}).First(&contact).Error
 	if err == nil {
 		return &contact, nil
 	}
 
-	// Create new contact
+	// Create new contact; if another worker created it meanwhile the upsert returns that row
 	contact = models.Contact{
 		OrganizationID: orgID,
 		PhoneNumber:    normalizedPhone,
 		ProfileName:    name,
 	}
-	if err := w.DB.Create(&contact).Error; err != nil {
+	if err := w.DB.Clauses(models.ContactUpsert(), clause.Returning{}).Create(&contact).Error; err != nil {
 		return nil, fmt.Errorf("failed to create contact: %w", err)
 	}
 
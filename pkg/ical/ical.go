@@ -0,0 +1,165 @@
+// Package ical parses the subset of iCalendar (RFC 5545) used by public
+// holiday feeds: VEVENT blocks with SUMMARY, DTSTART and DTEND.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is a calendar event. End is exclusive; for all-day events Start and
+// End are midnight UTC of the first day and the day after the last day.
+type Event struct {
+	UID     string
+	Summary string
+	Start   time.Time
+	End     time.Time
+	AllDay  bool
+}
+
+// ErrNoCalendar is returned when the input does not contain a VCALENDAR
+var ErrNoCalendar = errors.New("ical: not an iCalendar document")
+
+// Parse reads all VEVENTs from an iCalendar document
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events     []Event
+		current    *Event
+		inCalendar bool
+	)
+	for _, line := range lines {
+		name, params, value := splitLine(line)
+		switch {
+		case name == "BEGIN" && value == "VCALENDAR":
+			inCalendar = true
+		case name == "BEGIN" && value == "VEVENT":
+			current = &Event{}
+		case name == "END" && value == "VEVENT":
+			if current != nil && !current.Start.IsZero() {
+				if current.End.IsZero() || !current.End.After(current.Start) {
+					// A missing DTEND means a one-day event for dates and an instant otherwise
+					if current.AllDay {
+						current.End = current.Start.AddDate(0, 0, 1)
+					} else {
+						current.End = current.Start
+					}
+				}
+				events = append(events, *current)
+			}
+			current = nil
+		case current == nil:
+			continue
+		case name == "UID":
+			current.UID = value
+		case name == "SUMMARY":
+			current.Summary = unescape(value)
+		case name == "DTSTART":
+			t, allDay, err := parseTime(value, params)
+			if err != nil {
+				return nil, err
+			}
+			current.Start, current.AllDay = t, allDay
+		case name == "DTEND":
+			t, _, err := parseTime(value, params)
+			if err != nil {
+				return nil, err
+			}
+			current.End = t
+		}
+	}
+
+	if !inCalendar {
+		return nil, ErrNoCalendar
+	}
+	return events, nil
+}
+
+// Days returns the calendar days (midnight UTC) covered by the event
+func (e Event) Days() []time.Time {
+	start := truncateDay(e.Start)
+	end := e.End
+	if !e.AllDay {
+		// A timed event covers every day it touches
+		end = truncateDay(e.End.Add(-time.Nanosecond)).AddDate(0, 0, 1)
+	}
+
+	var days []time.Time
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	if len(days) == 0 {
+		days = append(days, start)
+	}
+	return days
+}
+
+// unfold joins continuation lines (those starting with a space or tab)
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// splitLine splits "NAME;PARAM=X:VALUE" into its name, parameters and value
+func splitLine(line string) (string, map[string]string, string) {
+	head, value, _ := strings.Cut(line, ":")
+	parts := strings.Split(head, ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, strings.TrimSpace(value)
+}
+
+// parseTime handles DATE, floating/UTC DATE-TIME and TZID-qualified DATE-TIME values
+func parseTime(value string, params map[string]string) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.Parse("20060102", value)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func unescape(s string) string {
+	r := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return r.Replace(s)
+}
@@ -0,0 +1,350 @@
+// Package phonenumber normalizes phone numbers to E.164 and gives best-effort
+// country and line-type hints from the country calling code and national number
+// prefixes. It is a lightweight check, not a full numbering plan database: numbers
+// that pass may still be unassigned, and line types are hints.
+package phonenumber
+
+import (
+	"errors"
+	"strings"
+)
+
+// LineType hints what kind of line a number belongs to
+type LineType string
+
+// Line types
+const (
+	Mobile        LineType = "mobile"
+	FixedLine     LineType = "fixed_line"
+	FixedOrMobile LineType = "fixed_or_mobile" // The numbering plan doesn't tell them apart
+	TollFree      LineType = "toll_free"
+	PremiumRate   LineType = "premium_rate"
+	Unknown       LineType = "unknown"
+)
+
+// Parse errors
+var (
+	ErrEmpty              = errors.New("phone number is empty")
+	ErrInvalidCharacters  = errors.New("phone number contains invalid characters")
+	ErrTooShort           = errors.New("phone number is too short")
+	ErrTooLong            = errors.New("phone number is longer than 15 digits")
+	ErrUnknownCallingCode = errors.New("unknown country calling code")
+	ErrInvalidLength      = errors.New("phone number has the wrong length for its country")
+	ErrUnknownCountry     = errors.New("unknown default country")
+)
+
+// Number is a parsed phone number
+type Number struct {
+	CallingCode    string // e.g. "91"
+	NationalNumber string // Significant national number, without trunk prefix
+	Country        string // ISO 3166-1 alpha-2 region; empty when the calling code isn't tied to one
+	LineType       LineType
+}
+
+// Digits returns the number with its calling code and no "+", the form WhatsApp
+// uses for wa_id and contacts are stored under
+func (n *Number) Digits() string {
+	return n.CallingCode + n.NationalNumber
+}
+
+// E164 returns the number in E.164 format, e.g. "+919876543210"
+func (n *Number) E164() string {
+	return "+" + n.Digits()
+}
+
+// Parse normalizes a phone number. Spaces, dashes, dots and parentheses are ignored.
+// A number starting with "+" or "00" is international. Otherwise it is taken as
+// national to defaultCountry when one is given and the number doesn't already start
+// with that country's calling code, and as international (as WhatsApp writes
+// numbers) when not.
+func Parse(raw, defaultCountry string) (*Number, error) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return nil, ErrEmpty
+	}
+
+	international := false
+	if strings.HasPrefix(s, "+") {
+		international = true
+		s = s[1:]
+	}
+	var digits strings.Builder
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')' || c == '\u00a0':
+		default:
+			return nil, ErrInvalidCharacters
+		}
+	}
+	d := digits.String()
+	if !international && strings.HasPrefix(d, "00") {
+		international = true
+		d = d[2:]
+	}
+	if d == "" {
+		return nil, ErrEmpty
+	}
+
+	if !international && defaultCountry != "" {
+		p, ok := regionPlans[strings.ToUpper(defaultCountry)]
+		if !ok {
+			return nil, ErrUnknownCountry
+		}
+		// A number that only fits the plan without the calling code is national even
+		// if it starts with the code
+		national := !strings.HasPrefix(d, p.code) || (p.trunk != "" && strings.HasPrefix(d, p.trunk))
+		if !national && p.maxLen > 0 {
+			rest := len(d) - len(p.code)
+			national = (rest < p.minLen || rest > p.maxLen) && len(d) >= p.minLen && len(d) <= p.maxLen
+		}
+		if national {
+			if p.trunk != "" {
+				d = strings.TrimPrefix(d, p.trunk)
+			}
+			d = p.code + d
+		}
+	}
+
+	if len(d) > 15 {
+		return nil, ErrTooLong
+	}
+	if len(d) < 7 {
+		return nil, ErrTooShort
+	}
+
+	code, p := lookupCallingCode(d)
+	if code == "" {
+		return nil, ErrUnknownCallingCode
+	}
+	n := &Number{CallingCode: code, NationalNumber: d[len(code):], LineType: Unknown}
+
+	if p == nil {
+		// Only the calling code is known
+		n.Country = fallbackRegions[code]
+		if len(n.NationalNumber) < 4 {
+			return nil, ErrTooShort
+		}
+		return n, nil
+	}
+
+	// Some countries keep a trunk prefix that was written after the calling code,
+	// e.g. +44 (0)20
+	if p.trunk != "" && len(n.NationalNumber) > p.maxLen && strings.HasPrefix(n.NationalNumber, p.trunk) {
+		n.NationalNumber = strings.TrimPrefix(n.NationalNumber, p.trunk)
+	}
+	if len(n.NationalNumber) < p.minLen || len(n.NationalNumber) > p.maxLen {
+		return nil, ErrInvalidLength
+	}
+
+	n.Country = p.region
+	if p.resolve != nil {
+		n.Country = p.resolve(n.NationalNumber)
+	}
+	n.LineType = p.lineType(n.NationalNumber)
+	return n, nil
+}
+
+// KnownCountry reports whether region is an ISO 3166-1 alpha-2 code Parse accepts as
+// a default country
+func KnownCountry(region string) bool {
+	_, ok := regionPlans[strings.ToUpper(region)]
+	return ok
+}
+
+// lookupCallingCode finds the calling code at the start of digits. Calling codes are
+// prefix-free, so at most one of the 1 to 3 digit prefixes matches.
+func lookupCallingCode(digits string) (string, *plan) {
+	for size := 1; size <= 3 && size < len(digits); size++ {
+		code := digits[:size]
+		if p, ok := codePlans[code]; ok {
+			return code, p
+		}
+		if _, ok := fallbackRegions[code]; ok {
+			return code, nil
+		}
+	}
+	return "", nil
+}
+
+// plan describes a country's numbering plan. Prefixes apply to the national number.
+type plan struct {
+	code     string
+	region   string
+	minLen   int
+	maxLen   int
+	trunk    string // National trunk prefix dialled before the number, e.g. "0"
+	mobile   []string
+	fixed    []string // When set, numbers matching none of the prefixes are unknown
+	tollFree []string
+	premium  []string
+	mixed    bool                           // Fixed and mobile numbers share ranges
+	resolve  func(national string) string   // Picks the region of a shared calling code
+	classify func(national string) LineType // Replaces the prefix rules
+}
+
+func (p *plan) lineType(national string) LineType {
+	if p.classify != nil {
+		return p.classify(national)
+	}
+	switch {
+	case hasAnyPrefix(national, p.tollFree):
+		return TollFree
+	case hasAnyPrefix(national, p.premium):
+		return PremiumRate
+	case p.mixed:
+		return FixedOrMobile
+	case hasAnyPrefix(national, p.mobile):
+		return Mobile
+	case len(p.fixed) == 0 || hasAnyPrefix(national, p.fixed):
+		return FixedLine
+	}
+	return Unknown
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func split(s string) []string {
+	return strings.Fields(s)
+}
+
+// canadianAreaCodes are the NANP area codes assigned to Canada; other +1 numbers are
+// reported as US
+var canadianAreaCodes = split("204 226 236 249 250 257 263 289 306 343 354 365 367 368 382 387 403 416 418 428 431 437 438 450 460 468 474 506 514 519 548 579 581 584 587 600 604 613 639 647 672 683 705 709 742 753 778 780 782 807 819 825 867 873 879 902 905")
+
+var plans = []*plan{
+	{code: "1", region: "US", minLen: 10, maxLen: 10, mixed: true,
+		tollFree: split("800 833 844 855 866 877 888"), premium: split("900"),
+		resolve: func(n string) string {
+			if hasAnyPrefix(n, canadianAreaCodes) {
+				return "CA"
+			}
+			return "US"
+		}},
+	{code: "7", region: "RU", minLen: 10, maxLen: 10, trunk: "8", mobile: split("9"), tollFree: split("800"),
+		resolve: func(n string) string {
+			if hasAnyPrefix(n, split("6 7")) {
+				return "KZ"
+			}
+			return "RU"
+		}},
+	{code: "20", region: "EG", minLen: 8, maxLen: 10, trunk: "0", mobile: split("1"), tollFree: split("800")},
+	{code: "27", region: "ZA", minLen: 9, maxLen: 9, trunk: "0", mobile: split("6 7 8"), tollFree: split("80"), premium: split("86")},
+	{code: "31", region: "NL", minLen: 9, maxLen: 9, trunk: "0", mobile: split("6"), tollFree: split("800"), premium: split("90")},
+	{code: "32", region: "BE", minLen: 8, maxLen: 9, trunk: "0", mobile: split("4"), tollFree: split("800"), premium: split("90")},
+	{code: "33", region: "FR", minLen: 9, maxLen: 9, trunk: "0", mobile: split("6 7"), tollFree: split("80"), premium: split("89")},
+	{code: "34", region: "ES", minLen: 9, maxLen: 9, mobile: split("6 7"), tollFree: split("800 900"), premium: split("803 806 807")},
+	{code: "39", region: "IT", minLen: 6, maxLen: 11, mobile: split("3"), tollFree: split("80"), premium: split("89")},
+	{code: "41", region: "CH", minLen: 9, maxLen: 9, trunk: "0", mobile: split("7"), tollFree: split("800"), premium: split("90")},
+	{code: "43", region: "AT", minLen: 4, maxLen: 13, trunk: "0", mobile: split("6"), tollFree: split("800"), premium: split("9")},
+	{code: "44", region: "GB", minLen: 9, maxLen: 10, trunk: "0", mobile: split("71 72 73 74 75 77 78 79"),
+		fixed: split("1 2"), tollFree: split("800 808"), premium: split("9")},
+	{code: "46", region: "SE", minLen: 7, maxLen: 9, trunk: "0", mobile: split("7"), tollFree: split("20"), premium: split("9")},
+	{code: "48", region: "PL", minLen: 9, maxLen: 9, mobile: split("45 5 6 7 88"), tollFree: split("800"), premium: split("70")},
+	{code: "49", region: "DE", minLen: 6, maxLen: 13, trunk: "0", mobile: split("15 16 17"), tollFree: split("800"), premium: split("900")},
+	{code: "51", region: "PE", minLen: 8, maxLen: 9, mobile: split("9")},
+	{code: "52", region: "MX", minLen: 10, maxLen: 10, mixed: true, tollFree: split("800"), premium: split("900")},
+	{code: "54", region: "AR", minLen: 10, maxLen: 11, trunk: "0", mobile: split("9"), tollFree: split("800")},
+	{code: "55", region: "BR", minLen: 10, maxLen: 11,
+		classify: func(n string) LineType {
+			// Mobile numbers have a 9 after the two-digit area code
+			switch {
+			case strings.HasPrefix(n, "800"):
+				return TollFree
+			case len(n) == 11 && n[2] == '9':
+				return Mobile
+			case len(n) == 10:
+				return FixedLine
+			}
+			return Unknown
+		}},
+	{code: "56", region: "CL", minLen: 9, maxLen: 9, mobile: split("9")},
+	{code: "57", region: "CO", minLen: 10, maxLen: 10, mobile: split("3"), fixed: split("60"), tollFree: split("80")},
+	{code: "60", region: "MY", minLen: 8, maxLen: 10, trunk: "0", mobile: split("1"), tollFree: split("1300 1800")},
+	{code: "61", region: "AU", minLen: 9, maxLen: 9, trunk: "0", mobile: split("4"), fixed: split("2 3 7 8"), tollFree: split("180")},
+	{code: "62", region: "ID", minLen: 8, maxLen: 12, trunk: "0", mobile: split("8"), tollFree: split("800")},
+	{code: "63", region: "PH", minLen: 8, maxLen: 10, trunk: "0", mobile: split("9"), tollFree: split("1800")},
+	{code: "64", region: "NZ", minLen: 8, maxLen: 10, trunk: "0", mobile: split("2"), tollFree: split("800"), premium: split("900")},
+	{code: "65", region: "SG", minLen: 8, maxLen: 8, mobile: split("8 9"), fixed: split("6"), tollFree: split("800")},
+	{code: "66", region: "TH", minLen: 8, maxLen: 9, trunk: "0", mobile: split("6 8 9"), tollFree: split("1800")},
+	{code: "81", region: "JP", minLen: 9, maxLen: 10, trunk: "0", mobile: split("70 80 90"), tollFree: split("120 800")},
+	{code: "82", region: "KR", minLen: 8, maxLen: 10, trunk: "0", mobile: split("10"), tollFree: split("80")},
+	{code: "84", region: "VN", minLen: 9, maxLen: 10, trunk: "0", mobile: split("3 5 7 8 9"), tollFree: split("1800")},
+	{code: "86", region: "CN", minLen: 5, maxLen: 12, trunk: "0", mobile: split("13 14 15 16 17 18 19"), tollFree: split("400 800")},
+	{code: "90", region: "TR", minLen: 10, maxLen: 10, trunk: "0", mobile: split("5"), tollFree: split("800"), premium: split("900")},
+	{code: "91", region: "IN", minLen: 10, maxLen: 10, trunk: "0", mobile: split("6 7 8 9"), fixed: split("2 3 4 5"), tollFree: split("1800")},
+	{code: "92", region: "PK", minLen: 9, maxLen: 10, trunk: "0", mobile: split("3"), tollFree: split("800"), premium: split("900")},
+	{code: "94", region: "LK", minLen: 9, maxLen: 9, trunk: "0", mobile: split("7")},
+	{code: "212", region: "MA", minLen: 9, maxLen: 9, trunk: "0", mobile: split("6 7"), tollFree: split("80")},
+	{code: "233", region: "GH", minLen: 9, maxLen: 9, trunk: "0", mobile: split("2 5"), fixed: split("3")},
+	{code: "234", region: "NG", minLen: 8, maxLen: 10, trunk: "0", mobile: split("70 80 81 90 91"), tollFree: split("800")},
+	{code: "251", region: "ET", minLen: 9, maxLen: 9, trunk: "0", mobile: split("7 9")},
+	{code: "254", region: "KE", minLen: 9, maxLen: 9, trunk: "0", mobile: split("1 7"), tollFree: split("800")},
+	{code: "255", region: "TZ", minLen: 9, maxLen: 9, trunk: "0", mobile: split("6 7"), tollFree: split("80")},
+	{code: "256", region: "UG", minLen: 9, maxLen: 9, trunk: "0", mobile: split("7"), tollFree: split("800")},
+	{code: "351", region: "PT", minLen: 9, maxLen: 9, mobile: split("9"), fixed: split("2"), tollFree: split("800")},
+	{code: "353", region: "IE", minLen: 7, maxLen: 9, trunk: "0", mobile: split("8"), tollFree: split("1800")},
+	{code: "880", region: "BD", minLen: 10, maxLen: 10, trunk: "0", mobile: split("1")},
+	{code: "962", region: "JO", minLen: 8, maxLen: 9, trunk: "0", mobile: split("7"), tollFree: split("800")},
+	{code: "965", region: "KW", minLen: 8, maxLen: 8, mobile: split("5 6 9"), fixed: split("2")},
+	{code: "966", region: "SA", minLen: 9, maxLen: 9, trunk: "0", mobile: split("5"), fixed: split("1"), tollFree: split("800")},
+	{code: "968", region: "OM", minLen: 8, maxLen: 8, mobile: split("7 9"), fixed: split("2"), tollFree: split("800")},
+	{code: "971", region: "AE", minLen: 8, maxLen: 9, trunk: "0", mobile: split("5"), fixed: split("2 3 4 6 7 9"), tollFree: split("800"), premium: split("900")},
+	{code: "972", region: "IL", minLen: 8, maxLen: 9, trunk: "0", mobile: split("5"), tollFree: split("1800")},
+	{code: "973", region: "BH", minLen: 8, maxLen: 8, mobile: split("3"), fixed: split("1"), tollFree: split("80")},
+	{code: "974", region: "QA", minLen: 8, maxLen: 8, mobile: split("3 5 6 7"), fixed: split("4"), tollFree: split("800")},
+	{code: "977", region: "NP", minLen: 8, maxLen: 10, trunk: "0", mobile: split("9")},
+}
+
+// fallbackRegions maps the remaining calling codes to their main region. Numbers
+// under them get no length check or line type.
+var fallbackRegions = map[string]string{
+	"211": "SS", "213": "DZ", "216": "TN", "218": "LY", "220": "GM", "221": "SN", "222": "MR",
+	"223": "ML", "224": "GN", "225": "CI", "226": "BF", "227": "NE", "228": "TG", "229": "BJ",
+	"230": "MU", "231": "LR", "232": "SL", "235": "TD", "236": "CF", "237": "CM", "238": "CV",
+	"239": "ST", "240": "GQ", "241": "GA", "242": "CG", "243": "CD", "244": "AO", "245": "GW",
+	"246": "IO", "248": "SC", "249": "SD", "250": "RW", "252": "SO", "253": "DJ", "257": "BI",
+	"258": "MZ", "260": "ZM", "261": "MG", "262": "RE", "263": "ZW", "264": "NA", "265": "MW",
+	"266": "LS", "267": "BW", "268": "SZ", "269": "KM", "290": "SH", "291": "ER", "297": "AW",
+	"298": "FO", "299": "GL",
+	"30": "GR", "36": "HU", "40": "RO", "45": "DK", "47": "NO", "53": "CU", "58": "VE", "93": "AF",
+	"95": "MM", "98": "IR",
+	"350": "GI", "352": "LU", "354": "IS", "355": "AL", "356": "MT", "357": "CY", "358": "FI",
+	"359": "BG", "370": "LT", "371": "LV", "372": "EE", "373": "MD", "374": "AM", "375": "BY",
+	"376": "AD", "377": "MC", "378": "SM", "380": "UA", "381": "RS", "382": "ME", "383": "XK",
+	"385": "HR", "386": "SI", "387": "BA", "389": "MK", "420": "CZ", "421": "SK", "423": "LI",
+	"500": "FK", "501": "BZ", "502": "GT", "503": "SV", "504": "HN", "505": "NI", "506": "CR",
+	"507": "PA", "508": "PM", "509": "HT", "590": "GP", "591": "BO", "592": "GY", "593": "EC",
+	"594": "GF", "595": "PY", "596": "MQ", "597": "SR", "598": "UY", "599": "CW",
+	"670": "TL", "672": "NF", "673": "BN", "674": "NR", "675": "PG", "676": "TO", "677": "SB",
+	"678": "VU", "679": "FJ", "680": "PW", "681": "WF", "682": "CK", "683": "NU", "685": "WS",
+	"686": "KI", "687": "NC", "688": "TV", "689": "PF", "690": "TK", "691": "FM", "692": "MH",
+	"850": "KP", "852": "HK", "853": "MO", "855": "KH", "856": "LA", "886": "TW",
+	"960": "MV", "961": "LB", "963": "SY", "964": "IQ", "967": "YE", "970": "PS", "975": "BT",
+	"976": "MN", "992": "TJ", "993": "TM", "994": "AZ", "995": "GE", "996": "KG", "998": "UZ",
+}
+
+var (
+	codePlans   = map[string]*plan{}
+	regionPlans = map[string]*plan{} // Also holds fallback regions, with only a code
+)
+
+func init() {
+	for _, p := range plans {
+		codePlans[p.code] = p
+		regionPlans[p.region] = p
+	}
+	regionPlans["CA"] = codePlans["1"]
+	regionPlans["KZ"] = codePlans["7"]
+	for code, region := range fallbackRegions {
+		regionPlans[region] = &plan{code: code, region: region}
+	}
+}
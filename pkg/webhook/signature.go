@@ -0,0 +1,248 @@
+// Package webhook provides signing and verification helpers for outbound
+// Whatomate webhooks.
+//
+// Every delivery carries these headers:
+//
+//	X-Webhook-Timestamp:    Unix time (seconds) the request was signed
+//	X-Webhook-Nonce:        Random value unique to the delivery attempt
+//	X-Webhook-Signature-V2: v1=<hex>[,v1=<hex>]
+//	X-Webhook-Signature:    sha256=<hex>  (legacy, body only)
+//
+// Each v1 signature is HMAC-SHA256 over "<timestamp>.<nonce>.<raw body>".
+// While a webhook secret is being rotated the header contains one v1 entry per
+// active secret (new and previous), so receivers can switch secrets at any point
+// during the grace window. The legacy header is signed with the current secret
+// over the body alone and offers no replay protection.
+//
+// Receivers should verify the V2 header, reject stale timestamps and remember
+// nonces for the tolerance window:
+//
+//	verifier := webhook.NewVerifier([]string{os.Getenv("WHATOMATE_WEBHOOK_SECRET")})
+//	http.HandleFunc("/hooks/whatomate", func(w http.ResponseWriter, r *http.Request) {
+//		body, err := verifier.VerifyRequest(r)
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusUnauthorized)
+//			return
+//		}
+//		// body is the raw JSON payload
+//	})
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header names set on outbound webhook requests
+const (
+	HeaderTimestamp   = "X-Webhook-Timestamp"
+	HeaderNonce       = "X-Webhook-Nonce"
+	HeaderSignatureV2 = "X-Webhook-Signature-V2"
+	HeaderSignature   = "X-Webhook-Signature"
+)
+
+// DefaultTolerance is the maximum accepted clock skew between signing and verification
+const DefaultTolerance = 5 * time.Minute
+
+// Verification errors
+var (
+	ErrMissingHeaders   = errors.New("webhook: missing signature headers")
+	ErrInvalidTimestamp = errors.New("webhook: invalid timestamp")
+	ErrTimestampExpired = errors.New("webhook: timestamp outside tolerance")
+	ErrInvalidSignature = errors.New("webhook: signature mismatch")
+	ErrReplayed         = errors.New("webhook: nonce already used")
+)
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<nonce>.<body>"
+func Sign(secret string, timestamp int64, nonce string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write([]byte(nonce))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SignatureHeader builds the X-Webhook-Signature-V2 value with one entry per secret
+func SignatureHeader(secrets []string, timestamp int64, nonce string, body []byte) string {
+	parts := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		parts = append(parts, "v1="+Sign(secret, timestamp, nonce, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// LegacySignature returns the body-only "sha256=<hex>" signature
+func LegacySignature(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// NewNonce returns a random 128-bit hex nonce
+func NewNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// SignRequest sets the timestamp, nonce and signature headers on an outgoing request.
+// secrets[0] is the current secret; any further entries are still-valid previous secrets.
+func SignRequest(header http.Header, secrets []string, body []byte, now time.Time) {
+	if len(secrets) == 0 || secrets[0] == "" {
+		return
+	}
+	timestamp := now.Unix()
+	nonce := NewNonce()
+	header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	header.Set(HeaderNonce, nonce)
+	header.Set(HeaderSignatureV2, SignatureHeader(secrets, timestamp, nonce, body))
+	header.Set(HeaderSignature, LegacySignature(secrets[0], body))
+}
+
+// NonceStore remembers nonces to reject replayed deliveries
+type NonceStore interface {
+	// Seen records the nonce and reports whether it had already been recorded
+	Seen(nonce string, expiresAt time.Time) bool
+}
+
+// MemoryNonceStore is an in-process NonceStore suitable for single-instance receivers
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+// Seen implements NonceStore, pruning expired nonces as it goes
+func (s *MemoryNonceStore) Seen(nonce string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for n, exp := range s.nonces {
+		if now.After(exp) {
+			delete(s.nonces, n)
+		}
+	}
+
+	if _, ok := s.nonces[nonce]; ok {
+		return true
+	}
+	s.nonces[nonce] = expiresAt
+	return false
+}
+
+// Verifier checks signatures on incoming Whatomate webhooks
+type Verifier struct {
+	// Secrets accepted for verification; list both while rotating
+	Secrets []string
+	// Tolerance is the allowed clock skew (DefaultTolerance if zero)
+	Tolerance time.Duration
+	// Nonces rejects replays when set
+	Nonces NonceStore
+	// Now overrides the clock, mainly for tests
+	Now func() time.Time
+}
+
+// NewVerifier creates a verifier with the default tolerance and an in-memory nonce store
+func NewVerifier(secrets []string) *Verifier {
+	return &Verifier{
+		Secrets:   secrets,
+		Tolerance: DefaultTolerance,
+		Nonces:    NewMemoryNonceStore(),
+	}
+}
+
+// Verify checks the headers and raw body of a delivery
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	tsHeader := header.Get(HeaderTimestamp)
+	nonce := header.Get(HeaderNonce)
+	sigHeader := header.Get(HeaderSignatureV2)
+	if tsHeader == "" || nonce == "" || sigHeader == "" {
+		return ErrMissingHeaders
+	}
+
+	timestamp, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	signedAt := time.Unix(timestamp, 0)
+	if now.Sub(signedAt) > tolerance || signedAt.Sub(now) > tolerance {
+		return ErrTimestampExpired
+	}
+
+	if !v.signatureMatches(sigHeader, timestamp, nonce, body) {
+		return ErrInvalidSignature
+	}
+
+	if v.Nonces != nil && v.Nonces.Seen(nonce, signedAt.Add(tolerance)) {
+		return ErrReplayed
+	}
+
+	return nil
+}
+
+// VerifyRequest reads and verifies the request body, then restores it so handlers can read it again
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := v.Verify(r.Header, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (v *Verifier) signatureMatches(sigHeader string, timestamp int64, nonce string, body []byte) bool {
+	for _, part := range strings.Split(sigHeader, ",") {
+		scheme, sig, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || scheme != "v1" {
+			continue
+		}
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			continue
+		}
+		for _, secret := range v.Secrets {
+			if secret == "" {
+				continue
+			}
+			want, _ := hex.DecodeString(Sign(secret, timestamp, nonce, body))
+			if hmac.Equal(got, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
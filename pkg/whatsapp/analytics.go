@@ -0,0 +1,63 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// MessageAnalytics is the number of messages Meta counted as sent and delivered for
+// a WABA in one period
+type MessageAnalytics struct {
+	Start     time.Time
+	End       time.Time
+	Sent      int64
+	Delivered int64
+}
+
+// GetMessageAnalytics fetches daily sent and delivered counts for account's WABA
+// between start and end, limited to phoneNumbers when given. Phone numbers are the
+// display numbers in digits only, e.g. "16505550111". Meta's counts lag by a few
+// hours and only cover messages sent through the Cloud API.
+func (c *Client) GetMessageAnalytics(ctx context.Context, account *Account, start, end time.Time, phoneNumbers []string) ([]MessageAnalytics, error) {
+	fields := fmt.Sprintf("analytics.start(%d).end(%d).granularity(DAY)", start.Unix(), end.Unix())
+	if len(phoneNumbers) > 0 {
+		fields += `.phone_numbers(["` + strings.Join(phoneNumbers, `","`) + `"])`
+	}
+	endpoint := fmt.Sprintf("%s/%s/%s?fields=%s", BaseURL, account.APIVersion, account.BusinessID, url.QueryEscape(fields))
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, endpoint, nil, account)
+	if err != nil {
+		c.Log.Error("Failed to fetch message analytics", "error", err, "waba_id", account.BusinessID)
+		return nil, err
+	}
+
+	var result struct {
+		Analytics struct {
+			DataPoints []struct {
+				Start     int64 `json:"start"`
+				End       int64 `json:"end"`
+				Sent      int64 `json:"sent"`
+				Delivered int64 `json:"delivered"`
+			} `json:"data_points"`
+		} `json:"analytics"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	points := make([]MessageAnalytics, len(result.Analytics.DataPoints))
+	for i, p := range result.Analytics.DataPoints {
+		points[i] = MessageAnalytics{
+			Start:     time.Unix(p.Start, 0).UTC(),
+			End:       time.Unix(p.End, 0).UTC(),
+			Sent:      p.Sent,
+			Delivered: p.Delivered,
+		}
+	}
+	return points, nil
+}
@@ -32,7 +32,7 @@ func (c *Client) CreateCatalog(ctx context.Context, account *Account, name strin
 		"name": name,
 	}
 
-	respBody, err := c.doRequest(ctx, http.MethodPost, apiURL, body, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodPost, apiURL, body, account)
 	if err != nil {
 		return "", err
 	}
@@ -51,7 +51,7 @@ func (c *Client) CreateCatalog(ctx context.Context, account *Account, name strin
 func (c *Client) ListCatalogs(ctx context.Context, account *Account) ([]CatalogInfo, error) {
 	apiURL := c.buildCatalogsURL(account)
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, apiURL, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodGet, apiURL, nil, account)
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +68,7 @@ func (c *Client) ListCatalogs(ctx context.Context, account *Account) ([]CatalogI
 func (c *Client) DeleteCatalog(ctx context.Context, account *Account, catalogID string) error {
 	apiURL := fmt.Sprintf("%s/%s/%s", BaseURL, account.APIVersion, catalogID)
 
-	_, err := c.doRequest(ctx, http.MethodDelete, apiURL, nil, account.AccessToken)
+	_, err := c.doRequest(ctx, http.MethodDelete, apiURL, nil, account)
 	return err
 }
 
@@ -81,7 +81,7 @@ func (c *Client) ListCatalogProducts(ctx context.Context, account *Account, cata
 	params.Add("fields", "id,name,price,currency,url,image_url,retailer_id,description")
 	apiURL = apiURL + "?" + params.Encode()
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, apiURL, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodGet, apiURL, nil, account)
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +114,7 @@ func (c *Client) CreateProduct(ctx context.Context, account *Account, catalogID
 		body["description"] = product.Description
 	}
 
-	respBody, err := c.doRequest(ctx, http.MethodPost, apiURL, body, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodPost, apiURL, body, account)
 	if err != nil {
 		return "", err
 	}
@@ -152,7 +152,7 @@ func (c *Client) UpdateProduct(ctx context.Context, account *Account, productID
 		body["description"] = product.Description
 	}
 
-	_, err := c.doRequest(ctx, http.MethodPost, apiURL, body, account.AccessToken)
+	_, err := c.doRequest(ctx, http.MethodPost, apiURL, body, account)
 	return err
 }
 
@@ -160,6 +160,6 @@ func (c *Client) UpdateProduct(ctx context.Context, account *Account, productID
 func (c *Client) DeleteProduct(ctx context.Context, account *Account, productID string) error {
 	apiURL := c.buildProductURL(account, productID)
 
-	_, err := c.doRequest(ctx, http.MethodDelete, apiURL, nil, account.AccessToken)
+	_, err := c.doRequest(ctx, http.MethodDelete, apiURL, nil, account)
 	return err
 }
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/zerodha/logf"
@@ -23,66 +25,99 @@ const (
 type Client struct {
 	HTTPClient *http.Client
 	Log        logf.Logger
+
+	// ProxyResolver, if set, returns the egress proxy URL for an account's
+	// requests; an empty result falls back to the client's default proxy and an
+	// error fails the request
+	ProxyResolver func(account *Account) (string, error)
+
+	// SendGuard, if set, is asked before every message sent for an account; an
+	// error refuses the send
+	SendGuard func(account *Account) error
+
+	// RequestLogger, if set, records the JSON Graph API calls of the accounts it
+	// is enabled for
+	RequestLogger RequestLogger
+
+	proxy         *url.URL
+	versionWarned sync.Map // Configured versions already logged as resolved to another
 }
 
 // New creates a new WhatsApp client
 func New(log logf.Logger) *Client {
-	return &Client{
-		HTTPClient: &http.Client{
-			Timeout: DefaultTimeout,
-		},
-		Log: log,
-	}
+	return NewWithTimeout(log, DefaultTimeout)
 }
 
 // NewWithTimeout creates a new WhatsApp client with custom timeout
 func NewWithTimeout(log logf.Logger, timeout time.Duration) *Client {
-	return &Client{
-		HTTPClient: &http.Client{
-			Timeout: timeout,
-		},
-		Log: log,
-	}
+	c := &Client{Log: log}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = c.proxyFor
+	c.HTTPClient = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+	return c
 }
 
-// doRequest performs an HTTP request to the Meta API
-func (c *Client) doRequest(ctx context.Context, method, url string, body interface{}, accessToken string) ([]byte, error) {
+// doRequest performs an HTTP request to the Meta API on behalf of account
+func (c *Client) doRequest(ctx context.Context, method, url string, body interface{}, account *Account) ([]byte, error) {
+	if c.SendGuard != nil && method == http.MethodPost && url == c.buildMessagesURL(account) {
+		if err := c.SendGuard(account); err != nil {
+			return nil, err
+		}
+	}
+
+	url, body = c.applyShims(method, url, body, account)
+
 	var reqBody io.Reader
+	var jsonBody []byte
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
 		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	req, err := http.NewRequestWithContext(WithAccount(ctx, account), method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Authorization", "Bearer "+account.AccessToken)
 	req.Header.Set("Content-Type", "application/json")
 
+	started := time.Now()
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		err = fmt.Errorf("request failed: %w", err)
+		c.logRequest(account, method, url, jsonBody, nil, 0, err, started)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		err = fmt.Errorf("failed to read response body: %w", err)
+		c.logRequest(account, method, url, jsonBody, nil, resp.StatusCode, err, started)
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		var apiErr MetaAPIError
 		if err := json.Unmarshal(respBody, &apiErr); err == nil && apiErr.Error.Message != "" {
-			return nil, fmt.Errorf("API error %d: %s", apiErr.Error.Code, apiErr.Error.Message)
+			err = &APIError{StatusCode: resp.StatusCode, Code: apiErr.Error.Code, Message: apiErr.Error.Message}
+			c.logRequest(account, method, url, jsonBody, respBody, resp.StatusCode, err, started)
+			return nil, err
 		}
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+		err = &APIError{StatusCode: resp.StatusCode, Message: string(respBody)}
+		c.logRequest(account, method, url, jsonBody, respBody, resp.StatusCode, err, started)
+		return nil, err
 	}
 
+	c.logRequest(account, method, url, jsonBody, respBody, resp.StatusCode, nil, started)
 	return respBody, nil
 }
 
@@ -109,7 +144,7 @@ type MediaURLResponse struct {
 func (c *Client) GetMediaURL(ctx context.Context, mediaID string, account *Account) (string, error) {
 	url := fmt.Sprintf("%s/%s/%s", BaseURL, account.APIVersion, mediaID)
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account)
 	if err != nil {
 		return "", fmt.Errorf("failed to get media URL: %w", err)
 	}
@@ -126,7 +161,8 @@ func (c *Client) GetMediaURL(ctx context.Context, mediaID string, account *Accou
 	return mediaResp.URL, nil
 }
 
-// DownloadMedia downloads media content from Meta's CDN URL
+// DownloadMedia downloads media content from Meta's CDN URL. Tag ctx with
+// WithAccount to route the download through the account's egress proxy.
 func (c *Client) DownloadMedia(ctx context.Context, mediaURL string, accessToken string) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
 	if err != nil {
@@ -180,7 +216,7 @@ func (c *Client) UploadMedia(ctx context.Context, account *Account, data []byte,
 
 	body.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	req, err := http.NewRequestWithContext(WithAccount(ctx, account), http.MethodPost, url, body)
 	if err != nil {
 		return "", fmt.Errorf("failed to create upload request: %w", err)
 	}
@@ -232,7 +268,7 @@ func (c *Client) SendImageMessage(ctx context.Context, account *Account, phoneNu
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending image message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		return "", fmt.Errorf("failed to send image message: %w", err)
 	}
@@ -268,7 +304,7 @@ func (c *Client) SendDocumentMessage(ctx context.Context, account *Account, phon
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending document message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		return "", fmt.Errorf("failed to send document message: %w", err)
 	}
@@ -303,7 +339,7 @@ func (c *Client) SendVideoMessage(ctx context.Context, account *Account, phoneNu
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending video message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		return "", fmt.Errorf("failed to send video message: %w", err)
 	}
@@ -337,7 +373,7 @@ func (c *Client) SendAudioMessage(ctx context.Context, account *Account, phoneNu
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending audio message", "phone", phoneNumber, "media_id", mediaID)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		return "", fmt.Errorf("failed to send audio message: %w", err)
 	}
@@ -356,6 +392,40 @@ func (c *Client) SendAudioMessage(ctx context.Context, account *Account, phoneNu
 	return messageID, nil
 }
 
+// SendStickerMessage sends a sticker (static or animated WebP) using a media ID
+func (c *Client) SendStickerMessage(ctx context.Context, account *Account, phoneNumber, mediaID string) (string, error) {
+	payload := map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                phoneNumber,
+		"type":              "sticker",
+		"sticker": map[string]interface{}{
+			"id": mediaID,
+		},
+	}
+
+	url := c.buildMessagesURL(account)
+	c.Log.Debug("Sending sticker message", "phone", phoneNumber, "media_id", mediaID)
+
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
+	if err != nil {
+		return "", fmt.Errorf("failed to send sticker message: %w", err)
+	}
+
+	var resp MetaAPIResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(resp.Messages) == 0 {
+		return "", fmt.Errorf("no message ID in response")
+	}
+
+	messageID := resp.Messages[0].ID
+	c.Log.Info("Sticker message sent", "message_id", messageID, "phone", phoneNumber)
+	return messageID, nil
+}
+
 // MarkMessageRead sends a read receipt for a message
 func (c *Client) MarkMessageRead(ctx context.Context, account *Account, messageID string) error {
 	payload := map[string]interface{}{
@@ -367,7 +437,7 @@ func (c *Client) MarkMessageRead(ctx context.Context, account *Account, messageI
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending read receipt", "message_id", messageID)
 
-	_, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	_, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		return fmt.Errorf("failed to send read receipt: %w", err)
 	}
@@ -0,0 +1,179 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// APIError is a non-200 response from the Graph API
+type APIError struct {
+	StatusCode int    // HTTP status of the response
+	Code       int    // Meta error code, 0 when the body wasn't a Meta error
+	Message    string // Meta error message, or the raw body
+}
+
+func (e *APIError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("API error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// retryableCodes are Meta error codes for throttling and temporary outages, which
+// may succeed if the request is sent again
+var retryableCodes = map[int]bool{
+	1:      true, // API unknown
+	2:      true, // API service temporarily unavailable
+	4:      true, // API too many calls
+	17:     true, // API user too many calls
+	32:     true, // Page-level throttling
+	80007:  true, // WhatsApp Business Account rate limit hit
+	130429: true, // Cloud API throughput reached
+	131000: true, // Something went wrong
+	131016: true, // Service unavailable
+	131056: true, // Pair rate limit: too many messages to the same number
+	133004: true, // Server temporarily unavailable
+}
+
+// Categories of failed sends
+const (
+	FailureInvalidNumber = "invalid_number" // The number can't receive WhatsApp messages
+	FailurePolicy        = "policy"         // Meta's policies rejected the message, its template or the account
+	FailureRateLimit     = "rate_limit"     // Throughput, pair or per-user limits
+	FailureUnknown       = "unknown"        // Anything else, including network errors
+)
+
+// FailureCategories lists every category of failed sends
+var FailureCategories = []string{FailureInvalidNumber, FailurePolicy, FailureRateLimit, FailureUnknown}
+
+// failureCategories are the Meta error codes of each category but FailureUnknown
+var failureCategories = map[int]string{
+	1013:   FailureInvalidNumber, // User is invalid
+	131021: FailureInvalidNumber, // Recipient can't be the sender
+	131026: FailureInvalidNumber, // Message undeliverable
+	131030: FailureInvalidNumber, // Recipient not in the test number's allowed list
+	368:    FailurePolicy,        // Temporarily blocked for policy violations
+	130497: FailurePolicy,        // Account restricted from messaging users in the country
+	131031: FailurePolicy,        // Account locked
+	131047: FailurePolicy,        // More than 24 hours since the customer last replied
+	131048: FailurePolicy,        // Spam rate limit, from the account's quality rating
+	131050: FailurePolicy,        // User stopped marketing messages
+	132007: FailurePolicy,        // Template format policy violated
+	132015: FailurePolicy,        // Template paused
+	132016: FailurePolicy,        // Template disabled
+	4:      FailureRateLimit,
+	17:     FailureRateLimit,
+	32:     FailureRateLimit,
+	80007:  FailureRateLimit,
+	130429: FailureRateLimit,
+	131049: FailureRateLimit, // Per-user marketing message limit
+	131056: FailureRateLimit,
+}
+
+// FailureCategory returns the category of a failure with the given Meta error code
+func FailureCategory(code int) string {
+	if category, ok := failureCategories[code]; ok {
+		return category
+	}
+	return FailureUnknown
+}
+
+// ClassifyFailure returns the Meta error code of a failed send, 0 when err isn't a
+// Meta error, and its category
+func ClassifyFailure(err error) (int, string) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return 0, FailureUnknown
+	}
+	if apiErr.Code == 0 && apiErr.StatusCode == http.StatusTooManyRequests {
+		return 0, FailureRateLimit
+	}
+	return apiErr.Code, FailureCategory(apiErr.Code)
+}
+
+// PermanentFailures are the categories of sends that fail again however often
+// they're retried
+var PermanentFailures = []string{FailureInvalidNumber, FailurePolicy}
+
+// IsPermanentFailure reports whether category is one of PermanentFailures
+func IsPermanentFailure(category string) bool {
+	return slices.Contains(PermanentFailures, category)
+}
+
+// IsRetryable reports whether a failed request may succeed if sent again: network
+// errors and timeouts, 429 and 5xx responses, and Meta's throttling and outage
+// codes. Anything else, like an invalid number or a rejected template, is permanent.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if retryableCodes[apiErr.Code] {
+			return true
+		}
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return false
+}
+
+// Retry policy for sends
+const (
+	SendAttempts     = 4 // Including the first
+	sendRetryBase    = 500 * time.Millisecond
+	sendRetryCeiling = 8 * time.Second // Longest wait between two attempts
+)
+
+// WithRetry calls fn until it succeeds, fails with an error IsRetryable rejects, or
+// SendAttempts are used up, waiting a jittered exponential backoff between attempts.
+// It returns fn's last error, or ctx's if it is cancelled while waiting.
+func WithRetry[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	for attempt := 0; attempt < SendAttempts; attempt++ {
+		if attempt > 0 {
+			if err := sleepCtx(ctx, retryBackoff(attempt)); err != nil {
+				return result, err
+			}
+		}
+		result, err = fn()
+		if err == nil || !IsRetryable(err) {
+			return result, err
+		}
+	}
+	return result, err
+}
+
+// retryBackoff returns the wait before the given retry: sendRetryBase doubled for
+// each earlier retry and capped at sendRetryCeiling, then jittered down by up to
+// half so workers that failed together don't retry together.
+func retryBackoff(attempt int) time.Duration {
+	ceiling := min(sendRetryBase<<(attempt-1), sendRetryCeiling)
+	return ceiling/2 + rand.N(ceiling/2+1)
+}
+
+// sleepCtx sleeps for d, returning early with ctx's error if it is cancelled
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -72,7 +72,7 @@ func (c *Client) CreateFlow(ctx context.Context, account *Account, name string,
 
 	c.Log.Info("Creating flow in Meta", "name", name, "categories", categories, "url", url, "business_id", account.BusinessID)
 
-	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account)
 	if err != nil {
 		c.Log.Error("Failed to create flow", "error", err, "name", name, "url", url)
 		return "", err
@@ -128,7 +128,7 @@ func (c *Client) UpdateFlowJSON(ctx context.Context, account *Account, flowID st
 	writer.Close()
 
 	// Create request
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
+	req, err := http.NewRequestWithContext(WithAccount(ctx, account), http.MethodPost, url, &buf)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -179,7 +179,7 @@ func (c *Client) PublishFlow(ctx context.Context, account *Account, flowID strin
 
 	c.Log.Info("Publishing flow", "flow_id", flowID)
 
-	respBody, err := c.doRequest(ctx, http.MethodPost, url, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodPost, url, nil, account)
 	if err != nil {
 		c.Log.Error("Failed to publish flow", "error", err, "flow_id", flowID)
 		return err
@@ -204,7 +204,7 @@ func (c *Client) DeprecateFlow(ctx context.Context, account *Account, flowID str
 
 	c.Log.Info("Deprecating flow", "flow_id", flowID)
 
-	respBody, err := c.doRequest(ctx, http.MethodPost, url, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodPost, url, nil, account)
 	if err != nil {
 		c.Log.Error("Failed to deprecate flow", "error", err, "flow_id", flowID)
 		return err
@@ -229,7 +229,7 @@ func (c *Client) DeleteFlow(ctx context.Context, account *Account, flowID string
 
 	c.Log.Info("Deleting flow from Meta", "flow_id", flowID)
 
-	_, err := c.doRequest(ctx, http.MethodDelete, url, nil, account.AccessToken)
+	_, err := c.doRequest(ctx, http.MethodDelete, url, nil, account)
 	if err != nil {
 		c.Log.Error("Failed to delete flow", "error", err, "flow_id", flowID)
 		return err
@@ -243,7 +243,7 @@ func (c *Client) DeleteFlow(ctx context.Context, account *Account, flowID string
 func (c *Client) GetFlow(ctx context.Context, account *Account, flowID string) (*FlowGetResponse, error) {
 	url := fmt.Sprintf("%s/%s/%s?fields=id,name,status,categories,preview.invalidate(false)", BaseURL, account.APIVersion, flowID)
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account)
 	if err != nil {
 		c.Log.Error("Failed to get flow", "error", err, "flow_id", flowID)
 		return nil, err
@@ -273,7 +273,7 @@ func (c *Client) GetFlowAssets(ctx context.Context, account *Account, flowID str
 
 	c.Log.Info("Fetching flow assets", "flow_id", flowID, "url", assetsURL)
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, assetsURL, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodGet, assetsURL, nil, account)
 	if err != nil {
 		c.Log.Error("Failed to get flow assets", "error", err, "flow_id", flowID)
 		return nil, err
@@ -299,7 +299,7 @@ func (c *Client) GetFlowAssets(ctx context.Context, account *Account, flowID str
 	}
 
 	// Download the flow JSON
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	req, err := http.NewRequestWithContext(WithAccount(ctx, account), http.MethodGet, downloadURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create download request: %w", err)
 	}
@@ -334,7 +334,7 @@ func (c *Client) GetFlowAssets(ctx context.Context, account *Account, flowID str
 func (c *Client) ListFlows(ctx context.Context, account *Account) ([]FlowGetResponse, error) {
 	url := fmt.Sprintf("%s?fields=id,name,status,categories,preview.invalidate(false)", c.buildFlowsURL(account))
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account)
 	if err != nil {
 		c.Log.Error("Failed to list flows", "error", err)
 		return nil, err
@@ -22,7 +22,7 @@ func (c *Client) SendTextMessage(ctx context.Context, account *Account, phoneNum
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending text message", "phone", phoneNumber, "url", url)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		c.Log.Error("Failed to send text message", "error", err, "phone", phoneNumber)
 		return "", fmt.Errorf("failed to send text message: %w", err)
@@ -42,6 +42,39 @@ func (c *Client) SendTextMessage(ctx context.Context, account *Account, phoneNum
 	return messageID, nil
 }
 
+// SendContactsMessage sends one or more contact cards to a phone number
+func (c *Client) SendContactsMessage(ctx context.Context, account *Account, phoneNumber string, contacts []ContactCard) (string, error) {
+	payload := map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                phoneNumber,
+		"type":              "contacts",
+		"contacts":          contacts,
+	}
+
+	url := c.buildMessagesURL(account)
+	c.Log.Debug("Sending contacts message", "phone", phoneNumber, "count", len(contacts))
+
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
+	if err != nil {
+		c.Log.Error("Failed to send contacts message", "error", err, "phone", phoneNumber)
+		return "", fmt.Errorf("failed to send contacts message: %w", err)
+	}
+
+	var resp MetaAPIResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(resp.Messages) == 0 {
+		return "", fmt.Errorf("no message ID in response")
+	}
+
+	messageID := resp.Messages[0].ID
+	c.Log.Info("Contacts message sent", "message_id", messageID, "phone", phoneNumber)
+	return messageID, nil
+}
+
 // SendInteractiveButtons sends an interactive message with buttons or list
 // If buttons <= 3, sends as buttons; if 4-10, sends as list
 func (c *Client) SendInteractiveButtons(ctx context.Context, account *Account, phoneNumber, bodyText string, buttons []Button) (string, error) {
@@ -122,7 +155,7 @@ func (c *Client) SendInteractiveButtons(ctx context.Context, account *Account, p
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending interactive message", "phone", phoneNumber, "button_count", len(buttons))
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		c.Log.Error("Failed to send interactive message", "error", err, "phone", phoneNumber)
 		return "", fmt.Errorf("failed to send interactive message: %w", err)
@@ -194,7 +227,7 @@ func (c *Client) SendTemplateMessage(ctx context.Context, account *Account, phon
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending template message", "phone", phoneNumber, "template", templateName)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		c.Log.Error("Failed to send template message", "error", err, "phone", phoneNumber, "template", templateName)
 		return "", fmt.Errorf("failed to send template message: %w", err)
@@ -237,7 +270,7 @@ func (c *Client) SendTemplateMessageWithComponents(ctx context.Context, account
 	url := c.buildMessagesURL(account)
 	c.Log.Debug("Sending template message with components", "phone", phoneNumber, "template", templateName)
 
-	respBody, err := c.doRequest(ctx, "POST", url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, "POST", url, payload, account)
 	if err != nil {
 		c.Log.Error("Failed to send template message", "error", err, "phone", phoneNumber, "template", templateName)
 		return "", fmt.Errorf("failed to send template message: %w", err)
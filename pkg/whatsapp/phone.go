@@ -0,0 +1,245 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// MigratePhoneNumber starts migrating a phone number that is registered on another
+// WABA to account's WABA (account.BusinessID) and returns the number's phone number
+// ID on the new WABA. The number then has to be verified and registered again.
+func (c *Client) MigratePhoneNumber(ctx context.Context, account *Account, countryCode, phoneNumber string) (string, error) {
+	url := fmt.Sprintf("%s/%s/%s/phone_numbers", BaseURL, account.APIVersion, account.BusinessID)
+
+	payload := map[string]interface{}{
+		"cc":                   countryCode,
+		"phone_number":         phoneNumber,
+		"migrate_phone_number": true,
+	}
+
+	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account)
+	if err != nil {
+		c.Log.Error("Failed to start phone number migration", "error", err, "waba_id", account.BusinessID)
+		return "", err
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.ID == "" {
+		return "", fmt.Errorf("no phone number ID in response")
+	}
+
+	c.Log.Info("Phone number migration started", "phone_id", result.ID, "waba_id", account.BusinessID)
+	return result.ID, nil
+}
+
+// RequestVerificationCode asks Meta to send a verification code to account's phone
+// number by SMS or VOICE
+func (c *Client) RequestVerificationCode(ctx context.Context, account *Account, method, language string) error {
+	url := fmt.Sprintf("%s/%s/%s/request_code", BaseURL, account.APIVersion, account.PhoneID)
+
+	payload := map[string]interface{}{
+		"code_method": method,
+		"language":    language,
+	}
+
+	if _, err := c.doRequest(ctx, http.MethodPost, url, payload, account); err != nil {
+		c.Log.Error("Failed to request verification code", "error", err, "phone_id", account.PhoneID)
+		return err
+	}
+	return nil
+}
+
+// VerifyCode verifies account's phone number with the code Meta sent
+func (c *Client) VerifyCode(ctx context.Context, account *Account, code string) error {
+	url := fmt.Sprintf("%s/%s/%s/verify_code", BaseURL, account.APIVersion, account.PhoneID)
+
+	if _, err := c.doRequest(ctx, http.MethodPost, url, map[string]interface{}{"code": code}, account); err != nil {
+		c.Log.Error("Failed to verify code", "error", err, "phone_id", account.PhoneID)
+		return err
+	}
+	return nil
+}
+
+// RegisterPhoneNumber registers account's phone number for Cloud API messaging,
+// setting its two-step verification PIN
+func (c *Client) RegisterPhoneNumber(ctx context.Context, account *Account, pin string) error {
+	url := fmt.Sprintf("%s/%s/%s/register", BaseURL, account.APIVersion, account.PhoneID)
+
+	payload := map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"pin":               pin,
+	}
+
+	if _, err := c.doRequest(ctx, http.MethodPost, url, payload, account); err != nil {
+		c.Log.Error("Failed to register phone number", "error", err, "phone_id", account.PhoneID)
+		return err
+	}
+
+	c.Log.Info("Phone number registered", "phone_id", account.PhoneID)
+	return nil
+}
+
+// SubscribeApp subscribes the app that owns account's access token to webhooks from
+// account's WABA
+func (c *Client) SubscribeApp(ctx context.Context, account *Account) error {
+	url := fmt.Sprintf("%s/%s/%s/subscribed_apps", BaseURL, account.APIVersion, account.BusinessID)
+
+	if _, err := c.doRequest(ctx, http.MethodPost, url, nil, account); err != nil {
+		c.Log.Error("Failed to subscribe app to WABA", "error", err, "waba_id", account.BusinessID)
+		return err
+	}
+
+	c.Log.Info("App subscribed to WABA", "waba_id", account.BusinessID)
+	return nil
+}
+
+// UnsubscribeApp stops webhooks from account's WABA reaching the app that owns
+// account's access token
+func (c *Client) UnsubscribeApp(ctx context.Context, account *Account) error {
+	url := fmt.Sprintf("%s/%s/%s/subscribed_apps", BaseURL, account.APIVersion, account.BusinessID)
+
+	if _, err := c.doRequest(ctx, http.MethodDelete, url, nil, account); err != nil {
+		c.Log.Error("Failed to unsubscribe app from WABA", "error", err, "waba_id", account.BusinessID)
+		return err
+	}
+
+	c.Log.Info("App unsubscribed from WABA", "waba_id", account.BusinessID)
+	return nil
+}
+
+// SubscribedApp is an app receiving webhooks from a WABA
+type SubscribedApp struct {
+	ID                  string
+	Name                string
+	OverrideCallbackURI string // Empty when the app's own callback URL is used
+}
+
+// GetSubscribedApps lists the apps subscribed to webhooks from account's WABA
+func (c *Client) GetSubscribedApps(ctx context.Context, account *Account) ([]SubscribedApp, error) {
+	url := fmt.Sprintf("%s/%s/%s/subscribed_apps", BaseURL, account.APIVersion, account.BusinessID)
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account)
+	if err != nil {
+		c.Log.Error("Failed to fetch WABA subscribed apps", "error", err, "waba_id", account.BusinessID)
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			App struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"whatsapp_business_api_data"`
+			OverrideCallbackURI string `json:"override_callback_uri"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	apps := make([]SubscribedApp, len(result.Data))
+	for i, d := range result.Data {
+		apps[i] = SubscribedApp{ID: d.App.ID, Name: d.App.Name, OverrideCallbackURI: d.OverrideCallbackURI}
+	}
+	return apps, nil
+}
+
+// SubscribeAppWithCallback subscribes the app that owns account's access token to
+// webhooks from account's WABA, delivering them to callbackURL instead of the app's
+// own callback URL. Meta verifies the URL with verifyToken before accepting it.
+func (c *Client) SubscribeAppWithCallback(ctx context.Context, account *Account, callbackURL, verifyToken string) error {
+	url := fmt.Sprintf("%s/%s/%s/subscribed_apps", BaseURL, account.APIVersion, account.BusinessID)
+
+	payload := map[string]interface{}{
+		"override_callback_uri": callbackURL,
+		"verify_token":          verifyToken,
+	}
+
+	if _, err := c.doRequest(ctx, http.MethodPost, url, payload, account); err != nil {
+		c.Log.Error("Failed to subscribe app to WABA with callback", "error", err, "waba_id", account.BusinessID)
+		return err
+	}
+
+	c.Log.Info("App subscribed to WABA with callback override", "waba_id", account.BusinessID)
+	return nil
+}
+
+// PhoneNumberStatus is what Meta reports about a phone number's display name and
+// standing
+type PhoneNumberStatus struct {
+	DisplayPhoneNumber      string
+	VerifiedName            string
+	NameStatus              string // e.g. APPROVED, PENDING_REVIEW, DECLINED
+	QualityRating           string
+	MessagingLimitTier      string
+	OfficialBusinessAccount string // Green tick status, e.g. NOT_STARTED, PENDING, APPROVED
+}
+
+// GetPhoneNumberStatus fetches the display name, quality and official business
+// account status of account's phone number
+func (c *Client) GetPhoneNumberStatus(ctx context.Context, account *Account) (*PhoneNumberStatus, error) {
+	url := fmt.Sprintf("%s/%s/%s?fields=display_phone_number,verified_name,name_status,quality_rating,messaging_limit_tier,is_official_business_account,official_business_account",
+		BaseURL, account.APIVersion, account.PhoneID)
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account)
+	if err != nil {
+		c.Log.Error("Failed to fetch phone number status", "error", err, "phone_id", account.PhoneID)
+		return nil, err
+	}
+
+	var result struct {
+		DisplayPhoneNumber        string `json:"display_phone_number"`
+		VerifiedName              string `json:"verified_name"`
+		NameStatus                string `json:"name_status"`
+		QualityRating             string `json:"quality_rating"`
+		MessagingLimitTier        string `json:"messaging_limit_tier"`
+		IsOfficialBusinessAccount bool   `json:"is_official_business_account"`
+		OfficialBusinessAccount   struct {
+			Status string `json:"oba_status"`
+		} `json:"official_business_account"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	status := &PhoneNumberStatus{
+		DisplayPhoneNumber:      result.DisplayPhoneNumber,
+		VerifiedName:            result.VerifiedName,
+		NameStatus:              result.NameStatus,
+		QualityRating:           result.QualityRating,
+		MessagingLimitTier:      result.MessagingLimitTier,
+		OfficialBusinessAccount: result.OfficialBusinessAccount.Status,
+	}
+	// The oba_status isn't returned to every app; the flag still tells approved apart
+	if status.OfficialBusinessAccount == "" && result.IsOfficialBusinessAccount {
+		status.OfficialBusinessAccount = "APPROVED"
+	}
+	return status, nil
+}
+
+// GetBusinessVerificationStatus fetches the verification status of the business
+// that owns account's WABA, e.g. verified, pending or not_verified
+func (c *Client) GetBusinessVerificationStatus(ctx context.Context, account *Account) (string, error) {
+	url := fmt.Sprintf("%s/%s/%s?fields=business_verification_status", BaseURL, account.APIVersion, account.BusinessID)
+
+	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account)
+	if err != nil {
+		c.Log.Error("Failed to fetch business verification status", "error", err, "waba_id", account.BusinessID)
+		return "", err
+	}
+
+	var result struct {
+		BusinessVerificationStatus string `json:"business_verification_status"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.BusinessVerificationStatus, nil
+}
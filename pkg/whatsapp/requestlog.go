@@ -0,0 +1,119 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redacted replaces secrets in logged requests and responses
+const Redacted = "[REDACTED]"
+
+// MaxLoggedBody is how much of a request or response body is kept in an APICall
+const MaxLoggedBody = 16 * 1024
+
+// APICall is a Graph API request and its outcome, with access tokens and other
+// secrets redacted
+type APICall struct {
+	Method       string
+	URL          string
+	RequestBody  string
+	StatusCode   int // 0 when no response was received
+	ResponseBody string
+	Error        string
+	Duration     time.Duration
+}
+
+// RequestLogger records Graph API calls for debugging. LogRequest is only called
+// for accounts Enabled reports true for.
+type RequestLogger interface {
+	Enabled(account *Account) bool
+	LogRequest(account *Account, call *APICall)
+}
+
+// sensitiveFields are JSON keys whose values are never logged
+var sensitiveFields = map[string]bool{
+	"access_token":  true,
+	"client_secret": true,
+	"app_secret":    true,
+	"password":      true,
+	"pin":           true,
+	"token":         true,
+}
+
+// logRequest passes a finished call to the client's RequestLogger, if it has one
+// and it is enabled for account
+func (c *Client) logRequest(account *Account, method, rawURL string, reqBody, respBody []byte, status int, err error, started time.Time) {
+	if c.RequestLogger == nil || !c.RequestLogger.Enabled(account) {
+		return
+	}
+	call := &APICall{
+		Method:       method,
+		URL:          redactURL(rawURL),
+		RequestBody:  Redacted,
+		StatusCode:   status,
+		ResponseBody: redactBody(respBody),
+		Duration:     time.Since(started),
+	}
+	// A verification code is the whole body, under a key too common to redact elsewhere
+	if !strings.HasSuffix(strings.SplitN(rawURL, "?", 2)[0], "/verify_code") {
+		call.RequestBody = redactBody(reqBody)
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	c.RequestLogger.LogRequest(account, call)
+}
+
+// redactURL hides secrets passed as query parameters
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	query := u.Query()
+	for key := range query {
+		if sensitiveFields[strings.ToLower(key)] {
+			query.Set(key, Redacted)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactBody hides the values of sensitive JSON fields and truncates the body to
+// MaxLoggedBody. Bodies that aren't JSON are kept as they are, truncated.
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err == nil {
+		if redacted, err := json.Marshal(redactValue(v)); err == nil {
+			body = redacted
+		}
+	}
+	if len(body) > MaxLoggedBody {
+		return string(body[:MaxLoggedBody]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, field := range val {
+			if sensitiveFields[strings.ToLower(k)] {
+				val[k] = Redacted
+			} else {
+				val[k] = redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = redactValue(val[i])
+		}
+	}
+	return v
+}
@@ -0,0 +1,108 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// A shim adapts request bodies to the Graph API version they are sent with, so the
+// rest of the client builds requests one way whichever version an account is on.
+// Add one when Meta renames or drops a field, limited to the versions that need it.
+type shim struct {
+	name    string
+	from    string // First version the shim applies to; "" for all older versions
+	before  string // Version the shim stops applying at; "" for all newer versions
+	matches func(method, path string) bool
+	apply   func(body map[string]interface{})
+}
+
+// legacyTemplateCategories maps template categories Meta retired to their
+// replacements; templates synced before the change may still carry them
+var legacyTemplateCategories = map[string]string{
+	"TRANSACTIONAL": "UTILITY",
+	"OTP":           "AUTHENTICATION",
+}
+
+var shims = []shim{
+	{
+		name: "legacy template categories",
+		matches: func(method, path string) bool {
+			return method == http.MethodPost && strings.HasSuffix(path, "/message_templates")
+		},
+		apply: func(body map[string]interface{}) {
+			if category, ok := body["category"].(string); ok {
+				if replacement, ok := legacyTemplateCategories[strings.ToUpper(category)]; ok {
+					body["category"] = replacement
+				}
+			}
+		},
+	},
+}
+
+func (s *shim) appliesTo(version string) bool {
+	if s.from != "" && CompareAPIVersions(version, s.from) < 0 {
+		return false
+	}
+	if s.before != "" && CompareAPIVersions(version, s.before) >= 0 {
+		return false
+	}
+	return true
+}
+
+// applyShims returns the request URL with the version it will be sent with and the
+// body adapted to that version. Accounts past their version's sunset are sent with
+// the oldest available version, which the first such request logs.
+func (c *Client) applyShims(method, url string, body interface{}, account *Account) (string, interface{}) {
+	prefix := BaseURL + "/" + account.APIVersion + "/"
+	if !strings.HasPrefix(url, prefix) {
+		return url, body
+	}
+	version := ResolveAPIVersion(account.APIVersion)
+	path := url[len(prefix)-1:]
+	if version != account.APIVersion {
+		url = BaseURL + "/" + version + path
+		if _, warned := c.versionWarned.LoadOrStore(account.APIVersion, true); !warned {
+			c.Log.Warn("Graph API version resolved to another version",
+				"configured", account.APIVersion, "used", version)
+		}
+	}
+
+	var fields map[string]interface{}
+	for i := range shims {
+		s := &shims[i]
+		if !s.appliesTo(version) || !s.matches(method, strings.SplitN(path, "?", 2)[0]) {
+			continue
+		}
+		if fields == nil {
+			if fields = bodyFields(body); fields == nil {
+				return url, body
+			}
+		}
+		s.apply(fields)
+	}
+	if fields != nil {
+		return url, fields
+	}
+	return url, body
+}
+
+// bodyFields returns a JSON object body as a map shims can change, or nil when the
+// body isn't an object
+func bodyFields(body interface{}) map[string]interface{} {
+	if body == nil {
+		return nil
+	}
+	if fields, ok := body.(map[string]interface{}); ok {
+		return fields
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
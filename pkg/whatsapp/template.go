@@ -158,7 +158,7 @@ func (c *Client) SubmitTemplate(ctx context.Context, account *Account, template
 
 	c.Log.Info("Submitting template to Meta", "url", url, "name", template.Name)
 
-	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account.AccessToken)
+	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account)
 	if err != nil {
 		c.Log.Error("Failed to submit template", "error", err, "name", template.Name)
 		return "", err
@@ -173,30 +173,64 @@ func (c *Client) SubmitTemplate(ctx context.Context, account *Account, template
 	return result.ID, nil
 }
 
-// FetchTemplates fetches all templates from Meta's API
+// FetchTemplates fetches all templates from Meta's API, following pagination
 func (c *Client) FetchTemplates(ctx context.Context, account *Account) ([]MetaTemplate, error) {
 	url := fmt.Sprintf("%s?limit=100", c.buildTemplatesURL(account))
 
-	respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account.AccessToken)
+	var templates []MetaTemplate
+	for url != "" {
+		respBody, err := c.doRequest(ctx, http.MethodGet, url, nil, account)
+		if err != nil {
+			c.Log.Error("Failed to fetch templates", "error", err)
+			return nil, err
+		}
+
+		var result TemplateListResponse
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		templates = append(templates, result.Data...)
+		url = result.Paging.Next
+	}
+
+	c.Log.Info("Fetched templates from Meta", "count", len(templates))
+	return templates, nil
+}
+
+// CopyTemplate creates a template fetched from one WABA on another WABA, keeping its
+// name, language, category and components (including examples) as Meta returned them.
+// Media header examples are handles scoped to the source app and usually have to be
+// uploaded again before Meta accepts the copy.
+func (c *Client) CopyTemplate(ctx context.Context, account *Account, template *MetaTemplate) (*TemplateResponse, error) {
+	url := c.buildTemplatesURL(account)
+
+	payload := map[string]interface{}{
+		"name":       template.Name,
+		"language":   template.Language,
+		"category":   template.Category,
+		"components": template.Components,
+	}
+
+	respBody, err := c.doRequest(ctx, http.MethodPost, url, payload, account)
 	if err != nil {
-		c.Log.Error("Failed to fetch templates", "error", err)
+		c.Log.Error("Failed to copy template", "error", err, "name", template.Name, "language", template.Language)
 		return nil, err
 	}
 
-	var result TemplateListResponse
+	var result TemplateResponse
 	if err := json.Unmarshal(respBody, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	c.Log.Info("Fetched templates from Meta", "count", len(result.Data))
-	return result.Data, nil
+	c.Log.Info("Template copied", "template_id", result.ID, "name", template.Name, "language", template.Language)
+	return &result, nil
 }
 
 // DeleteTemplate deletes a template from Meta's API
 func (c *Client) DeleteTemplate(ctx context.Context, account *Account, templateName string) error {
 	url := fmt.Sprintf("%s?name=%s", c.buildTemplatesURL(account), templateName)
 
-	_, err := c.doRequest(ctx, http.MethodDelete, url, nil, account.AccessToken)
+	_, err := c.doRequest(ctx, http.MethodDelete, url, nil, account)
 	if err != nil {
 		c.Log.Error("Failed to delete template", "error", err, "template", templateName)
 		return err
@@ -1,6 +1,11 @@
 package whatsapp
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Account represents WhatsApp Business Account credentials
 type Account struct {
@@ -16,6 +21,98 @@ type Button struct {
 	Title string `json:"title"`
 }
 
+// ContactCard represents a vCard-style contact shared in a contacts message
+// The same shape is used for inbound webhooks and outbound sends
+type ContactCard struct {
+	Name      ContactName      `json:"name"`
+	Phones    []ContactPhone   `json:"phones,omitempty"`
+	Emails    []ContactEmail   `json:"emails,omitempty"`
+	Org       *ContactOrg      `json:"org,omitempty"`
+	URLs      []ContactURL     `json:"urls,omitempty"`
+	Addresses []ContactAddress `json:"addresses,omitempty"`
+	Birthday  string           `json:"birthday,omitempty"` // YYYY-MM-DD
+}
+
+// ContactName represents the name of a shared contact (formatted_name is required)
+type ContactName struct {
+	FormattedName string `json:"formatted_name"`
+	FirstName     string `json:"first_name,omitempty"`
+	LastName      string `json:"last_name,omitempty"`
+	MiddleName    string `json:"middle_name,omitempty"`
+	Prefix        string `json:"prefix,omitempty"`
+	Suffix        string `json:"suffix,omitempty"`
+}
+
+// ContactPhone represents a phone number of a shared contact
+type ContactPhone struct {
+	Phone string `json:"phone,omitempty"`
+	Type  string `json:"type,omitempty"`  // CELL, MAIN, IPHONE, HOME, WORK
+	WaID  string `json:"wa_id,omitempty"` // Set when the number is on WhatsApp
+}
+
+// ContactEmail represents an email address of a shared contact
+type ContactEmail struct {
+	Email string `json:"email,omitempty"`
+	Type  string `json:"type,omitempty"` // HOME, WORK
+}
+
+// ContactOrg represents the organization of a shared contact
+type ContactOrg struct {
+	Company    string `json:"company,omitempty"`
+	Department string `json:"department,omitempty"`
+	Title      string `json:"title,omitempty"`
+}
+
+// ContactURL represents a website of a shared contact
+type ContactURL struct {
+	URL  string `json:"url,omitempty"`
+	Type string `json:"type,omitempty"` // HOME, WORK
+}
+
+// ContactAddress represents a postal address of a shared contact
+type ContactAddress struct {
+	Street      string `json:"street,omitempty"`
+	City        string `json:"city,omitempty"`
+	State       string `json:"state,omitempty"`
+	Zip         string `json:"zip,omitempty"`
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+	Type        string `json:"type,omitempty"` // HOME, WORK
+}
+
+// OrderMessage is the order a customer sends from a catalog or cart
+type OrderMessage struct {
+	CatalogID    string      `json:"catalog_id"`
+	Text         string      `json:"text,omitempty"`
+	ProductItems []OrderItem `json:"product_items"`
+}
+
+// OrderItem is one product line of an order message
+type OrderItem struct {
+	ProductRetailerID string  `json:"product_retailer_id"`
+	Quantity          Decimal `json:"quantity"`
+	ItemPrice         Decimal `json:"item_price"` // Unit price in the currency's main unit
+	Currency          string  `json:"currency"`
+}
+
+// Decimal is a number Meta may send either bare or quoted
+type Decimal float64
+
+// UnmarshalJSON accepts 12.5 and "12.5"
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*d = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*d = Decimal(v)
+	return nil
+}
+
 // MetaAPIResponse represents a successful API response from Meta
 type MetaAPIResponse struct {
 	Messages []struct {
@@ -40,7 +137,9 @@ type MetaAPIError struct {
 
 // TemplateResponse represents response from template submission
 type TemplateResponse struct {
-	ID string `json:"id"`
+	ID       string `json:"id"`
+	Status   string `json:"status,omitempty"`
+	Category string `json:"category,omitempty"`
 }
 
 // MetaTemplate represents a template fetched from Meta
@@ -73,13 +172,17 @@ type TemplateButton struct {
 
 // TemplateExample represents example values for template variables
 type TemplateExample struct {
-	HeaderText []string   `json:"header_text,omitempty"`
-	BodyText   [][]string `json:"body_text,omitempty"`
+	HeaderText   []string   `json:"header_text,omitempty"`
+	HeaderHandle []string   `json:"header_handle,omitempty"`
+	BodyText     [][]string `json:"body_text,omitempty"`
 }
 
 // TemplateListResponse represents response from fetching templates
 type TemplateListResponse struct {
-	Data []MetaTemplate `json:"data"`
+	Data   []MetaTemplate `json:"data"`
+	Paging struct {
+		Next string `json:"next"`
+	} `json:"paging"`
 }
 
 // WebhookPayload represents the incoming webhook from Meta
@@ -0,0 +1,177 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPIVersion is the Graph API version accounts use when none is configured
+const DefaultAPIVersion = "v21.0"
+
+// DeprecationWarning is how long before a version's sunset accounts still on it
+// are warned
+const DeprecationWarning = 90 * 24 * time.Hour
+
+// Graph API version statuses
+const (
+	APIVersionCurrent     = "current"
+	APIVersionDeprecating = "deprecating" // Sunsets within DeprecationWarning
+	APIVersionSunset      = "sunset"      // Past its sunset; requests use the oldest available version
+	APIVersionUnknown     = "unknown"     // Not a version this client knows
+)
+
+// APIVersionInfo describes a Graph API version. Sunset is nil until Meta releases
+// the next version.
+type APIVersionInfo struct {
+	Version  string     `json:"version"`
+	Released time.Time  `json:"released"`
+	Sunset   *time.Time `json:"sunset,omitempty"`
+}
+
+// apiVersionReleases are the Graph API versions this client has been used with,
+// oldest first. Meta keeps a version usable for two years after the next version is
+// released, which gives each version's sunset.
+var apiVersionReleases = []struct {
+	version  string
+	released string
+}{
+	{"v16.0", "2023-02-02"},
+	{"v17.0", "2023-05-23"},
+	{"v18.0", "2023-09-12"},
+	{"v19.0", "2024-01-23"},
+	{"v20.0", "2024-05-21"},
+	{"v21.0", "2024-10-02"},
+	{"v22.0", "2025-01-21"},
+	{"v23.0", "2025-05-29"},
+	{"v24.0", "2025-10-08"},
+}
+
+// APIVersions returns the known Graph API versions with their sunsets, oldest first
+func APIVersions() []APIVersionInfo {
+	versions := make([]APIVersionInfo, len(apiVersionReleases))
+	for i, r := range apiVersionReleases {
+		released, _ := time.Parse("2006-01-02", r.released)
+		versions[i] = APIVersionInfo{Version: r.version, Released: released}
+		if i > 0 {
+			sunset := released.AddDate(2, 0, 0)
+			versions[i-1].Sunset = &sunset
+		}
+	}
+	return versions
+}
+
+// LatestAPIVersion returns the newest Graph API version this client knows
+func LatestAPIVersion() string {
+	return apiVersionReleases[len(apiVersionReleases)-1].version
+}
+
+// NormalizeAPIVersion returns a Graph API version in the form Meta uses in URLs,
+// accepting e.g. "21", "21.0", "v21" and "V21.0" for "v21.0"
+func NormalizeAPIVersion(v string) (string, error) {
+	s := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), "v")
+	major, minor, found := strings.Cut(s, ".")
+	if !found {
+		minor = "0"
+	}
+	ma, err := strconv.Atoi(major)
+	if err != nil || ma <= 0 {
+		return "", fmt.Errorf("invalid Graph API version %q", v)
+	}
+	mi, err := strconv.Atoi(minor)
+	if err != nil || mi < 0 {
+		return "", fmt.Errorf("invalid Graph API version %q", v)
+	}
+	return fmt.Sprintf("v%d.%d", ma, mi), nil
+}
+
+// CompareAPIVersions returns -1, 0 or 1 as version a is older than, the same as or
+// newer than b. Both must be normalized.
+func CompareAPIVersions(a, b string) int {
+	pa, pb := parseAPIVersion(a), parseAPIVersion(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func parseAPIVersion(v string) [2]int {
+	major, minor, _ := strings.Cut(strings.TrimPrefix(v, "v"), ".")
+	ma, _ := strconv.Atoi(major)
+	mi, _ := strconv.Atoi(minor)
+	return [2]int{ma, mi}
+}
+
+// LookupAPIVersion returns what is known about a Graph API version
+func LookupAPIVersion(v string) (APIVersionInfo, bool) {
+	normalized, err := NormalizeAPIVersion(v)
+	if err != nil {
+		return APIVersionInfo{}, false
+	}
+	for _, info := range APIVersions() {
+		if info.Version == normalized {
+			return info, true
+		}
+	}
+	return APIVersionInfo{}, false
+}
+
+// APIVersionStatus returns whether a Graph API version is current, nearing its
+// sunset or past it at now, and its sunset if one is known. Versions newer than the
+// latest known are current and versions older than the oldest known are past their
+// sunset.
+func APIVersionStatus(v string, now time.Time) (string, *time.Time) {
+	normalized, err := NormalizeAPIVersion(v)
+	if err != nil {
+		return APIVersionUnknown, nil
+	}
+	info, ok := LookupAPIVersion(normalized)
+	if !ok {
+		switch {
+		case CompareAPIVersions(normalized, LatestAPIVersion()) > 0:
+			return APIVersionCurrent, nil
+		case CompareAPIVersions(normalized, apiVersionReleases[0].version) < 0:
+			return APIVersionSunset, nil
+		}
+		return APIVersionUnknown, nil
+	}
+	switch {
+	case info.Sunset == nil:
+		return APIVersionCurrent, nil
+	case !now.Before(*info.Sunset):
+		return APIVersionSunset, info.Sunset
+	case info.Sunset.Sub(now) <= DeprecationWarning:
+		return APIVersionDeprecating, info.Sunset
+	}
+	return APIVersionCurrent, info.Sunset
+}
+
+// ResolveAPIVersion returns the version requests for an account configured with v
+// are sent with: v normalized, DefaultAPIVersion when v is empty, and the oldest
+// available version when v is past its sunset, which is what Meta would serve. A
+// version that doesn't parse is returned unchanged for Meta to reject.
+func ResolveAPIVersion(v string) string {
+	if strings.TrimSpace(v) == "" {
+		return DefaultAPIVersion
+	}
+	normalized, err := NormalizeAPIVersion(v)
+	if err != nil {
+		return v
+	}
+	now := time.Now()
+	if status, _ := APIVersionStatus(normalized, now); status != APIVersionSunset {
+		return normalized
+	}
+	for _, info := range APIVersions() {
+		if info.Sunset == nil || now.Before(*info.Sunset) {
+			return info.Version
+		}
+	}
+	return LatestAPIVersion()
+}